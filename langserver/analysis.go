@@ -0,0 +1,88 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/deepequalerrors"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/analysis/passes/shadow"
+)
+
+// analysisSource is the source of the diagnostics of the analyzers. Their
+// code is the name of their analyzer, e.g. "printf".
+const analysisSource = "LSP: Go analysis"
+
+// optionalAnalyzers are the analyzers which are disabled by default, unlike
+// the vet analyzers.
+var optionalAnalyzers = []*analysis.Analyzer{
+	deepequalerrors.Analyzer,
+	nilness.Analyzer,
+	shadow.Analyzer,
+}
+
+// newAnalyzers returns the analyzers of the diagnostics: the vet analyzers
+// and the optional analyzers, enabled or disabled by Config.Analyses.
+// Unknown analyzers are logged and ignored.
+func newAnalyzers(analyses map[string]bool) []*analysis.Analyzer {
+	all := map[string]*analysis.Analyzer{}
+	enabled := map[string]bool{}
+	for _, a := range source.VetAnalyzers {
+		all[a.Name] = a
+		enabled[a.Name] = true
+	}
+	for _, a := range optionalAnalyzers {
+		all[a.Name] = a
+	}
+	for name, enable := range analyses {
+		if all[name] == nil {
+			log.Printf("unknown analyzer %q", name)
+			continue
+		}
+		enabled[name] = enable
+	}
+
+	var analyzers []*analysis.Analyzer
+	for name, a := range all {
+		if enabled[name] {
+			analyzers = append(analyzers, a)
+		}
+	}
+	sort.Slice(analyzers, func(i, j int) bool {
+		return analyzers[i].Name < analyzers[j].Name
+	})
+	return analyzers
+}
+
+// analysisDiagnostics runs the analyzers over pkg, unless it has errors, and
+// returns their findings by file.
+func analysisDiagnostics(ctx context.Context, v source.View, pkg source.Package, analyzers []*analysis.Analyzer) map[string][]lsp.Diagnostic {
+	if len(analyzers) == 0 || pkg.IsIllTyped() || pkg.GetTypesInfo() == nil || len(pkg.GetErrors()) > 0 {
+		return nil
+	}
+
+	fset := pkg.GetFileSet()
+	reports := map[string][]lsp.Diagnostic{}
+	err := source.RunAnalyses(ctx, v, pkg, analyzers, func(a *analysis.Analyzer, diag analysis.Diagnostic) {
+		pos := fset.Position(diag.Pos)
+		if !pos.IsValid() {
+			return
+		}
+		start := lsp.Position{Line: pos.Line - 1, Character: pos.Column - 1}
+		reports[pos.Filename] = append(reports[pos.Filename], lsp.Diagnostic{
+			Range:    lsp.Range{Start: start, End: start},
+			Severity: lsp.Warning,
+			Code:     a.Name,
+			Source:   analysisSource,
+			Message:  diag.Message,
+		})
+	})
+	if err != nil {
+		log.Printf("analyze %s: %s", pkg.GetPkgPath(), err)
+	}
+	return reports
+}
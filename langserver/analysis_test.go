@@ -0,0 +1,33 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestNewAnalyzers(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	names := func(analyzers []*analysis.Analyzer) map[string]bool {
+		m := map[string]bool{}
+		for _, a := range analyzers {
+			m[a.Name] = true
+		}
+		return m
+	}
+
+	defaults := names(newAnalyzers(nil))
+	require.Len(defaults, len(source.VetAnalyzers))
+	require.True(defaults["printf"])
+	require.False(defaults["shadow"])
+
+	configured := names(newAnalyzers(map[string]bool{"printf": false, "shadow": true, "staticcheck": true}))
+	require.False(configured["printf"])
+	require.True(configured["shadow"])
+	require.False(configured["staticcheck"])
+	require.Len(configured, len(source.VetAnalyzers))
+}
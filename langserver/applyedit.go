@@ -0,0 +1,38 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// createFileEdit returns the edit which creates the file uri with text. An
+// existing file is overwritten, so that the edit does not depend on its
+// content.
+func createFileEdit(uri lsp.DocumentURI, text string) *protocol.WorkspaceEdit {
+	return &protocol.WorkspaceEdit{
+		DocumentChanges: []interface{}{
+			protocol.CreateFile{Kind: "create", URI: uri, Options: &protocol.CreateFileOptions{Overwrite: true}},
+			protocol.TextDocumentEdit{
+				TextDocument: lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}},
+				Edits:        []lsp.TextEdit{{NewText: text}},
+			},
+		},
+	}
+}
+
+// applyEdit asks the client to apply edit with workspace/applyEdit.
+func applyEdit(ctx context.Context, conn jsonrpc2.JSONRPC2, label string, edit *protocol.WorkspaceEdit) error {
+	var resp protocol.ApplyWorkspaceEditResponse
+	err := conn.Call(ctx, "workspace/applyEdit", &protocol.ApplyWorkspaceEditParams{Label: label, Edit: *edit}, &resp)
+	if err != nil {
+		return err
+	}
+	if !resp.Applied {
+		return fmt.Errorf("%s has not been applied: %s", label, resp.FailureReason)
+	}
+	return nil
+}
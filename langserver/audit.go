@@ -0,0 +1,253 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// AuditEntry is a line of the audit log, see Config.AuditLog.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+
+	// Root is the workspace of the client.
+	Root lsp.DocumentURI `json:"root,omitempty"`
+
+	// Operation is the method of the request, eg. textDocument/formatting,
+	// textDocument/rename or workspace/executeCommand, workspace/applyEdit for
+	// the edits pushed to the client, or exec for the commands run.
+	Operation string `json:"operation"`
+
+	// Command is the command of workspace/executeCommand, or the command line
+	// run.
+	Command   string        `json:"command,omitempty"`
+	Arguments []interface{} `json:"arguments,omitempty"`
+	Dir       string        `json:"dir,omitempty"`
+	ExitCode  *int          `json:"exitCode,omitempty"`
+
+	// Label is the label of the edit pushed to the client.
+	Label string `json:"label,omitempty"`
+
+	// Files are the number of edits per file URI of the edits, the creations,
+	// renames and deletions of files counting as one edit.
+	Files map[string]int `json:"files,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// auditLog appends an AuditEntry per line to a file.
+type auditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// auditLogs are the open audit logs by file name, which the clients of the
+// process share.
+var auditLogs = struct {
+	sync.Mutex
+	m map[string]*auditLog
+}{m: map[string]*auditLog{}}
+
+// openAuditLog returns the audit log appending to filename. An empty
+// filename disables the audit log, in which case openAuditLog returns nil.
+func openAuditLog(filename string) *auditLog {
+	if filename == "" {
+		return nil
+	}
+
+	auditLogs.Lock()
+	defer auditLogs.Unlock()
+	if a, ok := auditLogs.m[filename]; ok {
+		return a
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		log.Printf("open audit log %s: %s", filename, err)
+		return nil
+	}
+	a := &auditLog{f: f}
+	auditLogs.m[filename] = a
+	return a
+}
+
+// record appends entry to the log.
+func (a *auditLog) record(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("audit %s: %s", entry.Operation, err)
+		return
+	}
+
+	// A single write keeps the lines whole when several servers append to
+	// the same file.
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(append(data, '\n')); err != nil {
+		log.Printf("write audit log %s: %s", a.f.Name(), err)
+	}
+}
+
+// auditedMethods are the requests whose results edit the documents, or which
+// run commands.
+var auditedMethods = map[string]bool{
+	"textDocument/formatting":      true,
+	"textDocument/rangeFormatting": true,
+	"textDocument/rename":          true,
+	"workspace/executeCommand":     true,
+}
+
+// audit records entry, in the workspace of the client.
+func (h *LangHandler) audit(entry AuditEntry) {
+	if h.auditLog == nil {
+		return
+	}
+	h.mu.Lock()
+	if h.init != nil {
+		entry.Root = h.init.Root()
+	}
+	h.mu.Unlock()
+	h.auditLog.record(entry)
+}
+
+// auditRequest records the edits of the result of req and the commands it
+// executed. The formatting which does not edit anything is not recorded.
+func (h *LangHandler) auditRequest(req *jsonrpc2.Request, result interface{}, err error) {
+	if h.auditLog == nil || !auditedMethods[req.Method] || req.Params == nil {
+		return
+	}
+	entry := AuditEntry{Operation: req.Method}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	switch req.Method {
+	case "workspace/executeCommand":
+		var params lsp.ExecuteCommandParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return
+		}
+		entry.Command, entry.Arguments = params.Command, params.Arguments
+	case "textDocument/rename":
+		entry.Files = auditedEdits("", result)
+	default:
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return
+		}
+		entry.Files = auditedEdits(string(params.TextDocument.URI), result)
+		if len(entry.Files) == 0 && err == nil {
+			return
+		}
+	}
+	h.audit(entry)
+}
+
+// auditExec records the command cmd, which ran with the error err.
+func (h *LangHandler) auditExec(cmd *exec.Cmd, err error) {
+	entry := AuditEntry{Operation: "exec", Command: strings.Join(cmd.Args, " "), Dir: cmd.Dir}
+	if cmd.ProcessState != nil {
+		code := cmd.ProcessState.ExitCode()
+		entry.ExitCode = &code
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	h.audit(entry)
+}
+
+// auditConn returns conn, recording the edits pushed to the client with
+// workspace/applyEdit.
+func (h *LangHandler) auditConn(conn jsonrpc2.JSONRPC2) jsonrpc2.JSONRPC2 {
+	if h.auditLog == nil {
+		return conn
+	}
+	return auditConn{JSONRPC2: conn, h: h}
+}
+
+type auditConn struct {
+	jsonrpc2.JSONRPC2
+	h *LangHandler
+}
+
+func (c auditConn) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	err := c.JSONRPC2.Call(ctx, method, params, result, opt...)
+	if method != "workspace/applyEdit" {
+		return err
+	}
+
+	// The parameters may have been translated into raw JSON.
+	var edit struct {
+		Label string          `json:"label"`
+		Edit  json.RawMessage `json:"edit"`
+	}
+	if data, err := json.Marshal(params); err == nil {
+		_ = json.Unmarshal(data, &edit)
+	}
+	entry := AuditEntry{Operation: method, Label: edit.Label, Files: auditedEdits("", edit.Edit)}
+	if resp, ok := result.(*protocol.ApplyWorkspaceEditResponse); ok && err == nil && !resp.Applied {
+		entry.Error = "not applied: " + resp.FailureReason
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	c.h.audit(entry)
+	return err
+}
+
+// auditedEdits returns the number of edits per file URI of v, the edits of
+// the document uri if it is set, or a workspace edit otherwise.
+func auditedEdits(uri string, v interface{}) map[string]int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	files := map[string]int{}
+	if uri != "" {
+		var edits []json.RawMessage
+		if json.Unmarshal(data, &edits) == nil && len(edits) > 0 {
+			files[uri] = len(edits)
+		}
+		return files
+	}
+
+	var edit struct {
+		Changes         map[string][]json.RawMessage `json:"changes"`
+		DocumentChanges []struct {
+			TextDocument *lsp.TextDocumentIdentifier `json:"textDocument"`
+			Edits        []json.RawMessage           `json:"edits"`
+			URI          string                      `json:"uri"`
+			OldURI       string                      `json:"oldUri"`
+		} `json:"documentChanges"`
+	}
+	if json.Unmarshal(data, &edit) != nil {
+		return nil
+	}
+	for uri, edits := range edit.Changes {
+		files[uri] += len(edits)
+	}
+	for _, change := range edit.DocumentChanges {
+		switch {
+		case change.TextDocument != nil:
+			files[string(change.TextDocument.URI)] += len(change.Edits)
+		case change.OldURI != "":
+			files[change.OldURI]++
+		case change.URI != "":
+			files[change.URI]++
+		}
+	}
+	return files
+}
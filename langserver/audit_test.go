@@ -0,0 +1,120 @@
+package langserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+// readAuditLog returns the entries of the audit log filename.
+func readAuditLog(t *testing.T, filename string) []AuditEntry {
+	t.Helper()
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), scanner.Text())
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestAuditedEdits(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(map[string]int{"file:///a.go": 2}, auditedEdits("file:///a.go", []lsp.TextEdit{{}, {}}))
+	require.Empty(auditedEdits("file:///a.go", []lsp.TextEdit{}))
+
+	edit := &protocol.WorkspaceEdit{
+		Changes: map[string][]lsp.TextEdit{"file:///a.go": {{}, {}}},
+		DocumentChanges: []interface{}{
+			protocol.TextDocumentEdit{
+				TextDocument: lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: "file:///a.go"}},
+				Edits:        []lsp.TextEdit{{}},
+			},
+			protocol.CreateFile{Kind: "create", URI: "file:///b.go"},
+		},
+	}
+	require.Equal(map[string]int{"file:///a.go": 3, "file:///b.go": 1}, auditedEdits("", edit))
+}
+
+// applyEditConn answers workspace/applyEdit with applied.
+type applyEditConn struct {
+	jsonrpc2.JSONRPC2
+	applied bool
+}
+
+func (c applyEditConn) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	result.(*protocol.ApplyWorkspaceEditResponse).Applied = c.applied
+	return nil
+}
+
+func TestAuditConn(t *testing.T) {
+	require := require.New(t)
+	filename := filepath.Join(writeWorkspace(t, nil), "audit.log")
+	h := &LangHandler{auditLog: openAuditLog(filename)}
+	require.True(h.auditLog == openAuditLog(filename), "the clients share the audit log")
+
+	edit := createFileEdit("file:///a.go", "package a\n")
+	require.NoError(applyEdit(context.Background(), h.auditConn(applyEditConn{applied: true}), "create a.go", edit))
+	require.Error(applyEdit(context.Background(), h.auditConn(applyEditConn{}), "create a.go", edit))
+
+	entries := readAuditLog(t, filename)
+	require.Len(entries, 2)
+	require.Equal("workspace/applyEdit", entries[0].Operation)
+	require.Equal("create a.go", entries[0].Label)
+	require.Equal(map[string]int{"file:///a.go": 2}, entries[0].Files)
+	require.Empty(entries[0].Error)
+	require.Equal("not applied: ", entries[1].Error)
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/audit\n",
+		"a.go":   "package audit\n\nfunc  A() {}\n",
+		"b.go":   "package audit\n\nfunc B() {}\n",
+	})
+	filename := filepath.Join(writeWorkspace(t, nil), "audit.log")
+	cfg := testConfig(cache.Ondemand)
+	cfg.AuditLog = filename
+	tx := newWorkspaceContext(t, cfg, root)
+
+	format := func(name string) {
+		var edits []lsp.TextEdit
+		require.NoError(tx.conn.Call(tx.ctx, "textDocument/formatting", lsp.DocumentFormattingParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: util.PathToURI(filepath.ToSlash(filepath.Join(root, name)))},
+		}, &edits))
+	}
+	format("a.go")
+	format("b.go")
+	err := tx.conn.Call(tx.ctx, "workspace/executeCommand", lsp.ExecuteCommandParams{Command: "bingo.unknown", Arguments: []interface{}{root}}, nil)
+	require.Error(err)
+
+	entries := readAuditLog(t, filename)
+	require.Len(entries, 2, "the formatting which does not edit anything is not recorded")
+	require.Equal("textDocument/formatting", entries[0].Operation)
+	require.Equal(lsp.DocumentURI(util.PathToURI(filepath.ToSlash(root))), entries[0].Root)
+	require.Len(entries[0].Files, 1)
+	require.NotZero(entries[0].Files[string(util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go"))))])
+	require.Equal("workspace/executeCommand", entries[1].Operation)
+	require.Equal("bingo.unknown", entries[1].Command)
+	require.Equal([]interface{}{root}, entries[1].Arguments)
+	require.Contains(entries[1].Error, "unknown command")
+}
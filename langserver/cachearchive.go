@@ -0,0 +1,42 @@
+package langserver
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+// ExportCache writes the disk cache of the workspace dir, which is saved in
+// the always cache style, to w as an archive for ImportCache, e.g. to publish
+// a warm cache from the CI. It returns the number of the exported packages.
+func ExportCache(dir string, w io.Writer) (int, error) {
+	rootDir, err := cacheRoot(dir)
+	if err != nil {
+		return 0, err
+	}
+	return cache.ExportCache(rootDir, w)
+}
+
+// ImportCache replaces the disk cache of the workspace dir with the packages
+// of the archive written by ExportCache whose files are the same here, so
+// that they are not loaded on the first start. It returns the number of the
+// imported packages.
+func ImportCache(dir string, r io.Reader) (int, error) {
+	rootDir, err := cacheRoot(dir)
+	if err != nil {
+		return 0, err
+	}
+	return cache.ImportCache(rootDir, r)
+}
+
+// cacheRoot returns the root path of the workspace dir as the handler
+// computes it from the root URI, which identifies its disk cache.
+func cacheRoot(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return util.LowerDriver(util.GetRealPath(abs)), nil
+}
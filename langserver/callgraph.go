@@ -0,0 +1,377 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// callGraphCommand is the workspace/executeCommand command which exports the
+// call graph of a function.
+const callGraphCommand = "bingo.callgraph"
+
+// maxCallGraphNodes bounds the size of an exported call graph.
+const maxCallGraphNodes = 2000
+
+// CallGraphParams is the argument of the bingo.callgraph command. The
+// position selects the root function.
+type CallGraphParams struct {
+	lsp.TextDocumentPositionParams
+
+	// Algorithm is the call graph construction algorithm, "cha" or "rta".
+	// Defaults to "cha".
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Direction is "callees" to follow the calls made by the root function,
+	// or "callers" to find how the root function is reached. Defaults to
+	// "callees".
+	Direction string `json:"direction,omitempty"`
+
+	// Format is "json" or "dot". Defaults to "json".
+	Format string `json:"format,omitempty"`
+}
+
+// CallGraph is the json form of a call graph.
+type CallGraph struct {
+	Nodes []CallGraphNode `json:"nodes"`
+	Edges []CallGraphEdge `json:"edges"`
+
+	// Truncated is set if the graph has more than maxCallGraphNodes nodes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// CallGraphNode is a function of a call graph. The root function has the ID 0.
+type CallGraphNode struct {
+	ID       int          `json:"id"`
+	Name     string       `json:"name"`
+	Location lsp.Location `json:"location"`
+}
+
+// CallGraphEdge is a call site of a call graph.
+type CallGraphEdge struct {
+	Caller   int          `json:"caller"`
+	Callee   int          `json:"callee"`
+	Location lsp.Location `json:"location"`
+}
+
+func (h *LangHandler) handleCallGraph(ctx context.Context, params CallGraphParams) (interface{}, error) {
+	prog, root, err := h.ssaFunctionAt(ctx, params.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+
+	cg, err := prog.callGraph(params.Algorithm, root)
+	if err != nil {
+		return nil, err
+	}
+
+	var graph *CallGraph
+	switch params.Direction {
+	case "", "callees":
+		graph = prog.exportCallGraph(cg.Nodes[root], false)
+	case "callers":
+		graph = prog.exportCallGraph(cg.Nodes[root], true)
+	default:
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("unknown call graph direction: %s", params.Direction))
+	}
+
+	switch params.Format {
+	case "", "json":
+		return graph, nil
+	case "dot":
+		return graph.dot(), nil
+	default:
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("unknown call graph format: %s", params.Format))
+	}
+}
+
+// ssaProgram is the SSA form of the cached packages.
+type ssaProgram struct {
+	*ssa.Program
+
+	// fsets maps the packages to the file set of their syntax, because the
+	// cached packages do not share a single file set.
+	fsets map[*types.Package]*token.FileSet
+
+	// infos maps the packages to their type information.
+	infos map[*types.Package]*types.Info
+}
+
+// ssaFunctionAt builds the SSA program of the cached packages and of the
+// package at position, and returns the function declared or referenced at
+// position.
+func (h *LangHandler) ssaFunctionAt(ctx context.Context, position lsp.TextDocumentPositionParams) (*ssaProgram, *ssa.Function, error) {
+	pkg, pos, err := h.typeCheck(ctx, position.TextDocument.URI, position.Position)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ident *ast.Ident
+	switch node := pathNodes[0].(type) {
+	case *ast.Ident:
+		ident = node
+	case *ast.FuncDecl:
+		ident = node.Name
+	default:
+		return nil, nil, source.NewInvalidNodeError(pkg.GetFileSet(), pathNodes[0])
+	}
+	obj, ok := pkg.GetTypesInfo().ObjectOf(ident).(*types.Func)
+	if !ok {
+		return nil, nil, requestErrorf(InvalidPositionError, "not a function or method")
+	}
+
+	prog, err := h.ssaProgram(ctx, pkg, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fn := prog.function(obj)
+	if fn == nil {
+		return nil, nil, requestErrorf(InvalidPositionError, "no function body found for %s", obj.FullName())
+	}
+	return prog, fn, nil
+}
+
+// ssaProgram builds the SSA form of every well typed cached package, and of
+// extra if it is not cached. The other packages are created from their types
+// only.
+func (h *LangHandler) ssaProgram(ctx context.Context, extra source.Package, mode ssa.BuilderMode) (*ssaProgram, error) {
+	byPath := map[string]source.Package{}
+	err := h.project.Search(func(pkg source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if pkg.GetTypes() != nil {
+			byPath[pkg.GetPkgPath()] = pkg
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := byPath[extra.GetPkgPath()]; !ok {
+		byPath[extra.GetPkgPath()] = extra
+	}
+
+	prog := &ssaProgram{
+		Program: ssa.NewProgram(extra.GetFileSet(), mode),
+		fsets:   map[*types.Package]*token.FileSet{},
+		infos:   map[*types.Package]*types.Info{},
+	}
+	var createTypes func(pkg *types.Package)
+	createTypes = func(pkg *types.Package) {
+		if prog.Package(pkg) != nil {
+			return
+		}
+		prog.CreatePackage(pkg, nil, nil, true)
+		for _, imp := range pkg.Imports() {
+			createTypes(imp)
+		}
+	}
+
+	for _, pkg := range byPath {
+		if pkg.IsIllTyped() || pkg.GetTypesInfo() == nil {
+			continue
+		}
+		prog.CreatePackage(pkg.GetTypes(), pkg.GetSyntax(), pkg.GetTypesInfo(), true)
+		prog.fsets[pkg.GetTypes()] = pkg.GetFileSet()
+		prog.infos[pkg.GetTypes()] = pkg.GetTypesInfo()
+	}
+	for _, pkg := range byPath {
+		createTypes(pkg.GetTypes())
+		for _, imp := range pkg.GetTypes().Imports() {
+			createTypes(imp)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	prog.Build()
+	return prog, nil
+}
+
+// function returns the SSA function of obj. Objects of the type checked
+// package of an open file are not the ones of the program, so obj is looked
+// up by its package path and name.
+func (prog *ssaProgram) function(obj *types.Func) *ssa.Function {
+	if obj.Pkg() == nil {
+		return nil
+	}
+	pkg := prog.ImportedPackage(obj.Pkg().Path())
+	if pkg == nil {
+		// Packages which are not importable, eg. main packages.
+		for _, p := range prog.AllPackages() {
+			if p.Pkg.Path() == obj.Pkg().Path() {
+				pkg = p
+				break
+			}
+		}
+	}
+	if pkg == nil {
+		return nil
+	}
+
+	sig := obj.Type().(*types.Signature)
+	if sig.Recv() == nil {
+		return pkg.Func(obj.Name())
+	}
+
+	named, ok := source.Deref(sig.Recv().Type()).(*types.Named)
+	if !ok {
+		return nil
+	}
+	T := pkg.Type(named.Obj().Name())
+	if T == nil || types.IsInterface(T.Type()) {
+		return nil
+	}
+	// Look the method up in the method set of its receiver, so that we do
+	// not get the wrapper of a value method for a pointer.
+	recv := T.Type()
+	if _, ok := sig.Recv().Type().(*types.Pointer); ok {
+		recv = types.NewPointer(recv)
+	}
+	sel := prog.MethodSets.MethodSet(recv).Lookup(pkg.Pkg, obj.Name())
+	if sel == nil {
+		return nil
+	}
+	return prog.MethodValue(sel)
+}
+
+// callGraph computes the call graph of the program with algorithm. The rta
+// algorithm is rooted at root and at the main functions of the program.
+func (prog *ssaProgram) callGraph(algorithm string, root *ssa.Function) (*callgraph.Graph, error) {
+	switch algorithm {
+	case "", "cha":
+		return cha.CallGraph(prog.Program), nil
+	case "rta":
+		roots := []*ssa.Function{root}
+		for _, mainPkg := range ssautil.MainPackages(prog.AllPackages()) {
+			if fn := mainPkg.Func("main"); fn != nil {
+				roots = append(roots, mainPkg.Func("init"), fn)
+			}
+		}
+		return rta.Analyze(roots, true).CallGraph, nil
+	default:
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("unknown call graph algorithm: %s", algorithm))
+	}
+}
+
+// location returns the location of pos in the syntax of pkg.
+func (prog *ssaProgram) location(pkg *types.Package, pos token.Pos, name string) lsp.Location {
+	fset := prog.fsets[pkg]
+	if fset == nil || !pos.IsValid() {
+		return lsp.Location{}
+	}
+	return goRangeToLSPLocation(fset, pos, name)
+}
+
+// funcLocation returns the location of the declaration of fn.
+func (prog *ssaProgram) funcLocation(fn *ssa.Function) lsp.Location {
+	if obj := fn.Object(); obj != nil {
+		return prog.location(obj.Pkg(), obj.Pos(), obj.Name())
+	}
+	if fn.Pkg != nil {
+		return prog.location(fn.Pkg.Pkg, fn.Pos(), "")
+	}
+	return lsp.Location{}
+}
+
+// siteLocation returns the location of the call site of edge.
+func (prog *ssaProgram) siteLocation(edge *callgraph.Edge) lsp.Location {
+	if edge.Site == nil || edge.Caller.Func.Pkg == nil {
+		return lsp.Location{}
+	}
+	return prog.location(edge.Caller.Func.Pkg.Pkg, edge.Pos(), "")
+}
+
+// exportCallGraph walks the graph from root, following the calls made by
+// the functions, or the calls to them if reverse is set.
+func (prog *ssaProgram) exportCallGraph(root *callgraph.Node, reverse bool) *CallGraph {
+	graph := &CallGraph{Nodes: []CallGraphNode{}, Edges: []CallGraphEdge{}}
+	if root == nil {
+		return graph
+	}
+
+	ids := map[*callgraph.Node]int{}
+	var queue []*callgraph.Node
+	visit := func(n *callgraph.Node) (int, bool) {
+		if id, ok := ids[n]; ok {
+			return id, true
+		}
+		if len(ids) >= maxCallGraphNodes {
+			graph.Truncated = true
+			return 0, false
+		}
+		id := len(ids)
+		ids[n] = id
+		graph.Nodes = append(graph.Nodes, CallGraphNode{
+			ID:       id,
+			Name:     n.Func.String(),
+			Location: prog.funcLocation(n.Func),
+		})
+		queue = append(queue, n)
+		return id, true
+	}
+
+	visit(root)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		edges := n.Out
+		if reverse {
+			edges = n.In
+		}
+		for _, edge := range edges {
+			other := edge.Callee
+			if reverse {
+				other = edge.Caller
+			}
+			otherID, ok := visit(other)
+			if !ok {
+				continue
+			}
+			caller, callee := ids[n], otherID
+			if reverse {
+				caller, callee = otherID, ids[n]
+			}
+			graph.Edges = append(graph.Edges, CallGraphEdge{
+				Caller:   caller,
+				Callee:   callee,
+				Location: prog.siteLocation(edge),
+			})
+		}
+	}
+	return graph
+}
+
+// dot returns the graph in the Graphviz dot language.
+func (g *CallGraph) dot() string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph callgraph {\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&buf, "\tn%d [label=%s];\n", n.ID, strconv.Quote(n.Name))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&buf, "\tn%d -> n%d;\n", e.Caller, e.Callee)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
@@ -0,0 +1,109 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// buildTestProgram builds the SSA program of a single package without
+// imports.
+func buildTestProgram(t *testing.T, src string) (*ssaProgram, *ssa.Package) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "/src/p/p.go", src, 0)
+	require.NoError(t, err)
+
+	pkg, _, err := ssautil.BuildPackage(&types.Config{}, fset, types.NewPackage("p", "p"), []*ast.File{f}, 0)
+	require.NoError(t, err)
+
+	prog := &ssaProgram{
+		Program: pkg.Prog,
+		fsets:   map[*types.Package]*token.FileSet{pkg.Pkg: fset},
+	}
+	return prog, pkg
+}
+
+func TestExportCallGraph(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	prog, pkg := buildTestProgram(t, `package p
+
+type T struct{}
+
+func (*T) m() { c() }
+
+func a() { b(); new(T).m() }
+
+func b() { c() }
+
+func c() {}
+`)
+
+	root := pkg.Func("a")
+	cg, err := prog.callGraph("cha", root)
+	require.NoError(err)
+
+	names := func(g *CallGraph) []string {
+		var names []string
+		for _, n := range g.Nodes {
+			names = append(names, n.Name)
+		}
+		return names
+	}
+
+	callees := prog.exportCallGraph(cg.Nodes[root], false)
+	require.Equal([]string{"p.a", "p.b", "(*p.T).m", "p.c"}, names(callees))
+	require.Len(callees.Edges, 4)
+	require.Equal(6, callees.Nodes[0].Location.Range.Start.Line)
+
+	c := pkg.Func("c")
+	callers := prog.exportCallGraph(cg.Nodes[c], true)
+	// The order of the callers depends on the order of the analysis.
+	require.ElementsMatch([]string{"p.c", "(*p.T).m", "p.b", "p.a"}, names(callers))
+	require.Equal("p.c", callers.Nodes[0].Name)
+	for _, e := range callers.Edges {
+		require.NotEqual(0, e.Caller)
+	}
+}
+
+func TestSSAFunction(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	prog, pkg := buildTestProgram(t, `package p
+
+type T struct{}
+
+func (T) m() {}
+
+func f() {}
+`)
+
+	f := pkg.Pkg.Scope().Lookup("f").(*types.Func)
+	require.Equal(pkg.Func("f"), prog.function(f))
+
+	T := pkg.Pkg.Scope().Lookup("T").Type()
+	m, _, _ := types.LookupFieldOrMethod(T, false, pkg.Pkg, "m")
+	fn := prog.function(m.(*types.Func))
+	require.NotNil(fn)
+	require.Equal("m", fn.Name())
+	require.Empty(fn.Synthetic)
+}
+
+func TestCallGraphDot(t *testing.T) {
+	t.Parallel()
+
+	g := &CallGraph{
+		Nodes: []CallGraphNode{{ID: 0, Name: "p.a"}, {ID: 1, Name: "(*p.T).m"}},
+		Edges: []CallGraphEdge{{Caller: 0, Callee: 1}},
+	}
+	want := "digraph callgraph {\n\tn0 [label=\"p.a\"];\n\tn1 [label=\"(*p.T).m\"];\n\tn0 -> n1;\n}\n"
+	require.Equal(t, want, g.dot())
+}
@@ -0,0 +1,291 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func (h *LangHandler) handlePrepareCallHierarchy(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]protocol.CallHierarchyItem, error) {
+	fn, _, _, err := h.callHierarchyFunc(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if fn == nil {
+		return []protocol.CallHierarchyItem{}, nil
+	}
+	item, ok := h.callHierarchyItem(fn)
+	if !ok {
+		return []protocol.CallHierarchyItem{}, nil
+	}
+	return []protocol.CallHierarchyItem{item}, nil
+}
+
+func (h *LangHandler) handleIncomingCalls(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	fn, _, _, err := h.callHierarchyFunc(ctx, itemPosition(params.Item))
+	if err != nil || fn == nil {
+		return []protocol.CallHierarchyIncomingCall{}, err
+	}
+	refs, err := h.findReferences(ctx, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	// The references are grouped by the function declaration enclosing
+	// them, and the references outside of functions are dropped.
+	fset := h.project.View().FileSet()
+	calls := map[token.Position]*protocol.CallHierarchyIncomingCall{}
+	seen := map[lsp.Location]bool{}
+	for _, ref := range refs {
+		loc := goRangeToLSPLocation(fset, ref.Pos(), ref.Name)
+		pos := fset.Position(ref.Pos())
+		if seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		pkg := h.packageOf(pos.Filename)
+		if pkg == nil {
+			continue
+		}
+		decl := funcDeclAt(pkg.GetFileSet(), pkg.GetSyntax(), pos.Filename, pos.Offset)
+		if decl == nil {
+			continue
+		}
+		declPos := pkg.GetFileSet().Position(decl.Pos())
+		call := calls[declPos]
+		if call == nil {
+			call = &protocol.CallHierarchyIncomingCall{From: declItem(pkg.GetFileSet(), pkg.GetTypesInfo(), decl)}
+			calls[declPos] = call
+		}
+		call.FromRanges = append(call.FromRanges, loc.Range)
+	}
+
+	result := make([]protocol.CallHierarchyIncomingCall, 0, len(calls))
+	for _, call := range calls {
+		sortRanges(call.FromRanges)
+		result = append(result, *call)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return itemLess(result[i].From, result[j].From)
+	})
+	return result, nil
+}
+
+func (h *LangHandler) handleOutgoingCalls(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	fn, pkg, decl, err := h.callHierarchyFunc(ctx, itemPosition(params.Item))
+	if err != nil || fn == nil || decl == nil || decl.Body == nil {
+		return []protocol.CallHierarchyOutgoingCall{}, err
+	}
+
+	fset := pkg.GetFileSet()
+	calls := map[*types.Func]*protocol.CallHierarchyOutgoingCall{}
+	var callees []*types.Func
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var id *ast.Ident
+		switch fun := astutil.Unparen(call.Fun).(type) {
+		case *ast.Ident:
+			id = fun
+		case *ast.SelectorExpr:
+			id = fun.Sel
+		default:
+			return true
+		}
+		callee, ok := pkg.GetTypesInfo().Uses[id].(*types.Func)
+		if !ok {
+			return true
+		}
+		out := calls[callee]
+		if out == nil {
+			to, ok := h.callHierarchyItem(callee)
+			if !ok {
+				return true
+			}
+			out = &protocol.CallHierarchyOutgoingCall{To: to}
+			calls[callee] = out
+			callees = append(callees, callee)
+		}
+		out.FromRanges = append(out.FromRanges, rangeForNode(fset, id))
+		return true
+	})
+
+	result := make([]protocol.CallHierarchyOutgoingCall, 0, len(callees))
+	for _, callee := range callees {
+		result = append(result, *calls[callee])
+	}
+	return result, nil
+}
+
+// callHierarchyFunc returns the function at position, along with the package
+// and the declaration of the function if it is declared there, or nil if
+// there is no function at position.
+func (h *LangHandler) callHierarchyFunc(ctx context.Context, position lsp.TextDocumentPositionParams) (*types.Func, source.Package, *ast.FuncDecl, error) {
+	pkg, pos, err := h.typeCheck(ctx, position.TextDocument.URI, position.Position)
+	if err != nil {
+		// Invalid nodes means we tried to click on something which is
+		// not an ident (eg comment/string/etc). Return no information.
+		if _, ok := err.(*source.InvalidNodeError); ok {
+			return nil, nil, nil, nil
+		}
+		return nil, nil, nil, err
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var ident *ast.Ident
+	var decl *ast.FuncDecl
+	switch node := pathNodes[0].(type) {
+	case *ast.Ident:
+		ident = node
+		if len(pathNodes) > 1 {
+			if d, ok := pathNodes[1].(*ast.FuncDecl); ok && d.Name == node {
+				decl = d
+			}
+		}
+	case *ast.FuncDecl:
+		ident, decl = node.Name, node
+	default:
+		return nil, nil, nil, nil
+	}
+
+	fn, _ := source.FindIdentObject(pkg, ident).(*types.Func)
+	if fn == nil {
+		return nil, nil, nil, nil
+	}
+	return fn, pkg, decl, nil
+}
+
+// callHierarchyItem returns the item of fn, or false if fn has no position.
+func (h *LangHandler) callHierarchyItem(fn *types.Func) (protocol.CallHierarchyItem, bool) {
+	fset := h.project.View().FileSet()
+	pos := fset.Position(fn.Pos())
+	if !pos.IsValid() {
+		return protocol.CallHierarchyItem{}, false
+	}
+
+	if pkg := h.packageOf(pos.Filename); pkg != nil {
+		fset := pkg.GetFileSet()
+		if decl := funcDeclAt(fset, pkg.GetSyntax(), pos.Filename, pos.Offset); decl != nil && fset.Position(decl.Name.Pos()).Offset == pos.Offset {
+			return declItem(fset, pkg.GetTypesInfo(), decl), true
+		}
+	}
+
+	// The methods of interfaces have no declaration of their own.
+	loc := goRangeToLSPLocation(fset, fn.Pos(), fn.Name())
+	return protocol.CallHierarchyItem{
+		Name:           funcName(fn),
+		Kind:           funcKind(fn),
+		Detail:         funcDetail(fn),
+		URI:            loc.URI,
+		Range:          loc.Range,
+		SelectionRange: loc.Range,
+	}, true
+}
+
+// packageOf returns the package of the file filename.
+func (h *LangHandler) packageOf(filename string) source.Package {
+	if pkg := h.project.Cache().GetByURI(filename); pkg != nil {
+		return pkg
+	}
+	return h.project.GetFromURI(lsp.DocumentURI(source.ToURI(filename)))
+}
+
+// funcDeclAt returns the function declaration of files enclosing the offset
+// of the file filename. The offsets are compared rather than the positions,
+// which differ if the files have been parsed again.
+func funcDeclAt(fset *token.FileSet, files []*ast.File, filename string, offset int) *ast.FuncDecl {
+	for _, file := range files {
+		if fset.Position(file.Pos()).Filename != filename {
+			continue
+		}
+		for _, d := range file.Decls {
+			decl, ok := d.(*ast.FuncDecl)
+			if ok && fset.Position(decl.Pos()).Offset <= offset && offset < fset.Position(decl.End()).Offset {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// declItem returns the item of the function declaration decl.
+func declItem(fset *token.FileSet, info *types.Info, decl *ast.FuncDecl) protocol.CallHierarchyItem {
+	item := protocol.CallHierarchyItem{
+		Name:           decl.Name.Name,
+		Kind:           lsp.SKFunction,
+		URI:            lsp.DocumentURI(source.ToURI(fset.Position(decl.Pos()).Filename)),
+		Range:          rangeForNode(fset, decl),
+		SelectionRange: rangeForNode(fset, decl.Name),
+	}
+	if info != nil {
+		if fn, ok := info.Defs[decl.Name].(*types.Func); ok {
+			item.Name, item.Kind, item.Detail = funcName(fn), funcKind(fn), funcDetail(fn)
+		}
+	}
+	return item
+}
+
+// funcName returns the name of fn, qualified by its receiver type for a
+// method, eg. "(*T).M".
+func funcName(fn *types.Func) string {
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		return "(" + types.TypeString(recv.Type(), func(*types.Package) string { return "" }) + ")." + fn.Name()
+	}
+	return fn.Name()
+}
+
+func funcKind(fn *types.Func) lsp.SymbolKind {
+	if fn.Type().(*types.Signature).Recv() != nil {
+		return lsp.SKMethod
+	}
+	return lsp.SKFunction
+}
+
+// funcDetail returns the import path of the package of fn.
+func funcDetail(fn *types.Func) string {
+	if fn.Pkg() == nil {
+		return ""
+	}
+	return fn.Pkg().Path()
+}
+
+// itemPosition returns the position of the name of item.
+func itemPosition(item protocol.CallHierarchyItem) lsp.TextDocumentPositionParams {
+	return lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: item.URI},
+		Position:     item.SelectionRange.Start,
+	}
+}
+
+func itemLess(a, b protocol.CallHierarchyItem) bool {
+	if a.URI != b.URI {
+		return a.URI < b.URI
+	}
+	return positionLess(a.Range.Start, b.Range.Start)
+}
+
+func sortRanges(ranges []lsp.Range) {
+	sort.Slice(ranges, func(i, j int) bool {
+		return positionLess(ranges[i].Start, ranges[j].Start)
+	})
+}
+
+func positionLess(a, b lsp.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Character < b.Character
+}
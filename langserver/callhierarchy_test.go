@@ -0,0 +1,54 @@
+package langserver
+
+import (
+	"go/ast"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallHierarchyItems(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `package p
+
+type counter struct{ n int }
+
+func (c *counter) inc() {
+	c.n++
+}
+
+func count(xs []int) int {
+	c := &counter{}
+	for range xs {
+		c.inc()
+	}
+	return c.n
+}
+`
+	pkg := checkSource(t, src)
+	fset, f, info := pkg.Fset, pkg.File, pkg.Info
+
+	offset := strings.Index(src, "c.inc()")
+	decl := funcDeclAt(fset, []*ast.File{f}, "/src/p/p.go", offset)
+	require.NotNil(decl)
+	require.Equal("count", decl.Name.Name)
+	require.Nil(funcDeclAt(fset, []*ast.File{f}, "/src/p/p.go", strings.Index(src, "type counter")))
+	require.Nil(funcDeclAt(fset, []*ast.File{f}, "/src/p/other.go", offset))
+
+	item := declItem(fset, info, decl)
+	require.Equal("count", item.Name)
+	require.Equal(lsp.SKFunction, item.Kind)
+	require.Equal("example.com/p", item.Detail)
+	require.Equal(lsp.DocumentURI("file:///src/p/p.go"), item.URI)
+	require.Equal(8, item.Range.Start.Line)
+	require.Equal(lsp.Position{Line: 8, Character: 5}, item.SelectionRange.Start)
+
+	inc := funcDeclAt(fset, []*ast.File{f}, "/src/p/p.go", strings.Index(src, "c.n++"))
+	item = declItem(fset, info, inc)
+	require.Equal("(*counter).inc", item.Name)
+	require.Equal(lsp.SKMethod, item.Kind)
+}
@@ -7,16 +7,29 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// supersededMethods are the methods whose in-flight request is cancelled
+// when a newer one arrives, eg. the workspace/symbol requests sent for every
+// keystroke in the symbol picker of the client.
+var supersededMethods = map[string]bool{
+	"workspace/symbol":              true,
+	"workspace/diagnostic":          true,
+	"textDocument/inlineCompletion": true,
+}
+
 // cancel manages $/cancelRequest by keeping track of running commands
 type cancel struct {
 	mu *sync.Mutex
 	m  map[jsonrpc2.ID]func()
+
+	// latest is the latest request of each superseded method.
+	latest map[string]jsonrpc2.ID
 }
 
 func NewCancel() *cancel {
 	return &cancel{
-		mu: &sync.Mutex{},
-		m:  make(map[jsonrpc2.ID]func()),
+		mu:     &sync.Mutex{},
+		m:      make(map[jsonrpc2.ID]func()),
+		latest: make(map[string]jsonrpc2.ID),
 	}
 }
 
@@ -33,11 +46,49 @@ func (c *cancel) WithCancel(ctx context.Context, id jsonrpc2.ID) (context.Contex
 	return ctx, func() {
 		c.mu.Lock()
 		delete(c.m, id)
+		for method, latest := range c.latest {
+			if latest == id {
+				delete(c.latest, method)
+			}
+		}
 		c.mu.Unlock()
 		cancel()
 	}
 }
 
+// Supersede cancels the in-flight request of method which is older than the
+// request with id. Requests are handled concurrently, so if a newer request
+// has already superseded it, the request with id itself is cancelled.
+func (c *cancel) Supersede(method string, id jsonrpc2.ID) {
+	c.mu.Lock()
+	if c.latest == nil {
+		c.latest = make(map[string]jsonrpc2.ID)
+	}
+	prev, ok := c.latest[method]
+	stale := id
+	switch {
+	case !ok:
+		c.latest[method] = id
+		c.mu.Unlock()
+		return
+	case requestBefore(prev, id):
+		c.latest[method] = id
+		stale = prev
+	}
+	c.mu.Unlock()
+
+	c.Cancel(stale)
+}
+
+// requestBefore reports whether the request a was sent before b. String IDs
+// are not ordered, so they are assumed to arrive in order.
+func requestBefore(a, b jsonrpc2.ID) bool {
+	if a.IsString || b.IsString {
+		return true
+	}
+	return a.Num < b.Num
+}
+
 // Cancel will cancel the request with id. If the request has already been
 // cancelled or not been tracked before, Cancel is a noop.
 func (c *cancel) Cancel(id jsonrpc2.ID) {
@@ -44,3 +44,48 @@ func TestCancel(t *testing.T) {
 	// should just be a noop.
 	c.Cancel(id3)
 }
+
+func TestCancelSupersede(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	c := NewCancel()
+	const method = "workspace/symbol"
+	id1 := jsonrpc2.ID{Num: 1}
+	id2 := jsonrpc2.ID{Num: 2}
+	id3 := jsonrpc2.ID{Num: 3}
+	other := jsonrpc2.ID{Num: 4}
+
+	ctx1, cancel1 := c.WithCancel(context.Background(), id1)
+	defer cancel1()
+	c.Supersede(method, id1)
+	require.NoError(ctx1.Err())
+
+	ctxOther, cancelOther := c.WithCancel(context.Background(), other)
+	defer cancelOther()
+
+	// A newer request cancels the previous one.
+	ctx3, cancel3 := c.WithCancel(context.Background(), id3)
+	defer cancel3()
+	c.Supersede(method, id3)
+	require.Error(ctx1.Err())
+	require.NoError(ctx3.Err())
+
+	// An older request which arrives late is cancelled at once.
+	ctx2, cancel2 := c.WithCancel(context.Background(), id2)
+	defer cancel2()
+	c.Supersede(method, id2)
+	require.Error(ctx2.Err())
+	require.NoError(ctx3.Err())
+
+	// Other requests are not affected.
+	require.NoError(ctxOther.Err())
+
+	// Once the latest request is done, the next one starts afresh.
+	cancel3()
+	id5 := jsonrpc2.ID{Num: 5}
+	ctx5, cancel5 := c.WithCancel(context.Background(), id5)
+	defer cancel5()
+	c.Supersede(method, id5)
+	require.NoError(ctx5.Err())
+}
@@ -0,0 +1,120 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+// awaitTimeout is how long the await helpers of fakeClient wait for a
+// notification.
+const awaitTimeout = 30 * time.Second
+
+// fakeClient is the client of a TestContext. It records the notifications of
+// the server, which the tests wait for with await, and answers the requests
+// of the server with null.
+type fakeClient struct {
+	mu            sync.Mutex
+	notifications []*jsonrpc2.Request
+
+	// received is closed and replaced when a notification is received.
+	received chan struct{}
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{received: make(chan struct{})}
+}
+
+func (c *fakeClient) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if !req.Notif {
+		_ = conn.Reply(ctx, req.ID, nil)
+		return
+	}
+
+	c.mu.Lock()
+	c.notifications = append(c.notifications, req)
+	close(c.received)
+	c.received = make(chan struct{})
+	c.mu.Unlock()
+}
+
+// await waits for a notification of method whose params match, and removes
+// it from the notifications, so that the next await waits for the next one.
+// A nil match matches any notification of method. It fails the test after
+// awaitTimeout.
+func (c *fakeClient) await(t testing.TB, method string, match func(params json.RawMessage) bool) json.RawMessage {
+	t.Helper()
+	timeout := time.After(awaitTimeout)
+	for {
+		c.mu.Lock()
+		for i, req := range c.notifications {
+			var params json.RawMessage
+			if req.Params != nil {
+				params = *req.Params
+			}
+			if req.Method != method || (match != nil && !match(params)) {
+				continue
+			}
+			c.notifications = append(c.notifications[:i:i], c.notifications[i+1:]...)
+			c.mu.Unlock()
+			return params
+		}
+		received := c.received
+		c.mu.Unlock()
+
+		select {
+		case <-received:
+		case <-timeout:
+			t.Fatalf("no %s notification received within %s", method, awaitTimeout)
+		}
+	}
+}
+
+// awaitDiagnostics waits for the diagnostics of the document uri.
+func (c *fakeClient) awaitDiagnostics(t testing.TB, uri lsp.DocumentURI) *lsp.PublishDiagnosticsParams {
+	t.Helper()
+	var diagnostics lsp.PublishDiagnosticsParams
+	c.await(t, "textDocument/publishDiagnostics", func(params json.RawMessage) bool {
+		return json.Unmarshal(params, &diagnostics) == nil && diagnostics.URI == uri
+	})
+	return &diagnostics
+}
+
+func TestFakeClientDiagnostics(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	broken := "package client\n\nfunc F() int {\n\treturn \"\"\n}\n"
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/client\n",
+		"a.go":   broken,
+	})
+
+	cfg := testConfig(cache.Ondemand)
+	cfg.DiagnosticsStyle = string(instantDiagnostics)
+	tx := newWorkspaceContext(t, cfg, root)
+	uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go")))
+
+	require.NoError(tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: broken},
+	}))
+	diagnostics := tx.client.awaitDiagnostics(t, uri)
+	require.Len(diagnostics.Diagnostics, 1)
+	require.Equal(3, diagnostics.Diagnostics[0].Range.Start.Line)
+
+	fixed := "package client\n\nfunc F() int {\n\treturn 0\n}\n"
+	require.NoError(tx.conn.Notify(tx.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+		TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: 2},
+		ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: fixed}},
+	}))
+	require.Empty(tx.client.awaitDiagnostics(t, uri).Diagnostics)
+}
@@ -0,0 +1,294 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"hash"
+	"hash/fnv"
+	"reflect"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// clonesCommand is the workspace/executeCommand command which finds the
+// duplicated functions of the workspace.
+const clonesCommand = "bingo.clones"
+
+// The defaults of ClonesParams.
+const (
+	defaultCloneMinNodes   = 40
+	defaultCloneSimilarity = 0.8
+)
+
+// cloneMinStmtNodes is the size of the smallest statements compared, so that
+// trivial statements like "return nil, err" do not make functions similar.
+const cloneMinStmtNodes = 8
+
+// ClonesParams is the argument of the bingo.clones command.
+type ClonesParams struct {
+	// MinNodes is the number of syntax nodes of the smallest functions
+	// compared. Defaults to 40.
+	MinNodes int `json:"minNodes,omitempty"`
+
+	// Similarity is the ratio of statements two functions must share to be
+	// reported, between 0 and 1. Defaults to 0.8.
+	Similarity float64 `json:"similarity,omitempty"`
+}
+
+// CloneGroup is a group of similar functions.
+type CloneGroup struct {
+	// Similarity is the lowest similarity of the functions of the group, 1
+	// if they only differ by their identifiers and literals.
+	Similarity float64        `json:"similarity"`
+	Locations  []lsp.Location `json:"locations"`
+}
+
+func (h *LangHandler) handleClones(ctx context.Context, params ClonesParams) ([]CloneGroup, error) {
+	if params.MinNodes <= 0 {
+		params.MinNodes = defaultCloneMinNodes
+	}
+	if params.Similarity <= 0 || params.Similarity > 1 {
+		params.Similarity = defaultCloneSimilarity
+	}
+
+	var funcs []*cloneFunc
+	// The test variants of the packages share their files.
+	seen := map[token.Position]bool{}
+	err := h.project.Search(func(pkg source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fset := pkg.GetFileSet()
+		for _, f := range pkg.GetSyntax() {
+			if !h.project.Contain(lsp.DocumentURI(source.ToURI(fset.Position(f.Pos()).Filename))) {
+				continue
+			}
+			for _, decl := range f.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil || seen[fset.Position(fn.Pos())] {
+					continue
+				}
+				seen[fset.Position(fn.Pos())] = true
+				if cf := newCloneFunc(fset, fn); cf.size >= params.MinNodes {
+					funcs = append(funcs, cf)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []CloneGroup{}
+	for _, g := range groupClones(funcs, params.Similarity) {
+		group := CloneGroup{Similarity: g.similarity}
+		for _, f := range g.funcs {
+			group.Locations = append(group.Locations, lsp.Location{
+				URI:   lsp.DocumentURI(source.ToURI(f.fset.Position(f.decl.Pos()).Filename)),
+				Range: rangeForNode(f.fset, f.decl),
+			})
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// cloneFunc is the normalized syntax of a function, where the identifiers
+// and the literals are indistinguishable.
+type cloneFunc struct {
+	fset *token.FileSet
+	decl *ast.FuncDecl
+
+	// size is the number of nodes of the body, and body its hash.
+	size int
+	body uint64
+
+	// stmts are the hashes of the statements of the body.
+	stmts map[uint64]bool
+}
+
+func newCloneFunc(fset *token.FileSet, decl *ast.FuncDecl) *cloneFunc {
+	f := &cloneFunc{fset: fset, decl: decl, stmts: map[uint64]bool{}}
+
+	// The hashes of a node and of its children are computed bottom up: a
+	// node is hashed when it is left, and its hash is added to its parent.
+	type frame struct {
+		node ast.Node
+		hash hash.Hash64
+		size int
+	}
+	var stack []*frame
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		if n != nil {
+			h := fnv.New64a()
+			h.Write([]byte(cloneToken(n)))
+			stack = append(stack, &frame{node: n, hash: h, size: 1})
+			return true
+		}
+
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		sum := top.hash.Sum64()
+		if _, ok := top.node.(ast.Stmt); ok && top.size >= cloneMinStmtNodes {
+			f.stmts[sum] = true
+		}
+		if len(stack) == 0 {
+			f.size, f.body = top.size, sum
+			return true
+		}
+		parent := stack[len(stack)-1]
+		var buf [8]byte
+		for i := range buf {
+			buf[i] = byte(sum >> (8 * uint(i)))
+		}
+		parent.hash.Write(buf[:])
+		parent.size += top.size
+		return true
+	})
+	return f
+}
+
+// cloneToken returns the token of the node n, which ignores the names and
+// the values.
+func cloneToken(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Ident:
+		return "ident"
+	case *ast.BasicLit:
+		return "lit"
+	case *ast.BinaryExpr:
+		return "binary " + n.Op.String()
+	case *ast.UnaryExpr:
+		return "unary " + n.Op.String()
+	case *ast.AssignStmt:
+		return "assign " + n.Tok.String()
+	case *ast.IncDecStmt:
+		return "incdec " + n.Tok.String()
+	case *ast.BranchStmt:
+		return "branch " + n.Tok.String()
+	}
+	return reflect.TypeOf(n).Elem().Name()
+}
+
+// similarity returns the Jaccard index of the statements of f and g, or 1 if
+// their bodies are the same.
+func (f *cloneFunc) similarity(g *cloneFunc) float64 {
+	if f.body == g.body {
+		return 1
+	}
+	shared := 0
+	for h := range f.stmts {
+		if g.stmts[h] {
+			shared++
+		}
+	}
+	union := len(f.stmts) + len(g.stmts) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// cloneGroup is a group of similar functions.
+type cloneGroup struct {
+	funcs      []*cloneFunc
+	similarity float64
+}
+
+// groupClones returns the groups of the functions which are transitively
+// similar to each other, the largest functions first.
+func groupClones(funcs []*cloneFunc, similarity float64) []cloneGroup {
+	// The functions sharing a statement are the candidates, which avoids
+	// comparing all the pairs.
+	byStmt := map[uint64][]int{}
+	byBody := map[uint64][]int{}
+	for i, f := range funcs {
+		for h := range f.stmts {
+			byStmt[h] = append(byStmt[h], i)
+		}
+		byBody[f.body] = append(byBody[f.body], i)
+	}
+
+	parents := make([]int, len(funcs))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+
+	lowest := map[[2]int]float64{}
+	compared := map[[2]int]bool{}
+	compare := func(candidates []int) {
+		for x := 0; x < len(candidates); x++ {
+			for y := x + 1; y < len(candidates); y++ {
+				pair := [2]int{candidates[x], candidates[y]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+				f, g := funcs[pair[0]], funcs[pair[1]]
+				// The functions of very different sizes are not clones.
+				if f.size < g.size && float64(f.size) < similarity*float64(g.size) || g.size < f.size && float64(g.size) < similarity*float64(f.size) {
+					continue
+				}
+				sim := f.similarity(g)
+				if sim < similarity {
+					continue
+				}
+				lowest[pair] = sim
+				parents[find(pair[0])] = find(pair[1])
+			}
+		}
+	}
+	for _, candidates := range byBody {
+		compare(candidates)
+	}
+	for _, candidates := range byStmt {
+		compare(candidates)
+	}
+
+	members := map[int][]int{}
+	for i := range funcs {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+	var groups []cloneGroup
+	for _, ids := range members {
+		if len(ids) < 2 {
+			continue
+		}
+		g := cloneGroup{similarity: 1}
+		for _, i := range ids {
+			g.funcs = append(g.funcs, funcs[i])
+		}
+		for pair, sim := range lowest {
+			if find(pair[0]) == find(ids[0]) && sim < g.similarity {
+				g.similarity = sim
+			}
+		}
+		sort.Slice(g.funcs, func(i, j int) bool {
+			pi, pj := g.funcs[i].fset.Position(g.funcs[i].decl.Pos()), g.funcs[j].fset.Position(g.funcs[j].decl.Pos())
+			if pi.Filename != pj.Filename {
+				return pi.Filename < pj.Filename
+			}
+			return pi.Offset < pj.Offset
+		})
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].funcs[0].size != groups[j].funcs[0].size {
+			return groups[i].funcs[0].size > groups[j].funcs[0].size
+		}
+		return groups[i].funcs[0].decl.Pos() < groups[j].funcs[0].decl.Pos()
+	})
+	return groups
+}
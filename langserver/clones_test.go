@@ -0,0 +1,86 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupClones(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", `package p
+
+func sumPositive(values []int) int {
+	total := 0
+	for _, v := range values {
+		if v > 0 {
+			total += v
+		}
+	}
+	return total
+}
+
+func addLarge(xs []int) int {
+	sum := 0
+	for _, x := range xs {
+		if x > 10 {
+			sum += x
+		}
+	}
+	return sum
+}
+
+func countLarge(xs []int) int {
+	n := 0
+	for _, x := range xs {
+		if x > 10 {
+			n += x
+		}
+	}
+	if n < 0 {
+		panic("overflow")
+	}
+	return n
+}
+
+func product(xs []int) int {
+	p := 1
+	for i := 0; i < len(xs); i++ {
+		p *= xs[i]
+	}
+	return p
+}
+`, 0)
+	require.NoError(err)
+
+	var funcs []*cloneFunc
+	for _, decl := range f.Decls {
+		funcs = append(funcs, newCloneFunc(fset, decl.(*ast.FuncDecl)))
+	}
+
+	names := func(groups []cloneGroup) [][]string {
+		var names [][]string
+		for _, g := range groups {
+			var group []string
+			for _, f := range g.funcs {
+				group = append(group, f.decl.Name.Name)
+			}
+			names = append(names, group)
+		}
+		return names
+	}
+
+	groups := groupClones(funcs, 1)
+	require.Equal([][]string{{"sumPositive", "addLarge"}}, names(groups))
+	require.Equal(1.0, groups[0].similarity)
+
+	groups = groupClones(funcs, 0.5)
+	require.Equal([][]string{{"sumPositive", "addLarge", "countLarge"}}, names(groups))
+	require.True(groups[0].similarity >= 0.5 && groups[0].similarity < 1)
+}
@@ -2,7 +2,6 @@ package langserver
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
@@ -23,11 +22,16 @@ func (h *LangHandler) handleCodeAction(ctx context.Context, conn jsonrpc2.JSONRP
 		return []protocol.CodeAction{}, nil
 	}
 
+	// An empty file cannot be parsed to organize its imports.
+	if actions, ok := h.packageClauseActions(ctx, fileURI); ok {
+		return actions, nil
+	}
+
 	edits, err := organizeImports(ctx, h.View(), fileURI)
 	if err != nil {
 		return nil, err
 	}
-	return []protocol.CodeAction{
+	actions := []protocol.CodeAction{
 		{
 			Title: "Organize Imports",
 			Kind:  protocol.SourceOrganizeImports,
@@ -37,7 +41,14 @@ func (h *LangHandler) handleCodeAction(ctx context.Context, conn jsonrpc2.JSONRP
 				},
 			},
 		},
-	}, nil
+	}
+	actions = append(actions, h.suppressActions(ctx, fileURI, params.Context.Diagnostics)...)
+	actions = append(actions, h.importActions(ctx, fileURI, params.Context.Diagnostics)...)
+	actions = append(actions, h.unusedVariableActions(ctx, fileURI, params.Context.Diagnostics)...)
+	actions = append(actions, h.missingDeclActions(ctx, fileURI, params.Context.Diagnostics)...)
+	actions = append(actions, h.exportActions(ctx, fileURI, params.Context.Diagnostics)...)
+	actions = append(actions, h.errorCheckActions(ctx, fileURI, params.Range)...)
+	return append(actions, h.mockActions(ctx, fileURI, params.Range)...), nil
 }
 
 func organizeImports(ctx context.Context, v source.View, uri lsp.DocumentURI) ([]lsp.TextEdit, error) {
@@ -51,7 +62,7 @@ func organizeImports(ctx context.Context, v source.View, uri lsp.DocumentURI) ([
 	}
 	tok := f.GetToken(ctx)
 	if tok == nil {
-		return nil, fmt.Errorf("token file does not exist for file %s", uri)
+		return nil, requestErrorf(StaleError, "token file does not exist for file %s", uri)
 	}
 
 	r := span.Range{
@@ -0,0 +1,346 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// showReferencesCommand is the client command which lists the references of
+// a code lens.
+const showReferencesCommand = "editor.action.showReferences"
+
+// The kinds of code lenses.
+const (
+	referencesLens      = "references"
+	implementationsLens = "implementations"
+	implementsLens      = "implements"
+	runLens             = "run"
+	runFileLens         = "runFile"
+	enumLens            = "enum"
+	testLens            = "test"
+	benchmarkLens       = "benchmark"
+)
+
+// codeLensData is the data of an unresolved code lens.
+type codeLensData struct {
+	Kind     string          `json:"kind"`
+	URI      lsp.DocumentURI `json:"uri"`
+	Position lsp.Position    `json:"position"`
+
+	// Name is the name of the function of a test or benchmark code lens.
+	Name string `json:"name,omitempty"`
+}
+
+func (h *LangHandler) handleCodeLens(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CodeLensParams) ([]protocol.CodeLens, error) {
+	if !h.config.ReferencesCodeLens && !h.config.ImplementationCodeLens && !h.config.RunCodeLens && !h.config.TestCodeLens && !h.config.EnumCodeLens {
+		return []protocol.CodeLens{}, nil
+	}
+
+	pkg, fAST, err := h.loadPackageAndAst(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	lenses := []protocol.CodeLens{}
+	if h.config.ReferencesCodeLens {
+		lenses = append(lenses, referencesCodeLenses(pkg.GetFileSet(), fAST, params.TextDocument.URI)...)
+	}
+	if h.config.ImplementationCodeLens {
+		lenses = append(lenses, implementationCodeLenses(pkg, fAST, params.TextDocument.URI)...)
+	}
+	if h.config.RunCodeLens {
+		lenses = append(lenses, runCodeLenses(pkg.GetFileSet(), fAST, params.TextDocument.URI)...)
+	}
+	if h.config.TestCodeLens {
+		lenses = append(lenses, testCodeLenses(pkg.GetFileSet(), fAST, params.TextDocument.URI)...)
+	}
+	if h.config.EnumCodeLens {
+		lenses = append(lenses, enumCodeLenses(pkg, fAST, params.TextDocument.URI)...)
+	}
+	return lenses, nil
+}
+
+// referencesCodeLenses returns an unresolved code lens for every exported
+// function, method and type declared at the top level of f. The references
+// are only counted when the client resolves the lens, because it has to
+// search the whole workspace.
+func referencesCodeLenses(fset *token.FileSet, f *ast.File, uri lsp.DocumentURI) []protocol.CodeLens {
+	lenses := []protocol.CodeLens{}
+	add := func(name *ast.Ident) {
+		if !name.IsExported() {
+			return
+		}
+		rng := rangeForNode(fset, name)
+		lenses = append(lenses, protocol.CodeLens{
+			Range: rng,
+			Data:  codeLensData{Kind: referencesLens, URI: uri, Position: rng.Start},
+		})
+	}
+
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			add(decl.Name)
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				add(spec.(*ast.TypeSpec).Name)
+			}
+		}
+	}
+	return lenses
+}
+
+func (h *LangHandler) handleCodeLensResolve(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.CodeLens) (protocol.CodeLens, error) {
+	// The data has been decoded as a generic map, decode it again.
+	raw, err := json.Marshal(params.Data)
+	if err != nil {
+		return params, err
+	}
+	var data codeLensData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return params, err
+	}
+	if data.URI == "" {
+		return params, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, "code lens without data")
+	}
+
+	position := lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: data.URI},
+		Position:     data.Position,
+	}
+
+	var title string
+	var locs []lsp.Location
+	switch data.Kind {
+	case referencesLens:
+		locs, err = h.doHandleTextDocumentReferences(ctx, conn, req, lsp.ReferenceParams{TextDocumentPositionParams: position})
+		title = referencesTitle(len(locs))
+	case implementationsLens:
+		locs, err = h.interfaceImplementations(ctx, conn, req, position)
+		title = implementationsTitle(len(locs))
+	case implementsLens:
+		title, locs, err = h.implementedInterfaceMethod(ctx, position)
+	case runLens, runFileLens:
+		params.Command = runLensCommand(data.Kind, data.URI)
+		return params, nil
+	case testLens, benchmarkLens:
+		params.Command = testLensCommand(data.Kind, data.URI, data.Name)
+		return params, nil
+	case enumLens:
+		params.Command, err = h.enumLensCommand(ctx, position)
+		return params, err
+	default:
+		return params, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("unknown code lens kind: %s", data.Kind))
+	}
+	if err != nil {
+		return params, err
+	}
+	if locs == nil {
+		locs = []lsp.Location{}
+	}
+
+	params.Command = &lsp.Command{
+		Title:     title,
+		Command:   showReferencesCommand,
+		Arguments: []interface{}{data.URI, data.Position, locs},
+	}
+	return params, nil
+}
+
+func referencesTitle(n int) string {
+	if n == 1 {
+		return "1 reference"
+	}
+	return fmt.Sprintf("%d references", n)
+}
+
+// implementationCodeLenses returns an unresolved code lens for every interface
+// type declared at the top level of f, and for every method of f which
+// implements a method of an interface of pkg or of its imports.
+func implementationCodeLenses(pkg source.Package, f *ast.File, uri lsp.DocumentURI) []protocol.CodeLens {
+	fset := pkg.GetFileSet()
+	info := pkg.GetTypesInfo()
+	lenses := []protocol.CodeLens{}
+	if info == nil {
+		return lenses
+	}
+
+	add := func(kind string, name *ast.Ident) {
+		rng := rangeForNode(fset, name)
+		lenses = append(lenses, protocol.CodeLens{
+			Range: rng,
+			Data:  codeLensData{Kind: kind, URI: uri, Position: rng.Start},
+		})
+	}
+
+	// The interfaces are only listed once for the methods of f.
+	var ifaces []*types.TypeName
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			fn, ok := info.Defs[decl.Name].(*types.Func)
+			if !ok || decl.Recv == nil {
+				continue
+			}
+			if ifaces == nil {
+				ifaces = interfacesOf(pkg.GetTypes())
+			}
+			if _, m := implementedMethod(ifaces, fn); m != nil {
+				add(implementsLens, decl.Name)
+			}
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				name := spec.(*ast.TypeSpec).Name
+				if obj := info.Defs[name]; obj != nil && types.IsInterface(obj.Type()) {
+					add(implementationsLens, name)
+				}
+			}
+		}
+	}
+	return lenses
+}
+
+// interfaceImplementations returns the locations of the types which
+// implement the interface at position.
+func (h *LangHandler) interfaceImplementations(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, position lsp.TextDocumentPositionParams) ([]lsp.Location, error) {
+	impls, err := h.handleTextDocumentImplementation(ctx, conn, req, position)
+	if err != nil {
+		return nil, err
+	}
+
+	var locs []lsp.Location
+	for _, impl := range impls {
+		if impl.Type == "to" {
+			locs = append(locs, impl.Location)
+		}
+	}
+	return locs, nil
+}
+
+// implementedInterfaceMethod returns the title and the location of the
+// interface method implemented by the method at position.
+func (h *LangHandler) implementedInterfaceMethod(ctx context.Context, position lsp.TextDocumentPositionParams) (string, []lsp.Location, error) {
+	pkg, pos, err := h.typeCheck(ctx, position.TextDocument.URI, position.Position)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return "", nil, err
+	}
+	ident, ok := pathNodes[0].(*ast.Ident)
+	if !ok {
+		return "", nil, source.NewInvalidNodeError(pkg.GetFileSet(), pathNodes[0])
+	}
+	fn, ok := pkg.GetTypesInfo().Defs[ident].(*types.Func)
+	if !ok {
+		return "", nil, errors.New("not a method")
+	}
+
+	iface, method := implementedMethod(interfacesOf(pkg.GetTypes()), fn)
+	if method == nil {
+		return "implements nothing", nil, nil
+	}
+
+	title := fmt.Sprintf("implements %s.%s", types.TypeString(iface.Type(), types.RelativeTo(pkg.GetTypes())), method.Name())
+	loc := goRangeToLSPLocation(pkg.GetFileSet(), method.Pos(), method.Name())
+	return title, []lsp.Location{loc}, nil
+}
+
+// interfacesOf returns the interfaces of pkg, of its imports and of the
+// universe which a method of pkg may implement, in name order.
+func interfacesOf(pkg *types.Package) []*types.TypeName {
+	if pkg == nil {
+		return nil
+	}
+
+	var ifaces []*types.TypeName
+	addScope := func(scope *types.Scope) {
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !types.IsInterface(obj.Type()) {
+				continue
+			}
+			if obj.Pkg() != nil && obj.Pkg() != pkg && !obj.Exported() {
+				continue
+			}
+			ifaces = append(ifaces, obj)
+		}
+	}
+	addScope(pkg.Scope())
+	seen := map[*types.Package]bool{pkg: true}
+	var addImports func(p *types.Package)
+	addImports = func(p *types.Package) {
+		for _, imp := range p.Imports() {
+			if seen[imp] {
+				continue
+			}
+			seen[imp] = true
+			addScope(imp.Scope())
+			addImports(imp)
+		}
+	}
+	addImports(pkg)
+	addScope(types.Universe)
+
+	sort.Slice(ifaces, func(i, j int) bool {
+		return types.TypeString(ifaces[i].Type(), nil) < types.TypeString(ifaces[j].Type(), nil)
+	})
+	return ifaces
+}
+
+// implementedMethod returns the first interface of ifaces, see interfacesOf,
+// which fn implements, and its method. It returns nil if fn does not
+// implement any interface method.
+func implementedMethod(ifaces []*types.TypeName, fn *types.Func) (*types.TypeName, *types.Func) {
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return nil, nil
+	}
+	recv := sig.Recv().Type()
+	if types.IsInterface(recv) {
+		return nil, nil
+	}
+	named, _ := source.Deref(recv).(*types.Named)
+
+	ptr := types.NewPointer(source.Deref(recv))
+	for _, obj := range ifaces {
+		if obj.Type() == named {
+			continue
+		}
+		iface := obj.Type().Underlying().(*types.Interface)
+		if iface.NumMethods() == 0 || !types.Implements(ptr, iface) {
+			continue
+		}
+		m, _, _ := types.LookupFieldOrMethod(obj.Type(), false, fn.Pkg(), fn.Name())
+		if m, ok := m.(*types.Func); ok {
+			return obj, m
+		}
+	}
+	return nil, nil
+}
+
+func implementationsTitle(n int) string {
+	if n == 1 {
+		return "1 implementation"
+	}
+	return fmt.Sprintf("%d implementations", n)
+}
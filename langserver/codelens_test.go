@@ -0,0 +1,149 @@
+package langserver
+
+import (
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferencesCodeLenses(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `package p
+
+type Exported struct{}
+
+type unexported struct{}
+
+func (Exported) Method() {}
+
+func (Exported) method() {}
+
+func Func() {}
+
+var Var = 1
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	require.NoError(err)
+
+	lenses := referencesCodeLenses(fset, f, "file:///p.go")
+	require.Len(lenses, 3)
+
+	var lines []int
+	for _, lens := range lenses {
+		require.Nil(lens.Command)
+		data := lens.Data.(codeLensData)
+		require.Equal(referencesLens, data.Kind)
+		require.Equal(lsp.DocumentURI("file:///p.go"), data.URI)
+		require.Equal(lens.Range.Start, data.Position)
+		lines = append(lines, lens.Range.Start.Line)
+	}
+	require.Equal([]int{2, 6, 10}, lines)
+}
+
+func TestReferencesTitle(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.Equal("0 references", referencesTitle(0))
+	require.Equal("1 reference", referencesTitle(1))
+	require.Equal("12 references", referencesTitle(12))
+}
+
+func TestImplementedMethod(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `package p
+
+type Shape interface {
+	Area() float64
+	Perimeter() float64
+}
+
+type Square struct{ side float64 }
+
+func (s *Square) Area() float64 { return s.side * s.side }
+
+func (s Square) Perimeter() float64 { return 4 * s.side }
+
+func (s Square) Error() string { return "square" }
+
+func (s Square) Scale() {}
+
+type Circle struct{}
+
+func (Circle) Area() float64 { return 0 }
+`
+	pkg := checkSource(t, src).Types
+
+	method := func(recv, name string) *types.Func {
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(pkg.Scope().Lookup(recv).Type()), false, pkg, name)
+		return obj.(*types.Func)
+	}
+
+	tests := []struct {
+		recv, name string
+		want       string
+	}{
+		{"Square", "Area", "Shape.Area"},
+		{"Square", "Perimeter", "Shape.Perimeter"},
+		{"Square", "Error", "error.Error"},
+		{"Square", "Scale", ""},
+		{"Circle", "Area", ""},
+	}
+	for _, test := range tests {
+		iface, m := implementedMethod(interfacesOf(pkg), method(test.recv, test.name))
+		got := ""
+		if m != nil {
+			got = types.TypeString(iface.Type(), types.RelativeTo(pkg)) + "." + m.Name()
+		}
+		require.Equal(test.want, got, "%s.%s", test.recv, test.name)
+	}
+}
+
+func TestImplementationCodeLenses(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `package p
+
+type Named interface{ Name() string }
+
+type T struct{}
+
+func (T) Name() string { return "" }
+
+func (T) Broken() {}
+
+func F() {}
+`
+	pkg := checkSource(t, src)
+	// The method of a broken declaration has no object.
+	for ident := range pkg.Info.Defs {
+		if ident.Name == "Broken" {
+			pkg.Info.Defs[ident] = nil
+		}
+	}
+
+	lenses := implementationCodeLenses(pkg, pkg.File, "file:///p.go")
+	var kinds []string
+	for _, lens := range lenses {
+		kinds = append(kinds, lens.Data.(codeLensData).Kind)
+	}
+	require.Equal([]string{implementationsLens, implementsLens}, kinds)
+}
+
+func TestImplementationsTitle(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.Equal("1 implementation", implementationsTitle(1))
+	require.Equal("3 implementations", implementationsTitle(3))
+}
@@ -0,0 +1,191 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const imageColorPkg = "image/color"
+
+func (h *LangHandler) handleDocumentColor(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.DocumentColorParams) ([]protocol.ColorInformation, error) {
+	if !h.config.DocumentColor {
+		return []protocol.ColorInformation{}, nil
+	}
+
+	pkg, fAST, err := h.loadPackageAndAst(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return documentColors(pkg, fAST), nil
+}
+
+// documentColors finds the color.RGBA and color.NRGBA composite literals
+// with constant components and the "#RRGGBB" like string literals of f.
+func documentColors(pkg source.Package, f *ast.File) []protocol.ColorInformation {
+	fset := pkg.GetFileSet()
+	info := pkg.GetTypesInfo()
+
+	colors := []protocol.ColorInformation{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CompositeLit:
+			if c, ok := rgbaLiteralColor(info, n); ok {
+				colors = append(colors, protocol.ColorInformation{Range: rangeForNode(fset, n), Color: c})
+				return false
+			}
+		case *ast.BasicLit:
+			if n.Kind != token.STRING {
+				return true
+			}
+			s, err := strconv.Unquote(n.Value)
+			if err != nil {
+				return true
+			}
+			if c, ok := parseHexColor(s); ok {
+				colors = append(colors, protocol.ColorInformation{Range: rangeForNode(fset, n), Color: c})
+			}
+		}
+		return true
+	})
+	return colors
+}
+
+// rgbaLiteralColor returns the color of a color.RGBA or color.NRGBA literal
+// whose components are all constants.
+func rgbaLiteralColor(info *types.Info, lit *ast.CompositeLit) (protocol.Color, bool) {
+	if info == nil {
+		return protocol.Color{}, false
+	}
+
+	named, ok := info.TypeOf(lit).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != imageColorPkg {
+		return protocol.Color{}, false
+	}
+	if name := named.Obj().Name(); name != "RGBA" && name != "NRGBA" {
+		return protocol.Color{}, false
+	}
+
+	// Missing fields are zero.
+	components := map[string]float64{}
+	fields := []string{"R", "G", "B", "A"}
+	for i, elt := range lit.Elts {
+		field, value := "", elt
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			ident, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				return protocol.Color{}, false
+			}
+			field, value = ident.Name, kv.Value
+		} else if i < len(fields) {
+			field = fields[i]
+		}
+
+		tv, ok := info.Types[value]
+		if !ok || tv.Value == nil {
+			return protocol.Color{}, false
+		}
+		v, ok := constant.Int64Val(constant.ToInt(tv.Value))
+		if !ok {
+			return protocol.Color{}, false
+		}
+		components[field] = float64(v) / 0xff
+	}
+
+	return protocol.Color{
+		Red:   components["R"],
+		Green: components["G"],
+		Blue:  components["B"],
+		Alpha: components["A"],
+	}, true
+}
+
+// parseHexColor parses "#RGB", "#RRGGBB" and "#RRGGBBAA" strings.
+func parseHexColor(s string) (protocol.Color, bool) {
+	if !strings.HasPrefix(s, "#") {
+		return protocol.Color{}, false
+	}
+	s = s[1:]
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return protocol.Color{}, false
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return protocol.Color{}, false
+	}
+	return protocol.Color{
+		Red:   float64(v>>24&0xff) / 0xff,
+		Green: float64(v>>16&0xff) / 0xff,
+		Blue:  float64(v>>8&0xff) / 0xff,
+		Alpha: float64(v&0xff) / 0xff,
+	}, true
+}
+
+func (h *LangHandler) handleColorPresentation(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.ColorPresentationParams) ([]protocol.ColorPresentation, error) {
+	if !h.config.DocumentColor {
+		return []protocol.ColorPresentation{}, nil
+	}
+
+	f, err := h.View().GetFile(ctx, span.FromDocumentURI(params.TextDocument.URI))
+	if err != nil {
+		return nil, err
+	}
+	content := f.GetContent(ctx)
+	lines := f.GetLineIndex(ctx)
+	start := lines.Offset(int(params.Range.Start.Line), int(params.Range.Start.Character))
+	end := lines.Offset(int(params.Range.End.Line), int(params.Range.End.Character))
+	if start == -1 || end == -1 || start > end {
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, "invalid range for color presentation")
+	}
+
+	return colorPresentations(string(content[start:end]), params.Color, params.Range), nil
+}
+
+// colorPresentations rewrites the color literal text with color, keeping its
+// flavor: hex strings stay hex strings and composite literals keep their type.
+func colorPresentations(text string, c protocol.Color, rng lsp.Range) []protocol.ColorPresentation {
+	r, g, b, a := colorByte(c.Red), colorByte(c.Green), colorByte(c.Blue), colorByte(c.Alpha)
+
+	var label string
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "`") {
+		label = fmt.Sprintf(`"#%02x%02x%02x"`, r, g, b)
+		if a != 0xff {
+			label = fmt.Sprintf(`"#%02x%02x%02x%02x"`, r, g, b, a)
+		}
+	} else {
+		typ := "color.RGBA"
+		if i := strings.Index(text, "{"); i > 0 {
+			typ = strings.TrimSpace(text[:i])
+		}
+		label = fmt.Sprintf("%s{R: %#02x, G: %#02x, B: %#02x, A: %#02x}", typ, r, g, b, a)
+	}
+
+	return []protocol.ColorPresentation{{
+		Label:    label,
+		TextEdit: &lsp.TextEdit{Range: rng, NewText: label},
+	}}
+}
+
+func colorByte(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 0xff))
+}
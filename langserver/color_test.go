@@ -0,0 +1,50 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+)
+
+func TestParseHexColor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  protocol.Color
+		ok    bool
+	}{
+		{"#ff0000", protocol.Color{Red: 1, Alpha: 1}, true},
+		{"#0f0", protocol.Color{Green: 1, Alpha: 1}, true},
+		{"#0000ff00", protocol.Color{Blue: 1}, true},
+		{"ff0000", protocol.Color{}, false},
+		{"#ff00", protocol.Color{}, false},
+		{"#gg0000", protocol.Color{}, false},
+	}
+	for _, test := range tests {
+		got, ok := parseHexColor(test.input)
+		if ok != test.ok || got != test.want {
+			t.Errorf("parseHexColor(%q) = %v, %t, want %v, %t", test.input, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestColorPresentations(t *testing.T) {
+	t.Parallel()
+
+	c := protocol.Color{Red: 1, Green: 0.5, Alpha: 1}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`"#000000"`, `"#ff8000"`},
+		{"color.NRGBA{0, 0, 0, 0}", "color.NRGBA{R: 0xff, G: 0x80, B: 0x00, A: 0xff}"},
+	}
+	for _, test := range tests {
+		got := colorPresentations(test.text, c, lsp.Range{})
+		if len(got) != 1 || got[0].Label != test.want {
+			t.Errorf("colorPresentations(%q) = %v, want %q", test.text, got, test.want)
+		}
+	}
+}
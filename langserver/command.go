@@ -0,0 +1,171 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *LangHandler) handleExecuteCommand(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.ExecuteCommandParams) (interface{}, error) {
+	if !h.config.commandAvailable(params.Command) {
+		return nil, requestErrorf(UnavailableError, "%s is not available in read-only mode", params.Command)
+	}
+	if err := h.confirmCommand(ctx, conn, params); err != nil {
+		return nil, err
+	}
+
+	switch params.Command {
+	case runCommand:
+		if len(params.Arguments) != 2 {
+			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, "bingo.run expects a directory and a target")
+		}
+		dir, ok := params.Arguments[0].(string)
+		target, ok2 := params.Arguments[1].(string)
+		if !ok || !ok2 {
+			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, "bingo.run expects string arguments")
+		}
+		return nil, h.run(dir, target)
+
+	case testCommand:
+		if len(params.Arguments) != 2 {
+			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, "bingo.test expects a directory and a test name")
+		}
+		dir, ok := params.Arguments[0].(string)
+		name, ok2 := params.Arguments[1].(string)
+		if !ok || !ok2 {
+			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, "bingo.test expects string arguments")
+		}
+		return nil, h.test(dir, name)
+
+	case statusCommand:
+		return h.handleStatus(ctx)
+
+	case callGraphCommand:
+		var args CallGraphParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleCallGraph(ctx, args)
+
+	case panicsCommand:
+		var args PanicsParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handlePanics(ctx, args)
+
+	case taintCommand:
+		var args TaintParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleTaint(ctx, args)
+
+	case enumCommand:
+		var args EnumParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleEnum(ctx, conn, args)
+
+	case mockCommand:
+		var args MockParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleMock(ctx, conn, args)
+
+	case jsonToStructCommand:
+		var args JSONToStructParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleJSONToStruct(ctx, conn, args)
+
+	case structToJSONCommand:
+		var args StructToJSONParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleStructToJSON(ctx, args)
+
+	case clonesCommand:
+		var args ClonesParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleClones(ctx, args)
+
+	case rewriteCommand:
+		var args RewriteParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleRewrite(ctx, conn, args)
+
+	case promoteVariableCommand:
+		var args PromoteVariableParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handlePromoteVariable(ctx, conn, args)
+
+	case previewEditCommand:
+		var args PreviewEditParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handlePreviewEdit(ctx, conn, args)
+
+	case applyEditCommand:
+		var args ApplyEditParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return nil, h.handleApplyEdit(ctx, conn, args)
+
+	case fixImportsCommand, fixAllCommand:
+		var args FixParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleFix(ctx, conn, args, params.Command == fixAllCommand)
+
+	case importersCommand:
+		var args ImportersParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handleImporters(ctx, args)
+
+	case playgroundShareCommand:
+		var args PlaygroundShareParams
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return h.handlePlaygroundShare(ctx, args)
+
+	default:
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("unknown command: %s", params.Command))
+	}
+}
+
+// commandArgument decodes the single object argument of a command into v.
+func commandArgument(params lsp.ExecuteCommandParams, v interface{}) error {
+	if len(params.Arguments) != 1 {
+		return newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("%s expects a single argument", params.Command))
+	}
+
+	// The argument has been decoded as a generic map, decode it again.
+	raw, err := json.Marshal(params.Arguments[0])
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("invalid %s argument: %s", params.Command, err))
+	}
+	return nil
+}
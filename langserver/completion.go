@@ -8,16 +8,21 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CompletionParams) (*lsp.CompletionList, error) {
+func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CompletionParams) (*protocol.CompletionList, error) {
 	fileURI := params.TextDocument.URI
 	if err := checkFileURI(fileURI); err != nil {
 		return nil, nil
@@ -27,15 +32,21 @@ func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jso
 	if err != nil {
 		return nil, err
 	}
-	tok := f.GetToken(ctx)
-	if tok == nil {
-		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, fmt.Sprintf("token file does not exist of %s", fileURI))
-	}
-
-	pos := fromProtocolPosition(tok, params.Position)
-	items, prefix, err := source.Completion(ctx, f, pos, h.project.Cache())
+	// Only the function being edited is type-checked again if possible.
+	offset := f.GetLineIndex(ctx).Offset(params.Position.Line, params.Position.Character)
+	opts := h.completionOptions()
+	items, prefix, err := source.SpeculativeCompletion(ctx, f, offset, h.project.Cache(), opts)
 	if err != nil {
-		return nil, err
+		tok := f.GetToken(ctx)
+		if tok == nil {
+			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, fmt.Sprintf("token file does not exist of %s", fileURI))
+		}
+
+		pos := fromProtocolPosition(tok, params.Position)
+		items, prefix, err = source.Completion(ctx, f, pos, h.project.Cache(), opts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if ctx.Err() != nil {
@@ -43,13 +54,26 @@ func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jso
 	}
 
 	useSnippets := h.clientSupportsSnippets() && !h.config.DisableFuncSnippet
-	result := &lsp.CompletionList{
-		IsIncomplete: false,
-		Items:        toProtocolCompletionItems(items, prefix, params.Position, useSnippets, false),
+	imports := &completionImports{content: f.GetContent(ctx)}
+	result := &protocol.CompletionList{
+		// The candidates of the packages not imported yet are limited.
+		IsIncomplete: opts.Unimported,
+		Items:        toProtocolCompletionItems(items, prefix, params.Position, useSnippets, false, h.completionMatcher(), imports),
+	}
+	for _, item := range h.errorCheckCompletion(ctx, params, prefix, useSnippets) {
+		result.Items = append(result.Items, protocol.CompletionItem{CompletionItem: item})
 	}
 	return result, nil
 }
 
+// completionMatcher returns the matcher of the candidates of the completion.
+func (h *LangHandler) completionMatcher() source.Matcher {
+	if h.config.CompletionMatcher == "fuzzy" {
+		return source.FuzzyMatch
+	}
+	return source.PrefixMatch
+}
+
 func (h *LangHandler) clientSupportsSnippets() bool {
 	return h.init != nil && h.init.Capabilities.TextDocument.Completion.CompletionItem.SnippetSupport
 }
@@ -61,30 +85,46 @@ func getLspRange(pos lsp.Position, rangeLen int) lsp.Range {
 	}
 }
 
-func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string, pos lsp.Position, snippetsSupported, signatureHelpEnabled bool) []lsp.CompletionItem {
+func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string, pos lsp.Position, snippetsSupported, signatureHelpEnabled bool, match source.Matcher, imports *completionImports) []protocol.CompletionItem {
 	insertTextFormat := lsp.ITFPlainText
 	if snippetsSupported {
 		insertTextFormat = lsp.ITFSnippet
 	}
-	sort.SliceStable(candidates, func(i, j int) bool {
-		return candidates[i].Score > candidates[j].Score
-	})
-	items := []lsp.CompletionItem{}
-	for i, candidate := range candidates {
-		// Matching against the label.
-		if !strings.HasPrefix(candidate.Label, prefix) {
-			continue
+	// Matching against the name of the label, ranked by the score of the
+	// candidates weighted by how well they match.
+	type matched struct {
+		candidate source.CompletionItem
+		score     float64
+	}
+	var matches []matched
+	for _, candidate := range candidates {
+		if m := match(prefix, candidateName(candidate.Label)); m > 0 {
+			matches = append(matches, matched{candidate, candidate.Score * m})
 		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	items := []protocol.CompletionItem{}
+	for i, m := range matches {
+		candidate := m.candidate
 		// InsertText is deprecated in favor of TextEdits.
 		// TODO(rstambler): Remove this logic when we are confident that we no
 		// longer need to support it.
 		insertText, _ := labelToProtocolSnippets(candidate.Label, candidate.Kind, insertTextFormat, signatureHelpEnabled)
+		if candidate.Qualifier != "" {
+			insertText = candidate.Qualifier + "." + insertText
+		}
 		//if strings.HasPrefix(insertText, prefix) {
 		//	insertText = insertText[len(prefix):]
 		//}
+		detail := candidate.Detail
+		if candidate.Import != "" {
+			detail = strings.TrimSpace(fmt.Sprintf("%s (import %q)", detail, candidate.Import))
+		}
 		item := lsp.CompletionItem{
 			Label:            candidate.Label,
-			Detail:           candidate.Detail,
+			Detail:           detail,
 			Kind:             toProtocolCompletionItemKind(candidate.Kind),
 			TextEdit: &lsp.TextEdit{
 				NewText: insertText,
@@ -106,11 +146,55 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 		//		Command: "editor.action.triggerParameterHints",
 		//	}
 		//}
-		items = append(items, item)
+		var importEdits []lsp.TextEdit
+		if candidate.Import != "" {
+			importEdits = imports.edits(candidate.Import, candidate.Qualifier)
+		}
+		items = append(items, protocol.CompletionItem{CompletionItem: item, AdditionalTextEdits: importEdits})
 	}
 	return items
 }
 
+// candidateName returns the name of a candidate from its label, which may be
+// followed by the parameters of a function or the value of a constant.
+func candidateName(label string) string {
+	if i := strings.IndexAny(label, "( "); i > 0 {
+		return label[:i]
+	}
+	return label
+}
+
+// completionImports computes the additional edits of the completion items
+// which import the package of their candidate in the completed file.
+type completionImports struct {
+	content []byte
+	fset    *token.FileSet
+	file    *ast.File
+}
+
+// edits returns the edits which import the package path in the file, named
+// name if it is not the last element of path.
+func (imports *completionImports) edits(path, name string) []lsp.TextEdit {
+	if imports == nil {
+		return nil
+	}
+	if imports.file == nil {
+		imports.fset = token.NewFileSet()
+		imports.file, _ = parser.ParseFile(imports.fset, "", imports.content, parser.ImportsOnly)
+		if imports.file == nil {
+			return nil
+		}
+	}
+	spec := strconv.Quote(path)
+	if name != "" && name != path[strings.LastIndex(path, "/")+1:] {
+		spec = name + " " + spec
+	}
+	edits := fileEdits{}
+	end := imports.file.Name.End()
+	edits.replace(imports.fset, end, end, "\n\nimport "+spec)
+	return edits[""]
+}
+
 func toProtocolCompletionItemKind(kind source.CompletionItemKind) lsp.CompletionItemKind {
 	switch kind {
 	case source.InterfaceCompletionItem:
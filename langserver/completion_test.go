@@ -0,0 +1,41 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToProtocolCompletionItems(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	candidates := []source.CompletionItem{
+		{Label: "Nowhere", Kind: source.VariableCompletionItem, Score: 1},
+		{Label: "NewWriter()", Kind: source.FunctionCompletionItem, Score: 1},
+		{Label: "Other", Kind: source.VariableCompletionItem, Score: 1},
+		{Label: "NewReader()", Kind: source.FunctionCompletionItem, Score: 0.5, Import: "example.com/go-bufio", Qualifier: "bufio"},
+	}
+	labels := func(match source.Matcher) []string {
+		var labels []string
+		for _, item := range toProtocolCompletionItems(candidates, "nw", lsp.Position{}, false, false, match, nil) {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+	require.Empty(labels(source.PrefixMatch))
+	require.Equal([]string{"NewWriter()", "Nowhere", "NewReader()"}, labels(source.FuzzyMatch))
+
+	const content = "package p\n\nimport \"fmt\"\n"
+	items := toProtocolCompletionItems(candidates[3:], "New", lsp.Position{Line: 4, Character: 4}, false, false, source.PrefixMatch, &completionImports{content: []byte(content)})
+	require.Len(items, 1)
+	item := items[0]
+	require.Equal("bufio.NewReader()", item.TextEdit.NewText)
+	require.Equal(`(import "example.com/go-bufio")`, item.Detail)
+	require.Equal([]lsp.TextEdit{{
+		Range:   lsp.Range{Start: lsp.Position{Line: 0, Character: 9}, End: lsp.Position{Line: 0, Character: 9}},
+		NewText: "\n\nimport bufio \"example.com/go-bufio\"",
+	}}, item.AdditionalTextEdits)
+}
@@ -19,11 +19,57 @@ type Config struct {
 	// Defaults to "always" if not specified
 	GlobalCacheStyle string
 
+	// GlobalCacheMemory is the budget, in megabytes, of the syntax and the
+	// type information of the packages of the global cache which are not
+	// in the workspace. The least recently used ones are dropped, and loaded
+	// again when they are used. 0 means unbounded.
+	//
+	// Defaults to 0
+	GlobalCacheMemory int
+
+	// GCPercent is the garbage collection target percentage of the server,
+	// see debug.SetGCPercent. The GOGC environment variable takes precedence.
+	// It is process-wide, so it cannot be overridden by InitializationOptions.
+	//
+	// Defaults to 0, which keeps the default of the runtime.
+	GCPercent int
+
+	// MemoryLimit is the soft memory limit, in megabytes, of the server, see
+	// debug.SetMemoryLimit. The GOMEMLIMIT environment variable takes
+	// precedence. It is process-wide, so it cannot be overridden by
+	// InitializationOptions.
+	//
+	// Defaults to 0, which derives it from GlobalCacheMemory when it is set.
+	MemoryLimit int
+
+	// GlobalCacheProfile is what is loaded into the global cache: "full"
+	// for the syntax and the type information of every package, or
+	// "navigation" for the types only of the dependencies of the loaded
+	// packages, whose files are parsed when they are used.
+	//
+	// Defaults to "full"
+	GlobalCacheProfile string
+
+	// IndexingProgress is how the progress of the loading of the packages
+	// of the workspace in the "always" cache style is reported: "progress"
+	// for the work done progress of the initialize request, "message" for
+	// window/showMessage notifications, or "none".
+	//
+	// Defaults to "none"
+	IndexingProgress string
+
 	// DiagnosticsEnabled enables handling of diagnostics
 	//
 	// Defaults to false if not specified.
 	DiagnosticsStyle string
 
+	// DiagnosticsHistory is the number of the last published sets of
+	// diagnostics of every document returned by the
+	// bingo/diagnosticsHistory request. 0 disables the history.
+	//
+	// Defaults to 0
+	DiagnosticsHistory int
+
 	// FormatStyle format style
 	//
 	// Defaults to "gofmt" if not secified
@@ -42,6 +88,35 @@ type Config struct {
 	// Defaults to half of your CPU cores if not specified.
 	MaxParallelism int
 
+	// MaxRequestsPerSecond limits how many hover, completion, signature help
+	// and definition requests are served per second and method. Identical
+	// requests received in a short window always share a single computation.
+	//
+	// Defaults to 0, which means unlimited.
+	MaxRequestsPerSecond int
+
+	// SlowRequestThreshold is the time, in milliseconds, above which a
+	// request is logged as slow, with the time spent loading the packages,
+	// type-checking them, walking their syntax and type information and
+	// encoding the result.
+	//
+	// Defaults to 0, which logs none.
+	SlowRequestThreshold int
+
+	// SlowRequestProfileDir is the directory the CPU profiles of the slow
+	// requests are written to, from the moment they exceed the
+	// SlowRequestThreshold. Only one request is profiled at a time.
+	//
+	// Defaults to empty, which profiles none.
+	SlowRequestProfileDir string
+
+	// KeepaliveInterval is the interval, in seconds, of the bingo/ping
+	// requests sent to the client. The connection is closed when the client
+	// does not answer one within the interval.
+	//
+	// Defaults to 0, which sends none.
+	KeepaliveInterval int
+
 	// EnhanceSignatureHelp enhance the signature help with return result.
 	//
 	// Defaults to false
@@ -51,6 +126,300 @@ type Config struct {
 	//
 	// Defaults to empty
 	BuildTags []string
+
+	// BuildVariants are the build configurations, besides the one of the
+	// BuildTags, the open files are also type-checked in when they are
+	// built in them, eg. "GOOS=darwin" or "GOOS=windows GOARCH=386
+	// tags=integration,cgo". Their compiler diagnostics are merged with the
+	// ones of the BuildTags, noting the configurations in their message.
+	// Every variant loads the package of the file again.
+	//
+	// Defaults to empty
+	BuildVariants []string
+
+	// DisabledFeatures lists the features which should neither be advertised
+	// in the server capabilities nor served, eg. "documentFormatting",
+	// "workspaceSymbol" or "diagnostics".
+	//
+	// Defaults to empty
+	DisabledFeatures []string
+
+	// DocumentColor enables the color provider for color.RGBA and
+	// color.NRGBA literals and "#RRGGBB" strings.
+	//
+	// Defaults to false
+	DocumentColor bool
+
+	// CoverageOnSave runs the tests of the package of a test file with
+	// coverage when it is saved, and pushes their coverage with the
+	// bingo/publishCoverage notification.
+	//
+	// Defaults to false
+	CoverageOnSave bool
+
+	// CoverageDiagnostics reports the blocks which are not covered by the
+	// tests run by bingo/coverage or on save as hint diagnostics.
+	//
+	// Defaults to false
+	CoverageDiagnostics bool
+
+	// RenameFiles renames the file of a type along with the type, when the
+	// file is named after its only type, e.g. http_server.go or
+	// httpserver.go for HTTPServer.
+	//
+	// Defaults to false
+	RenameFiles bool
+
+	// ReferencesCodeLens enables a code lens above exported functions and
+	// types which shows their number of references.
+	//
+	// Defaults to false
+	ReferencesCodeLens bool
+
+	// ImplementationCodeLens enables a code lens above interfaces which shows
+	// their number of implementations, and above methods which shows the
+	// interface method they implement.
+	//
+	// Defaults to false
+	ImplementationCodeLens bool
+
+	// RunCodeLens enables a code lens on func main and on the package clause
+	// of package main files which runs them with go run.
+	//
+	// Defaults to false
+	RunCodeLens bool
+
+	// RunFlags are the flags passed to go run by the run code lens.
+	//
+	// Defaults to empty
+	RunFlags []string
+
+	// RunEnv are the "KEY=VALUE" environment variables added to the
+	// environment of go run by the run code lens.
+	//
+	// Defaults to empty
+	RunEnv []string
+
+	// TestCodeLens enables a code lens on the test and benchmark functions
+	// of test files which runs them with go test.
+	//
+	// Defaults to false
+	TestCodeLens bool
+
+	// GOROOT is the root of the Go toolchain of the workspace, relative to
+	// the root of the workspace, eg. a toolchain checked into the repository
+	// or supplied by Bazel. The mismatches between its go command, its
+	// standard library and their export data are reported as diagnostics.
+	//
+	// Defaults to empty, which uses the GOROOT of the environment
+	GOROOT string
+
+	// PlaygroundURL is the URL of the Go Playground, or of a proxy of it,
+	// the bingo.playground.share command uploads the programs to.
+	//
+	// Defaults to https://play.golang.org
+	PlaygroundURL string
+
+	// Offline avoids any network access: the go command runs with
+	// GOPROXY=off and GOSUMDB=off, and with -mod=mod unless the workspace is
+	// vendored, so that it resolves the imports with the module cache only,
+	// and bingo.playground.share is disabled. The required modules missing
+	// from the module cache are reported once, as a diagnostic of go.mod.
+	//
+	// Defaults to false
+	Offline bool
+
+	// WrapErrors wraps the errors returned by the inserted error checks with
+	// fmt.Errorf and the name of the called function, eg.
+	// fmt.Errorf("os.Open: %w", err).
+	//
+	// Defaults to false
+	WrapErrors bool
+
+	// AutoPackageClause inserts the package clause, and the license header,
+	// in the empty Go files when they are opened. Otherwise a code action
+	// inserts them. The package is the one of the other files of the
+	// directory, or else named after the directory.
+	//
+	// Defaults to false
+	AutoPackageClause bool
+
+	// LicenseHeader is the text/template of the comment inserted above the
+	// package clause of the empty Go files, eg. "Copyright {{.Year}} Acme".
+	// The lines are commented unless it already is a comment.
+	//
+	// Defaults to empty
+	LicenseHeader string
+
+	// TagSchemaFile is a JSON file of rules the struct tags of the workspace
+	// are checked against, relative to the root of the workspace, eg.
+	// {"rules": [{"types": ["*Config"], "required": ["yaml", "default"],
+	// "unique": ["yaml", "env"]}]}. The missing and the duplicated tags are
+	// reported as missingTag and duplicateTag warnings. The file is read at
+	// initialization.
+	//
+	// Defaults to empty, which disables the check.
+	TagSchemaFile string
+
+	// RouteIndex indexes the HTTP routes registered with net/http,
+	// gorilla/mux, chi and gin: workspace/symbol finds them by method and
+	// pattern, eg. "GET /users", and the definition of a route pattern is
+	// its handler.
+	//
+	// Defaults to false
+	RouteIndex bool
+
+	// Frameworks are the dependency injection frameworks, "wire" and "fx",
+	// whose provider sets are checked, and whose providers are located by
+	// the bingo/providerUsages request.
+	//
+	// Defaults to empty
+	Frameworks []string
+
+	// MockBackend generates the mocks of the bingo.mock command: "builtin"
+	// for a struct with a function field per method, "mockgen" or "moq" to
+	// run these tools.
+	//
+	// Defaults to "builtin"
+	MockBackend string
+
+	// EnumCodeLens enables a code lens above the integer types of const
+	// enum groups which generates their String method with the bingo.enum
+	// command, or regenerates it once generated.
+	//
+	// Defaults to false
+	EnumCodeLens bool
+
+	// TaintSinks are the glob patterns of the functions the experimental
+	// bingo.taint command traces data flows to, eg. "os/exec.Command" or
+	// "(*database/sql.DB).Query*".
+	//
+	// Defaults to empty, which uses the os/exec and database/sql sinks.
+	TaintSinks []string
+
+	// FolderEnv maps workspace folders, given as URIs or paths, to the
+	// environment variables which override the environment of the go command
+	// in them, eg. GOFLAGS or GOPRIVATE.
+	//
+	// Defaults to empty
+	FolderEnv map[string]map[string]string
+
+	// DiagnosticsSeverity remaps the severity of the diagnostics by their
+	// code, eg. "unusedVariable", or by their source, eg. "LSP: Go compiler",
+	// to "error", "warning", "information" or "hint". Codes take precedence
+	// over sources.
+	//
+	// Defaults to empty
+	DiagnosticsSeverity map[string]string
+
+	// CompleteUnexported includes the unexported members of the other
+	// packages of the workspace in the completion, marked as not accessible,
+	// and marks the unexported symbols of workspace/symbol. A quick fix
+	// exports the unexported members referenced from other packages.
+	//
+	// Defaults to false
+	CompleteUnexported bool
+
+	// CompletionMatcher is how the candidates of the completion match the
+	// identifier being typed: "prefix" for the candidates of which it is a
+	// prefix, or "fuzzy" for the candidates containing its characters in
+	// order, ignoring their case, ranked by how well they match.
+	//
+	// Defaults to "prefix"
+	CompletionMatcher string
+
+	// DeepCompletion includes the exported members of the cached packages
+	// which are not imported yet in the completion of identifiers. Their
+	// completion items insert the import of their package.
+	//
+	// Defaults to false
+	DeepCompletion bool
+
+	// InlineCompletion are the providers of the suggestions of
+	// textDocument/inlineCompletion, in order: "iferr" for the error check
+	// of the preceding assignment, "structfill" for the fields of an empty
+	// struct literal, "template" for the obvious statement following the
+	// preceding one, eg. the deferred Unlock of a Lock, and the providers
+	// registered with RegisterInlineCompletionProvider.
+	//
+	// Defaults to empty, which uses the built-in providers.
+	InlineCompletion []string
+
+	// FixOnSave is which fixes are pushed with workspace/applyEdit when a
+	// document is saved, once the user confirms them: "imports" adds the
+	// missing imports and removes the unused ones, "all" also removes the
+	// unused variables, "none" pushes none.
+	//
+	// Defaults to "none"
+	FixOnSave string
+
+	// Analyses enables or disables the analyzers of the diagnostics by name,
+	// e.g. "printf": false or "shadow": true. The vet analyzers are enabled
+	// by default, and deepequalerrors, nilness and shadow are disabled.
+	//
+	// Defaults to empty
+	Analyses map[string]bool
+
+	// NolintMarker is the marker of the //nolint:<code> directives which
+	// suppress diagnostics. The //lint:ignore and //lint:file-ignore
+	// directives are always honored.
+	//
+	// Defaults to "nolint"
+	NolintMarker string
+
+	// CommandAllowlist lists the commands which run code of the workspace,
+	// eg. "bingo.run", that may run without a confirmation of the user. It
+	// cannot be overridden by InitializationOptions.
+	//
+	// Defaults to empty
+	CommandAllowlist []string
+
+	// ScrubCommandEnv restricts the environment of the commands which run
+	// code of the workspace to the variables the go command needs. It
+	// cannot be overridden by InitializationOptions.
+	//
+	// Defaults to false
+	ScrubCommandEnv bool
+
+	// TokenStream streams the changes of the semantic tokens of the
+	// documents of the project, including those edited by the other clients
+	// sharing it, with the bingo/documentTokens notification, for companion
+	// tools which mirror them.
+	//
+	// Defaults to false
+	TokenStream bool
+
+	// ReadOnly disables the features which edit the documents, i.e. the
+	// formatting, the rename and the code actions, the fixes and the tests
+	// run on save, and the commands which edit the documents or run tools
+	// or code of the workspace, e.g. for a code browsing web UI over a
+	// shared checkout. It cannot be overridden by InitializationOptions.
+	//
+	// Defaults to false
+	ReadOnly bool
+
+	// AllowedRoots are the directories, besides the workspace, GOROOT and
+	// the module cache, whose files the requests may refer to, eg. the
+	// GOPATH of a project which is not a module. The relative ones are
+	// relative to the workspace. The requests referring to the other files
+	// are refused. It cannot be overridden by InitializationOptions.
+	//
+	// Defaults to empty
+	AllowedRoots []string
+
+	// AuditLog is the file the edits of the formatting, of the rename and of
+	// the commands, the commands executed with their arguments, and the
+	// commands run with their exit codes are appended to, one JSON object
+	// per line. It cannot be overridden by InitializationOptions.
+	//
+	// Defaults to empty, which disables the audit log.
+	AuditLog string
+
+	// SessionFile is the file where the open documents and the published
+	// diagnostics are persisted, so that they survive a restart of the server.
+	//
+	// Defaults to empty, which disables persistence.
+	SessionFile string
 }
 
 // Apply sets the corresponding field in c for each non-nil field in o.
@@ -66,10 +435,26 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.DiagnosticsStyle = *o.DiagnosticsStyle
 	}
 
+	if o.DiagnosticsHistory != nil {
+		c.DiagnosticsHistory = *o.DiagnosticsHistory
+	}
+
 	if o.GlobalCacheStyle != nil {
 		c.GlobalCacheStyle = *o.GlobalCacheStyle
 	}
 
+	if o.GlobalCacheMemory != nil {
+		c.GlobalCacheMemory = *o.GlobalCacheMemory
+	}
+
+	if o.GlobalCacheProfile != nil {
+		c.GlobalCacheProfile = *o.GlobalCacheProfile
+	}
+
+	if o.IndexingProgress != nil {
+		c.IndexingProgress = *o.IndexingProgress
+	}
+
 	if o.FormatStyle != nil {
 		c.FormatStyle = *o.FormatStyle
 	}
@@ -90,6 +475,146 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.BuildTags = o.BuildTags
 	}
 
+	if o.BuildVariants != nil {
+		c.BuildVariants = o.BuildVariants
+	}
+
+	if o.DisabledFeatures != nil {
+		c.DisabledFeatures = o.DisabledFeatures
+	}
+
+	if o.DocumentColor != nil {
+		c.DocumentColor = *o.DocumentColor
+	}
+
+	if o.CoverageOnSave != nil {
+		c.CoverageOnSave = *o.CoverageOnSave
+	}
+
+	if o.CoverageDiagnostics != nil {
+		c.CoverageDiagnostics = *o.CoverageDiagnostics
+	}
+
+	if o.RenameFiles != nil {
+		c.RenameFiles = *o.RenameFiles
+	}
+
+	if o.ReferencesCodeLens != nil {
+		c.ReferencesCodeLens = *o.ReferencesCodeLens
+	}
+
+	if o.ImplementationCodeLens != nil {
+		c.ImplementationCodeLens = *o.ImplementationCodeLens
+	}
+
+	if o.RunCodeLens != nil {
+		c.RunCodeLens = *o.RunCodeLens
+	}
+
+	if o.RunFlags != nil {
+		c.RunFlags = o.RunFlags
+	}
+
+	if o.RunEnv != nil {
+		c.RunEnv = o.RunEnv
+	}
+
+	if o.TaintSinks != nil {
+		c.TaintSinks = o.TaintSinks
+	}
+
+	if o.FolderEnv != nil {
+		c.FolderEnv = o.FolderEnv
+	}
+
+	if o.DiagnosticsSeverity != nil {
+		c.DiagnosticsSeverity = o.DiagnosticsSeverity
+	}
+
+	if o.CompleteUnexported != nil {
+		c.CompleteUnexported = *o.CompleteUnexported
+	}
+
+	if o.CompletionMatcher != nil {
+		c.CompletionMatcher = *o.CompletionMatcher
+	}
+
+	if o.DeepCompletion != nil {
+		c.DeepCompletion = *o.DeepCompletion
+	}
+
+	if o.FixOnSave != nil {
+		c.FixOnSave = *o.FixOnSave
+	}
+
+	if o.Analyses != nil {
+		c.Analyses = o.Analyses
+	}
+
+	if o.NolintMarker != nil {
+		c.NolintMarker = *o.NolintMarker
+	}
+
+	if o.TestCodeLens != nil {
+		c.TestCodeLens = *o.TestCodeLens
+	}
+
+	if o.GOROOT != nil {
+		c.GOROOT = *o.GOROOT
+	}
+
+	if o.PlaygroundURL != nil {
+		c.PlaygroundURL = *o.PlaygroundURL
+	}
+
+	if o.Offline != nil {
+		c.Offline = *o.Offline
+	}
+
+	if o.WrapErrors != nil {
+		c.WrapErrors = *o.WrapErrors
+	}
+
+	if o.AutoPackageClause != nil {
+		c.AutoPackageClause = *o.AutoPackageClause
+	}
+
+	if o.LicenseHeader != nil {
+		c.LicenseHeader = *o.LicenseHeader
+	}
+
+	if o.TagSchemaFile != nil {
+		c.TagSchemaFile = *o.TagSchemaFile
+	}
+
+	if o.RouteIndex != nil {
+		c.RouteIndex = *o.RouteIndex
+	}
+
+	if o.Frameworks != nil {
+		c.Frameworks = o.Frameworks
+	}
+
+	if o.InlineCompletion != nil {
+		c.InlineCompletion = o.InlineCompletion
+	}
+
+	if o.MockBackend != nil {
+		c.MockBackend = *o.MockBackend
+	}
+
+	if o.EnumCodeLens != nil {
+		c.EnumCodeLens = *o.EnumCodeLens
+	}
+
+	if o.TokenStream != nil {
+		c.TokenStream = *o.TokenStream
+	}
+
+	if o.SessionFile != nil {
+		c.SessionFile = *o.SessionFile
+	}
+
 	return c
 }
 
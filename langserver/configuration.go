@@ -0,0 +1,123 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/imports"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// buildFlags returns the build flags of the go command for the BuildTags.
+func (c *Config) buildFlags() []string {
+	buildFlags := []string{}
+	if len(c.BuildTags) > 0 {
+		buildFlags = append(buildFlags, "-tags", strings.Join(c.BuildTags, " "))
+	}
+	return buildFlags
+}
+
+// diagnosticsStyle returns the DiagnosticsStyle, or none if the diagnostics
+// feature is disabled.
+func (c *Config) diagnosticsStyle() DiagnosticsStyleEnum {
+	if !c.featureEnabled(diagnosticsFeature) {
+		return noneDiagnostics
+	}
+	return DiagnosticsStyleEnum(c.DiagnosticsStyle)
+}
+
+// handleDidChangeConfiguration applies the settings of the client on top of
+// the current configuration. The settings have the fields of the
+// InitializationOptions, at the top level or in a "bingo" section. A change
+// of the build tags invalidates the type-checked packages. The capabilities
+// announced by the server on initialize do not change.
+func (h *LangHandler) handleDidChangeConfiguration(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DidChangeConfigurationParams) error {
+	options, err := settingsOptions(params.Settings)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	old := h.config
+	config := old.Apply(options).readOnly()
+	if _, err := cache.ParseLoadProfile(config.GlobalCacheProfile); err != nil {
+		h.mu.Unlock()
+		return err
+	}
+	h.config = &config
+	h.mu.Unlock()
+
+	imports.LocalPrefix = config.GoimportsLocalPrefix
+	h.overlay.reconfigure(config.diagnosticsStyle(), newSeverityMap(config.DiagnosticsSeverity), newAnalyzers(config.Analyses), config.buildVariants())
+	if !reflect.DeepEqual(old.buildFlags(), config.buildFlags()) {
+		if err := h.setBuildFlags(ctx, conn, &config); err != nil {
+			return err
+		}
+		h.notifyLog("build flags changed to " + strings.Join(config.buildFlags(), " "))
+	}
+	return nil
+}
+
+// setBuildFlags changes the build flags of the project to those of config.
+// The project of a pool is shared by clients which keep their build flags, so
+// the client moves to the project of the pool with its new build flags
+// instead, along with its open documents.
+func (h *LangHandler) setBuildFlags(ctx context.Context, conn jsonrpc2.JSONRPC2, config *Config) error {
+	c, ok := conn.(*jsonrpc2.Conn)
+	if h.pool == nil || !ok {
+		h.project.SetBuildFlags(config.buildFlags())
+		return nil
+	}
+
+	h.mu.Lock()
+	rootPath := h.FilePath(h.init.Root())
+	h.mu.Unlock()
+	project, initProject, release := h.newProject(ctx, c, rootPath, config, nil)
+	if err := initProject(); err != nil {
+		release()
+		return err
+	}
+
+	h.mu.Lock()
+	previous, releasePrevious := h.project, h.releaseProject
+	h.project, h.releaseProject = project, release
+	h.mu.Unlock()
+	h.indexing.set(project)
+	h.healthState.set(project, config.Offline)
+	h.healthState.setInitialized()
+	h.scratch.move(project)
+	h.notebooks.move(project)
+	h.overlay.move(project)
+	traceProject(previous, -1)
+	traceProject(project, 1)
+	releasePrevious()
+	return nil
+}
+
+// settingsOptions decodes the settings of a workspace/didChangeConfiguration
+// notification.
+func settingsOptions(settings interface{}) (*InitializationOptions, error) {
+	if section, ok := settings.(map[string]interface{}); ok {
+		if bingo, ok := section["bingo"]; ok {
+			settings = bingo
+		}
+	}
+	if settings == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	var options InitializationOptions
+	if err := json.Unmarshal(data, &options); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
@@ -0,0 +1,57 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingsOptions(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	var settings interface{}
+	require.NoError(json.Unmarshal([]byte(`{"bingo": {"buildTags": ["integration"], "diagnosticsStyle": "onsave"}}`), &settings))
+	options, err := settingsOptions(settings)
+	require.NoError(err)
+	cfg := NewDefaultConfig().Apply(options)
+	require.Equal([]string{"-tags", "integration"}, cfg.buildFlags())
+	require.Equal(onsaveDiagnostics, cfg.diagnosticsStyle())
+
+	require.NoError(json.Unmarshal([]byte(`{"goimportsLocalPrefix": "example.com"}`), &settings))
+	options, err = settingsOptions(settings)
+	require.NoError(err)
+	require.Equal("example.com", NewDefaultConfig().Apply(options).GoimportsLocalPrefix)
+
+	options, err = settingsOptions(nil)
+	require.NoError(err)
+	require.Nil(options)
+	cfg = NewDefaultConfig()
+	require.Equal([]string{}, cfg.buildFlags())
+}
+
+func TestUnknownLoadProfile(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cfg := testConfig(cache.None)
+	cfg.GlobalCacheProfile = "types"
+	root := writeWorkspace(t, map[string]string{"go.mod": "module example.com/p\n"})
+	ctx := context.Background()
+	client, server := net.Pipe()
+	jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}), NewHandler(cfg))
+	conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), newFakeClient())
+	defer conn.Close()
+
+	err := conn.Call(ctx, "initialize", InitializeParams{InitializeParams: lsp.InitializeParams{RootURI: util.PathToURI(filepath.ToSlash(root))}}, nil)
+	require.Error(err)
+	require.Contains(err.Error(), `unknown cache profile "types"`)
+}
@@ -0,0 +1,195 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// confinement is the policy of the files the requests may refer to: those
+// of the workspace, of GOROOT, of the module cache and of
+// Config.AllowedRoots. The other files, eg. file:///etc/passwd or
+// file:///root/work/../../etc/passwd, are refused with a policy error, so
+// that a client cannot have them read, type checked or run by commands.
+type confinement struct {
+	// rootPath is the root of the workspace, against which the relative
+	// paths are resolved.
+	rootPath string
+
+	// roots are the allowed directories, cleaned and with their symbolic
+	// links evaluated.
+	roots []string
+}
+
+// processGoEnv is the go env of the server process, read once.
+var processGoEnv struct {
+	once  sync.Once
+	goEnv map[string]string
+}
+
+// serverGoEnv returns the go env of the server process, or nil if it cannot
+// be read.
+func serverGoEnv() map[string]string {
+	processGoEnv.once.Do(func() {
+		goEnv, err := cache.ProcessGoEnv(context.Background())
+		if err != nil {
+			log.Printf("go env: %s", err)
+			return
+		}
+		processGoEnv.goEnv = goEnv
+	})
+	return processGoEnv.goEnv
+}
+
+// newConfinement returns the policy of the workspace rootPath, with the
+// additional allowed roots, which are relative to rootPath. GOROOT and the
+// module cache are those of the server process: the environment overrides
+// of a project come from its client, which could allow any file with eg.
+// GOMODCACHE=/.
+func newConfinement(rootPath string, allowed []string) *confinement {
+	goEnv := serverGoEnv()
+	goroot := goEnv["GOROOT"]
+	if goroot == "" {
+		goroot = runtime.GOROOT()
+	}
+	modCache := goEnv["GOMODCACHE"]
+	if modCache == "" {
+		if gopath := filepath.SplitList(goEnv["GOPATH"]); len(gopath) > 0 {
+			modCache = filepath.Join(gopath[0], "pkg", "mod")
+		}
+	}
+
+	c := &confinement{rootPath: rootPath}
+	for _, root := range append([]string{rootPath, goroot, modCache}, allowed...) {
+		if root == "" {
+			continue
+		}
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(rootPath, root)
+		}
+		c.roots = append(c.roots, evalSymlinks(filepath.Clean(root)))
+	}
+	return c
+}
+
+// evalSymlinks returns path with the symbolic links of its longest existing
+// prefix evaluated, so that the files being created are confined too.
+func evalSymlinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	dir := filepath.Dir(path)
+	if dir == path {
+		return path
+	}
+	return filepath.Join(evalSymlinks(dir), filepath.Base(path))
+}
+
+// allowed reports whether the file or the directory path is under an
+// allowed root.
+func (c *confinement) allowed(path string) bool {
+	path = evalSymlinks(filepath.Clean(path))
+	for _, root := range c.roots {
+		if underRoot(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// underRoot reports whether the clean path is root or is under it, ignoring
+// the case on Windows.
+func underRoot(path, root string) bool {
+	if runtime.GOOS == "windows" {
+		path, root = strings.ToLower(path), strings.ToLower(root)
+	}
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// check returns a policy error if the parameters of req refer to a file
+// which is not allowed: the file URIs anywhere in them, however they are
+// spelled, eg. file:/etc/passwd or FILE:///etc/passwd, and the paths of the
+// arguments of workspace/executeCommand, the relative ones being resolved
+// against the root of the workspace as the commands do. The other URIs, eg.
+// untitled:, do not refer to files.
+func (c *confinement) check(req *jsonrpc2.Request) error {
+	if c == nil || req.Params == nil {
+		return nil
+	}
+	var params interface{}
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		// Left to the handler of the request to report.
+		return nil
+	}
+	if req.Method == "workspace/executeCommand" {
+		if params, ok := params.(map[string]interface{}); ok {
+			for _, arg := range flattenStrings(params["arguments"]) {
+				if !c.allowed(c.resolve(arg)) {
+					return requestErrorf(PolicyError, "%s is outside of the workspace, GOROOT and the module cache", arg)
+				}
+			}
+		}
+	}
+	for _, s := range flattenStrings(params) {
+		path, ok := c.filePath(s)
+		if ok && !c.allowed(path) {
+			return requestErrorf(PolicyError, "%s is outside of the workspace, GOROOT and the module cache", s)
+		}
+	}
+	return nil
+}
+
+// resolve returns path, resolved against the root of the workspace if it is
+// relative.
+func (c *confinement) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.rootPath, path)
+}
+
+// filePath returns the path of s if it is a file URI, whose scheme is
+// case-insensitive, or false if it is not one.
+func (c *confinement) filePath(s string) (string, bool) {
+	u, err := url.Parse(s)
+	if err != nil || !strings.EqualFold(u.Scheme, "file") {
+		return "", false
+	}
+	if u.Opaque != "" {
+		// A relative path, eg. file:../etc/passwd.
+		return c.resolve(filepath.FromSlash(u.Opaque)), true
+	}
+	return c.resolve(util.UriToRealPath(lsp.DocumentURI(s))), true
+}
+
+// flattenStrings returns the strings of the decoded JSON value v.
+func flattenStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var strs []string
+		for _, e := range v {
+			strs = append(strs, flattenStrings(e)...)
+		}
+		return strs
+	case map[string]interface{}:
+		var strs []string
+		for _, e := range v {
+			strs = append(strs, flattenStrings(e)...)
+		}
+		return strs
+	}
+	return nil
+}
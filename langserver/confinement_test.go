@@ -0,0 +1,106 @@
+package langserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfinement(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "confinement")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	root, outside := filepath.Join(dir, "root"), filepath.Join(dir, "outside")
+	require.NoError(os.MkdirAll(root, 0755))
+	require.NoError(os.MkdirAll(outside, 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(outside, "secret.go"), nil, 0644))
+	require.NoError(os.Symlink(outside, filepath.Join(root, "link")))
+
+	c := &confinement{rootPath: root, roots: []string{evalSymlinks(root)}}
+	require.True(c.allowed(root))
+	require.True(c.allowed(filepath.Join(root, "a.go")))
+	require.True(c.allowed(filepath.Join(root, "new", "b.go")), "the files being created are allowed")
+	require.False(c.allowed(filepath.Join(root, "..", "outside", "secret.go")))
+	require.False(c.allowed(filepath.Join(root, "link", "secret.go")), "the symbolic links are followed")
+	require.False(c.allowed(root + "2"))
+
+	request := func(method string, params interface{}) *jsonrpc2.Request {
+		data, err := json.Marshal(params)
+		require.NoError(err)
+		raw := json.RawMessage(data)
+		return &jsonrpc2.Request{Method: method, Params: &raw}
+	}
+	secret := util.PathToURI(filepath.ToSlash(filepath.Join(outside, "secret.go")))
+	err = c.check(request("textDocument/hover", lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: secret}}))
+	require.Error(err)
+	require.Equal(PolicyError, err.(*requestError).category)
+	require.NoError(c.check(request("textDocument/hover", lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go")))}})))
+	require.NoError(c.check(request("textDocument/hover", lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: "untitled:Untitled-1"}})))
+
+	// The other spellings of the file URIs.
+	slashed := filepath.ToSlash(filepath.Join(outside, "secret.go"))
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed
+	}
+	for _, uri := range []string{"file:" + slashed, "FILE://" + slashed, "File://localhost" + slashed, "file:../outside/secret.go"} {
+		require.Error(c.check(request("textDocument/hover", lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: lsp.DocumentURI(uri)}})), uri)
+	}
+
+	// The paths of the arguments of the commands.
+	require.Error(c.check(request("workspace/executeCommand", lsp.ExecuteCommandParams{Command: runCommand, Arguments: []interface{}{map[string]interface{}{"dir": outside}}})))
+	require.NoError(c.check(request("workspace/executeCommand", lsp.ExecuteCommandParams{Command: runCommand, Arguments: []interface{}{root, "./..."}})))
+	// The relative paths are resolved against the root of the workspace.
+	require.Error(c.check(request("workspace/executeCommand", lsp.ExecuteCommandParams{Command: runCommand, Arguments: []interface{}{map[string]interface{}{"dir": "../outside"}}})))
+	require.NoError(c.check(request("workspace/executeCommand", lsp.ExecuteCommandParams{Command: runCommand, Arguments: []interface{}{map[string]interface{}{"dir": "sub"}}})))
+
+	// GOROOT and the module cache are those of the server, whatever the
+	// environment of the project.
+	c = newConfinement(root, nil)
+	require.False(c.allowed(outside))
+	require.True(c.allowed(filepath.Join(runtime.GOROOT(), "src", "fmt", "print.go")))
+
+	// Nothing is confined before the project is initialized.
+	var none *confinement
+	require.NoError(none.check(request("textDocument/hover", lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: secret}})))
+}
+
+func TestConfinementPolicyError(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/p\n",
+		"a.go":   "package p\n",
+	})
+	outside := writeWorkspace(t, map[string]string{
+		"b.go": "package q\n",
+	})
+	tx := newWorkspaceContext(t, testConfig(cache.Ondemand), root)
+
+	var hover interface{}
+	err := tx.conn.Call(tx.ctx, "textDocument/hover", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: util.PathToURI(filepath.ToSlash(filepath.Join(outside, "b.go")))},
+	}, &hover)
+	rpcErr, ok := err.(*jsonrpc2.Error)
+	require.True(ok, "%v", err)
+	require.Equal(int64(codePolicy), rpcErr.Code)
+	var data ErrorData
+	require.NoError(json.Unmarshal(*rpcErr.Data, &data))
+	require.Equal(ErrorData{Category: PolicyError}, data)
+
+	require.NoError(tx.conn.Call(tx.ctx, "textDocument/hover", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go")))},
+	}, &hover))
+}
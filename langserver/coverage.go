@@ -0,0 +1,281 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// coverageSource is the source of the diagnostics of the uncovered blocks.
+const coverageSource = "coverage"
+
+// coverageCommand is the name under which the bingo/coverage requests and the
+// coverage on save, which run the tests of the workspace, are allowed by
+// Config.CommandAllowlist or confirmed by the user.
+const coverageCommand = "bingo/coverage"
+
+// coverBlockRegexp matches a block of a coverage profile:
+// "file:startLine.startCol,endLine.endCol numStmts count".
+var coverBlockRegexp = regexp.MustCompile(`^(.+):(\d+)\.(\d+),(\d+)\.(\d+) (\d+) (\d+)$`)
+
+// CoverageParams is the parameter of the bingo/coverage request.
+type CoverageParams struct {
+	// TextDocument is a document of the package whose tests are run.
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// Coverage is the statement coverage of the tests of a package, returned by
+// bingo/coverage and pushed by the bingo/publishCoverage notification.
+type Coverage struct {
+	Dir string `json:"dir"`
+
+	// Percent is the percentage of the statements of the package covered
+	// by its tests.
+	Percent float64 `json:"percent"`
+
+	Files []FileCoverage `json:"files"`
+}
+
+// FileCoverage are the blocks of a file which are covered by the tests and
+// the ones which are not, in order.
+type FileCoverage struct {
+	URI       lsp.DocumentURI `json:"uri"`
+	Covered   []lsp.Range     `json:"covered"`
+	Uncovered []lsp.Range     `json:"uncovered"`
+}
+
+func (h *LangHandler) handleCoverage(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params CoverageParams) (*Coverage, error) {
+	if err := checkFileURI(params.TextDocument.URI); err != nil {
+		return nil, err
+	}
+	if err := h.confirmCommand(ctx, conn, coverageParams(params.TextDocument.URI)); err != nil {
+		return nil, err
+	}
+
+	coverage, err := h.coverage(ctx, filepath.Dir(util.UriToRealPath(params.TextDocument.URI)))
+	if err != nil {
+		return nil, err
+	}
+	h.showCoverage(ctx, coverage)
+	return coverage, nil
+}
+
+// coverageOnSave runs the tests of the package of the test file saved by req
+// with coverage in the background if Config.CoverageOnSave is set, and
+// pushes their coverage with the bingo/publishCoverage notification.
+func (h *LangHandler) coverageOnSave(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) {
+	if !h.config.CoverageOnSave {
+		return
+	}
+	var params lsp.DidSaveTextDocumentParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return
+	}
+	filename := util.UriToRealPath(params.TextDocument.URI)
+	if !strings.HasSuffix(filename, "_test.go") {
+		return
+	}
+
+	go func() {
+		// The tests outlive the notification, so they are not bound to its
+		// context.
+		ctx := context.Background()
+		if err := h.confirmCommand(ctx, conn, coverageParams(params.TextDocument.URI)); err != nil {
+			h.notifyLog(err.Error())
+			return
+		}
+		coverage, err := h.coverage(ctx, filepath.Dir(filename))
+		if err != nil {
+			h.notifyLog(err.Error())
+			return
+		}
+		_ = conn.Notify(ctx, "bingo/publishCoverage", coverage)
+		h.showCoverage(ctx, coverage)
+	}()
+}
+
+// coverageParams returns the command confirmed before the tests of the
+// package of the document uri are run with coverage.
+func coverageParams(uri lsp.DocumentURI) lsp.ExecuteCommandParams {
+	return lsp.ExecuteCommandParams{Command: coverageCommand, Arguments: []interface{}{uri}}
+}
+
+// coverage runs the tests of the package in dir with go test -coverprofile
+// and returns their coverage.
+func (h *LangHandler) coverage(ctx context.Context, dir string) (*Coverage, error) {
+	profile, err := ioutil.TempFile("", "bingo-coverage")
+	if err != nil {
+		return nil, err
+	}
+	profile.Close()
+	defer os.Remove(profile.Name())
+
+	args := []string{"test", "-coverprofile=" + profile.Name(), "."}
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = h.commandEnv(nil)
+	out, runErr := cmd.CombinedOutput()
+	h.auditExec(cmd, runErr)
+
+	// The profile is written even if a test fails.
+	data, err := ioutil.ReadFile(profile.Name())
+	if err != nil || len(data) == 0 {
+		if runErr == nil {
+			runErr = fmt.Errorf("no coverage profile")
+		}
+		return nil, fmt.Errorf("go %s (in %s): %s\n%s", strings.Join(args, " "), dir, runErr, strings.TrimSpace(string(out)))
+	}
+	return parseCoverProfile(data, dir)
+}
+
+// parseCoverProfile parses the coverage profile of the package in dir. The
+// blocks listed several times are covered if one of them is.
+func parseCoverProfile(data []byte, dir string) (*Coverage, error) {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if !strings.HasPrefix(lines[0], "mode: ") {
+		return nil, fmt.Errorf("%q is not a coverage profile", lines[0])
+	}
+
+	type block struct {
+		rng   lsp.Range
+		stmts int
+	}
+	covered := map[string]map[block]bool{}
+	for _, line := range lines[1:] {
+		m := coverBlockRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			return nil, fmt.Errorf("invalid block %q of the coverage profile", line)
+		}
+		n := make([]int, 6)
+		for i := range n {
+			n[i], _ = strconv.Atoi(m[i+2])
+		}
+		// The lines and the columns of the profile are 1-based.
+		b := block{
+			rng: lsp.Range{
+				Start: lsp.Position{Line: n[0] - 1, Character: n[1] - 1},
+				End:   lsp.Position{Line: n[2] - 1, Character: n[3] - 1},
+			},
+			stmts: n[4],
+		}
+		// The files are named by the import path of the package.
+		filename := filepath.Join(dir, path.Base(m[1]))
+		if covered[filename] == nil {
+			covered[filename] = map[block]bool{}
+		}
+		covered[filename][b] = covered[filename][b] || n[5] > 0
+	}
+
+	coverage := &Coverage{Dir: dir}
+	var stmts, coveredStmts int
+	for filename, blocks := range covered {
+		fc := FileCoverage{URI: lsp.DocumentURI(source.ToURI(filename)), Covered: []lsp.Range{}, Uncovered: []lsp.Range{}}
+		for b, ok := range blocks {
+			stmts += b.stmts
+			if ok {
+				coveredStmts += b.stmts
+				fc.Covered = append(fc.Covered, b.rng)
+			} else {
+				fc.Uncovered = append(fc.Uncovered, b.rng)
+			}
+		}
+		sortRanges(fc.Covered)
+		sortRanges(fc.Uncovered)
+		coverage.Files = append(coverage.Files, fc)
+	}
+	sort.Slice(coverage.Files, func(i, j int) bool {
+		return coverage.Files[i].URI < coverage.Files[j].URI
+	})
+	if stmts > 0 {
+		coverage.Percent = 100 * float64(coveredStmts) / float64(stmts)
+	}
+	return coverage, nil
+}
+
+// showCoverage reports the uncovered blocks of coverage as hints if
+// Config.CoverageDiagnostics is set, and diagnoses the open documents of the
+// package again to publish them.
+func (h *LangHandler) showCoverage(ctx context.Context, coverage *Coverage) {
+	if !h.config.CoverageDiagnostics {
+		return
+	}
+	h.overlay.setCoverage(coverage)
+	for _, fc := range coverage.Files {
+		if h.overlay.version(fc.URI) == 0 {
+			continue
+		}
+		f, err := h.View().GetFile(ctx, span.FromDocumentURI(fc.URI))
+		if err != nil {
+			continue
+		}
+		// The documents of the package are diagnosed together.
+		h.overlay.diagnosetics(ctx, f)
+		return
+	}
+}
+
+// setCoverage replaces the hints of the uncovered blocks of the files of
+// coverage.
+func (h *overlay) setCoverage(coverage *Coverage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.coverage == nil {
+		h.coverage = map[string][]lsp.Diagnostic{}
+	}
+	for filename := range h.coverage {
+		if filepath.Dir(filename) == coverage.Dir {
+			delete(h.coverage, filename)
+		}
+	}
+	for _, fc := range coverage.Files {
+		var diagnostics []lsp.Diagnostic
+		for _, rng := range fc.Uncovered {
+			diagnostics = append(diagnostics, lsp.Diagnostic{
+				Range:    rng,
+				Severity: lsp.Hint,
+				Source:   coverageSource,
+				Message:  "not covered by the tests",
+			})
+		}
+		if len(diagnostics) > 0 {
+			h.coverage[util.UriToRealPath(fc.URI)] = diagnostics
+		}
+	}
+}
+
+// coverageDiagnostics returns the hints of the uncovered blocks, by filename.
+func (h *overlay) coverageDiagnostics() map[string][]lsp.Diagnostic {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	diagnostics := make(map[string][]lsp.Diagnostic, len(h.coverage))
+	for filename, d := range h.coverage {
+		diagnostics[filename] = d
+	}
+	return diagnostics
+}
+
+// clearCoverage drops the hints of the uncovered blocks of the document uri,
+// whose ranges are stale once it changes.
+func (h *overlay) clearCoverage(uri lsp.DocumentURI) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.coverage, util.UriToRealPath(uri))
+}
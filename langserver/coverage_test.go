@@ -0,0 +1,90 @@
+package langserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCoverProfile(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := filepath.FromSlash("/src/p")
+	profile := `mode: set
+example.com/p/p.go:3.14,5.2 1 1
+example.com/p/p.go:7.14,9.16 2 0
+example.com/p/p.go:9.16,11.3 1 1
+example.com/p/q.go:3.14,5.2 1 0
+example.com/p/p.go:7.14,9.16 2 0
+`
+	coverage, err := parseCoverProfile([]byte(profile), dir)
+	require.NoError(err)
+	require.Equal(dir, coverage.Dir)
+	require.InDelta(40, coverage.Percent, 0.001)
+
+	rng := func(startLine, startCol, endLine, endCol int) lsp.Range {
+		return lsp.Range{Start: lsp.Position{Line: startLine, Character: startCol}, End: lsp.Position{Line: endLine, Character: endCol}}
+	}
+	require.Equal([]FileCoverage{
+		{
+			URI:       lsp.DocumentURI(source.ToURI(filepath.Join(dir, "p.go"))),
+			Covered:   []lsp.Range{rng(2, 13, 4, 1), rng(8, 15, 10, 2)},
+			Uncovered: []lsp.Range{rng(6, 13, 8, 15)},
+		},
+		{
+			URI:       lsp.DocumentURI(source.ToURI(filepath.Join(dir, "q.go"))),
+			Covered:   []lsp.Range{},
+			Uncovered: []lsp.Range{rng(2, 13, 4, 1)},
+		},
+	}, coverage.Files)
+
+	_, err = parseCoverProfile([]byte("PASS\n"), dir)
+	require.Error(err)
+}
+
+func TestCoverageConfirmation(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/covered\n",
+		"a.go":   "package covered\n\nfunc A() int { return 1 }\n",
+		"a_test.go": `package covered
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestA(t *testing.T) {
+	_ = ioutil.WriteFile("ran", nil, 0644)
+	A()
+}
+`,
+	})
+	params := CoverageParams{TextDocument: lsp.TextDocumentIdentifier{URI: util.PathToURI(filepath.ToSlash(filepath.Join(root, "a_test.go")))}}
+	ran := func() bool {
+		_, err := os.Stat(filepath.Join(root, "ran"))
+		return err == nil
+	}
+
+	// The client does not confirm the tests.
+	tx := newWorkspaceContext(t, testConfig(cache.None), root)
+	require.Error(tx.conn.Call(tx.ctx, "bingo/coverage", params, nil))
+	require.False(ran(), "the tests ran without a confirmation")
+
+	cfg := testConfig(cache.None)
+	cfg.CommandAllowlist = []string{coverageCommand}
+	tx = newWorkspaceContext(t, cfg, root)
+	var coverage Coverage
+	require.NoError(tx.conn.Call(tx.ctx, "bingo/coverage", params, &coverage))
+	require.True(ran(), "the allowed tests did not run")
+	require.InDelta(100, coverage.Percent, 0.001)
+}
@@ -0,0 +1,158 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// DebugStatus is the result of the bingo/debug request and of the
+// /debug/bingo page of the --pprof listener: the latencies of the requests,
+// the loads of packages and the caches of the projects of the server, to be
+// attached to bug reports.
+type DebugStatus struct {
+	GoVersion  string    `json:"goVersion"`
+	Start      time.Time `json:"start"`
+	Goroutines int       `json:"goroutines"`
+
+	// HeapAlloc and HeapSys are the bytes of the allocated heap objects and
+	// of the heap obtained from the system, see runtime.MemStats.
+	HeapAlloc uint64 `json:"heapAlloc"`
+	HeapSys   uint64 `json:"heapSys"`
+
+	// Requests are the latencies of the requests and the notifications,
+	// by method.
+	Requests []MethodStats   `json:"requests"`
+	Loads    cache.LoadStats `json:"loads"`
+	Projects []ProjectStatus `json:"projects"`
+}
+
+// MethodStats are the latencies of the requests of a method, in
+// milliseconds.
+type MethodStats struct {
+	Method string `json:"method"`
+	Count  int64  `json:"count"`
+	Errors int64  `json:"errors"`
+
+	TotalMillis float64 `json:"totalMillis"`
+	MaxMillis   float64 `json:"maxMillis"`
+	LastMillis  float64 `json:"lastMillis"`
+}
+
+// ProjectStatus is the status of the global cache of a project.
+type ProjectStatus struct {
+	Cache cache.CacheStats `json:"cache"`
+
+	// ResidentMemory is the estimated memory of the packages whose syntax
+	// and type information are loaded, see bingo/memoryUsage.
+	ResidentMemory int64 `json:"residentMemory"`
+}
+
+// traces records the latencies of the requests of the process, and the
+// projects of its handlers, for DebugHandler.
+var traces = struct {
+	mu       sync.Mutex
+	start    time.Time
+	methods  map[string]*MethodStats
+	projects map[*cache.Project]int
+}{start: time.Now(), methods: map[string]*MethodStats{}, projects: map[*cache.Project]int{}}
+
+// traceRequest records the latency of the request of method which started at
+// start, and failed if err is not nil.
+func traceRequest(method string, start time.Time, err error) {
+	millis := float64(time.Since(start)) / float64(time.Millisecond)
+
+	traces.mu.Lock()
+	defer traces.mu.Unlock()
+	stats := traces.methods[method]
+	if stats == nil {
+		stats = &MethodStats{Method: method}
+		traces.methods[method] = stats
+	}
+	stats.Count++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.TotalMillis += millis
+	stats.LastMillis = millis
+	if millis > stats.MaxMillis {
+		stats.MaxMillis = millis
+	}
+}
+
+// traceProject adds the project of a handler to the status, and removes it
+// once none of the handlers sharing it are left if delta is negative.
+func traceProject(project *cache.Project, delta int) {
+	if project == nil {
+		return
+	}
+
+	traces.mu.Lock()
+	defer traces.mu.Unlock()
+	traces.projects[project] += delta
+	if traces.projects[project] <= 0 {
+		delete(traces.projects, project)
+	}
+}
+
+// debugStatus returns the status of the server.
+func debugStatus() *DebugStatus {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	status := &DebugStatus{
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  mem.HeapAlloc,
+		HeapSys:    mem.HeapSys,
+		Requests:   []MethodStats{},
+		Loads:      cache.GetLoadStats(),
+		Projects:   []ProjectStatus{},
+	}
+
+	traces.mu.Lock()
+	status.Start = traces.start
+	for _, stats := range traces.methods {
+		status.Requests = append(status.Requests, *stats)
+	}
+	var projects []*cache.Project
+	for project := range traces.projects {
+		projects = append(projects, project)
+	}
+	traces.mu.Unlock()
+
+	sort.Slice(status.Requests, func(i, j int) bool {
+		return status.Requests[i].Method < status.Requests[j].Method
+	})
+	for _, project := range projects {
+		status.Projects = append(status.Projects, ProjectStatus{
+			Cache:          project.CacheStats(),
+			ResidentMemory: project.MemoryUsage().Resident,
+		})
+	}
+	sort.Slice(status.Projects, func(i, j int) bool {
+		return status.Projects[i].Cache.Packages > status.Projects[j].Cache.Packages
+	})
+	return status
+}
+
+func (h *LangHandler) handleDebug(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) (*DebugStatus, error) {
+	return debugStatus(), nil
+}
+
+// DebugHandler serves the status of the server returned by bingo/debug as
+// JSON.
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(debugStatus())
+	})
+}
@@ -0,0 +1,40 @@
+package langserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugStatus(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	start := time.Now().Add(-10 * time.Millisecond)
+	traceRequest("test/debugStatus", start, nil)
+	traceRequest("test/debugStatus", start, errors.New("failed"))
+
+	var stats *MethodStats
+	for _, s := range debugStatus().Requests {
+		if s.Method == "test/debugStatus" {
+			s := s
+			stats = &s
+		}
+	}
+	require.NotNil(stats, "the requests of the method are traced")
+	require.Equal(int64(2), stats.Count)
+	require.Equal(int64(1), stats.Errors)
+	require.True(stats.MaxMillis >= 10 && stats.TotalMillis >= 20, "got %+v", stats)
+
+	// The page of the pprof listener serves the same status.
+	w := httptest.NewRecorder()
+	DebugHandler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/bingo", nil))
+	var status DebugStatus
+	require.NoError(json.Unmarshal(w.Body.Bytes(), &status))
+	require.NotEmpty(status.GoVersion)
+	require.NotEmpty(status.Requests)
+}
@@ -2,7 +2,6 @@ package langserver
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"go/ast"
 	"go/types"
@@ -83,6 +82,13 @@ func (h *LangHandler) doHandleXDefinition(ctx context.Context, conn jsonrpc2.JSO
 		return h.lookupCallExprDefinition(ctx, conn, pkg, pathNodes, node)
 	case *ast.SelectorExpr:
 		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, node.Sel)
+	case *ast.BasicLit:
+		if h.config.RouteIndex {
+			if loc, ok := routeDefinition(pkg, node); ok {
+				return []symbolLocationInformation{{Location: loc}}, nil
+			}
+		}
+		return nil, source.NewInvalidNodeError(pkg.GetFileSet(), firstNode)
 	default:
 		return nil, source.NewInvalidNodeError(pkg.GetFileSet(), firstNode)
 	}
@@ -142,7 +148,7 @@ func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.J
 		}
 	}
 	if len(nodes) == 0 {
-		return nil, errors.New("definition not found")
+		return nil, requestErrorf(NotFoundError, "definition not found")
 	}
 	findPackage := h.getFindPackageFunc()
 	locs := make([]symbolLocationInformation, 0, len(nodes))
@@ -0,0 +1,63 @@
+package langserver
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// maxDependentChecks is the number of the dependents of a saved document
+// diagnosed concurrently.
+var maxDependentChecks = runtime.NumCPU()
+
+// diagnoseDependents diagnoses the open documents whose packages import the
+// package of the saved document uri, one document per package. The packages
+// are diagnosed level by level in dependency order, so that a package shared
+// by several dependents is type-checked once, and those of a level are
+// diagnosed concurrently. The diagnostics of every package are published as
+// soon as it is diagnosed.
+func (h *overlay) diagnoseDependents(ctx context.Context, uri span.URI) {
+	h.mu.Lock()
+	var opened []span.URI
+	for sourceURI := range h.open {
+		if sourceURI != uri {
+			opened = append(opened, sourceURI)
+		}
+	}
+	h.mu.Unlock()
+	if len(opened) == 0 {
+		return
+	}
+
+	for _, level := range h.project.Dependents(uri, opened) {
+		if ctx.Err() != nil {
+			return
+		}
+		h.diagnoseLevel(ctx, level)
+	}
+}
+
+// diagnoseLevel diagnoses the documents uris with at most maxDependentChecks
+// of them at once.
+func (h *overlay) diagnoseLevel(ctx context.Context, uris []span.URI) {
+	sem := make(chan struct{}, maxDependentChecks)
+	var wg sync.WaitGroup
+	for _, uri := range uris {
+		f, err := h.view().GetFile(ctx, uri)
+		if err != nil {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			h.diagnosetics(ctx, f)
+		}()
+	}
+	wg.Wait()
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/sourcegraph/go-lsp"
 
@@ -26,11 +27,33 @@ func diagnostics(ctx context.Context, f source.File) (map[string][]lsp.Diagnosti
 	}
 
 	reports := make(map[string][]lsp.Diagnostic)
-	for _, filename := range pkg.GetFilenames() {
+	if cache.IsAuxiliary(pkg.GetPkgPath()) {
+		// The other files of the package are diagnosed in the configuration
+		// of the view, which excludes the file.
+		filename, err := f.URI().Filename()
+		if err != nil {
+			return nil, err
+		}
 		reports[filename] = []lsp.Diagnostic{}
+	} else {
+		for _, filename := range pkg.GetFilenames() {
+			reports[filename] = []lsp.Diagnostic{}
+		}
+	}
+	for _, err := range compilerErrors(pkg.GetErrors()) {
+		filename, diagnostic := compilerDiagnostic(err)
+		if _, ok := reports[filename]; ok {
+			reports[filename] = append(reports[filename], diagnostic)
+		}
 	}
+	return reports, nil
+}
+
+// compilerErrors returns the parse errors of a package, or its type errors
+// if it has none, since they may follow from the parse errors.
+func compilerErrors(errs []packages.Error) []packages.Error {
 	var parseErrors, typeErrors []packages.Error
-	for _, err := range pkg.GetErrors() {
+	for _, err := range errs {
 		switch err.Kind {
 		case packages.ParseError:
 			parseErrors = append(parseErrors, err)
@@ -42,35 +65,35 @@ func diagnostics(ctx context.Context, f source.File) (map[string][]lsp.Diagnosti
 		}
 	}
 	// Don't report type errors if there are parse errors.
-	errors := typeErrors
 	if len(parseErrors) > 0 {
-		errors = parseErrors
+		return parseErrors
 	}
-	for _, err := range errors {
-		pos := parseErrorPos(err)
-		line := pos.Line - 1
-		col := pos.Column - 1
-		diagnostic := lsp.Diagnostic{
-			// TODO(rstambler): Add support for diagnostic ranges.
-			Range: lsp.Range{
-				Start: lsp.Position{
-					Line:      line,
-					Character: col,
-				},
-				End: lsp.Position{
-					Line:      line,
-					Character: col,
-				},
+	return typeErrors
+}
+
+// compilerDiagnostic returns the diagnostic of a parse or type error, and
+// the file it is reported in.
+func compilerDiagnostic(err packages.Error) (string, lsp.Diagnostic) {
+	pos := parseErrorPos(err)
+	line := pos.Line - 1
+	col := pos.Column - 1
+	return pos.Filename, lsp.Diagnostic{
+		// TODO(rstambler): Add support for diagnostic ranges.
+		Range: lsp.Range{
+			Start: lsp.Position{
+				Line:      line,
+				Character: col,
 			},
-			Severity: lsp.Error,
-			Source:   "LSP: Go compiler",
-			Message:  err.Msg,
-		}
-		if _, ok := reports[pos.Filename]; ok {
-			reports[pos.Filename] = append(reports[pos.Filename], diagnostic)
-		}
+			End: lsp.Position{
+				Line:      line,
+				Character: col,
+			},
+		},
+		Severity: lsp.Error,
+		Code:     compilerErrorCode(err),
+		Source:   "LSP: Go compiler",
+		Message:  err.Msg,
 	}
-	return reports, nil
 }
 
 func parseErrorPos(pkgErr packages.Error) (pos token.Position) {
@@ -0,0 +1,141 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// handleDocumentSymbolTree handles the textDocument/documentSymbol requests
+// of the clients which support hierarchical document symbols.
+func (h *LangHandler) handleDocumentSymbolTree(ctx context.Context, params lsp.DocumentSymbolParams) ([]protocol.DocumentSymbol, error) {
+	pkg, astFile, err := h.loadPackageAndAst(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return documentSymbols(pkg.GetFileSet(), astFile), nil
+}
+
+// documentSymbols returns the symbols of file as trees, in which the fields
+// of the structs and the methods of the interfaces are the children of their
+// type. The children of a symbol are within its range, so that the methods
+// declared in file are not: they are named after their receiver, eg. (*T).M.
+func documentSymbols(fset *token.FileSet, file *ast.File) []protocol.DocumentSymbol {
+	symbols := []protocol.DocumentSymbol{}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil {
+				if len(decl.Recv.List) == 1 {
+					symbols = append(symbols, funcSymbol(fset, decl, "("+recvString(decl.Recv.List[0].Type)+")."+decl.Name.Name, lsp.SKMethod))
+				}
+				continue
+			}
+			symbols = append(symbols, funcSymbol(fset, decl, decl.Name.Name, lsp.SKFunction))
+
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				// The range of a single declaration includes its keyword.
+				node := ast.Node(spec)
+				if len(decl.Specs) == 1 && !decl.Lparen.IsValid() {
+					node = decl
+				}
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name == "_" {
+						continue
+					}
+					symbols = append(symbols, typeSymbol(fset, node, spec))
+				case *ast.ValueSpec:
+					kind := lsp.SKVariable
+					if decl.Tok == token.CONST {
+						kind = lsp.SKConstant
+					}
+					detail := ""
+					if spec.Type != nil {
+						detail = types.ExprString(spec.Type)
+					}
+					for _, name := range spec.Names {
+						if name.Name == "_" {
+							continue
+						}
+						symbols = append(symbols, protocol.DocumentSymbol{
+							Name:           name.Name,
+							Detail:         detail,
+							Kind:           kind,
+							Range:          rangeForNode(fset, node),
+							SelectionRange: rangeForNode(fset, name),
+						})
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// funcSymbol returns the symbol name of the function or the method fn, whose
+// detail is its signature.
+func funcSymbol(fset *token.FileSet, fn *ast.FuncDecl, name string, kind lsp.SymbolKind) protocol.DocumentSymbol {
+	return protocol.DocumentSymbol{
+		Name:           name,
+		Detail:         types.ExprString(fn.Type),
+		Kind:           kind,
+		Range:          rangeForNode(fset, fn),
+		SelectionRange: rangeForNode(fset, fn.Name),
+	}
+}
+
+// typeSymbol returns the symbol of the type spec, whose range is the one of
+// node, with its fields or its methods.
+func typeSymbol(fset *token.FileSet, node ast.Node, spec *ast.TypeSpec) protocol.DocumentSymbol {
+	symbol := protocol.DocumentSymbol{
+		Name:           spec.Name.Name,
+		Kind:           lsp.SKClass,
+		Range:          rangeForNode(fset, node),
+		SelectionRange: rangeForNode(fset, spec.Name),
+	}
+	var fields *ast.FieldList
+	switch typ := spec.Type.(type) {
+	case *ast.StructType:
+		symbol.Detail = "struct"
+		fields = typ.Fields
+	case *ast.InterfaceType:
+		symbol.Detail = "interface"
+		symbol.Kind = lsp.SKInterface
+		fields = typ.Methods
+	default:
+		symbol.Detail = types.ExprString(spec.Type)
+		return symbol
+	}
+
+	for _, field := range fields.List {
+		kind := lsp.SKField
+		if _, ok := field.Type.(*ast.FuncType); ok {
+			kind = lsp.SKMethod
+		}
+		child := protocol.DocumentSymbol{
+			Detail: types.ExprString(field.Type),
+			Kind:   kind,
+			Range:  rangeForNode(fset, field),
+		}
+		if len(field.Names) == 0 {
+			// An embedded field or interface.
+			child.Name = strings.TrimPrefix(child.Detail, "*")
+			child.SelectionRange = rangeForNode(fset, field.Type)
+			symbol.Children = append(symbol.Children, child)
+			continue
+		}
+		for _, name := range field.Names {
+			child.Name = name.Name
+			child.SelectionRange = rangeForNode(fset, name)
+			symbol.Children = append(symbol.Children, child)
+		}
+	}
+	return symbol
+}
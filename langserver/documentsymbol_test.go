@@ -0,0 +1,100 @@
+package langserver
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentSymbols(t *testing.T) {
+	require := require.New(t)
+
+	src := `package p
+
+const C = 1
+
+var (
+	V, _ string
+	W    = 2
+)
+
+type S struct {
+	*Embedded
+	A, B int
+}
+
+type I interface {
+	M(x int) error
+}
+
+type N int
+
+func F(s string) (int, error) { return 0, nil }
+
+func (s *S) Method() {}
+
+func (o *Other) Orphan() {}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "a.go", src, 0)
+	require.NoError(err)
+
+	rng := func(startLine, startCharacter, endLine, endCharacter int) lsp.Range {
+		return lsp.Range{Start: lsp.Position{Line: startLine, Character: startCharacter}, End: lsp.Position{Line: endLine, Character: endCharacter}}
+	}
+	type symbol struct {
+		name, detail string
+		kind         lsp.SymbolKind
+		children     []symbol
+	}
+	var simplify func(symbols []protocol.DocumentSymbol) []symbol
+	simplify = func(symbols []protocol.DocumentSymbol) []symbol {
+		var simple []symbol
+		for _, s := range symbols {
+			simple = append(simple, symbol{s.Name, s.Detail, s.Kind, simplify(s.Children)})
+		}
+		return simple
+	}
+
+	symbols := documentSymbols(fset, file)
+	require.Equal([]symbol{
+		{"C", "", lsp.SKConstant, nil},
+		{"V", "string", lsp.SKVariable, nil},
+		{"W", "", lsp.SKVariable, nil},
+		{"S", "struct", lsp.SKClass, []symbol{
+			{"Embedded", "*Embedded", lsp.SKField, nil},
+			{"A", "int", lsp.SKField, nil},
+			{"B", "int", lsp.SKField, nil},
+		}},
+		{"I", "interface", lsp.SKInterface, []symbol{
+			{"M", "func(x int) error", lsp.SKMethod, nil},
+		}},
+		{"N", "int", lsp.SKClass, nil},
+		{"F", "func(s string) (int, error)", lsp.SKFunction, nil},
+		{"(*S).Method", "func()", lsp.SKMethod, nil},
+		{"(*Other).Orphan", "func()", lsp.SKMethod, nil},
+	}, simplify(symbols))
+
+	// The ranges of the single declarations include their keyword.
+	require.Equal(rng(2, 0, 2, 11), symbols[0].Range)
+	require.Equal(rng(2, 6, 2, 7), symbols[0].SelectionRange)
+	require.Equal(rng(5, 1, 5, 12), symbols[1].Range)
+	require.Equal(rng(9, 0, 12, 1), symbols[3].Range)
+	require.Equal(rng(9, 5, 9, 6), symbols[3].SelectionRange)
+	require.Equal(rng(22, 0, 22, 23), symbols[7].Range)
+	require.Equal(rng(22, 12, 22, 18), symbols[7].SelectionRange)
+
+	// The children are within the range of their parent.
+	before := func(a, b lsp.Position) bool {
+		return a.Line < b.Line || a.Line == b.Line && a.Character <= b.Character
+	}
+	for _, s := range symbols {
+		for _, child := range s.Children {
+			require.True(before(s.Range.Start, child.Range.Start) && before(child.Range.End, s.Range.End), "%s is outside %s", child.Name, s.Name)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// enumCommand is the workspace/executeCommand command which generates the
+// String method of a const enum type, like stringer does.
+const enumCommand = "bingo.enum"
+
+// EnumParams is the argument of the bingo.enum command. The position selects
+// the type or one of its constants.
+type EnumParams struct {
+	lsp.TextDocumentPositionParams
+
+	// Helpers also generates the MarshalText and UnmarshalText methods and
+	// the ParseX function of the type.
+	Helpers bool `json:"helpers,omitempty"`
+}
+
+// enumHeader matches the header of the files generated by bingo.enum.
+var enumHeader = regexp.MustCompile(`^Code generated by "bingo\.enum -type=(\w+)( -helpers)?"; DO NOT EDIT\.`)
+
+func (h *LangHandler) handleEnum(ctx context.Context, conn jsonrpc2.JSONRPC2, params EnumParams) (*protocol.WorkspaceEdit, error) {
+	pkg, obj, err := h.enumTypeAt(ctx, params.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := generateEnum(pkg.GetTypes(), obj, params.Helpers)
+	if err != nil {
+		return nil, err
+	}
+
+	edit := createFileEdit(util.PathToURI(enumFilename(pkg.GetFileSet(), obj)), string(src))
+	if err := applyEdit(ctx, conn, "generate String for "+obj.Name(), edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// enumTypeAt returns the integer type at position, or the type of the
+// constant at position.
+func (h *LangHandler) enumTypeAt(ctx context.Context, position lsp.TextDocumentPositionParams) (source.Package, *types.TypeName, error) {
+	pkg, pos, err := h.typeCheck(ctx, position.TextDocument.URI, position.Position)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	ident, ok := pathNodes[0].(*ast.Ident)
+	if !ok {
+		return nil, nil, source.NewInvalidNodeError(pkg.GetFileSet(), pathNodes[0])
+	}
+
+	var obj *types.TypeName
+	switch o := pkg.GetTypesInfo().ObjectOf(ident).(type) {
+	case *types.TypeName:
+		obj = o
+	case *types.Const:
+		if named, ok := o.Type().(*types.Named); ok {
+			obj = named.Obj()
+		}
+	}
+	if obj == nil || !isEnumType(obj) {
+		return nil, nil, requestErrorf(InvalidPositionError, "not an integer type or constant")
+	}
+	if obj.Pkg() != pkg.GetTypes() {
+		return nil, nil, fmt.Errorf("%s is not declared in package %s", obj.Name(), pkg.GetName())
+	}
+	return pkg, obj, nil
+}
+
+// isEnumType reports whether obj is a package level named integer type.
+func isEnumType(obj *types.TypeName) bool {
+	if obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() || obj.IsAlias() {
+		return false
+	}
+	basic, ok := obj.Type().Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0
+}
+
+// enumConsts returns the package level constants of type obj, in the order
+// of their declarations.
+func enumConsts(obj *types.TypeName) []*types.Const {
+	var consts []*types.Const
+	scope := obj.Pkg().Scope()
+	for _, name := range scope.Names() {
+		if c, ok := scope.Lookup(name).(*types.Const); ok && types.Identical(c.Type(), obj.Type()) {
+			consts = append(consts, c)
+		}
+	}
+	sort.Slice(consts, func(i, j int) bool {
+		return consts[i].Pos() < consts[j].Pos()
+	})
+	return consts
+}
+
+// enumFilename returns the name of the file generated for obj, next to the
+// file which declares it.
+func enumFilename(fset *token.FileSet, obj *types.TypeName) string {
+	dir := filepath.Dir(fset.Position(obj.Pos()).Filename)
+	return filepath.Join(dir, strings.ToLower(obj.Name())+"_enum.go")
+}
+
+// generateEnum returns the source of the String method of obj, and of its
+// text marshaling methods and parse function if helpers is set.
+func generateEnum(pkg *types.Package, obj *types.TypeName, helpers bool) ([]byte, error) {
+	consts := enumConsts(obj)
+	if len(consts) == 0 {
+		return nil, requestErrorf(RefusedError, "no constant of type %s", obj.Name())
+	}
+	name := obj.Name()
+
+	var flags string
+	if helpers {
+		flags = " -helpers"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by \"bingo.enum -type=%s%s\"; DO NOT EDIT.\n\n", name, flags)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg.Name())
+	if helpers {
+		buf.WriteString("import (\n\"fmt\"\n\"strconv\"\n)\n\n")
+	} else {
+		buf.WriteString("import \"strconv\"\n\n")
+	}
+
+	// The first constant of a value names it, like stringer does.
+	fmt.Fprintf(&buf, "func (i %s) String() string {\nswitch i {\n", name)
+	seen := map[string]bool{}
+	for _, c := range consts {
+		if v := c.Val().ExactString(); !seen[v] {
+			seen[v] = true
+			fmt.Fprintf(&buf, "case %s:\nreturn %q\n", c.Name(), c.Name())
+		}
+	}
+	buf.WriteString("}\n")
+	if obj.Type().Underlying().(*types.Basic).Info()&types.IsUnsigned != 0 {
+		fmt.Fprintf(&buf, "return %q + strconv.FormatUint(uint64(i), 10) + \")\"\n}\n", name+"(")
+	} else {
+		fmt.Fprintf(&buf, "return %q + strconv.FormatInt(int64(i), 10) + \")\"\n}\n", name+"(")
+	}
+
+	if helpers {
+		parse := enumParseName(obj)
+		fmt.Fprintf(&buf, "\n// MarshalText implements encoding.TextMarshaler.\nfunc (i %s) MarshalText() ([]byte, error) {\nreturn []byte(i.String()), nil\n}\n", name)
+		fmt.Fprintf(&buf, "\n// UnmarshalText implements encoding.TextUnmarshaler.\nfunc (i *%s) UnmarshalText(text []byte) error {\nv, err := %s(string(text))\nif err != nil {\nreturn err\n}\n*i = v\nreturn nil\n}\n", name, parse)
+		fmt.Fprintf(&buf, "\n// %s returns the %s named s.\nfunc %s(s string) (%s, error) {\nswitch s {\n", parse, name, parse, name)
+		for _, c := range consts {
+			fmt.Fprintf(&buf, "case %q:\nreturn %s, nil\n", c.Name(), c.Name())
+		}
+		fmt.Fprintf(&buf, "}\nreturn 0, fmt.Errorf(\"invalid %s: %%q\", s)\n}\n", name)
+	}
+	return format.Source(buf.Bytes())
+}
+
+// enumParseName returns the name of the parse function of obj, which is
+// exported if obj is.
+func enumParseName(obj *types.TypeName) string {
+	if obj.Exported() {
+		return "Parse" + obj.Name()
+	}
+	r, size := utf8.DecodeRuneInString(obj.Name())
+	return "parse" + string(unicode.ToUpper(r)) + obj.Name()[size:]
+}
+
+// generatedEnum reports whether pkg has a file generated by bingo.enum for
+// the type name, and whether it has the helpers.
+func generatedEnum(pkg source.Package, name string) (found, helpers bool) {
+	for _, f := range pkg.GetSyntax() {
+		for _, cg := range f.Comments {
+			if cg.Pos() > f.Package {
+				break
+			}
+			if m := enumHeader.FindStringSubmatch(cg.Text()); m != nil && m[1] == name {
+				return true, m[2] != ""
+			}
+		}
+	}
+	return false, false
+}
+
+// enumCodeLenses returns an unresolved code lens for every integer type
+// declared at the top level of f which has constants in an iota group.
+func enumCodeLenses(pkg source.Package, f *ast.File, uri lsp.DocumentURI) []protocol.CodeLens {
+	fset := pkg.GetFileSet()
+	info := pkg.GetTypesInfo()
+	lenses := []protocol.CodeLens{}
+	if info == nil {
+		return lenses
+	}
+
+	for _, decl := range f.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			name := spec.(*ast.TypeSpec).Name
+			obj, ok := info.Defs[name].(*types.TypeName)
+			if !ok || !isEnumType(obj) {
+				continue
+			}
+			for _, c := range enumConsts(obj) {
+				if findConstGroup(pkg.GetSyntax(), c) != nil {
+					rng := rangeForNode(fset, name)
+					lenses = append(lenses, protocol.CodeLens{
+						Range: rng,
+						Data:  codeLensData{Kind: enumLens, URI: uri, Position: rng.Start},
+					})
+					break
+				}
+			}
+		}
+	}
+	return lenses
+}
+
+// enumLensCommand returns the command of the enum code lens at position,
+// which regenerates the String method with the same options if it has
+// already been generated.
+func (h *LangHandler) enumLensCommand(ctx context.Context, position lsp.TextDocumentPositionParams) (*lsp.Command, error) {
+	pkg, obj, err := h.enumTypeAt(ctx, position)
+	if err != nil {
+		return nil, err
+	}
+
+	title := "generate String"
+	found, helpers := generatedEnum(pkg, obj.Name())
+	if found {
+		title = "regenerate String"
+	}
+	return &lsp.Command{
+		Title:     title,
+		Command:   enumCommand,
+		Arguments: []interface{}{EnumParams{TextDocumentPositionParams: position, Helpers: helpers}},
+	}, nil
+}
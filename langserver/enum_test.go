@@ -0,0 +1,89 @@
+package langserver
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func checkEnumPackage(t *testing.T, src string) *types.Package {
+	return checkSource(t, src).Types
+}
+
+func TestGenerateEnum(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	pkg := checkEnumPackage(t, `package p
+
+type Color uint8
+
+const (
+	Red Color = iota
+	Green
+	Blue
+	Default = Red
+)
+`)
+	obj := pkg.Scope().Lookup("Color").(*types.TypeName)
+	require.True(isEnumType(obj))
+
+	src, err := generateEnum(pkg, obj, false)
+	require.NoError(err)
+	require.Equal(`// Code generated by "bingo.enum -type=Color"; DO NOT EDIT.
+
+package p
+
+import "strconv"
+
+func (i Color) String() string {
+	switch i {
+	case Red:
+		return "Red"
+	case Green:
+		return "Green"
+	case Blue:
+		return "Blue"
+	}
+	return "Color(" + strconv.FormatUint(uint64(i), 10) + ")"
+}
+`, string(src))
+
+	src, err = generateEnum(pkg, obj, true)
+	require.NoError(err)
+	require.Contains(string(src), `func ParseColor(s string) (Color, error) {`)
+	require.Contains(string(src), `case "Default":
+		return Default, nil`)
+	require.Contains(string(src), `func (i *Color) UnmarshalText(text []byte) error {`)
+	require.Contains(string(src), `import (
+	"fmt"
+	"strconv"
+)`)
+
+	m := enumHeader.FindStringSubmatch(`Code generated by "bingo.enum -type=Color -helpers"; DO NOT EDIT.`)
+	require.Equal([]string{`Code generated by "bingo.enum -type=Color -helpers"; DO NOT EDIT.`, "Color", " -helpers"}, m)
+}
+
+func TestGenerateEnumErrors(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	pkg := checkEnumPackage(t, `package p
+
+type level int
+
+type name string
+
+const (
+	a name = "a"
+)
+`)
+	level := pkg.Scope().Lookup("level").(*types.TypeName)
+	require.True(isEnumType(level))
+	require.Equal("parseLevel", enumParseName(level))
+	_, err := generateEnum(pkg, level, false)
+	require.EqualError(err, "no constant of type level")
+
+	require.False(isEnumType(pkg.Scope().Lookup("name").(*types.TypeName)))
+}
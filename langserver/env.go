@@ -0,0 +1,80 @@
+package langserver
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// statusCommand is the workspace/executeCommand command which reports the
+// environment of the workspace.
+const statusCommand = "bingo.status"
+
+// Status is the result of the bingo.status command.
+type Status struct {
+	// Root is the root directory of the workspace.
+	Root string `json:"root"`
+
+	// Env are the "KEY=VALUE" overrides of the environment of the go
+	// command for the workspace.
+	Env []string `json:"env"`
+
+	// GoEnv is the go env captured when the workspace was initialized.
+	GoEnv map[string]string `json:"goEnv"`
+}
+
+func (h *LangHandler) handleStatus(ctx context.Context) (*Status, error) {
+	env := h.project.Env()
+	if env == nil {
+		env = []string{}
+	}
+	return &Status{
+		Root:  h.FilePath(h.init.Root()),
+		Env:   env,
+		GoEnv: h.project.GoEnv(),
+	}, nil
+}
+
+// folderEnv returns the environment overrides of Config.FolderEnv which apply
+// to dir. The overrides of a folder apply to its subdirectories, and the
+// ones of the innermost folder win.
+func (c *Config) folderEnv(dir string) []string {
+	var folders []string
+	for folder := range c.FolderEnv {
+		folders = append(folders, folder)
+	}
+	// Outermost folders first, so that the innermost ones override them.
+	sort.Slice(folders, func(i, j int) bool {
+		return len(folderPath(folders[i])) < len(folderPath(folders[j]))
+	})
+
+	vars := map[string]string{}
+	for _, folder := range folders {
+		path := folderPath(folder)
+		if dir != path && !strings.HasPrefix(dir, path+string(filepath.Separator)) {
+			continue
+		}
+		for k, v := range c.FolderEnv[folder] {
+			vars[k] = v
+		}
+	}
+
+	var env []string
+	for k, v := range vars {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// folderPath returns the path of a workspace folder given as a URI or a path.
+func folderPath(folder string) string {
+	if util.IsURI(lsp.DocumentURI(folder)) {
+		folder = util.UriToRealPath(lsp.DocumentURI(folder))
+	}
+	return filepath.Clean(folder)
+}
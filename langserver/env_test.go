@@ -0,0 +1,23 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFolderEnv(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cfg := NewDefaultConfig()
+	cfg.FolderEnv = map[string]map[string]string{
+		"file:///work":      {"GOFLAGS": "-mod=vendor", "GOPRIVATE": "example.com"},
+		"/work/project":     {"GOFLAGS": "-mod=mod"},
+		"file:///elsewhere": {"GOPROXY": "off"},
+	}
+
+	require.Equal([]string{"GOFLAGS=-mod=mod", "GOPRIVATE=example.com"}, cfg.folderEnv("/work/project"))
+	require.Equal([]string{"GOFLAGS=-mod=vendor", "GOPRIVATE=example.com"}, cfg.folderEnv("/work"))
+	require.Empty(cfg.folderEnv("/workspace"))
+}
@@ -0,0 +1,273 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// errorCheckLabel is the label of the completion item which inserts the
+// error check of the preceding assignment.
+const errorCheckLabel = "iferr"
+
+// errorCheck is the `if err != nil` statement checking the error assigned
+// by an assignment.
+type errorCheck struct {
+	err *ast.Ident
+
+	// call is the name of the function returning the error, or empty if the
+	// error is not returned by a call.
+	call string
+
+	// results are the results of the enclosing function, which returns the
+	// error as its last result. If it does not, the error is passed to the
+	// Fatal method of the *testing.T or *testing.B parameter named test.
+	results []types.Type
+	test    string
+}
+
+// newErrorCheck returns the error check of the assignment assign, whose
+// parents are the enclosing nodes, or nil if it does not assign an error or
+// its error is already checked.
+func newErrorCheck(info *types.Info, assign *ast.AssignStmt, parents []ast.Node) *errorCheck {
+	if assign == nil || len(parents) == 0 {
+		return nil
+	}
+	errorType := types.Universe.Lookup("error").Type()
+	c := &errorCheck{}
+	for i := len(assign.Lhs) - 1; i >= 0; i-- {
+		if id, ok := assign.Lhs[i].(*ast.Ident); ok && id.Name != "_" && types.Identical(info.TypeOf(id), errorType) {
+			c.err = id
+			break
+		}
+	}
+	list, ok := blockStmts(parents[0])
+	if c.err == nil || !ok {
+		return nil
+	}
+	for i, stmt := range list {
+		if stmt == assign && i+1 < len(list) && isErrorChecked(list[i+1], c.err.Name) {
+			return nil
+		}
+	}
+	if len(assign.Rhs) == 1 {
+		if call, ok := assign.Rhs[0].(*ast.CallExpr); ok {
+			c.call = types.ExprString(call.Fun)
+		}
+	}
+
+	var sig *types.Signature
+	for _, n := range parents {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			sig, _ = info.TypeOf(n).(*types.Signature)
+		case *ast.FuncDecl:
+			if fn, ok := info.Defs[n.Name].(*types.Func); ok {
+				sig = fn.Type().(*types.Signature)
+			}
+		default:
+			continue
+		}
+		break
+	}
+	if sig == nil {
+		return nil
+	}
+	if n := sig.Results().Len(); n > 0 && types.Identical(sig.Results().At(n-1).Type(), errorType) {
+		for i := 0; i < n; i++ {
+			c.results = append(c.results, sig.Results().At(i).Type())
+		}
+		return c
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		param := sig.Params().At(i)
+		ptr, ok := param.Type().(*types.Pointer)
+		if !ok || param.Name() == "" || param.Name() == "_" {
+			continue
+		}
+		if named, ok := ptr.Elem().(*types.Named); ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "testing" && (named.Obj().Name() == "T" || named.Obj().Name() == "B") {
+			c.test = param.Name()
+			return c
+		}
+	}
+	return nil
+}
+
+// blockStmts returns the statements of the block n, and whether n is a
+// block.
+func blockStmts(n ast.Node) ([]ast.Stmt, bool) {
+	switch n := n.(type) {
+	case *ast.BlockStmt:
+		return n.List, true
+	case *ast.CaseClause:
+		return n.Body, true
+	case *ast.CommClause:
+		return n.Body, true
+	}
+	return nil, false
+}
+
+// isErrorChecked reports whether stmt is `if err != nil`.
+func isErrorChecked(stmt ast.Stmt, err string) bool {
+	ifStmt, ok := stmt.(*ast.IfStmt)
+	if !ok {
+		return false
+	}
+	cond, ok := ifStmt.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return false
+	}
+	x, ok := cond.X.(*ast.Ident)
+	return ok && x.Name == err
+}
+
+// text returns the error check, whose lines after the first are indented
+// by indent. The returned error is wrapped with fmt.Errorf if wrap is set,
+// with a placeholder for its message in a snippet.
+func (c *errorCheck) text(qualifier types.Qualifier, wrap bool, indent string, snippet bool) string {
+	escape := func(s string) string {
+		if !snippet {
+			return s
+		}
+		return strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`).Replace(s)
+	}
+
+	var body string
+	if c.results == nil {
+		body = escape(c.test + ".Fatal(" + c.err.Name + ")")
+	} else {
+		var results []string
+		for _, t := range c.results[:len(c.results)-1] {
+			results = append(results, zeroValue(t, qualifier))
+		}
+		err := c.err.Name
+		if !wrap || c.call == "" {
+			body = escape("return " + strings.Join(append(results, err), ", "))
+		} else {
+			fmtName := qualifier(types.NewPackage("fmt", "fmt"))
+			quoted := strconv.Quote(c.call)
+			message := escape(quoted[1 : len(quoted)-1])
+			if snippet {
+				message = "${1:" + message + "}"
+			}
+			body = escape("return "+strings.Join(append(results, fmtName+`.Errorf("`), ", ")) + message + escape(`: %w", `+err+")")
+		}
+	}
+	return escape("if "+c.err.Name+" != nil {\n"+indent+"\t") + body + escape("\n"+indent+"}")
+}
+
+// errorCheckActions returns the action which inserts the error check of the
+// assignment at rng.
+func (h *LangHandler) errorCheckActions(ctx context.Context, uri lsp.DocumentURI, rng lsp.Range) []protocol.CodeAction {
+	pkg, pos, err := h.typeCheck(ctx, uri, rng.Start)
+	if err != nil {
+		return nil
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil
+	}
+	file, ok := pathNodes[len(pathNodes)-1].(*ast.File)
+	if !ok {
+		return nil
+	}
+	for i, n := range pathNodes {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok {
+			continue
+		}
+		check := newErrorCheck(pkg.GetTypesInfo(), assign, pathNodes[i+1:])
+		if check == nil {
+			return nil
+		}
+
+		fset := pkg.GetFileSet()
+		imp := newFileImporter(pkg.GetPkgPath(), file)
+		indent := strings.Repeat("\t", fset.Position(assign.Pos()).Column-1)
+		edits := fileEdits{}
+		edits.replace(fset, assign.End(), assign.End(), "\n"+indent+check.text(imp.qualifier, h.config.WrapErrors, indent, false))
+		imp.addImports(edits, fset)
+		return []protocol.CodeAction{{
+			Title: "Add error check",
+			Kind:  protocol.RefactorRewrite,
+			Edit: lsp.WorkspaceEdit{
+				Changes: map[string][]lsp.TextEdit{string(uri): edits[fset.Position(file.Pos()).Filename]},
+			},
+		}}
+	}
+	return nil
+}
+
+// errorCheckCompletion returns the completion item which inserts the error
+// check of the assignment preceding the position, when prefix is a prefix of
+// its label. Completion items cannot import fmt, which is left to the client
+// organizing the imports.
+func (h *LangHandler) errorCheckCompletion(ctx context.Context, params lsp.CompletionParams, prefix string, snippets bool) []lsp.CompletionItem {
+	if !strings.HasPrefix(errorCheckLabel, prefix) {
+		return nil
+	}
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		return nil
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil
+	}
+	file, ok := pathNodes[len(pathNodes)-1].(*ast.File)
+	if !ok {
+		return nil
+	}
+	assign, parents := precedingAssign(pathNodes, pos)
+	check := newErrorCheck(pkg.GetTypesInfo(), assign, parents)
+	if check == nil {
+		return nil
+	}
+
+	format := lsp.ITFPlainText
+	if snippets {
+		format = lsp.ITFSnippet
+	}
+	imp := newFileImporter(pkg.GetPkgPath(), file)
+	indent := strings.Repeat("\t", pkg.GetFileSet().Position(assign.Pos()).Column-1)
+	text := check.text(imp.qualifier, h.config.WrapErrors, indent, snippets)
+	return []lsp.CompletionItem{{
+		Label:            errorCheckLabel,
+		Detail:           fmt.Sprintf("if %s != nil { ... }", check.err.Name),
+		Kind:             lsp.CIKSnippet,
+		TextEdit:         &lsp.TextEdit{NewText: text, Range: getLspRange(params.Position, len(prefix))},
+		InsertTextFormat: format,
+		InsertText:       text,
+	}}
+}
+
+// precedingAssign returns the assignment preceding pos in its block, and
+// the block and its parents.
+func precedingAssign(pathNodes []ast.Node, pos token.Pos) (*ast.AssignStmt, []ast.Node) {
+	for i, n := range pathNodes {
+		list, ok := blockStmts(n)
+		if !ok {
+			continue
+		}
+
+		// The statement being typed is not the preceding one.
+		var preceding ast.Stmt
+		for _, stmt := range list {
+			if stmt.End() >= pos {
+				break
+			}
+			preceding = stmt
+		}
+		assign, _ := preceding.(*ast.AssignStmt)
+		return assign, pathNodes[i:]
+	}
+	return nil, nil
+}
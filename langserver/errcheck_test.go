@@ -0,0 +1,70 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func TestErrorCheck(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	_, f, pkg, info := checkStubbedPackage(t, `package p
+
+import (
+	"os"
+	"testing"
+)
+
+type config struct{}
+
+func load(name string) (*config, int, config, error) {
+	f, err := os.Open(name)
+	_, _ = f, err
+	return nil, 0, config{}, nil
+}
+
+func checked() error {
+	_, err := os.Open("x")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func TestRemove(t *testing.T) {
+	err := os.Remove("x")
+	_ = err
+}
+`, nil)
+
+	check := func(fn string) *errorCheck {
+		for _, decl := range f.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && decl.Name.Name == fn {
+				second := decl.Body.List[1]
+				path, _ := astutil.PathEnclosingInterval(f, second.Pos(), second.Pos())
+				assign, parents := precedingAssign(path, second.Pos())
+				require.Equal(decl.Body.List[0], assign)
+				return newErrorCheck(info, assign, parents)
+			}
+		}
+		return nil
+	}
+	qualifier := types.RelativeTo(pkg)
+
+	c := check("load")
+	require.NotNil(c)
+	require.Equal("if err != nil {\n\t\treturn nil, 0, config{}, err\n\t}", c.text(qualifier, false, "\t", false))
+	require.Equal("if err != nil {\n\t\treturn nil, 0, config{}, fmt.Errorf(\"os.Open: %w\", err)\n\t}", c.text(qualifier, true, "\t", false))
+	require.Equal("if err != nil {\n\t\treturn nil, 0, config{\\}, fmt.Errorf(\"${1:os.Open}: %w\", err)\n\t\\}", c.text(qualifier, true, "\t", true))
+
+	require.Nil(check("checked"))
+
+	c = check("TestRemove")
+	require.NotNil(c)
+	require.Equal("if err != nil {\n\t\tt.Fatal(err)\n\t}", c.text(qualifier, true, "\t", false))
+}
@@ -0,0 +1,203 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ErrorCategory tells the clients what went wrong with a request, in the
+// data of the errors of the responses.
+type ErrorCategory string
+
+const (
+	// InvalidParamsError is a malformed request, e.g. an invalid rewrite
+	// rule.
+	InvalidParamsError ErrorCategory = "invalidParams"
+
+	// InvalidPositionError is a request whose position does not select
+	// what it applies to, e.g. bingo.mock on something else than an
+	// interface.
+	InvalidPositionError ErrorCategory = "invalidPosition"
+
+	// UnsupportedError is a method which is not supported or disabled.
+	UnsupportedError ErrorCategory = "unsupported"
+
+	// NotFoundError is an object or a package which does not exist.
+	NotFoundError ErrorCategory = "notFound"
+
+	// RefusedError is a valid request which cannot be carried out, e.g. a
+	// rename which conflicts with another declaration.
+	RefusedError ErrorCategory = "refused"
+
+	// PackageError is a package which could not be loaded or type-checked.
+	PackageError ErrorCategory = "package"
+
+	// StaleError is a request computed on documents which changed since,
+	// or on packages which are not loaded yet. It may be retried.
+	StaleError ErrorCategory = "stale"
+
+	// CanceledError is a request canceled by the client or superseded by a
+	// newer one. It may be retried.
+	CanceledError ErrorCategory = "canceled"
+
+	// RateLimitedError is a request rejected by the server because its
+	// client exceeds the rate of its method, see Config.MaxRequestsPerSecond.
+	// It may be retried.
+	RateLimitedError ErrorCategory = "rateLimited"
+
+	// NotInitializedError is a request received before initialize. It may
+	// be retried.
+	NotInitializedError ErrorCategory = "notInitialized"
+
+	// UnavailableError is a feature which is not available, e.g. while
+	// the server is shutting down or in offline mode.
+	UnavailableError ErrorCategory = "unavailable"
+
+	// PolicyError is a request refused by the policy of the server, e.g.
+	// on a file outside of the workspace, see confinement.
+	PolicyError ErrorCategory = "policy"
+
+	// InternalError is any other error, including the panics of the
+	// handlers.
+	InternalError ErrorCategory = "internal"
+)
+
+// The error codes of bingo, in the range of JSON-RPC reserved for the
+// implementation defined server errors. The other categories use the codes
+// of JSON-RPC and LSP.
+const (
+	codeServerNotInitialized = -32002
+	codeContentModified      = -32801
+	codeServerCancelled      = -32802
+	codeNotFound             = -32010
+	codePackageError         = -32011
+	codeRefused              = -32012
+	codeUnavailable          = -32013
+	codePolicy               = -32014
+)
+
+// errorCodes are the codes of the error categories.
+var errorCodes = map[ErrorCategory]int64{
+	InvalidParamsError:   jsonrpc2.CodeInvalidParams,
+	InvalidPositionError: jsonrpc2.CodeInvalidParams,
+	UnsupportedError:     jsonrpc2.CodeMethodNotFound,
+	NotFoundError:        codeNotFound,
+	RefusedError:         codeRefused,
+	PackageError:         codePackageError,
+	StaleError:           codeContentModified,
+	CanceledError:        codeRequestCancelled,
+	RateLimitedError:     codeServerCancelled,
+	NotInitializedError:  codeServerNotInitialized,
+	UnavailableError:     codeUnavailable,
+	PolicyError:          codePolicy,
+	InternalError:        jsonrpc2.CodeInternalError,
+}
+
+// ErrorData is the data of the errors of the responses.
+type ErrorData struct {
+	Category ErrorCategory `json:"category"`
+
+	// Retryable reports whether the same request may succeed later
+	// without any change of the client.
+	Retryable bool `json:"retryable"`
+
+	// Package is the path of the package involved, if any.
+	Package string `json:"package,omitempty"`
+
+	// Panic reports whether the handler of the request panicked, which is
+	// a bug of bingo. The category of the panics is internal.
+	Panic bool `json:"panic,omitempty"`
+}
+
+// requestError is an error of a request with its category.
+type requestError struct {
+	category ErrorCategory
+	pkgPath  string
+	panic    bool
+	err      error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+// requestErrorf returns an error of category.
+func requestErrorf(category ErrorCategory, format string, args ...interface{}) error {
+	return &requestError{category: category, err: fmt.Errorf(format, args...)}
+}
+
+// packageErrorf returns an error of category involving the package pkgPath.
+func packageErrorf(category ErrorCategory, pkgPath string, format string, args ...interface{}) error {
+	return &requestError{category: category, pkgPath: pkgPath, err: fmt.Errorf(format, args...)}
+}
+
+// panicError returns err, the error of a recovered panic, as an internal
+// error.
+func panicError(err error) error {
+	return &requestError{category: InternalError, panic: true, err: err}
+}
+
+// withCategory returns err as an error of category, unless it is nil, a
+// cancellation or already has a category.
+func withCategory(category ErrorCategory, err error) error {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+	switch err.(type) {
+	case *requestError, *jsonrpc2.Error:
+		return err
+	}
+	return &requestError{category: category, err: err}
+}
+
+// isRetryable reports whether the requests failing with category may be
+// retried as they are.
+func isRetryable(category ErrorCategory) bool {
+	return category == StaleError || category == CanceledError || category == RateLimitedError || category == NotInitializedError
+}
+
+// responseError returns err as the error of a response, with the code of its
+// category and an ErrorData. The errors without a category are internal.
+func responseError(err error) *jsonrpc2.Error {
+	data := ErrorData{Category: InternalError}
+	var rpcErr *jsonrpc2.Error
+	switch e := err.(type) {
+	case *jsonrpc2.Error:
+		if e.Data != nil {
+			return e
+		}
+		switch e.Code {
+		case jsonrpc2.CodeParseError, jsonrpc2.CodeInvalidRequest, jsonrpc2.CodeInvalidParams:
+			data.Category = InvalidParamsError
+		case jsonrpc2.CodeMethodNotFound:
+			data.Category = UnsupportedError
+		case codeRequestCancelled:
+			data.Category = CanceledError
+		}
+		rpcErr = &jsonrpc2.Error{Code: e.Code, Message: e.Message}
+
+	case *requestError:
+		data.Category, data.Package, data.Panic = e.category, e.pkgPath, e.panic
+		rpcErr = &jsonrpc2.Error{Code: errorCodes[e.category], Message: e.Error()}
+
+	default:
+		switch err.(type) {
+		case *json.SyntaxError, *json.UnmarshalTypeError:
+			// The params of the request do not decode.
+			data.Category = InvalidParamsError
+		}
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			data.Category = CanceledError
+		}
+		rpcErr = &jsonrpc2.Error{Code: errorCodes[data.Category], Message: err.Error()}
+	}
+	if rpcErr.Message == "" {
+		rpcErr.Message = string(data.Category)
+	}
+	data.Retryable = isRetryable(data.Category)
+	rpcErr.SetError(data)
+	return rpcErr
+}
@@ -0,0 +1,59 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseError(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	data := func(err *jsonrpc2.Error) ErrorData {
+		var data ErrorData
+		require.NotNil(err.Data)
+		require.NoError(json.Unmarshal(*err.Data, &data))
+		return data
+	}
+
+	err := responseError(packageErrorf(StaleError, "example.com/p", "%s has changed", "p.go"))
+	require.Equal(int64(codeContentModified), err.Code)
+	require.Equal("p.go has changed", err.Message)
+	require.Equal(ErrorData{Category: StaleError, Retryable: true, Package: "example.com/p"}, data(err))
+
+	err = responseError(errors.New("boom"))
+	require.Equal(int64(jsonrpc2.CodeInternalError), err.Code)
+	require.Equal(ErrorData{Category: InternalError}, data(err))
+
+	var params struct{ Line int }
+	err = responseError(json.Unmarshal([]byte(`{"line":"1"}`), &params))
+	require.Equal(int64(jsonrpc2.CodeInvalidParams), err.Code)
+	require.Equal(ErrorData{Category: InvalidParamsError}, data(err))
+
+	err = responseError(context.Canceled)
+	require.Equal(int64(codeRequestCancelled), err.Code)
+	require.Equal(ErrorData{Category: CanceledError, Retryable: true}, data(err))
+
+	err = responseError(panicError(errors.New("unexpected panic: nil map")))
+	require.Equal(int64(jsonrpc2.CodeInternalError), err.Code)
+	require.Equal(ErrorData{Category: InternalError, Panic: true}, data(err))
+
+	err = responseError(&jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams})
+	require.Equal(int64(jsonrpc2.CodeInvalidParams), err.Code)
+	require.Equal("invalidParams", err.Message)
+	require.Equal(ErrorData{Category: InvalidParamsError}, data(err))
+
+	// A categorized error keeps its category, and the renames which are not
+	// possible are still shown to the user.
+	err = renameError(requestErrorf(InvalidPositionError, "no identifier")).(*jsonrpc2.Error)
+	require.Equal(int64(jsonrpc2.CodeInvalidParams), err.Code)
+	require.Equal(InvalidPositionError, data(err).Category)
+	err = renameError(errors.New("conflict")).(*jsonrpc2.Error)
+	require.Equal(RefusedError, data(err).Category)
+	require.Equal(err, responseError(err), "the data of the errors is not replaced")
+}
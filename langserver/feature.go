@@ -0,0 +1,143 @@
+package langserver
+
+// Feature names which can be listed in Config.DisabledFeatures.
+const (
+	hoverFeature                   = "hover"
+	definitionFeature              = "definition"
+	typeDefinitionFeature          = "typeDefinition"
+	xdefinitionFeature             = "xdefinition"
+	completionFeature              = "completion"
+	referencesFeature              = "references"
+	documentHighlightFeature       = "documentHighlight"
+	implementationFeature          = "implementation"
+	callHierarchyFeature           = "callHierarchy"
+	documentSymbolFeature          = "documentSymbol"
+	signatureHelpFeature           = "signatureHelp"
+	documentFormattingFeature      = "documentFormatting"
+	documentRangeFormattingFeature = "documentRangeFormatting"
+	workspaceSymbolFeature         = "workspaceSymbol"
+	workspaceReferencesFeature     = "workspaceReferences"
+	renameFeature                  = "rename"
+	codeActionFeature              = "codeAction"
+	diagnosticsFeature             = "diagnostics"
+	metricsFeature                 = "metrics"
+	documentColorFeature           = "documentColor"
+	foldingRangeFeature            = "foldingRange"
+	codeLensFeature                = "codeLens"
+	executeCommandFeature          = "executeCommand"
+	packageDocFeature              = "packageDoc"
+	coverageFeature                = "coverage"
+	inlineCompletionFeature        = "inlineCompletion"
+)
+
+// methodFeatures maps an LSP request method to the feature which serves it.
+var methodFeatures = map[string]string{
+	"textDocument/hover":                hoverFeature,
+	"textDocument/definition":           definitionFeature,
+	"textDocument/typeDefinition":       typeDefinitionFeature,
+	"textDocument/xdefinition":          xdefinitionFeature,
+	"textDocument/completion":           completionFeature,
+	"textDocument/references":           referencesFeature,
+	"textDocument/documentHighlight":    documentHighlightFeature,
+	"textDocument/implementation":       implementationFeature,
+	"textDocument/prepareCallHierarchy": callHierarchyFeature,
+	"callHierarchy/incomingCalls":       callHierarchyFeature,
+	"callHierarchy/outgoingCalls":       callHierarchyFeature,
+	"textDocument/documentSymbol":       documentSymbolFeature,
+	"textDocument/signatureHelp":        signatureHelpFeature,
+	"textDocument/formatting":           documentFormattingFeature,
+	"textDocument/rangeFormatting":      documentRangeFormattingFeature,
+	"workspace/symbol":                  workspaceSymbolFeature,
+	"workspace/xreferences":             workspaceReferencesFeature,
+	"textDocument/rename":               renameFeature,
+	"textDocument/prepareRename":        renameFeature,
+	"textDocument/codeAction":           codeActionFeature,
+	"bingo/metrics":                     metricsFeature,
+	"textDocument/documentColor":        documentColorFeature,
+	"textDocument/colorPresentation":    documentColorFeature,
+	"textDocument/foldingRange":         foldingRangeFeature,
+	"textDocument/codeLens":             codeLensFeature,
+	"codeLens/resolve":                  codeLensFeature,
+	"workspace/executeCommand":          executeCommandFeature,
+	"bingo/packageDoc":                  packageDocFeature,
+	"bingo/coverage":                    coverageFeature,
+	"textDocument/diagnostic":           diagnosticsFeature,
+	"workspace/diagnostic":              diagnosticsFeature,
+	"textDocument/inlineCompletion":     inlineCompletionFeature,
+}
+
+// featureEnabled reports whether feature has not been disabled by the user.
+func (c *Config) featureEnabled(feature string) bool {
+	for _, f := range c.DisabledFeatures {
+		if f == feature {
+			return false
+		}
+	}
+	return true
+}
+
+// methodEnabled reports whether the feature serving method has not been
+// disabled. Methods which do not belong to a feature are always enabled.
+func (c *Config) methodEnabled(method string) bool {
+	feature, ok := methodFeatures[method]
+	if !ok {
+		return true
+	}
+	return c.featureEnabled(feature)
+}
+
+// disableCapabilities removes every disabled feature from caps, so that the
+// client does not send requests we are not going to serve.
+func (c *Config) disableCapabilities(caps *ServerCapabilities) {
+	for _, feature := range c.DisabledFeatures {
+		switch feature {
+		case hoverFeature:
+			caps.HoverProvider = false
+		case definitionFeature:
+			caps.DefinitionProvider = false
+		case typeDefinitionFeature:
+			caps.TypeDefinitionProvider = false
+		case xdefinitionFeature:
+			caps.XDefinitionProvider = false
+		case completionFeature:
+			caps.CompletionProvider = nil
+		case referencesFeature:
+			caps.ReferencesProvider = false
+		case documentHighlightFeature:
+			caps.DocumentHighlightProvider = false
+		case implementationFeature:
+			caps.ImplementationProvider = false
+		case callHierarchyFeature:
+			caps.CallHierarchyProvider = false
+		case documentSymbolFeature:
+			caps.DocumentSymbolProvider = false
+		case signatureHelpFeature:
+			caps.SignatureHelpProvider = nil
+		case documentFormattingFeature:
+			caps.DocumentFormattingProvider = false
+		case documentRangeFormattingFeature:
+			caps.DocumentRangeFormattingProvider = false
+		case workspaceSymbolFeature:
+			caps.WorkspaceSymbolProvider = false
+			caps.XWorkspaceSymbolByProperties = false
+		case workspaceReferencesFeature:
+			caps.XWorkspaceReferencesProvider = false
+		case renameFeature:
+			caps.RenameProvider = false
+		case codeActionFeature:
+			caps.CodeActionProvider = false
+		case documentColorFeature:
+			caps.ColorProvider = false
+		case foldingRangeFeature:
+			caps.FoldingRangeProvider = false
+		case codeLensFeature:
+			caps.CodeLensProvider = nil
+		case executeCommandFeature:
+			caps.ExecuteCommandProvider = nil
+		case diagnosticsFeature:
+			caps.DiagnosticProvider = nil
+		case inlineCompletionFeature:
+			caps.InlineCompletionProvider = false
+		}
+	}
+}
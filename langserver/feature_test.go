@@ -0,0 +1,64 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisabledFeatures(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cfg := NewDefaultConfig()
+	cfg.DisabledFeatures = []string{documentFormattingFeature, workspaceSymbolFeature, diagnosticsFeature}
+
+	require.False(cfg.methodEnabled("textDocument/formatting"))
+	require.False(cfg.methodEnabled("workspace/symbol"))
+	require.True(cfg.methodEnabled("textDocument/rangeFormatting"))
+	require.True(cfg.methodEnabled("textDocument/hover"))
+	require.True(cfg.methodEnabled("textDocument/didOpen"))
+	require.False(cfg.featureEnabled(diagnosticsFeature))
+
+	caps := ServerCapabilities{
+		ServerCapabilities: lsp.ServerCapabilities{
+			HoverProvider:                true,
+			DocumentFormattingProvider:   true,
+			WorkspaceSymbolProvider:      true,
+			XWorkspaceSymbolByProperties: true,
+		},
+	}
+	cfg.disableCapabilities(&caps)
+	require.True(caps.HoverProvider)
+	require.False(caps.DocumentFormattingProvider)
+	require.False(caps.WorkspaceSymbolProvider)
+	require.False(caps.XWorkspaceSymbolByProperties)
+}
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cfg := NewDefaultConfig()
+	cfg.ReadOnly = true
+	cfg.FixOnSave = allFixOnSave
+	cfg.RunCodeLens = true
+	cfg.ReferencesCodeLens = true
+	cfg.CommandAllowlist = []string{runCommand}
+	cfg = cfg.Apply(&InitializationOptions{}).readOnly()
+
+	require.False(cfg.methodEnabled("textDocument/formatting"))
+	require.False(cfg.methodEnabled("textDocument/rename"))
+	require.False(cfg.methodEnabled("textDocument/codeAction"))
+	require.False(cfg.methodEnabled("bingo/coverage"))
+	require.True(cfg.methodEnabled("textDocument/hover"))
+	require.True(cfg.methodEnabled("workspace/executeCommand"))
+	require.Equal(noFixOnSave, cfg.FixOnSave)
+	require.False(cfg.RunCodeLens)
+	require.True(cfg.ReferencesCodeLens)
+	require.Empty(cfg.CommandAllowlist)
+
+	require.Equal([]string{statusCommand, importersCommand}, cfg.availableCommands([]string{statusCommand, runCommand, rewriteCommand, importersCommand}))
+	require.False(cfg.commandAvailable(applyEditCommand))
+}
@@ -0,0 +1,159 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The workspace/executeCommand commands which push the fixes of a document
+// with workspace/applyEdit.
+const (
+	fixImportsCommand = "bingo.fixImports"
+	fixAllCommand     = "bingo.fixAll"
+)
+
+// The fixes applied when a document is saved, see Config.FixOnSave.
+const (
+	noFixOnSave      = "none"
+	importsFixOnSave = "imports"
+	allFixOnSave     = "all"
+)
+
+// fixAction is the action of the confirmation of the fixes of a saved
+// document.
+const fixAction = "Apply"
+
+// FixParams are the arguments of the bingo.fixImports and bingo.fixAll
+// commands.
+type FixParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// handleFix applies the fixes of a document, its imports only unless all is
+// set. It returns the applied edit, which is nil if there is nothing to fix.
+func (h *LangHandler) handleFix(ctx context.Context, conn jsonrpc2.JSONRPC2, params FixParams, all bool) (*protocol.WorkspaceEdit, error) {
+	edit, label, err := h.fixEdit(ctx, params.TextDocument.URI, all)
+	if err != nil || edit == nil {
+		return nil, err
+	}
+	if err := applyEdit(ctx, conn, label, edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// fixOnSave pushes the fixes of Config.FixOnSave of the document saved by
+// req once the user confirms them. They are computed in the background,
+// since the notifications of the documents are handled in order.
+func (h *LangHandler) fixOnSave(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) {
+	style := h.config.FixOnSave
+	if style != importsFixOnSave && style != allFixOnSave {
+		return
+	}
+	var params lsp.DidSaveTextDocumentParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return
+	}
+
+	go func() {
+		edit, label, err := h.fixEdit(ctx, params.TextDocument.URI, style == allFixOnSave)
+		if err != nil || edit == nil {
+			return
+		}
+		var action *protocol.MessageActionItem
+		err = conn.Call(ctx, "window/showMessageRequest", &protocol.ShowMessageRequestParams{
+			Type:    lsp.Info,
+			Message: strings.ToUpper(label[:1]) + label[1:] + "?",
+			Actions: []protocol.MessageActionItem{{Title: fixAction}, {Title: "Skip"}},
+		}, &action)
+		if err != nil || action == nil || action.Title != fixAction {
+			return
+		}
+		if err := applyEdit(ctx, conn, label, edit); err != nil {
+			h.notifyError(err.Error())
+		}
+	}()
+}
+
+// fixEdit returns the edit which adds the missing imports of the document uri
+// and removes its unused ones, and also removes its unused variables if all is
+// set, with its label. These fixes do not change what the code does. The
+// edit is nil if there is nothing to fix.
+func (h *LangHandler) fixEdit(ctx context.Context, uri lsp.DocumentURI, all bool) (*protocol.WorkspaceEdit, string, error) {
+	if err := checkFileURI(uri); err != nil {
+		return nil, "", err
+	}
+	edits, err := organizeImports(ctx, h.View(), uri)
+	if err != nil {
+		return nil, "", err
+	}
+	label := "fix the imports of " + path.Base(string(uri))
+	if all {
+		label = "apply the safe fixes of " + path.Base(string(uri))
+		edits = mergeEdits(edits, h.unusedVariableFixes(ctx, uri)...)
+	}
+	if len(edits) == 0 {
+		return nil, label, nil
+	}
+
+	// The client rejects the edit if the document has changed since.
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{string(uri): edits}}
+	if version := h.overlay.version(uri); version != 0 && h.init != nil && h.init.Capabilities.Workspace.WorkspaceEdit.DocumentChanges {
+		edit = &protocol.WorkspaceEdit{DocumentChanges: []interface{}{protocol.TextDocumentEdit{
+			TextDocument: lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: version},
+			Edits:        edits,
+		}}}
+	}
+	return edit, label, nil
+}
+
+// unusedVariableFixes returns the edits of the quick fixes of the unused
+// variables of the document uri.
+func (h *LangHandler) unusedVariableFixes(ctx context.Context, uri lsp.DocumentURI) [][]lsp.TextEdit {
+	f, err := h.View().GetFile(ctx, span.FromDocumentURI(uri))
+	if err != nil {
+		return nil
+	}
+	reports, err := diagnostics(ctx, f)
+	if err != nil {
+		return nil
+	}
+	filename, err := span.FromDocumentURI(uri).Filename()
+	if err != nil {
+		return nil
+	}
+	var fixes [][]lsp.TextEdit
+	for _, action := range h.unusedVariableActions(ctx, uri, reports[filename]) {
+		fixes = append(fixes, action.Edit.Changes[string(uri)])
+	}
+	return fixes
+}
+
+// mergeEdits adds to edits the sets of edits of fixes which do not overlap
+// them, nor the sets added before. The sets which overlap are left for the
+// next fix, once the others are applied.
+func mergeEdits(edits []lsp.TextEdit, fixes ...[]lsp.TextEdit) []lsp.TextEdit {
+	overlaps := func(fix []lsp.TextEdit) bool {
+		for _, a := range fix {
+			for _, b := range edits {
+				if !positionLess(a.Range.End, b.Range.Start) && !positionLess(b.Range.End, a.Range.Start) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, fix := range fixes {
+		if !overlaps(fix) {
+			edits = append(edits, fix...)
+		}
+	}
+	return edits
+}
@@ -0,0 +1,28 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeEdits(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	edit := func(line, start, end int, text string) lsp.TextEdit {
+		return lsp.TextEdit{
+			Range:   lsp.Range{Start: lsp.Position{Line: line, Character: start}, End: lsp.Position{Line: line, Character: end}},
+			NewText: text,
+		}
+	}
+	imports := []lsp.TextEdit{edit(2, 0, 12, `import "os"`)}
+	merged := mergeEdits(imports,
+		[]lsp.TextEdit{edit(6, 1, 7, "_")},
+		[]lsp.TextEdit{edit(2, 7, 11, `"io"`)},
+		[]lsp.TextEdit{edit(6, 4, 8, "="), edit(8, 1, 2, "_")},
+		[]lsp.TextEdit{edit(8, 1, 9, "")},
+	)
+	require.Equal([]lsp.TextEdit{imports[0], edit(6, 1, 7, "_"), edit(8, 1, 9, "")}, merged)
+}
@@ -0,0 +1,81 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *LangHandler) handleFoldingRange(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.FoldingRangeParams) ([]protocol.FoldingRange, error) {
+	pkg, fAST, err := h.loadPackageAndAst(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	lineFoldingOnly := h.init != nil && h.init.Capabilities.TextDocument.FoldingRange.LineFoldingOnly
+	return foldingRanges(pkg.GetFileSet(), fAST, lineFoldingOnly), nil
+}
+
+// foldingRanges returns the folding ranges of the blocks of f, the bodies of
+// its struct and interface types and of its composite literals, its
+// parenthesized declarations and its comments spanning several lines. The
+// delimiters of a block stay visible once it is folded: if lineFoldingOnly is
+// set, the client folds whole lines, so the line of the closing delimiter is
+// not folded.
+func foldingRanges(fset *token.FileSet, f *ast.File, lineFoldingOnly bool) []protocol.FoldingRange {
+	ranges := []protocol.FoldingRange{}
+	addBlock := func(open, close token.Pos, kind protocol.FoldingRangeKind) {
+		if !open.IsValid() || !close.IsValid() {
+			return
+		}
+		start, end := fset.Position(open), fset.Position(close)
+		r := protocol.FoldingRange{StartLine: start.Line - 1, EndLine: end.Line - 1, Kind: kind}
+		if lineFoldingOnly {
+			r.EndLine--
+		} else {
+			// The range is between the delimiters, which are one byte long.
+			startCharacter, endCharacter := start.Column, end.Column-1
+			r.StartCharacter, r.EndCharacter = &startCharacter, &endCharacter
+		}
+		if r.EndLine > r.StartLine {
+			ranges = append(ranges, r)
+		}
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GenDecl:
+			kind := protocol.FoldingRangeKind("")
+			if n.Tok == token.IMPORT {
+				kind = protocol.Imports
+			}
+			addBlock(n.Lparen, n.Rparen, kind)
+		case *ast.BlockStmt:
+			addBlock(n.Lbrace, n.Rbrace, "")
+		case *ast.StructType:
+			addBlock(n.Fields.Opening, n.Fields.Closing, "")
+		case *ast.InterfaceType:
+			addBlock(n.Methods.Opening, n.Methods.Closing, "")
+		case *ast.CompositeLit:
+			addBlock(n.Lbrace, n.Rbrace, "")
+		}
+		return true
+	})
+
+	// The comments are folded up to the end of their last line.
+	for _, c := range f.Comments {
+		start, end := fset.Position(c.Pos()), fset.Position(c.End())
+		if end.Line > start.Line {
+			ranges = append(ranges, protocol.FoldingRange{StartLine: start.Line - 1, EndLine: end.Line - 1, Kind: protocol.Comment})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].StartLine < ranges[j].StartLine
+	})
+	return ranges
+}
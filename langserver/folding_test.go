@@ -0,0 +1,81 @@
+package langserver
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+const foldingSrc = `package p
+
+import (
+	"fmt"
+	"os"
+)
+
+// T is
+// folded.
+type T struct {
+	A int
+	B int
+}
+
+func f() {
+	x := []int{
+		1,
+	}
+	fmt.Println(x, os.Args)
+}
+
+func g() {}
+`
+
+func TestFoldingRanges(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", foldingSrc, parser.ParseComments)
+	require.NoError(err)
+
+	type lines struct {
+		start, end int
+		kind       protocol.FoldingRangeKind
+	}
+	linesOf := func(ranges []protocol.FoldingRange) []lines {
+		var got []lines
+		for _, r := range ranges {
+			got = append(got, lines{r.StartLine, r.EndLine, r.Kind})
+		}
+		return got
+	}
+
+	ranges := foldingRanges(fset, f, true)
+	require.Equal([]lines{
+		{2, 4, protocol.Imports},
+		{7, 8, protocol.Comment},
+		{9, 11, ""},
+		{14, 18, ""},
+		{15, 16, ""},
+	}, linesOf(ranges))
+	for _, r := range ranges {
+		require.Nil(r.StartCharacter)
+		require.Nil(r.EndCharacter)
+	}
+
+	ranges = foldingRanges(fset, f, false)
+	require.Equal([]lines{
+		{2, 5, protocol.Imports},
+		{7, 8, protocol.Comment},
+		{9, 12, ""},
+		{14, 19, ""},
+		{15, 17, ""},
+	}, linesOf(ranges))
+	// The range of the body of f starts after its opening brace and ends
+	// before its closing brace.
+	require.Equal(10, *ranges[3].StartCharacter)
+	require.Equal(0, *ranges[3].EndCharacter)
+}
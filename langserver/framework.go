@@ -0,0 +1,396 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// missingProviderCode is the code of the diagnostics of the providers whose
+// dependencies are not provided.
+const missingProviderCode = "missingProvider"
+
+// framework is the support of a dependency injection framework, which
+// registers providers with calls like wire.NewSet or fx.Provide.
+type framework interface {
+	// name is the name of the framework in Config.Frameworks.
+	name() string
+
+	// registrations returns the providers registered by the calls of pkg.
+	registrations(pkg *frameworkPackage) []registration
+
+	// check returns the errors of the provider sets of pkg.
+	check(pkg *frameworkPackage) []frameworkError
+}
+
+// frameworks are the supported frameworks, by name.
+var frameworks = map[string]framework{}
+
+func registerFramework(f framework) {
+	frameworks[f.name()] = f
+}
+
+// newFrameworks returns the supported frameworks of names.
+func newFrameworks(names []string) []framework {
+	var enabled []framework
+	for _, name := range names {
+		if f, ok := frameworks[name]; ok {
+			enabled = append(enabled, f)
+		}
+	}
+	return enabled
+}
+
+// frameworkPackage is the type checked syntax of a package analyzed by the
+// frameworks.
+type frameworkPackage struct {
+	fset  *token.FileSet
+	files []*ast.File
+	types *types.Package
+	info  *types.Info
+
+	// imported returns the package of an import path of the package, or
+	// nil if it is not loaded.
+	imported func(path string) *frameworkPackage
+}
+
+func newFrameworkPackage(pkg source.Package) *frameworkPackage {
+	return &frameworkPackage{
+		fset:  pkg.GetFileSet(),
+		files: pkg.GetSyntax(),
+		types: pkg.GetTypes(),
+		info:  pkg.GetTypesInfo(),
+		imported: func(path string) *frameworkPackage {
+			imp := pkg.GetImport(path)
+			if imp == nil || imp.GetTypesInfo() == nil {
+				return nil
+			}
+			return newFrameworkPackage(imp)
+		},
+	}
+}
+
+// declaring returns the package which declares obj, if it is loaded.
+func (pkg *frameworkPackage) declaring(obj types.Object) *frameworkPackage {
+	if obj.Pkg() == nil {
+		return nil
+	}
+	if obj.Pkg() == pkg.types {
+		return pkg
+	}
+	return pkg.imported(obj.Pkg().Path())
+}
+
+// registration is a provider registered by a call.
+type registration struct {
+	call *ast.CallExpr
+
+	// arg is the argument of call which names the provider, and obj is the
+	// function or the provider set it refers to.
+	arg ast.Expr
+	obj types.Object
+}
+
+// frameworkError is an error of a provider set.
+type frameworkError struct {
+	node    ast.Node
+	message string
+}
+
+// calledFunc returns the package path and the name of the function called
+// by call, if it is a package level function.
+func calledFunc(info *types.Info, call *ast.CallExpr) (string, string) {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return "", ""
+	}
+	fn, ok := info.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Type().(*types.Signature).Recv() != nil {
+		return "", ""
+	}
+	return fn.Pkg().Path(), fn.Name()
+}
+
+// referencedObject returns the object named by expr, an identifier or a
+// qualified identifier.
+func referencedObject(info *types.Info, expr ast.Expr) types.Object {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return info.Uses[expr]
+	case *ast.SelectorExpr:
+		return info.Uses[expr.Sel]
+	case *ast.ParenExpr:
+		return referencedObject(info, expr.X)
+	}
+	return nil
+}
+
+// varInitializer returns the expression which initializes the package level
+// variable v of pkg.
+func varInitializer(pkg *frameworkPackage, v *types.Var) ast.Expr {
+	for _, f := range pkg.files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.ValueSpec)
+				for i, name := range spec.Names {
+					if pkg.info.Defs[name] == v && i < len(spec.Values) && len(spec.Names) == len(spec.Values) {
+						return spec.Values[i]
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// callsOf returns the calls of files to the functions names of the package
+// path.
+func callsOf(pkg *frameworkPackage, path string, names ...string) []*ast.CallExpr {
+	var calls []*ast.CallExpr
+	for _, f := range pkg.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			p, name := calledFunc(pkg.info, call)
+			if p != path {
+				return true
+			}
+			for _, n := range names {
+				if name == n {
+					calls = append(calls, call)
+					break
+				}
+			}
+			return true
+		})
+	}
+	return calls
+}
+
+// enclosingFunc returns the function declaration of pkg which contains node.
+func enclosingFunc(pkg *frameworkPackage, node ast.Node) *ast.FuncDecl {
+	for _, f := range pkg.files {
+		if node.Pos() < f.Pos() || node.Pos() > f.End() {
+			continue
+		}
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Pos() <= node.Pos() && node.End() <= fn.End() {
+				return fn
+			}
+		}
+	}
+	return nil
+}
+
+// provider is a constructor of a provider set.
+type provider struct {
+	name     string
+	node     ast.Node
+	needs    []types.Type
+	provides []types.Type
+}
+
+// providerSet is the flattened content of provider sets.
+type providerSet struct {
+	root      *frameworkPackage
+	providers []provider
+
+	// expanded are the provider set variables already expanded.
+	expanded map[string]bool
+
+	// unsupported is set if the set has a provider which is not understood,
+	// and so cannot be checked.
+	unsupported bool
+}
+
+func newProviderSet(root *frameworkPackage) *providerSet {
+	return &providerSet{root: root, expanded: map[string]bool{}}
+}
+
+// add adds a provider named by arg. The errors of the providers of
+// other packages are reported on report, the argument of the root package
+// which refers to them.
+func (s *providerSet) add(arg ast.Expr, report ast.Node, needs, provides []types.Type) {
+	if report == nil {
+		report = arg
+	}
+	s.providers = append(s.providers, provider{
+		name:     types.ExprString(arg),
+		node:     report,
+		needs:    needs,
+		provides: provides,
+	})
+}
+
+// expandVar calls expand with the initializer of the provider set variable v
+// named by arg of pkg.
+func (s *providerSet) expandVar(pkg *frameworkPackage, v *types.Var, arg ast.Expr, report ast.Node, expand func(pkg *frameworkPackage, expr ast.Expr, report ast.Node)) {
+	key := objectKey(v)
+	if s.expanded[key] {
+		return
+	}
+	s.expanded[key] = true
+
+	declPkg := pkg.declaring(v)
+	if declPkg == nil {
+		s.unsupported = true
+		return
+	}
+	init := varInitializer(declPkg, v)
+	if init == nil {
+		s.unsupported = true
+		return
+	}
+	if declPkg != s.root && report == nil {
+		report = arg
+	}
+	expand(declPkg, init, report)
+}
+
+// missing returns the errors of the providers which need a type which is
+// neither given nor provided by the set, nor builtin.
+func (s *providerSet) missing(given []types.Type, builtin func(types.Type) bool) []frameworkError {
+	provided := map[string]bool{}
+	for _, t := range given {
+		provided[typeKey(t)] = true
+	}
+	for _, p := range s.providers {
+		for _, t := range p.provides {
+			provided[typeKey(t)] = true
+		}
+	}
+
+	var errs []frameworkError
+	for _, p := range s.providers {
+		for _, t := range p.needs {
+			if provided[typeKey(t)] || builtin != nil && builtin(t) {
+				continue
+			}
+			errs = append(errs, frameworkError{
+				node:    p.node,
+				message: fmt.Sprintf("no provider of %s, needed by %s", types.TypeString(t, types.RelativeTo(s.root.types)), p.name),
+			})
+		}
+	}
+	return errs
+}
+
+// typeKey identifies a type across the type checked packages, which do not
+// share their objects.
+func typeKey(t types.Type) string {
+	return types.TypeString(t, nil)
+}
+
+// objectKey identifies a package level object across the type checked
+// packages.
+func objectKey(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// frameworkDiagnostics returns the diagnostics of the provider sets of pkg,
+// by filename.
+func frameworkDiagnostics(fws []framework, pkg source.Package) map[string][]lsp.Diagnostic {
+	if len(fws) == 0 || pkg.IsIllTyped() || pkg.GetTypesInfo() == nil {
+		return nil
+	}
+
+	fpkg := newFrameworkPackage(pkg)
+	reports := map[string][]lsp.Diagnostic{}
+	for _, fw := range fws {
+		for _, err := range fw.check(fpkg) {
+			rng := rangeForNode(fpkg.fset, err.node)
+			filename := fpkg.fset.Position(err.node.Pos()).Filename
+			reports[filename] = append(reports[filename], lsp.Diagnostic{
+				Range:    rng,
+				Severity: lsp.Warning,
+				Code:     missingProviderCode,
+				Source:   fw.name(),
+				Message:  err.message,
+			})
+		}
+	}
+	return reports
+}
+
+// handleProviderUsages returns the locations where the provider function or
+// provider set at position is registered in the workspace.
+func (h *LangHandler) handleProviderUsages(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]lsp.Location, error) {
+	locs := []lsp.Location{}
+	fws := newFrameworks(h.config.Frameworks)
+	if len(fws) == 0 {
+		return locs, nil
+	}
+
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		return nil, err
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, err
+	}
+	ident, ok := pathNodes[0].(*ast.Ident)
+	if !ok {
+		return nil, source.NewInvalidNodeError(pkg.GetFileSet(), pathNodes[0])
+	}
+	obj := pkg.GetTypesInfo().ObjectOf(ident)
+	switch obj.(type) {
+	case *types.Func, *types.Var:
+	default:
+		return nil, requestErrorf(InvalidPositionError, "not a provider function or a provider set")
+	}
+	key := objectKey(obj)
+
+	err = h.project.Search(func(p source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if p.IsIllTyped() || p.GetTypesInfo() == nil {
+			return nil
+		}
+		fpkg := newFrameworkPackage(p)
+		for _, fw := range fws {
+			for _, reg := range fw.registrations(fpkg) {
+				if reg.obj != nil && objectKey(reg.obj) == key {
+					locs = append(locs, lsp.Location{
+						URI:   lsp.DocumentURI(source.ToURI(fpkg.fset.Position(reg.arg.Pos()).Filename)),
+						Range: rangeForNode(fpkg.fset, reg.arg),
+					})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].URI != locs[j].URI {
+			return locs[i].URI < locs[j].URI
+		}
+		return locs[i].Range.Start.Line < locs[j].Range.Start.Line
+	})
+	return locs, nil
+}
@@ -0,0 +1,175 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// frameworkStubs are the declarations of the frameworks used by the tests.
+var frameworkStubs = map[string]string{
+	wirePath: `package wire
+
+type ProviderSet struct{}
+
+func NewSet(...interface{}) ProviderSet { return ProviderSet{} }
+func Build(...interface{}) string { return "" }
+func Bind(iface, to interface{}) struct{} { return struct{}{} }
+func Value(interface{}) struct{} { return struct{}{} }
+`,
+	fxPath: `package fx
+
+type Option interface{}
+type Lifecycle interface{}
+type In struct{}
+type App struct{}
+
+func New(...Option) *App { return nil }
+func Options(...Option) Option { return nil }
+func Provide(...interface{}) Option { return nil }
+func Invoke(...interface{}) Option { return nil }
+`,
+}
+
+type stubImporter struct {
+	fset  *token.FileSet
+	stubs map[string]string
+	pkgs  map[string]*types.Package
+}
+
+func (imp *stubImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := imp.pkgs[path]; ok {
+		return pkg, nil
+	}
+	src, ok := imp.stubs[path]
+	if !ok {
+		// The stubs and the package must share the standard packages.
+		pkg, err := importer.Default().Import(path)
+		imp.pkgs[path] = pkg
+		return pkg, err
+	}
+	f, err := parser.ParseFile(imp.fset, path+".go", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := (&types.Config{Importer: imp}).Check(path, imp.fset, []*ast.File{f}, nil)
+	imp.pkgs[path] = pkg
+	return pkg, err
+}
+
+// checkStubbedPackage type checks src, whose imports of stubs are stubbed.
+func checkStubbedPackage(t *testing.T, src string, stubs map[string]string) (*token.FileSet, *ast.File, *types.Package, *types.Info) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	require.NoError(t, err)
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	conf := types.Config{Importer: &stubImporter{fset: fset, stubs: stubs, pkgs: map[string]*types.Package{}}}
+	pkg, err := conf.Check("example.com/p", fset, []*ast.File{f}, info)
+	require.NoError(t, err)
+	return fset, f, pkg, info
+}
+
+func checkFrameworkPackage(t *testing.T, src string) *frameworkPackage {
+	fset, f, pkg, info := checkStubbedPackage(t, src, frameworkStubs)
+	return &frameworkPackage{
+		fset:     fset,
+		files:    []*ast.File{f},
+		types:    pkg,
+		info:     info,
+		imported: func(string) *frameworkPackage { return nil },
+	}
+}
+
+func frameworkMessages(errs []frameworkError) []string {
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.message)
+	}
+	sort.Strings(messages)
+	return messages
+}
+
+func TestWireCheck(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	pkg := checkFrameworkPackage(t, `package p
+
+import "github.com/google/wire"
+
+type Config struct{}
+type DB struct{}
+type Cache struct{}
+type Store interface{}
+type Server struct{}
+
+func NewDB(Config) *DB { return nil }
+func NewServer(Store, *Cache) (*Server, error) { return nil, nil }
+
+var StoreSet = wire.NewSet(NewDB, wire.Bind(new(Store), new(*DB)))
+
+func InitServer(cfg Config) (*Server, error) {
+	panic(wire.Build(StoreSet, NewServer))
+}
+
+func InitDB() *DB {
+	panic(wire.Build(NewDB))
+}
+`)
+	fw := wireFramework{}
+	require.Equal([]string{
+		"no provider of *Cache, needed by NewServer",
+		"no provider of Config, needed by NewDB",
+	}, frameworkMessages(fw.check(pkg)))
+
+	var names []string
+	for _, reg := range fw.registrations(pkg) {
+		names = append(names, objectKey(reg.obj))
+	}
+	require.Equal([]string{"example.com/p.NewDB", "example.com/p.StoreSet", "example.com/p.NewServer", "example.com/p.NewDB"}, names)
+}
+
+func TestFxCheck(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	pkg := checkFrameworkPackage(t, `package p
+
+import "go.uber.org/fx"
+
+type Config struct{}
+type DB struct{}
+type Handler struct{}
+
+type Params struct {
+	fx.In
+
+	DB      *DB
+	Handler *Handler `+"`optional:\"true\"`"+`
+}
+
+func NewDB(Config, fx.Lifecycle) (*DB, error) { return nil, nil }
+func Register(Params) {}
+
+var Module = fx.Options(fx.Provide(NewDB))
+
+func main() {
+	fx.New(Module, fx.Invoke(Register))
+	fx.New(fx.Provide(func() Config { return Config{} }), Module, fx.Invoke(Register))
+}
+`)
+	fw := fxFramework{}
+	errs := fw.check(pkg)
+	require.Equal([]string{"no provider of Config, needed by NewDB"}, frameworkMessages(errs))
+	require.Equal("NewDB", types.ExprString(errs[0].node.(ast.Expr)))
+}
@@ -4,14 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"unicode/utf8"
+	"sync"
+	"time"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
 	lsp "github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/analysis"
 )
 
 // isFileSystemRequest returns if this is an LSP method whose sole
@@ -88,13 +92,164 @@ func (h *HandlerShared) handleFileSystemRequest(ctx context.Context, req *jsonrp
 // overlay owns the overlay filesystem, as well as handling LSP filesystem
 // requests.
 type overlay struct {
-	conn             *jsonrpc2.Conn
+	conn             jsonrpc2.JSONRPC2
 	project          *cache.Project
 	diagnosticsStyle DiagnosticsStyleEnum
+	severities       severityMap
+	analyzers        []*analysis.Analyzer
+	variants         []cache.BuildVariant
+	nolintMarker     string
+	frameworks       []framework
+	tagSchema        *tagSchema
+	boilerplate      *boilerplate
+	session          *session
+	history          *diagnosticsHistory
+	pull             *diagnosticsPull // nil if the diagnostics are pushed
+	tokens           *tokenStream     // nil unless the tokens are streamed
+
+	mu        sync.Mutex
+	open      map[span.URI]bool            // documents open in the client
+	synced    map[span.URI]*cache.Document // version of the documents the client last sent
+	coverage  map[string][]lsp.Diagnostic  // hints of the uncovered blocks, by filename
+	opened    []span.URI                   // documents opened since the last batch
+	openTimer *time.Timer
+	openLimit time.Time // when the batch is diagnosed at the latest
+
+	// unsubscribe stops the notifications of the changes of the documents
+	// of the project, see documentChanged.
+	unsubscribe func()
 }
 
-func newOverlay(conn *jsonrpc2.Conn, project *cache.Project, diagnosticsStyle DiagnosticsStyleEnum) *overlay {
-	return &overlay{conn: conn, project: project, diagnosticsStyle: diagnosticsStyle}
+// openBatchDelay is how long the diagnostics of an opened document wait for
+// the documents opened along with it, eg. when an editor restores a session.
+const openBatchDelay = 50 * time.Millisecond
+
+// openBatchMaxDelay bounds how long the diagnostics of an opened document
+// wait while other documents keep being opened.
+const openBatchMaxDelay = 500 * time.Millisecond
+
+func newOverlay(conn jsonrpc2.JSONRPC2, project *cache.Project, diagnosticsStyle DiagnosticsStyleEnum, severities severityMap, analyzers []*analysis.Analyzer, variants []cache.BuildVariant, nolintMarker string, frameworks []framework, tagSchema *tagSchema, boilerplate *boilerplate, session *session, history *diagnosticsHistory, pull *diagnosticsPull, tokens *tokenStream) *overlay {
+	h := &overlay{
+		conn:             conn,
+		project:          project,
+		diagnosticsStyle: diagnosticsStyle,
+		severities:       severities,
+		analyzers:        analyzers,
+		variants:         variants,
+		nolintMarker:     nolintMarker,
+		frameworks:       frameworks,
+		tagSchema:        tagSchema,
+		boilerplate:      boilerplate,
+		session:          session,
+		history:          history,
+		pull:             pull,
+		tokens:           tokens,
+		open:             make(map[span.URI]bool),
+		synced:           make(map[span.URI]*cache.Document),
+	}
+	h.unsubscribe = project.Overlay().Subscribe(h.documentChanged)
+	tokens.start(h)
+	return h
+}
+
+// close stops the notifications of the changes of the documents, which the
+// clients sharing the project may still change, and closes the documents
+// which the client did not close before it disconnected.
+func (h *overlay) close() {
+	h.unsubscribe()
+	h.tokens.stop()
+
+	h.mu.Lock()
+	open := h.open
+	h.open = make(map[span.URI]bool)
+	h.synced = make(map[span.URI]*cache.Document)
+	h.mu.Unlock()
+	for uri := range open {
+		h.documents().Close(uri)
+	}
+}
+
+// move moves the documents open in the client to project, which replaces the
+// project of the overlay. They are closed in the previous project, where the
+// other clients keep theirs open.
+func (h *overlay) move(project *cache.Project) {
+	h.unsubscribe()
+	h.mu.Lock()
+	previous := h.project
+	h.project = project
+	open := make([]span.URI, 0, len(h.open))
+	for uri := range h.open {
+		open = append(open, uri)
+	}
+	h.mu.Unlock()
+
+	h.unsubscribe = project.Overlay().Subscribe(h.documentChanged)
+	for _, uri := range open {
+		if doc := previous.Overlay().Get(uri); doc != nil {
+			doc = project.Overlay().Open(uri, doc.Version, doc.Content)
+			h.mu.Lock()
+			h.synced[uri] = doc
+			h.mu.Unlock()
+		}
+		previous.Overlay().Close(uri)
+	}
+}
+
+// reconfigure changes the diagnostics style, the severities of the
+// diagnostics of the documents, the analyzers which produce some of them and
+// the build variants the documents are also type-checked in.
+func (h *overlay) reconfigure(diagnosticsStyle DiagnosticsStyleEnum, severities severityMap, analyzers []*analysis.Analyzer, variants []cache.BuildVariant) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.diagnosticsStyle = diagnosticsStyle
+	h.severities = severities
+	h.analyzers = analyzers
+	h.variants = variants
+}
+
+// currentSeverities returns the severities of the diagnostics of the
+// documents.
+func (h *overlay) currentSeverities() severityMap {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.severities
+}
+
+// currentAnalyzers returns the analyzers of the diagnostics of the documents.
+func (h *overlay) currentAnalyzers() []*analysis.Analyzer {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.analyzers
+}
+
+// version returns the version of a document open in the client, or 0 if it
+// is not open.
+func (h *overlay) version(uri lsp.DocumentURI) int {
+	sourceURI := span.FromDocumentURI(uri)
+	h.mu.Lock()
+	open := h.open[sourceURI]
+	h.mu.Unlock()
+	if !open {
+		return 0
+	}
+	if doc := h.documents().Get(sourceURI); doc != nil {
+		return doc.Version
+	}
+	return 0
+}
+
+// currentVariants returns the build variants the documents are also
+// type-checked in.
+func (h *overlay) currentVariants() []cache.BuildVariant {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.variants
+}
+
+// documents returns the open documents of the project, including those of
+// the other clients sharing it.
+func (h *overlay) documents() *cache.Overlay {
+	return h.project.Overlay()
 }
 
 func (h *overlay) view() source.View {
@@ -102,7 +257,45 @@ func (h *overlay) view() source.View {
 }
 
 func (h *overlay) didOpen(ctx context.Context, params *lsp.DidOpenTextDocumentParams) {
-	h.cacheAndDiagnose(ctx, params.TextDocument.URI, []byte(params.TextDocument.Text))
+	sourceURI := span.FromDocumentURI(params.TextDocument.URI)
+	text := []byte(params.TextDocument.Text)
+
+	// The other clients sharing the project keep the document open until
+	// they close it too.
+	h.mu.Lock()
+	reopened := h.open[sourceURI]
+	h.mu.Unlock()
+	var doc *cache.Document
+	if reopened {
+		doc = h.documents().Set(sourceURI, params.TextDocument.Version, text)
+	} else {
+		doc = h.documents().Open(sourceURI, params.TextDocument.Version, text)
+	}
+	h.mu.Lock()
+	h.open[sourceURI] = true
+	h.synced[sourceURI] = doc
+	h.mu.Unlock()
+
+	if h.pull != nil {
+		// The client pulls the diagnostics of the document.
+		h.pull.changed()
+	} else if diagnostics, ok := h.session.open(params.TextDocument.URI, text); ok && h.diagnosticsStyle != noneDiagnostics {
+		// The document did not change since the previous session, so the
+		// diagnostics we published back then are still valid.
+		h.publishDiagnostics(ctx, params.TextDocument.URI, diagnostics)
+	}
+
+	if h.diagnosticsStyle == instantDiagnostics && h.pull == nil {
+		h.queueOpened(sourceURI)
+	}
+
+	if filename, err := sourceURI.Filename(); err == nil {
+		h.project.WarmUp(filename)
+		h.project.LoadTests(filename)
+		if h.boilerplate.auto && isEmptyGoFile(filename, text) {
+			h.boilerplate.insert(h.conn, params.TextDocument.URI)
+		}
+	}
 }
 
 func (h *overlay) didChange(ctx context.Context, params *lsp.DidChangeTextDocumentParams) error {
@@ -110,50 +303,179 @@ func (h *overlay) didChange(ctx context.Context, params *lsp.DidChangeTextDocume
 		return &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: "no content changes provided"}
 	}
 
-	text, err := h.applyChanges(ctx, params)
+	// The ranged changes only apply to the version of the document the
+	// client last sent. If another client sharing the project changed it
+	// since, they are rejected, and the client is asked to resync it.
+	sourceURI := span.FromDocumentURI(params.TextDocument.URI)
+	h.mu.Lock()
+	synced := h.synced[sourceURI]
+	h.mu.Unlock()
+	ranged := len(params.ContentChanges) > 1 || params.ContentChanges[0].Range != nil
+	doc, err := changeDocument(h.documents(), sourceURI, synced, ranged, params.TextDocument.Version, func(old *cache.Document) ([]byte, error) {
+		return h.applyChanges(ctx, old, params)
+	})
+	if err == errDocumentConflict {
+		h.warnConflict(ctx, params.TextDocument.URI)
+	}
 	if err != nil {
 		return err
 	}
-
-	h.cacheAndDiagnose(ctx, params.TextDocument.URI, text)
+	h.mu.Lock()
+	if h.open[sourceURI] {
+		h.synced[sourceURI] = doc
+	}
+	h.mu.Unlock()
+	h.clearCoverage(params.TextDocument.URI)
+	h.session.change(params.TextDocument.URI, doc.Content)
 	return nil
 }
 
+// errDocumentConflict is the error of the ranged changes of a document which
+// another client sharing the project changed since the version they apply to.
+var errDocumentConflict = errors.New("the document was changed by another client")
+
+// changeDocument replaces the current version of the document uri with the
+// version and the content apply returns for it, and returns the new version.
+// The full text of a document replaces it whatever its current version is,
+// while its ranged changes only apply to the version synced, the one the
+// client last sent. If another client sharing the project changed the
+// document since, the ranged changes are not applied and changeDocument
+// returns errDocumentConflict.
+func changeDocument(documents *cache.Overlay, uri span.URI, synced *cache.Document, ranged bool, version int, apply func(old *cache.Document) ([]byte, error)) (*cache.Document, error) {
+	for {
+		old := documents.Get(uri)
+		if ranged && old != synced {
+			return nil, errDocumentConflict
+		}
+		content, err := apply(old)
+		if err != nil {
+			return nil, err
+		}
+		if doc, ok := documents.CompareAndSwap(uri, old, version, content); ok {
+			return doc, nil
+		}
+	}
+}
+
+// warnConflict asks the client to resync the document uri, whose changes
+// were rejected because another client changed it meanwhile.
+func (h *overlay) warnConflict(ctx context.Context, uri lsp.DocumentURI) {
+	_ = h.conn.Notify(ctx, "window/showMessage", &lsp.ShowMessageParams{
+		Type:    lsp.MTWarning,
+		Message: fmt.Sprintf("%s was changed by another client, so your last changes were not applied: reopen it to resync it", uri),
+	})
+}
+
 func (h *overlay) didClose(ctx context.Context, params *lsp.DidCloseTextDocumentParams) {
 	uri := span.FromDocumentURI(params.TextDocument.URI)
-	h.setContent(ctx, uri, nil)
+	h.mu.Lock()
+	open := h.open[uri]
+	delete(h.open, uri)
+	delete(h.synced, uri)
+	h.mu.Unlock()
+	if open {
+		h.documents().Close(uri)
+	}
+	h.session.close(params.TextDocument.URI)
+	if h.pull != nil {
+		h.pull.changed()
+	}
 }
 
 func (h *overlay) didSave(ctx context.Context, param *lsp.DidSaveTextDocumentParams) {
-	if h.diagnosticsStyle != onsaveDiagnostics {
+	if h.diagnosticsStyle == noneDiagnostics {
 		return
 	}
 
 	sourceURI := span.FromDocumentURI(param.TextDocument.URI)
-	f, err := h.view().GetFile(ctx, sourceURI)
-	if err != nil {
-		log.Fatal(err)
-		return
+	if h.diagnosticsStyle == onsaveDiagnostics {
+		f, err := h.view().GetFile(ctx, sourceURI)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		h.diagnosetics(ctx, f)
 	}
-	h.diagnosetics(ctx, f)
+	h.diagnoseDependents(ctx, sourceURI)
 }
 
-func (h *overlay) cacheAndDiagnose(ctx context.Context, uri lsp.DocumentURI, text []byte) {
-	sourceURI := span.FromDocumentURI(uri)
-	h.setContent(ctx, sourceURI, text)
-	f, err := h.view().GetFile(ctx, sourceURI)
-	if err != nil {
+// documentChanged diagnoses a document open in the client once it changed,
+// also when another client sharing the project changed it. The opened
+// documents are diagnosed by didOpen. The clients which pull the
+// diagnostics are only notified of the change. The changes of the tokens of
+// the documents are streamed to the clients which opt in.
+func (h *overlay) documentChanged(change cache.DocumentChange) {
+	h.tokens.changed(h, change)
+	if change.Old == nil || change.New == nil {
 		return
 	}
-	if h.diagnosticsStyle != instantDiagnostics {
+	if h.pull != nil {
+		h.pull.changed()
+		return
+	}
+	h.mu.Lock()
+	diagnose := h.open[change.URI] && h.diagnosticsStyle == instantDiagnostics
+	h.mu.Unlock()
+	if !diagnose {
 		return
 	}
 
+	ctx := context.Background()
+	f, err := h.view().GetFile(ctx, change.URI)
+	if err != nil {
+		return
+	}
 	go h.diagnosetics(ctx, f)
 }
 
-func (h *overlay) setContent(ctx context.Context, uri span.URI, content []byte) error {
-	return h.view().SetContent(ctx, uri, content)
+// queueOpened schedules the diagnostics of an opened document. The documents
+// opened within openBatchDelay are diagnosed together, see diagnoseOpened,
+// but not later than openBatchMaxDelay after the first one.
+func (h *overlay) queueOpened(uri span.URI) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.opened = append(h.opened, uri)
+	if h.openTimer == nil {
+		h.openLimit = time.Now().Add(openBatchMaxDelay)
+		h.openTimer = time.AfterFunc(openBatchDelay, h.diagnoseOpened)
+		return
+	}
+	delay := openBatchDelay
+	if left := time.Until(h.openLimit); left < delay {
+		delay = left
+	}
+	h.openTimer.Reset(delay)
+}
+
+// diagnoseOpened diagnoses the queued documents one after the other, so that
+// their packages are loaded once with the content of every document, and
+// every package is diagnosed once.
+func (h *overlay) diagnoseOpened() {
+	h.mu.Lock()
+	uris := h.opened
+	h.opened = nil
+	h.openTimer = nil
+	h.mu.Unlock()
+
+	ctx := context.Background()
+	diagnosed := map[source.Package]bool{}
+	for _, uri := range uris {
+		f, err := h.view().GetFile(ctx, uri)
+		if err != nil {
+			continue
+		}
+		pkg := f.GetPackage(ctx)
+		if pkg == nil || diagnosed[pkg] {
+			continue
+		}
+		// The auxiliary packages of the files excluded by the build tags
+		// are diagnosed one file at a time, see diagnostics.
+		if !cache.IsAuxiliary(pkg.GetPkgPath()) {
+			diagnosed[pkg] = true
+		}
+		h.diagnosetics(ctx, f)
+	}
 }
 
 type DiagnosticsStyleEnum string
@@ -165,57 +487,78 @@ const (
 )
 
 func (h *overlay) diagnosetics(ctx context.Context, f source.File) {
-	reports, err := diagnostics(ctx, f)
-	if err == nil {
-		for filename, diagnostics := range reports {
-			fileURI := source.ToURI(filename)
-			params := &lsp.PublishDiagnosticsParams{
-				URI:         lsp.DocumentURI(fileURI),
-				Diagnostics: diagnostics,
-			}
-
-			h.conn.Notify(ctx, "textDocument/publishDiagnostics", params)
+	reports, err := h.reports(ctx, f)
+	if err != nil {
+		return
+	}
+	if h.pull != nil {
+		// The client pulls the diagnostics, once asked to.
+		h.pull.refresh(h.conn)
+		return
+	}
+	for filename, diagnostics := range reports {
+		fileURI := source.ToURI(filename)
+		if !h.session.publish(lsp.DocumentURI(fileURI), diagnostics) {
+			continue
 		}
+		h.publishDiagnostics(ctx, lsp.DocumentURI(fileURI), diagnostics)
 	}
 }
 
-func bytesOffset(content []byte, pos lsp.Position) int {
-	var line, char, offset int
-
-	for len(content) > 0 {
-		if line == int(pos.Line) && char == int(pos.Character) {
-			return offset
-		}
-		r, size := utf8.DecodeRune(content)
-		char++
-		// The offsets are based on a UTF-16 string representation.
-		// So the rune should be checked twice for two code units in UTF-16.
-		if r >= 0x10000 {
-			if line == int(pos.Line) && char == int(pos.Character) {
-				return offset
+// reports returns the diagnostics of the files of the package of f, with
+// their severities remapped and without the suppressed ones.
+func (h *overlay) reports(ctx context.Context, f source.File) (map[string][]lsp.Diagnostic, error) {
+	reports, err := diagnostics(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	if variants := h.currentVariants(); len(variants) > 0 {
+		if filename, err := f.URI().Filename(); err == nil {
+			for name, diagnostics := range variantDiagnostics(ctx, h.project, filename, variants, reports) {
+				reports[name] = append(reports[name], diagnostics...)
 			}
-			char++
 		}
-		offset += size
-		content = content[size:]
-		if r == '\n' {
-			line++
-			char = 0
+	}
+	if pkg := f.GetPackage(ctx); pkg != nil {
+		extras := []map[string][]lsp.Diagnostic{
+			frameworkDiagnostics(h.frameworks, pkg),
+			h.tagSchema.diagnostics(pkg),
+			analysisDiagnostics(ctx, h.view(), pkg, h.currentAnalyzers()),
+			h.coverageDiagnostics(),
+		}
+		for _, extra := range extras {
+			for filename, diagnostics := range extra {
+				if _, ok := reports[filename]; ok {
+					reports[filename] = append(reports[filename], diagnostics...)
+				}
+			}
 		}
 	}
-
-	if line == int(pos.Line) && char == int(pos.Character) {
-		return offset
+	for filename, diagnostics := range reports {
+		diagnostics = h.suppressDiagnostics(ctx, filename, diagnostics)
+		h.currentSeverities().remap(diagnostics)
+		reports[filename] = diagnostics
 	}
+	return reports, nil
+}
 
-	return -1
+// publishDiagnostics publishes the diagnostics of the document uri and adds
+// them to its history.
+func (h *overlay) publishDiagnostics(ctx context.Context, uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) {
+	h.history.record(uri, h.version(uri), diagnostics)
+	h.conn.Notify(ctx, "textDocument/publishDiagnostics", &lsp.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
 }
 
 func newJsonrpc2Errorf(code int64, message string) error {
 	return &jsonrpc2.Error{Code: code, Message: message}
 }
 
-func (h *overlay) applyChanges(ctx context.Context, params *lsp.DidChangeTextDocumentParams) ([]byte, error) {
+// applyChanges returns the content of the document old, or of its file if it
+// is not open, with the changes of params.
+func (h *overlay) applyChanges(ctx context.Context, old *cache.Document, params *lsp.DidChangeTextDocumentParams) ([]byte, error) {
 	if len(params.ContentChanges) == 1 && params.ContentChanges[0].Range == nil {
 		// If range is empty, we expect the full content of file, i.e. a single change with no range.
 		change := params.ContentChanges[0]
@@ -230,18 +573,32 @@ func (h *overlay) applyChanges(ctx context.Context, params *lsp.DidChangeTextDoc
 		return nil, err
 	}
 
-	file, err := h.project.View().GetFile(ctx, sourceURI)
-	if err != nil {
-		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "file not found")
+	var content []byte
+	var lines *span.LineIndex
+	if old != nil {
+		content, lines = old.Content, old.LineIndex()
+	} else {
+		file, err := h.project.View().GetFile(ctx, sourceURI)
+		if err != nil {
+			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "file not found")
+		}
+		content, lines = file.GetContent(ctx), file.GetLineIndex(ctx)
 	}
+	return applyContentChanges(content, lines, params.ContentChanges)
+}
 
-	content := file.GetContent(ctx)
-	for _, change := range params.ContentChanges {
-		start := bytesOffset(content, change.Range.Start)
+// applyContentChanges returns content, whose line index is lines, with the
+// ranged changes.
+func applyContentChanges(content []byte, lines *span.LineIndex, changes []lsp.TextDocumentContentChangeEvent) ([]byte, error) {
+	for i, change := range changes {
+		if i > 0 {
+			lines = span.NewLineIndex(content)
+		}
+		start := lines.Offset(int(change.Range.Start.Line), int(change.Range.Start.Character))
 		if start == -1 {
 			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "invalid range for content change")
 		}
-		end := bytesOffset(content, change.Range.End)
+		end := lines.Offset(int(change.Range.End.Line), int(change.Range.End.Character))
 		if end == -1 {
 			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "invalid range for content change")
 		}
@@ -0,0 +1,201 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeDocument(t *testing.T) {
+	require := require.New(t)
+	documents := cache.NewOverlay()
+	uri := span.FileURI("/src/a.go")
+	synced := documents.Open(uri, 1, []byte("a"))
+
+	appendC := func(old *cache.Document) ([]byte, error) {
+		return append(append([]byte{}, old.Content...), 'c'), nil
+	}
+	synced, err := changeDocument(documents, uri, synced, true, 2, appendC)
+	require.NoError(err)
+	require.Equal("ac", string(synced.Content))
+
+	// Another client changes the document: the ranged changes computed for
+	// the previous version are rejected.
+	documents.Set(uri, 10, []byte("b"))
+	_, err = changeDocument(documents, uri, synced, true, 3, appendC)
+	require.Equal(errDocumentConflict, err)
+	require.Equal("b", string(documents.Get(uri).Content))
+
+	// Another client changes the document while the changes are applied.
+	current := documents.Get(uri)
+	calls := 0
+	_, err = changeDocument(documents, uri, current, true, 3, func(old *cache.Document) ([]byte, error) {
+		if calls++; calls == 1 {
+			documents.Set(uri, 11, []byte("d"))
+		}
+		return appendC(old)
+	})
+	require.Equal(errDocumentConflict, err)
+	require.Equal("d", string(documents.Get(uri).Content))
+
+	// The full text replaces the document whatever its version.
+	doc, err := changeDocument(documents, uri, synced, false, 4, func(old *cache.Document) ([]byte, error) {
+		return []byte("e"), nil
+	})
+	require.NoError(err)
+	require.Equal("e", string(doc.Content))
+	require.Equal(4, documents.Get(uri).Version)
+}
+
+func TestSharedDocuments(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/shared\n",
+		"a.go":   "package shared\n",
+	})
+	uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go")))
+	pool := NewProjectPool()
+	connect := func() *TestContext {
+		tx := &TestContext{h: NewSharedHandler(testConfig(cache.None), pool), ctx: context.Background(), dir: root}
+		tx.initServer(t)
+		return tx
+	}
+	notify := func(tx *TestContext, method string, params interface{}) {
+		require.NoError(tx.conn.Notify(tx.ctx, method, params))
+		// The notifications of the documents are handled before the
+		// requests which follow them.
+		require.NoError(tx.conn.Call(tx.ctx, "bingo/health", nil, nil))
+	}
+	document := func() *cache.Document {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		for _, sp := range pool.projects {
+			return sp.project.Overlay().Get(span.FromDocumentURI(uri))
+		}
+		return nil
+	}
+
+	tx1, tx2 := connect(), connect()
+	t.Cleanup(tx1.tearDown)
+	open := lsp.DidOpenTextDocumentParams{TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: "package shared\n\nvar A int\n"}}
+	notify(tx1, "textDocument/didOpen", open)
+	notify(tx2, "textDocument/didOpen", open)
+
+	// The ranged changes of a client are not applied to the changes of the
+	// other one.
+	notify(tx2, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+		TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: 2},
+		ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: "package shared\n\nvar B int\n"}},
+	})
+	rng := lsp.Range{Start: lsp.Position{Line: 2, Character: 4}, End: lsp.Position{Line: 2, Character: 5}}
+	notify(tx1, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+		TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: 2},
+		ContentChanges: []lsp.TextDocumentContentChangeEvent{{Range: &rng, RangeLength: 1, Text: "C"}},
+	})
+	require.Equal("package shared\n\nvar B int\n", string(document().Content))
+	tx1.client.await(t, "window/showMessage", nil)
+
+	notify(tx1, "textDocument/didClose", lsp.DidCloseTextDocumentParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}})
+	require.NotNil(document(), "the document was closed while the other client has it open")
+
+	// The documents of a client which disconnects are closed.
+	tx2.tearDown()
+	require.True(waitFor(func() bool { return document() == nil }), "the document of the disconnected client was not closed")
+}
+
+func TestOpenBurst(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	files := map[string]string{
+		"go.mod": "module example.com/burst\n",
+		"p/a.go": "package p\n\nfunc A() {}\n",
+		"p/b.go": "package p\n\nfunc B() {}\n",
+		"p/c.go": "package p\n\nfunc C() {}\n",
+		"q/d.go": "package q\n\nfunc D() {}\n",
+	}
+	root := writeWorkspace(t, files)
+	cfg := testConfig(cache.Ondemand)
+	cfg.DiagnosticsStyle = string(instantDiagnostics)
+	tx := newWorkspaceContext(t, cfg, root)
+
+	// An editor restoring a session opens its documents at once.
+	uris := map[lsp.DocumentURI]string{}
+	for _, name := range []string{"p/a.go", "p/b.go", "p/c.go", "q/d.go"} {
+		uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, filepath.FromSlash(name))))
+		uris[uri] = name
+		require.NoError(tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: files[name]},
+		}))
+	}
+	for uri := range uris {
+		tx.client.awaitDiagnostics(t, uri)
+	}
+
+	// Every package is diagnosed once, with the diagnostics of all its
+	// files: none of the documents is diagnosed again.
+	time.Sleep(4 * openBatchDelay)
+	tx.client.mu.Lock()
+	defer tx.client.mu.Unlock()
+	for _, req := range tx.client.notifications {
+		if req.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params lsp.PublishDiagnosticsParams
+		require.NoError(json.Unmarshal(*req.Params, &params))
+		name, ok := uris[params.URI]
+		require.False(ok, "%s was diagnosed more than once", name)
+	}
+}
+
+func TestOpenStream(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{"go.mod": "module example.com/stream\n"}
+	var names []string
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("p/f%d.go", i)
+		files[name] = fmt.Sprintf("package p\n\nfunc F%d() {}\n", i)
+		names = append(names, name)
+	}
+	root := writeWorkspace(t, files)
+	cfg := testConfig(cache.Ondemand)
+	cfg.DiagnosticsStyle = string(instantDiagnostics)
+	tx := newWorkspaceContext(t, cfg, root)
+
+	diagnosed := func() bool {
+		tx.client.mu.Lock()
+		defer tx.client.mu.Unlock()
+		for _, req := range tx.client.notifications {
+			if req.Method == "textDocument/publishDiagnostics" {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The documents keep being opened within openBatchDelay of each other:
+	// the first ones are diagnosed anyway once openBatchMaxDelay elapsed.
+	for _, name := range names {
+		uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, filepath.FromSlash(name))))
+		require.NoError(t, tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: files[name]},
+		}))
+		if diagnosed() {
+			return
+		}
+		time.Sleep(openBatchDelay / 2)
+	}
+	t.Fatalf("no document was diagnosed while %d documents were opened", len(names))
+}
@@ -0,0 +1,186 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// fuzzTimeout is how long a fuzzed request may take before it is reported
+// as a hang.
+const fuzzTimeout = 30 * time.Second
+
+// fuzzSource is the document of the fuzzed requests.
+const fuzzSource = `package fuzz
+
+// T is a type.
+type T struct {
+	F int
+}
+
+// M is a method.
+func (t *T) M(n int) int {
+	return t.F + n
+}
+
+func use() {
+	var t T
+	_ = t.M(1)
+}
+`
+
+// fuzzMethods are the requests fuzzed. The lifecycle requests, the
+// notifications and the commands, which change the state of the server or
+// run external tools, are left out.
+var fuzzMethods = []string{
+	"textDocument/hover",
+	"textDocument/definition",
+	"textDocument/typeDefinition",
+	"textDocument/xdefinition",
+	"textDocument/completion",
+	"textDocument/references",
+	"textDocument/documentHighlight",
+	"textDocument/prepareCallHierarchy",
+	"callHierarchy/incomingCalls",
+	"callHierarchy/outgoingCalls",
+	"textDocument/implementation",
+	"textDocument/documentSymbol",
+	"textDocument/signatureHelp",
+	"textDocument/formatting",
+	"textDocument/rangeFormatting",
+	"workspace/symbol",
+	"workspace/xreferences",
+	"textDocument/rename",
+	"textDocument/prepareRename",
+	"textDocument/codeAction",
+	"textDocument/foldingRange",
+	"textDocument/documentColor",
+	"textDocument/colorPresentation",
+	"textDocument/codeLens",
+	"codeLens/resolve",
+	"bingo/packageDoc",
+	"bingo/searchAST",
+}
+
+// newFuzzContext sets up a server of the fuzz target f, and returns the URI
+// of its document, which has the content fuzzSource.
+func newFuzzContext(f *testing.F) (*TestContext, lsp.DocumentURI) {
+	root := writeWorkspace(f, map[string]string{
+		"go.mod":  "module example.com/fuzz\n",
+		"fuzz.go": fuzzSource,
+	})
+	tx := newWorkspaceContext(f, testConfig(cache.Ondemand), root)
+	uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, "fuzz.go")))
+	if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: fuzzSource},
+	}); err != nil {
+		f.Fatal(err)
+	}
+	return tx, uri
+}
+
+// fuzzCall sends the request of method with params, and fails t if the
+// server panics, does not answer, or answers with an error without data.
+func fuzzCall(t *testing.T, tx *TestContext, method string, params json.RawMessage) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(tx.ctx, fuzzTimeout)
+	defer cancel()
+
+	var result json.RawMessage
+	err := tx.conn.Call(ctx, method, params, &result)
+	if err == nil {
+		return
+	}
+	rpcErr, ok := err.(*jsonrpc2.Error)
+	if !ok {
+		t.Fatalf("%s %s: no response: %v", method, params, err)
+	}
+	var data ErrorData
+	if rpcErr.Data == nil || json.Unmarshal(*rpcErr.Data, &data) != nil {
+		t.Fatalf("%s %s: error without data: %v", method, params, rpcErr)
+	}
+	if data.Panic {
+		t.Fatalf("%s %s: %s", method, params, rpcErr.Message)
+	}
+}
+
+// FuzzHandle sends requests with arbitrary params. "$URI" in the params is
+// replaced by the URI of the document of the server.
+func FuzzHandle(f *testing.F) {
+	tx, uri := newFuzzContext(f)
+
+	seeds := []string{
+		`{"textDocument":{"uri":"$URI"},"position":{"line":8,"character":13}}`,
+		`{"textDocument":{"uri":"$URI"},"position":{"line":-1,"character":1000000}}`,
+		`{"textDocument":{"uri":"$URI"},"range":{"start":{"line":9,"character":0},"end":{"line":0,"character":0}}}`,
+		`{"textDocument":{"uri":"$URI"},"position":{"line":14,"character":7},"newName":"","context":{"includeDeclaration":true}}`,
+		`{"textDocument":{"uri":"file:///nonexistent.go"},"position":{"line":0,"character":0}}`,
+		`{"textDocument":{"uri":42},"position":"8:13"}`,
+		`{"item":{"name":"M","uri":"$URI"}}`,
+		`{"query":"T"}`,
+		`{}`,
+		`[]`,
+		`null`,
+	}
+	for i := range fuzzMethods {
+		for _, seed := range seeds {
+			f.Add(uint8(i), seed)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, method uint8, params string) {
+		// The JSON which does not decode is rejected by the codec of the
+		// connection, before the handler.
+		if !json.Valid([]byte(params)) {
+			t.Skip()
+		}
+		params = strings.Replace(params, "$URI", string(uri), -1)
+		fuzzCall(t, tx, fuzzMethods[int(method)%len(fuzzMethods)], json.RawMessage(params))
+	})
+}
+
+// FuzzPositions changes the content of the document, e.g. truncates it, and
+// sends the requests of a position at an arbitrary position of it.
+func FuzzPositions(f *testing.F) {
+	tx, uri := newFuzzContext(f)
+
+	for i := range fuzzMethods {
+		f.Add(uint8(i), fuzzSource, 8, 13)
+		f.Add(uint8(i), fuzzSource[:len(fuzzSource)/2], 8, 13)
+		f.Add(uint8(i), fuzzSource, 100, 0)
+		f.Add(uint8(i), "package", 0, 3)
+		f.Add(uint8(i), "", 0, 0)
+	}
+
+	version := 1
+	f.Fuzz(func(t *testing.T, method uint8, text string, line, char int) {
+		version++
+		if err := tx.conn.Notify(tx.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+			TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: version},
+			ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: text}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		position := lsp.Position{Line: line, Character: char}
+		params, err := json.Marshal(map[string]interface{}{
+			"textDocument": lsp.TextDocumentIdentifier{URI: uri},
+			"position":     position,
+			"range":        lsp.Range{Start: position, End: position},
+			"newName":      "x",
+			"context":      lsp.ReferenceContext{IncludeDeclaration: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fuzzCall(t, tx, fuzzMethods[int(method)%len(fuzzMethods)], params)
+	})
+}
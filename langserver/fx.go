@@ -0,0 +1,232 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+// fxPath is the import path of uber/fx.
+const fxPath = "go.uber.org/fx"
+
+// fxFramework supports uber/fx.
+type fxFramework struct{}
+
+func init() {
+	registerFramework(fxFramework{})
+}
+
+func (fxFramework) name() string {
+	return "fx"
+}
+
+func (fxFramework) registrations(pkg *frameworkPackage) []registration {
+	var regs []registration
+	for _, call := range callsOf(pkg, fxPath, "Provide", "Invoke", "Decorate") {
+		for _, arg := range call.Args {
+			switch obj := referencedObject(pkg.info, arg).(type) {
+			case *types.Func, *types.Var:
+				regs = append(regs, registration{call: call, arg: arg, obj: obj})
+			}
+		}
+	}
+	return regs
+}
+
+// check reports the constructors and the invoked functions of the
+// applications created by fx.New whose parameters are not provided.
+func (fxFramework) check(pkg *frameworkPackage) []frameworkError {
+	var errs []frameworkError
+	for _, call := range callsOf(pkg, fxPath, "New") {
+		set := newProviderSet(pkg)
+		for _, arg := range call.Args {
+			addFxOption(set, pkg, arg, nil)
+		}
+		if !set.unsupported {
+			errs = append(errs, set.missing(nil, isFxType)...)
+		}
+	}
+	return errs
+}
+
+// addFxOption adds the providers of the fx option expr of pkg to set.
+func addFxOption(set *providerSet, pkg *frameworkPackage, expr ast.Expr, report ast.Node) {
+	if v, ok := referencedObject(pkg.info, expr).(*types.Var); ok && isFxType(v.Type()) {
+		set.expandVar(pkg, v, expr, report, func(pkg *frameworkPackage, init ast.Expr, report ast.Node) {
+			addFxOption(set, pkg, init, report)
+		})
+		return
+	}
+
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		set.unsupported = true
+		return
+	}
+	path, name := calledFunc(pkg.info, call)
+	if path != fxPath {
+		set.unsupported = true
+		return
+	}
+
+	switch name {
+	case "Options":
+		for _, arg := range call.Args {
+			addFxOption(set, pkg, arg, report)
+		}
+
+	case "Module":
+		if len(call.Args) == 0 {
+			set.unsupported = true
+			return
+		}
+		// The first argument is the name of the module.
+		for _, arg := range call.Args[1:] {
+			addFxOption(set, pkg, arg, report)
+		}
+
+	case "Provide", "Invoke", "Decorate":
+		for _, arg := range call.Args {
+			sig, ok := pkg.info.TypeOf(arg).(*types.Signature)
+			if !ok {
+				set.unsupported = true
+				return
+			}
+			needs, ok := fxParams(sig)
+			if !ok {
+				set.unsupported = true
+				return
+			}
+			var provides []types.Type
+			if name == "Provide" {
+				if provides, ok = fxResults(sig); !ok {
+					set.unsupported = true
+					return
+				}
+			}
+			set.add(arg, report, needs, provides)
+		}
+
+	case "Supply":
+		var provides []types.Type
+		for _, arg := range call.Args {
+			provides = append(provides, pkg.info.TypeOf(arg))
+		}
+		set.add(call, report, nil, provides)
+
+	case "Populate":
+		var needs []types.Type
+		for _, arg := range call.Args {
+			ptr, ok := pkg.info.TypeOf(arg).(*types.Pointer)
+			if !ok {
+				set.unsupported = true
+				return
+			}
+			needs = append(needs, ptr.Elem())
+		}
+		set.add(call, report, needs, nil)
+
+	case "Logger", "WithLogger", "NopLogger", "StartTimeout", "StopTimeout", "ErrorHook", "RecoverFromPanics":
+		// Options which do not provide anything.
+
+	default:
+		set.unsupported = true
+	}
+}
+
+// fxParams returns the types needed by a constructor or an invoked
+// function: its parameters, or the fields of its fx.In parameters. It fails
+// on the named and grouped values, which are not matched by type.
+func fxParams(sig *types.Signature) ([]types.Type, bool) {
+	var needs []types.Type
+	for i := 0; i < sig.Params().Len(); i++ {
+		t := sig.Params().At(i).Type()
+		st, ok := fxStruct(t, "In")
+		if !ok {
+			needs = append(needs, t)
+			continue
+		}
+		fields, ok := fxFields(st, true)
+		if !ok {
+			return nil, false
+		}
+		needs = append(needs, fields...)
+	}
+	return needs, true
+}
+
+// fxResults returns the types provided by a constructor: its results other
+// than error, or the fields of its fx.Out results.
+func fxResults(sig *types.Signature) ([]types.Type, bool) {
+	var provides []types.Type
+	for i := 0; i < sig.Results().Len(); i++ {
+		t := sig.Results().At(i).Type()
+		if isErrorType(t) {
+			continue
+		}
+		st, ok := fxStruct(t, "Out")
+		if !ok {
+			provides = append(provides, t)
+			continue
+		}
+		fields, ok := fxFields(st, false)
+		if !ok {
+			return nil, false
+		}
+		provides = append(provides, fields...)
+	}
+	return provides, true
+}
+
+// fxStruct returns the struct t if it embeds fx.In or fx.Out.
+func fxStruct(t types.Type, embedded string) (*types.Struct, bool) {
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return nil, false
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if f.Anonymous() && isFxType(f.Type()) && f.Name() == embedded {
+			return st, true
+		}
+	}
+	return nil, false
+}
+
+// fxFields returns the types of the fields of the fx.In or fx.Out struct
+// st, skipping the optional ones if in is set.
+func fxFields(st *types.Struct, in bool) ([]types.Type, bool) {
+	var fields []types.Type
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if f.Anonymous() && isFxType(f.Type()) {
+			continue
+		}
+		tag := reflect.StructTag(st.Tag(i))
+		if tag.Get("name") != "" || tag.Get("group") != "" {
+			return nil, false
+		}
+		if in && tag.Get("optional") == "true" {
+			continue
+		}
+		fields = append(fields, f.Type())
+	}
+	return fields, true
+}
+
+// isFxType reports whether t is a type of fx, like fx.Lifecycle, which the
+// applications provide.
+func isFxType(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	path := named.Obj().Pkg().Path()
+	return path == fxPath || strings.HasPrefix(path, fxPath+"/")
+}
+
+// isErrorType reports whether t is the error type.
+func isErrorType(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
@@ -0,0 +1,172 @@
+package langserver
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files of TestGolden")
+
+// goldenDir holds the cases of TestGolden. A case is a directory with:
+//
+//	workspace/       the workspace served, e.g. a module
+//	requests.json    the requests sent to the server
+//	expected/        the golden responses, one file per request
+//
+// The requests are a list of goldenRequest, e.g.
+//
+//	[{"method": "textDocument/hover", "position": "a.go:3:6"}]
+//
+// and the golden files are generated with
+//
+//	go test ./langserver -run TestGolden -update
+const goldenDir = "testdata/golden"
+
+// goldenRequest is a request of a case of TestGolden.
+type goldenRequest struct {
+	Method string `json:"method"`
+
+	// Position is the "file:line:column" of the request, 1-based and
+	// relative to the workspace. It sets the textDocument and position of
+	// the params.
+	Position string `json:"position"`
+
+	// Params are merged into the params of the request, e.g. the context
+	// of textDocument/references.
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// goldenResponse is a golden response: its result, or its error.
+type goldenResponse struct {
+	Result interface{}     `json:"result"`
+	Error  *jsonrpc2.Error `json:"error,omitempty"`
+}
+
+// name returns the name of the golden file of r, e.g.
+// "textDocument.hover@a.go_3_6.json".
+func (r *goldenRequest) name() string {
+	position := strings.NewReplacer("/", "_", ":", "_").Replace(r.Position)
+	return fmt.Sprintf("%s@%s.json", strings.Replace(r.Method, "/", ".", -1), position)
+}
+
+// params returns the params of r in the workspace rootURI.
+func (r *goldenRequest) params(rootURI lsp.DocumentURI) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	if r.Position != "" {
+		file, line, char, err := parsePos(r.Position)
+		if err != nil {
+			return nil, err
+		}
+		params["textDocument"] = lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)}
+		params["position"] = lsp.Position{Line: line, Character: char}
+	}
+	for k, v := range r.Params {
+		params[k] = v
+	}
+	return params, nil
+}
+
+func TestGolden(t *testing.T) {
+	cases, err := ioutil.ReadDir(goldenDir)
+	if os.IsNotExist(err) {
+		t.Skip("no golden cases")
+	}
+	require.NoError(t, err)
+
+	for _, c := range cases {
+		if !c.IsDir() {
+			continue
+		}
+		dir := filepath.Join(goldenDir, c.Name())
+		t.Run(c.Name(), func(t *testing.T) {
+			t.Parallel()
+			testGoldenCase(t, dir)
+		})
+	}
+}
+
+func testGoldenCase(t *testing.T, dir string) {
+	require := require.New(t)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "requests.json"))
+	require.NoError(err)
+	var requests []*goldenRequest
+	require.NoError(json.Unmarshal(data, &requests), "requests.json")
+
+	// The workspace is copied, so that the server does not write anything
+	// into the tree, e.g. go.sum.
+	root, err := ioutil.TempDir("", "bingo-golden")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	root, err = filepath.EvalSymlinks(root)
+	require.NoError(err)
+	require.NoError(copyDir(filepath.Join(dir, "workspace"), root))
+
+	tx := newWorkspaceContext(t, testConfig(cache.Ondemand), root)
+	rootURI := util.PathToURI(filepath.ToSlash(root))
+	normalize := strings.NewReplacer(
+		string(rootURI), "$ROOT",
+		filepath.ToSlash(root), "$ROOT",
+		filepath.ToSlash(runtime.GOROOT()), "$GOROOT",
+	)
+
+	for _, r := range requests {
+		params, err := r.params(rootURI)
+		require.NoError(err, r.Position)
+
+		var response goldenResponse
+		if err := tx.conn.Call(tx.ctx, r.Method, params, &response.Result); err != nil {
+			rpcErr, ok := err.(*jsonrpc2.Error)
+			require.True(ok, "%s %s: %v", r.Method, r.Position, err)
+			response.Error = rpcErr
+		}
+		got, err := json.MarshalIndent(response, "", "\t")
+		require.NoError(err)
+		got = append([]byte(normalize.Replace(string(got))), '\n')
+
+		golden := filepath.Join(dir, "expected", r.name())
+		if *update {
+			require.NoError(os.MkdirAll(filepath.Dir(golden), 0755))
+			require.NoError(ioutil.WriteFile(golden, got, 0644))
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		require.NoError(err, "run the tests with -update to generate the golden file")
+		require.Equal(string(want), string(got), "%s %s", r.Method, r.Position)
+	}
+}
+
+// copyDir copies the files of the directory src into dst.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+}
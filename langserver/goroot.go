@@ -0,0 +1,155 @@
+package langserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// goroot returns the path of Config.GOROOT, which is relative to the root
+// of the workspace.
+func (c *Config) goroot(rootPath string) string {
+	if c.GOROOT == "" || filepath.IsAbs(c.GOROOT) {
+		return c.GOROOT
+	}
+	return filepath.Join(rootPath, c.GOROOT)
+}
+
+// workspaceEnv returns the environment overrides of the go command in the
+// workspace rootPath: the GOROOT of Config.GOROOT, the overrides of
+// Config.FolderEnv, which win, and the ones of Config.Offline, which win
+// over them.
+func (c *Config) workspaceEnv(rootPath string) []string {
+	env := c.folderEnv(rootPath)
+	if goroot := c.goroot(rootPath); goroot != "" {
+		env = append([]string{"GOROOT=" + goroot}, env...)
+	}
+	if c.Offline {
+		env = offlineEnv(env, rootPath)
+	}
+	return env
+}
+
+// checkGOROOT returns the problems of the toolchain goroot, given the go env
+// of the workspace: the go command must use it, and its version must match
+// the one of its standard library and of the export data of the library.
+func checkGOROOT(goroot string, goEnv map[string]string) []string {
+	if info, err := os.Stat(filepath.Join(goroot, "src", "runtime")); err != nil || !info.IsDir() {
+		return []string{fmt.Sprintf("GOROOT %s has no standard library", goroot)}
+	}
+
+	var problems []string
+	if used := goEnv["GOROOT"]; used != "" && filepath.Clean(used) != filepath.Clean(goroot) {
+		problems = append(problems, fmt.Sprintf("the go command uses the GOROOT %s instead of %s", used, goroot))
+	}
+
+	// The development toolchains have no VERSION file.
+	version := gorootVersion(goroot)
+	if version == "" {
+		return problems
+	}
+	if goVersion := goEnv["GOVERSION"]; goVersion != "" && goVersion != version {
+		problems = append(problems, fmt.Sprintf("the standard library of GOROOT %s is %s, but the go command is %s", goroot, version, goVersion))
+	}
+
+	// Since go1.20, the export data of the standard library is built in
+	// the build cache rather than shipped in GOROOT/pkg.
+	archive := filepath.Join(goroot, "pkg", goEnv["GOOS"]+"_"+goEnv["GOARCH"], "runtime.a")
+	if exportVersion, err := exportDataVersion(archive); err == nil && exportVersion != version {
+		problems = append(problems, fmt.Sprintf("the export data of the standard library of GOROOT %s was built by %s, not %s", goroot, exportVersion, version))
+	}
+	return problems
+}
+
+// gorootVersion returns the version of the toolchain goroot, eg. "go1.12.5",
+// or empty if it is unknown.
+func gorootVersion(goroot string) string {
+	data, err := ioutil.ReadFile(filepath.Join(goroot, "VERSION"))
+	if err != nil {
+		return ""
+	}
+	version := strings.TrimSpace(string(data))
+	if i := strings.IndexByte(version, '\n'); i >= 0 {
+		version = version[:i]
+	}
+	return strings.TrimSpace(version)
+}
+
+// exportDataVersion returns the version of the compiler of the archive
+// filename, read from its "go object GOOS GOARCH VERSION" header.
+func exportDataVersion(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// The header is at the beginning of the __.PKGDEF member.
+	r := bufio.NewReader(io.LimitReader(f, 4096))
+	for {
+		line, err := r.ReadBytes('\n')
+		if i := bytes.Index(line, []byte("go object ")); i >= 0 {
+			fields := strings.Fields(string(line[i:]))
+			if len(fields) < 5 {
+				return "", fmt.Errorf("%s: invalid object header %q", filename, line[i:])
+			}
+			return fields[4], nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: no object header", filename)
+		}
+	}
+}
+
+// publishWorkspaceDiagnostics reports the problems of Config.GOROOT and the
+// modules missing from the module cache as the diagnostics of the go.mod
+// file of the workspace, or else of its root, and the go directives which
+// cannot be honored as the diagnostics of their go.mod file.
+func (h *LangHandler) publishWorkspaceDiagnostics(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string) {
+	byFile := goVersionDiagnostics(rootPath, h.project.GoEnv()["GOVERSION"])
+	root := rootPath
+	if goMod := filepath.Join(rootPath, "go.mod"); fileExists(goMod) {
+		root = goMod
+	}
+	byFile[root] = append(append(h.gorootDiagnostics(rootPath), h.missingModulesDiagnostics()...), byFile[root]...)
+
+	for filename, diagnostics := range byFile {
+		if len(diagnostics) == 0 {
+			continue
+		}
+		_ = conn.Notify(ctx, "textDocument/publishDiagnostics", &lsp.PublishDiagnosticsParams{URI: util.PathToURI(filename), Diagnostics: diagnostics})
+	}
+}
+
+// gorootDiagnostics returns the problems of Config.GOROOT.
+func (h *LangHandler) gorootDiagnostics(rootPath string) []lsp.Diagnostic {
+	goroot := h.config.goroot(rootPath)
+	if goroot == "" {
+		return nil
+	}
+	var diagnostics []lsp.Diagnostic
+	for _, problem := range checkGOROOT(goroot, h.project.GoEnv()) {
+		diagnostics = append(diagnostics, lsp.Diagnostic{
+			Severity: lsp.Error,
+			Source:   "goroot",
+			Message:  problem,
+		})
+	}
+	return diagnostics
+}
+
+// fileExists reports whether filename is an existing file.
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,54 @@
+package langserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceEnv(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cfg := NewDefaultConfig()
+	cfg.GOROOT = "third_party/go"
+	cfg.FolderEnv = map[string]map[string]string{"/work": {"GOFLAGS": "-mod=vendor"}}
+	require.Equal([]string{"GOROOT=" + filepath.Join("/work", "third_party/go"), "GOFLAGS=-mod=vendor"}, cfg.workspaceEnv("/work"))
+
+	cfg.GOROOT = ""
+	require.Equal([]string{"GOFLAGS=-mod=vendor"}, cfg.workspaceEnv("/work"))
+}
+
+func TestCheckGOROOT(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	goroot, err := ioutil.TempDir("", "bingo-goroot")
+	require.NoError(err)
+	defer os.RemoveAll(goroot)
+
+	goEnv := map[string]string{"GOROOT": goroot, "GOVERSION": "go1.12.5", "GOOS": "linux", "GOARCH": "amd64"}
+	require.Equal([]string{"GOROOT " + goroot + " has no standard library"}, checkGOROOT(goroot, goEnv))
+
+	require.NoError(os.MkdirAll(filepath.Join(goroot, "src", "runtime"), 0755))
+	require.Empty(checkGOROOT(goroot, goEnv))
+
+	require.NoError(ioutil.WriteFile(filepath.Join(goroot, "VERSION"), []byte("go1.12.5\ntime 2019-05-06T21:12:37Z\n"), 0644))
+	pkgDir := filepath.Join(goroot, "pkg", "linux_amd64")
+	require.NoError(os.MkdirAll(pkgDir, 0755))
+	archive := "!<arch>\n__.PKGDEF       0           0     0     644     1024      `\ngo object linux amd64 go1.12.5 X:framepointer\n"
+	require.NoError(ioutil.WriteFile(filepath.Join(pkgDir, "runtime.a"), []byte(archive), 0644))
+	require.Empty(checkGOROOT(goroot, goEnv))
+
+	goEnv["GOROOT"] = "/usr/local/go"
+	goEnv["GOVERSION"] = "go1.13"
+	require.NoError(ioutil.WriteFile(filepath.Join(goroot, "VERSION"), []byte("go1.12.6"), 0644))
+	require.Equal([]string{
+		"the go command uses the GOROOT /usr/local/go instead of " + goroot,
+		"the standard library of GOROOT " + goroot + " is go1.12.6, but the go command is go1.13",
+		"the export data of the standard library of GOROOT " + goroot + " was built by go1.12.5, not go1.12.6",
+	}, checkGOROOT(goroot, goEnv))
+}
@@ -0,0 +1,124 @@
+package langserver
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// testCommand is the workspace/executeCommand command which runs a test or
+// a benchmark with go test. Its arguments are the directory of the package
+// and the name of the test or benchmark function.
+const testCommand = "bingo.test"
+
+// testCodeLenses returns a "run test" code lens on the test functions and a
+// "run benchmark" code lens on the benchmark functions of the test file f.
+func testCodeLenses(fset *token.FileSet, f *ast.File, uri lsp.DocumentURI) []protocol.CodeLens {
+	lenses := []protocol.CodeLens{}
+	if !strings.HasSuffix(fset.Position(f.Pos()).Filename, "_test.go") {
+		return lenses
+	}
+
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Type.Params.NumFields() != 1 {
+			continue
+		}
+		kind := ""
+		switch {
+		case isTestName(fn.Name.Name, "Test"):
+			kind = testLens
+		case isTestName(fn.Name.Name, "Benchmark"):
+			kind = benchmarkLens
+		default:
+			continue
+		}
+		rng := rangeForNode(fset, fn.Name)
+		lenses = append(lenses, protocol.CodeLens{
+			Range: rng,
+			Data:  codeLensData{Kind: kind, URI: uri, Position: rng.Start, Name: fn.Name.Name},
+		})
+	}
+	return lenses
+}
+
+// isTestName reports whether name is the name of a test function with the
+// prefix, like go test: the prefix is not followed by a lower case letter.
+func isTestName(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+// testLensCommand returns the command of the test or benchmark code lens of
+// the function name of the file uri.
+func testLensCommand(kind string, uri lsp.DocumentURI, name string) *lsp.Command {
+	title := "run test"
+	if kind == benchmarkLens {
+		title = "run benchmark"
+	}
+	return &lsp.Command{
+		Title:     title,
+		Command:   testCommand,
+		Arguments: []interface{}{filepath.Dir(util.UriToRealPath(uri)), name},
+	}
+}
+
+// testArgs returns the arguments of go test which run only the test or the
+// benchmark name.
+func testArgs(name string) []string {
+	pattern := "^" + regexp.QuoteMeta(name) + "$"
+	if isTestName(name, "Benchmark") {
+		return []string{"test", "-run", "^$", "-bench", pattern}
+	}
+	return []string{"test", "-run", pattern}
+}
+
+// test starts go test for the test or benchmark name of the package in dir,
+// streams its output to the client log, and shows whether it passed once it
+// exits. It does not wait for go test to exit.
+func (h *LangHandler) test(dir, name string) error {
+	args := testArgs(name)
+
+	// The tests outlive the request, so they are not bound to its context.
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = h.commandEnv(nil)
+	out := &lineWriter{emit: h.notifyLog}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	cmdName := "go " + strings.Join(args, " ")
+	if err := cmd.Start(); err != nil {
+		h.auditExec(cmd, err)
+		return fmt.Errorf("%s: %s", cmdName, err)
+	}
+	h.notifyLog(fmt.Sprintf("%s (in %s)", cmdName, dir))
+
+	go func() {
+		err := cmd.Wait()
+		out.flush()
+		h.auditExec(cmd, err)
+		if err != nil {
+			h.notifyError(fmt.Sprintf("%s failed: %s", name, err))
+			return
+		}
+		h.notifyInfo(fmt.Sprintf("%s passed", name))
+	}()
+	return nil
+}
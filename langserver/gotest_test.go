@@ -0,0 +1,72 @@
+package langserver
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestCodeLenses(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `package p
+
+import "testing"
+
+func TestAdd(t *testing.T) {}
+
+func Test(t *testing.T) {}
+
+func Testable(t *testing.T) {}
+
+func (s) TestMethod(t *testing.T) {}
+
+func TestMain(m *testing.M) {}
+
+func BenchmarkAdd(b *testing.B) {}
+
+func helper() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "/a/p_test.go", src, 0)
+	require.NoError(err)
+
+	lenses := testCodeLenses(fset, f, "file:///a/p_test.go")
+	var names, kinds []string
+	for _, lens := range lenses {
+		data := lens.Data.(codeLensData)
+		names = append(names, data.Name)
+		kinds = append(kinds, data.Kind)
+	}
+	require.Equal([]string{"TestAdd", "Test", "TestMain", "BenchmarkAdd"}, names)
+	require.Equal([]string{testLens, testLens, testLens, benchmarkLens}, kinds)
+	require.Equal(4, lenses[0].Range.Start.Line)
+
+	f, err = parser.ParseFile(fset, "/a/p.go", src, 0)
+	require.NoError(err)
+	require.Empty(testCodeLenses(fset, f, "file:///a/p.go"))
+}
+
+func TestTestLensCommand(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cmd := testLensCommand(testLens, "file:///a/p_test.go", "TestAdd")
+	require.Equal("run test", cmd.Title)
+	require.Equal(testCommand, cmd.Command)
+	require.Equal([]interface{}{"/a", "TestAdd"}, cmd.Arguments)
+
+	cmd = testLensCommand(benchmarkLens, "file:///a/p_test.go", "BenchmarkAdd")
+	require.Equal("run benchmark", cmd.Title)
+}
+
+func TestTestArgs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.Equal([]string{"test", "-run", "^TestAdd$"}, testArgs("TestAdd"))
+	require.Equal([]string{"test", "-run", "^$", "-bench", "^BenchmarkAdd$"}, testArgs("BenchmarkAdd"))
+}
@@ -0,0 +1,69 @@
+package langserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// goModFiles returns the go.mod files of the modules of the workspace
+// rootPath, skipping the vendor and testdata directories and the ones the
+// go command ignores.
+func goModFiles(rootPath string) []string {
+	var files []string
+	_ = filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != rootPath && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == "go.mod" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// goVersionDiagnostics returns the diagnostics of the go.mod files of the
+// workspace rootPath whose go directive is newer than the go command of the
+// workspace, goVersion, or than the Go version bingo is built with, which
+// type-checks the packages.
+func goVersionDiagnostics(rootPath, goVersion string) map[string][]lsp.Diagnostic {
+	diagnostics := map[string][]lsp.Diagnostic{}
+	for _, goMod := range goModFiles(rootPath) {
+		version, line, err := cache.GoModVersion(goMod)
+		if err != nil || version == "" {
+			continue
+		}
+		var problems []string
+		if goVersion != "" && cache.CompareGoVersions(version, goVersion) > 0 {
+			problems = append(problems, fmt.Sprintf("the module requires %s, but the go command is %s", version, goVersion))
+		}
+		if cache.CompareGoVersions(version, runtime.Version()) > 0 {
+			problems = append(problems, fmt.Sprintf("the module requires %s, but bingo is built with %s, which cannot type-check it", version, runtime.Version()))
+		}
+		for _, problem := range problems {
+			diagnostics[goMod] = append(diagnostics[goMod], lsp.Diagnostic{
+				Range: lsp.Range{
+					Start: lsp.Position{Line: line},
+					End:   lsp.Position{Line: line + 1},
+				},
+				Severity: lsp.Warning,
+				Source:   "goversion",
+				Message:  problem,
+			})
+		}
+	}
+	return diagnostics
+}
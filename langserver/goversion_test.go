@@ -0,0 +1,35 @@
+package langserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoVersionDiagnostics(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "bingo-goversion")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filename)
+		require.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(ioutil.WriteFile(filename, []byte(content), 0644))
+	}
+	write("go.mod", "module example.com/root\n\ngo 1.16\n")
+	write("tools/go.mod", "module example.com/tools\n\ngo 1.20\n")
+	write("vendor/example.com/v/go.mod", "module example.com/v\n\ngo 1.99\n")
+
+	diagnostics := goVersionDiagnostics(dir, "go1.18.4")
+	require.Len(diagnostics, 1)
+	tools := diagnostics[filepath.Join(dir, "tools", "go.mod")]
+	require.Len(tools, 1)
+	require.Equal(2, tools[0].Range.Start.Line)
+	require.Contains(tools[0].Message, "requires go1.20, but the go command is go1.18.4")
+}
@@ -3,15 +3,15 @@ package langserver
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
-	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/tools/imports"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	lsp "github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/go-lsp/lspext"
 	"github.com/sourcegraph/jsonrpc2"
@@ -21,9 +21,23 @@ import (
 
 // NewHandler creates a Go language server handler.
 func NewHandler(defaultCfg Config) jsonrpc2.Handler {
+	return NewSharedHandler(defaultCfg, nil)
+}
+
+// NewSharedHandler creates a Go language server handler which shares the
+// projects of pool with the other handlers of the pool. A nil pool shares
+// nothing.
+func NewSharedHandler(defaultCfg Config, pool *ProjectPool) jsonrpc2.Handler {
 	return lspHandler{jsonrpc2.HandlerWithError((&LangHandler{
 		DefaultConfig: defaultCfg,
 		HandlerShared: &HandlerShared{},
+		pool:          pool,
+		limiter:       newLimiter(defaultCfg.MaxRequestsPerSecond),
+		slowRequests:  newSlowRequests(defaultCfg.SlowRequestThreshold, defaultCfg.SlowRequestProfileDir),
+		memo:          newMemo(),
+		scratch:       newScratchDocuments(),
+		notebooks:     newNotebookDocuments(),
+		auditLog:      openAuditLog(defaultCfg.AuditLog),
 	}).handle)}
 }
 
@@ -44,7 +58,7 @@ type lspHandler struct {
 
 // Handle implements jsonrpc2.Handler
 func (h lspHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	if isFileSystemRequest(req.Method) {
+	if isFileSystemRequest(req.Method) || isNotebookRequest(req.Method) || req.Method == "workspace/didChangeConfiguration" {
 		h.Handler.Handle(ctx, conn, req)
 		return
 	}
@@ -59,9 +73,40 @@ type LangHandler struct {
 	init *InitializeParams // set by "initialize" request
 
 	project *cache.Project
+	pool    *ProjectPool
+
+	// releaseProject detaches the handler from project, which is closed once
+	// it has no more clients.
+	releaseProject func()
 
 	cancel *cancel
 
+	// negotiated is the protocol of the client, set by initialize.
+	negotiated clientProtocol
+
+	// confinement is the policy of the files the requests may refer to,
+	// set once the project is initialized.
+	confinement *confinement
+
+	limiter      *limiter
+	memo         *memo
+	scratch      *scratchDocuments
+	notebooks    *notebookDocuments
+	slowRequests *slowRequests
+	auditLog     *auditLog
+
+	// retainedEdits are the edits previewed by bingo.previewEdit, which
+	// bingo.applyEdit applies.
+	retainedEdits retainedEdits
+
+	// indexing is the project being initialized, whose loading may be
+	// canceled while doInit holds mu.
+	indexing indexingProject
+
+	// healthState is the state reported by bingo/health, which is answered
+	// while doInit holds mu.
+	healthState healthState
+
 	// DefaultConfig is the default values used for configuration. It is
 	// combined with InitializationOptions after initialize. This should be
 	// set by LangHandler creators. Please read config instead.
@@ -81,28 +126,107 @@ func (h *LangHandler) doInit(ctx context.Context, conn *jsonrpc2.Conn, init *Ini
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	config := h.DefaultConfig.Apply(init.InitializationOptions)
+	config := h.DefaultConfig.Apply(init.InitializationOptions).readOnly()
 	h.config = &config
 	imports.LocalPrefix = h.config.GoimportsLocalPrefix
 	h.init = init
+	h.negotiated = negotiateProtocol(init.Capabilities)
 	h.cancel = NewCancel()
 
+	if _, err := cache.ParseLoadProfile(h.config.GlobalCacheProfile); err != nil {
+		return err
+	}
+
 	rootPath := h.FilePath(init.Root())
-	buildFlags := []string{}
-	if len(h.config.BuildTags) > 0 {
-		buildFlags = append(buildFlags, "-tags", strings.Join(h.config.BuildTags, " "))
+	var initProject func() error
+	h.project, initProject, h.releaseProject = h.newProject(ctx, conn, rootPath, h.config, init.WorkDoneToken)
+	h.indexing.set(h.project)
+	h.healthState.set(h.project, h.config.Offline)
+	traceProject(h.project, 1)
+	h.scratch.reset(h.project)
+	h.notebooks.reset(h.project)
+	session := newSession(h.config.SessionFile)
+	h.overlay = newOverlay(h.notebooks.conn(h.scratch.conn(h.auditConn(conn))), h.project, h.config.diagnosticsStyle(), newSeverityMap(h.config.DiagnosticsSeverity), newAnalyzers(h.config.Analyses), h.config.buildVariants(), h.nolintMarker(), newFrameworks(h.config.Frameworks), loadTagSchema(h.config.tagSchemaFile(rootPath)), newBoilerplate(h.config), session, newDiagnosticsHistory(h.config.DiagnosticsHistory), newDiagnosticsPull(init.Capabilities), newTokenStream(h.config.TokenStream))
+	overlay := h.overlay
+	go func() {
+		<-conn.DisconnectNotify()
+		overlay.close()
+	}()
+	if h.config.KeepaliveInterval > 0 {
+		go keepalive(conn, time.Duration(h.config.KeepaliveInterval)*time.Second)
 	}
-	h.project = cache.NewProject(ctx, conn, rootPath, buildFlags)
-	h.overlay = newOverlay(conn, h.project, DiagnosticsStyleEnum(h.DefaultConfig.DiagnosticsStyle))
-	if err := h.project.Init(ctx, cache.CacheStyle(h.DefaultConfig.GlobalCacheStyle)); err != nil {
+	if err := initProject(); err != nil {
 		return err
 	}
+	h.confinement = newConfinement(rootPath, h.config.AllowedRoots)
+	h.healthState.setInitialized()
+	h.publishWorkspaceDiagnostics(ctx, conn, rootPath)
+	warmSession(context.Background(), h.project, session)
 	return nil
 }
 
+// newProject returns the project of the workspace rootPath with the build
+// flags and the settings of config, a function which initializes it, and a
+// function which releases it. The project is shared with the other clients of
+// the pool with the same configuration, if any. Its indexing progress is
+// reported on token.
+func (h *LangHandler) newProject(ctx context.Context, conn *jsonrpc2.Conn, rootPath string, config *Config, token protocol.ProgressToken) (*cache.Project, func() error, func()) {
+	style := cache.CacheStyle(h.DefaultConfig.GlobalCacheStyle)
+	configure := func(project *cache.Project) {
+		project.SetMemoryLimit(int64(config.GlobalCacheMemory) << 20)
+		project.SetLoadProfile(cache.LoadProfile(config.GlobalCacheProfile))
+		project.SetProgress(cache.ProgressStyle(config.IndexingProgress), token)
+	}
+	if h.pool != nil {
+		settings := fmt.Sprintf("%d %s %s", config.GlobalCacheMemory, config.GlobalCacheProfile, config.IndexingProgress)
+		return h.pool.acquire(ctx, conn, rootPath, config.buildFlags(), config.workspaceEnv(rootPath), settings, style, configure)
+	}
+
+	project := cache.NewProject(ctx, conn, rootPath, config.buildFlags(), config.workspaceEnv(rootPath))
+	configure(project)
+	var once sync.Once
+	release := func() {
+		once.Do(project.Close)
+	}
+	go func() {
+		<-conn.DisconnectNotify()
+		release()
+	}()
+	return project, func() error { return project.Init(ctx, style) }, release
+}
+
 // handle implements jsonrpc2.Handler.
 func (h *LangHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result interface{}, err error) {
-	return h.Handle(ctx, conn, req)
+	if req.Method == "window/workDoneProgress/cancel" {
+		return nil, h.indexing.cancel(req)
+	}
+	if req.Method == "bingo/health" {
+		return h.health(), nil
+	}
+	if req.Method == pingMethod {
+		return req.Params, nil
+	}
+
+	audited := req
+	req = h.scratch.request(req)
+	req, cell := h.notebooks.request(req)
+	ctx, slow := h.slowRequests.start(ctx, req)
+	result, err = h.limiter.do(ctx, req, func() (interface{}, error) {
+		return h.memoize(req, func() (interface{}, error) {
+			return h.Handle(ctx, conn, req)
+		})
+	})
+	if err != nil {
+		h.auditRequest(audited, nil, err)
+		_, err = slow.end(nil, err)
+		return nil, responseError(err)
+	}
+	result, err = h.scratch.result(result)
+	if err == nil {
+		result, err = h.notebooks.result(cell, result)
+	}
+	h.auditRequest(audited, result, err)
+	return slow.end(result, err)
 }
 
 // Handle creates a response for a JSONRPC2 LSP request. Note: LSP has strict
@@ -110,10 +234,17 @@ func (h *LangHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *json
 // jsonrpc2.AsyncHandler. Ensure you have the same ordering as used in the
 // NewHandler implementation.
 func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) (result interface{}, err error) {
+	// The latency is recorded once a panic has been turned into err.
+	start := time.Now()
+	defer trackRequest(req.Method, start)()
+	defer func() {
+		traceRequest(req.Method, start, err)
+	}()
+
 	// Prevent any uncaught panics from taking the entire server down.
 	defer func() {
 		if perr := util.Panicf(recover(), "%v", req.Method); perr != nil {
-			err = perr
+			err = panicError(perr)
 		}
 	}()
 
@@ -122,8 +253,11 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 	cancelManager = h.cancel
 	if req.Method != "initialize" && h.init == nil {
 		h.mu.Unlock()
-		return nil, errors.New("server must be initialized")
+		return nil, requestErrorf(NotInitializedError, "server must be initialized")
 	}
+	config := h.config
+	confinement := h.confinement
+	negotiated := h.negotiated
 	h.mu.Unlock()
 	if err := h.CheckReady(); err != nil {
 		if req.Method == "exit" {
@@ -132,17 +266,27 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		return nil, err
 	}
 
+	if config != nil && !config.methodEnabled(req.Method) {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: fmt.Sprintf("method disabled: %s", req.Method)}
+	}
+	if err := confinement.check(req); err != nil {
+		return nil, err
+	}
+
 	// Notifications don't have an ID, so they can't be cancelled
 	if cancelManager != nil && !req.Notif {
 		var cancel func()
 		ctx, cancel = cancelManager.WithCancel(ctx, req.ID)
 		defer cancel()
+		if supersededMethods[req.Method] {
+			cancelManager.Supersede(req.Method, req.ID)
+		}
 	}
 
 	switch req.Method {
 	case "initialize":
 		if h.init != nil {
-			return nil, errors.New("language server is already initialized")
+			return nil, requestErrorf(RefusedError, "language server is already initialized")
 		}
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -165,37 +309,67 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		kind := lsp.TDSKIncremental
 		completionOp := &lsp.CompletionOptions{TriggerCharacters: []string{"."}}
 
-		return lsp.InitializeResult{
-			Capabilities: lsp.ServerCapabilities{
-				TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
-					Kind:    &kind,
-					Options: &lsp.TextDocumentSyncOptions{OpenClose: true},
-				},
-				CodeActionProvider:              false,
-				CompletionProvider:              completionOp,
-				DefinitionProvider:              true,
-				TypeDefinitionProvider:          true,
-				DocumentFormattingProvider:      true,
-				DocumentRangeFormattingProvider: true,
-				DocumentSymbolProvider:          true,
-				HoverProvider:                   true,
-				ReferencesProvider:              true,
-				RenameProvider:                  true,
-				WorkspaceSymbolProvider:         true,
-				ImplementationProvider:          true,
-				XWorkspaceReferencesProvider:    true,
-				XDefinitionProvider:             true,
-				XWorkspaceSymbolByProperties:    true,
-				SignatureHelpProvider:           &lsp.SignatureHelpOptions{TriggerCharacters: []string{"(", ","}},
+		capabilities := ServerCapabilities{}
+		capabilities.ServerCapabilities = lsp.ServerCapabilities{
+			TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
+				Kind:    &kind,
+				Options: &lsp.TextDocumentSyncOptions{OpenClose: true},
 			},
-		}, nil
+			CodeActionProvider:              true,
+			CompletionProvider:              completionOp,
+			DefinitionProvider:              true,
+			TypeDefinitionProvider:          true,
+			DocumentFormattingProvider:      true,
+			DocumentRangeFormattingProvider: true,
+			DocumentSymbolProvider:          true,
+			DocumentHighlightProvider:       true,
+			HoverProvider:                   true,
+			ReferencesProvider:              true,
+			WorkspaceSymbolProvider:         true,
+			ImplementationProvider:          true,
+			XWorkspaceReferencesProvider:    true,
+			XDefinitionProvider:             true,
+			XWorkspaceSymbolByProperties:    true,
+			SignatureHelpProvider:           &lsp.SignatureHelpOptions{TriggerCharacters: []string{"(", ","}},
+		}
+		capabilities.ColorProvider = h.config.DocumentColor
+		capabilities.CallHierarchyProvider = true
+		capabilities.FoldingRangeProvider = true
+		capabilities.InlineCompletionProvider = len(newInlineCompletionProviders(h.config.InlineCompletion)) > 0
+		capabilities.NotebookDocumentSync = &protocol.NotebookDocumentSyncOptions{
+			NotebookSelector: []protocol.NotebookSelector{{Cells: []protocol.NotebookCellLanguage{{Language: "go"}}}},
+			Save:             true,
+		}
+		if h.overlay.pull != nil && h.config.diagnosticsStyle() != noneDiagnostics {
+			capabilities.DiagnosticProvider = &protocol.DiagnosticOptions{Identifier: "bingo", InterFileDependencies: true, WorkspaceDiagnostics: true}
+		}
+		capabilities.RenameProvider = true
+		if params.Capabilities.TextDocument.Rename.PrepareSupport {
+			capabilities.RenameProvider = &protocol.RenameOptions{PrepareProvider: true}
+		}
+		if h.config.ReferencesCodeLens || h.config.ImplementationCodeLens || h.config.RunCodeLens || h.config.TestCodeLens || h.config.EnumCodeLens {
+			capabilities.CodeLensProvider = &lsp.CodeLensOptions{ResolveProvider: true}
+		}
+		commands := []string{statusCommand, callGraphCommand, panicsCommand, taintCommand, enumCommand, mockCommand, jsonToStructCommand, structToJSONCommand, clonesCommand, rewriteCommand, promoteVariableCommand, previewEditCommand, applyEditCommand, fixImportsCommand, fixAllCommand, playgroundShareCommand, importersCommand}
+		if h.config.RunCodeLens {
+			commands = append(commands, runCommand)
+		}
+		if h.config.TestCodeLens {
+			commands = append(commands, testCommand)
+		}
+		capabilities.ExecuteCommandProvider = &lsp.ExecuteCommandOptions{Commands: h.config.availableCommands(commands)}
+		h.config.disableCapabilities(&capabilities)
+
+		return InitializeResult{Capabilities: capabilities}, nil
 
 	case "initialized":
-		// A notification that the client is ready to receive requests. Ignore
+		// A notification that the client is ready to receive requests.
+		h.registerWatchers(ctx, conn)
 		return nil, nil
 
 	case "shutdown":
 		h.ShutDown()
+		traceProject(h.project, -1)
 		return nil, nil
 
 	case "exit":
@@ -204,6 +378,27 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return nil, nil
 
+	case "workspace/didChangeConfiguration":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.DidChangeConfigurationParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, h.handleDidChangeConfiguration(ctx, conn, req, params)
+
+	case "workspace/didChangeWatchedFiles":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.DidChangeWatchedFilesParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		h.project.DidChangeWatchedFiles(params.Changes)
+		return nil, nil
+
 	case "$/cancelRequest":
 		// notification, don't send back results/errors
 		if req.Params == nil {
@@ -231,7 +426,11 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		if err := json.Unmarshal(*req.Params, &params); err != nil {
 			return nil, err
 		}
-		return h.handleHover(ctx, conn, req, params)
+		hover, err := h.handleHover(ctx, conn, req, params)
+		if err != nil {
+			return nil, err
+		}
+		return negotiated.hover(hover), nil
 
 	case "textDocument/definition":
 		if req.Params == nil {
@@ -273,6 +472,16 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleTextDocumentCompletion(ctx, conn, req, params)
 
+	case "textDocument/inlineCompletion":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.InlineCompletionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentInlineCompletion(ctx, conn, req, params)
+
 	case "textDocument/references":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -283,6 +492,46 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleTextDocumentReferences(ctx, conn, req, params)
 
+	case "textDocument/documentHighlight":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentHighlight(ctx, conn, req, params)
+
+	case "textDocument/prepareCallHierarchy":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handlePrepareCallHierarchy(ctx, conn, req, params)
+
+	case "callHierarchy/incomingCalls":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.CallHierarchyIncomingCallsParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleIncomingCalls(ctx, conn, req, params)
+
+	case "callHierarchy/outgoingCalls":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.CallHierarchyOutgoingCallsParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleOutgoingCalls(ctx, conn, req, params)
+
 	case "textDocument/implementation":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -301,6 +550,9 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		if err := json.Unmarshal(*req.Params, &params); err != nil {
 			return nil, err
 		}
+		if negotiated.hierarchicalSymbols {
+			return h.handleDocumentSymbolTree(ctx, params)
+		}
 		return h.handleTextDocumentSymbol(ctx, conn, req, params)
 
 	case "textDocument/signatureHelp":
@@ -361,7 +613,24 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		if err := json.Unmarshal(*req.Params, &params); err != nil {
 			return nil, err
 		}
-		return h.handleRename(ctx, conn, req, params)
+		edit, err := h.handleRename(ctx, conn, req, params)
+		if err != nil {
+			return nil, err
+		}
+		if rename := h.renameFileOperation(ctx, params); rename != nil {
+			return withResourceOperation(edit, rename), nil
+		}
+		return edit, nil
+
+	case "textDocument/prepareRename":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handlePrepareRename(ctx, conn, req, params)
 
 	case "textDocument/codeAction":
 		if req.Params == nil {
@@ -374,9 +643,159 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 
 		return h.handleCodeAction(ctx, conn, req, params)
 
+	case "textDocument/diagnostic":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.DocumentDiagnosticParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleDocumentDiagnostic(ctx, conn, req, params)
+
+	case "workspace/diagnostic":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.WorkspaceDiagnosticParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleWorkspaceDiagnostic(ctx, conn, req, params)
+
+	case "textDocument/foldingRange":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.FoldingRangeParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleFoldingRange(ctx, conn, req, params)
+
+	case "textDocument/documentColor":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.DocumentColorParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleDocumentColor(ctx, conn, req, params)
+
+	case "textDocument/colorPresentation":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.ColorPresentationParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleColorPresentation(ctx, conn, req, params)
+
+	case "textDocument/codeLens":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.CodeLensParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleCodeLens(ctx, conn, req, params)
+
+	case "codeLens/resolve":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.CodeLens
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleCodeLensResolve(ctx, conn, req, params)
+
+	case "workspace/executeCommand":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.ExecuteCommandParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleExecuteCommand(ctx, h.auditConn(conn), req, params)
+
+	case "bingo/metrics":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params MetricsParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleMetrics(ctx, conn, req, params)
+
+	case "bingo/memoryUsage":
+		return h.handleMemoryUsage(ctx, conn, req)
+
+	case "bingo/debug":
+		return h.handleDebug(ctx, conn, req)
+
+	case "bingo/diagnosticsHistory":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params DiagnosticsHistoryParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleDiagnosticsHistory(ctx, conn, req, params)
+
+	case "bingo/providerUsages":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleProviderUsages(ctx, conn, req, params)
+
+	case "bingo/searchAST":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params SearchASTParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleSearchAST(ctx, conn, req, params)
+
+	case "bingo/coverage":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params CoverageParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleCoverage(ctx, conn, req, params)
+
+	case "bingo/packageDoc":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params PackageDocParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handlePackageDoc(ctx, conn, req, params)
+
 	default:
 		if isFileSystemRequest(req.Method) {
 			err := h.handleFileSystemRequest(ctx, req)
+			if err == nil && req.Method == "textDocument/didSave" {
+				h.fixOnSave(ctx, h.auditConn(conn), req)
+				h.coverageOnSave(ctx, conn, req)
+			}
 			return nil, err
 		}
 
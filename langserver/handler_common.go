@@ -1,7 +1,6 @@
 package langserver
 
 import (
-	"errors"
 	"log"
 	"sync"
 )
@@ -31,7 +30,7 @@ func (h *HandlerCommon) CheckReady() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	if h.shutdown {
-		return errors.New("server is shutting down")
+		return requestErrorf(UnavailableError, "server is shutting down")
 	}
 	return nil
 }
@@ -0,0 +1,155 @@
+package langserver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+)
+
+// stalledRequestAge is the age of the oldest running request from which the
+// server is reported as stalled by bingo/health.
+const stalledRequestAge = time.Minute
+
+// The degraded modes reported by bingo/health.
+const (
+	// offlineMode is the offline mode, see Config.Offline.
+	offlineMode = "offline"
+
+	// missingModulesMode is a workspace which requires modules missing from
+	// the module cache, whose packages are not loaded.
+	missingModulesMode = "missingModules"
+
+	// partialIndexMode is a workspace whose loading was canceled, whose
+	// packages are partly loaded.
+	partialIndexMode = "partialIndex"
+)
+
+// Health is the result of the bingo/health request, for the clients which
+// wait for the server to be ready or restart it when it is stalled.
+type Health struct {
+	// Ready reports whether the server is initialized, its packages are
+	// loaded and it is not shutting down.
+	Ready        bool `json:"ready"`
+	Initialized  bool `json:"initialized"`
+	Indexing     bool `json:"indexing"`
+	ShuttingDown bool `json:"shuttingDown"`
+
+	// Progress is the percentage of the files of the workspace parsed
+	// while it is indexed, when the progress is reported, see
+	// --indexing-progress. It is 100 once the server is initialized.
+	Progress int `json:"progress"`
+
+	// Degraded are the degraded modes of the server: "offline",
+	// "missingModules" and "partialIndex".
+	Degraded []string `json:"degraded"`
+
+	// RunningRequests is the number of the requests and notifications being
+	// handled, and OldestRequestMillis the age of the oldest one.
+	RunningRequests     int   `json:"runningRequests"`
+	OldestRequestMillis int64 `json:"oldestRequestMillis"`
+
+	// Stalled reports whether a request has been running for more than a
+	// minute: the server is likely wedged.
+	Stalled bool `json:"stalled"`
+}
+
+// healthState is the state of the handler reported by bingo/health, which
+// is answered while the handler is being initialized. It does not wait for
+// the locks of the view, which are held while type-checking.
+type healthState struct {
+	mu          sync.Mutex
+	project     *cache.Project
+	offline     bool
+	initialized bool
+}
+
+func (s *healthState) set(project *cache.Project, offline bool) {
+	s.mu.Lock()
+	s.project, s.offline, s.initialized = project, offline, false
+	s.mu.Unlock()
+}
+
+func (s *healthState) setInitialized() {
+	s.mu.Lock()
+	s.initialized = true
+	s.mu.Unlock()
+}
+
+// runningRequest is a request being handled.
+type runningRequest struct {
+	method string
+	start  time.Time
+}
+
+// running holds the requests being handled by the handlers of the process.
+var running = struct {
+	mu       sync.Mutex
+	next     int
+	requests map[int]runningRequest
+}{requests: map[int]runningRequest{}}
+
+// trackRequest records the start of a request of method, and returns the
+// function which records its end.
+func trackRequest(method string, start time.Time) func() {
+	running.mu.Lock()
+	id := running.next
+	running.next++
+	running.requests[id] = runningRequest{method: method, start: start}
+	running.mu.Unlock()
+
+	return func() {
+		running.mu.Lock()
+		delete(running.requests, id)
+		running.mu.Unlock()
+	}
+}
+
+// health returns the health of the handler h.
+func (h *LangHandler) health() *Health {
+	h.healthState.mu.Lock()
+	project, offline, initialized := h.healthState.project, h.healthState.offline, h.healthState.initialized
+	h.healthState.mu.Unlock()
+
+	health := &Health{
+		Initialized:  initialized,
+		ShuttingDown: h.CheckReady() != nil,
+		Degraded:     []string{},
+	}
+	if offline {
+		health.Degraded = append(health.Degraded, offlineMode)
+	}
+	if project != nil {
+		status := project.IndexingStatus()
+		health.Indexing = status.Running
+		if status.Running {
+			health.Progress = status.Percentage
+		} else if initialized {
+			health.Progress = 100
+		}
+		if len(project.MissingModules()) > 0 {
+			health.Degraded = append(health.Degraded, missingModulesMode)
+		}
+		if status.Canceled {
+			health.Degraded = append(health.Degraded, partialIndexMode)
+		}
+	}
+	health.Ready = health.Initialized && !health.Indexing && !health.ShuttingDown
+
+	// The initialization takes as long as the loading of the packages,
+	// which is reported by Indexing instead.
+	now := time.Now()
+	running.mu.Lock()
+	for _, req := range running.requests {
+		if req.method == "initialize" {
+			continue
+		}
+		health.RunningRequests++
+		if millis := int64(now.Sub(req.start) / time.Millisecond); millis > health.OldestRequestMillis {
+			health.OldestRequestMillis = millis
+		}
+	}
+	running.mu.Unlock()
+	health.Stalled = time.Duration(health.OldestRequestMillis)*time.Millisecond > stalledRequestAge
+	return health
+}
@@ -0,0 +1,49 @@
+package langserver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	h := &LangHandler{}
+	health := h.health()
+	require.False(health.Ready)
+	require.False(health.Initialized)
+
+	dir, err := ioutil.TempDir("", "bingo-health")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	h.healthState.set(cache.NewProject(context.Background(), nil, dir, nil, nil), true)
+	require.False(h.health().Ready, "the project is being initialized")
+	h.healthState.setInitialized()
+	health = h.health()
+	require.True(health.Ready)
+	require.Equal(100, health.Progress)
+	require.Equal([]string{offlineMode}, health.Degraded)
+
+	// A long initialization is not a stalled server, unlike the other
+	// requests.
+	done := trackRequest("initialize", time.Now().Add(-2*time.Minute))
+	require.False(h.health().Stalled)
+	done()
+	done = trackRequest("textDocument/hover", time.Now().Add(-2*time.Minute))
+	health = h.health()
+	require.True(health.Stalled)
+	require.True(health.OldestRequestMillis >= 120000, "got %+v", health)
+	done()
+
+	h.ShutDown()
+	health = h.health()
+	require.True(health.ShuttingDown)
+	require.False(health.Ready)
+}
@@ -0,0 +1,101 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *LangHandler) handleTextDocumentHighlight(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]lsp.DocumentHighlight, error) {
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		// Invalid nodes means we tried to click on something which is
+		// not an ident (eg comment/string/etc). Return no information.
+		if _, ok := err.(*source.InvalidNodeError); ok {
+			return []lsp.DocumentHighlight{}, nil
+		}
+		return nil, err
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	var ident *ast.Ident
+	switch node := pathNodes[0].(type) {
+	case *ast.Ident:
+		ident = node
+	case *ast.FuncDecl:
+		ident = node.Name
+	default:
+		return []lsp.DocumentHighlight{}, nil
+	}
+	file, ok := pathNodes[len(pathNodes)-1].(*ast.File)
+	if !ok {
+		return []lsp.DocumentHighlight{}, nil
+	}
+
+	obj := source.FindIdentObject(pkg, ident)
+	if obj == nil {
+		return []lsp.DocumentHighlight{}, nil
+	}
+	return documentHighlights(pkg.GetFileSet(), pkg.GetTypesInfo(), file, obj), nil
+}
+
+// documentHighlights returns the occurrences of obj in file. The declarations
+// and the assignments of obj are writes, the other uses are reads.
+func documentHighlights(fset *token.FileSet, info *types.Info, file *ast.File, obj types.Object) []lsp.DocumentHighlight {
+	assigned := map[*ast.Ident]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					assigned[id] = true
+				}
+			}
+		case *ast.IncDecStmt:
+			if id, ok := n.X.(*ast.Ident); ok {
+				assigned[id] = true
+			}
+		case *ast.RangeStmt:
+			for _, x := range []ast.Expr{n.Key, n.Value} {
+				if id, ok := x.(*ast.Ident); ok {
+					assigned[id] = true
+				}
+			}
+		}
+		return true
+	})
+
+	highlights := []lsp.DocumentHighlight{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		kind := lsp.Read
+		switch {
+		case info.Defs[id] == obj:
+			kind = lsp.Write
+		case info.Uses[id] == obj:
+			if assigned[id] {
+				kind = lsp.Write
+			}
+		default:
+			return true
+		}
+		highlights = append(highlights, lsp.DocumentHighlight{
+			Range: rangeForNode(fset, id),
+			Kind:  kind,
+		})
+		return true
+	})
+	return highlights
+}
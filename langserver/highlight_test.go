@@ -0,0 +1,44 @@
+package langserver
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentHighlights(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	pkg := checkSource(t, `package p
+
+func count(xs []int) int {
+	n := 0
+	for _, x := range xs {
+		n += x
+		n++
+	}
+	return n
+}
+`)
+	fset, f, info := pkg.Fset, pkg.File, pkg.Info
+
+	var n types.Object
+	for id, obj := range info.Defs {
+		if id.Name == "n" {
+			n = obj
+		}
+	}
+	require.NotNil(n)
+
+	var got []int
+	var lines []int
+	for _, h := range documentHighlights(fset, info, f, n) {
+		got = append(got, h.Kind)
+		lines = append(lines, h.Range.Start.Line)
+	}
+	require.Equal([]int{lsp.Write, lsp.Write, lsp.Write, lsp.Read}, got)
+	require.Equal([]int{3, 5, 6, 8}, lines)
+}
@@ -0,0 +1,85 @@
+package langserver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// DiagnosticsHistoryParams is the parameter of the bingo/diagnosticsHistory
+// request.
+type DiagnosticsHistoryParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DiagnosticsSnapshot is a set of diagnostics published for a document.
+type DiagnosticsSnapshot struct {
+	// Version is the version of the document the diagnostics were computed
+	// for, 0 if the document was not open.
+	Version     int              `json:"version"`
+	Time        time.Time        `json:"time"`
+	Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+}
+
+// diagnosticsHistory keeps the last published sets of diagnostics of every
+// document, so that clients can show what changed between two versions of a
+// document and users can debug flapping findings.
+type diagnosticsHistory struct {
+	mu        sync.Mutex
+	size      int
+	snapshots map[lsp.DocumentURI][]DiagnosticsSnapshot
+
+	now func() time.Time
+}
+
+// newDiagnosticsHistory returns the history of the size last sets of
+// diagnostics of every document. A size of 0 disables the history, in which
+// case newDiagnosticsHistory returns nil.
+func newDiagnosticsHistory(size int) *diagnosticsHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &diagnosticsHistory{
+		size:      size,
+		snapshots: make(map[lsp.DocumentURI][]DiagnosticsSnapshot),
+		now:       time.Now,
+	}
+}
+
+// record adds the diagnostics published for the version of the document
+// uri to its history, dropping the oldest set beyond the size of h.
+func (h *diagnosticsHistory) record(uri lsp.DocumentURI, version int, diagnostics []lsp.Diagnostic) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snapshots := append(h.snapshots[uri], DiagnosticsSnapshot{
+		Version:     version,
+		Time:        h.now(),
+		Diagnostics: append([]lsp.Diagnostic{}, diagnostics...),
+	})
+	if len(snapshots) > h.size {
+		snapshots = append([]DiagnosticsSnapshot(nil), snapshots[len(snapshots)-h.size:]...)
+	}
+	h.snapshots[uri] = snapshots
+}
+
+// get returns the history of the document uri, the oldest set first.
+func (h *diagnosticsHistory) get(uri lsp.DocumentURI) []DiagnosticsSnapshot {
+	if h == nil {
+		return []DiagnosticsSnapshot{}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]DiagnosticsSnapshot{}, h.snapshots[uri]...)
+}
+
+func (h *LangHandler) handleDiagnosticsHistory(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params DiagnosticsHistoryParams) ([]DiagnosticsSnapshot, error) {
+	return h.overlay.history.get(params.TextDocument.URI), nil
+}
@@ -0,0 +1,40 @@
+package langserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticsHistory(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.Nil(newDiagnosticsHistory(0))
+	var disabled *diagnosticsHistory
+	disabled.record("file:///src/a.go", 1, nil)
+	require.Empty(disabled.get("file:///src/a.go"))
+
+	h := newDiagnosticsHistory(2)
+	start := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+	h.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	uri := lsp.DocumentURI("file:///src/a.go")
+	for version := 1; version <= 3; version++ {
+		h.record(uri, version, []lsp.Diagnostic{{Message: "undeclared name: x"}})
+	}
+	h.record("file:///src/b.go", 1, []lsp.Diagnostic{})
+
+	got := h.get(uri)
+	require.Len(got, 2)
+	require.Equal(2, got[0].Version)
+	require.Equal(3, got[1].Version)
+	require.Equal(start.Add(3*time.Second), got[1].Time)
+	require.Empty(h.get("file:///src/c.go"))
+}
@@ -79,7 +79,10 @@ func (h *LangHandler) hoverBasicLit(pkg source.Package, nodes []ast.Node, basicL
 
 	if node, ok := nodes[1].(*ast.ImportSpec); ok {
 		importPkg := pkg.GetImport(strings.Trim(node.Path.Value, `"`))
-		comments := source.PackageDoc(importPkg.GetSyntax(), importPkg.GetName())
+		if importPkg == nil {
+			return nil, nil
+		}
+		comments := packageSynopsis(importPkg)
 		r := rangeForNode(pkg.GetFileSet(), node)
 		return &lsp.Hover{
 			Contents: maybeAddComments(comments, []lsp.MarkedString{{Language: "go", Value: "package " + importPkg.GetName()}}),
@@ -156,11 +159,23 @@ func (h *LangHandler) hoverIdent(pkg source.Package, pathNodes []ast.Node, ident
 		s = types.TypeString(t, qf)
 	}
 
-	comments, err := source.FindComments(pkg, pkg.GetFileSet(), o, ident.Name)
-	if err != nil {
-		return nil, err
+	var comments string
+	if pkgName, ok := o.(*types.PkgName); ok {
+		// The full documentation is served by bingo/packageDoc.
+		comments = packageSynopsis(pkg.GetImport(pkgName.Imported().Path()))
+	} else {
+		var err error
+		comments, err = source.FindComments(pkg, pkg.GetFileSet(), o, ident.Name)
+		if err != nil {
+			return nil, err
+		}
 	}
 	contents := maybeAddComments(comments, []lsp.MarkedString{{Language: "go", Value: s}})
+	if c, ok := o.(*types.Const); ok && !isBuiltIn {
+		if group := iotaHover(pkg, c); group != "" {
+			contents = append(contents, lsp.RawMarkedString(group))
+		}
+	}
 	if extra != "" {
 		// If we have extra info, ensure it comes after the usually
 		// more useful documentation
@@ -182,7 +197,7 @@ func (h *LangHandler) packageStatement(pkg source.Package, ident *ast.Ident, pos
 			Range:    &r,
 		}, nil
 	}
-	return nil, fmt.Errorf("type/object not found at %+v", position)
+	return nil, requestErrorf(NotFoundError, "type/object not found at %+v", position)
 }
 
 // packageStatementName returns the package name ((*ast.Ident).Name)
@@ -301,7 +316,7 @@ func packageForFile(pkgs map[string]*ast.Package, filename string) (string, *ast
 			}
 		}
 	}
-	return "", nil, fmt.Errorf("failed to find %s in packages %v", filename, pkgs)
+	return "", nil, requestErrorf(StaleError, "failed to find %s in packages %v", filename, pkgs)
 }
 
 // inRange tells if x is in the range of a-b inclusive.
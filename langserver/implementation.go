@@ -2,7 +2,6 @@ package langserver
 
 import (
 	"context"
-	"errors"
 	"go/ast"
 	"go/types"
 	"sort"
@@ -47,7 +46,7 @@ func implements(project *cache.Project, pkg source.Package, path []ast.Node, act
 			if obj, ok := pkg.GetTypesInfo().ObjectOf(id).(*types.Func); ok {
 				recv := obj.Type().(*types.Signature).Recv()
 				if recv == nil {
-					return nil, errors.New("this function is not a method")
+					return nil, requestErrorf(InvalidPositionError, "this function is not a method")
 				}
 				method = obj
 				T = recv.Type()
@@ -63,7 +62,7 @@ func implements(project *cache.Project, pkg source.Package, path []ast.Node, act
 		T = pkg.GetTypesInfo().TypeOf(path[0].(ast.Expr))
 	}
 	if T == nil {
-		return nil, errors.New("not a type, method, or value")
+		return nil, requestErrorf(InvalidPositionError, "not a type, method, or value")
 	}
 
 	// Find all named types, even local types (which can have
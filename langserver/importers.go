@@ -0,0 +1,59 @@
+package langserver
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// importersCommand is the workspace/executeCommand command which lists the
+// packages importing a package.
+const importersCommand = "bingo.importers"
+
+// ImportersParams is the argument of the bingo.importers command. The
+// package is either the package path or the package of the document.
+type ImportersParams struct {
+	Package      string                     `json:"package,omitempty"`
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument,omitempty"`
+
+	// Transitive also lists the packages which import the package through
+	// other packages.
+	Transitive bool `json:"transitive,omitempty"`
+}
+
+// Importer is a package importing the package of bingo.importers.
+type Importer struct {
+	Package string `json:"package"`
+
+	// ID differs from Package for the test variants of the packages, e.g.
+	// "p [p.test]".
+	ID  string          `json:"id"`
+	Dir lsp.DocumentURI `json:"dir,omitempty"`
+}
+
+func (h *LangHandler) handleImporters(ctx context.Context, params ImportersParams) ([]Importer, error) {
+	pkgPath := params.Package
+	if pkgPath == "" {
+		if params.TextDocument.URI == "" {
+			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, "bingo.importers expects a package or a document")
+		}
+		pkg := h.project.GetFromURI(params.TextDocument.URI)
+		if pkg == nil {
+			return nil, requestErrorf(NotFoundError, "no package found for %s", params.TextDocument.URI)
+		}
+		pkgPath = pkg.GetPkgPath()
+	}
+
+	importers := []Importer{}
+	for _, pkg := range h.project.Cache().Importers(pkgPath, params.Transitive) {
+		importer := Importer{Package: pkg.GetPkgPath(), ID: pkg.GetID()}
+		if filenames := pkg.GetFilenames(); len(filenames) > 0 {
+			importer.Dir = lsp.DocumentURI(source.ToURI(filepath.Dir(filenames[0])))
+		}
+		importers = append(importers, importer)
+	}
+	return importers, nil
+}
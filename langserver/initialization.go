@@ -1,6 +1,9 @@
 package langserver
 
-import lsp "github.com/sourcegraph/go-lsp"
+import (
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	lsp "github.com/sourcegraph/go-lsp"
+)
 
 // This file contains Go-specific extensions to LSP types.
 //
@@ -19,11 +22,23 @@ type InitializationOptions struct {
 	// Defaults to false if not specified.
 	DiagnosticsStyle *string `json:"diagnosticsStyle"`
 
+	// DiagnosticsHistory is an optional version of Config.DiagnosticsHistory
+	DiagnosticsHistory *int `json:"diagnosticsHistory"`
+
 	// EnableGlobalCache enable global cache when hover, reference, definition. Can be overridden by InitializationOptions.
 	//
 	// Defaults to false if not specified
 	GlobalCacheStyle *string `json:"globalCacheStyle"`
 
+	// GlobalCacheMemory is an optional version of Config.GlobalCacheMemory
+	GlobalCacheMemory *int `json:"globalCacheMemory"`
+
+	// GlobalCacheProfile is an optional version of Config.GlobalCacheProfile
+	GlobalCacheProfile *string `json:"globalCacheProfile"`
+
+	// IndexingProgress is an optional version of Config.IndexingProgress
+	IndexingProgress *string `json:"indexingProgress"`
+
 	// FormatStyle format style
 	//
 	// Defaults to "gofmt" if not specified
@@ -43,13 +58,125 @@ type InitializationOptions struct {
 
 	// BuildTags is an optional version of Config.BuildTags
 	BuildTags []string `json:"buildTags"`
+
+	// BuildVariants is an optional version of Config.BuildVariants
+	BuildVariants []string `json:"buildVariants"`
+
+	// DisabledFeatures is an optional version of Config.DisabledFeatures
+	DisabledFeatures []string `json:"disabledFeatures"`
+
+	// DocumentColor is an optional version of Config.DocumentColor
+	DocumentColor *bool `json:"documentColor"`
+
+	// CoverageOnSave is an optional version of Config.CoverageOnSave
+	CoverageOnSave *bool `json:"coverageOnSave"`
+
+	// CoverageDiagnostics is an optional version of Config.CoverageDiagnostics
+	CoverageDiagnostics *bool `json:"coverageDiagnostics"`
+
+	// RenameFiles is an optional version of Config.RenameFiles
+	RenameFiles *bool `json:"renameFiles"`
+
+	// ReferencesCodeLens is an optional version of Config.ReferencesCodeLens
+	ReferencesCodeLens *bool `json:"referencesCodeLens"`
+
+	// ImplementationCodeLens is an optional version of Config.ImplementationCodeLens
+	ImplementationCodeLens *bool `json:"implementationCodeLens"`
+
+	// TaintSinks is an optional version of Config.TaintSinks
+	TaintSinks []string `json:"taintSinks"`
+
+	// FolderEnv is an optional version of Config.FolderEnv
+	FolderEnv map[string]map[string]string `json:"folderEnv"`
+
+	// DiagnosticsSeverity is an optional version of Config.DiagnosticsSeverity
+	DiagnosticsSeverity map[string]string `json:"diagnosticsSeverity"`
+
+	// CompleteUnexported is an optional version of Config.CompleteUnexported
+	CompleteUnexported *bool `json:"completeUnexported"`
+
+	// CompletionMatcher is an optional version of Config.CompletionMatcher
+	CompletionMatcher *string `json:"completionMatcher"`
+
+	// DeepCompletion is an optional version of Config.DeepCompletion
+	DeepCompletion *bool `json:"deepCompletion"`
+
+	// FixOnSave is an optional version of Config.FixOnSave
+	FixOnSave *string `json:"fixOnSave"`
+
+	// Analyses is an optional version of Config.Analyses
+	Analyses map[string]bool `json:"analyses"`
+
+	// NolintMarker is an optional version of Config.NolintMarker
+	NolintMarker *string `json:"nolintMarker"`
+
+	// TestCodeLens is an optional version of Config.TestCodeLens
+	TestCodeLens *bool `json:"testCodeLens"`
+
+	// GOROOT is an optional version of Config.GOROOT
+	GOROOT *string `json:"goroot"`
+
+	// PlaygroundURL is an optional version of Config.PlaygroundURL
+	PlaygroundURL *string `json:"playgroundURL"`
+
+	// Offline is an optional version of Config.Offline
+	Offline *bool `json:"offline"`
+
+	// WrapErrors is an optional version of Config.WrapErrors
+	WrapErrors *bool `json:"wrapErrors"`
+
+	// AutoPackageClause is an optional version of Config.AutoPackageClause
+	AutoPackageClause *bool `json:"autoPackageClause"`
+
+	// LicenseHeader is an optional version of Config.LicenseHeader
+	LicenseHeader *string `json:"licenseHeader"`
+
+	// TagSchemaFile is an optional version of Config.TagSchemaFile
+	TagSchemaFile *string `json:"tagSchemaFile"`
+
+	// RouteIndex is an optional version of Config.RouteIndex
+	RouteIndex *bool `json:"routeIndex"`
+
+	// Frameworks is an optional version of Config.Frameworks
+	Frameworks []string `json:"frameworks"`
+
+	// InlineCompletion is an optional version of Config.InlineCompletion
+	InlineCompletion []string `json:"inlineCompletion"`
+
+	// MockBackend is an optional version of Config.MockBackend
+	MockBackend *string `json:"mockBackend"`
+
+	// EnumCodeLens is an optional version of Config.EnumCodeLens
+	EnumCodeLens *bool `json:"enumCodeLens"`
+
+	// RunCodeLens is an optional version of Config.RunCodeLens
+	RunCodeLens *bool `json:"runCodeLens"`
+
+	// RunFlags is an optional version of Config.RunFlags
+	RunFlags []string `json:"runFlags"`
+
+	// RunEnv is an optional version of Config.RunEnv
+	RunEnv []string `json:"runEnv"`
+
+	// TokenStream is an optional version of Config.TokenStream
+	TokenStream *bool `json:"tokenStream"`
+
+	// SessionFile is an optional version of Config.SessionFile
+	SessionFile *string `json:"sessionFile"`
 }
 
 type InitializeParams struct {
 	lsp.InitializeParams
 
+	// Capabilities shadows the capabilities of lsp.InitializeParams.
+	Capabilities ClientCapabilities `json:"capabilities"`
+
 	InitializationOptions *InitializationOptions `json:"initializationOptions,omitempty"`
 
+	// WorkDoneToken is the token of the work done progress of the
+	// initialization, which reports the loading of the packages.
+	WorkDoneToken protocol.ProgressToken `json:"workDoneToken,omitempty"`
+
 	// TODO these should be InitializationOptions
 	// RootImportPath is the root Go import path for this
 	// workspace. For example,
@@ -57,3 +184,117 @@ type InitializeParams struct {
 	// path for "github.com/golang/tools".
 	RootImportPath string
 }
+
+// ClientCapabilities extends lsp.ClientCapabilities with the capabilities
+// that go-lsp does not define.
+type ClientCapabilities struct {
+	lsp.ClientCapabilities
+
+	Workspace WorkspaceClientCapabilities `json:"workspace,omitempty"`
+
+	TextDocument TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+}
+
+// TextDocumentClientCapabilities extends lsp.TextDocumentClientCapabilities
+// with the capabilities that go-lsp does not define.
+type TextDocumentClientCapabilities struct {
+	lsp.TextDocumentClientCapabilities
+
+	Rename struct {
+		// PrepareSupport is set if the client supports the
+		// textDocument/prepareRename request.
+		PrepareSupport bool `json:"prepareSupport,omitempty"`
+	} `json:"rename,omitempty"`
+
+	Hover struct {
+		// ContentFormat are the formats of the contents of the hovers which
+		// the client supports, the preferred one first. The clients which
+		// do not set it expect the lsp.MarkedString contents of go-lsp.
+		ContentFormat []protocol.MarkupKind `json:"contentFormat,omitempty"`
+	} `json:"hover,omitempty"`
+
+	DocumentSymbol struct {
+		// HierarchicalDocumentSymbolSupport is set if the client supports
+		// the protocol.DocumentSymbol results of textDocument/documentSymbol
+		// rather than the lsp.SymbolInformation ones.
+		HierarchicalDocumentSymbolSupport bool `json:"hierarchicalDocumentSymbolSupport,omitempty"`
+	} `json:"documentSymbol,omitempty"`
+
+	FoldingRange struct {
+		// LineFoldingOnly is set if the client ignores the start and the
+		// end characters of the folding ranges.
+		LineFoldingOnly bool `json:"lineFoldingOnly,omitempty"`
+	} `json:"foldingRange,omitempty"`
+
+	// Diagnostic is set if the client pulls the diagnostics of the
+	// documents with textDocument/diagnostic.
+	Diagnostic *struct {
+		DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+	} `json:"diagnostic,omitempty"`
+}
+
+// WorkspaceClientCapabilities are the workspace capabilities of the client.
+type WorkspaceClientCapabilities struct {
+	DidChangeWatchedFiles struct {
+		// DynamicRegistration is set if the client supports the
+		// registration of the watchers of workspace/didChangeWatchedFiles.
+		DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+	} `json:"didChangeWatchedFiles,omitempty"`
+
+	WorkspaceEdit struct {
+		// DocumentChanges is set if the client supports the versioned
+		// document changes of workspace edits.
+		DocumentChanges bool `json:"documentChanges,omitempty"`
+
+		// ResourceOperations lists the supported resource operations,
+		// such as "create", "rename" and "delete".
+		ResourceOperations []string `json:"resourceOperations,omitempty"`
+	} `json:"workspaceEdit,omitempty"`
+
+	Diagnostics struct {
+		// RefreshSupport is set if the client supports the
+		// workspace/diagnostic/refresh request.
+		RefreshSupport bool `json:"refreshSupport,omitempty"`
+	} `json:"diagnostics,omitempty"`
+}
+
+// ServerCapabilities extends lsp.ServerCapabilities with the capabilities
+// that go-lsp does not define.
+type ServerCapabilities struct {
+	lsp.ServerCapabilities
+
+	// ColorProvider is set if the server provides document colors.
+	ColorProvider bool `json:"colorProvider,omitempty"`
+
+	// CallHierarchyProvider is set if the server provides the call
+	// hierarchy of functions.
+	CallHierarchyProvider bool `json:"callHierarchyProvider,omitempty"`
+
+	// FoldingRangeProvider is set if the server provides the folding
+	// ranges of documents.
+	FoldingRangeProvider bool `json:"foldingRangeProvider,omitempty"`
+
+	// RenameProvider shadows the rename provider of lsp.ServerCapabilities,
+	// which is either a bool or the protocol.RenameOptions of the clients
+	// supporting textDocument/prepareRename.
+	RenameProvider interface{} `json:"renameProvider,omitempty"`
+
+	// DiagnosticProvider is set if the server answers the
+	// textDocument/diagnostic and workspace/diagnostic requests of the
+	// clients which pull the diagnostics.
+	DiagnosticProvider *protocol.DiagnosticOptions `json:"diagnosticProvider,omitempty"`
+
+	// InlineCompletionProvider is set if the server answers the
+	// textDocument/inlineCompletion requests.
+	InlineCompletionProvider bool `json:"inlineCompletionProvider,omitempty"`
+
+	// NotebookDocumentSync selects the notebooks whose Go cells are synced
+	// with the notebookDocument notifications.
+	NotebookDocumentSync *protocol.NotebookDocumentSyncOptions `json:"notebookDocumentSync,omitempty"`
+}
+
+// InitializeResult is lsp.InitializeResult with the extended
+// ServerCapabilities.
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
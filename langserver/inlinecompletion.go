@@ -0,0 +1,401 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// InlineCompletionProvider suggests the text completing the line being
+// typed, which the clients supporting textDocument/inlineCompletion show as
+// ghost text. The providers of Config.InlineCompletion are asked in order,
+// and only the first suggestion is returned.
+//
+// The built-in providers are "iferr", "structfill" and "template". The
+// programs embedding the language server plug other suggestion engines in
+// with RegisterInlineCompletionProvider.
+type InlineCompletionProvider interface {
+	// Name is the name of the provider in Config.InlineCompletion.
+	Name() string
+
+	// Suggest returns the text replacing req.Typed, which it starts with, or
+	// an empty string if it has no suggestion. It is called for most
+	// keystrokes, and should return quickly.
+	Suggest(ctx context.Context, req *InlineCompletionRequest) (string, error)
+}
+
+// InlineCompletionRequest is the position of an inline completion in the
+// type checked syntax of its package.
+type InlineCompletionRequest struct {
+	Fset  *token.FileSet
+	File  *ast.File
+	Types *types.Package
+	Info  *types.Info
+
+	// Pos is the position, and Path are the nodes enclosing it, from the
+	// innermost one to File.
+	Pos  token.Pos
+	Path []ast.Node
+
+	// Indent is the indentation of the line of the position, Typed is the
+	// text typed between the indentation and the position, and Rest is the
+	// text of the line after the position.
+	Indent string
+	Typed  string
+	Rest   string
+
+	// Qualifier qualifies the names of the other packages. The suggestions
+	// cannot import them, which is left to the client organizing the
+	// imports.
+	Qualifier types.Qualifier
+
+	// WrapErrors is Config.WrapErrors.
+	WrapErrors bool
+}
+
+// inlineCompletionProviders are the registered providers, by name.
+var inlineCompletionProviders = map[string]InlineCompletionProvider{}
+
+// RegisterInlineCompletionProvider registers a provider of inline
+// completions, which is asked for suggestions once Config.InlineCompletion
+// lists its name. It must be called before the server is started, eg. from
+// an init function.
+func RegisterInlineCompletionProvider(p InlineCompletionProvider) {
+	inlineCompletionProviders[p.Name()] = p
+}
+
+// defaultInlineCompletion are the providers asked when
+// Config.InlineCompletion is empty.
+var defaultInlineCompletion = []string{errorCheckLabel, structFillProviderName, templateProviderName}
+
+func init() {
+	RegisterInlineCompletionProvider(errorCheckProvider{})
+	RegisterInlineCompletionProvider(structFillProvider{})
+	RegisterInlineCompletionProvider(templateProvider{})
+}
+
+// newInlineCompletionProviders returns the registered providers of names.
+func newInlineCompletionProviders(names []string) []InlineCompletionProvider {
+	if len(names) == 0 {
+		names = defaultInlineCompletion
+	}
+	var providers []InlineCompletionProvider
+	for _, name := range names {
+		if p, ok := inlineCompletionProviders[name]; ok {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// handleTextDocumentInlineCompletion returns the first suggestion of the
+// providers at the position. No suggestion competes with the completion
+// item selected in the client.
+func (h *LangHandler) handleTextDocumentInlineCompletion(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.InlineCompletionParams) (*protocol.InlineCompletionList, error) {
+	result := &protocol.InlineCompletionList{Items: []protocol.InlineCompletionItem{}}
+	providers := newInlineCompletionProviders(h.config.InlineCompletion)
+	if params.Context.SelectedCompletionInfo != nil || len(providers) == 0 {
+		return result, nil
+	}
+
+	// The documents being typed do not type check more often than not,
+	// which is not worth an error.
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		return result, nil
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return result, nil
+	}
+	file, ok := pathNodes[len(pathNodes)-1].(*ast.File)
+	if !ok {
+		return result, nil
+	}
+	f, err := h.View().GetFile(ctx, span.FromDocumentURI(params.TextDocument.URI))
+	if err != nil {
+		return nil, err
+	}
+	content := string(f.GetContent(ctx))
+	lines := f.GetLineIndex(ctx)
+	start, offset := lines.Offset(params.Position.Line, 0), lines.Offset(params.Position.Line, params.Position.Character)
+	if start < 0 || offset < 0 {
+		return result, nil
+	}
+	end := strings.IndexByte(content[offset:], '\n')
+	if end < 0 {
+		end = len(content) - offset
+	}
+	line := content[start:offset]
+	typed := strings.TrimLeft(line, " \t")
+
+	r := &InlineCompletionRequest{
+		Fset:       pkg.GetFileSet(),
+		File:       file,
+		Types:      pkg.GetTypes(),
+		Info:       pkg.GetTypesInfo(),
+		Pos:        pos,
+		Path:       pathNodes,
+		Indent:     line[:len(line)-len(typed)],
+		Typed:      typed,
+		Rest:       strings.TrimRight(content[offset:offset+end], "\r"),
+		Qualifier:  newFileImporter(pkg.GetPkgPath(), file).qualifier,
+		WrapErrors: h.config.WrapErrors,
+	}
+	for _, p := range providers {
+		text, err := p.Suggest(ctx, r)
+		if err != nil {
+			log.Printf("inline completion provider %s: %v", p.Name(), err)
+			continue
+		}
+		if text == "" || text == typed || !strings.HasPrefix(text, typed) {
+			continue
+		}
+		rng := getLspRange(params.Position, utf16Len(typed))
+		result.Items = append(result.Items, protocol.InlineCompletionItem{InsertText: text, FilterText: typed, Range: &rng})
+		break
+	}
+	return result, nil
+}
+
+// blankRest reports whether the rest of the line of r is blank, which the
+// suggestions of statements require.
+func (r *InlineCompletionRequest) blankRest() bool {
+	return strings.TrimSpace(r.Rest) == ""
+}
+
+// errorCheckProvider suggests the error check of the assignment preceding
+// the position, like the iferr completion item.
+type errorCheckProvider struct{}
+
+func (errorCheckProvider) Name() string {
+	return errorCheckLabel
+}
+
+func (errorCheckProvider) Suggest(ctx context.Context, r *InlineCompletionRequest) (string, error) {
+	if !r.blankRest() {
+		return "", nil
+	}
+	assign, parents := precedingAssign(r.Path, r.Pos)
+	check := newErrorCheck(r.Info, assign, parents)
+	if check == nil {
+		return "", nil
+	}
+	return check.text(r.Qualifier, r.WrapErrors, r.Indent, false), nil
+}
+
+// structFillProviderName is the name of structFillProvider.
+const structFillProviderName = "structfill"
+
+// structFillProvider suggests the fields of the empty struct literal at the
+// position, with their zero values, one per line.
+type structFillProvider struct{}
+
+func (structFillProvider) Name() string {
+	return structFillProviderName
+}
+
+func (structFillProvider) Suggest(ctx context.Context, r *InlineCompletionRequest) (string, error) {
+	for _, n := range r.Path {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			continue
+		}
+		if len(lit.Elts) > 0 || r.Pos <= lit.Lbrace || r.Pos > lit.Rbrace {
+			return "", nil
+		}
+		t := r.Info.TypeOf(lit)
+		if t == nil {
+			return "", nil
+		}
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			return "", nil
+		}
+		var fields []string
+		for i := 0; i < st.NumFields(); i++ {
+			field := st.Field(i)
+			if field.Exported() || field.Pkg() == r.Types {
+				fields = append(fields, field.Name()+": "+zeroValue(field.Type(), r.Qualifier)+",")
+			}
+		}
+		if len(fields) == 0 {
+			return "", nil
+		}
+
+		// Between the braces of T{}, or on a blank line between the
+		// braces of a literal spanning lines.
+		if r.Pos == lit.Rbrace {
+			indent := "\n" + r.Indent + "\t"
+			return r.Typed + indent + strings.Join(fields, indent) + "\n" + r.Indent, nil
+		}
+		if r.Typed == "" && r.blankRest() {
+			return strings.Join(fields, "\n"+r.Indent), nil
+		}
+		return "", nil
+	}
+	return "", nil
+}
+
+// templateProviderName is the name of templateProvider.
+const templateProviderName = "template"
+
+// templateProvider suggests the statement which obviously follows the
+// preceding one, according to statementTemplates, unless the block already
+// has it.
+type templateProvider struct{}
+
+func (templateProvider) Name() string {
+	return templateProviderName
+}
+
+func (templateProvider) Suggest(ctx context.Context, r *InlineCompletionRequest) (string, error) {
+	if !r.blankRest() {
+		return "", nil
+	}
+	for _, n := range r.Path {
+		list, ok := blockStmts(n)
+		if !ok {
+			continue
+		}
+
+		// The statement being typed is not the preceding one, and neither
+		// is the error check of the preceding one.
+		i := 0
+		for i < len(list) && list[i].End() < r.Pos {
+			i++
+		}
+		preceding := i - 1
+		if preceding > 0 && isNilCheck(list[preceding]) {
+			preceding--
+		}
+		if preceding < 0 {
+			return "", nil
+		}
+		for _, template := range statementTemplates {
+			text := template(r.Info, list[preceding])
+			if text != "" && !hasStmt(list, text) {
+				return text, nil
+			}
+		}
+		return "", nil
+	}
+	return "", nil
+}
+
+// statementTemplates return the statement which obviously follows stmt, eg.
+// the deferred Close of the file it opens, or an empty string.
+var statementTemplates = []func(info *types.Info, stmt ast.Stmt) string{
+	deferUnlockTemplate,
+	deferCancelTemplate,
+	deferCloseTemplate,
+}
+
+// deferUnlockTemplate defers the Unlock of a Lock call, or the RUnlock of a
+// RLock call.
+func deferUnlockTemplate(info *types.Info, stmt ast.Stmt) string {
+	expr, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return ""
+	}
+	call, ok := expr.X.(*ast.CallExpr)
+	if !ok {
+		return ""
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || (sel.Sel.Name != "Lock" && sel.Sel.Name != "RLock") {
+		return ""
+	}
+	unlock := strings.TrimSuffix(sel.Sel.Name, "Lock") + "Unlock"
+	if !hasNiladicMethod(info.TypeOf(sel.X), unlock) {
+		return ""
+	}
+	return "defer " + types.ExprString(sel.X) + "." + unlock + "()"
+}
+
+// deferCancelTemplate defers the call of an assigned context.CancelFunc.
+func deferCancelTemplate(info *types.Info, stmt ast.Stmt) string {
+	assign, ok := stmt.(*ast.AssignStmt)
+	if !ok {
+		return ""
+	}
+	for _, lhs := range assign.Lhs {
+		id, ok := lhs.(*ast.Ident)
+		if !ok || id.Name == "_" {
+			continue
+		}
+		if named, ok := info.TypeOf(id).(*types.Named); ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "CancelFunc" {
+			return "defer " + id.Name + "()"
+		}
+	}
+	return ""
+}
+
+// deferCloseTemplate defers the Close of the first value returned by a
+// call, or the Close of the body of an *http.Response.
+func deferCloseTemplate(info *types.Info, stmt ast.Stmt) string {
+	assign, ok := stmt.(*ast.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return ""
+	}
+	if _, ok := assign.Rhs[0].(*ast.CallExpr); !ok {
+		return ""
+	}
+	id, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok || id.Name == "_" {
+		return ""
+	}
+	t := info.TypeOf(id)
+	if ptr, ok := t.(*types.Pointer); ok {
+		if named, ok := ptr.Elem().(*types.Named); ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "net/http" && named.Obj().Name() == "Response" {
+			return "defer " + id.Name + ".Body.Close()"
+		}
+	}
+	if !hasNiladicMethod(t, "Close") {
+		return ""
+	}
+	return "defer " + id.Name + ".Close()"
+}
+
+// hasNiladicMethod reports whether the addressable values of type t have a
+// method name without parameters.
+func hasNiladicMethod(t types.Type, name string) bool {
+	if t == nil {
+		return false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	fn, ok := obj.(*types.Func)
+	return ok && fn.Type().(*types.Signature).Params().Len() == 0
+}
+
+// isNilCheck reports whether stmt is `if x != nil`.
+func isNilCheck(stmt ast.Stmt) bool {
+	ifStmt, ok := stmt.(*ast.IfStmt)
+	if !ok {
+		return false
+	}
+	cond, ok := ifStmt.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return false
+	}
+	y, ok := cond.Y.(*ast.Ident)
+	return ok && y.Name == "nil"
+}
+
+// hasStmt reports whether list has the defer statement text.
+func hasStmt(list []ast.Stmt, text string) bool {
+	for _, stmt := range list {
+		if d, ok := stmt.(*ast.DeferStmt); ok && "defer "+types.ExprString(d.Call) == text {
+			return true
+		}
+	}
+	return false
+}
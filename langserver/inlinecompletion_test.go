@@ -0,0 +1,142 @@
+package langserver
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+type testInlineCompletionProvider struct{}
+
+func (testInlineCompletionProvider) Name() string {
+	return "test"
+}
+
+func (testInlineCompletionProvider) Suggest(ctx context.Context, r *InlineCompletionRequest) (string, error) {
+	return r.Typed + "// test", nil
+}
+
+func TestNewInlineCompletionProviders(t *testing.T) {
+	RegisterInlineCompletionProvider(testInlineCompletionProvider{})
+
+	var names []string
+	for _, p := range newInlineCompletionProviders(nil) {
+		names = append(names, p.Name())
+	}
+	require.Equal(t, []string{"iferr", "structfill", "template"}, names)
+
+	names = nil
+	for _, p := range newInlineCompletionProviders([]string{"test", "unknown", "iferr"}) {
+		names = append(names, p.Name())
+	}
+	require.Equal(t, []string{"test", "iferr"}, names)
+}
+
+func TestInlineCompletion(t *testing.T) {
+	t.Parallel()
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/inline\n",
+		"a.go":   "package inline\n",
+	})
+	tx := newWorkspaceContext(t, testConfig(cache.Ondemand), root)
+	uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go")))
+	require.NoError(t, tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: "package inline\n"},
+	}))
+
+	const header = `package inline
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+type point struct {
+	X, Y int
+	name string
+}
+
+`
+	tests := []struct {
+		name string
+		src  string // | is the position
+		want string
+	}{
+		{
+			name: "iferr",
+			src:  "func open(name string) (*os.File, error) {\n\tf, err := os.Open(name)\n\t|\n\treturn f, nil\n}\n",
+			want: "if err != nil {\n\t\treturn nil, err\n\t}",
+		},
+		{
+			name: "iferr typed",
+			src:  "func open(name string) (*os.File, error) {\n\tf, err := os.Open(name)\n\tif|\n\treturn f, nil\n}\n",
+			want: "if err != nil {\n\t\treturn nil, err\n\t}",
+		},
+		{
+			name: "close",
+			src:  "func open(name string) (*os.File, error) {\n\tf, err := os.Open(name)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n\t|\n\treturn f, nil\n}\n",
+			want: "defer f.Close()",
+		},
+		{
+			name: "unlock",
+			src:  "var mu sync.RWMutex\n\nfunc lock() {\n\tmu.RLock()\n\t|\n}\n",
+			want: "defer mu.RUnlock()",
+		},
+		{
+			name: "unlock deferred",
+			src:  "var mu sync.Mutex\n\nfunc lock() {\n\tmu.Lock()\n\t|\n\tdefer mu.Unlock()\n}\n",
+		},
+		{
+			name: "cancel",
+			src:  "func run() {\n\tctx, cancel := context.WithCancel(context.Background())\n\t|\n\t_ = ctx\n}\n",
+			want: "defer cancel()",
+		},
+		{
+			name: "structfill",
+			src:  "func origin() point {\n\tp := point{|}\n\treturn p\n}\n",
+			want: "p := point{\n\t\tX: 0,\n\t\tY: 0,\n\t\tname: \"\",\n\t",
+		},
+		{
+			name: "structfill multiline",
+			src:  "func origin() point {\n\treturn point{\n\t\t|\n\t}\n}\n",
+			want: "X: 0,\n\t\tY: 0,\n\t\tname: \"\",",
+		},
+		{
+			name: "none",
+			src:  "func noop() {\n\t|\n}\n",
+		},
+	}
+	for i, test := range tests {
+		text := header + test.src
+		offset := strings.Index(text, "|")
+		text = text[:offset] + text[offset+1:]
+		line := strings.Count(text[:offset], "\n")
+		position := lsp.Position{Line: line, Character: offset - strings.LastIndex(text[:offset], "\n") - 1}
+
+		require.NoError(t, tx.conn.Notify(tx.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+			TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: i + 2},
+			ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: text}},
+		}))
+		var result protocol.InlineCompletionList
+		require.NoError(t, tx.conn.Call(tx.ctx, "textDocument/inlineCompletion", protocol.InlineCompletionParams{
+			TextDocumentPositionParams: lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}, Position: position},
+			Context:                    protocol.InlineCompletionContext{TriggerKind: protocol.InlineCompletionAutomatic},
+		}, &result))
+		if test.want == "" {
+			require.Empty(t, result.Items, test.name)
+			continue
+		}
+		require.Len(t, result.Items, 1, test.name)
+		require.Equal(t, test.want, result.Items[0].InsertText, test.name)
+		typed := strings.TrimLeft(text[strings.LastIndex(text[:offset], "\n")+1:offset], "\t")
+		require.Equal(t, position.Character-len(typed), result.Items[0].Range.Start.Character, test.name)
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"go/ast"
+	goimporter "go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestRunAnalyses(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs := map[string]*Package{}
+	imp := goimporter.ForCompiler(fset, "source", nil)
+	check := func(path, src string) *Package {
+		file, err := parser.ParseFile(fset, path+".go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		}
+		cfg := &types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+			if pkg := pkgs[path]; pkg != nil {
+				return pkg.types, nil
+			}
+			return imp.Import(path)
+		})}
+		typ, err := cfg.Check(path, fset, []*ast.File{file}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The packages of the global cache, whose analyses are created
+		// when they are added.
+		pkg := create(&packages.Package{
+			ID:              path,
+			PkgPath:         path,
+			Name:            typ.Name(),
+			CompiledGoFiles: []string{path + ".go"},
+			Syntax:          []*ast.File{file},
+			Types:           typ,
+			TypesInfo:       info,
+			Fset:            fset,
+		})
+		for _, imported := range typ.Imports() {
+			if dep := pkgs[imported.Path()]; dep != nil {
+				pkg.imports[imported.Path()] = dep
+			}
+		}
+		pkgs[path] = pkg
+		return pkg
+	}
+
+	check("q", "package q\n\nimport \"fmt\"\n\nfunc Logf(format string, args ...interface{}) { fmt.Printf(format, args...) }\n")
+	p := check("p", "package p\n\nimport \"q\"\n\nfunc f() { q.Logf(\"%d\", \"s\") }\n")
+
+	v := NewView(&packages.Config{Fset: fset})
+	var messages []string
+	err := source.RunAnalyses(context.Background(), v, p, []*analysis.Analyzer{printf.Analyzer}, func(a *analysis.Analyzer, diag analysis.Diagnostic) {
+		messages = append(messages, fset.Position(diag.Pos).String()+": "+diag.Message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0] != "p.go:5:12: Logf format %d has arg \"s\" of wrong type string" {
+		t.Errorf("got %q", messages)
+	}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
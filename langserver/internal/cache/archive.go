@@ -0,0 +1,357 @@
+package cache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// archiveRoot is a directory which the files of an archive of a disk cache
+// are relative to, so that the archive can be imported on another machine.
+type archiveRoot struct {
+	name string
+	dir  string
+}
+
+// archiveRoots returns the directories which the files of an archive of the
+// disk cache of the workspace rootDir are relative to: the workspace, the
+// module cache and GOROOT, by specificity.
+func archiveRoots(rootDir string) []archiveRoot {
+	return []archiveRoot{
+		{"$ROOT", rootDir},
+		{"$GOMODCACHE", filepath.Join(gopaths[0], "pkg", "mod")},
+		{"$GOROOT", goroot},
+	}
+}
+
+// toPortable returns the name of filename relative to the first of roots
+// containing it, or false if there is none.
+func toPortable(roots []archiveRoot, filename string) (string, bool) {
+	for _, root := range roots {
+		if root.dir == "" {
+			continue
+		}
+		rel, err := filepath.Rel(root.dir, filename)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return root.name + "/" + filepath.ToSlash(rel), true
+		}
+	}
+	return "", false
+}
+
+// fromPortable returns the filename of name, which is relative to one of
+// roots.
+func fromPortable(roots []archiveRoot, name string) (string, bool) {
+	for _, root := range roots {
+		if root.dir != "" && strings.HasPrefix(name, root.name+"/") {
+			return filepath.Join(root.dir, filepath.FromSlash(name[len(root.name)+1:])), true
+		}
+	}
+	return "", false
+}
+
+// ExportCache writes the disk cache of the workspace rootDir to w as a gzipped
+// tar archive, which ImportCache imports on another machine. The files of the
+// packages are identified by their content, relative to the workspace, the
+// module cache or GOROOT, so that the packages whose files are elsewhere or
+// have changed since they were cached are not exported. It returns the number
+// of the exported packages.
+func ExportCache(rootDir string, w io.Writer) (int, error) {
+	return exportCache(newDiskCache(rootDir), archiveRoots(rootDir), w)
+}
+
+func exportCache(d *diskCache, roots []archiveRoot, w io.Writer) (int, error) {
+	index, err := d.readIndex()
+	if err != nil {
+		return 0, err
+	}
+	if index == nil || index.Version != diskCacheVersion {
+		return 0, fmt.Errorf("no disk cache in %s", d.dir)
+	}
+
+	var entries []*diskEntry
+	for _, entry := range index.Packages {
+		files, ok := portableStamps(roots, entry.Files)
+		if !ok {
+			continue
+		}
+		exported := *entry
+		exported.Files = files
+		entries = append(entries, &exported)
+	}
+	entries = withImports(entries)
+
+	exportData := make(map[string][]byte, len(entries))
+	var archived []*diskEntry
+	for _, entry := range entries {
+		data, err := relocateExportData(d, entry, func(filename string) string {
+			if name, ok := toPortable(roots, filename); ok {
+				return name
+			}
+			return filename
+		})
+		if err != nil {
+			continue
+		}
+		exportData[entry.ID] = data
+		archived = append(archived, entry)
+	}
+	archived = withImports(archived)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, entry := range archived {
+		if err := writeTarFile(tw, entry.Export, exportData[entry.ID]); err != nil {
+			return 0, err
+		}
+	}
+	data, err := json.Marshal(&diskIndex{Version: diskCacheVersion, GoVersion: index.GoVersion, Packages: archived})
+	if err != nil {
+		return 0, err
+	}
+	if err := writeTarFile(tw, "index.json", data); err != nil {
+		return 0, err
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	return len(archived), gw.Close()
+}
+
+// ImportCache replaces the disk cache of the workspace rootDir with the
+// packages of the archive written by ExportCache read from r whose files
+// have the same content here. It returns the number of the imported
+// packages.
+func ImportCache(rootDir string, r io.Reader) (int, error) {
+	return importCache(newDiskCache(rootDir), archiveRoots(rootDir), r)
+}
+
+func importCache(d *diskCache, roots []archiveRoot, r io.Reader) (int, error) {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return 0, err
+	}
+	staging, err := ioutil.TempDir(filepath.Dir(d.dir), "import")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(staging)
+	archive := &diskCache{dir: staging}
+	if err := extractTar(r, staging); err != nil {
+		return 0, err
+	}
+
+	index, err := archive.readIndex()
+	if err != nil {
+		return 0, err
+	}
+	if index == nil || index.Version != diskCacheVersion {
+		return 0, fmt.Errorf("not an archive of a disk cache of version %d", diskCacheVersion)
+	}
+	if index.GoVersion != runtime.Version() {
+		return 0, fmt.Errorf("the archive is of %s, not %s", index.GoVersion, runtime.Version())
+	}
+
+	archivedExport := make(map[string]string, len(index.Packages))
+	var entries []*diskEntry
+	for _, entry := range index.Packages {
+		files, ok := localStamps(roots, entry.Files)
+		if !ok {
+			continue
+		}
+		archivedExport[entry.ID] = entry.Export
+		imported := *entry
+		imported.Files = files
+		imported.Export = exportFile(entry.ID, files)
+		entries = append(entries, &imported)
+	}
+	entries = withImports(entries)
+
+	imported := &diskIndex{Version: diskCacheVersion, GoVersion: index.GoVersion}
+	written := map[string]bool{"index.json": true}
+	for _, entry := range entries {
+		archived := *entry
+		archived.Export = archivedExport[entry.ID]
+		data, err := relocateExportData(archive, &archived, func(name string) string {
+			if filename, ok := fromPortable(roots, name); ok {
+				return filename
+			}
+			return name
+		})
+		if err != nil {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(d.dir, entry.Export), data, 0644); err != nil {
+			return 0, err
+		}
+		imported.Packages = append(imported.Packages, entry)
+		written[entry.Export] = true
+	}
+	imported.Packages = withImports(imported.Packages)
+	return len(imported.Packages), d.writeIndex(imported, written)
+}
+
+// portableStamps returns the stamps of the files of a package for an
+// archive, or false if one of them is not relative to roots or has changed
+// since it was stamped.
+func portableStamps(roots []archiveRoot, stamps []fileStamp) ([]fileStamp, bool) {
+	current, err := stampFiles(filenames(stamps))
+	if err != nil {
+		return nil, false
+	}
+	portable := make([]fileStamp, 0, len(stamps))
+	for i, stamp := range stamps {
+		if current[i].Size != stamp.Size || !current[i].ModTime.Equal(stamp.ModTime) {
+			return nil, false
+		}
+		name, ok := toPortable(roots, stamp.Name)
+		if !ok {
+			return nil, false
+		}
+		hash, err := hashFile(stamp.Name)
+		if err != nil {
+			return nil, false
+		}
+		portable = append(portable, fileStamp{Name: name, Size: stamp.Size, Hash: hash})
+	}
+	return portable, true
+}
+
+// localStamps returns the stamps of the local files of the portable stamps
+// of an archive, or false if one of them does not have the same content.
+func localStamps(roots []archiveRoot, portable []fileStamp) ([]fileStamp, bool) {
+	stamps := make([]fileStamp, 0, len(portable))
+	for _, stamp := range portable {
+		filename, ok := fromPortable(roots, stamp.Name)
+		if !ok {
+			return nil, false
+		}
+		fi, err := os.Stat(filename)
+		if err != nil || fi.Size() != stamp.Size {
+			return nil, false
+		}
+		if hash, err := hashFile(filename); err != nil || hash != stamp.Hash {
+			return nil, false
+		}
+		stamps = append(stamps, fileStamp{Name: filename, Size: fi.Size(), ModTime: fi.ModTime()})
+	}
+	return stamps, true
+}
+
+func hashFile(filename string) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// withImports returns the entries whose imports are among them, recursively,
+// since the others could not be restored.
+func withImports(entries []*diskEntry) []*diskEntry {
+	for {
+		ids := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			ids[entry.ID] = true
+		}
+		var kept []*diskEntry
+		for _, entry := range entries {
+			ok := true
+			for _, id := range entry.Imports {
+				ok = ok && ids[id]
+			}
+			if ok {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == len(entries) {
+			return kept
+		}
+		entries = kept
+	}
+}
+
+// relocateExportData returns the export data of the package of entry in d,
+// with the files of the positions of its objects renamed by rename.
+func relocateExportData(d *diskCache, entry *diskEntry, rename func(string) string) ([]byte, error) {
+	fset := token.NewFileSet()
+	typ, err := d.readExportData(fset, make(map[string]*types.Package), entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// The relocated files have the bases of the original ones, so that the
+	// positions are the same.
+	relocated := token.NewFileSet()
+	fset.Iterate(func(f *token.File) bool {
+		r := relocated.AddFile(rename(f.Name()), f.Base(), f.Size())
+		r.SetLines(f.Lines())
+		return true
+	})
+
+	tmp, err := ioutil.TempDir("", "bingo-relocate")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := (&diskCache{dir: tmp}).writeExportData(relocated, typ, "export"); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(filepath.Join(tmp, "export"))
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// extractTar extracts the files of the gzipped tar archive read from r into
+// dir.
+func extractTar(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// The files of a disk cache are not in directories.
+		name := filepath.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || name != hdr.Name {
+			continue
+		}
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"bytes"
+	"go/ast"
+	goimporter "go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := "package p\n\nimport \"strings\"\n\nfunc Upper(s string) string { return strings.ToUpper(s) }\n"
+	workspace := func(name string) string {
+		root := filepath.Join(dir, name)
+		if err := os.MkdirAll(root, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, "p.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+	ci, dev := workspace("ci"), workspace("dev")
+
+	fset := token.NewFileSet()
+	filename := filepath.Join(ci, "p.go")
+	file, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &types.Config{Importer: goimporter.ForCompiler(fset, "source", nil)}
+	typ, err := cfg.Check("example.com/p", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs := []*Package{
+		{id: "example.com/p", pkgPath: "example.com/p", name: "p", files: []string{filename}, types: typ},
+		{id: "strings", pkgPath: "strings", name: "strings", files: []string{filename}, types: typ.Imports()[0]},
+	}
+	pkgs[0].imports = map[string]*Package{"strings": pkgs[1]}
+	if err := (&diskCache{dir: filepath.Join(dir, "ci-cache")}).save(fset, pkgs); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	n, err := exportCache(&diskCache{dir: filepath.Join(dir, "ci-cache")}, []archiveRoot{{"$ROOT", ci}}, &archive)
+	if err != nil || n != 2 {
+		t.Fatalf("exported %d packages: %v", n, err)
+	}
+
+	d := &diskCache{dir: filepath.Join(dir, "dev-cache")}
+	n, err = importCache(d, []archiveRoot{{"$ROOT", dev}}, bytes.NewReader(archive.Bytes()))
+	if err != nil || n != 2 {
+		t.Fatalf("imported %d packages: %v", n, err)
+	}
+	restoredFset := token.NewFileSet()
+	restored, err := d.restore(restoredFset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := restored["example.com/p"]
+	if pkg == nil || pkg.files[0] != filepath.Join(dev, "p.go") || !isFresh(pkg, map[string]bool{}) {
+		t.Fatalf("restored %v", restored)
+	}
+	upper := pkg.GetTypes().Scope().Lookup("Upper")
+	if pos := restoredFset.Position(upper.Pos()); pos.Filename != filepath.Join(dev, "p.go") || pos.Line != 5 {
+		t.Errorf("Upper is declared at %s", pos)
+	}
+
+	// The packages whose files differ are not imported, nor the ones which
+	// import them.
+	if err := ioutil.WriteFile(filepath.Join(dev, "p.go"), []byte(src+"\nvar V int\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, err = importCache(d, []archiveRoot{{"$ROOT", dev}}, bytes.NewReader(archive.Bytes()))
+	if err != nil || n != 0 {
+		t.Errorf("imported %d packages from a different workspace: %v", n, err)
+	}
+}
@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"go/build"
+	"go/build/constraint"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// maxAuxiliaryTags bounds the number of the tags of the build constraints of
+// a file which are combined to find the tags it is built with.
+const maxAuxiliaryTags = 8
+
+// IsAuxiliary reports whether pkgPath is the path of a package type-checked
+// in an auxiliary configuration, with the tags of a file excluded by the
+// configuration of the view, see auxiliaryPkgPath.
+func IsAuxiliary(pkgPath string) bool {
+	return strings.HasSuffix(pkgPath, "]") && strings.Contains(pkgPath, " [tags=")
+}
+
+// auxiliaryPkgPath returns the path the metadata and the packages of the
+// package pkgPath type-checked with the additional tags are cached under,
+// eg. "example.com/p [tags=integration]", so that they do not replace the
+// package of the configuration of the view.
+func auxiliaryPkgPath(pkgPath string, tags []string) string {
+	return fmt.Sprintf("%s [tags=%s]", pkgPath, strings.Join(tags, ","))
+}
+
+// basePkgPath returns the import path of the package of pkgPath, which may
+// be the path of an auxiliary package.
+func basePkgPath(pkgPath string) string {
+	if IsAuxiliary(pkgPath) {
+		return pkgPath[:strings.LastIndex(pkgPath, " [tags=")]
+	}
+	return pkgPath
+}
+
+// auxiliaryTags returns the fewest tags, besides the build flags of the view,
+// which the file filename of the given content is built with, or nil if it
+// is built without any or if none are found. The "ignore" tag is never
+// used, since it marks the files which are not meant to be built.
+func (v *View) auxiliaryTags(filename string, content []byte) []string {
+	goos, goarch := envValue(v.Config.Env, "GOOS"), envValue(v.Config.Env, "GOARCH")
+	if goos == "" {
+		goos = build.Default.GOOS
+	}
+	if goarch == "" {
+		goarch = build.Default.GOARCH
+	}
+	viewTags := flagTags(v.Config.BuildFlags)
+	if (BuildVariant{}).matches(filename, content, goos, goarch, viewTags) {
+		return nil
+	}
+
+	candidates := constraintTags(content)
+	if len(candidates) > maxAuxiliaryTags {
+		candidates = candidates[:maxAuxiliaryTags]
+	}
+	// The subsets of the candidates by increasing size, so that the file is
+	// built with as few other files as possible.
+	subsets := make([][]string, 0, 1<<uint(len(candidates)))
+	for mask := 1; mask < 1<<uint(len(candidates)); mask++ {
+		var subset []string
+		for i, tag := range candidates {
+			if mask&(1<<uint(i)) != 0 {
+				subset = append(subset, tag)
+			}
+		}
+		subsets = append(subsets, subset)
+	}
+	sort.SliceStable(subsets, func(i, j int) bool { return len(subsets[i]) < len(subsets[j]) })
+	for _, tags := range subsets {
+		if (BuildVariant{Tags: tags}).matches(filename, content, goos, goarch, viewTags) {
+			return tags
+		}
+	}
+	return nil
+}
+
+// constraintTags returns the sorted tags of the build constraints of the
+// header of a Go file, except "ignore".
+func constraintTags(content []byte) []string {
+	seen := map[string]bool{}
+	var walk func(expr constraint.Expr)
+	walk = func(expr constraint.Expr) {
+		switch expr := expr.(type) {
+		case *constraint.AndExpr:
+			walk(expr.X)
+			walk(expr.Y)
+		case *constraint.OrExpr:
+			walk(expr.X)
+			walk(expr.Y)
+		case *constraint.NotExpr:
+			walk(expr.X)
+		case *constraint.TagExpr:
+			if expr.Tag != "ignore" {
+				seen[expr.Tag] = true
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			// The build constraints precede the package clause.
+			break
+		}
+		if constraint.IsGoBuild(line) || constraint.IsPlusBuild(line) {
+			if expr, err := constraint.Parse(line); err == nil {
+				walk(expr)
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(seen))
+	for tag := range seen {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// envValue returns the value of the variable key of env, the last one if it
+// is set more than once, as the go command does.
+func envValue(env []string, key string) string {
+	var value string
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			value = kv[len(key)+1:]
+		}
+	}
+	return value
+}
+
+// auxiliaryChanged reports whether the tags the file f, excluded by the
+// configuration of the view, is built with have changed, eg. since its
+// build constraints were removed. It is assumed that the caller holds the
+// mutexes of the view and of the mcache.
+func (v *View) auxiliaryChanged(f *File, filename string) bool {
+	if f.meta == nil || !IsAuxiliary(f.meta.pkgPath) {
+		return false
+	}
+	return !reflect.DeepEqual(v.auxiliaryTags(filename, f.content), f.meta.tags)
+}
+
+// loadAuxiliary loads the package of the file f, excluded by the
+// configuration of the view, with the tags it is built with, and links its
+// metadata as an auxiliary package. It returns false if the file is not
+// built with any tags. It is assumed that the caller holds the mutexes of
+// the view and of the mcache.
+func (v *View) loadAuxiliary(ctx context.Context, f *File, filename string) (bool, []packages.Error, error) {
+	f.read(ctx)
+	tags := v.auxiliaryTags(filename, f.content)
+	if tags == nil {
+		return false, nil, nil
+	}
+
+	cfg := v.Config
+	cfg.Mode = packages.LoadImports
+	cfg.Tests = strings.HasSuffix(filename, "_test.go")
+	cfg.BuildFlags = BuildVariant{Tags: tags}.buildFlags(cfg.BuildFlags)
+	pkgs, err := timedLoad(&cfg, fmt.Sprintf("file=%s", filename))
+	pkg := packageOf(pkgs, filename)
+	if pkg == nil {
+		if err == nil {
+			err = fmt.Errorf("no packages found for %s with tags %s", filename, strings.Join(tags, ","))
+		}
+		return true, nil, err
+	}
+	if len(pkg.Errors) > 0 {
+		return true, pkg.Errors, fmt.Errorf("package %s has errors, skipping type-checking", pkg.PkgPath)
+	}
+	v.linkAuxiliary(f, pkg, tags)
+	return true, nil, nil
+}
+
+// packageOf returns the package of pkgs whose files include filename, the
+// one with the most files if there are many, eg. its test variant.
+func packageOf(pkgs []*packages.Package, filename string) *packages.Package {
+	var result *packages.Package
+	for _, pkg := range pkgs {
+		for _, name := range pkg.CompiledGoFiles {
+			if sameFile(name, filename) && (result == nil || len(pkg.CompiledGoFiles) > len(result.CompiledGoFiles)) {
+				result = pkg
+			}
+		}
+	}
+	return result
+}
+
+// linkAuxiliary links the metadata of the package pkg of the file f loaded
+// with the tags. Only f is linked to it, since the other files of the
+// package are seen in the configuration of the view, and so are the imports
+// of the package which are already linked. It is assumed that the caller
+// holds the mutexes of the view and of the mcache.
+func (v *View) linkAuxiliary(f *File, pkg *packages.Package, tags []string) {
+	pkgPath := auxiliaryPkgPath(pkg.PkgPath, tags)
+	m, ok := v.mcache.packages[pkgPath]
+	if !ok {
+		m = &metadata{
+			pkgPath:  pkgPath,
+			id:       pkgPath,
+			tags:     tags,
+			parents:  make(map[string]bool),
+			children: make(map[string]bool),
+		}
+		v.mcache.packages[pkgPath] = m
+	}
+	m.name = pkg.Name
+	m.files = pkg.CompiledGoFiles
+	f.meta = m
+
+	for importPath, importPkg := range pkg.Imports {
+		child, ok := v.mcache.packages[importPath]
+		if !ok {
+			v.link(importPath, importPkg, m)
+			continue
+		}
+		m.children[importPath] = true
+		child.parents[pkgPath] = true
+	}
+	for importPath := range m.children {
+		if _, ok := pkg.Imports[importPath]; !ok {
+			delete(m.children, importPath)
+			if child, ok := v.mcache.packages[importPath]; ok {
+				delete(child.parents, pkgPath)
+			}
+		}
+	}
+}
+
+// ownsFile reports whether the file f is cached with the package pkg, which
+// only owns the files linked to it if it is auxiliary, see linkAuxiliary.
+func ownsFile(pkg *Package, f *File) bool {
+	return !IsAuxiliary(pkg.pkgPath) || f.meta != nil && f.meta.pkgPath == pkg.pkgPath
+}
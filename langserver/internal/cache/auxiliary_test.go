@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestConstraintTags(t *testing.T) {
+	tests := []struct {
+		content string
+		tags    []string
+	}{
+		{"package p\n", []string{}},
+		{"//go:build integration\n\npackage p\n", []string{"integration"}},
+		{"// Copyright\n\n//go:build (e2e || integration) && !windows\n// +build e2e integration\n// +build !windows\n\npackage p\n", []string{"e2e", "integration", "windows"}},
+		{"//go:build ignore\n\npackage main\n", []string{}},
+		{"package p\n\n//go:build integration\n", []string{}},
+	}
+	for _, test := range tests {
+		if got := constraintTags([]byte(test.content)); !reflect.DeepEqual(got, test.tags) {
+			t.Errorf("constraintTags(%q) = %q, want %q", test.content, got, test.tags)
+		}
+	}
+}
+
+func TestAuxiliaryTags(t *testing.T) {
+	v := NewView(&packages.Config{Env: []string{"GOOS=linux", "GOARCH=amd64"}, BuildFlags: []string{"-tags", "e2e"}})
+	tests := []struct {
+		content string
+		tags    []string
+	}{
+		{"package p\n", nil},
+		{"//go:build e2e\n\npackage p\n", nil},
+		{"//go:build integration\n\npackage p\n", []string{"integration"}},
+		{"//go:build integration && slow\n\npackage p\n", []string{"integration", "slow"}},
+		{"//go:build integration || slow\n\npackage p\n", []string{"integration"}},
+		{"//go:build !e2e\n\npackage p\n", nil},
+		{"//go:build ignore\n\npackage main\n", nil},
+	}
+	for _, test := range tests {
+		if got := v.auxiliaryTags("/src/p/p_test.go", []byte(test.content)); !reflect.DeepEqual(got, test.tags) {
+			t.Errorf("auxiliaryTags(%q) = %q, want %q", test.content, got, test.tags)
+		}
+	}
+}
+
+func TestAuxiliaryPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-auxiliary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"go.mod":              "module example.com/p\n\ngo 1.16\n",
+		"p.go":                "package p\n\nfunc Start() string { return \"\" }\n",
+		"unit_test.go":        "//go:build !integration\n\npackage p\n\nvar unit = Start()\n",
+		"integration_test.go": "//go:build integration\n\npackage p\n\nvar integration = Start() + helper()\n",
+		"helper_test.go":      "//go:build integration\n\npackage p\n\nfunc helper() string { return \"\" }\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	v := NewView(&packages.Config{
+		Context: ctx,
+		Dir:     dir,
+		Env:     append(os.Environ(), "GO111MODULE=on", "GOFLAGS=-mod=mod", "GOPROXY=off"),
+		Fset:    token.NewFileSet(),
+		Tests:   true,
+		ParseFile: func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		},
+	})
+	check := func(name, pkgPath string) {
+		f, err := v.GetFile(ctx, span.FileURI(filepath.Join(dir, name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg := f.GetPackage(ctx)
+		if pkg == nil {
+			t.Fatalf("no package of %s", name)
+		}
+		if pkg.GetPkgPath() != pkgPath {
+			t.Errorf("package of %s is %q, want %q", name, pkg.GetPkgPath(), pkgPath)
+		}
+		if errs := pkg.GetErrors(); len(errs) > 0 {
+			t.Errorf("errors of the package of %s: %v", name, errs)
+		}
+	}
+	check("unit_test.go", "example.com/p")
+	check("integration_test.go", "example.com/p [tags=integration]")
+	// The files of the package of the view are still seen in it.
+	check("unit_test.go", "example.com/p")
+	check("p.go", "example.com/p")
+}
@@ -0,0 +1,433 @@
+package cache
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// checkFunctionBodies type-checks the package meta again when only the
+// bodies of functions of one of its files have changed since the package was
+// last type-checked, and the other declarations kept their lines and
+// columns. The modified functions are type-checked against the scope of the
+// last package, whose type information is kept for the rest of the package.
+// It returns nil if the whole package has to be type-checked again. It is
+// assumed that the caller holds the mutexes of the view and of the mcache.
+func (v *View) checkFunctionBodies(meta *metadata) *Package {
+	if len(meta.files) == 0 {
+		return nil
+	}
+	old := v.gcache.GetByID(meta.id)
+	if old == nil || old.types == nil || old.typesInfo == nil || old.fset != v.Config.Fset ||
+		old.pkgPath != meta.pkgPath || old.hasParseErrors() || !sameStrings(old.files, meta.files) {
+		return nil
+	}
+
+	// Exactly one file has changed.
+	var changed *ast.File
+	for _, file := range old.syntax {
+		filename := v.Config.Fset.File(file.Pos()).Name()
+		if f := v.files[span.FileURI(filename)]; f == nil || f.ast != file {
+			if changed != nil {
+				return nil
+			}
+			changed = file
+		}
+	}
+	if changed == nil {
+		return nil
+	}
+	parsed, errs := v.parseFiles([]string{v.Config.Fset.File(changed.Pos()).Name()})
+	if len(errs) > 0 || len(parsed) != 1 {
+		return nil
+	}
+
+	info, typeErrs, modified, ok := checkModifiedBodies(v.Config.Fset, old.types, old.typesInfo, changed, parsed[0], v.packageGoVersion(meta))
+	if !ok {
+		return nil
+	}
+
+	pkg := &Package{
+		id:        old.id,
+		pkgPath:   old.pkgPath,
+		name:      old.name,
+		files:     meta.files,
+		imports:   old.imports,
+		types:     old.types,
+		typesInfo: info,
+		fset:      old.fset,
+		analyses:  make(map[*analysis.Analyzer]*analysisEntry),
+	}
+	for _, file := range old.syntax {
+		if file == changed {
+			file = parsed[0]
+		}
+		pkg.syntax = append(pkg.syntax, file)
+	}
+
+	// The errors of the modified functions are replaced by the new ones.
+	filename := v.Config.Fset.File(changed.Pos()).Name()
+	for _, err := range old.errors {
+		if !inModifiedBody(v.Config.Fset, err, filename, modified) {
+			pkg.errors = append(pkg.errors, err)
+		}
+	}
+	for _, err := range typeErrs {
+		v.appendPkgError(pkg, err)
+	}
+	return pkg
+}
+
+// checkModifiedBodies type-checks the functions of newFile whose body differs
+// from the one of oldFile, a file of pkg with the type information info. It
+// returns the type information of the package with newFile, the errors of the
+// modified functions, and their old declarations. It fails if anything else
+// than the bodies of functions differs, if a declaration has moved, or if an
+// import is no longer used.
+func checkModifiedBodies(fset *token.FileSet, pkg *types.Package, info *types.Info, oldFile, newFile *ast.File, goVersion string) (*types.Info, []error, []*ast.FuncDecl, bool) {
+	if oldFile.Name.Name != newFile.Name.Name || len(oldFile.Decls) != len(newFile.Decls) {
+		return nil, nil, nil, false
+	}
+
+	// The nodes of the unmodified declarations are mapped to the new ones.
+	nodes := map[ast.Node]ast.Node{oldFile: newFile, oldFile.Name: newFile.Name}
+	var modified [][2]*ast.FuncDecl
+	for i, oldDecl := range oldFile.Decls {
+		newDecl := newFile.Decls[i]
+		oldFunc, _ := oldDecl.(*ast.FuncDecl)
+		newFunc, _ := newDecl.(*ast.FuncDecl)
+		if oldFunc == nil || newFunc == nil || oldFunc.Body == nil || newFunc.Body == nil {
+			if !mapNodes(fset, nodes, oldDecl, newDecl) {
+				return nil, nil, nil, false
+			}
+			continue
+		}
+
+		// The signature of a modified function is type-checked again with
+		// its body, since its parameters are in the scope of the body.
+		fn := map[ast.Node]ast.Node{oldFunc: newFunc}
+		if !mapNodes(fset, fn, oldFunc.Recv, newFunc.Recv) || !mapNodes(fset, fn, oldFunc.Name, newFunc.Name) ||
+			!mapNodes(fset, fn, oldFunc.Type, newFunc.Type) || !samePosition(fset, oldFunc.Body.Lbrace, newFunc.Body.Lbrace) {
+			return nil, nil, nil, false
+		}
+		if !mapNodes(fset, fn, oldFunc.Body, newFunc.Body) {
+			modified = append(modified, [2]*ast.FuncDecl{oldFunc, newFunc})
+			continue
+		}
+		for o, n := range fn {
+			nodes[o] = n
+		}
+	}
+	if len(modified) == 0 {
+		return nil, nil, nil, false
+	}
+	var oldDecls []*ast.FuncDecl
+	for _, m := range modified {
+		oldDecls = append(oldDecls, m[0])
+	}
+
+	// The type information of the other files is kept, and the one of the
+	// unmodified declarations is moved to their new nodes.
+	tok := fset.File(oldFile.Pos())
+	inOldFile := func(n ast.Node) bool {
+		return tok.Base() <= int(n.Pos()) && int(n.Pos()) <= tok.Base()+tok.Size()
+	}
+	newInfo := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue, len(info.Types)),
+		Defs:       make(map[*ast.Ident]types.Object, len(info.Defs)),
+		Uses:       make(map[*ast.Ident]types.Object, len(info.Uses)),
+		Implicits:  make(map[ast.Node]types.Object, len(info.Implicits)),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection, len(info.Selections)),
+		Scopes:     make(map[ast.Node]*types.Scope, len(info.Scopes)),
+	}
+	move := func(n ast.Node) (ast.Node, bool) {
+		if !inOldFile(n) {
+			return n, true
+		}
+		moved, ok := nodes[n]
+		return moved, ok
+	}
+	for n, tv := range info.Types {
+		if moved, ok := move(n); ok {
+			newInfo.Types[moved.(ast.Expr)] = tv
+		}
+	}
+	for id, obj := range info.Defs {
+		if moved, ok := move(id); ok {
+			newInfo.Defs[moved.(*ast.Ident)] = obj
+		}
+	}
+	for id, obj := range info.Uses {
+		if moved, ok := move(id); ok {
+			newInfo.Uses[moved.(*ast.Ident)] = obj
+		}
+	}
+	for n, obj := range info.Implicits {
+		if moved, ok := move(n); ok {
+			newInfo.Implicits[moved] = obj
+		}
+	}
+	for sel, s := range info.Selections {
+		if moved, ok := move(sel); ok {
+			newInfo.Selections[moved.(*ast.SelectorExpr)] = s
+		}
+	}
+	for n, scope := range info.Scopes {
+		if moved, ok := move(n); ok {
+			newInfo.Scopes[moved] = scope
+		}
+	}
+
+	// The modified functions are renamed to the blank identifier, so that
+	// they are not declared again in the package.
+	fake := &ast.File{Package: newFile.Package, Name: newFile.Name, Imports: newFile.Imports}
+	for _, decl := range newFile.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+			fake.Decls = append(fake.Decls, decl)
+		}
+	}
+	for _, m := range modified {
+		fn := *m[1]
+		fn.Name = &ast.Ident{NamePos: fn.Name.NamePos, Name: "_"}
+		fake.Decls = append(fake.Decls, &fn)
+		newInfo.Defs[m[1].Name] = info.Defs[m[0].Name]
+	}
+	fakeInfo := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	// The fake file is checked in a copy of pkg, so that pkg, which may be in
+	// use, is not modified. The copy has the path of pkg, so that its
+	// unexported names are accessible.
+	scratch := types.NewPackage(pkg.Path(), pkg.Name())
+	for _, name := range pkg.Scope().Names() {
+		scratch.Scope().Insert(pkg.Scope().Lookup(name))
+	}
+	scratch.SetImports(pkg.Imports())
+	var errs []error
+	cfg := &types.Config{
+		GoVersion: goVersion,
+		Importer:  packageImports{pkg},
+		Error: func(err error) {
+			// The errors of the imports of the fake file, e.g. the unused
+			// ones, are not errors of the file.
+			if err, ok := err.(types.Error); ok && inModified(modified, err.Pos) {
+				errs = append(errs, err)
+			}
+		},
+	}
+	_ = types.NewChecker(cfg, fset, scratch, fakeInfo).Files([]*ast.File{fake})
+
+	// The imports of the fake file are the ones of the old file.
+	fileScope := info.Scopes[oldFile]
+	for n, tv := range fakeInfo.Types {
+		if inModified(modified, n.Pos()) {
+			newInfo.Types[n] = tv
+		}
+	}
+	for id, obj := range fakeInfo.Defs {
+		if inModified(modified, id.Pos()) && id.Name != "_" {
+			newInfo.Defs[id] = obj
+		}
+	}
+	for id, obj := range fakeInfo.Uses {
+		if pkgName, ok := obj.(*types.PkgName); ok && fileScope != nil {
+			if imported := fileScope.Lookup(pkgName.Name()); imported != nil {
+				obj = imported
+			}
+		}
+		if inModified(modified, id.Pos()) {
+			newInfo.Uses[id] = obj
+		}
+	}
+	for n, obj := range fakeInfo.Implicits {
+		if inModified(modified, n.Pos()) {
+			newInfo.Implicits[n] = obj
+		}
+	}
+	for sel, selection := range fakeInfo.Selections {
+		if inModified(modified, sel.Pos()) {
+			newInfo.Selections[sel] = selection
+		}
+	}
+	for n, scope := range fakeInfo.Scopes {
+		if inModified(modified, n.Pos()) {
+			newInfo.Scopes[n] = scope
+		}
+	}
+
+	// An import which is no longer used is an error of the whole file.
+	used := map[types.Object]bool{}
+	for id, obj := range newInfo.Uses {
+		if _, ok := obj.(*types.PkgName); ok && newFile.Pos() <= id.Pos() && id.Pos() <= newFile.End() {
+			used[obj] = true
+		}
+	}
+	for _, spec := range newFile.Imports {
+		obj := newInfo.Implicits[spec]
+		if spec.Name != nil {
+			obj = newInfo.Defs[spec.Name]
+		}
+		if obj != nil && !used[obj] && obj.Name() != "_" && obj.Name() != "." {
+			return nil, nil, nil, false
+		}
+	}
+	return newInfo, errs, oldDecls, true
+}
+
+// mapNodes maps the nodes of old to the ones of new in nodes, if they have
+// the same structure, identifiers, literals and operators, and the same
+// lines and columns. The comments are ignored.
+func mapNodes(fset *token.FileSet, nodes map[ast.Node]ast.Node, old, new ast.Node) bool {
+	if reflect.ValueOf(old).IsNil() || reflect.ValueOf(new).IsNil() {
+		return reflect.ValueOf(old).IsNil() == reflect.ValueOf(new).IsNil()
+	}
+	oldNodes, newNodes := preorder(old), preorder(new)
+	if len(oldNodes) != len(newNodes) {
+		return false
+	}
+	for i, o := range oldNodes {
+		n := newNodes[i]
+		if reflect.TypeOf(o) != reflect.TypeOf(n) || !samePosition(fset, o.Pos(), n.Pos()) || !samePosition(fset, o.End(), n.End()) ||
+			nodeToken(o) != nodeToken(n) {
+			return false
+		}
+	}
+	for i, o := range oldNodes {
+		nodes[o] = newNodes[i]
+	}
+	return true
+}
+
+// preorder returns the nodes of root but the comments, in depth-first order.
+func preorder(root ast.Node) []ast.Node {
+	var nodes []ast.Node
+	ast.Inspect(root, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil:
+			return false
+		case *ast.CommentGroup, *ast.Comment:
+			return false
+		}
+		nodes = append(nodes, n)
+		return true
+	})
+	return nodes
+}
+
+// nodeToken returns the text of the identifier, the literal or the operator
+// of n which its children do not have.
+func nodeToken(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Ident:
+		return n.Name
+	case *ast.BasicLit:
+		return n.Value
+	case *ast.BinaryExpr:
+		return n.Op.String()
+	case *ast.UnaryExpr:
+		return n.Op.String()
+	case *ast.AssignStmt:
+		return n.Tok.String()
+	case *ast.IncDecStmt:
+		return n.Tok.String()
+	case *ast.BranchStmt:
+		return n.Tok.String()
+	case *ast.RangeStmt:
+		return n.Tok.String()
+	case *ast.GenDecl:
+		return n.Tok.String()
+	case *ast.ChanType:
+		return strconv.Itoa(int(n.Dir))
+	}
+	return ""
+}
+
+// samePosition reports whether old and new have the same line and column.
+func samePosition(fset *token.FileSet, old, new token.Pos) bool {
+	if !old.IsValid() || !new.IsValid() {
+		return old.IsValid() == new.IsValid()
+	}
+	p, q := fset.Position(old), fset.Position(new)
+	return p.Line == q.Line && p.Column == q.Column
+}
+
+// inModified reports whether pos is in one of the modified functions.
+func inModified(modified [][2]*ast.FuncDecl, pos token.Pos) bool {
+	for _, m := range modified {
+		if m[1].Pos() <= pos && pos <= m[1].End() {
+			return true
+		}
+	}
+	return false
+}
+
+// inModifiedBody reports whether err is an error of the file filename in one
+// of the old declarations of the modified functions.
+func inModifiedBody(fset *token.FileSet, err packages.Error, filename string, modified []*ast.FuncDecl) bool {
+	// The position is "filename:line:column".
+	i := strings.LastIndex(err.Pos, ":")
+	if i < 0 {
+		return false
+	}
+	j := strings.LastIndex(err.Pos[:i], ":")
+	if j < 0 || err.Pos[:j] != filename {
+		return false
+	}
+	line, convErr := strconv.Atoi(err.Pos[j+1 : i])
+	if convErr != nil {
+		return false
+	}
+	for _, decl := range modified {
+		if fset.Position(decl.Pos()).Line <= line && line <= fset.Position(decl.End()).Line {
+			return true
+		}
+	}
+	return false
+}
+
+// packageImports is the importer of the packages imported by pkg.
+type packageImports struct {
+	pkg *types.Package
+}
+
+func (imp packageImports) Import(path string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	for _, p := range imp.pkg.Imports() {
+		if p.Path() == path || strings.HasSuffix(p.Path(), "/vendor/"+path) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not imported by %s", path, imp.pkg.Path())
+}
+
+// sameStrings reports whether a and b have the same elements.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := map[string]bool{}
+	for _, s := range a {
+		set[s] = true
+	}
+	for _, s := range b {
+		if !set[s] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/testutil"
+)
+
+const bodyCheckSrc = `package p
+
+import "strings"
+
+var prefix = "p"
+
+func upper(s string) string {
+	return strings.ToUpper(prefix + s)
+}
+
+func lower(s string) string {
+	return strings.ToLower(s)
+}
+`
+
+// checkBodies type-checks src, then checks the modified bodies of edited.
+func checkBodies(t *testing.T, src, edited string) (*ast.File, *types.Info, []error, bool) {
+	checked := testutil.CheckSource(t, src)
+	fset, oldFile, pkg, info := checked.Fset, checked.File, checked.Types, checked.Info
+	newFile, err := parser.ParseFile(fset, testutil.Filename, edited, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newInfo, errs, _, ok := checkModifiedBodies(fset, pkg, info, oldFile, newFile, "")
+	return newFile, newInfo, errs, ok
+}
+
+func TestCheckModifiedBodies(t *testing.T) {
+	edited := `package p
+
+import "strings"
+
+var prefix = "p"
+
+func upper(s string) string {
+	return strings.ToUpper(prefix + s + s)
+}
+
+func lower(s string) string {
+	return strings.ToLower(s)
+}
+`
+	file, info, errs, ok := checkBodies(t, bodyCheckSrc, edited)
+	if !ok {
+		t.Fatal("the modified body was not checked")
+	}
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	upper := file.Decls[2].(*ast.FuncDecl)
+	if obj := info.Defs[upper.Name]; obj == nil || obj.Name() != "upper" || obj.Parent() == nil {
+		t.Errorf("the name of upper is defined as %v", obj)
+	}
+	ret := upper.Body.List[0].(*ast.ReturnStmt)
+	call := ret.Results[0].(*ast.CallExpr)
+	if tv, ok := info.Types[call]; !ok || tv.Type.String() != "string" {
+		t.Errorf("the type of %s is %v", types.ExprString(call), tv.Type)
+	}
+	pkgName, _ := info.Uses[call.Fun.(*ast.SelectorExpr).X.(*ast.Ident)].(*types.PkgName)
+	spec := file.Imports[0]
+	if pkgName == nil || info.Implicits[spec] != pkgName {
+		t.Errorf("strings is not the import of the file: %v", pkgName)
+	}
+
+	lower := file.Decls[3].(*ast.FuncDecl)
+	if _, ok := info.Types[lower.Body.List[0].(*ast.ReturnStmt).Results[0]]; !ok {
+		t.Error("the type information of lower was not kept")
+	}
+}
+
+func TestCheckModifiedBodiesErrors(t *testing.T) {
+	edited := `package p
+
+import "strings"
+
+var prefix = "p"
+
+func upper(s string) string {
+	return strings.ToUpper(prefix + 1)
+}
+
+func lower(s string) string {
+	return strings.ToLower(s)
+}
+`
+	_, _, errs, ok := checkBodies(t, bodyCheckSrc, edited)
+	if !ok {
+		t.Fatal("the modified body was not checked")
+	}
+	if len(errs) != 1 {
+		t.Fatalf("got errors %v, want one", errs)
+	}
+	if err := errs[0].(types.Error); err.Fset.Position(err.Pos).Line != 8 {
+		t.Errorf("the error %v is not in the body of upper", err)
+	}
+}
+
+func TestCheckModifiedBodiesFails(t *testing.T) {
+	for _, test := range []struct {
+		name, edited string
+	}{
+		{"signature", `package p
+
+import "strings"
+
+var prefix = "p"
+
+func upper(s, t string) string {
+	return strings.ToUpper(prefix + s)
+}
+
+func lower(s string) string {
+	return strings.ToLower(s)
+}
+`},
+		{"moved", `package p
+
+import "strings"
+
+var prefix = "p"
+
+func upper(s string) string {
+	s += "!"
+	return strings.ToUpper(prefix + s)
+}
+
+func lower(s string) string {
+	return strings.ToLower(s)
+}
+`},
+		{"unused import", `package p
+
+import "strings"
+
+var prefix = "p"
+
+func upper(s string) string {
+	return prefix + s
+}
+
+func lower(s string) string {
+	return s
+}
+`},
+		{"unmodified", bodyCheckSrc},
+	} {
+		if _, _, _, ok := checkBodies(t, bodyCheckSrc, test.edited); ok {
+			t.Errorf("%s: the modified bodies were checked", test.name)
+		}
+	}
+}
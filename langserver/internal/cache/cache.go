@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"sort"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/util"
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
 )
 
@@ -21,9 +23,54 @@ const (
 	Always   CacheStyle = "always"
 )
 
+// LoadProfile is the information of the packages loaded into the global
+// cache, which trades features for memory.
+type LoadProfile string
+
+const (
+	// FullProfile loads the syntax and the type information of the packages
+	// and of their dependencies.
+	FullProfile LoadProfile = "full"
+
+	// NavigationProfile loads the syntax and the type information of the
+	// packages, and the types of their dependencies from their export data.
+	// The files of a dependency are parsed and type-checked the first time
+	// its syntax is used, e.g. to hover over its declarations.
+	NavigationProfile LoadProfile = "navigation"
+)
+
+// ParseLoadProfile returns the load profile named name, FullProfile if name
+// is empty, or an error if there is no such profile.
+func ParseLoadProfile(name string) (LoadProfile, error) {
+	switch profile := LoadProfile(name); profile {
+	case "":
+		return FullProfile, nil
+	case FullProfile, NavigationProfile:
+		return profile, nil
+	default:
+		return "", fmt.Errorf("unknown cache profile %q: want %q or %q", name, FullProfile, NavigationProfile)
+	}
+}
+
+// mode returns the mode of the loading of the packages of profile. The
+// go/packages version bingo is pinned to has no Need* bits to combine, only
+// the LoadFiles to LoadAllSyntax levels, so that a profile is one of them.
+// The profiles are validated by ParseLoadProfile.
+func (profile LoadProfile) mode() packages.LoadMode {
+	if profile == NavigationProfile {
+		return packages.LoadSyntax
+	}
+	return packages.LoadAllSyntax
+}
+
 type GlobalPackage struct {
 	pkg     *Package
 	modTime time.Time
+
+	// size is the estimated memory of the package, and lastUsed the clock
+	// of the cache when it was last used.
+	size     int64
+	lastUsed int64
 }
 
 func (p *GlobalPackage) Package() *Package {
@@ -41,8 +88,9 @@ func (p *GlobalPackage) ModTime() time.Time {
 }
 
 type id2Package map[string]*GlobalPackage
-type file2Package map[string]*GlobalPackage
+type file2Package map[string][]*GlobalPackage
 type path2Package map[string]*GlobalPackage
+type path2Importers map[string]map[string]bool
 
 func getPackageModTime(pkg *Package) time.Time {
 	if pkg == nil || len(pkg.files) == 0 {
@@ -64,6 +112,21 @@ type GlobalCache struct {
 	idMap   id2Package
 	pathMap path2Package
 	fileMap file2Package
+
+	// importerMap holds the IDs of the packages which import each package
+	// path, see Importers.
+	importerMap path2Importers
+
+	// limit is the memory budget of the packages which are not pinned, see
+	// SetMemoryLimit.
+	limit     int64
+	pinned    func(pkg *Package) bool
+	clock     int64
+	evictions int64
+
+	// hits and misses count the lookups of packages, see Stats.
+	hits   int64
+	misses int64
 }
 
 // debugCache trace package cache
@@ -71,7 +134,7 @@ var debugCache = false
 
 // NewCache new a package cache
 func NewCache() *GlobalCache {
-	return &GlobalCache{idMap: id2Package{}, pathMap: path2Package{}, fileMap: file2Package{}}
+	return &GlobalCache{idMap: id2Package{}, pathMap: path2Package{}, fileMap: file2Package{}, importerMap: path2Importers{}}
 }
 
 func (c *GlobalCache) put(pkg *Package) {
@@ -84,13 +147,67 @@ func (c *GlobalCache) put(pkg *Package) {
 	}
 
 	c.delete(pkg.id)
-	p := &GlobalPackage{pkg: pkg, modTime: getPackageModTime(pkg)}
+	p := &GlobalPackage{pkg: pkg, modTime: getPackageModTime(pkg), size: estimateMemory(pkg)}
+	c.touch(p)
 	c.idMap[pkg.id] = p
-	c.pathMap[pkg.pkgPath] = p
 
+	// The test variants of a package have its path and some of its files:
+	// the package itself is preferred to them.
+	if old := c.pathMap[pkg.pkgPath]; old == nil || !isTestVariant(pkg) || isTestVariant(old.pkg) {
+		c.pathMap[pkg.pkgPath] = p
+	}
 	for _, file := range pkg.files {
-		c.fileMap[util.LowerDriver(file)] = p
+		file = util.LowerDriver(file)
+		variants := append(c.fileMap[file], p)
+		sort.Slice(variants, func(i, j int) bool {
+			return variantLess(variants[i].pkg, variants[j].pkg)
+		})
+		c.fileMap[file] = variants
+	}
+	c.addImporter(pkg)
+}
+
+// isTestVariant reports whether pkg is a package type-checked with the tests
+// of its package, e.g. "p [p.test]".
+func isTestVariant(pkg *Package) bool {
+	return pkg.id != pkg.pkgPath
+}
+
+// IsTestVariant reports whether pkg is a cached package type-checked with the
+// tests of its package.
+func IsTestVariant(pkg source.Package) bool {
+	p, ok := pkg.(*Package)
+	return ok && isTestVariant(p)
+}
+
+// variantLess orders the packages which contain the same file: the package
+// itself comes first, then its test variants by ID. The first one owns the
+// file, see GetByURI.
+func variantLess(x, y *Package) bool {
+	if isTestVariant(x) != isTestVariant(y) {
+		return !isTestVariant(x)
+	}
+	return x.id < y.id
+}
+
+// bestVariant returns the variant which contains the most open documents,
+// so that a file is seen along with the open test files of its package. The
+// owner of the file wins a tie.
+func bestVariant(variants []*Package, open map[string]bool) *Package {
+	var best *Package
+	bestCount := -1
+	for _, pkg := range variants {
+		count := 0
+		for _, file := range pkg.files {
+			if open[util.LowerDriver(file)] {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = pkg, count
+		}
 	}
+	return best
 }
 
 func (c *GlobalCache) get(id string) *Package {
@@ -103,6 +220,8 @@ func (c *GlobalCache) get(id string) *Package {
 	if debugCache {
 		log.Printf("get %s = %p\n", id, pkg)
 	}
+	c.count(pkg)
+	c.touch(pkg)
 	return pkg.Package()
 }
 
@@ -121,11 +240,25 @@ func (c *GlobalCache) delete(id string) {
 	}
 
 	delete(c.idMap, id)
-	delete(c.pathMap, p.pkg.pkgPath)
+	if c.pathMap[p.pkg.pkgPath] == p {
+		delete(c.pathMap, p.pkg.pkgPath)
+	}
 
 	for _, file := range p.pkg.files {
-		delete(c.fileMap, util.LowerDriver(file))
+		file = util.LowerDriver(file)
+		var variants []*GlobalPackage
+		for _, v := range c.fileMap[file] {
+			if v != p {
+				variants = append(variants, v)
+			}
+		}
+		if len(variants) == 0 {
+			delete(c.fileMap, file)
+		} else {
+			c.fileMap[file] = variants
+		}
 	}
+	c.removeImporter(p.pkg)
 }
 
 func (c *GlobalCache) RLock() {
@@ -181,6 +314,8 @@ func (c *GlobalCache) Get(pkgPath string) *GlobalPackage {
 
 	c.RLock()
 	p := c.pathMap[pkgPath]
+	c.count(p)
+	c.touch(p)
 	c.RUnlock()
 	return p
 }
@@ -193,6 +328,7 @@ func (c *GlobalCache) Put(pkg *Package) {
 	c.Lock()
 	defer c.Unlock()
 	c.put(pkg)
+	c.evict()
 }
 
 func (c *GlobalCache) Delete(id string) {
@@ -205,15 +341,48 @@ func (c *GlobalCache) Delete(id string) {
 	c.delete(id)
 }
 
-// GetByURI get package by filename from global cache
+// GetByURI get package by filename from global cache. The package is the
+// owner of the file among the variants which contain it: the package itself
+// rather than its test variants.
 func (c *GlobalCache) GetByURI(filename string) *Package {
 	if c == nil {
 		return nil
 	}
 	c.RLock()
-	p := c.fileMap[util.LowerDriver(filename)]
-	c.RUnlock()
-	return p.Package()
+	defer c.RUnlock()
+	variants := c.fileMap[util.LowerDriver(filename)]
+	if len(variants) == 0 {
+		c.count(nil)
+		return nil
+	}
+	c.count(variants[0])
+	c.touch(variants[0])
+	return variants[0].Package()
+}
+
+// Variants returns the packages which contain filename, the owner first.
+func (c *GlobalCache) Variants(filename string) []*Package {
+	if c == nil {
+		return nil
+	}
+	c.RLock()
+	defer c.RUnlock()
+	var pkgs []*Package
+	for _, p := range c.fileMap[util.LowerDriver(filename)] {
+		c.touch(p)
+		pkgs = append(pkgs, p.pkg)
+	}
+	return pkgs
+}
+
+// GetByID get package by package ID from global cache
+func (c *GlobalCache) GetByID(id string) *Package {
+	if c == nil {
+		return nil
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return c.get(id)
 }
 
 // Walk walk the global package cache
@@ -264,7 +433,8 @@ func (c *GlobalCache) Walk(walkFunc source.WalkFunc, ranks []string) error {
 
 func (c *GlobalCache) walk(idList []string, walkFunc source.WalkFunc) error {
 	for _, id := range idList {
-		pkg := c.get(id)
+		// A walk does not make the packages recently used.
+		pkg := c.idMap[id].Package()
 		if err := walkFunc(pkg); err != nil {
 			return err
 		}
@@ -282,6 +452,33 @@ func (c *GlobalCache) Add(pkg *packages.Package) {
 	defer c.Unlock()
 
 	c.recusiveAdd(pkg, nil)
+	c.evict()
+}
+
+// AddRestored adds pkg, a package restored from the disk cache, and the
+// packages it imports.
+func (c *GlobalCache) AddRestored(pkg *Package) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.putRestored(pkg)
+	c.evict()
+}
+
+func (c *GlobalCache) putRestored(pkg *Package) {
+	if p, _ := c.idMap[pkg.id]; p != nil {
+		return
+	}
+
+	for _, ip := range pkg.imports {
+		c.putRestored(ip)
+	}
+
+	c.put(pkg)
 }
 
 func (c *GlobalCache) recusiveAdd(pkg *packages.Package, parent *Package) {
@@ -306,7 +503,7 @@ func (c *GlobalCache) recusiveAdd(pkg *packages.Package, parent *Package) {
 }
 
 func create(pkg *packages.Package) *Package {
-	return &Package{
+	p := &Package{
 		name:      pkg.Name,
 		id:        pkg.ID,
 		pkgPath:   pkg.PkgPath,
@@ -317,5 +514,14 @@ func create(pkg *packages.Package) *Package {
 		typesInfo: pkg.TypesInfo,
 		fset:      pkg.Fset,
 		imports:   make(map[string]*Package),
+		analyses:  make(map[*analysis.Analyzer]*analysisEntry),
 	}
+	// A dependency loaded from its export data is loaded like an evicted
+	// package when it is used. The stamps of its files size it for the
+	// memory limit.
+	if pkg.Syntax == nil && pkg.Types != nil && len(pkg.CompiledGoFiles) > 0 {
+		stamps, _ := stampFiles(pkg.CompiledGoFiles)
+		p.disk = &diskPackage{stamps: stamps}
+	}
+	return p
 }
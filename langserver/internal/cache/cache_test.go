@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestCacheVariants(t *testing.T) {
+	p := &Package{id: "p", pkgPath: "p", files: []string{"/p/p.go"}}
+	test := &Package{id: "p [p.test]", pkgPath: "p", files: []string{"/p/p.go", "/p/p_test.go"}}
+	xtest := &Package{id: "p_test [p.test]", pkgPath: "p_test", files: []string{"/p/x_test.go"}}
+
+	c := NewCache()
+	c.Put(test)
+	c.Put(xtest)
+	if got := c.GetByURI("/p/p.go"); got != test {
+		t.Errorf("p.go is owned by %v without the package", got)
+	}
+
+	c.Put(p)
+	if got := c.GetByURI("/p/p.go"); got != p {
+		t.Errorf("p.go is owned by %s", got.id)
+	}
+	if got := c.GetByURI("/p/p_test.go"); got != test {
+		t.Errorf("p_test.go is owned by %v", got)
+	}
+	if got := c.Get("p").Package(); got != p {
+		t.Errorf("the path p is %s", got.id)
+	}
+	if n := len(c.fileMap["/p/p.go"]); n != 2 {
+		t.Errorf("p.go is in %d packages", n)
+	}
+
+	c.Delete(p.id)
+	if got := c.GetByURI("/p/p.go"); got != test {
+		t.Errorf("p.go is owned by %v after the package is deleted", got)
+	}
+	c.Delete(test.id)
+	if got := c.GetByURI("/p/p.go"); got != nil {
+		t.Errorf("p.go is owned by %s after the variants are deleted", got.id)
+	}
+	if !IsTestVariant(xtest) || IsTestVariant(p) {
+		t.Error("IsTestVariant")
+	}
+}
+
+func TestBestVariant(t *testing.T) {
+	p := &Package{id: "p", pkgPath: "p", files: []string{"/p/p.go"}}
+	test := &Package{id: "p [p.test]", pkgPath: "p", files: []string{"/p/p.go", "/p/p_test.go"}}
+
+	c := NewCache()
+	c.Put(test)
+	c.Put(p)
+	variants := c.Variants("/p/p.go")
+	if len(variants) != 2 || variants[0] != p {
+		t.Fatalf("the variants of p.go are %v", variants)
+	}
+	if got := bestVariant(variants, map[string]bool{"/p/p.go": true}); got != p {
+		t.Errorf("p.go is seen in %s without open test files", got.id)
+	}
+	if got := bestVariant(variants, map[string]bool{"/p/p.go": true, "/p/p_test.go": true}); got != test {
+		t.Errorf("p.go is seen in %s with p_test.go open", got.id)
+	}
+	if got := bestVariant(nil, nil); got != nil {
+		t.Errorf("got %s without variants", got.id)
+	}
+	if got := c.GetByID(test.id); got != test {
+		t.Errorf("GetByID(%q) = %v", test.id, got)
+	}
+}
+
+func TestNavigationProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "p.go")
+	if err := ioutil.WriteFile(filename, []byte("package p\n\n// F is a func.\nfunc F() int { return 1 }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	typ := types.NewPackage("p", "p")
+	typ.MarkComplete()
+
+	// The dependencies are loaded from their export data in navigation mode.
+	pkg := create(&packages.Package{ID: "p", PkgPath: "p", Name: "p", CompiledGoFiles: []string{filename}, Types: typ, Fset: token.NewFileSet()})
+	if pkg.resident() {
+		t.Fatal("the syntax of p is loaded before it is used")
+	}
+	syntax := pkg.GetSyntax()
+	if len(syntax) != 1 || syntax[0].Scope.Lookup("F") == nil {
+		t.Fatalf("got the syntax %v of p, want p.go", syntax)
+	}
+	if info := pkg.GetTypesInfo(); info == nil || len(info.Defs) == 0 {
+		t.Error("p is not type-checked once its syntax is used")
+	}
+
+	if NavigationProfile.mode() != packages.LoadSyntax || FullProfile.mode() != packages.LoadAllSyntax || LoadProfile("").mode() != packages.LoadAllSyntax {
+		t.Error("the profiles do not load the expected information")
+	}
+}
+
+func TestParseLoadProfile(t *testing.T) {
+	for name, want := range map[string]LoadProfile{"": FullProfile, "full": FullProfile, "navigation": NavigationProfile} {
+		if got, err := ParseLoadProfile(name); err != nil || got != want {
+			t.Errorf("ParseLoadProfile(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseLoadProfile("types"); err == nil {
+		t.Error("the unknown profile types was accepted")
+	}
+}
@@ -13,7 +13,9 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
@@ -41,12 +43,30 @@ func (v *View) parse(ctx context.Context, uri span.URI) ([]packages.Error, error
 	}
 	// Check if the file's imports have changed. If they have, update the
 	// metadata by calling packages.Load.
-	if errs, err := v.checkMetadata(ctx, f); err != nil {
+	loadStart := time.Now()
+	errs, err := v.checkMetadata(ctx, f)
+	addPhase(ctx, LoadPhase, loadStart)
+	if err != nil {
 		return errs, err
 	}
 	if f.meta == nil {
 		return nil, fmt.Errorf("no metadata found for %v", uri)
 	}
+	defer addPhase(ctx, TypeCheckPhase, time.Now())
+	// Only the modified function bodies are type-checked if nothing else
+	// has changed.
+	if pkg := v.checkFunctionBodies(f.meta); pkg != nil {
+		v.pcache.mu.Lock()
+		v.pcache.putLastGood(f.meta, pkg)
+		v.pcache.mu.Unlock()
+		v.gcache.Put(pkg)
+		v.cachePackage(pkg)
+
+		if f.pkg == nil {
+			return nil, fmt.Errorf("no package found for %v", uri)
+		}
+		return nil, nil
+	}
 	imp := &importer{
 		view:     v,
 		circular: make(map[string]struct{}),
@@ -84,6 +104,9 @@ func (v *View) cachePackage(pkg *Package) {
 		}
 		fURI := span.FileURI(tok.Name())
 		f := v.getFile(fURI)
+		if !ownsFile(pkg, f) {
+			continue
+		}
 		f.token = tok
 		f.ast = file
 		f.imports = f.ast.Imports
@@ -96,10 +119,20 @@ func (v *View) checkMetadata(ctx context.Context, f *File) ([]packages.Error, er
 	if err != nil {
 		return nil, err
 	}
-	if v.reparseImports(ctx, f, filename) {
+	if v.reparseImports(ctx, f, filename) || v.auxiliaryChanged(f, filename) {
+		if v.isScratch(filename) {
+			return v.linkScratch(ctx, f, filename)
+		}
 		cfg := v.Config
 		cfg.Mode = packages.LoadImports
-		pkgs, err := packages.Load(&cfg, fmt.Sprintf("file=%s", filename))
+		pkgs, err := timedLoad(&cfg, fmt.Sprintf("file=%s", filename))
+		if packageOf(pkgs, filename) == nil {
+			// The file is excluded by the build constraints of the
+			// configuration of the view, eg. an integration test.
+			if ok, errs, err := v.loadAuxiliary(ctx, f, filename); ok {
+				return errs, err
+			}
+		}
 		if len(pkgs) == 0 {
 			if err == nil {
 				err = fmt.Errorf("no packages found for %s", filename)
@@ -228,7 +261,7 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 	if meta.pkgPath == "unsafe" {
 		typ = types.Unsafe
 	} else {
-		typ = types.NewPackage(meta.pkgPath, meta.name)
+		typ = types.NewPackage(basePkgPath(meta.pkgPath), meta.name)
 	}
 	pkg := &Package{
 		id:      meta.id,
@@ -267,7 +300,8 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 	newCircular[pkgPath] = struct{}{}
 
 	cfg := &types.Config{
-		Error: appendError,
+		GoVersion: imp.view.packageGoVersion(meta),
+		Error:     appendError,
 		Importer: &importer{
 			view:     imp.view,
 			circular: newCircular,
@@ -282,16 +316,64 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 	imp.view.pcache.mu.Lock()
 	defer imp.view.pcache.mu.Unlock()
 
+	// A parse error drops declarations which the importers of the package
+	// may use, so they are type-checked against its last good version
+	// until the error is fixed.
+	if !pkg.hasParseErrors() {
+		imp.view.pcache.putLastGood(meta, pkg)
+	} else if isImport {
+		if good := imp.lastGood(meta); good != nil {
+			return good, nil
+		}
+	}
+
 	for importPath := range meta.children {
 		if importEntry, ok := imp.view.pcache.packages[importPath]; ok {
 			pkg.imports[importPath] = importEntry.pkg
 		}
 	}
 
-	imp.view.gcache.Put(pkg)
+	if !imp.view.isScratchPackage(meta) && !IsAuxiliary(meta.pkgPath) {
+		imp.view.gcache.Put(pkg)
+	}
 	return pkg, nil
 }
 
+// packageGoVersion returns the language version of the module of the package
+// of meta, see goVersionCache.languageVersion.
+func (v *View) packageGoVersion(meta *metadata) string {
+	if len(meta.files) == 0 {
+		return ""
+	}
+	return v.goVersions.languageVersion(filepath.Dir(meta.files[0]))
+}
+
+// lastGood returns the last version of the package of meta without parse
+// errors, either type-checked by the view or loaded in the global cache. It
+// is assumed that the caller holds the mutex of the pcache.
+func (imp *importer) lastGood(meta *metadata) *Package {
+	// The types of the last good version refer to those of the packages
+	// the view type-checked since.
+	imports := make(map[string]*types.Package)
+	for pkgPath, e := range imp.view.pcache.packages {
+		select {
+		case <-e.ready:
+			if e.pkg != nil && e.pkg.types != nil && pkgPath != meta.pkgPath {
+				imports[pkgPath] = e.pkg.types
+			}
+		default:
+		}
+	}
+	if good := imp.view.pcache.lastGood.get(imp.view.Config.Fset, meta, imports); good != nil {
+		return good
+	}
+	good := imp.view.gcache.Get(meta.pkgPath).Package()
+	if good == nil || good.types == nil || good.hasParseErrors() {
+		return nil
+	}
+	return good
+}
+
 func (imp *importer) cloneFromCache(pkg *Package) bool {
 	clone := imp.view.gcache.Get(pkg.pkgPath)
 	if clone == nil {
@@ -376,8 +458,10 @@ func (v *View) parseFiles(filenames []string) ([]*ast.File, []error) {
 		// First, check if we have already cached an AST for this file.
 		f := v.files[span.FileURI(filename)]
 		var fAST *ast.File
+		var active bool
 		if f != nil {
 			fAST = f.ast
+			active = f.active
 		}
 
 		wg.Add(1)
@@ -405,6 +489,16 @@ func (v *View) parseFiles(filenames []string) ([]*ast.File, []error) {
 				} else {
 					// ParseFile may return both an AST and an error.
 					parsed[i], errors[i] = v.Config.ParseFile(v.Config.Fset, filename, src)
+					// The file being edited is likely in the middle of a
+					// statement: keep the AST of the statement, but report
+					// the original errors.
+					if errors[i] != nil && active {
+						if fixed := source.RecoverSource(filename, src, errors[i]); fixed != nil {
+							if recovered, _ := v.Config.ParseFile(v.Config.Fset, filename, fixed); recovered != nil {
+								parsed[i] = recovered
+							}
+						}
+					}
 				}
 			}
 
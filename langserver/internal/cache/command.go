@@ -3,18 +3,21 @@ package cache
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os/exec"
 	"strings"
 )
 
-// invokeGo returns the stdout of a go command invocation.
-func invokeGo(ctx context.Context, dir string, args ...string) (*bytes.Buffer, error) {
+// invokeGo returns the stdout of a go command invocation. A nil env means
+// the environment of the current process.
+func invokeGo(ctx context.Context, dir string, env []string, args ...string) (*bytes.Buffer, error) {
 	stdout := new(bytes.Buffer)
 	stderr := new(bytes.Buffer)
 
 	cmd := exec.CommandContext(ctx, "go", args...)
 	cmd.Dir = dir
+	cmd.Env = env
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	if err := cmd.Run(); err != nil {
@@ -38,3 +41,19 @@ func invokeGo(ctx context.Context, dir string, args ...string) (*bytes.Buffer, e
 
 	return stdout, nil
 }
+
+// ProcessGoEnv returns the go env of the server process, run in its working
+// directory, unlike Project.GoEnv, which includes the environment overrides
+// of the project that the clients choose.
+func ProcessGoEnv(ctx context.Context) (map[string]string, error) {
+	buf, err := invokeGo(ctx, "", nil, "env", "-json")
+	if err != nil {
+		return nil, err
+	}
+
+	goEnv := map[string]string{}
+	if err := json.Unmarshal(buf.Bytes(), &goEnv); err != nil {
+		return nil, fmt.Errorf("go env: %s", err)
+	}
+	return goEnv, nil
+}
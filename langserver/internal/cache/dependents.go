@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// Dependents returns the files of uris whose packages import the package of
+// the file uri, directly or not, one file per package. They are grouped by
+// level in dependency order: the packages of a level import the package of
+// uri only through the packages of the previous levels, so that the packages
+// of a level may be type-checked concurrently once the previous levels are.
+func (p *Project) Dependents(uri span.URI, uris []span.URI) [][]span.URI {
+	return p.view.dependents(uri, uris)
+}
+
+func (v *View) dependents(uri span.URI, uris []span.URI) [][]span.URI {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+
+	f, ok := v.files[uri]
+	if !ok || f.meta == nil {
+		return nil
+	}
+	root := f.meta.pkgPath
+
+	// Collect the importers of the package, directly or not.
+	importers := map[string]bool{}
+	var collect func(pkgPath string)
+	collect = func(pkgPath string) {
+		m, ok := v.mcache.packages[pkgPath]
+		if !ok {
+			return
+		}
+		for parent := range m.parents {
+			if parent != root && !importers[parent] {
+				importers[parent] = true
+				collect(parent)
+			}
+		}
+	}
+	collect(root)
+
+	// The level of an importer is the length of the longest import chain
+	// from it to the package. An import cycle does not make it longer.
+	levels := map[string]int{root: 0}
+	var level func(pkgPath string) int
+	level = func(pkgPath string) int {
+		if l, ok := levels[pkgPath]; ok {
+			return l
+		}
+		levels[pkgPath] = 1
+		l := 1
+		for child := range v.mcache.packages[pkgPath].children {
+			if child == root || importers[child] {
+				if n := level(child) + 1; n > l {
+					l = n
+				}
+			}
+		}
+		levels[pkgPath] = l
+		return l
+	}
+
+	var grouped [][]span.URI
+	seen := map[string]bool{}
+	for _, uri := range uris {
+		f, ok := v.files[uri]
+		if !ok || f.meta == nil {
+			continue
+		}
+		pkgPath := f.meta.pkgPath
+		if !importers[pkgPath] || seen[pkgPath] {
+			continue
+		}
+		seen[pkgPath] = true
+		l := level(pkgPath)
+		for len(grouped) < l {
+			grouped = append(grouped, nil)
+		}
+		grouped[l-1] = append(grouped[l-1], uri)
+	}
+
+	// Drop the levels without any file.
+	var dependents [][]span.URI
+	for _, uris := range grouped {
+		if len(uris) > 0 {
+			dependents = append(dependents, uris)
+		}
+	}
+	return dependents
+}
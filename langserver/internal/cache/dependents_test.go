@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestDependents(t *testing.T) {
+	v := NewView(&packages.Config{})
+	add := func(pkgPath string, imports ...string) span.URI {
+		filename := filepath.FromSlash("/src/" + pkgPath + "/" + pkgPath + ".go")
+		m, ok := v.mcache.packages[pkgPath]
+		if !ok {
+			m = &metadata{pkgPath: pkgPath, parents: map[string]bool{}, children: map[string]bool{}}
+			v.mcache.packages[pkgPath] = m
+		}
+		m.files = []string{filename}
+		for _, importPath := range imports {
+			m.children[importPath] = true
+			v.mcache.packages[importPath].parents[pkgPath] = true
+		}
+		f := &File{uri: span.FileURI(filename), view: v, meta: m}
+		v.files[f.uri] = f
+		return f.uri
+	}
+	// c and d import a, b imports c, e imports a and b, and f is unrelated.
+	a := add("a")
+	c := add("c", "a")
+	d := add("d", "a")
+	b := add("b", "c")
+	e := add("e", "a", "b")
+	f := add("f")
+
+	got := v.dependents(a, []span.URI{a, e, f, b, d, c, e})
+	want := [][]span.URI{{d, c}, {b}, {e}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependents of a: got %v, want %v", got, want)
+	}
+	if got := v.dependents(e, []span.URI{a, b, c, d}); got != nil {
+		t.Errorf("dependents of e: %v", got)
+	}
+}
@@ -0,0 +1,477 @@
+package cache
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/go/packages"
+)
+
+// diskCacheVersion is the version of the format of the disk cache. The
+// caches of other versions are ignored.
+const diskCacheVersion = 1
+
+// diskCache persists the export data of the packages of the global cache of
+// a project under $GOPATH/pkg/bingo, so that a restarted server only loads
+// again the packages whose files have changed since.
+type diskCache struct {
+	dir string
+}
+
+// diskIndex is the index of the packages of a disk cache.
+type diskIndex struct {
+	Version   int
+	GoVersion string
+	Packages  []*diskEntry
+}
+
+// diskEntry is a package of a disk cache. Export is the name of the file of
+// its export data, and Imports are the IDs of the packages it imports.
+type diskEntry struct {
+	ID      string
+	PkgPath string
+	Name    string
+	Files   []fileStamp
+	Imports []string
+	Export  string
+}
+
+// fileStamp identifies the content of a file by its size and its
+// modification time. The modification times are not portable, so the
+// archives of disk caches identify the content by its SHA-256 Hash instead.
+type fileStamp struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	Hash    string `json:",omitempty"`
+}
+
+func newDiskCache(rootDir string) *diskCache {
+	sum := sha256.Sum256([]byte(rootDir))
+	return &diskCache{dir: filepath.Join(gopaths[0], "pkg", "bingo", hex.EncodeToString(sum[:8]))}
+}
+
+func (d *diskCache) indexFile() string {
+	return filepath.Join(d.dir, "index.json")
+}
+
+// restore reads the packages of the disk cache with fset, by ID. Their
+// syntax and type information are only loaded when they are used.
+func (d *diskCache) restore(fset *token.FileSet) (map[string]*Package, error) {
+	index, err := d.readIndex()
+	if index == nil || err != nil {
+		return nil, err
+	}
+	if index.Version != diskCacheVersion || index.GoVersion != runtime.Version() {
+		return nil, nil
+	}
+
+	// The packages share the imported packages, so that their objects are
+	// identical.
+	imports := make(map[string]*types.Package)
+	pkgs := make(map[string]*Package, len(index.Packages))
+	for _, entry := range index.Packages {
+		typ, err := d.readExportData(fset, imports, entry)
+		if err != nil {
+			log.Printf("read disk cache %s: %s", entry.ID, err)
+			continue
+		}
+		pkg := &Package{
+			id:       entry.ID,
+			pkgPath:  entry.PkgPath,
+			name:     entry.Name,
+			imports:  make(map[string]*Package),
+			types:    typ,
+			fset:     fset,
+			analyses: make(map[*analysis.Analyzer]*analysisEntry),
+			disk:     &diskPackage{stamps: entry.Files},
+		}
+		for _, file := range entry.Files {
+			pkg.files = append(pkg.files, file.Name)
+		}
+		pkgs[entry.ID] = pkg
+	}
+
+	// A package is only fresh if its imports could be restored too.
+	for _, entry := range index.Packages {
+		pkg := pkgs[entry.ID]
+		if pkg == nil {
+			continue
+		}
+		for _, id := range entry.Imports {
+			imported := pkgs[id]
+			if imported == nil {
+				pkg.disk.missing = true
+				continue
+			}
+			pkg.imports[imported.pkgPath] = imported
+		}
+	}
+	return pkgs, nil
+}
+
+// readIndex reads the index of the disk cache, or returns nil if there is
+// none.
+func (d *diskCache) readIndex() (*diskIndex, error) {
+	data, err := ioutil.ReadFile(d.indexFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index diskIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("read disk cache %s: %s", d.indexFile(), err)
+	}
+	return &index, nil
+}
+
+func (d *diskCache) readExportData(fset *token.FileSet, imports map[string]*types.Package, entry *diskEntry) (*types.Package, error) {
+	f, err := os.Open(filepath.Join(d.dir, entry.Export))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return gcexportdata.Read(bufio.NewReader(f), fset, imports, entry.PkgPath)
+}
+
+// save replaces the content of the disk cache with pkgs. The export data of
+// the packages which are already in the disk cache is not written again.
+func (d *diskCache) save(fset *token.FileSet, pkgs []*Package) error {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+
+	index := diskIndex{Version: diskCacheVersion, GoVersion: runtime.Version()}
+	written := map[string]bool{"index.json": true}
+	for _, pkg := range pkgs {
+		stamps, err := stampFiles(pkg.files)
+		if err != nil {
+			continue
+		}
+		entry := &diskEntry{
+			ID:      pkg.id,
+			PkgPath: pkg.pkgPath,
+			Name:    pkg.name,
+			Files:   stamps,
+			Export:  exportFile(pkg.id, stamps),
+		}
+		for _, imported := range pkg.imports {
+			entry.Imports = append(entry.Imports, imported.id)
+		}
+		if err := d.writeExportData(fset, pkg.types, entry.Export); err != nil {
+			log.Printf("write disk cache %s: %s", pkg.id, err)
+			continue
+		}
+		index.Packages = append(index.Packages, entry)
+		written[entry.Export] = true
+	}
+	return d.writeIndex(&index, written)
+}
+
+// writeIndex replaces the index of the disk cache with index, and removes the
+// files of the disk cache which are not written, but the snapshots of the
+// listings. written includes the index.
+func (d *diskCache) writeIndex(index *diskIndex, written map[string]bool) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that a crash while saving does not
+	// leave a truncated index behind.
+	tmp := d.indexFile() + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, d.indexFile()); err != nil {
+		return err
+	}
+
+	// Remove the export data of the packages which are no longer cached.
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if !written[fi.Name()] && !strings.HasPrefix(fi.Name(), snapshotPrefix) {
+			_ = os.Remove(filepath.Join(d.dir, fi.Name()))
+		}
+	}
+	return nil
+}
+
+func (d *diskCache) writeExportData(fset *token.FileSet, typ *types.Package, name string) error {
+	filename := filepath.Join(d.dir, name)
+	if _, err := os.Stat(filename); err == nil {
+		return nil
+	}
+	tmp := filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = gcexportdata.Write(w, fset, typ)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// exportFile returns the name of the file of the export data of the package
+// id with the files stamps.
+func exportFile(id string, stamps []fileStamp) string {
+	h := sha256.New()
+	fmt.Fprintln(h, id)
+	for _, stamp := range stamps {
+		fmt.Fprintln(h, stamp.Name, stamp.Size, stamp.ModTime.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16]) + ".export"
+}
+
+func stampFiles(filenames []string) ([]fileStamp, error) {
+	stamps := make([]fileStamp, 0, len(filenames))
+	for _, filename := range filenames {
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+		stamps = append(stamps, fileStamp{Name: filename, Size: fi.Size(), ModTime: fi.ModTime()})
+	}
+	return stamps, nil
+}
+
+// diskPackage is the state of a package restored from the disk cache. Its
+// files are parsed and type-checked against the packages it imports the first
+// time its syntax or its type information is used.
+//
+// An evicted package is loaded the same way, but its types are the ones of
+// the check instead, so that they are the objects of its type information. It is only loaded if its files have not changed since it was
+// evicted.
+type diskPackage struct {
+	stamps  []fileStamp
+	missing bool
+	evicted bool
+
+	once      sync.Once
+	done      int32
+	syntax    []*ast.File
+	typesInfo *types.Info
+	types     *types.Package
+}
+
+// isLoaded reports whether the files of the package have been loaded.
+func (d *diskPackage) isLoaded() bool {
+	return atomic.LoadInt32(&d.done) == 1
+}
+
+// fresh reports whether the files of the package have not changed since it
+// was saved.
+func (d *diskPackage) fresh() bool {
+	if d.missing {
+		return false
+	}
+	stamps, err := stampFiles(filenames(d.stamps))
+	if err != nil {
+		return false
+	}
+	for i, stamp := range stamps {
+		if stamp.Size != d.stamps[i].Size || !stamp.ModTime.Equal(d.stamps[i].ModTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *diskPackage) load(pkg *Package) *diskPackage {
+	d.once.Do(func() {
+		defer atomic.StoreInt32(&d.done, 1)
+
+		d.typesInfo = &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		}
+		if d.evicted && !d.fresh() {
+			// The types of the package are the ones of the old files: it is
+			// left empty until the global cache is rebuilt.
+			log.Printf("%s changed since it was evicted", pkg.id)
+			return
+		}
+
+		for _, filename := range pkg.files {
+			file, err := parser.ParseFile(pkg.fset, filename, nil, parser.AllErrors|parser.ParseComments)
+			if file != nil {
+				d.syntax = append(d.syntax, file)
+			}
+			if err != nil {
+				log.Printf("parse %s: %s", filename, err)
+			}
+		}
+		// The objects of the package are the ones of this check, but its
+		// imports are the ones of the export data.
+		cfg := &types.Config{
+			Importer: packageImports{pkg.types},
+			Error:    func(error) {},
+		}
+		checked := types.NewPackage(pkg.pkgPath, pkg.name)
+		_ = types.NewChecker(cfg, pkg.fset, checked, d.typesInfo).Files(d.syntax)
+		if d.evicted {
+			d.types = checked
+		}
+	})
+	return d
+}
+
+func filenames(stamps []fileStamp) []string {
+	names := make([]string, 0, len(stamps))
+	for _, stamp := range stamps {
+		names = append(names, stamp.Name)
+	}
+	return names
+}
+
+// isFresh reports whether pkg was restored from the disk cache, and its files
+// and the ones of its dependencies have not changed since.
+func isFresh(pkg *Package, memo map[string]bool) bool {
+	if fresh, ok := memo[pkg.id]; ok {
+		return fresh
+	}
+	memo[pkg.id] = false
+	fresh := pkg.disk != nil && pkg.disk.fresh()
+	for _, imported := range pkg.imports {
+		if !fresh {
+			break
+		}
+		fresh = isFresh(imported, memo)
+	}
+	memo[pkg.id] = fresh
+	return fresh
+}
+
+// loadPackages loads the packages matching the patterns into the new cache
+// of the project. The packages restored from the disk cache which are fresh
+// are reused instead, and their test variants are not loaded.
+func (p *Project) loadPackages(cfg *packages.Config, patterns ...string) error {
+	if len(p.restored) == 0 {
+		pkgs, err := p.loadAll(cfg, patterns...)
+		if err != nil {
+			return err
+		}
+		p.setCache(pkgs)
+		return nil
+	}
+
+	listed, err := p.listPackages(cfg, patterns...)
+	if err != nil {
+		return err
+	}
+	memo := map[string]bool{}
+	var stale []string
+	for _, l := range listed {
+		// The test variants are loaded with their package.
+		if l.ID != l.PkgPath {
+			continue
+		}
+		if pkg := p.restored[l.ID]; pkg != nil && sameStrings(pkg.files, l.CompiledGoFiles) && isFresh(pkg, memo) {
+			p.newCache.AddRestored(pkg)
+			continue
+		}
+		stale = append(stale, l.PkgPath)
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	pkgs, err := p.loadAll(cfg, stale...)
+	if err != nil {
+		return err
+	}
+	p.setCache(pkgs)
+	return nil
+}
+
+// restoreCache reads the packages of the disk cache of the project.
+func (p *Project) restoreCache() {
+	p.disk = newDiskCache(p.rootDir)
+	restored, err := p.disk.restore(p.view.Config.Fset)
+	if err != nil {
+		p.notify(err)
+		return
+	}
+	p.restored = restored
+	if len(restored) > 0 {
+		p.notifyLog(fmt.Sprintf("restored %d packages from %s", len(restored), p.disk.dir))
+	}
+}
+
+// saveCache writes the packages of the global cache to the disk cache of the
+// project, but the test variants, the packages with errors, and the ones
+// whose files were modified after since or are being edited.
+func (p *Project) saveCache(since time.Time) {
+	if p.disk == nil {
+		return
+	}
+
+	v := p.getView()
+	v.mu.Lock()
+	edited := make(map[string]bool, len(v.Config.Overlay))
+	for filename := range v.Config.Overlay {
+		edited[filename] = true
+	}
+	cache := v.gcache
+	v.mu.Unlock()
+
+	var pkgs []*Package
+	cache.RLock()
+	for id, gp := range cache.idMap {
+		pkg := gp.pkg
+		if id != pkg.pkgPath || pkg.types == nil || len(pkg.errors) > 0 || len(pkg.files) == 0 {
+			continue
+		}
+		ok := true
+		for _, filename := range pkg.files {
+			fi, err := os.Stat(filename)
+			if err != nil || edited[filename] || fi.ModTime().After(since) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	cache.RUnlock()
+
+	if err := p.disk.save(v.Config.Fset, pkgs); err != nil {
+		p.notify(fmt.Errorf("write disk cache %s: %s", p.disk.dir, err))
+	}
+}
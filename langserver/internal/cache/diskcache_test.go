@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"go/ast"
+	goimporter "go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "p.go")
+	src := "package p\n\nimport \"strings\"\n\nfunc Upper(s string) string { return strings.ToUpper(s) }\n"
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &types.Config{Importer: goimporter.ForCompiler(fset, "source", nil)}
+	typ, err := cfg.Check("example.com/p", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strs := typ.Imports()[0]
+	pkgs := []*Package{
+		{id: "example.com/p", pkgPath: "example.com/p", name: "p", files: []string{filename}, types: typ},
+		{id: "strings", pkgPath: "strings", name: "strings", files: []string{filename}, types: strs},
+	}
+	pkgs[0].imports = map[string]*Package{"strings": pkgs[1]}
+
+	d := &diskCache{dir: filepath.Join(dir, "cache")}
+	if err := d.save(fset, pkgs); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := d.restore(token.NewFileSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := restored["example.com/p"]
+	if pkg == nil || len(restored) != 2 {
+		t.Fatalf("restored %v", restored)
+	}
+	if pkg.GetImport("strings") != restored["strings"] {
+		t.Error("the import of strings was not restored")
+	}
+	if obj := pkg.GetTypes().Scope().Lookup("Upper"); obj == nil || obj.Type().String() != "func(s string) string" {
+		t.Errorf("Upper is %v", obj)
+	}
+	if !isFresh(pkg, map[string]bool{}) {
+		t.Error("the restored package is not fresh")
+	}
+
+	syntax := pkg.GetSyntax()
+	if len(syntax) != 1 {
+		t.Fatalf("got %d files", len(syntax))
+	}
+	fn := syntax[0].Decls[1].(*ast.FuncDecl)
+	if obj := pkg.GetTypesInfo().Defs[fn.Name]; obj == nil || obj.Name() != "Upper" {
+		t.Errorf("the name of Upper is defined as %v", obj)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filename, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if isFresh(pkg, map[string]bool{}) {
+		t.Error("the restored package is fresh after its file was modified")
+	}
+}
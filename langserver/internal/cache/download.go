@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// maxDownloads is the number of the modules downloaded concurrently.
+const maxDownloads = 4
+
+// downloadedModule is a module printed by go mod download -json.
+type downloadedModule struct {
+	Path    string
+	Version string
+	Error   string
+}
+
+// missingModules returns the modules of moduleMap which are not in the module
+// cache modCache, sorted by path: the go command would download them while
+// listing the packages, without any feedback. The modules replaced by a local
+// directory are never downloaded, and the other replacements are downloaded
+// instead of the modules they replace.
+func missingModules(moduleMap map[string]moduleInfo, modCache string) []moduleInfo {
+	var missing []moduleInfo
+	for _, module := range moduleMap {
+		if module.Main || module.isLocalReplace() || module.Version == "" {
+			continue
+		}
+		if module.Replace != nil {
+			module = *module.Replace
+		}
+		if _, err := os.Stat(filepath.Join(modCache, escapeModulePath(module.Path)+"@"+escapeModulePath(module.Version))); err != nil {
+			missing = append(missing, module)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Path < missing[j].Path
+	})
+	return missing
+}
+
+// escapeModulePath escapes the upper-case letters of a module path or
+// version as the module cache does, e.g. "github.com/BurntSushi/toml" is
+// "github.com/!burnt!sushi/toml".
+func escapeModulePath(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// modCache returns the module cache of the project.
+func (p *Project) modCache() string {
+	if dir := p.goEnv["GOMODCACHE"]; dir != "" {
+		return dir
+	}
+	return filepath.Join(gopaths[0], "pkg", "mod")
+}
+
+// downloadModules downloads the modules required by m which are missing
+// from the module cache with go mod download, reporting the progress of the
+// indexing if there is one. The downloads stop when the indexing is
+// canceled. Nothing is downloaded with GOPROXY=off. The modules which are
+// not downloaded are left to go list to report, and to MissingModules.
+func (m *module) downloadModules() {
+	p := m.project
+	p.setMissingModules(nil)
+	if _, err := os.Stat(filepath.Join(m.rootDir, vendor, "modules.txt")); err == nil {
+		return
+	}
+	m.mu.RLock()
+	missing := missingModules(m.moduleMap, p.modCache())
+	m.mu.RUnlock()
+	if len(missing) == 0 {
+		return
+	}
+	if p.goEnv["GOPROXY"] == "off" {
+		p.setMissingModules(missing)
+		return
+	}
+
+	ctx := p.context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p.indexingMu.Lock()
+	ix := p.indexing
+	p.indexingMu.Unlock()
+	if ix != nil {
+		ctx = ix.ctx
+	}
+
+	p.notifyLog(fmt.Sprintf("downloading %d modules", len(missing)))
+	var mu sync.Mutex
+	done := 0
+	var failed []moduleInfo
+	sem := make(chan struct{}, maxDownloads)
+	var wg sync.WaitGroup
+	for _, module := range missing {
+		module := module
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := m.download(ctx, module)
+
+			mu.Lock()
+			done++
+			n := done
+			if err != nil {
+				failed = append(failed, module)
+			}
+			mu.Unlock()
+			if err != nil {
+				p.notifyLog(err.Error())
+			}
+			if ix != nil {
+				ix.downloaded(n, len(missing), module)
+			}
+		}()
+	}
+	wg.Wait()
+	p.setMissingModules(failed)
+}
+
+// setMissingModules records the modules which could not be downloaded.
+func (p *Project) setMissingModules(modules []moduleInfo) {
+	var missing []string
+	for _, module := range modules {
+		missing = append(missing, module.Path+"@"+module.Version)
+	}
+	sort.Strings(missing)
+
+	p.missingMu.Lock()
+	defer p.missingMu.Unlock()
+	p.missing = missing
+}
+
+// MissingModules returns the modules required by the workspace which are
+// missing from the module cache and could not be downloaded, e.g. with
+// GOPROXY=off, as "path@version".
+func (p *Project) MissingModules() []string {
+	p.missingMu.Lock()
+	defer p.missingMu.Unlock()
+	return append([]string(nil), p.missing...)
+}
+
+// download downloads module with go mod download.
+func (m *module) download(ctx context.Context, module moduleInfo) error {
+	version := module.Path + "@" + module.Version
+	buf, err := invokeGo(ctx, m.rootDir, m.project.view.Config.Env, "mod", "download", "-json", version)
+	if err != nil {
+		return err
+	}
+	var downloaded downloadedModule
+	if err := json.Unmarshal(buf.Bytes(), &downloaded); err != nil {
+		return fmt.Errorf("go mod download %s: %s", version, err)
+	}
+	if downloaded.Error != "" {
+		return fmt.Errorf("go mod download %s: %s", version, downloaded.Error)
+	}
+	return nil
+}
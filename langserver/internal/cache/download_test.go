@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissingModules(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	modCache, err := ioutil.TempDir("", "bingo-modcache")
+	require.NoError(err)
+	defer os.RemoveAll(modCache)
+	require.NoError(os.MkdirAll(filepath.Join(modCache, "example.com/y@v1.0.0"), 0755))
+
+	m := testModule(t, testGoMod)
+	var missing []string
+	for _, module := range missingModules(m.moduleMap, modCache) {
+		missing = append(missing, module.Path+"@"+module.Version)
+	}
+	require.Equal([]string{"example.com/zz@v1.1.0"}, missing, "the replacement of z is downloaded, but not the local one of x")
+	require.Equal("github.com/!burnt!sushi/toml@v0.3.1", escapeModulePath("github.com/BurntSushi/toml@v0.3.1"))
+}
+
+func TestDownloadProgress(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	conn := &recorder{}
+	p := &Project{conn: conn}
+	p.SetProgress(WorkDoneProgress, "init")
+	p.beginIndexing(context.Background())
+	p.indexing.downloaded(1, 2, moduleInfo{Path: "example.com/a", Version: "v1.0.0"})
+	p.endIndexing()
+
+	report := conn.params[1].(*protocol.ProgressParams).Value.(*protocol.WorkDoneProgressReport)
+	require.Equal("downloaded 1/2 modules (example.com/a@v1.0.0)", report.Message)
+	require.Equal(0, report.Percentage)
+
+	conn = &recorder{}
+	p = &Project{conn: conn}
+	p.SetProgress(MessageProgress, nil)
+	p.beginIndexing(context.Background())
+	for n := 1; n <= 20; n++ {
+		p.indexing.downloaded(n, 20, moduleInfo{Path: "example.com/a", Version: "v1.0.0"})
+	}
+	p.endIndexing()
+	require.Len(conn.params, 12, "a message per 10% of the modules")
+	require.Equal("Loading packages: downloaded 2/20 modules (example.com/a@v1.0.0)", conn.params[1].(*lsp.ShowMessageParams).Message)
+}
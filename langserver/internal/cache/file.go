@@ -20,6 +20,7 @@ type File struct {
 	view    *View
 	active  bool
 	content []byte
+	lines   *span.LineIndex
 	ast     *ast.File
 	token   *token.File
 	pkg     *Package
@@ -43,6 +44,22 @@ func (f *File) GetContent(ctx context.Context) []byte {
 	return f.content
 }
 
+// GetLineIndex returns the line index of the contents of the file. It is
+// shared by every request until the contents change.
+func (f *File) GetLineIndex(ctx context.Context) *span.LineIndex {
+	f.view.mu.Lock()
+	defer f.view.mu.Unlock()
+
+	if ctx.Err() == nil {
+		f.read(ctx)
+	}
+
+	if f.lines == nil {
+		f.lines = span.NewLineIndex(f.content)
+	}
+	return f.lines
+}
+
 func (f *File) GetFileSet(ctx context.Context) *token.FileSet {
 	return f.view.Config.Fset
 }
@@ -88,21 +105,37 @@ func (f *File) GetPackage(ctx context.Context) source.Package {
 	return f.pkg
 }
 
+// GetCheckedPackage returns the package the file was last type-checked in,
+// without type-checking its pending changes. The file is type-checked if it
+// never was.
+func (f *File) GetCheckedPackage(ctx context.Context) source.Package {
+	f.view.mu.Lock()
+	pkg := f.pkg
+	f.view.mu.Unlock()
+
+	if pkg == nil {
+		if filename, err := f.uri.Filename(); err == nil {
+			pkg = f.view.cachedPackage(filename)
+		}
+	}
+	if pkg == nil {
+		return f.GetPackage(ctx)
+	}
+	return pkg
+}
+
 // read is the internal part of GetContent. It assumes that the caller is
 // holding the mutex of the file's view.
 func (f *File) read(ctx context.Context) {
-	if f.content != nil {
-		if len(f.view.contentChanges) == 0 {
-			return
-		}
-
+	if len(f.view.contentChanges) > 0 {
+		// The pending changes include the content of a document which was
+		// just opened.
 		f.view.mcache.mu.Lock()
-		err := f.view.applyContentChanges(ctx)
+		f.view.applyContentChanges(ctx)
 		f.view.mcache.mu.Unlock()
-
-		if err == nil {
-			return
-		}
+	}
+	if f.content != nil {
+		return
 	}
 	// We don't know the content yet, so read it.
 	filename, err := f.uri.Filename()
@@ -114,6 +147,7 @@ func (f *File) read(ctx context.Context) {
 		return
 	}
 	f.content = content
+	f.lines = nil
 }
 
 // isPopulated returns true if all of the computed fields of the file are set.
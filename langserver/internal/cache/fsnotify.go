@@ -33,6 +33,8 @@ func (s *fsSubject) notify() {
 
 		for {
 			select {
+			case <-s.observer.getContext().Done():
+				return
 			case event, ok := <-watcher.Events:
 				if !ok {
 					return
@@ -2,8 +2,6 @@ package cache
 
 import (
 	"sync"
-
-	"golang.org/x/tools/go/packages"
 )
 
 type gopath struct {
@@ -47,7 +45,7 @@ func (p *gopath) buildCache() error {
 
 	cfg := p.project.view.Config
 	cfg.Dir = p.rootDir
-	cfg.Mode = packages.LoadAllSyntax
+	cfg.Mode = p.project.loadProfile.mode()
 
 	var pattern string
 	if p.underGoroot {
@@ -56,11 +54,5 @@ func (p *gopath) buildCache() error {
 		pattern = p.importPath + "/..."
 	}
 
-	pkgs, err := packages.Load(&cfg, pattern)
-	if err != nil {
-		return err
-	}
-
-	p.project.setCache(pkgs)
-	return nil
+	return p.project.loadPackages(&cfg, pattern)
 }
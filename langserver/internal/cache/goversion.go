@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// goVersionCache caches the go directives of the go.mod files.
+type goVersionCache struct {
+	mu      sync.Mutex
+	modules map[string]goDirective
+}
+
+// goDirective is the go directive of a go.mod file.
+type goDirective struct {
+	modTime time.Time
+
+	// version is the language version of the module, e.g. "go1.18", or
+	// empty if the go.mod file has no go directive.
+	version string
+}
+
+// languageVersion returns the language version of the go directive of the
+// go.mod file of the module of dir, e.g. "go1.18", or empty if dir is not in
+// a module or if its go.mod file has no go directive. The modules of a
+// monorepo may declare different versions, which decide e.g. whether type
+// parameters are available.
+func (c *goVersionCache) languageVersion(dir string) string {
+	goMod := findGoMod(dir)
+	if goMod == "" {
+		return ""
+	}
+	info, err := os.Stat(goMod)
+	if err != nil {
+		return ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := c.modules[goMod]; ok && d.modTime.Equal(info.ModTime()) {
+		return d.version
+	}
+	version, _, _ := GoModVersion(goMod)
+	if c.modules == nil {
+		c.modules = make(map[string]goDirective)
+	}
+	c.modules[goMod] = goDirective{modTime: info.ModTime(), version: version}
+	return version
+}
+
+// findGoMod returns the go.mod file of the innermost module containing dir,
+// or empty if there is none.
+func findGoMod(dir string) string {
+	for {
+		goMod := filepath.Join(dir, "go.mod")
+		if info, err := os.Stat(goMod); err == nil && !info.IsDir() {
+			return goMod
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// GoModVersion returns the language version of the go directive of the
+// go.mod file filename, e.g. "go1.18" for "go 1.18.2", and its zero-based
+// line. The version is empty if there is no go directive.
+func GoModVersion(filename string) (version string, line int, err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", 0, err
+	}
+	version, line = parseGoDirective(data)
+	return version, line, nil
+}
+
+// parseGoDirective returns the language version of the go directive of the
+// go.mod file data and its zero-based line.
+func parseGoDirective(data []byte) (string, int) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 0; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 || fields[0] != "go" {
+			continue
+		}
+		if major, minor, ok := parseGoVersion("go" + fields[1]); ok {
+			return fmt.Sprintf("go%d.%d", major, minor), line
+		}
+	}
+	return "", 0
+}
+
+// CompareGoVersions returns -1, 0 or 1 whether the language version of a is
+// older than, the same as or newer than the one of b. The patch releases and
+// the pre-releases are the language version of their minor release.
+func CompareGoVersions(a, b string) int {
+	aMajor, aMinor, _ := parseGoVersion(a)
+	bMajor, bMinor, _ := parseGoVersion(b)
+	if aMajor != bMajor {
+		return compareInts(aMajor, bMajor)
+	}
+	return compareInts(aMinor, bMinor)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// parseGoVersion returns the major and the minor versions of version, e.g.
+// 1 and 18 for "go1.18.2" or "go1.18rc1".
+func parseGoVersion(version string) (major, minor int, ok bool) {
+	if !strings.HasPrefix(version, "go") {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(version[len("go"):], ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	if len(parts) == 1 {
+		return major, 0, true
+	}
+	// The pre-releases have no patch version, e.g. go1.22rc1.
+	digits := strings.IndexFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' })
+	if digits == 0 {
+		return 0, 0, false
+	}
+	if digits > 0 {
+		parts[1] = parts[1][:digits]
+	}
+	minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGoDirective(t *testing.T) {
+	tests := []struct {
+		goMod   string
+		version string
+		line    int
+	}{
+		{"module example.com/m\n\ngo 1.18\n", "go1.18", 2},
+		{"module example.com/m\n\ngo 1.21.3 // patch\n", "go1.21", 2},
+		{"module example.com/m\ngo 1.22rc1\n", "go1.22", 1},
+		{"module example.com/m\n\nrequire example.com/go v1.0.0\n", "", 0},
+		{"module example.com/m\n\n// go 1.20\n", "", 0},
+	}
+	for _, test := range tests {
+		version, line := parseGoDirective([]byte(test.goMod))
+		if version != test.version || line != test.line {
+			t.Errorf("parseGoDirective(%q) = %q, %d, want %q, %d", test.goMod, version, line, test.version, test.line)
+		}
+	}
+}
+
+func TestCompareGoVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"go1.18", "go1.18", 0},
+		{"go1.18", "go1.18.5", 0},
+		{"go1.9", "go1.18", -1},
+		{"go1.22rc1", "go1.21.4", 1},
+		{"go2.0", "go1.30", 1},
+	}
+	for _, test := range tests {
+		if got := CompareGoVersions(test.a, test.b); got != test.want {
+			t.Errorf("CompareGoVersions(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestLanguageVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-goversion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filename)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/root\n\ngo 1.16\n")
+	write("legacy/pkg/a.go", "package pkg\n")
+	write("modern/go.mod", "module example.com/modern\n\ngo 1.21\n")
+	write("modern/pkg/a.go", "package pkg\n")
+
+	var c goVersionCache
+	if got := c.languageVersion(filepath.Join(dir, "legacy", "pkg")); got != "go1.16" {
+		t.Errorf("language version of the root module: got %q, want go1.16", got)
+	}
+	if got := c.languageVersion(filepath.Join(dir, "modern", "pkg")); got != "go1.21" {
+		t.Errorf("language version of the nested module: got %q, want go1.21", got)
+	}
+}
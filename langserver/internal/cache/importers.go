@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"sort"
+)
+
+// addImporter adds pkg to the importers of its imports. It is assumed that
+// the caller holds the mutex of the cache.
+func (c *GlobalCache) addImporter(pkg *Package) {
+	for _, imported := range pkg.imports {
+		if imported == nil {
+			continue
+		}
+		ids := c.importerMap[imported.pkgPath]
+		if ids == nil {
+			ids = make(map[string]bool)
+			c.importerMap[imported.pkgPath] = ids
+		}
+		ids[pkg.id] = true
+	}
+}
+
+// removeImporter removes pkg from the importers of its imports. It is
+// assumed that the caller holds the mutex of the cache.
+func (c *GlobalCache) removeImporter(pkg *Package) {
+	for _, imported := range pkg.imports {
+		if imported == nil {
+			continue
+		}
+		ids := c.importerMap[imported.pkgPath]
+		delete(ids, pkg.id)
+		if len(ids) == 0 {
+			delete(c.importerMap, imported.pkgPath)
+		}
+	}
+}
+
+// Importers returns the cached packages which import the package path
+// pkgPath, sorted by ID, the test variants included. With transitive, the
+// packages which import it through other packages are returned too.
+func (c *GlobalCache) Importers(pkgPath string, transitive bool) []*Package {
+	if c == nil {
+		return nil
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	seen := map[string]bool{}
+	var importers []*Package
+	queue := []string{pkgPath}
+	visited := map[string]bool{pkgPath: true}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		for id := range c.importerMap[path] {
+			p := c.idMap[id]
+			if p == nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+			importers = append(importers, p.pkg)
+			if transitive && !visited[p.pkg.pkgPath] {
+				visited[p.pkg.pkgPath] = true
+				queue = append(queue, p.pkg.pkgPath)
+			}
+		}
+	}
+	sort.Slice(importers, func(i, j int) bool {
+		return importers[i].id < importers[j].id
+	})
+	return importers
+}
+
+// IsImporter reports whether the cached package id imports the package path
+// pkgPath.
+func (c *GlobalCache) IsImporter(id, pkgPath string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	return c.importerMap[pkgPath][id]
+}
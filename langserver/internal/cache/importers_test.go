@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImporters(t *testing.T) {
+	a := &Package{id: "a", pkgPath: "a"}
+	b := &Package{id: "b", pkgPath: "b", imports: map[string]*Package{"a": a}}
+	c := &Package{id: "c", pkgPath: "c", imports: map[string]*Package{"b": b}}
+	ctest := &Package{id: "c [c.test]", pkgPath: "c", imports: map[string]*Package{"a": a, "b": b}}
+
+	cache := NewCache()
+	for _, pkg := range []*Package{a, b, c, ctest} {
+		cache.Put(pkg)
+	}
+	ids := func(pkgs []*Package) []string {
+		var ids []string
+		for _, pkg := range pkgs {
+			ids = append(ids, pkg.id)
+		}
+		return ids
+	}
+
+	if got, want := ids(cache.Importers("a", false)), []string{"b", "c [c.test]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("importers of a: got %v, want %v", got, want)
+	}
+	if got, want := ids(cache.Importers("a", true)), []string{"b", "c", "c [c.test]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("transitive importers of a: got %v, want %v", got, want)
+	}
+	if !cache.IsImporter("c", "b") || cache.IsImporter("c", "a") {
+		t.Error("IsImporter")
+	}
+
+	// A package put again with other imports, or deleted, no longer imports
+	// the packages it imported.
+	cache.Put(&Package{id: "b", pkgPath: "b"})
+	if got := cache.Importers("a", false); len(got) != 1 || got[0] != ctest {
+		t.Errorf("importers of a after b changed: %v", ids(got))
+	}
+	cache.Delete(ctest.id)
+	if got := cache.Importers("a", true); len(got) != 0 {
+		t.Errorf("importers of a after the test variant is deleted: %v", ids(got))
+	}
+	if len(cache.importerMap) != 1 {
+		t.Errorf("the index is not cleaned up: %v", cache.importerMap)
+	}
+}
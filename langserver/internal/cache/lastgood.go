@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"bytes"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/gcexportdata"
+)
+
+// maxLastGood is the number of packages whose last good version is kept.
+const maxLastGood = 64
+
+// lastGoodCache holds the export data of the last version of the packages
+// which had no parse errors, by package ID, so that the test variants of a
+// package do not collide. Only their types are kept, and only those of the
+// maxLastGood packages stored last.
+type lastGoodCache struct {
+	data  map[string][]byte
+	order []string // the IDs of data, the least recently stored first
+}
+
+func newLastGoodCache() *lastGoodCache {
+	return &lastGoodCache{data: make(map[string][]byte)}
+}
+
+// put stores the export data of pkg, which has no parse errors.
+func (c *lastGoodCache) put(pkg *Package) {
+	if pkg.types == nil || pkg.types == types.Unsafe {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gcexportdata.Write(&buf, pkg.fset, pkg.types); err != nil {
+		return
+	}
+
+	if _, ok := c.data[pkg.id]; ok {
+		for i, id := range c.order {
+			if id == pkg.id {
+				c.order = append(c.order[:i:i], c.order[i+1:]...)
+				break
+			}
+		}
+	}
+	c.data[pkg.id] = buf.Bytes()
+	c.order = append(c.order, pkg.id)
+	for len(c.order) > maxLastGood {
+		delete(c.data, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// get returns the last good version of the package of meta, whose types are
+// read from its export data against the packages of imports, or nil if there
+// is none.
+func (c *lastGoodCache) get(fset *token.FileSet, meta *metadata, imports map[string]*types.Package) *Package {
+	data, ok := c.data[meta.id]
+	if !ok {
+		return nil
+	}
+	// The reader adds the packages it creates to its map.
+	m := make(map[string]*types.Package, len(imports))
+	for path, typ := range imports {
+		m[path] = typ
+	}
+	typ, err := gcexportdata.Read(bytes.NewReader(data), fset, m, meta.pkgPath)
+	if err != nil {
+		return nil
+	}
+	return &Package{
+		id:      meta.id,
+		pkgPath: meta.pkgPath,
+		name:    meta.name,
+		files:   meta.files,
+		imports: make(map[string]*Package),
+		types:   typ,
+		fset:    fset,
+	}
+}
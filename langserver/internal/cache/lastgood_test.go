@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestLastGoodImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-lastgood")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.16\n",
+		"a/a.go": "package a\n\nfunc A() int { return 1 }\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nvar B = a.A()\n",
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	v := NewView(&packages.Config{
+		Context: ctx,
+		Dir:     dir,
+		Env:     append(os.Environ(), "GO111MODULE=on", "GOFLAGS=-mod=mod", "GOPROXY=off"),
+		Fset:    token.NewFileSet(),
+		Tests:   true,
+		ParseFile: func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		},
+	})
+	check := func(name string) *types.Package {
+		f, err := v.GetFile(ctx, span.FileURI(filepath.Join(dir, filepath.FromSlash(name))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg := f.GetPackage(ctx)
+		if pkg == nil {
+			t.Fatalf("no package of %s", name)
+		}
+		if errs := pkg.GetErrors(); len(errs) > 0 {
+			t.Errorf("errors of the package of %s: %v", name, errs)
+		}
+		return pkg.GetTypes()
+	}
+	check("a/a.go")
+	check("b/b.go")
+
+	// The edit of a drops A, which b still resolves.
+	if err := v.SetContent(ctx, span.FileURI(filepath.Join(dir, "a", "a.go")), []byte("package a\n\nvar x = (\n\nfunc A() int { return 1 }\n")); err != nil {
+		t.Fatal(err)
+	}
+	b := check("b/b.go")
+	if typ := b.Scope().Lookup("B").Type(); typ.String() != "int" {
+		t.Errorf("the type of B is %s, want int", typ)
+	}
+}
+
+func TestLastGoodCache(t *testing.T) {
+	fset := token.NewFileSet()
+	newPackage := func(id, pkgPath, decl string) *Package {
+		file, err := parser.ParseFile(fset, id+".go", "package p\n\n"+decl+"\n", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ, err := new(types.Config).Check(pkgPath, fset, []*ast.File{file}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Package{id: id, pkgPath: pkgPath, name: "p", types: typ, fset: fset}
+	}
+	get := func(c *lastGoodCache, id, pkgPath string) *types.Package {
+		pkg := c.get(fset, &metadata{id: id, pkgPath: pkgPath, name: "p"}, nil)
+		if pkg == nil {
+			return nil
+		}
+		return pkg.types
+	}
+
+	// The test variant of a package does not replace it.
+	c := newLastGoodCache()
+	c.put(newPackage("example.com/p", "example.com/p", "func A() {}"))
+	c.put(newPackage("example.com/p [example.com/p.test]", "example.com/p", "func A() {}\n\nfunc Helper() {}"))
+	if p := get(c, "example.com/p", "example.com/p"); p == nil || p.Scope().Lookup("Helper") != nil {
+		t.Errorf("the last good package is %v", p)
+	}
+	if p := get(c, "example.com/p [example.com/p.test]", "example.com/p"); p == nil || p.Scope().Lookup("Helper") == nil {
+		t.Errorf("the last good test variant is %v", p)
+	}
+
+	// The packages stored first are evicted.
+	for i := 0; i < maxLastGood; i++ {
+		c.put(newPackage(fmt.Sprintf("example.com/p%d", i), fmt.Sprintf("example.com/p%d", i), "var V int"))
+	}
+	if len(c.data) != maxLastGood || get(c, "example.com/p", "example.com/p") != nil {
+		t.Errorf("%d packages are kept, want %d", len(c.data), maxLastGood)
+	}
+	if get(c, "example.com/p0", "example.com/p0") == nil {
+		t.Error("a package stored last was evicted")
+	}
+}
@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+// memoryPerSourceByte is a rough estimate of the memory of the syntax and the
+// type information of a package per byte of its source.
+const memoryPerSourceByte = 20
+
+// freeOSMemoryThreshold is the estimated memory of the packages dropped from
+// the global caches from which the memory of the process is returned to the
+// operating system, see releaseMemory.
+const freeOSMemoryThreshold = 256 << 20
+
+// freeOSMemoryDelay is how long the memory of the dropped packages is
+// returned after they reach freeOSMemoryThreshold, once they are no longer
+// used by the requests in progress.
+const freeOSMemoryDelay = 10 * time.Second
+
+// released is the estimated memory of the packages dropped since the memory
+// of the process was last returned to the operating system.
+var released struct {
+	sync.Mutex
+	bytes int64
+	timer *time.Timer
+}
+
+// releaseMemory records that packages of an estimated memory of size were
+// evicted or dropped, e.g. the global cache of the previous branch. The heap
+// shrinks at the next garbage collection, but the runtime keeps the memory
+// it got from the system for minutes: once the dropped packages reach
+// freeOSMemoryThreshold, it is returned with debug.FreeOSMemory.
+func releaseMemory(size int64) {
+	released.Lock()
+	defer released.Unlock()
+
+	released.bytes += size
+	if released.bytes < freeOSMemoryThreshold || released.timer != nil {
+		return
+	}
+	released.timer = time.AfterFunc(freeOSMemoryDelay, func() {
+		released.Lock()
+		released.bytes = 0
+		released.timer = nil
+		released.Unlock()
+
+		debug.FreeOSMemory()
+	})
+}
+
+// MemoryUsage is the estimated memory of the syntax and the type information
+// of the packages of a global cache.
+type MemoryUsage struct {
+	// Limit is the memory budget of the packages which are not pinned, 0 if
+	// it is unbounded.
+	Limit int64 `json:"limit"`
+
+	// Resident is the memory of the packages whose syntax and type
+	// information are loaded.
+	Resident int64 `json:"resident"`
+
+	// Evictions is the number of packages evicted since the cache was
+	// created.
+	Evictions int64 `json:"evictions"`
+
+	// Packages are the resident packages, the largest first.
+	Packages []PackageMemory `json:"packages"`
+}
+
+// PackageMemory is the estimated memory of a resident package.
+type PackageMemory struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+
+	// Pinned is set for the packages of the workspace, which are never
+	// evicted.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// SetMemoryLimit bounds the memory of the packages of c which are not pinned
+// to limit bytes, unless limit is 0. The least recently used ones are
+// evicted: their syntax, type information and types are dropped, and they
+// are loaded again when they are used.
+func (c *GlobalCache) SetMemoryLimit(limit int64, pinned func(pkg *Package) bool) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.limit = limit
+	c.pinned = pinned
+	c.evict()
+}
+
+// MemoryUsage returns the estimated memory of the packages of c.
+func (c *GlobalCache) MemoryUsage() MemoryUsage {
+	if c == nil {
+		return MemoryUsage{Packages: []PackageMemory{}}
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	usage := MemoryUsage{Limit: c.limit, Evictions: atomic.LoadInt64(&c.evictions), Packages: []PackageMemory{}}
+	for id, p := range c.idMap {
+		if !p.pkg.resident() {
+			continue
+		}
+		usage.Resident += p.size
+		usage.Packages = append(usage.Packages, PackageMemory{ID: id, Size: p.size, Pinned: c.isPinned(p.pkg)})
+	}
+	sort.Slice(usage.Packages, func(i, j int) bool {
+		if usage.Packages[i].Size != usage.Packages[j].Size {
+			return usage.Packages[i].Size > usage.Packages[j].Size
+		}
+		return usage.Packages[i].ID < usage.Packages[j].ID
+	})
+	return usage
+}
+
+// touch marks p as the most recently used package of c.
+func (c *GlobalCache) touch(p *GlobalPackage) {
+	if p != nil {
+		atomic.StoreInt64(&p.lastUsed, atomic.AddInt64(&c.clock, 1))
+	}
+}
+
+// isPinned reports whether pkg can not be evicted: it is a package of the
+// workspace, or it has no types to check its files against again.
+func (c *GlobalCache) isPinned(pkg *Package) bool {
+	return pkg.types == nil || pkg.pkgPath == BuiltinPkg || c.pinned != nil && c.pinned(pkg)
+}
+
+// evict evicts the least recently used packages of c until the memory of the
+// packages which are not pinned is within the limit of c. It is assumed that
+// the caller holds the lock of c.
+func (c *GlobalCache) evict() {
+	if c.limit <= 0 {
+		return
+	}
+
+	var resident int64
+	var candidates []*GlobalPackage
+	for _, p := range c.idMap {
+		if p.pkg.resident() && !c.isPinned(p.pkg) {
+			resident += p.size
+			candidates = append(candidates, p)
+		}
+	}
+	if resident <= c.limit {
+		return
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return atomic.LoadInt64(&candidates[i].lastUsed) < atomic.LoadInt64(&candidates[j].lastUsed)
+	})
+	var evicted int64
+	for _, p := range candidates {
+		if resident <= c.limit {
+			break
+		}
+		p.pkg.evict()
+		resident -= p.size
+		evicted += p.size
+		atomic.AddInt64(&c.evictions, 1)
+	}
+	releaseMemory(evicted)
+}
+
+// residentMemory returns the estimated memory of the resident packages of c.
+func (c *GlobalCache) residentMemory() int64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	var resident int64
+	for _, p := range c.idMap {
+		if p.pkg.resident() {
+			resident += p.size
+		}
+	}
+	return resident
+}
+
+// estimateMemory returns the estimated memory of the syntax and the type
+// information of pkg, from the size of its source.
+func estimateMemory(pkg *Package) int64 {
+	var size int64
+	if pkg.disk != nil {
+		for _, stamp := range pkg.disk.stamps {
+			size += stamp.Size
+		}
+	} else if pkg.fset != nil {
+		for _, file := range pkg.syntax {
+			if tok := pkg.fset.File(file.Pos()); tok != nil {
+				size += int64(tok.Size())
+			}
+		}
+	}
+	return size * memoryPerSourceByte
+}
+
+// workspacePinner returns the function which pins the packages whose files
+// are in the workspace, according to inWorkspace.
+func workspacePinner(inWorkspace func(filename string) bool) func(pkg *Package) bool {
+	return func(pkg *Package) bool {
+		return len(pkg.files) > 0 && inWorkspace(util.LowerDriver(pkg.files[0]))
+	}
+}
+
+// loaded returns the syntax and the type information of pkg, which are
+// loaded if pkg was restored from the disk cache or evicted.
+func (pkg *Package) loaded() ([]*ast.File, *types.Info) {
+	pkg.mu.Lock()
+	d, syntax, info := pkg.disk, pkg.syntax, pkg.typesInfo
+	pkg.mu.Unlock()
+
+	if d != nil {
+		d = d.load(pkg)
+		return d.syntax, d.typesInfo
+	}
+	return syntax, info
+}
+
+// resident reports whether the syntax and the type information of pkg are
+// loaded.
+func (pkg *Package) resident() bool {
+	pkg.mu.Lock()
+	defer pkg.mu.Unlock()
+
+	if pkg.disk != nil {
+		return pkg.disk.isLoaded()
+	}
+	return pkg.syntax != nil
+}
+
+// loadedTypes returns the types of pkg. The types of an evicted package are
+// the ones of its type information, so that it is loaded first.
+func (pkg *Package) loadedTypes() *types.Package {
+	pkg.mu.Lock()
+	d := pkg.disk
+	pkg.mu.Unlock()
+
+	if d != nil && d.evicted {
+		if d = d.load(pkg); d.types != nil {
+			return d.types
+		}
+	}
+	return pkg.types
+}
+
+// evict drops the syntax, the type information and the types of pkg, which
+// are loaded again from its files the next time they are used.
+func (pkg *Package) evict() {
+	pkg.mu.Lock()
+	defer pkg.mu.Unlock()
+
+	if pkg.disk != nil {
+		pkg.disk = &diskPackage{stamps: pkg.disk.stamps, missing: pkg.disk.missing, evicted: pkg.disk.evicted}
+		return
+	}
+	stamps, err := stampFiles(pkg.files)
+	pkg.disk = &diskPackage{stamps: stamps, missing: err != nil || !sameSizes(pkg.fset, pkg.syntax, stamps), evicted: true}
+	pkg.syntax = nil
+	pkg.typesInfo = nil
+}
+
+// sameSizes reports whether the files of syntax have the sizes of stamps,
+// i.e. whether they have not changed since they were parsed, as far as it
+// can be told.
+func sameSizes(fset *token.FileSet, syntax []*ast.File, stamps []fileStamp) bool {
+	if len(syntax) != len(stamps) {
+		return false
+	}
+	for i, file := range syntax {
+		tok := fset.File(file.Pos())
+		if tok == nil || int64(tok.Size()) != stamps[i].Size {
+			return false
+		}
+	}
+	return true
+}
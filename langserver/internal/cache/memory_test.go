@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fset := token.NewFileSet()
+	newPackage := func(path string) *Package {
+		filename := filepath.Join(dir, path, path+".go")
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		src := "package " + path + "\n\nfunc F() int { return 1 }\n"
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{Defs: map[*ast.Ident]types.Object{}}
+		typ, err := (&types.Config{}).Check(path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Package{id: path, pkgPath: path, name: path, files: []string{filename}, syntax: []*ast.File{f},
+			types: typ, typesInfo: info, fset: fset, imports: map[string]*Package{}}
+	}
+	a, b, c, work := newPackage("a"), newPackage("b"), newPackage("c"), newPackage("work")
+
+	cache := NewCache()
+	size := estimateMemory(a)
+	if size == 0 {
+		t.Fatal("a is estimated to use no memory")
+	}
+	cache.SetMemoryLimit(2*size, func(pkg *Package) bool { return pkg == work })
+	cache.Put(work)
+	cache.Put(a)
+	cache.Put(b)
+	if !a.resident() || !b.resident() {
+		t.Fatal("a package is evicted within the limit")
+	}
+	cache.Put(c)
+	if a.resident() || !b.resident() || !c.resident() || !work.resident() {
+		t.Fatalf("the least recently used package a is not the evicted one: a %t, b %t, c %t, work %t", a.resident(), b.resident(), c.resident(), work.resident())
+	}
+
+	usage := cache.MemoryUsage()
+	if usage.Evictions != 1 || usage.Resident != 2*size+estimateMemory(work) || len(usage.Packages) != 3 {
+		t.Errorf("got usage %+v", usage)
+	}
+
+	// An evicted package is loaded again.
+	if syntax := a.GetSyntax(); len(syntax) != 1 || len(a.GetTypesInfo().Defs) == 0 {
+		t.Fatal("the syntax of a is not loaded again")
+	}
+	if !a.resident() || a.GetTypes() == nil {
+		t.Error("a is not resident after it is used")
+	}
+	for ident, obj := range a.GetTypesInfo().Defs {
+		if ident.Name == "F" && obj != a.GetTypes().Scope().Lookup("F") {
+			t.Error("the types of a are not the ones of its type information after it is loaded again")
+		}
+	}
+
+	cache.GetByID("a")
+	cache.Put(newPackage("d"))
+	if !a.resident() || b.resident() || c.resident() {
+		t.Errorf("b and c should be evicted after a is used: a %t, b %t, c %t", a.resident(), b.resident(), c.resident())
+	}
+
+	// An evicted package whose files changed is not loaded again.
+	if err := ioutil.WriteFile(b.files[0], []byte("package b\n\nfunc G() string { return \"\" }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if syntax := b.GetSyntax(); len(syntax) != 0 || len(b.GetTypesInfo().Defs) != 0 {
+		t.Error("the changed files of b are loaded")
+	}
+	if b.GetTypes().Scope().Lookup("F") == nil {
+		t.Error("the types of b are dropped")
+	}
+}
@@ -3,23 +3,48 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"path/filepath"
+	"sort"
 	"sync"
-	"time"
 
 	"github.com/saibing/bingo/langserver/internal/util"
-
-	"golang.org/x/tools/go/packages"
 )
 
 type moduleInfo struct {
-	Path     string    `json:"Path"`
-	Main     bool      `json:"Main"`
-	Dir      string    `json:"Dir"`
-	GoMod    string    `json:"GoMod"`
-	Version  string    `json:"Version"`
-	Time     time.Time `json:"Time"`
-	Indirect bool      `json:"Indirect"`
+	Path     string `json:"Path"`
+	Main     bool   `json:"Main"`
+	Dir      string `json:"Dir"`
+	Version  string `json:"Version"`
+	Indirect bool   `json:"Indirect"`
+	// Replace is the module replacing this one in go.mod, whose Dir is
+	// the Dir of this one.
+	Replace *moduleInfo `json:"Replace"`
+}
+
+// goModFile is the go.mod file of a module, as printed by go mod edit -json.
+type goModFile struct {
+	Module struct {
+		Path string
+	}
+	Go      string
+	Require []struct {
+		Path     string
+		Version  string
+		Indirect bool
+	}
+	Replace []struct {
+		Old moduleInfo
+		New moduleInfo
+	}
+}
+
+// isLocalReplace reports whether the module is replaced by a local
+// directory, e.g. with replace example.com/x => ../x. The replacements by
+// another module have a version.
+func (info moduleInfo) isLocalReplace() bool {
+	return !info.Main && info.Replace != nil && info.Replace.Version == "" && info.Dir != ""
 }
 
 type module struct {
@@ -40,6 +65,7 @@ func (m *module) init() (err error) {
 		return err
 	}
 
+	m.downloadModules()
 	return m.buildCache()
 }
 
@@ -53,37 +79,109 @@ func (m *module) doInit() error {
 	return nil
 }
 
+// readGoModule reads the modules of the go.mod file of m. Unlike go list -m
+// all, it does not load the module graph, whose modules are not all needed:
+// the go command only loads the modules providing the imported packages, and
+// prunes the graph of the modules declaring go 1.17 or later. The go.mod of
+// an older module only lists its direct requirements, so that its module
+// graph is listed instead.
 func (m *module) readGoModule() (map[string]moduleInfo, error) {
-	buf, err := invokeGo(context.Background(), m.rootDir, "list", "-m", "-json", "all")
+	buf, err := invokeGo(context.Background(), m.rootDir, m.project.view.Config.Env, "mod", "edit", "-json")
 	if err != nil {
 		return nil, err
 	}
 
-	var modules []moduleInfo
+	var modFile goModFile
+	if err := json.Unmarshal(buf.Bytes(), &modFile); err != nil {
+		return nil, fmt.Errorf("go mod edit: %s", err)
+	}
+	if !prunesModuleGraph(modFile.Go) {
+		return m.listModules()
+	}
+	return moduleMapOf(modFile, m.rootDir), nil
+}
 
-	decoder := json.NewDecoder(buf)
+// prunesModuleGraph reports whether the go.mod file of a module declaring
+// the go version goVersion, e.g. "1.17", lists all the modules providing the
+// packages of the module, and so its module graph is pruned.
+func prunesModuleGraph(goVersion string) bool {
+	return goVersion != "" && CompareGoVersions("go"+goVersion, "go1.17") >= 0
+}
+
+// listModules lists the modules of the module graph of m.
+func (m *module) listModules() (map[string]moduleInfo, error) {
+	buf, err := invokeGo(context.Background(), m.rootDir, m.project.view.Config.Env, "list", "-m", "-json", "all")
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeModules(buf)
+}
+
+// decodeModules decodes the modules printed by go list -m -json, by their
+// path and version.
+func decodeModules(r io.Reader) (map[string]moduleInfo, error) {
+	moduleMap := map[string]moduleInfo{}
+	decoder := json.NewDecoder(r)
 	for {
 		module := moduleInfo{}
-		err = decoder.Decode(&module)
+		err := decoder.Decode(&module)
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("go list -m: %s", err)
+		}
+		if module.Dir != "" {
+			module.Dir = util.LowerDriver(module.Dir)
 		}
-		modules = append(modules, module)
+		moduleMap[module.key()] = module
 	}
+	return moduleMap, nil
+}
 
-	moduleMap := map[string]moduleInfo{}
-	for _, module := range modules {
-		if module.Dir == "" {
-			// module define in go.mod but not in ${GOMOD}
+// moduleMapOf returns the main module and the required modules of modFile,
+// the go.mod file of the module in rootDir, by their path and version. The
+// modules replaced by a local directory are in this directory.
+func moduleMapOf(modFile goModFile, rootDir string) map[string]moduleInfo {
+	modules := map[string]moduleInfo{
+		modFile.Module.Path: {Path: modFile.Module.Path, Main: true, Dir: rootDir},
+	}
+	for _, r := range modFile.Require {
+		modules[r.Path] = moduleInfo{Path: r.Path, Version: r.Version, Indirect: r.Indirect}
+	}
+	for _, r := range modFile.Replace {
+		module, ok := modules[r.Old.Path]
+		if !ok || r.Old.Version != "" && r.Old.Version != module.Version {
 			continue
 		}
-		moduleMap[util.LowerDriver(module.Dir)] = module
+		replace := r.New
+		module.Replace = &replace
+		if replace.Version == "" {
+			dir := replace.Path
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(rootDir, dir)
+			}
+			module.Dir = util.LowerDriver(filepath.Clean(dir))
+		}
+		modules[r.Old.Path] = module
 	}
 
-	return moduleMap, nil
+	moduleMap := make(map[string]moduleInfo, len(modules))
+	for _, module := range modules {
+		moduleMap[module.key()] = module
+	}
+	return moduleMap
+}
+
+// key identifies the version of the module used by the main module, so
+// that a change of version, or of replacement, is a new module.
+func (info moduleInfo) key() string {
+	key := info.Path + "@" + info.Version
+	if info.Replace != nil {
+		key += " => " + info.Replace.Path + "@" + info.Replace.Version
+	}
+	return key
 }
 
 func (m *module) initModule(moduleMap map[string]moduleInfo) {
@@ -98,6 +196,24 @@ func (m *module) initModule(moduleMap map[string]moduleInfo) {
 	m.moduleMap = moduleMap
 }
 
+// localReplaces returns the modules replaced by a local directory in the
+// go.mod of m, sorted by path. Their packages are edited along with the
+// packages of m, so they belong to the workspace.
+func (m *module) localReplaces() []moduleInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var replaces []moduleInfo
+	for _, module := range m.moduleMap {
+		if module.isLocalReplace() {
+			replaces = append(replaces, module)
+		}
+	}
+	sort.Slice(replaces, func(i, j int) bool {
+		return replaces[i].Path < replaces[j].Path
+	})
+	return replaces
+}
+
 func (m *module) checkModuleCache() (bool, error) {
 	moduleMap, err := m.readGoModule()
 	if err != nil {
@@ -122,14 +238,15 @@ func (m *module) rebuildCache() (bool, error) {
 		return false, nil
 	}
 
+	m.downloadModules()
 	err = m.buildCache()
 	return err == nil, err
 }
 
 func (m *module) hasChanged(moduleMap map[string]moduleInfo) bool {
-	for dir := range moduleMap {
+	for key := range moduleMap {
 		// there are some new module add into go.mod
-		if _, ok := m.moduleMap[dir]; !ok {
+		if _, ok := m.moduleMap[key]; !ok {
 			return true
 		}
 	}
@@ -143,14 +260,11 @@ func (m *module) buildCache() error {
 
 	cfg := m.project.view.Config
 	cfg.Dir = m.rootDir
-	cfg.Mode = packages.LoadAllSyntax
-	pattern := cfg.Dir + "/..."
-
-	pkgs, err := packages.Load(&cfg, pattern)
-	if err != nil {
-		return err
+	cfg.Mode = m.project.loadProfile.mode()
+	patterns := []string{cfg.Dir + "/..."}
+	for _, module := range m.localReplaces() {
+		patterns = append(patterns, module.Path+"/...")
 	}
 
-	m.project.setCache(pkgs)
-	return nil
+	return m.project.loadPackages(&cfg, patterns...)
 }
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testGoMod is the go mod edit -json output of the go.mod file of the
+// module example.com/ws.
+const testGoMod = `{
+	"Module": {"Path": "example.com/ws"},
+	"Go": "1.17",
+	"Require": [
+		{"Path": "example.com/x", "Version": "v0.0.0-00010101000000-000000000000"},
+		{"Path": "example.com/y", "Version": "v1.0.0", "Indirect": true},
+		{"Path": "example.com/z", "Version": "v1.0.0"}
+	],
+	"Replace": [
+		{"Old": {"Path": "example.com/x"}, "New": {"Path": "../x"}},
+		{"Old": {"Path": "example.com/z", "Version": "v1.0.0"}, "New": {"Path": "example.com/zz", "Version": "v1.1.0"}},
+		{"Old": {"Path": "example.com/unused"}, "New": {"Path": "../unused"}}
+	]
+}`
+
+func testModule(t *testing.T, goMod string) *module {
+	var modFile goModFile
+	if err := json.Unmarshal([]byte(goMod), &modFile); err != nil {
+		t.Fatal(err)
+	}
+	return &module{moduleMap: moduleMapOf(modFile, filepath.FromSlash("/ws"))}
+}
+
+func TestModuleMapOf(t *testing.T) {
+	m := testModule(t, testGoMod)
+	for key, dir := range map[string]string{
+		"example.com/ws@": "/ws",
+		"example.com/x@v0.0.0-00010101000000-000000000000 => ../x@": "/x",
+		"example.com/y@v1.0.0":                          "",
+		"example.com/z@v1.0.0 => example.com/zz@v1.1.0": "",
+	} {
+		module, ok := m.moduleMap[key]
+		if !ok {
+			t.Errorf("missing module %s in %v", key, m.moduleMap)
+			continue
+		}
+		if module.Dir != filepath.FromSlash(dir) && dir != "" {
+			t.Errorf("module %s is in %s, want %s", key, module.Dir, dir)
+		}
+	}
+	if len(m.moduleMap) != 4 {
+		t.Errorf("got %d modules, want 4: %v", len(m.moduleMap), m.moduleMap)
+	}
+
+	// Upgrading a module changes the modules.
+	upgraded := testModule(t, `{"Module": {"Path": "example.com/ws"}, "Require": [{"Path": "example.com/y", "Version": "v1.1.0"}]}`)
+	if !m.hasChanged(upgraded.moduleMap) {
+		t.Error("the upgrade of example.com/y is not a change")
+	}
+}
+
+func TestLocalReplaces(t *testing.T) {
+	m := testModule(t, testGoMod)
+	replaces := m.localReplaces()
+	if len(replaces) != 1 || replaces[0].Path != "example.com/x" {
+		t.Fatalf("got local replaces %v, want example.com/x only", replaces)
+	}
+
+	p := &Project{rootDir: filepath.FromSlash("/ws"), modules: []*module{m}}
+	for filename, want := range map[string]bool{
+		"/ws/a.go":         true,
+		"/x/x.go":          true,
+		"/x/internal/x.go": true,
+		"/xx/x.go":         false,
+		"/unused/u.go":     false,
+	} {
+		if got := p.inWorkspace(filepath.FromSlash(filename)); got != want {
+			t.Errorf("inWorkspace(%s) = %t, want %t", filename, got, want)
+		}
+	}
+}
+
+func TestPrunesModuleGraph(t *testing.T) {
+	for goVersion, want := range map[string]bool{
+		"":       false,
+		"1.11":   false,
+		"1.16":   false,
+		"1.17":   true,
+		"1.21.0": true,
+	} {
+		if got := prunesModuleGraph(goVersion); got != want {
+			t.Errorf("prunesModuleGraph(%q) = %t, want %t", goVersion, got, want)
+		}
+	}
+}
+
+func TestDecodeModules(t *testing.T) {
+	// The go list -m -json all output of a module declaring go 1.16, which
+	// lists the indirect requirements missing from its go.mod.
+	moduleMap, err := decodeModules(strings.NewReader(`{"Path": "example.com/ws", "Main": true, "Dir": "/ws"}
+{"Path": "example.com/x", "Version": "v0.0.0-00010101000000-000000000000", "Replace": {"Path": "../x", "Dir": "/x"}, "Dir": "/x"}
+{"Path": "example.com/y", "Version": "v1.0.0", "Indirect": true}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &module{moduleMap: moduleMap}
+	if _, ok := moduleMap["example.com/y@v1.0.0"]; !ok || len(moduleMap) != 3 {
+		t.Errorf("got modules %v, want example.com/ws, example.com/x and example.com/y", moduleMap)
+	}
+	if replaces := m.localReplaces(); len(replaces) != 1 || replaces[0].Dir != "/x" {
+		t.Errorf("got local replaces %v, want example.com/x in /x", replaces)
+	}
+
+	if _, err := decodeModules(strings.NewReader("{")); err == nil {
+		t.Error("decoded a truncated output")
+	}
+}
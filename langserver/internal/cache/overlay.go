@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// Document is a version of an open document. It is never modified: every
+// change of the document is a new Document, so that it can be read while the
+// document changes.
+type Document struct {
+	URI     span.URI
+	Version int
+	Content []byte
+
+	// Hash is the SHA-256 of Content.
+	Hash string
+
+	linesOnce sync.Once
+	lines     *span.LineIndex
+}
+
+// LineIndex returns the line index of the content of d.
+func (d *Document) LineIndex() *span.LineIndex {
+	d.linesOnce.Do(func() {
+		d.lines = span.NewLineIndex(d.Content)
+	})
+	return d.lines
+}
+
+// DocumentChange is a change of the overlay: Old is nil when the document is
+// opened, and New is nil when it is closed.
+type DocumentChange struct {
+	URI      span.URI
+	Old, New *Document
+}
+
+// Overlay is the versioned store of the open documents of a view, whose
+// contents supersede those of the files. The view is updated on every change,
+// through a subscription.
+type Overlay struct {
+	mu        sync.Mutex
+	documents map[span.URI]*Document
+
+	// opens counts the clients which opened each document with Open and did
+	// not close it yet.
+	opens map[span.URI]int
+
+	// notifyMu is held while the subscribers are notified of a change, so
+	// that they are notified of the changes in the order of the overlay.
+	notifyMu    sync.Mutex
+	subscribers []*subscriber
+}
+
+type subscriber struct {
+	notify func(DocumentChange)
+}
+
+// NewOverlay returns an overlay without documents.
+func NewOverlay() *Overlay {
+	return &Overlay{documents: make(map[span.URI]*Document), opens: make(map[span.URI]int)}
+}
+
+// Get returns the current version of the document uri, or nil if it is not
+// open.
+func (o *Overlay) Get(uri span.URI) *Document {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.documents[uri]
+}
+
+// Documents returns the current versions of the open documents, sorted by
+// URI.
+func (o *Overlay) Documents() []*Document {
+	o.mu.Lock()
+	documents := make([]*Document, 0, len(o.documents))
+	for _, doc := range o.documents {
+		documents = append(documents, doc)
+	}
+	o.mu.Unlock()
+
+	sort.Slice(documents, func(i, j int) bool { return documents[i].URI < documents[j].URI })
+	return documents
+}
+
+// Set opens the document uri, or replaces its current version, with the
+// version and content. The content must not be modified afterwards.
+func (o *Overlay) Set(uri span.URI, version int, content []byte) *Document {
+	doc := newDocument(uri, version, content)
+	o.mu.Lock()
+	old := o.documents[uri]
+	o.documents[uri] = doc
+	o.notify(DocumentChange{URI: uri, Old: old, New: doc})
+	return doc
+}
+
+// Open opens the document uri for a client, with the version and content
+// which replace the current ones if another client already opened it. The
+// document stays open until every client which opened it closes it.
+func (o *Overlay) Open(uri span.URI, version int, content []byte) *Document {
+	doc := newDocument(uri, version, content)
+	o.mu.Lock()
+	old := o.documents[uri]
+	o.documents[uri] = doc
+	o.opens[uri]++
+	o.notify(DocumentChange{URI: uri, Old: old, New: doc})
+	return doc
+}
+
+// Close closes the document uri for a client which opened it, and closes the
+// document once the last of them closed it. It returns whether the document
+// is closed.
+func (o *Overlay) Close(uri span.URI) bool {
+	o.mu.Lock()
+	if o.opens[uri] > 1 {
+		o.opens[uri]--
+		o.mu.Unlock()
+		return false
+	}
+	delete(o.opens, uri)
+	old, ok := o.documents[uri]
+	if !ok {
+		o.mu.Unlock()
+		return true
+	}
+	delete(o.documents, uri)
+	o.notify(DocumentChange{URI: uri, Old: old})
+	return true
+}
+
+// CompareAndSwap replaces the version old of a document with the version and
+// content, unless the document changed since old. A nil old is a document
+// which is not open. It returns the new version, and false if the document
+// changed.
+func (o *Overlay) CompareAndSwap(uri span.URI, old *Document, version int, content []byte) (*Document, bool) {
+	doc := newDocument(uri, version, content)
+	o.mu.Lock()
+	if o.documents[uri] != old {
+		o.mu.Unlock()
+		return nil, false
+	}
+	o.documents[uri] = doc
+	o.notify(DocumentChange{URI: uri, Old: old, New: doc})
+	return doc, true
+}
+
+// Delete closes the document uri, for all the clients.
+func (o *Overlay) Delete(uri span.URI) {
+	o.mu.Lock()
+	delete(o.opens, uri)
+	old, ok := o.documents[uri]
+	if !ok {
+		o.mu.Unlock()
+		return
+	}
+	delete(o.documents, uri)
+	o.notify(DocumentChange{URI: uri, Old: old})
+}
+
+// Subscribe calls notify on every change of the overlay, in order, until the
+// returned function is called. notify must not change the overlay.
+func (o *Overlay) Subscribe(notify func(DocumentChange)) (unsubscribe func()) {
+	s := &subscriber{notify: notify}
+	o.notifyMu.Lock()
+	o.subscribers = append(o.subscribers, s)
+	o.notifyMu.Unlock()
+
+	return func() {
+		o.notifyMu.Lock()
+		defer o.notifyMu.Unlock()
+		for i, other := range o.subscribers {
+			if other == s {
+				o.subscribers = append(o.subscribers[:i:i], o.subscribers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// notify notifies the subscribers of change. It assumes that the caller holds
+// the mutex of the overlay, which it unlocks.
+func (o *Overlay) notify(change DocumentChange) {
+	o.notifyMu.Lock()
+	o.mu.Unlock()
+	defer o.notifyMu.Unlock()
+
+	for _, s := range o.subscribers {
+		s.notify(change)
+	}
+}
+
+func newDocument(uri span.URI, version int, content []byte) *Document {
+	sum := sha256.Sum256(content)
+	return &Document{
+		URI:     uri,
+		Version: version,
+		Content: content,
+		Hash:    hex.EncodeToString(sum[:]),
+	}
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestOverlay(t *testing.T) {
+	v := NewView(&packages.Config{})
+	o := v.Overlay()
+	uri := span.FileURI("/src/a.go")
+
+	var changes []DocumentChange
+	unsubscribe := o.Subscribe(func(change DocumentChange) {
+		changes = append(changes, change)
+	})
+
+	v1 := o.Set(uri, 1, []byte("package a"))
+	if got := o.Get(uri); got != v1 || got.Version != 1 || got.Hash == "" {
+		t.Fatalf("Get after Set: %+v", got)
+	}
+	if _, ok := o.CompareAndSwap(uri, nil, 2, []byte("package b")); ok {
+		t.Error("CompareAndSwap of an open document as a closed one succeeded")
+	}
+	v2, ok := o.CompareAndSwap(uri, v1, 2, []byte("package a\n"))
+	if !ok || o.Get(uri) != v2 {
+		t.Fatal("CompareAndSwap of the current version failed")
+	}
+	if _, ok := o.CompareAndSwap(uri, v1, 3, []byte("package c")); ok {
+		t.Error("CompareAndSwap of a stale version succeeded")
+	}
+	if v1.Hash == v2.Hash || string(v1.Content) != "package a" {
+		t.Error("the versions of a document are not immutable")
+	}
+
+	// The view type-checks the current version.
+	if got := v.getFile(uri).GetContent(context.Background()); string(got) != "package a\n" {
+		t.Errorf("content of the view: %q", got)
+	}
+	if got := v.Config.Overlay["/src/a.go"]; string(got) != "package a\n" {
+		t.Errorf("overlay of the configuration: %q", got)
+	}
+
+	o.Delete(uri)
+	unsubscribe()
+	o.Set(uri, 1, nil)
+	if len(changes) != 3 || changes[0].Old != nil || changes[1].Old != v1 || changes[1].New != v2 || changes[2].New != nil {
+		t.Errorf("changes: %+v", changes)
+	}
+}
+
+func TestOverlayOpenClose(t *testing.T) {
+	o := NewOverlay()
+	uri := span.FileURI("/src/a.go")
+
+	o.Open(uri, 1, []byte("package a"))
+	o.Open(uri, 1, []byte("package a"))
+	if o.Close(uri) || o.Get(uri) == nil {
+		t.Fatal("the document was closed while another client has it open")
+	}
+	if !o.Close(uri) || o.Get(uri) != nil {
+		t.Fatal("the document was not closed by the last client")
+	}
+
+	o.Open(uri, 1, []byte("package a"))
+	o.Open(uri, 1, []byte("package a"))
+	o.Delete(uri)
+	o.Open(uri, 1, []byte("package a"))
+	if !o.Close(uri) || o.Get(uri) != nil {
+		t.Fatal("Delete did not close the document for all the clients")
+	}
+}
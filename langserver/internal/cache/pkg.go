@@ -25,6 +25,10 @@ type Package struct {
 	typesInfo   *types.Info
 	fset        *token.FileSet
 
+	// disk is set if the package was restored from the disk cache, in which
+	// case its syntax and type information are loaded when they are used.
+	disk *diskPackage
+
 	// The analysis cache holds analysis information for all the packages in a view.
 	// Each graph node (action) is one unit of analysis.
 	// Edges express package-to-package (vertical) dependencies,
@@ -110,7 +114,8 @@ func (pkg *Package) GetFilenames() []string {
 }
 
 func (pkg *Package) GetSyntax() []*ast.File {
-	return pkg.syntax
+	syntax, _ := pkg.loaded()
+	return syntax
 }
 
 func (pkg *Package) GetErrors() []packages.Error {
@@ -118,21 +123,39 @@ func (pkg *Package) GetErrors() []packages.Error {
 }
 
 func (pkg *Package) GetTypes() *types.Package {
-	return pkg.types
+	return pkg.loadedTypes()
 }
 
 func (pkg *Package) GetTypesInfo() *types.Info {
-	return pkg.typesInfo
+	_, info := pkg.loaded()
+	return info
 }
 
 func (pkg *Package) GetPkgPath() string {
 	return pkg.pkgPath
 }
 
+// GetID returns the ID of the package, which differs from its path for the
+// test variants.
+func (pkg *Package) GetID() string {
+	return pkg.id
+}
+
 func (pkg *Package) IsIllTyped() bool {
 	return pkg.types == nil && pkg.typesInfo == nil
 }
 
+// hasParseErrors reports whether some file of pkg could not be parsed, in
+// which case its declarations are incomplete.
+func (pkg *Package) hasParseErrors() bool {
+	for _, err := range pkg.errors {
+		if err.Kind == packages.ParseError {
+			return true
+		}
+	}
+	return false
+}
+
 func (pkg *Package) GetImport(pkgPath string) source.Package {
 	if ip, ok := pkg.imports[pkgPath]; ok {
 		return ip
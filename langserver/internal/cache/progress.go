@@ -0,0 +1,299 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/packages"
+)
+
+// ProgressStyle is how the progress of the loading of the packages of the
+// workspace is reported to the client.
+type ProgressStyle string
+
+// The progress styles.
+const (
+	NoProgress       ProgressStyle = "none"
+	WorkDoneProgress ProgressStyle = "progress"
+	MessageProgress  ProgressStyle = "message"
+)
+
+// messageProgressStep is the step, in percents, of the progress reported by
+// window/showMessage notifications.
+const messageProgressStep = 10
+
+// indexing reports the progress of the loading of the packages of a project,
+// counted in files parsed, and cancels it on demand.
+type indexing struct {
+	project *Project
+	style   ProgressStyle
+	token   protocol.ProgressToken
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// notifyMu keeps the notifications in order.
+	notifyMu sync.Mutex
+
+	mu        sync.Mutex
+	files     map[string]string // package of each file left to parse
+	remaining map[string]int    // number of files left to parse of each package
+	total     int               // files
+	parsed    int               // files
+	packages  int
+	loaded    int // packages
+	reported  int // percentage
+}
+
+// SetProgress reports the progress of the loading of the packages of the
+// workspace by Init in style. The work done progress uses token, the work
+// done token of the initialize request, and is not reported without one. It
+// must be called before Init.
+func (p *Project) SetProgress(style ProgressStyle, token protocol.ProgressToken) {
+	p.progressStyle = style
+	p.progressToken = token
+}
+
+// CancelIndexing cancels the loading of the packages of the workspace whose
+// work done progress is token, and reports whether it was in progress.
+func (p *Project) CancelIndexing(token protocol.ProgressToken) bool {
+	p.indexingMu.Lock()
+	ix := p.indexing
+	p.indexingMu.Unlock()
+	if ix == nil || ix.style != WorkDoneProgress || ix.token != token {
+		return false
+	}
+	ix.cancel()
+	return true
+}
+
+// beginIndexing starts reporting the progress of the loading of the packages
+// of the workspace, which can be canceled until endIndexing.
+func (p *Project) beginIndexing(ctx context.Context) {
+	style := p.progressStyle
+	if style == WorkDoneProgress && p.progressToken == nil || style != WorkDoneProgress && style != MessageProgress {
+		return
+	}
+
+	ix := &indexing{
+		project:   p,
+		style:     style,
+		token:     p.progressToken,
+		files:     make(map[string]string),
+		remaining: make(map[string]int),
+	}
+	ix.ctx, ix.cancel = context.WithCancel(ctx)
+	p.indexingMu.Lock()
+	p.indexing = ix
+	p.indexingMu.Unlock()
+
+	ix.notify(&protocol.WorkDoneProgressBegin{
+		Kind:        "begin",
+		Title:       "Loading packages",
+		Cancellable: true,
+		Message:     "listing packages",
+	})
+}
+
+// endIndexing stops reporting the progress of the loading of the packages of
+// the workspace.
+func (p *Project) endIndexing() {
+	p.indexingMu.Lock()
+	ix := p.indexing
+	p.indexing = nil
+	if ix != nil {
+		p.canceled = ix.ctx.Err() != nil
+	}
+	p.indexingMu.Unlock()
+	if ix == nil {
+		return
+	}
+
+	message := fmt.Sprintf("loaded %d packages", ix.loaded)
+	if ix.ctx.Err() != nil {
+		message = fmt.Sprintf("canceled after %d/%d packages", ix.loaded, ix.packages)
+	}
+	ix.cancel()
+	ix.notify(&protocol.WorkDoneProgressEnd{Kind: "end", Message: message})
+}
+
+// IndexingStatus is the state of the loading of the packages of the
+// workspace.
+type IndexingStatus struct {
+	Running bool
+
+	// Percentage is the percentage of the files parsed while it is running.
+	// The files are only counted when the progress is reported, see
+	// SetProgress.
+	Percentage int
+
+	// Canceled reports whether the last loading was canceled, so that some
+	// packages are missing from the cache.
+	Canceled bool
+}
+
+// IndexingStatus returns the state of the loading of the packages of the
+// workspace.
+func (p *Project) IndexingStatus() IndexingStatus {
+	p.indexingMu.Lock()
+	ix := p.indexing
+	status := IndexingStatus{Running: ix != nil, Canceled: p.canceled}
+	p.indexingMu.Unlock()
+	if ix == nil {
+		return status
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.total > 0 {
+		status.Percentage = ix.parsed * 100 / ix.total
+	}
+	return status
+}
+
+// loadAll loads the packages matching patterns with their syntax and type
+// information, reporting the progress of the indexing if there is one.
+func (p *Project) loadAll(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+	p.indexingMu.Lock()
+	ix := p.indexing
+	p.indexingMu.Unlock()
+	if ix == nil {
+		return timedLoad(cfg, patterns...)
+	}
+
+	// The packages are listed first to know how many files are going to be
+	// parsed.
+	listCfg := *cfg
+	listCfg.Context = ix.ctx
+	listCfg.Mode = listMode(cfg.Mode)
+	listed, err := timedLoad(&listCfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	ix.add(listed)
+
+	loadCfg := *cfg
+	loadCfg.Context = ix.ctx
+	parseFile := cfg.ParseFile
+	loadCfg.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		defer ix.fileParsed(filename)
+		if parseFile == nil {
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		}
+		return parseFile(fset, filename, src)
+	}
+	return timedLoad(&loadCfg, patterns...)
+}
+
+// listMode returns the mode of the listing of the packages whose files are
+// parsed when they are loaded with mode: their dependencies are only listed
+// if their syntax is loaded too, e.g. not with the NavigationProfile.
+func listMode(mode packages.LoadMode) packages.LoadMode {
+	if mode >= packages.LoadAllSyntax {
+		return packages.LoadImports
+	}
+	return packages.LoadFiles
+}
+
+// add counts the files of pkgs and of their dependencies.
+func (ix *indexing) add(pkgs []*packages.Package) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if len(pkg.CompiledGoFiles) == 0 {
+			return
+		}
+		ix.packages++
+		ix.remaining[pkg.ID] += len(pkg.CompiledGoFiles)
+		for _, filename := range pkg.CompiledGoFiles {
+			ix.files[filename] = pkg.ID
+			ix.total++
+		}
+	})
+}
+
+// fileParsed counts the file filename as parsed, and reports the progress
+// when it changes enough.
+func (ix *indexing) fileParsed(filename string) {
+	ix.mu.Lock()
+	ix.parsed++
+	if id, ok := ix.files[filename]; ok {
+		delete(ix.files, filename)
+		ix.remaining[id]--
+		if ix.remaining[id] == 0 {
+			ix.loaded++
+		}
+	}
+	percentage := 0
+	if ix.total > 0 {
+		percentage = ix.parsed * 100 / ix.total
+	}
+	step := 1
+	if ix.style == MessageProgress {
+		step = messageProgressStep
+	}
+	if percentage < ix.reported+step {
+		ix.mu.Unlock()
+		return
+	}
+	ix.reported = percentage
+	message := fmt.Sprintf("%d/%d packages", ix.loaded, ix.packages)
+	ix.mu.Unlock()
+
+	ix.notify(&protocol.WorkDoneProgressReport{
+		Kind:        "report",
+		Cancellable: true,
+		Message:     message,
+		Percentage:  percentage,
+	})
+}
+
+// downloaded reports the download of module, the nth of total modules
+// downloaded before the packages are listed. The downloads are reported
+// without percentage, which is the one of the files parsed.
+func (ix *indexing) downloaded(n, total int, module moduleInfo) {
+	if ix.style == MessageProgress && n != total && n*100/total/messageProgressStep == (n-1)*100/total/messageProgressStep {
+		return
+	}
+	ix.notify(&protocol.WorkDoneProgressReport{
+		Kind:        "report",
+		Cancellable: true,
+		Message:     fmt.Sprintf("downloaded %d/%d modules (%s@%s)", n, total, module.Path, module.Version),
+	})
+}
+
+// notify sends the progress to the client: a $/progress notification, or a
+// window/showMessage notification for the other styles.
+func (ix *indexing) notify(value interface{}) {
+	ix.notifyMu.Lock()
+	defer ix.notifyMu.Unlock()
+
+	conn := ix.project.conn
+	if conn == nil {
+		return
+	}
+	if ix.style == WorkDoneProgress {
+		_ = conn.Notify(context.Background(), "$/progress", &protocol.ProgressParams{Token: ix.token, Value: value})
+		return
+	}
+
+	var message string
+	switch v := value.(type) {
+	case *protocol.WorkDoneProgressBegin:
+		message = v.Title + ": " + v.Message
+	case *protocol.WorkDoneProgressReport:
+		message = fmt.Sprintf("Loading packages: %d%% (%s)", v.Percentage, v.Message)
+		if v.Percentage == 0 {
+			message = "Loading packages: " + v.Message
+		}
+	case *protocol.WorkDoneProgressEnd:
+		message = "Loading packages: " + v.Message
+	}
+	_ = conn.Notify(context.Background(), "window/showMessage", &lsp.ShowMessageParams{Type: lsp.Info, Message: message})
+}
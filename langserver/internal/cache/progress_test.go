@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+)
+
+// recorder is a jsonrpc2.JSONRPC2 which records the notifications.
+type recorder struct {
+	mu     sync.Mutex
+	params []interface{}
+}
+
+func (r *recorder) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	return nil
+}
+
+func (r *recorder) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	r.mu.Lock()
+	r.params = append(r.params, params)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *recorder) Close() error {
+	return nil
+}
+
+func TestIndexingMessages(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	conn := &recorder{}
+	p := &Project{conn: conn}
+	p.SetProgress(MessageProgress, nil)
+	p.beginIndexing(context.Background())
+
+	dep := &packages.Package{ID: "dep", CompiledGoFiles: []string{"/dep/a.go"}}
+	p.indexing.add([]*packages.Package{{
+		ID:              "main",
+		CompiledGoFiles: []string{"/main/a.go", "/main/b.go", "/main/c.go", "/main/d.go"},
+		Imports:         map[string]*packages.Package{"dep": dep},
+	}})
+	for _, filename := range []string{"/dep/a.go", "/main/a.go", "/main/b.go", "/main/c.go", "/main/d.go"} {
+		p.indexing.fileParsed(filename)
+	}
+	p.endIndexing()
+	require.Nil(p.indexing)
+
+	var messages []string
+	for _, params := range conn.params {
+		messages = append(messages, params.(*lsp.ShowMessageParams).Message)
+	}
+	require.Equal([]string{
+		"Loading packages: listing packages",
+		"Loading packages: 20% (1/2 packages)",
+		"Loading packages: 40% (1/2 packages)",
+		"Loading packages: 60% (1/2 packages)",
+		"Loading packages: 80% (1/2 packages)",
+		"Loading packages: 100% (2/2 packages)",
+		"Loading packages: loaded 2 packages",
+	}, messages)
+}
+
+func TestCancelIndexing(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	conn := &recorder{}
+	p := &Project{conn: conn}
+	require.False(p.CancelIndexing("init"))
+
+	// The work done progress needs the token of the client.
+	p.SetProgress(WorkDoneProgress, nil)
+	p.beginIndexing(context.Background())
+	require.Nil(p.indexing)
+
+	p.SetProgress(WorkDoneProgress, "init")
+	p.beginIndexing(context.Background())
+	require.False(p.CancelIndexing("other"))
+	require.True(p.CancelIndexing("init"))
+	require.Error(p.indexing.ctx.Err())
+	p.endIndexing()
+
+	require.Len(conn.params, 2)
+	begin := conn.params[0].(*protocol.ProgressParams)
+	require.Equal("init", begin.Token)
+	require.True(begin.Value.(*protocol.WorkDoneProgressBegin).Cancellable)
+	end := conn.params[1].(*protocol.ProgressParams).Value.(*protocol.WorkDoneProgressEnd)
+	require.Equal("canceled after 0/0 packages", end.Message)
+}
+
+func TestListMode(t *testing.T) {
+	// Only the files of the packages are parsed with the navigation profile,
+	// so that their dependencies are not counted.
+	require.Equal(t, packages.LoadFiles, listMode(NavigationProfile.mode()))
+	require.Equal(t, packages.LoadImports, listMode(FullProfile.mode()))
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -12,8 +13,10 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/saibing/bingo/langserver/internal/util"
@@ -65,6 +68,7 @@ type FindPackageFunc func(project *Project, importPath string) (source.Package,
 // Project project struct
 type Project struct {
 	context       context.Context
+	cancel        context.CancelFunc
 	conn          jsonrpc2.JSONRPC2
 	view          *View
 	rootDir       string
@@ -73,12 +77,36 @@ type Project struct {
 	gopath        *gopath
 	cached        bool
 	newCache      *GlobalCache
+	disk          *diskCache
+	restored      map[string]*Package
 	changedCount  int
 	lastBuildTime time.Time
-}
-
-// NewProject new project
-func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, buildFlags []string) *Project {
+	cacheStyle    CacheStyle
+	warmer        *warmer
+	env           []string
+	goEnv         map[string]string
+	memoryLimit   int64
+	loadProfile   LoadProfile
+	progressStyle ProgressStyle
+	progressToken protocol.ProgressToken
+	indexingMu    sync.Mutex
+	indexing      *indexing
+	canceled      bool // the last indexing was canceled
+
+	// missingMu guards missing, the modules which could not be downloaded.
+	missingMu sync.Mutex
+	missing   []string
+}
+
+// NewProject new project. env are the "KEY=VALUE" variables which override
+// the environment of the go command for this project. The background work of
+// the project stops when ctx is done or the project is closed.
+func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, buildFlags []string, env []string) *Project {
+	ctx, cancel := context.WithCancel(ctx)
+	var cmdEnv []string
+	if len(env) > 0 {
+		cmdEnv = append(os.Environ(), env...)
+	}
 	cfg := &packages.Config{
 		Context: ctx,
 		Dir:     rootPath,
@@ -90,13 +118,18 @@ func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, bu
 		},
 		Tests:      true,
 		BuildFlags: buildFlags,
+		Env:        cmdEnv,
 	}
 	view := NewView(cfg)
+	view.scratchDir = filepath.Join(util.LowerDriver(rootPath), scratchDir)
 
 	p := &Project{
+		context: ctx,
+		cancel:  cancel,
 		conn:    conn,
 		view:    view,
 		rootDir: util.LowerDriver(rootPath),
+		env:     env,
 	}
 
 	p.vendorDir = filepath.Join(p.rootDir, vendor)
@@ -111,6 +144,17 @@ func (p *Project) getView() *View {
 	return p.view
 }
 
+// Generation returns the generation of the view of the project, see
+// View.Generation.
+func (p *Project) Generation() uint64 {
+	return p.view.Generation()
+}
+
+// Overlay returns the open documents of the project.
+func (p *Project) Overlay() *Overlay {
+	return p.view.Overlay()
+}
+
 func (p *Project) notify(err error) {
 	if err != nil {
 		p.notifyLog(fmt.Sprintf("notify: %s\n", err))
@@ -119,7 +163,8 @@ func (p *Project) notify(err error) {
 
 // Init init project
 func (p *Project) Init(ctx context.Context, globalCacheStyle CacheStyle) error {
-	p.context = ctx
+	p.cacheStyle = globalCacheStyle
+	p.captureGoEnv(ctx)
 	start := time.Now()
 	defer func() {
 		elapsedTime := time.Since(start) / time.Second
@@ -131,25 +176,69 @@ func (p *Project) Init(ctx context.Context, globalCacheStyle CacheStyle) error {
 		return nil
 	}
 
-	p.newCache = NewCache()
+	p.newCache = p.newGlobalCache()
 	p.getView().gcache = p.newCache
+	if globalCacheStyle == Always {
+		p.restoreCache()
+	}
 	err := p.createBuiltin()
 	if err != nil {
 		p.notify(err)
 	}
 
 	if globalCacheStyle != Always {
+		p.warmer = newWarmer(p)
 		return nil
 	}
 
+	p.beginIndexing(ctx)
 	err = p.createProject()
+	p.endIndexing()
 	p.notify(err)
 	p.lastBuildTime = time.Now()
+	go p.saveCache(start)
 
 	p.fsnotify()
 	return nil
 }
 
+// captureGoEnv records the go env of the project, which includes the
+// overrides of its environment.
+func (p *Project) captureGoEnv(ctx context.Context) {
+	buf, err := invokeGo(ctx, p.rootDir, p.view.Config.Env, "env", "-json")
+	if err != nil {
+		p.notify(err)
+		return
+	}
+
+	goEnv := map[string]string{}
+	if err := json.Unmarshal(buf.Bytes(), &goEnv); err != nil {
+		p.notify(fmt.Errorf("go env: %s", err))
+		return
+	}
+	p.goEnv = goEnv
+}
+
+// GoEnv returns the go env captured when the project was initialized.
+func (p *Project) GoEnv() map[string]string {
+	goEnv := make(map[string]string, len(p.goEnv))
+	for k, v := range p.goEnv {
+		goEnv[k] = v
+	}
+	return goEnv
+}
+
+// Env returns the overrides of the environment of the go command.
+func (p *Project) Env() []string {
+	return p.env
+}
+
+// Close stops watching the files of the project and cancels its loads in the
+// background. The project must not be used afterwards.
+func (p *Project) Close() {
+	p.cancel()
+}
+
 func (p *Project) fsnotify() {
 	if !p.cached {
 		return
@@ -159,9 +248,34 @@ func (p *Project) fsnotify() {
 	go subject.notify()
 }
 
+// Contain reports whether the document fileURI is in the workspace: in the
+// root directory of the project, or in the directory of a module replaced by
+// a local directory in one of its go.mod files.
 func (p *Project) Contain(fileURI lsp.DocumentURI) bool {
 	filePath, _ := source.FromDocumentURI(fileURI).Filename()
-	return strings.HasPrefix(filePath, p.rootDir)
+	return p.inWorkspace(filePath)
+}
+
+// inWorkspace reports whether the file filename is in the workspace, see
+// Contain.
+func (p *Project) inWorkspace(filename string) bool {
+	if strings.HasPrefix(filename, p.rootDir) {
+		return true
+	}
+	for _, m := range p.modules {
+		for _, module := range m.localReplaces() {
+			if inDir(filename, util.LowerDriver(module.Dir)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inDir reports whether filename is in dir or in one of its subdirectories.
+func inDir(filename, dir string) bool {
+	rel, err := filepath.Rel(dir, filename)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
 func (p *Project) getImportPath() string {
@@ -330,10 +444,11 @@ func (p *Project) walkDir(rootDir string, level int, walkFunc func(string, strin
 	return nil
 }
 
-// GetFromURI get package from document uri.
+// GetFromURI get package from document uri. Among the variants of the
+// package, e.g. with its tests, the one with the most open documents wins.
 func (p *Project) GetFromURI(uri lsp.DocumentURI) source.Package {
 	filename, _ := source.FromDocumentURI(uri).Filename()
-	pkg := p.getCache().GetByURI(filename)
+	pkg := p.getView().cachedPackage(filename)
 	if pkg == nil {
 		return nil
 	}
@@ -348,6 +463,34 @@ func (p *Project) getCache() *GlobalCache {
 	return cache
 }
 
+// SetMemoryLimit bounds the estimated memory of the packages of the global
+// cache which are not in the workspace to limit bytes, unless limit is 0. It
+// must be called before Init.
+func (p *Project) SetMemoryLimit(limit int64) {
+	p.memoryLimit = limit
+}
+
+// SetLoadProfile changes the information of the packages loaded into the
+// global cache, which is FullProfile by default. It must be called before
+// Init.
+func (p *Project) SetLoadProfile(profile LoadProfile) {
+	p.loadProfile = profile
+}
+
+// MemoryUsage returns the estimated memory of the packages of the global
+// cache.
+func (p *Project) MemoryUsage() MemoryUsage {
+	return p.getCache().MemoryUsage()
+}
+
+// newGlobalCache returns an empty global cache with the memory limit of the
+// project.
+func (p *Project) newGlobalCache() *GlobalCache {
+	c := NewCache()
+	c.SetMemoryLimit(p.memoryLimit, workspacePinner(p.inWorkspace))
+	return c
+}
+
 // GetFromPkgPath get package from package import path.
 func (p *Project) GetFromPkgPath(pkgPath string) source.Package {
 	pkg := p.getCache().Get(pkgPath)
@@ -360,15 +503,57 @@ func (p *Project) GetFromPkgPath(pkgPath string) source.Package {
 func (p *Project) update(eventName string) {
 	if p.needRebuild(eventName) {
 		p.notifyLog("fsnotify " + eventName)
-		p.newCache = NewCache()
+		start := time.Now()
+		p.newCache = p.newGlobalCache()
 		p.newCache.Put(p.GetBuiltinPackage().(*Package))
 		p.rebuildGopapthCache(eventName)
 		p.rebuildModuleCache(eventName)
 		p.lastBuildTime = time.Now()
 
-		p.view.mu.Lock()
-		p.view.gcache = p.newCache
-		p.view.mu.Unlock()
+		p.swapCache(p.newCache)
+		p.saveCache(start)
+	}
+}
+
+// SetBuildFlags changes the build flags of the go command of the project.
+// The packages of the view are type-checked again, and the global cache is
+// loaded again in the background.
+func (p *Project) SetBuildFlags(buildFlags []string) {
+	p.view.SetBuildFlags(buildFlags)
+
+	switch p.cacheStyle {
+	case Ondemand:
+		p.warmer = newWarmer(p)
+	case Always:
+		go p.rebuild()
+	}
+}
+
+// rebuild loads the packages of the project into a new global cache.
+func (p *Project) rebuild() {
+	start := time.Now()
+	p.newCache = p.newGlobalCache()
+	if builtin, ok := p.GetBuiltinPackage().(*Package); ok && builtin != nil {
+		p.newCache.Put(builtin)
+	}
+	p.modules = nil
+	p.notify(p.createProject())
+	p.lastBuildTime = time.Now()
+
+	p.swapCache(p.newCache)
+	p.saveCache(start)
+}
+
+// swapCache makes c the global cache of the view, and releases the memory of
+// the packages of the previous one.
+func (p *Project) swapCache(c *GlobalCache) {
+	p.view.mu.Lock()
+	old := p.view.gcache
+	p.view.gcache = c
+	p.view.mu.Unlock()
+
+	if old != nil && old != c {
+		releaseMemory(old.residentMemory())
 	}
 }
 
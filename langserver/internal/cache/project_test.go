@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProjectClose(t *testing.T) {
+	p := NewProject(context.Background(), nil, "/work", nil, nil)
+	if err := p.getContext().Err(); err != nil {
+		t.Fatalf("the context of a new project is done: %s", err)
+	}
+
+	p.Close()
+	select {
+	case <-p.getContext().Done():
+	default:
+		t.Fatal("the watchers of a closed project are not stopped")
+	}
+	if p.view.Config.Context.Err() == nil {
+		t.Fatal("the loads of a closed project are not canceled")
+	}
+}
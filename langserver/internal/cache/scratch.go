@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// scratchDir is the directory of the workspace, which does not exist on
+// disk, of the scratch files: the untitled documents of the client.
+const scratchDir = ".bingo-scratch"
+
+// ScratchFilename returns the name of the scratch file of the untitled
+// document name. A scratch file is type-checked as a main package of its
+// own, in the context of the module of the workspace.
+func (p *Project) ScratchFilename(name string) string {
+	return filepath.Join(p.rootDir, scratchDir, name)
+}
+
+// isScratch reports whether filename is a scratch file of the view.
+func (v *View) isScratch(filename string) bool {
+	return v.scratchDir != "" && strings.HasPrefix(filename, v.scratchDir+string(filepath.Separator))
+}
+
+// isScratchPackage reports whether m is the synthetic package of a scratch
+// file, which is not cached in the global cache.
+func (v *View) isScratchPackage(m *metadata) bool {
+	return len(m.files) == 1 && v.isScratch(m.files[0])
+}
+
+// linkScratch links the metadata of the synthetic main package of the
+// scratch file f, whose imports are loaded from the workspace. It is assumed
+// that the caller holds the mutexes of the view and of the mcache.
+func (v *View) linkScratch(ctx context.Context, f *File, filename string) ([]packages.Error, error) {
+	f.read(ctx)
+	pkg := &packages.Package{
+		ID:              "untitled:" + filepath.Base(filename),
+		PkgPath:         "untitled:" + filepath.Base(filename),
+		Name:            "main",
+		CompiledGoFiles: []string{filename},
+		Imports:         make(map[string]*packages.Package),
+	}
+
+	var importPaths []string
+	if parsed, _ := parser.ParseFile(token.NewFileSet(), filename, f.content, parser.ImportsOnly); parsed != nil {
+		for _, spec := range parsed.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				importPaths = append(importPaths, path)
+			}
+		}
+	}
+	if len(importPaths) > 0 {
+		cfg := v.Config
+		cfg.Context = ctx
+		cfg.Mode = packages.LoadImports
+		cfg.Tests = false
+		imported, err := timedLoad(&cfg, importPaths...)
+		if err != nil {
+			return nil, err
+		}
+		// The imports which can not be found are reported by the type
+		// checker.
+		for _, ip := range imported {
+			pkg.Imports[ip.PkgPath] = ip
+		}
+	}
+	v.link(pkg.PkgPath, pkg, nil)
+	return nil, nil
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// snapshotPrefix is the prefix of the files of the snapshots of the listings
+// of the packages in a disk cache.
+const snapshotPrefix = "list-"
+
+// listSnapshot is the listing of the packages matching the patterns of a
+// load, which is reused on the next startup instead of running go list, and
+// resolving the modules, again. Key identifies what the listing depends on,
+// and Dirs are the directories whose files are listed: their modification
+// times change when files are created or deleted.
+type listSnapshot struct {
+	Key      string
+	Dirs     []fileStamp
+	Packages []listedPackage
+}
+
+// listedPackage is a package of a listSnapshot.
+type listedPackage struct {
+	ID              string
+	PkgPath         string
+	CompiledGoFiles []string
+}
+
+// listPackages lists the packages matching the patterns with their files.
+// The listing is read from the snapshot of the disk cache if nothing it
+// depends on has changed since, and written to it otherwise.
+func (p *Project) listPackages(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+	key := snapshotKey(cfg, patterns)
+	if p.disk != nil {
+		if listed := p.disk.readSnapshot(key, cfg.Dir, patterns); listed != nil {
+			p.notifyLog(fmt.Sprintf("list %s from the snapshot of %s", strings.Join(patterns, " "), p.disk.dir))
+			return listed, nil
+		}
+	}
+
+	listCfg := *cfg
+	listCfg.Mode = packages.LoadFiles
+	listed, err := timedLoad(&listCfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if p.disk != nil {
+		if err := p.disk.writeSnapshot(key, cfg.Dir, patterns, listed); err != nil {
+			p.notify(fmt.Errorf("write the snapshot of %s: %s", strings.Join(patterns, " "), err))
+		}
+	}
+	return listed, nil
+}
+
+// snapshotKey returns the hash of what the listing of the packages matching
+// the patterns with cfg depends on, but the files of the packages: the go.mod
+// and go.sum files of cfg.Dir, the version of Go, the build flags and the
+// environment overrides.
+func snapshotKey(cfg *packages.Config, patterns []string) string {
+	h := sha256.New()
+	fmt.Fprintln(h, runtime.Version(), cfg.Dir, cfg.Tests)
+	fmt.Fprintln(h, patterns, cfg.BuildFlags)
+	for _, kv := range cfg.Env {
+		// The overrides are appended to the environment of bingo.
+		fmt.Fprintln(h, kv)
+	}
+	for _, name := range []string{gomod, gosum} {
+		data, _ := ioutil.ReadFile(filepath.Join(cfg.Dir, name))
+		fmt.Fprintf(h, "%s %d\n", name, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// snapshotFile returns the name of the file of the snapshot of the listing of
+// the packages matching the patterns in dir.
+func (d *diskCache) snapshotFile(dir string, patterns []string) string {
+	sum := sha256.Sum256([]byte(dir + "\n" + strings.Join(patterns, "\n")))
+	return filepath.Join(d.dir, snapshotPrefix+hex.EncodeToString(sum[:8])+".json")
+}
+
+// readSnapshot returns the listing of the packages matching the patterns in
+// dir saved by writeSnapshot, or nil if it is missing or out of date.
+func (d *diskCache) readSnapshot(key, dir string, patterns []string) []*packages.Package {
+	data, err := ioutil.ReadFile(d.snapshotFile(dir, patterns))
+	if err != nil {
+		return nil
+	}
+	var snapshot listSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil || snapshot.Key != key {
+		return nil
+	}
+	for _, stamp := range snapshot.Dirs {
+		fi, err := os.Stat(stamp.Name)
+		if err != nil || !fi.ModTime().Equal(stamp.ModTime) {
+			return nil
+		}
+	}
+
+	listed := make([]*packages.Package, 0, len(snapshot.Packages))
+	for _, l := range snapshot.Packages {
+		listed = append(listed, &packages.Package{ID: l.ID, PkgPath: l.PkgPath, CompiledGoFiles: l.CompiledGoFiles})
+	}
+	return listed
+}
+
+// writeSnapshot saves the listing of the packages matching the patterns in
+// dir, unless go list reported errors, which the next listing may not have.
+func (d *diskCache) writeSnapshot(key, dir string, patterns []string, listed []*packages.Package) error {
+	snapshot := listSnapshot{Key: key}
+	dirs := map[string]bool{}
+	for _, pkg := range listed {
+		if len(pkg.Errors) > 0 {
+			return nil
+		}
+		snapshot.Packages = append(snapshot.Packages, listedPackage{ID: pkg.ID, PkgPath: pkg.PkgPath, CompiledGoFiles: pkg.CompiledGoFiles})
+		for _, filename := range pkg.CompiledGoFiles {
+			dirs[filepath.Dir(filename)] = true
+		}
+	}
+	// The new packages of the workspace are in new directories, which
+	// change the modification time of their parent.
+	_ = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return nil
+		}
+		if path != dir && (isExclude(fi.Name()) || strings.HasPrefix(fi.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		dirs[path] = true
+		return nil
+	})
+	for path := range dirs {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		snapshot.Dirs = append(snapshot.Dirs, fileStamp{Name: path, ModTime: fi.ModTime()})
+	}
+
+	data, err := json.Marshal(&snapshot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+	filename := d.snapshotFile(dir, patterns)
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestListSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	pdir := filepath.Join(root, "p")
+	if err := os.MkdirAll(pdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(filename, content string) {
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(root, gomod), "module example.com\n")
+	write(filepath.Join(pdir, "p.go"), "package p\n")
+
+	d := &diskCache{dir: filepath.Join(dir, "cache")}
+	cfg := &packages.Config{Dir: root}
+	patterns := []string{"example.com/..."}
+	listed := []*packages.Package{{ID: "example.com/p", PkgPath: "example.com/p", CompiledGoFiles: []string{filepath.Join(pdir, "p.go")}}}
+	if err := d.writeSnapshot(snapshotKey(cfg, patterns), root, patterns, listed); err != nil {
+		t.Fatal(err)
+	}
+
+	got := d.readSnapshot(snapshotKey(cfg, patterns), root, patterns)
+	if len(got) != 1 || got[0].ID != "example.com/p" || !sameStrings(got[0].CompiledGoFiles, listed[0].CompiledGoFiles) {
+		t.Fatalf("got the listing %v from the snapshot", got)
+	}
+	if d.readSnapshot(snapshotKey(cfg, patterns), root, []string{"./..."}) != nil {
+		t.Error("the snapshot of other patterns is read")
+	}
+
+	// A new file changes the modification time of its directory.
+	write(filepath.Join(pdir, "q.go"), "package p\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(pdir, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if d.readSnapshot(snapshotKey(cfg, patterns), root, patterns) != nil {
+		t.Error("the snapshot is read after a file is created")
+	}
+
+	if err := d.writeSnapshot(snapshotKey(cfg, patterns), root, patterns, listed); err != nil {
+		t.Fatal(err)
+	}
+	key := snapshotKey(cfg, patterns)
+	write(filepath.Join(root, gomod), "module example.com\n\nrequire example.org/m v1.0.0\n")
+	if newKey := snapshotKey(cfg, patterns); newKey == key {
+		t.Fatal("the key does not depend on go.mod")
+	} else if d.readSnapshot(newKey, root, patterns) != nil {
+		t.Error("the snapshot is read after go.mod changed")
+	}
+
+	// A listing with errors is not saved.
+	listed[0].Errors = []packages.Error{{Msg: "cannot find module"}}
+	if err := d.writeSnapshot(key, root, []string{"./p"}, listed); err != nil {
+		t.Fatal(err)
+	}
+	if d.readSnapshot(key, root, []string{"./p"}) != nil {
+		t.Error("a listing with errors is saved")
+	}
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// recentLoads is the number of the last loads kept by LoadStats.
+const recentLoads = 20
+
+// CacheStats are the counts of the lookups of the packages of a global cache.
+type CacheStats struct {
+	// Packages is the number of the packages of the cache, including the
+	// test variants.
+	Packages int `json:"packages"`
+
+	// Hits and Misses are the lookups by ID, import path or file which found
+	// a package and the ones which did not.
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// LoadStats are the timings of the loads of packages of the process with
+// go/packages.
+type LoadStats struct {
+	Loads    int64 `json:"loads"`
+	Failures int64 `json:"failures"`
+
+	// Millis is the time spent loading packages, in milliseconds.
+	Millis int64 `json:"millis"`
+
+	// Recent are the last loads, the latest first.
+	Recent []PackageLoad `json:"recent"`
+}
+
+// PackageLoad is a load of packages with go/packages.
+type PackageLoad struct {
+	Start    time.Time `json:"start"`
+	Millis   int64     `json:"millis"`
+	Mode     string    `json:"mode"`
+	Patterns []string  `json:"patterns"`
+
+	// Packages is the number of the packages matching the patterns.
+	Packages int    `json:"packages"`
+	Error    string `json:"error,omitempty"`
+}
+
+// loads records the loads of packages of the process.
+var loads struct {
+	mu    sync.Mutex
+	stats LoadStats
+}
+
+// Stats returns the counts of the lookups of the packages of c.
+func (c *GlobalCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	return CacheStats{Packages: len(c.idMap), Hits: atomic.LoadInt64(&c.hits), Misses: atomic.LoadInt64(&c.misses)}
+}
+
+// count counts a lookup of a package of c, found if p is not nil. The
+// lookups are made under the read lock, so the counts are atomic.
+func (c *GlobalCache) count(p *GlobalPackage) {
+	if p != nil {
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		atomic.AddInt64(&c.misses, 1)
+	}
+}
+
+// CacheStats returns the counts of the lookups of the packages of the global
+// cache of the project.
+func (p *Project) CacheStats() CacheStats {
+	return p.getCache().Stats()
+}
+
+// GetLoadStats returns the timings of the loads of packages of the process.
+func GetLoadStats() LoadStats {
+	loads.mu.Lock()
+	defer loads.mu.Unlock()
+
+	stats := loads.stats
+	stats.Recent = make([]PackageLoad, len(loads.stats.Recent))
+	for i, load := range loads.stats.Recent {
+		stats.Recent[len(stats.Recent)-1-i] = load
+	}
+	return stats
+}
+
+// timedLoad loads the packages matching the patterns with packages.Load,
+// and records the timing of the load.
+func timedLoad(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+	start := time.Now()
+	pkgs, err := packages.Load(cfg, patterns...)
+	load := PackageLoad{
+		Start:    start,
+		Millis:   int64(time.Since(start) / time.Millisecond),
+		Mode:     modeString(cfg.Mode),
+		Patterns: patterns,
+		Packages: len(pkgs),
+	}
+	if err != nil {
+		load.Error = err.Error()
+	}
+
+	loads.mu.Lock()
+	defer loads.mu.Unlock()
+	loads.stats.Loads++
+	if err != nil {
+		loads.stats.Failures++
+	}
+	loads.stats.Millis += load.Millis
+	loads.stats.Recent = append(loads.stats.Recent, load)
+	if len(loads.stats.Recent) > recentLoads {
+		loads.stats.Recent = loads.stats.Recent[1:]
+	}
+	return pkgs, err
+}
+
+// modeString returns the name of the load mode.
+func modeString(mode packages.LoadMode) string {
+	switch mode {
+	case packages.LoadFiles:
+		return "files"
+	case packages.LoadImports:
+		return "imports"
+	case packages.LoadTypes:
+		return "types"
+	case packages.LoadSyntax:
+		return "syntax"
+	case packages.LoadAllSyntax:
+		return "all-syntax"
+	}
+	return "unknown"
+}
+
+// Phase is a phase of the handling of a request whose time is recorded by
+// the Phases of its context.
+type Phase string
+
+const (
+	// LoadPhase is the loading of the metadata of the packages.
+	LoadPhase Phase = "load"
+
+	// TypeCheckPhase is the parsing and the type-checking of the packages.
+	TypeCheckPhase Phase = "typecheck"
+)
+
+type phasesKey struct{}
+
+// Phases records the time spent in the phases of a request.
+type Phases struct {
+	mu        sync.Mutex
+	durations map[Phase]time.Duration
+}
+
+// WithPhases returns a context whose time spent in the phases of the view
+// is recorded by the returned Phases.
+func WithPhases(ctx context.Context) (context.Context, *Phases) {
+	phases := &Phases{durations: map[Phase]time.Duration{}}
+	return context.WithValue(ctx, phasesKey{}, phases), phases
+}
+
+// Add records the duration d of the phase.
+func (p *Phases) Add(phase Phase, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.durations[phase] += d
+}
+
+// Durations returns the time spent in every phase.
+func (p *Phases) Durations() map[Phase]time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	durations := make(map[Phase]time.Duration, len(p.durations))
+	for phase, d := range p.durations {
+		durations[phase] = d
+	}
+	return durations
+}
+
+// addPhase records the time since start spent in the phase by the Phases of
+// ctx, if any.
+func addPhase(ctx context.Context, phase Phase, start time.Time) {
+	if phases, ok := ctx.Value(phasesKey{}).(*Phases); ok {
+		phases.Add(phase, time.Since(start))
+	}
+}
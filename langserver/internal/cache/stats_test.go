@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestCacheStats(t *testing.T) {
+	c := NewCache()
+	c.Put(&Package{id: "p", pkgPath: "p", files: []string{"/p/p.go"}})
+	c.Get("p")
+	c.GetByID("p")
+	c.GetByURI("/p/p.go")
+	c.Get("q")
+	c.GetByURI("/q/q.go")
+
+	if got := c.Stats(); got != (CacheStats{Packages: 1, Hits: 3, Misses: 2}) {
+		t.Errorf("got the stats %+v", got)
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	// The loads fail fast outside of any directory.
+	cfg := &packages.Config{Mode: packages.LoadFiles, Dir: "/nonexistent"}
+	for i := 0; i < recentLoads+1; i++ {
+		timedLoad(cfg, "./nonexistent")
+	}
+	stats := GetLoadStats()
+	if stats.Loads < recentLoads+1 || stats.Failures == 0 || len(stats.Recent) != recentLoads {
+		t.Fatalf("got %d loads, %d recent ones", stats.Loads, len(stats.Recent))
+	}
+	if latest := stats.Recent[0]; latest.Patterns[0] != "./nonexistent" || latest.Mode != "files" {
+		t.Errorf("got the latest load %+v", latest)
+	}
+}
+
+func TestPhases(t *testing.T) {
+	// Without Phases, nothing is recorded.
+	addPhase(context.Background(), LoadPhase, time.Now())
+
+	ctx, phases := WithPhases(context.Background())
+	addPhase(ctx, LoadPhase, time.Now().Add(-2*time.Millisecond))
+	addPhase(ctx, LoadPhase, time.Now().Add(-3*time.Millisecond))
+	phases.Add(TypeCheckPhase, time.Millisecond)
+
+	durations := phases.Durations()
+	if durations[LoadPhase] < 5*time.Millisecond || durations[TypeCheckPhase] != time.Millisecond {
+		t.Errorf("got the durations %v", durations)
+	}
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// LoadTests loads the test variants of the package of the test file filename
+// into the global cache in the background, unless they are already there, so
+// that the navigation in the file and the references to the package include
+// the uses in its tests.
+func (p *Project) LoadTests(filename string) {
+	if p.cacheStyle == None || !strings.HasSuffix(filename, "_test.go") {
+		return
+	}
+
+	go func() {
+		if err := p.loadTests(filename); err != nil {
+			p.notifyLog(fmt.Sprintf("load tests of %s: %s", filename, err))
+		}
+	}()
+}
+
+func (p *Project) loadTests(filename string) error {
+	if p.getCache().GetByURI(filename) != nil {
+		return nil
+	}
+
+	v := p.getView()
+	v.mu.Lock()
+	cfg := v.Config
+	overlay := make(map[string][]byte, len(v.Config.Overlay))
+	for filename, content := range v.Config.Overlay {
+		overlay[filename] = content
+	}
+	v.mu.Unlock()
+
+	cfg.Context = context.Background()
+	cfg.Dir = filepath.Dir(filename)
+	cfg.Mode = p.loadProfile.mode()
+	cfg.Tests = true
+	cfg.Overlay = overlay
+	pkgs, err := timedLoad(&cfg, ".")
+	if err != nil {
+		return err
+	}
+
+	// The packages which are already in the cache are kept.
+	cache := p.getCache()
+	for _, pkg := range pkgs {
+		cache.Add(pkg)
+	}
+	p.notifyLog(fmt.Sprintf("load the tests of %s", filepath.Dir(filename)))
+	return nil
+}
@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/build"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/packages"
+)
+
+// BuildVariant is a build configuration, besides the one of the view, the
+// files may be type-checked in, eg. the darwin variant of a portable
+// package. The empty fields are those of the view.
+type BuildVariant struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+// ParseBuildVariant parses a build variant of the form
+// "GOOS=darwin GOARCH=arm64 tags=integration,cgo".
+func ParseBuildVariant(s string) (BuildVariant, error) {
+	var b BuildVariant
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return b, fmt.Errorf("empty build variant")
+	}
+	for _, field := range fields {
+		i := strings.Index(field, "=")
+		if i <= 0 || i == len(field)-1 {
+			return b, fmt.Errorf("invalid build variant %q: %q is not KEY=VALUE", s, field)
+		}
+		switch key, value := field[:i], field[i+1:]; key {
+		case "GOOS":
+			b.GOOS = value
+		case "GOARCH":
+			b.GOARCH = value
+		case "tags":
+			b.Tags = strings.Split(value, ",")
+		default:
+			return b, fmt.Errorf("invalid build variant %q: unknown key %q", s, key)
+		}
+	}
+	return b, nil
+}
+
+// String returns the build variant in the form parsed by ParseBuildVariant.
+func (b BuildVariant) String() string {
+	var fields []string
+	if b.GOOS != "" {
+		fields = append(fields, "GOOS="+b.GOOS)
+	}
+	if b.GOARCH != "" {
+		fields = append(fields, "GOARCH="+b.GOARCH)
+	}
+	if len(b.Tags) > 0 {
+		fields = append(fields, "tags="+strings.Join(b.Tags, ","))
+	}
+	return strings.Join(fields, " ")
+}
+
+// env returns env, or the environment of the process if it is nil, with the
+// GOOS and the GOARCH of the variant.
+func (b BuildVariant) env(env []string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append([]string(nil), env...)
+	if b.GOOS != "" {
+		env = append(env, "GOOS="+b.GOOS)
+	}
+	if b.GOARCH != "" {
+		env = append(env, "GOARCH="+b.GOARCH)
+	}
+	return env
+}
+
+// buildFlags returns the build flags of the go command with the tags of the
+// variant added to their -tags flag.
+func (b BuildVariant) buildFlags(flags []string) []string {
+	if len(b.Tags) == 0 {
+		return flags
+	}
+	tags := append(flagTags(flags), b.Tags...)
+	var result []string
+	for i := 0; i < len(flags); i++ {
+		switch {
+		case flags[i] == "-tags" || flags[i] == "--tags":
+			i++
+		case strings.HasPrefix(flags[i], "-tags=") || strings.HasPrefix(flags[i], "--tags="):
+		default:
+			result = append(result, flags[i])
+		}
+	}
+	return append(result, "-tags", strings.Join(tags, ","))
+}
+
+// flagTags returns the tags of the -tags flag of the build flags, which are
+// separated by commas or, in older releases of the go command, by spaces.
+func flagTags(flags []string) []string {
+	var value string
+	for i, flag := range flags {
+		switch {
+		case (flag == "-tags" || flag == "--tags") && i+1 < len(flags):
+			value = flags[i+1]
+		case strings.HasPrefix(flag, "-tags="), strings.HasPrefix(flag, "--tags="):
+			value = flag[strings.Index(flag, "=")+1:]
+		}
+	}
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// matches reports whether the file filename, of the given content, is built
+// in the variant of the configuration goos, goarch and tags.
+func (b BuildVariant) matches(filename string, content []byte, goos, goarch string, tags []string) bool {
+	ctxt := build.Default
+	ctxt.GOOS, ctxt.GOARCH = goos, goarch
+	if b.GOOS != "" {
+		ctxt.GOOS = b.GOOS
+	}
+	if b.GOARCH != "" {
+		ctxt.GOARCH = b.GOARCH
+	}
+	if ctxt.GOOS != build.Default.GOOS || ctxt.GOARCH != build.Default.GOARCH {
+		// The go command disables cgo when cross-compiling.
+		ctxt.CgoEnabled = false
+	}
+	ctxt.BuildTags = append(append([]string(nil), tags...), b.Tags...)
+	ctxt.OpenFile = func(path string) (io.ReadCloser, error) {
+		if path == filename {
+			return ioutil.NopCloser(bytes.NewReader(content)), nil
+		}
+		return os.Open(path)
+	}
+	match, err := ctxt.MatchFile(filepath.Dir(filename), filepath.Base(filename))
+	return err == nil && match
+}
+
+// CheckVariant type-checks the package of the file filename, with the
+// content of the open documents, in the build variant, and returns its
+// errors. ok is false if the file is not built in the variant.
+func (p *Project) CheckVariant(ctx context.Context, filename string, variant BuildVariant) (errs []packages.Error, ok bool, err error) {
+	v := p.getView()
+	v.mu.Lock()
+	cfg := v.Config
+	f := v.getFile(span.FileURI(filename))
+	f.read(ctx)
+	content := f.content
+	v.mu.Unlock()
+
+	goos, goarch := p.goEnv["GOOS"], p.goEnv["GOARCH"]
+	if goos == "" {
+		goos = build.Default.GOOS
+	}
+	if goarch == "" {
+		goarch = build.Default.GOARCH
+	}
+	if content == nil || !variant.matches(filename, content, goos, goarch, flagTags(cfg.BuildFlags)) {
+		return nil, false, nil
+	}
+
+	cfg.Context = ctx
+	cfg.Mode = packages.LoadSyntax
+	cfg.Tests = strings.HasSuffix(filename, "_test.go")
+	cfg.Env = variant.env(cfg.Env)
+	cfg.BuildFlags = variant.buildFlags(cfg.BuildFlags)
+	// The syntax of the variant is not shared with the view.
+	cfg.Fset = token.NewFileSet()
+	pkgs, err := timedLoad(&cfg, "file="+filename)
+	if err != nil {
+		return nil, false, err
+	}
+	if pkg := packageOf(pkgs, filename); pkg != nil {
+		return pkg.Errors, true, nil
+	}
+	return nil, false, nil
+}
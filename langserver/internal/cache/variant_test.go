@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBuildVariant(t *testing.T) {
+	tests := []struct {
+		s       string
+		variant BuildVariant
+		err     bool
+	}{
+		{"GOOS=darwin", BuildVariant{GOOS: "darwin"}, false},
+		{"GOOS=windows GOARCH=386 tags=integration,cgo", BuildVariant{GOOS: "windows", GOARCH: "386", Tags: []string{"integration", "cgo"}}, false},
+		{"", BuildVariant{}, true},
+		{"GOOS", BuildVariant{}, true},
+		{"GOARM=7", BuildVariant{}, true},
+	}
+	for _, test := range tests {
+		variant, err := ParseBuildVariant(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("ParseBuildVariant(%q) error = %v, want error %v", test.s, err, test.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(variant, test.variant) {
+			t.Errorf("ParseBuildVariant(%q) = %+v, want %+v", test.s, variant, test.variant)
+		}
+		if err == nil && variant.String() != test.s {
+			t.Errorf("ParseBuildVariant(%q).String() = %q", test.s, variant.String())
+		}
+	}
+}
+
+func TestBuildVariantBuildFlags(t *testing.T) {
+	tests := []struct {
+		flags []string
+		tags  []string
+		want  []string
+	}{
+		{[]string{"-mod=mod"}, nil, []string{"-mod=mod"}},
+		{nil, []string{"integration"}, []string{"-tags", "integration"}},
+		{[]string{"-tags", "a b", "-mod=mod"}, []string{"c"}, []string{"-mod=mod", "-tags", "a,b,c"}},
+		{[]string{"-tags=a,b"}, []string{"c"}, []string{"-tags", "a,b,c"}},
+	}
+	for _, test := range tests {
+		got := BuildVariant{Tags: test.tags}.buildFlags(test.flags)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("buildFlags(%q) with tags %q = %q, want %q", test.flags, test.tags, got, test.want)
+		}
+	}
+}
+
+func TestBuildVariantMatches(t *testing.T) {
+	tagged := []byte("//go:build integration\n\npackage p\n")
+	portable := []byte("package p\n")
+	tests := []struct {
+		filename string
+		content  []byte
+		variant  BuildVariant
+		want     bool
+	}{
+		{"/src/p/p.go", portable, BuildVariant{GOOS: "darwin"}, true},
+		{"/src/p/p_darwin.go", portable, BuildVariant{GOOS: "darwin"}, true},
+		{"/src/p/p_darwin.go", portable, BuildVariant{GOOS: "windows"}, false},
+		{"/src/p/p_linux.go", portable, BuildVariant{GOARCH: "arm64"}, true},
+		{"/src/p/it.go", tagged, BuildVariant{GOOS: "darwin"}, false},
+		{"/src/p/it.go", tagged, BuildVariant{Tags: []string{"integration"}}, true},
+	}
+	for _, test := range tests {
+		if got := test.variant.matches(test.filename, test.content, "linux", "amd64", nil); got != test.want {
+			t.Errorf("%s matches %s = %v, want %v", test.variant, test.filename, got, test.want)
+		}
+	}
+}
@@ -7,10 +7,12 @@ package cache
 import (
 	"context"
 	"go/token"
+	"path/filepath"
 	"sync"
 
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
 	"golang.org/x/tools/go/packages"
 )
 
@@ -33,6 +35,10 @@ type View struct {
 	// files caches information for opened files in a view.
 	files map[span.URI]*File
 
+	// overlay holds the open documents, whose changes are queued in
+	// contentChanges.
+	overlay *Overlay
+
 	// contentChanges saves the content changes for a given state of the view.
 	// When type information is requested by the view, all of the dirty changes
 	// are applied, potentially invalidating some data in the caches. The
@@ -48,6 +54,18 @@ type View struct {
 
 	// gcache caches all package for project
 	gcache *GlobalCache
+
+	// goVersions caches the language versions of the modules, which the
+	// packages are type-checked with.
+	goVersions goVersionCache
+
+	// scratchDir is the directory of the scratch files, see
+	// Project.ScratchFilename.
+	scratchDir string
+
+	// generation is incremented whenever the content of a document or the
+	// packages of the view change, see Generation.
+	generation uint64
 }
 
 type metadataCache struct {
@@ -59,11 +77,28 @@ type metadata struct {
 	id, pkgPath, name string
 	files             []string
 	parents, children map[string]bool
+
+	// tags are the tags an auxiliary package is loaded with, see
+	// linkAuxiliary.
+	tags []string
 }
 
 type packageCache struct {
 	mu       sync.Mutex
 	packages map[string]*entry
+
+	// lastGood holds the last version of the packages which had no parse
+	// errors. It survives the invalidation of the packages.
+	lastGood *lastGoodCache
+}
+
+// putLastGood stores pkg, the package of meta without parse errors, as its
+// last good version if other packages of the view import it. It is assumed
+// that the caller holds the mutex of the pcache.
+func (c *packageCache) putLastGood(meta *metadata, pkg *Package) {
+	if len(meta.parents) > 0 {
+		c.lastGood.put(pkg)
+	}
 }
 
 type entry struct {
@@ -75,19 +110,50 @@ type entry struct {
 func NewView(config *packages.Config) *View {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &View{
+	v := &View{
 		backgroundCtx:  ctx,
 		cancel:         cancel,
 		Config:         *config,
 		files:          make(map[span.URI]*File),
+		overlay:        NewOverlay(),
 		contentChanges: make(map[span.URI]func()),
 		mcache: &metadataCache{
 			packages: make(map[string]*metadata),
 		},
 		pcache: &packageCache{
 			packages: make(map[string]*entry),
+			lastGood: newLastGoodCache(),
 		},
 	}
+	v.overlay.Subscribe(v.documentChanged)
+	return v
+}
+
+// Overlay returns the open documents of the view.
+func (v *View) Overlay() *Overlay {
+	return v.overlay
+}
+
+// documentChanged queues the change of an open document.
+func (v *View) documentChanged(change DocumentChange) {
+	var content []byte
+	if change.New != nil {
+		// An empty document is open, unlike a nil content.
+		content = change.New.Content
+		if content == nil {
+			content = []byte{}
+		}
+	}
+	v.SetContent(context.Background(), change.URI, content)
+}
+
+// Generation returns the generation of the view, which changes whenever the
+// content of a document changes, whichever client edited it, or the packages
+// are invalidated.
+func (v *View) Generation() uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.generation
 }
 
 func (v *View) BackgroundContext() context.Context {
@@ -97,10 +163,76 @@ func (v *View) BackgroundContext() context.Context {
 	return v.backgroundCtx
 }
 
+// cachedPackage returns the variant of the global cache which filename is
+// best seen in, given the open documents. It must not be called with the
+// mutex of the view held.
+func (v *View) cachedPackage(filename string) *Package {
+	v.mu.Lock()
+	gcache := v.gcache
+	open := make(map[string]bool, len(v.Config.Overlay))
+	for name := range v.Config.Overlay {
+		open[util.LowerDriver(name)] = true
+	}
+	v.mu.Unlock()
+
+	return bestVariant(gcache.Variants(filename), open)
+}
+
 func (v *View) FileSet() *token.FileSet {
 	return v.Config.Fset
 }
 
+// SetBuildFlags changes the build flags of the go command. The metadata and
+// the packages of the view are invalidated, since the files of a package
+// depend on the build tags.
+func (v *View) SetBuildFlags(buildFlags []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.Config.BuildFlags = buildFlags
+	v.invalidate()
+}
+
+// buildFlags returns the build flags of the go command.
+func (v *View) buildFlags() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.Config.BuildFlags
+}
+
+// Invalidate drops the metadata and the packages of the view, e.g. once the
+// dependencies of the modules change.
+func (v *View) Invalidate() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.invalidate()
+}
+
+// invalidate drops the metadata and the packages of the view. It assumes
+// that the caller holds the view's mutex.
+func (v *View) invalidate() {
+	v.generation++
+	v.cancel()
+	v.backgroundCtx, v.cancel = context.WithCancel(context.Background())
+
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	v.mcache.packages = make(map[string]*metadata)
+	v.pcache.packages = make(map[string]*entry)
+	v.pcache.lastGood = newLastGoodCache()
+	for _, f := range v.files {
+		f.ast = nil
+		f.token = nil
+		f.pkg = nil
+		f.meta = nil
+		f.imports = nil
+	}
+}
+
 // SetContent sets the overlay contents for a file.
 func (v *View) SetContent(ctx context.Context, uri span.URI, content []byte) error {
 	v.mu.Lock()
@@ -110,6 +242,7 @@ func (v *View) SetContent(ctx context.Context, uri span.URI, content []byte) err
 	// operating on stale data.
 	v.cancel()
 	v.backgroundCtx, v.cancel = context.WithCancel(context.Background())
+	v.generation++
 
 	v.contentChanges[uri] = func() {
 		v.applyContentChange(uri, content)
@@ -142,6 +275,7 @@ func (v *View) applyContentChanges(ctx context.Context) error {
 func (v *View) applyContentChange(uri span.URI, content []byte) {
 	f := v.getFile(uri)
 	f.content = content
+	f.lines = nil
 
 	// TODO(rstambler): Should we recompute these here?
 	f.ast = nil
@@ -157,18 +291,36 @@ func (v *View) applyContentChange(uri span.URI, content []byte) {
 		// The file was active, so we need to forget its content.
 		f.active = false
 		if filename, err := f.uri.Filename(); err == nil {
-			delete(f.view.Config.Overlay, filename)
+			f.view.setOverlay(filename, nil)
 		}
 		f.content = nil
+		f.lines = nil
 	case content != nil:
 		// This is an active overlay, so we update the map.
 		f.active = true
 		if filename, err := f.uri.Filename(); err == nil {
-			f.view.Config.Overlay[filename] = f.content
+			f.view.setOverlay(filename, f.content)
 		}
 	}
 }
 
+// setOverlay sets the content of filename in the overlay of the go/packages
+// configuration, or removes it if content is nil. The map is copied rather
+// than modified, since the loads in progress hold copies of the
+// configuration. It assumes that the caller is holding the view's mutex.
+func (v *View) setOverlay(filename string, content []byte) {
+	overlay := make(map[string][]byte, len(v.Config.Overlay)+1)
+	for name, data := range v.Config.Overlay {
+		overlay[name] = data
+	}
+	if content == nil {
+		delete(overlay, filename)
+	} else {
+		overlay[filename] = content
+	}
+	v.Config.Overlay = overlay
+}
+
 // remove invalidates a package and its reverse dependencies in the view's
 // package cache. It is assumed that the caller has locked both the mutexes
 // of both the mcache and the pcache.
@@ -195,6 +347,36 @@ func (v *View) remove(pkgPath string, seen map[string]bool) {
 	delete(v.pcache.packages, pkgPath)
 }
 
+// invalidateDirs drops the metadata of the packages of the files of dirs, and
+// the packages which depend on them, whose files are loaded again since a
+// file of dirs was created or deleted.
+func (v *View) invalidateDirs(dirs map[string]bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.generation++
+
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	seen := map[string]bool{}
+	for pkgPath, m := range v.mcache.packages {
+		for _, filename := range m.files {
+			if dirs[filepath.Dir(filename)] {
+				v.remove(pkgPath, seen)
+				break
+			}
+		}
+	}
+	for uri, f := range v.files {
+		if filename, err := uri.Filename(); err == nil && dirs[filepath.Dir(filename)] {
+			f.meta = nil
+			f.pkg = nil
+		}
+	}
+}
+
 // GetFile returns a File for the given URI. It will always succeed because it
 // adds the file to the managed set if needed.
 func (v *View) GetFile(ctx context.Context, uri span.URI) (source.File, error) {
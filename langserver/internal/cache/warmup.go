@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+	"golang.org/x/tools/go/packages"
+)
+
+// warmer preloads the workspace importers of opened files in on-demand mode,
+// so that the first find-references or rename after opening a file does not
+// pay the full load cost.
+type warmer struct {
+	mu      sync.Mutex
+	project *Project
+
+	// importers maps a package path to the workspace packages importing it.
+	importers map[string][]string
+
+	// fileMap maps a workspace file to the path of its package.
+	fileMap map[string]string
+
+	// warmed records the packages which have already been loaded, until the
+	// files of the workspace change.
+	warmed map[string]bool
+}
+
+// maxWarmUp is the number of packages a warm-up loads at most, the nearest
+// importers first.
+const maxWarmUp = 32
+
+func newWarmer(project *Project) *warmer {
+	return &warmer{project: project, warmed: make(map[string]bool)}
+}
+
+// warmUp loads the direct and transitive workspace importers of filename into
+// the global cache, maxWarmUp packages at most.
+func (w *warmer) warmUp(filename string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pkgPath, ok := w.fileMap[util.LowerDriver(filename)]
+	if !ok {
+		// The file may be new since we last loaded the import graph.
+		if err := w.buildImportGraph(); err != nil {
+			return err
+		}
+		if pkgPath, ok = w.fileMap[util.LowerDriver(filename)]; !ok {
+			return nil
+		}
+	}
+
+	pending, skipped := w.pending(pkgPath, maxWarmUp)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	pkgs, err := w.load(w.project.loadProfile.mode(), pending...)
+	if err != nil {
+		return err
+	}
+
+	w.project.setCache(pkgs)
+	for _, path := range pending {
+		w.warmed[path] = true
+	}
+	message := fmt.Sprintf("warm up %d packages for %s", len(pending), filename)
+	if skipped > 0 {
+		message += fmt.Sprintf(", %d more importers are loaded on demand", skipped)
+	}
+	w.project.notifyLog(message)
+	return nil
+}
+
+// pending returns the package pkgPath and its direct and transitive
+// workspace importers which are not loaded yet, the nearest first and limit
+// at most, and the number of the other ones. It assumes that the caller holds
+// w.mu.
+func (w *warmer) pending(pkgPath string, limit int) ([]string, int) {
+	var pending []string
+	skipped := 0
+	seen := map[string]bool{pkgPath: true}
+	queue := []string{pkgPath}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		if !w.warmed[path] && w.project.getCache().Get(path) == nil {
+			if len(pending) < limit {
+				pending = append(pending, path)
+			} else {
+				skipped++
+			}
+		}
+		for _, importer := range w.importers[path] {
+			if !seen[importer] {
+				seen[importer] = true
+				queue = append(queue, importer)
+			}
+		}
+	}
+	return pending, skipped
+}
+
+// invalidate forgets the packages loaded and the import graph, since the
+// files of the workspace changed.
+func (w *warmer) invalidate() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warmed = make(map[string]bool)
+	w.importers = nil
+	w.fileMap = nil
+}
+
+// buildImportGraph loads the metadata of every workspace package and records
+// the reverse import graph. It assumes that the caller holds w.mu.
+func (w *warmer) buildImportGraph() error {
+	pkgs, err := w.load(packages.LoadImports, w.project.rootDir+"/...")
+	if err != nil {
+		return err
+	}
+
+	w.importers = make(map[string][]string)
+	w.fileMap = make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.CompiledGoFiles {
+			w.fileMap[util.LowerDriver(file)] = pkg.PkgPath
+		}
+		for importPath := range pkg.Imports {
+			w.importers[importPath] = append(w.importers[importPath], pkg.PkgPath)
+		}
+	}
+
+	return nil
+}
+
+func (w *warmer) load(mode packages.LoadMode, patterns ...string) ([]*packages.Package, error) {
+	v := w.project.getView()
+	v.mu.Lock()
+	cfg := v.Config
+	overlay := make(map[string][]byte, len(v.Config.Overlay))
+	for filename, content := range v.Config.Overlay {
+		overlay[filename] = content
+	}
+	v.mu.Unlock()
+
+	cfg.Context = w.project.getContext()
+	cfg.Dir = w.project.rootDir
+	cfg.Mode = mode
+	cfg.Overlay = overlay
+	return timedLoad(&cfg, patterns...)
+}
+
+// WarmUp preloads the workspace packages importing the file in the
+// background. It does nothing unless the global cache style is on-demand.
+func (p *Project) WarmUp(filename string) {
+	if p.cacheStyle != Ondemand || p.warmer == nil {
+		return
+	}
+
+	if !inDir(util.LowerDriver(filepath.Clean(filename)), p.rootDir) {
+		return
+	}
+
+	go func() {
+		if err := p.warmer.warmUp(filename); err != nil {
+			p.notifyLog(fmt.Sprintf("warm up %s: %s", filename, err))
+		}
+	}()
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWarmerPending(t *testing.T) {
+	w := newWarmer(NewProject(context.Background(), nil, "/work", nil, nil))
+	// b and c import a, d imports b and c, and e imports d.
+	w.importers = map[string][]string{
+		"a": {"b", "c"},
+		"b": {"d"},
+		"c": {"d"},
+		"d": {"e"},
+	}
+	w.fileMap = map[string]string{"/work/a/a.go": "a"}
+
+	pending, skipped := w.pending("a", 3)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(pending, want) || skipped != 2 {
+		t.Errorf("pending(a, 3) = %v, %d, want %v, 2", pending, skipped, want)
+	}
+
+	w.warmed["b"] = true
+	pending, skipped = w.pending("a", maxWarmUp)
+	if want := []string{"a", "c", "d", "e"}; !reflect.DeepEqual(pending, want) || skipped != 0 {
+		t.Errorf("pending(a) = %v, %d, want %v, 0", pending, skipped, want)
+	}
+
+	// The packages are warmed up again once the workspace changes.
+	w.invalidate()
+	if len(w.warmed) != 0 || w.importers != nil || w.fileMap != nil {
+		t.Errorf("the warmer was not invalidated: %v %v %v", w.warmed, w.importers, w.fileMap)
+	}
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+const gosum = "go.sum"
+
+// DidChangeWatchedFiles invalidates the packages affected by the changes of
+// files outside of the editor. A change of the dependencies in a go.mod or a
+// go.sum file reloads the packages of the project, as a change of the build
+// flags does. The packages of the Go files which are created, deleted,
+// or changed while they are not open are loaded again in the background.
+func (p *Project) DidChangeWatchedFiles(changes []lsp.FileEvent) {
+	resolve := false
+	dirs := map[string]bool{}
+	var filenames []string
+	for _, change := range changes {
+		filename, err := source.FromDocumentURI(change.URI).Filename()
+		if err != nil {
+			continue
+		}
+		switch name := filepath.Base(filename); {
+		case name == gomod || name == gosum:
+			resolve = true
+		case strings.HasSuffix(name, goext) && !p.isOpen(filename):
+			dirs[filepath.Dir(filename)] = true
+			filenames = append(filenames, filename)
+		}
+	}
+
+	// The modules are only read in the always cache style, otherwise the
+	// dependencies are resolved when the packages are loaded.
+	if resolve {
+		if p.cacheStyle == Always && !p.modulesChanged() {
+			// The new sums of the modules may resolve the errors of the
+			// packages of the documents.
+			p.view.Invalidate()
+		} else {
+			p.notifyLog("the dependencies changed, reload the packages")
+			p.SetBuildFlags(p.view.buildFlags())
+			return
+		}
+	}
+	if len(dirs) > 0 {
+		p.view.invalidateDirs(dirs)
+		if p.warmer != nil {
+			p.warmer.invalidate()
+		}
+		if p.cacheStyle != None {
+			go p.reloadDirs(dirs, filenames)
+		}
+	}
+}
+
+// isOpen reports whether the document of the file filename is open, in which
+// case its overlay is its content.
+func (p *Project) isOpen(filename string) bool {
+	v := p.getView()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, ok := v.Config.Overlay[filename]
+	return ok
+}
+
+// modulesChanged reports whether the required modules of a module of the
+// project have changed, in which case they are read again.
+func (p *Project) modulesChanged() bool {
+	changed := false
+	for _, m := range p.modules {
+		c, err := m.checkModuleCache()
+		p.notify(err)
+		changed = changed || c
+	}
+	return changed
+}
+
+// reloadDirs loads the packages of dirs again into the global cache. The
+// packages of the files which changed are dropped first, which also drops
+// the packages of dirs which no longer have files.
+func (p *Project) reloadDirs(dirs map[string]bool, filenames []string) {
+	v := p.getView()
+	v.mu.Lock()
+	cfg := v.Config
+	overlay := make(map[string][]byte, len(v.Config.Overlay))
+	for filename, content := range v.Config.Overlay {
+		overlay[filename] = content
+	}
+	v.mu.Unlock()
+
+	var patterns []string
+	for dir := range dirs {
+		patterns = append(patterns, dir)
+	}
+	sort.Strings(patterns)
+
+	cfg.Context = context.Background()
+	cfg.Dir = p.rootDir
+	cfg.Mode = p.loadProfile.mode()
+	cfg.Overlay = overlay
+	pkgs, err := timedLoad(&cfg, patterns...)
+	if err != nil {
+		p.notifyLog(fmt.Sprintf("reload %s: %s", strings.Join(patterns, ", "), err))
+		return
+	}
+
+	c := p.getCache()
+	for _, filename := range filenames {
+		for _, pkg := range c.Variants(filename) {
+			c.Delete(pkg.id)
+		}
+	}
+	for _, pkg := range pkgs {
+		c.Delete(pkg.ID)
+		if len(pkg.CompiledGoFiles) > 0 {
+			c.Add(pkg)
+		}
+	}
+	// The results computed while the packages were reloaded are stale.
+	v.mu.Lock()
+	v.generation++
+	v.mu.Unlock()
+	p.notifyLog(fmt.Sprintf("reload the packages of %s", strings.Join(patterns, ", ")))
+}
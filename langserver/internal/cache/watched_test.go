@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestInvalidateDirs(t *testing.T) {
+	v := NewView(&packages.Config{})
+	add := func(pkgPath string, parents ...string) *File {
+		filename := filepath.FromSlash("/src/" + pkgPath + "/" + pkgPath + ".go")
+		m := &metadata{pkgPath: pkgPath, files: []string{filename}, parents: map[string]bool{}, children: map[string]bool{}}
+		for _, parent := range parents {
+			m.parents[parent] = true
+		}
+		v.mcache.packages[pkgPath] = m
+		pkg := &Package{pkgPath: pkgPath}
+		v.pcache.packages[pkgPath] = &entry{pkg: pkg}
+		f := &File{uri: span.FileURI(filename), view: v, pkg: pkg, meta: m}
+		v.files[f.uri] = f
+		return f
+	}
+	a := add("a", "b")
+	b := add("b")
+	c := add("c")
+
+	// A file of a was created.
+	v.invalidateDirs(map[string]bool{filepath.FromSlash("/src/a"): true})
+	for _, pkgPath := range []string{"a", "b"} {
+		if _, ok := v.pcache.packages[pkgPath]; ok {
+			t.Errorf("package %s is still cached", pkgPath)
+		}
+	}
+	if _, ok := v.pcache.packages["c"]; !ok {
+		t.Error("package c is not cached")
+	}
+	if a.meta != nil || a.pkg != nil {
+		t.Error("the metadata of a.go is not dropped")
+	}
+	if b.pkg != nil || b.meta == nil {
+		t.Error("b.go is not type-checked again with its metadata")
+	}
+	if c.pkg == nil || c.meta == nil {
+		t.Error("c.go is invalidated")
+	}
+}
@@ -0,0 +1,83 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hunks returns the hunks of the unified diff which converts the lines a
+// into b, with the given number of lines of context around the changes. The
+// lines keep their line breaks, as with strings.SplitAfter.
+func Hunks(a, b []string, context int) []string {
+	a, b = trimEmptyLine(a), trimEmptyLine(b)
+	ops := Operations(a, b)
+	var hunks []string
+	for len(ops) > 0 {
+		// The changes whose contexts overlap share a hunk.
+		n := 1
+		for n < len(ops) && ops[n].I1-ops[n-1].I2 <= 2*context {
+			n++
+		}
+		hunks = append(hunks, hunk(a, b, ops[:n], context))
+		ops = ops[n:]
+	}
+	return hunks
+}
+
+// hunk returns the hunk of the operations ops.
+func hunk(a, b []string, ops []*Op, context int) string {
+	first, last := ops[0], ops[len(ops)-1]
+	i1 := first.I1 - context
+	if i1 < 0 {
+		i1 = 0
+	}
+	i2 := last.I2 + context
+	if i2 > len(a) {
+		i2 = len(a)
+	}
+	j1 := first.J1 - (first.I1 - i1)
+
+	var body strings.Builder
+	writeLines := func(prefix string, lines []string) {
+		for _, line := range lines {
+			body.WriteString(prefix + line)
+			if !strings.HasSuffix(line, "\n") {
+				body.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	i, alen, blen := i1, i2-i1, i2-i1
+	for _, op := range ops {
+		writeLines(" ", a[i:op.I1])
+		switch op.Kind {
+		case Delete:
+			writeLines("-", a[op.I1:op.I2])
+			blen -= op.I2 - op.I1
+		case Insert:
+			writeLines("+", b[op.J1:op.J2])
+			blen += op.J2 - op.J1
+		}
+		i = op.I2
+	}
+	writeLines(" ", a[i:i2])
+
+	return fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(i1, alen), hunkRange(j1, blen)) + body.String()
+}
+
+// hunkRange returns the range of the lines of a hunk, whose start is the
+// line before the hunk when it is empty.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// trimEmptyLine removes the empty last line which strings.SplitAfter returns
+// after a final line break.
+func trimEmptyLine(lines []string) []string {
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		return lines[:n-1]
+	}
+	return lines
+}
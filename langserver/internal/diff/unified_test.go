@@ -0,0 +1,40 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHunks(t *testing.T) {
+	for _, tt := range []struct {
+		a, b  string
+		hunks []string
+	}{
+		{
+			a: "a\nb\nc\nd\ne\nf\ng\nh\n",
+			b: "a\nB\nc\nd\ne\nf\ng\nH\n",
+			hunks: []string{
+				"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+				"@@ -7,2 +7,2 @@\n g\n-h\n+H\n",
+			},
+		},
+		{
+			a: "a\nb\nc\n",
+			b: "a\nx\nb\nc",
+			hunks: []string{
+				"@@ -1,3 +1,4 @@\n a\n+x\n b\n-c\n+c\n\\ No newline at end of file\n",
+			},
+		},
+		{
+			a:     "a\n",
+			b:     "a\n",
+			hunks: nil,
+		},
+	} {
+		hunks := Hunks(strings.SplitAfter(tt.a, "\n"), strings.SplitAfter(tt.b, "\n"), 1)
+		if !reflect.DeepEqual(hunks, tt.hunks) {
+			t.Errorf("Hunks(%q, %q) = %q, want %q", tt.a, tt.b, hunks, tt.hunks)
+		}
+	}
+}
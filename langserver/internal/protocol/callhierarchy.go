@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * Represents programming constructs like functions or constructors in the context
+ * of call hierarchy.
+ */
+type CallHierarchyItem struct {
+
+	/**
+	 * The name of this item.
+	 */
+	Name string `json:"name"`
+
+	/**
+	 * The kind of this item.
+	 */
+	Kind lsp.SymbolKind `json:"kind"`
+
+	/**
+	 * More detail for this item, e.g. the signature of a function.
+	 */
+	Detail string `json:"detail,omitempty"`
+
+	/**
+	 * The resource identifier of this item.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The range enclosing this symbol not including leading/trailing whitespace
+	 * but everything else, e.g. comments and code.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The range that should be selected and revealed when this symbol is being
+	 * picked, e.g. the name of a function. Must be contained by the
+	 * [`range`](#CallHierarchyItem.range).
+	 */
+	SelectionRange lsp.Range `json:"selectionRange"`
+}
+
+/**
+ * The parameter of a `callHierarchy/incomingCalls` request.
+ */
+type CallHierarchyIncomingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+/**
+ * Represents an incoming call, e.g. a caller of a method or constructor.
+ */
+type CallHierarchyIncomingCall struct {
+
+	/**
+	 * The item that makes the call.
+	 */
+	From CallHierarchyItem `json:"from"`
+
+	/**
+	 * The ranges at which the calls appear. This is relative to the caller
+	 * denoted by [`this.from`](#CallHierarchyIncomingCall.from).
+	 */
+	FromRanges []lsp.Range `json:"fromRanges"`
+}
+
+/**
+ * The parameter of a `callHierarchy/outgoingCalls` request.
+ */
+type CallHierarchyOutgoingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+/**
+ * Represents an outgoing call, e.g. calling a getter from a method or a method
+ * from a constructor etc.
+ */
+type CallHierarchyOutgoingCall struct {
+
+	/**
+	 * The item that is called.
+	 */
+	To CallHierarchyItem `json:"to"`
+
+	/**
+	 * The range at which this item is called. This is the range relative to
+	 * the caller, e.g the item passed to `callHierarchy/outgoingCalls` request.
+	 */
+	FromRanges []lsp.Range `json:"fromRanges"`
+}
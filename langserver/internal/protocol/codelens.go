@@ -0,0 +1,32 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * A code lens represents a [command](#Command) that should be shown along with
+ * source text, like the number of references, a way to run tests, etc.
+ *
+ * A code lens is _unresolved_ when no command is associated to it. For performance
+ * reasons the creation of a code lens and resolving should be done in two stages.
+ */
+type CodeLens struct {
+
+	/**
+	 * The range in which this code lens is valid. Should only span a single line.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The command this code lens represents.
+	 */
+	Command *lsp.Command `json:"command,omitempty"`
+
+	/**
+	 * A data entry field that is preserved on a code lens item between
+	 * a [CodeLensRequest](#CodeLensRequest) and a [CodeLensResolveRequest]
+	 * (#CodeLensResolveRequest)
+	 */
+	Data interface{} `json:"data,omitempty"`
+}
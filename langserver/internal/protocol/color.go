@@ -0,0 +1,102 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * Represents a color in RGBA space.
+ */
+type Color struct {
+
+	/**
+	 * The red component of this color in the range [0-1].
+	 */
+	Red float64 `json:"red"`
+
+	/**
+	 * The green component of this color in the range [0-1].
+	 */
+	Green float64 `json:"green"`
+
+	/**
+	 * The blue component of this color in the range [0-1].
+	 */
+	Blue float64 `json:"blue"`
+
+	/**
+	 * The alpha component of this color in the range [0-1].
+	 */
+	Alpha float64 `json:"alpha"`
+}
+
+/**
+ * Represents a color range from a document.
+ */
+type ColorInformation struct {
+
+	/**
+	 * The range in the document where this color appears.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The actual color value for this color range.
+	 */
+	Color Color `json:"color"`
+}
+
+/**
+ * Parameters for a [DocumentColorRequest](#DocumentColorRequest).
+ */
+type DocumentColorParams struct {
+
+	/**
+	 * The text document.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+/**
+ * Parameters for a [ColorPresentationRequest](#ColorPresentationRequest).
+ */
+type ColorPresentationParams struct {
+
+	/**
+	 * The text document.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The color to request presentations for.
+	 */
+	Color Color `json:"color"`
+
+	/**
+	 * The range where the color would be inserted. Serves as a context.
+	 */
+	Range lsp.Range `json:"range"`
+}
+
+type ColorPresentation struct {
+
+	/**
+	 * The label of this color presentation. It will be shown on the color
+	 * picker header. By default this is also the text that is inserted when selecting
+	 * this color presentation.
+	 */
+	Label string `json:"label"`
+
+	/**
+	 * An [edit](#TextEdit) which is applied to a document when selecting
+	 * this presentation for the color.  When `falsy` the [label](#ColorPresentation.label)
+	 * is used.
+	 */
+	TextEdit *lsp.TextEdit `json:"textEdit,omitempty"`
+
+	/**
+	 * An optional array of additional [text edits](#TextEdit) that are applied when
+	 * selecting this color presentation. Edits must not overlap with the main [edit](#ColorPresentation.textEdit) nor with themselves.
+	 */
+	AdditionalTextEdits []lsp.TextEdit `json:"additionalTextEdits,omitempty"`
+}
@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * The document diagnostic report kinds.
+ */
+type DocumentDiagnosticReportKind string
+
+const (
+	/**
+	 * A diagnostic report with a full set of problems.
+	 */
+	FullReport DocumentDiagnosticReportKind = "full"
+
+	/**
+	 * A report indicating that the last returned report is still accurate.
+	 */
+	UnchangedReport DocumentDiagnosticReportKind = "unchanged"
+)
+
+/**
+ * Diagnostic options.
+ */
+type DiagnosticOptions struct {
+
+	/**
+	 * An optional identifier under which the diagnostics are managed by the
+	 * client.
+	 */
+	Identifier string `json:"identifier,omitempty"`
+
+	/**
+	 * Whether the language has inter file dependencies meaning that editing
+	 * code in one file can result in a different diagnostic set in another
+	 * file.
+	 */
+	InterFileDependencies bool `json:"interFileDependencies"`
+
+	/**
+	 * The server provides support for workspace diagnostics as well.
+	 */
+	WorkspaceDiagnostics bool `json:"workspaceDiagnostics"`
+}
+
+/**
+ * Parameters of the document diagnostic request.
+ */
+type DocumentDiagnosticParams struct {
+
+	/**
+	 * The text document.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The additional identifier provided during registration.
+	 */
+	Identifier string `json:"identifier,omitempty"`
+
+	/**
+	 * The result id of a previous response if provided.
+	 */
+	PreviousResultID string `json:"previousResultId,omitempty"`
+}
+
+/**
+ * A diagnostic report with a full set of problems.
+ */
+type FullDocumentDiagnosticReport struct {
+
+	/**
+	 * A full document diagnostic report, "full".
+	 */
+	Kind DocumentDiagnosticReportKind `json:"kind"`
+
+	/**
+	 * An optional result id. If provided it will be sent on the next
+	 * diagnostic request for the same document.
+	 */
+	ResultID string `json:"resultId,omitempty"`
+
+	/**
+	 * The actual items.
+	 */
+	Items []lsp.Diagnostic `json:"items"`
+}
+
+/**
+ * A diagnostic report indicating that the last returned report is still
+ * accurate.
+ */
+type UnchangedDocumentDiagnosticReport struct {
+
+	/**
+	 * A document diagnostic report indicating no changes to the last
+	 * result, "unchanged".
+	 */
+	Kind DocumentDiagnosticReportKind `json:"kind"`
+
+	/**
+	 * A result id which will be sent on the next diagnostic request for the
+	 * same document.
+	 */
+	ResultID string `json:"resultId"`
+}
+
+/**
+ * A previous result id in a workspace pull request.
+ */
+type PreviousResultID struct {
+
+	/**
+	 * The URI for which the client knows a result id.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The value of the previous result id.
+	 */
+	Value string `json:"value"`
+}
+
+/**
+ * Parameters of the workspace diagnostic request.
+ */
+type WorkspaceDiagnosticParams struct {
+
+	/**
+	 * The additional identifier provided during registration.
+	 */
+	Identifier string `json:"identifier,omitempty"`
+
+	/**
+	 * The currently known diagnostic reports with their previous result
+	 * ids.
+	 */
+	PreviousResultIds []PreviousResultID `json:"previousResultIds"`
+}
+
+/**
+ * A full document diagnostic report for a workspace diagnostic result.
+ */
+type WorkspaceFullDocumentDiagnosticReport struct {
+	FullDocumentDiagnosticReport
+
+	/**
+	 * The URI for which diagnostic information is reported.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The version number for which the diagnostics are reported. If the
+	 * document is not marked as open null can be provided.
+	 */
+	Version *int `json:"version"`
+}
+
+/**
+ * An unchanged document diagnostic report for a workspace diagnostic result.
+ */
+type WorkspaceUnchangedDocumentDiagnosticReport struct {
+	UnchangedDocumentDiagnosticReport
+
+	/**
+	 * The URI for which diagnostic information is reported.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The version number for which the diagnostics are reported. If the
+	 * document is not marked as open null can be provided.
+	 */
+	Version *int `json:"version"`
+}
+
+/**
+ * A workspace diagnostic report.
+ */
+type WorkspaceDiagnosticReport struct {
+
+	/**
+	 * The WorkspaceFullDocumentDiagnosticReport and the
+	 * WorkspaceUnchangedDocumentDiagnosticReport of the documents.
+	 */
+	Items []interface{} `json:"items"`
+}
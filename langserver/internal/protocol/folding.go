@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * Enum of known range kinds
+ */
+type FoldingRangeKind string
+
+const (
+	/**
+	 * Folding range for a comment
+	 */
+	Comment FoldingRangeKind = "comment"
+
+	/**
+	 * Folding range for a imports or includes
+	 */
+	Imports FoldingRangeKind = "imports"
+
+	/**
+	 * Folding range for a region (e.g. `#region`)
+	 */
+	Region FoldingRangeKind = "region"
+)
+
+/**
+ * Represents a folding range.
+ */
+type FoldingRange struct {
+
+	/**
+	 * The zero-based line number from where the folded range starts.
+	 */
+	StartLine int `json:"startLine"`
+
+	/**
+	 * The zero-based character offset from where the folded range starts. If not defined, defaults to the length of the start line.
+	 */
+	StartCharacter *int `json:"startCharacter,omitempty"`
+
+	/**
+	 * The zero-based line number where the folded range ends.
+	 */
+	EndLine int `json:"endLine"`
+
+	/**
+	 * The zero-based character offset before the folded range ends. If not defined, defaults to the length of the end line.
+	 */
+	EndCharacter *int `json:"endCharacter,omitempty"`
+
+	/**
+	 * Describes the kind of the folding range such as `comment' or 'region'. The kind
+	 * is used to categorize folding ranges and used by commands like 'Fold all comments'. See
+	 * [FoldingRangeKind](#FoldingRangeKind) for an enumeration of standardized kinds.
+	 */
+	Kind FoldingRangeKind `json:"kind,omitempty"`
+}
+
+/**
+ * Parameters for a [FoldingRangeRequest](#FoldingRangeRequest).
+ */
+type FoldingRangeParams struct {
+
+	/**
+	 * The text document.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
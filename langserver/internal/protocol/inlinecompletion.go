@@ -0,0 +1,111 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * Describes how an inline completion provider was triggered.
+ */
+type InlineCompletionTriggerKind int
+
+const (
+	/**
+	 * Completion was triggered explicitly by a user gesture.
+	 */
+	InlineCompletionInvoked InlineCompletionTriggerKind = 1
+
+	/**
+	 * Completion was triggered automatically while editing.
+	 */
+	InlineCompletionAutomatic InlineCompletionTriggerKind = 2
+)
+
+/**
+ * Describes the currently selected completion item.
+ */
+type SelectedCompletionInfo struct {
+
+	/**
+	 * The range that will be replaced if this completion item is accepted.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The text the range will be replaced with if this completion is
+	 * accepted.
+	 */
+	Text string `json:"text"`
+}
+
+/**
+ * Provides information about the context in which an inline completion was
+ * requested.
+ */
+type InlineCompletionContext struct {
+
+	/**
+	 * Describes how the inline completion was triggered.
+	 */
+	TriggerKind InlineCompletionTriggerKind `json:"triggerKind"`
+
+	/**
+	 * Provides information about the currently selected item in the
+	 * autocomplete widget if it is visible.
+	 */
+	SelectedCompletionInfo *SelectedCompletionInfo `json:"selectedCompletionInfo,omitempty"`
+}
+
+/**
+ * A parameter literal used in inline completion requests.
+ */
+type InlineCompletionParams struct {
+	lsp.TextDocumentPositionParams
+
+	/**
+	 * Additional information about the context in which inline completions
+	 * were requested.
+	 */
+	Context InlineCompletionContext `json:"context"`
+}
+
+/**
+ * An inline completion item represents a text snippet that is proposed
+ * inline to complete text that is being typed.
+ */
+type InlineCompletionItem struct {
+
+	/**
+	 * The text to replace the range with. Must be set.
+	 */
+	InsertText string `json:"insertText"`
+
+	/**
+	 * A text that is used to decide if this inline completion should be
+	 * shown.
+	 */
+	FilterText string `json:"filterText,omitempty"`
+
+	/**
+	 * The range to replace. Must begin and end on the same line.
+	 */
+	Range *lsp.Range `json:"range,omitempty"`
+
+	/**
+	 * An optional command that is executed *after* inserting this
+	 * completion.
+	 */
+	Command *lsp.Command `json:"command,omitempty"`
+}
+
+/**
+ * Represents a collection of inline completion items to be presented in
+ * the editor.
+ */
+type InlineCompletionList struct {
+
+	/**
+	 * The inline completion items.
+	 */
+	Items []InlineCompletionItem `json:"items"`
+}
@@ -117,3 +117,68 @@ type CodeAction struct {
 	 */
 	Command Command `json:"command,omitempty"`
 }
+
+/**
+ * A completion item extends lsp.CompletionItem with the additional text edits, which go-lsp
+ * does not define.
+ */
+type CompletionItem struct {
+	lsp.CompletionItem
+
+	/**
+	 * An optional array of additional text edits that are applied when
+	 * selecting this completion. Edits must not overlap (including the same insert position)
+	 * with the main edit nor with themselves.
+	 *
+	 * Additional text edits should be used to change text unrelated to the current cursor position
+	 * (for example adding an import statement at the top of the file if the completion item will
+	 * insert an unqualified type).
+	 */
+	AdditionalTextEdits []lsp.TextEdit `json:"additionalTextEdits,omitempty"`
+}
+
+/**
+ * Represents a collection of [completion items](#CompletionItem) to be presented
+ * in the editor.
+ */
+type CompletionList struct {
+
+	/**
+	 * This list it not complete. Further typing results in recomputing this list.
+	 */
+	IsIncomplete bool `json:"isIncomplete"`
+
+	/**
+	 * The completion items.
+	 */
+	Items []CompletionItem `json:"items"`
+}
+
+/**
+ * The result of a prepare rename request: the range of the identifier to rename, and the
+ * placeholder of its new name.
+ */
+type PrepareRenameResult struct {
+
+	/**
+	 * The range of the string to rename.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * A placeholder text of the string content to be renamed.
+	 */
+	Placeholder string `json:"placeholder"`
+}
+
+/**
+ * Rename options may only be specified if the client states that it supports
+ * `prepareSupport` in its initial `initialize` request.
+ */
+type RenameOptions struct {
+
+	/**
+	 * Renames should be checked and tested before being executed.
+	 */
+	PrepareProvider bool `json:"prepareProvider,omitempty"`
+}
@@ -0,0 +1,98 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * Describes the content type that a client supports in various
+ * result literals like `Hover`, `ParameterInfo` or `CompletionItem`.
+ */
+type MarkupKind string
+
+const (
+	/**
+	 * Plain text is supported as a content format
+	 */
+	PlainText MarkupKind = "plaintext"
+
+	/**
+	 * Markdown is supported as a content format
+	 */
+	Markdown MarkupKind = "markdown"
+)
+
+/**
+ * A `MarkupContent` literal represents a string value which content is
+ * interpreted base on its kind flag.
+ */
+type MarkupContent struct {
+
+	/**
+	 * The type of the Markup
+	 */
+	Kind MarkupKind `json:"kind"`
+
+	/**
+	 * The content itself
+	 */
+	Value string `json:"value"`
+}
+
+/**
+ * The result of a hover request, whose contents are a `MarkupContent`.
+ */
+type Hover struct {
+
+	/**
+	 * The hover's content
+	 */
+	Contents MarkupContent `json:"contents"`
+
+	/**
+	 * An optional range is a range inside a text document
+	 * that is used to visualize a hover, e.g. by changing the background color.
+	 */
+	Range *lsp.Range `json:"range,omitempty"`
+}
+
+/**
+ * Represents programming constructs like variables, classes, interfaces etc.
+ * that appear in a document. Document symbols can be hierarchical and they
+ * have two ranges: one that encloses its definition and one that points to its
+ * most interesting range, e.g. the range of an identifier.
+ */
+type DocumentSymbol struct {
+
+	/**
+	 * The name of this symbol.
+	 */
+	Name string `json:"name"`
+
+	/**
+	 * More detail for this symbol, e.g the signature of a function.
+	 */
+	Detail string `json:"detail,omitempty"`
+
+	/**
+	 * The kind of this symbol.
+	 */
+	Kind lsp.SymbolKind `json:"kind"`
+
+	/**
+	 * The range enclosing this symbol not including leading/trailing whitespace
+	 * but everything else like comments.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The range that should be selected and revealed when this symbol is being
+	 * picked, e.g. the name of a function. Must be contained by the `range`.
+	 */
+	SelectionRange lsp.Range `json:"selectionRange"`
+
+	/**
+	 * Children of this symbol, e.g. properties of a class.
+	 */
+	Children []DocumentSymbol `json:"children,omitempty"`
+}
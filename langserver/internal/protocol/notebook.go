@@ -0,0 +1,269 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * A notebook cell kind.
+ */
+type NotebookCellKind int
+
+const (
+	/**
+	 * A markup-cell is formatted source that is used for display.
+	 */
+	Markup NotebookCellKind = 1
+
+	/**
+	 * A code-cell is source code.
+	 */
+	Code NotebookCellKind = 2
+)
+
+/**
+ * A notebook document.
+ */
+type NotebookDocument struct {
+
+	/**
+	 * The notebook document's URI.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The type of the notebook.
+	 */
+	NotebookType string `json:"notebookType"`
+
+	/**
+	 * The version number of this document (it will increase after each
+	 * change, including undo/redo).
+	 */
+	Version int `json:"version"`
+
+	/**
+	 * The cells of a notebook.
+	 */
+	Cells []NotebookCell `json:"cells"`
+}
+
+/**
+ * A notebook cell.
+ *
+ * A cell's document URI must be unique across ALL notebook
+ * cells and can therefore be used to uniquely identify a
+ * notebook cell or the cell's text document.
+ */
+type NotebookCell struct {
+
+	/**
+	 * The cell's kind.
+	 */
+	Kind NotebookCellKind `json:"kind"`
+
+	/**
+	 * The URI of the cell's text document content.
+	 */
+	Document lsp.DocumentURI `json:"document"`
+}
+
+/**
+ * The params sent in an open notebook document notification.
+ */
+type DidOpenNotebookDocumentParams struct {
+
+	/**
+	 * The notebook document that got opened.
+	 */
+	NotebookDocument NotebookDocument `json:"notebookDocument"`
+
+	/**
+	 * The text documents that represent the content
+	 * of a notebook cell.
+	 */
+	CellTextDocuments []lsp.TextDocumentItem `json:"cellTextDocuments"`
+}
+
+/**
+ * A versioned notebook document identifier.
+ */
+type VersionedNotebookDocumentIdentifier struct {
+
+	/**
+	 * The version number of this notebook document.
+	 */
+	Version int `json:"version"`
+
+	/**
+	 * The notebook document's URI.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+}
+
+/**
+ * A literal to identify a notebook document in the client.
+ */
+type NotebookDocumentIdentifier struct {
+
+	/**
+	 * The notebook document's URI.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+}
+
+/**
+ * A change describing how to move a `NotebookCell`
+ * array from state S to S'.
+ */
+type NotebookCellArrayChange struct {
+
+	/**
+	 * The start offset of the cell that changed.
+	 */
+	Start int `json:"start"`
+
+	/**
+	 * The deleted cells
+	 */
+	DeleteCount int `json:"deleteCount"`
+
+	/**
+	 * The new cells, if any
+	 */
+	Cells []NotebookCell `json:"cells,omitempty"`
+}
+
+/**
+ * The content changes of a cell text document.
+ */
+type NotebookCellTextContentChange struct {
+	Document lsp.VersionedTextDocumentIdentifier `json:"document"`
+
+	Changes []lsp.TextDocumentContentChangeEvent `json:"changes"`
+}
+
+/**
+ * A change event for a notebook document.
+ */
+type NotebookDocumentChangeEvent struct {
+
+	/**
+	 * Changes to cells
+	 */
+	Cells *struct {
+
+		/**
+		 * Changes to the cell structure to add or
+		 * remove cells.
+		 */
+		Structure *struct {
+
+			/**
+			 * The change to the cell array.
+			 */
+			Array NotebookCellArrayChange `json:"array"`
+
+			/**
+			 * Additional opened cell text documents.
+			 */
+			DidOpen []lsp.TextDocumentItem `json:"didOpen,omitempty"`
+
+			/**
+			 * Additional closed cell text documents.
+			 */
+			DidClose []lsp.TextDocumentIdentifier `json:"didClose,omitempty"`
+		} `json:"structure,omitempty"`
+
+		/**
+		 * Changes to notebook cells properties like its
+		 * kind, execution summary or metadata.
+		 */
+		Data []NotebookCell `json:"data,omitempty"`
+
+		/**
+		 * Changes to the text content of notebook cells.
+		 */
+		TextContent []NotebookCellTextContentChange `json:"textContent,omitempty"`
+	} `json:"cells,omitempty"`
+}
+
+/**
+ * The params sent in a change notebook document notification.
+ */
+type DidChangeNotebookDocumentParams struct {
+
+	/**
+	 * The notebook document that did change. The version number points
+	 * to the version after all provided changes have been applied.
+	 */
+	NotebookDocument VersionedNotebookDocumentIdentifier `json:"notebookDocument"`
+
+	/**
+	 * The actual changes to the notebook document.
+	 */
+	Change NotebookDocumentChangeEvent `json:"change"`
+}
+
+/**
+ * The params sent in a save notebook document notification.
+ */
+type DidSaveNotebookDocumentParams struct {
+
+	/**
+	 * The notebook document that got saved.
+	 */
+	NotebookDocument NotebookDocumentIdentifier `json:"notebookDocument"`
+}
+
+/**
+ * The params sent in a close notebook document notification.
+ */
+type DidCloseNotebookDocumentParams struct {
+
+	/**
+	 * The notebook document that got closed.
+	 */
+	NotebookDocument NotebookDocumentIdentifier `json:"notebookDocument"`
+
+	/**
+	 * The text documents that represent the content
+	 * of a notebook cell that got closed.
+	 */
+	CellTextDocuments []lsp.TextDocumentIdentifier `json:"cellTextDocuments"`
+}
+
+/**
+ * The language of the cells of the notebooks a server is interested in.
+ */
+type NotebookCellLanguage struct {
+	Language string `json:"language"`
+}
+
+/**
+ * The notebooks a server is interested in: those of the type Notebook, or
+ * of any type if it is empty, with cells of the languages Cells.
+ */
+type NotebookSelector struct {
+	Notebook string `json:"notebook,omitempty"`
+
+	Cells []NotebookCellLanguage `json:"cells,omitempty"`
+}
+
+/**
+ * Options specific to a notebook plus its cells
+ * to be synced to the server.
+ */
+type NotebookDocumentSyncOptions struct {
+
+	/**
+	 * The notebooks to be synced
+	 */
+	NotebookSelector []NotebookSelector `json:"notebookSelector"`
+
+	/**
+	 * Whether save notification should be forwarded to
+	 * the server. Will only be honored if mode === `notebook`.
+	 */
+	Save bool `json:"save,omitempty"`
+}
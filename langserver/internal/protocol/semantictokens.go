@@ -0,0 +1,43 @@
+package protocol
+
+/**
+ * The legend of the semantic tokens: the names of their types and of their
+ * modifiers, which the tokens refer to by index, and by bit for the
+ * modifiers.
+ */
+type SemanticTokensLegend struct {
+
+	/**
+	 * The token types a server uses.
+	 */
+	TokenTypes []string `json:"tokenTypes"`
+
+	/**
+	 * The token modifiers a server uses.
+	 */
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+/**
+ * An edit of the integers encoding the semantic tokens of a document, five
+ * per token: the line of the token relative to the previous one, its start
+ * character relative to the previous one if they are on the same line, its
+ * length, its type and its modifiers.
+ */
+type SemanticTokensEdit struct {
+
+	/**
+	 * The start offset of the edit.
+	 */
+	Start int `json:"start"`
+
+	/**
+	 * The count of elements to remove.
+	 */
+	DeleteCount int `json:"deleteCount"`
+
+	/**
+	 * The elements to insert.
+	 */
+	Data []uint32 `json:"data,omitempty"`
+}
@@ -0,0 +1,123 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+type ShowMessageRequestParams struct {
+
+	/**
+	 * The message type. See {@link MessageType}
+	 */
+	Type lsp.MessageType `json:"type"`
+
+	/**
+	 * The actual message
+	 */
+	Message string `json:"message"`
+
+	/**
+	 * The message action items to present.
+	 */
+	Actions []MessageActionItem `json:"actions,omitempty"`
+}
+
+type MessageActionItem struct {
+
+	/**
+	 * A short title like 'Retry', 'Open Log' etc.
+	 */
+	Title string `json:"title"`
+}
+
+/**
+ * A token of a work done progress, a string or a number.
+ */
+type ProgressToken interface{}
+
+type ProgressParams struct {
+
+	/**
+	 * The progress token provided by the client or server.
+	 */
+	Token ProgressToken `json:"token"`
+
+	/**
+	 * The progress data.
+	 */
+	Value interface{} `json:"value"`
+}
+
+type WorkDoneProgressBegin struct {
+
+	/**
+	 * Always "begin".
+	 */
+	Kind string `json:"kind"`
+
+	/**
+	 * Mandatory title of the progress operation.
+	 */
+	Title string `json:"title"`
+
+	/**
+	 * Controls if a cancel button should show to allow the user to cancel the
+	 * long running operation.
+	 */
+	Cancellable bool `json:"cancellable,omitempty"`
+
+	/**
+	 * Optional, more detailed associated progress message.
+	 */
+	Message string `json:"message,omitempty"`
+
+	/**
+	 * Optional progress percentage to display (value 100 is considered 100%).
+	 */
+	Percentage int `json:"percentage"`
+}
+
+type WorkDoneProgressReport struct {
+
+	/**
+	 * Always "report".
+	 */
+	Kind string `json:"kind"`
+
+	/**
+	 * Controls enablement state of a cancel button.
+	 */
+	Cancellable bool `json:"cancellable,omitempty"`
+
+	/**
+	 * Optional, more detailed associated progress message.
+	 */
+	Message string `json:"message,omitempty"`
+
+	/**
+	 * Optional progress percentage to display (value 100 is considered 100%).
+	 */
+	Percentage int `json:"percentage"`
+}
+
+type WorkDoneProgressEnd struct {
+
+	/**
+	 * Always "end".
+	 */
+	Kind string `json:"kind"`
+
+	/**
+	 * Optional, a final message indicating to for example indicate the outcome
+	 * of the operation.
+	 */
+	Message string `json:"message,omitempty"`
+}
+
+type WorkDoneProgressCancelParams struct {
+
+	/**
+	 * The token to be used to report progress.
+	 */
+	Token ProgressToken `json:"token"`
+}
@@ -0,0 +1,195 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * A workspace edit represents changes to many resources managed in the workspace.
+ * The edit should either provide `changes` or `documentChanges`.
+ */
+type WorkspaceEdit struct {
+
+	/**
+	 * Holds changes to existing resources.
+	 */
+	Changes map[string][]lsp.TextEdit `json:"changes,omitempty"`
+
+	/**
+	 * The document changes, either TextDocumentEdit or resource operations
+	 * like CreateFile, executed in order.
+	 */
+	DocumentChanges []interface{} `json:"documentChanges,omitempty"`
+}
+
+/**
+ * Create file operation.
+ */
+type CreateFile struct {
+
+	/**
+	 * A create
+	 */
+	Kind string `json:"kind"`
+
+	/**
+	 * The resource to create.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * Additional options
+	 */
+	Options *CreateFileOptions `json:"options,omitempty"`
+}
+
+/**
+ * Options to create a file.
+ */
+type CreateFileOptions struct {
+
+	/**
+	 * Overwrite existing file. Overwrite wins over `ignoreIfExists`
+	 */
+	Overwrite bool `json:"overwrite,omitempty"`
+
+	/**
+	 * Ignore if exists.
+	 */
+	IgnoreIfExists bool `json:"ignoreIfExists,omitempty"`
+}
+
+type TextDocumentEdit struct {
+
+	/**
+	 * The text document to change.
+	 */
+	TextDocument lsp.VersionedTextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The edits to be applied.
+	 */
+	Edits []lsp.TextEdit `json:"edits"`
+}
+
+type ApplyWorkspaceEditParams struct {
+
+	/**
+	 * An optional label of the workspace edit. This label is
+	 * presented in the user interface for example on an undo
+	 * stack to undo the workspace edit.
+	 */
+	Label string `json:"label,omitempty"`
+
+	/**
+	 * The edits to apply.
+	 */
+	Edit WorkspaceEdit `json:"edit"`
+}
+
+type ApplyWorkspaceEditResponse struct {
+
+	/**
+	 * Indicates whether the edit was applied or not.
+	 */
+	Applied bool `json:"applied"`
+
+	/**
+	 * An optional textual description for why the edit was not applied.
+	 */
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+/**
+ * Rename file operation.
+ */
+type RenameFile struct {
+
+	/**
+	 * A rename
+	 */
+	Kind string `json:"kind"`
+
+	/**
+	 * The old (existing) location.
+	 */
+	OldURI lsp.DocumentURI `json:"oldUri"`
+
+	/**
+	 * The new location.
+	 */
+	NewURI lsp.DocumentURI `json:"newUri"`
+
+	/**
+	 * Rename options.
+	 */
+	Options *RenameFileOptions `json:"options,omitempty"`
+}
+
+/**
+ * Rename file options.
+ */
+type RenameFileOptions struct {
+
+	/**
+	 * Overwrite target if existing. Overwrite wins over `ignoreIfExists`
+	 */
+	Overwrite bool `json:"overwrite,omitempty"`
+
+	/**
+	 * Ignores if target exists.
+	 */
+	IgnoreIfExists bool `json:"ignoreIfExists,omitempty"`
+}
+
+/**
+ * General parameters to register for a capability.
+ */
+type Registration struct {
+
+	/**
+	 * The id used to register the request. The id can be used to deregister
+	 * the request again.
+	 */
+	ID string `json:"id"`
+
+	/**
+	 * The method / capability to register for.
+	 */
+	Method string `json:"method"`
+
+	/**
+	 * Options necessary for the registration.
+	 */
+	RegisterOptions interface{} `json:"registerOptions,omitempty"`
+}
+
+type RegistrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+/**
+ * Describe options to be used when registering for file system change events.
+ */
+type DidChangeWatchedFilesRegistrationOptions struct {
+
+	/**
+	 * The watchers to register.
+	 */
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+type FileSystemWatcher struct {
+
+	/**
+	 * The  glob pattern to watch
+	 */
+	GlobPattern string `json:"globPattern"`
+
+	/**
+	 * The kind of events of interest. If omitted it defaults
+	 * to WatchKind.Create | WatchKind.Change | WatchKind.Delete
+	 * which is 7.
+	 */
+	Kind int `json:"kind,omitempty"`
+}
@@ -152,8 +152,10 @@ func (act *Action) execOnce(fset *token.FileSet) {
 	var err error
 	if len(act.Pkg.GetErrors()) > 0 && !pass.Analyzer.RunDespiteErrors {
 		err = fmt.Errorf("analysis skipped due to errors in package")
+	} else if pass.Pkg == nil || pass.TypesInfo == nil {
+		err = fmt.Errorf("analysis skipped due to missing type information")
 	} else {
-		act.result, err = pass.Analyzer.Run(pass)
+		act.result, err = runPass(pass)
 		if err == nil {
 			if got, want := reflect.TypeOf(act.result), pass.Analyzer.ResultType; got != want {
 				err = fmt.Errorf(
@@ -169,6 +171,17 @@ func (act *Action) execOnce(fset *token.FileSet) {
 	pass.ExportPackageFact = nil
 }
 
+// runPass runs the analyzer of pass. A panic of the analyzer is returned as
+// an error, because the analyzers run on the goroutines of the server.
+func runPass(pass *analysis.Pass) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("analyzer %s panicked: %v", pass.Analyzer, r)
+		}
+	}()
+	return pass.Analyzer.Run(pass)
+}
+
 // inheritFacts populates act.facts with
 // those it obtains from its dependency, dep.
 func inheritFacts(act, dep *Action) {
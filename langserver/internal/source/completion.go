@@ -8,6 +8,8 @@ import (
 	"go/token"
 	"go/types"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -19,6 +21,15 @@ type CompletionItem struct {
 	Kind          CompletionItemKind
 	Score         float64
 	Documentation string
+
+	// Import is the path of the package of the candidate when the file does
+	// not import it yet. The package must be imported along with the
+	// candidate.
+	Import string
+
+	// Qualifier is the name of the package qualifying the candidate when it
+	// is inserted, for the candidates of the packages not imported yet.
+	Qualifier string
 }
 
 type CompletionItemKind int
@@ -40,6 +51,40 @@ const (
 // stdScore is the base score value set for all completion items.
 const stdScore float64 = 1.0
 
+// unaddressableWeight demotes the methods with a pointer receiver selected
+// on a value which is not addressable, which do not compile.
+const unaddressableWeight = 0.1
+
+// notAddressableNote labels the methods with a pointer receiver selected on
+// a value which is not addressable.
+const notAddressableNote = "pointer receiver, value is not addressable"
+
+// inaccessibleWeight demotes the unexported members of other packages, which
+// are only candidates with CompletionOptions.Unexported.
+const inaccessibleWeight = 0.1
+
+// inaccessibleNote labels the unexported members of other packages.
+const inaccessibleNote = "unexported, not accessible"
+
+// unimportedWeight demotes the members of the packages which are not
+// imported yet, which are only candidates with CompletionOptions.Unimported.
+const unimportedWeight = 0.5
+
+// maxUnimported is the maximum number of the candidates of the packages which
+// are not imported yet.
+const maxUnimported = 50
+
+// CompletionOptions configures the candidates for completion.
+type CompletionOptions struct {
+	// Unexported reports whether the unexported members of a package other
+	// than the completed one are candidates. Nil means that they are not.
+	Unexported func(pkg *types.Package) bool
+
+	// Unimported reports whether the exported members of the cached packages
+	// which the file does not import are candidates for an identifier.
+	Unimported bool
+}
+
 // finder is a function used to record a completion candidate item in a list of
 // completion items.
 type finder func(types.Object, float64, []CompletionItem) []CompletionItem
@@ -49,13 +94,18 @@ type finder func(types.Object, float64, []CompletionItem) []CompletionItem
 // identifier and can be used by the client to score the quality of the
 // completion. For instance, some clients may tolerate imperfect matches as
 // valid completion results, since users may make typos.
-func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items []CompletionItem, prefix string, err error) {
+func Completion(ctx context.Context, f File, pos token.Pos, cache Cache, opts CompletionOptions) (items []CompletionItem, prefix string, err error) {
 	file := f.GetAST(ctx)
 	pkg := f.GetPackage(ctx)
 	if pkg.IsIllTyped() {
 		return nil, "", fmt.Errorf("package for %s is ill typed", f.URI())
 	}
+	return completion(file, f.GetToken(ctx), f.GetContent(ctx), pos, pkg.GetTypes(), pkg.GetTypesInfo(), cache, opts)
+}
 
+// completion returns the candidates for completion at pos in file, whose
+// token file is tok and content is content, of the package pkg.
+func completion(file *ast.File, tok *token.File, content []byte, pos token.Pos, pkg *types.Package, info *types.Info, cache Cache, opts CompletionOptions) (items []CompletionItem, prefix string, err error) {
 	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
 	if path == nil {
 		return nil, "", fmt.Errorf("cannot find node enclosing position")
@@ -71,9 +121,7 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 			case *ast.Ident, *ast.SelectorExpr:
 				path = p // use preceding ident/selector
 			default:
-				contents := f.GetContent(ctx)
-				token := f.GetToken(ctx)
-				cursorIdent = offsetForIdent(contents, token.Position(pos))
+				cursorIdent = offsetForIdent(content, tok.Position(pos))
 			}
 		}
 	}
@@ -93,17 +141,22 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 	// Save certain facts about the query position, including the expected type
 	// of the completion result, the signature of the function enclosing the
 	// position.
-	typ := expectedType(path, pos, pkg.GetTypesInfo())
-	sig := enclosingFunction(path, pos, pkg.GetTypesInfo())
-	pkgStringer := qualifier(file, pkg.GetTypes(), pkg.GetTypesInfo())
+	typ := expectedType(path, pos, info)
+	sig := enclosingFunction(path, pos, info)
+	pkgStringer := qualifier(file, pkg, info)
+	imported := importedPaths(file)
 
 	seen := make(map[types.Object]bool)
 
 	// found adds a candidate completion.
 	// Only the first candidate of a given name is considered.
 	found := func(obj types.Object, weight float64, items []CompletionItem) []CompletionItem {
-		if obj.Pkg() != nil && obj.Pkg() != pkg.GetTypes() && !obj.Exported() {
-			return items // inaccessible
+		inaccessible := obj.Pkg() != nil && !samePackage(obj.Pkg(), pkg) && !obj.Exported()
+		if inaccessible {
+			if opts.Unexported == nil || !opts.Unexported(obj.Pkg()) {
+				return items
+			}
+			weight *= inaccessibleWeight
 		}
 		if !seen[obj] {
 			seen[obj] = true
@@ -113,6 +166,12 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 			item := formatCompletion(obj, pkgStringer, weight, func(v *types.Var) bool {
 				return isParameter(sig, v)
 			})
+			if inaccessible {
+				item.Detail = strings.TrimSpace(item.Detail + " (" + inaccessibleNote + ")")
+			}
+			if p := obj.Pkg(); p != nil && obj.Parent() == p.Scope() && !samePackage(p, pkg) && !imported[p.Path()] {
+				item.Import = p.Path()
+			}
 
 			// TODO(mbana): figure out how to get `golang.org/x/tools/go/packages.Packages` from `go/types.Package`.
 			// pkg, ok := obj.Pkg().(pkg.GetTypes())
@@ -127,7 +186,7 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 	}
 
 	// The position is within a composite literal.
-	if items, prefix, ok := complit(path, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cursorIdent, cache); ok {
+	if items, prefix, ok := complit(path, pos, pkg, info, found, cursorIdent, cache); ok {
 		return items, prefix, nil
 	}
 	switch n := path[0].(type) {
@@ -137,39 +196,45 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 
 		// Is this the Sel part of a selector?
 		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == n {
-			items, err = selector(sel, pos, pkg.GetTypesInfo(), found, cache)
+			items, err = selector(sel, pos, info, found, cache)
 			return items, prefix, err
 		}
 		// reject defining identifiers
-		if obj, ok := pkg.GetTypesInfo().Defs[n]; ok {
+		if obj, ok := info.Defs[n]; ok {
 			if v, ok := obj.(*types.Var); ok && v.IsField() {
 				// An anonymous field is also a reference to a type.
 			} else {
 				of := ""
 				if obj != nil {
-					qual := types.RelativeTo(pkg.GetTypes())
+					qual := types.RelativeTo(pkg)
 					of += ", of " + types.ObjectString(obj, qual)
 				}
 				return nil, "", fmt.Errorf("this is a definition%s", of)
 			}
 		}
 
-		items = append(items, lexical(path, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cursorIdent, cache)...)
+		items = append(items, lexical(path, pos, pkg, info, found, cursorIdent, cache)...)
+		if opts.Unimported {
+			items = append(items, unimported(file, pkg, prefix, found, cache)...)
+		}
 
 	// The function name hasn't been typed yet, but the parens are there:
 	//   recv.‸(arg)
 	case *ast.TypeAssertExpr:
 		// Create a fake selector expression.
-		items, err = selector(&ast.SelectorExpr{X: n.X}, pos, pkg.GetTypesInfo(), found, cache)
+		items, err = selector(&ast.SelectorExpr{X: n.X}, pos, info, found, cache)
 		return items, prefix, err
 
 	case *ast.SelectorExpr:
-		items, err = selector(n, pos, pkg.GetTypesInfo(), found, cache)
+		items, err = selector(n, pos, info, found, cache)
 		return items, prefix, err
 
 	default:
 		// fallback to lexical completions
-		return lexical(path, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cursorIdent, cache), getPrefix(cursorIdent), nil
+		items, prefix = lexical(path, pos, pkg, info, found, cursorIdent, cache), getPrefix(cursorIdent)
+		if opts.Unimported {
+			items = append(items, unimported(file, pkg, prefix, found, cache)...)
+		}
 	}
 	return items, prefix, nil
 }
@@ -215,26 +280,88 @@ func selector(sel *ast.SelectorExpr, pos token.Pos, info *types.Info, found find
 		return nil, fmt.Errorf("cannot resolve %s", sel.X)
 	}
 
+	return members(tv.Type, tv.Addressable(), stdScore, found, items), nil
+}
+
+// members adds the methods and the fields which can be selected from a value
+// of type T to items. The members promoted through embedded fields are
+// labeled with the embedded type declaring them. The methods with a pointer
+// receiver cannot be called on a value which is not addressable, so they
+// are demoted and labeled as such.
+func members(T types.Type, addressable bool, score float64, found finder, items []CompletionItem) []CompletionItem {
+	add := func(obj types.Object, origin types.Type, score float64, notes ...string) {
+		n := len(items)
+		items = found(obj, score, items)
+		if len(items) == n {
+			return
+		}
+		if origin != nil {
+			notes = append([]string{"from " + embeddedName(origin)}, notes...)
+		}
+		if len(notes) > 0 {
+			items[n].Detail = strings.TrimSpace(items[n].Detail + " (" + strings.Join(notes, ", ") + ")")
+		}
+	}
+
 	// methods of T
-	mset := types.NewMethodSet(tv.Type)
+	mset := types.NewMethodSet(T)
 	for i := 0; i < mset.Len(); i++ {
-		items = found(mset.At(i).Obj(), stdScore, items)
+		add(mset.At(i).Obj(), embeddedOrigin(T, mset.At(i).Index()), score)
 	}
 
 	// methods of *T
-	if tv.Addressable() && !types.IsInterface(tv.Type) && !isPointer(tv.Type) {
-		mset := types.NewMethodSet(types.NewPointer(tv.Type))
+	if !types.IsInterface(T) && !isPointer(T) {
+		ptr := types.NewPointer(T)
+		mset := types.NewMethodSet(ptr)
 		for i := 0; i < mset.Len(); i++ {
-			items = found(mset.At(i).Obj(), stdScore, items)
+			origin := embeddedOrigin(ptr, mset.At(i).Index())
+			if addressable {
+				add(mset.At(i).Obj(), origin, score)
+			} else {
+				// The methods of T have been added already.
+				add(mset.At(i).Obj(), origin, score*unaddressableWeight, notAddressableNote)
+			}
 		}
 	}
 
 	// fields of T
-	for _, f := range fieldSelections(tv.Type) {
-		items = found(f, stdScore, items)
+	for _, f := range fieldSelections(T) {
+		add(f.field, f.origin, score)
 	}
 
-	return items, nil
+	return items
+}
+
+// embeddedOrigin returns the type of the embedded field of T through which
+// the field or method at index is promoted, or nil if it is not promoted.
+func embeddedOrigin(T types.Type, index []int) types.Type {
+	if len(index) < 2 {
+		return nil
+	}
+	for _, i := range index[:len(index)-1] {
+		s, ok := deref(T).Underlying().(*types.Struct)
+		if !ok || i >= s.NumFields() {
+			return nil
+		}
+		T = s.Field(i).Type()
+	}
+	return T
+}
+
+// embeddedName returns the name of an embedded type, eg. *Inner or io.Reader.
+func embeddedName(T types.Type) string {
+	prefix := ""
+	if p, ok := T.(*types.Pointer); ok {
+		prefix = "*"
+		T = p.Elem()
+	}
+	if named, ok := T.(*types.Named); ok {
+		if pkg := named.Obj().Pkg(); pkg != nil && pkg.Name() != "" {
+			return prefix + pkg.Name() + "." + named.Obj().Name()
+		}
+		return prefix + named.Obj().Name()
+	}
+	return prefix + T.String()
 }
 
 func getPrefix(cursorIdent string) string {
@@ -274,26 +401,9 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 			if name + "." == cursorIdent && obj.Type() != types.Typ[types.Invalid] {
 				items = items[0:0]
 
-				objType := obj.Type()
-				// methods of T
-				mset := types.NewMethodSet(objType)
-				for i := 0; i < mset.Len(); i++ {
-					items = found(mset.At(i).Obj(), score, items)
-				}
-
-				// methods of *T
-				if !types.IsInterface(obj.Type()) && !isPointer(objType) {
-					mset := types.NewMethodSet(types.NewPointer(objType))
-					for i := 0; i < mset.Len(); i++ {
-						items = found(mset.At(i).Obj(), score, items)
-					}
-				}
-
-				// fields of T
-				for _, f := range fieldSelections(objType) {
-					items = found(f, score, items)
-				}
-
+				// Only variables are addressable.
+				_, addressable := obj.(*types.Var)
+				items = members(obj.Type(), addressable, score, found, items)
 				return
 			}
 
@@ -380,6 +490,109 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 	return items
 }
 
+// unimported finds the exported members of the cached packages which file
+// does not import and which match prefix. Only the best candidates are kept,
+// since the cache may hold many packages.
+func unimported(file *ast.File, pkg *types.Package, prefix string, found finder, cache Cache) (items []CompletionItem) {
+	if prefix == "" || cache == nil {
+		return nil
+	}
+	imported := importedPaths(file)
+	names := map[string]bool{}
+	for _, spec := range file.Imports {
+		if spec.Name != nil {
+			names[spec.Name.Name] = true
+		} else if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			names[importName(path)] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	cache.Walk(func(p Package) error {
+		tpkg := p.GetTypes()
+		path := p.GetPkgPath()
+		if tpkg == nil || seen[path] || imported[path] || path == pkg.Path() || !canImport(pkg.Path(), path) {
+			return nil
+		}
+		seen[path] = true
+		// The package name must not be shadowed in the file.
+		name := tpkg.Name()
+		if name == "main" || strings.HasSuffix(name, "_test") || names[name] || pkg.Scope().Lookup(name) != nil {
+			return nil
+		}
+		scope := tpkg.Scope()
+		for _, member := range scope.Names() {
+			if !ast.IsExported(member) {
+				continue
+			}
+			match := FuzzyMatch(prefix, member)
+			if match == 0 {
+				continue
+			}
+			n := len(items)
+			items = found(scope.Lookup(member), unimportedWeight*match, items)
+			if len(items) > n {
+				items[n].Qualifier = name
+			}
+		}
+		return nil
+	}, []string{})
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Score > items[j].Score
+	})
+	if len(items) > maxUnimported {
+		items = items[:maxUnimported]
+	}
+	return items
+}
+
+// importedPaths returns the set of the paths of the imports of file.
+func importedPaths(file *ast.File) map[string]bool {
+	paths := map[string]bool{}
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			paths[path] = true
+		}
+	}
+	return paths
+}
+
+// importName guesses the name of the package of an import path, whose last
+// element may be a major version or have a "go-" prefix.
+func importName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexAny(name, ".-"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// canImport reports whether the package from may import the package path,
+// which it may not when path is internal to another tree or vendored.
+func canImport(from, path string) bool {
+	if strings.HasPrefix(path, "vendor/") || strings.Contains(path, "/vendor/") {
+		return false
+	}
+	i := strings.LastIndex(path, "/internal/")
+	switch {
+	case i >= 0:
+		return from == path[:i] || strings.HasPrefix(from, path[:i]+"/")
+	case strings.HasPrefix(path, "internal/"), path == "internal":
+		// The internal packages of the standard library.
+		return !strings.Contains(strings.SplitN(from, "/", 2)[0], ".")
+	case strings.HasSuffix(path, "/internal"):
+		parent := strings.TrimSuffix(path, "/internal")
+		return from == parent || strings.HasPrefix(from, parent+"/")
+	}
+	return true
+}
+
 // inComment checks if given token position is inside ast.Comment node.
 func inComment(pos token.Pos, commentGroups []*ast.CommentGroup) bool {
 	for _, g := range commentGroups {
@@ -494,7 +707,7 @@ func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 			}
 			// Add lexical completions if the user hasn't typed a key value expression
 			// and if the struct fields are defined in the same package as the user is in.
-			if !hasKeys && structPkg == pkg {
+			if !hasKeys && samePackage(structPkg, pkg) {
 				items = append(items, lexical(path, pos, pkg, info, found, prefix, cache)...)
 			}
 			return items, prefix, true
@@ -638,7 +851,7 @@ func qualifier(f *ast.File, pkg *types.Package, info *types.Info) types.Qualifie
 	}
 	// Define qualifier to replace full package paths with names of the imports.
 	return func(p *types.Package) string {
-		if p == pkg {
+		if samePackage(p, pkg) {
 			return ""
 		}
 		if name, ok := imports[p]; ok {
@@ -648,6 +861,12 @@ func qualifier(f *ast.File, pkg *types.Package, info *types.Info) types.Qualifie
 	}
 }
 
+// samePackage reports whether a and b are the same package. The package of a
+// speculative type-checking is a copy of the checked one with the same path.
+func samePackage(a, b *types.Package) bool {
+	return a == b || a != nil && b != nil && a.Path() == b.Path()
+}
+
 // enclosingFunction returns the signature of the function enclosing the given
 // position.
 func enclosingFunction(path []ast.Node, pos token.Pos, info *types.Info) *types.Signature {
@@ -735,32 +954,46 @@ func exprAtPos(pos token.Pos, args []ast.Expr) int {
 	return len(args)
 }
 
-// fieldSelections returns the set of fields that can
-// be selected from a value of type T.
-func fieldSelections(T types.Type) (fields []*types.Var) {
-	// TODO(adonovan): this algorithm doesn't exclude ambiguous
-	// selections that match more than one field/method.
-	// types.NewSelectionSet should do that for us.
+// selectedField is a field which can be selected from a value.
+type selectedField struct {
+	field *types.Var
 
+	// origin is the type of the embedded field through which field is
+	// promoted, or nil if it is not promoted.
+	origin types.Type
+}
+
+// fieldSelections returns the set of fields that can
+// be selected from a value of type T, at any depth of embedding,
+// through embedded structs and pointers to structs. The fields
+// shadowed by a shallower field or method, and the ambiguous ones,
+// are left out.
+func fieldSelections(T types.Type) (fields []selectedField) {
 	seen := make(map[types.Type]bool) // for termination on recursive types
-	var visit func(T types.Type)
-	visit = func(T types.Type) {
-		if !seen[T] {
-			seen[T] = true
-			if T, ok := deref(T).Underlying().(*types.Struct); ok {
-				for i := 0; i < T.NumFields(); i++ {
-					f := T.Field(i)
-					fields = append(fields, f)
+	var visit func(typ, origin types.Type)
+	visit = func(typ, origin types.Type) {
+		if !seen[typ] {
+			seen[typ] = true
+			if s, ok := deref(typ).Underlying().(*types.Struct); ok {
+				for i := 0; i < s.NumFields(); i++ {
+					f := s.Field(i)
+					fields = append(fields, selectedField{field: f, origin: origin})
 					if f.Anonymous() {
-						visit(f.Type())
+						visit(f.Type(), f.Type())
 					}
 				}
 			}
 		}
 	}
-	visit(T)
+	visit(T, nil)
 
-	return fields
+	selectable := fields[:0]
+	for _, f := range fields {
+		if obj, _, _ := types.LookupFieldOrMethod(T, true, f.field.Pkg(), f.field.Name()); obj == f.field {
+			selectable = append(selectable, f)
+		}
+	}
+	return selectable
 }
 
 func isPointer(T types.Type) bool {
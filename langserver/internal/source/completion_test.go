@@ -0,0 +1,253 @@
+package source
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/testutil"
+)
+
+func TestMembers(t *testing.T) {
+	const src = `package p
+
+type Reader interface{ Read() }
+
+type Base struct {
+	ID   int
+	Name string
+}
+
+func (*Base) Save() {}
+
+type Middle struct {
+	*Base
+	Reader
+	Name string
+}
+
+type Other struct{ ID int }
+
+type Outer struct {
+	Middle
+	Other
+	Size int
+}
+`
+	pkg := testutil.CheckSource(t, src).Types
+
+	seen := map[types.Object]bool{}
+	found := func(obj types.Object, score float64, items []CompletionItem) []CompletionItem {
+		if seen[obj] {
+			return items
+		}
+		seen[obj] = true
+		return append(items, formatCompletion(obj, nil, score, func(*types.Var) bool { return false }))
+	}
+	items := members(pkg.Scope().Lookup("Outer").Type(), true, stdScore, found, nil)
+
+	details := map[string]string{}
+	for _, item := range items {
+		details[item.Label] = item.Detail
+	}
+	want := map[string]string{
+		"Read()": "(from p.Reader)",
+		"Save()": "(from *p.Base)",
+		"Middle": "example.com/p.Middle",
+		"Other":  "example.com/p.Other",
+		"Size":   "int",
+		"Base":   "*example.com/p.Base (from p.Middle)",
+		"Reader": "example.com/p.Reader (from p.Middle)",
+		"Name":   "string (from p.Middle)",
+	}
+	for label, detail := range want {
+		if got, ok := details[label]; !ok || got != detail {
+			t.Errorf("detail of %s = %q, want %q", label, got, detail)
+		}
+	}
+	// Other.ID shadows the deeper Middle.Base.ID.
+	if details["ID"] != "int (from p.Other)" {
+		t.Errorf("detail of ID = %q", details["ID"])
+	}
+	if len(items) != len(want)+1 {
+		t.Errorf("got %d items, want %d: %v", len(items), len(want)+1, details)
+	}
+}
+
+func TestMembersNotAddressable(t *testing.T) {
+	const src = `package p
+
+type T struct{}
+
+func (T) Value() {}
+
+func (*T) Pointer() {}
+`
+	pkg := testutil.CheckSource(t, src).Types
+
+	found := func(obj types.Object, score float64, items []CompletionItem) []CompletionItem {
+		for _, item := range items {
+			if item.Label == obj.Name()+"()" {
+				return items
+			}
+		}
+		return append(items, formatCompletion(obj, nil, score, func(*types.Var) bool { return false }))
+	}
+	T := pkg.Scope().Lookup("T").Type()
+
+	items := members(T, true, stdScore, found, nil)
+	if len(items) != 2 || items[1].Label != "Pointer()" || items[1].Detail != "" || items[1].Score != stdScore {
+		t.Errorf("addressable: got %+v", items)
+	}
+
+	items = members(T, false, stdScore, found, nil)
+	if len(items) != 2 || items[0].Label != "Value()" || items[0].Detail != "" {
+		t.Fatalf("not addressable: got %+v", items)
+	}
+	if items[1].Detail != "("+notAddressableNote+")" || items[1].Score >= stdScore {
+		t.Errorf("not addressable: got %+v", items[1])
+	}
+}
+
+func TestCompletionUnexported(t *testing.T) {
+	fset := token.NewFileSet()
+	check := func(path, src string, imp types.Importer) *types.Package {
+		f, err := parser.ParseFile(fset, path+".go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf := types.Config{Importer: imp}
+		pkg, err := conf.Check(path, fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkg
+	}
+	q := check("example.com/q", "package q\n\nfunc Exported() {}\n\nfunc helper() {}\n", nil)
+	const checked = "package p\n\nimport \"example.com/q\"\n\nfunc run() {\n\tq.Exported()\n}\n"
+	p := check("example.com/p", checked, importerFunc(func(string) (*types.Package, error) { return q, nil }))
+
+	edited := strings.Replace(checked, "q.Exported()", "q.", 1)
+	offset := strings.Index(edited, "q.") + len("q.")
+	complete := func(opts CompletionOptions) map[string]CompletionItem {
+		items, _, err := speculativeCompletion(p, "p.go", []byte(edited), offset, nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		labels := map[string]CompletionItem{}
+		for _, item := range items {
+			labels[item.Label] = item
+		}
+		return labels
+	}
+
+	if _, ok := complete(CompletionOptions{})["helper()"]; ok {
+		t.Error("helper is a candidate by default")
+	}
+	items := complete(CompletionOptions{Unexported: func(pkg *types.Package) bool { return pkg == q }})
+	helper, ok := items["helper()"]
+	if !ok {
+		t.Fatal("helper is not a candidate")
+	}
+	if !strings.Contains(helper.Detail, inaccessibleNote) || helper.Score >= items["Exported()"].Score {
+		t.Errorf("helper is not marked as inaccessible: %+v", helper)
+	}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// cachedPackage is a cached package of which only the types are known.
+type cachedPackage struct {
+	Package
+	types *types.Package
+}
+
+func (p cachedPackage) GetTypes() *types.Package { return p.types }
+func (p cachedPackage) GetPkgPath() string       { return p.types.Path() }
+func (p cachedPackage) GetName() string          { return p.types.Name() }
+
+type cachedPackages []Package
+
+func (c cachedPackages) Walk(walkFunc WalkFunc, ranks []string) error {
+	for _, p := range c {
+		if err := walkFunc(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCompletionUnimported(t *testing.T) {
+	fset := token.NewFileSet()
+	check := func(path, src string) *types.Package {
+		f, err := parser.ParseFile(fset, path+".go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, err := new(types.Config).Check(path, fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkg
+	}
+	cache := cachedPackages{
+		cachedPackage{types: check("example.com/json", "package json\n\nfunc Unmarshal() {}\n\nfunc unmarshal() {}\n")},
+		cachedPackage{types: check("example.com/other/internal/codec", "package codec\n\nfunc Unmarshal() {}\n")},
+		cachedPackage{types: check("example.com/cmd", "package main\n\nfunc Unmarshal() {}\n")},
+	}
+	p := check("example.com/p", "package p\n\nfunc run() {\n}\n")
+
+	const src = "package p\n\nfunc run() {\n\tunmar\n}\n"
+	offset := strings.Index(src, "unmar") + len("unmar")
+	complete := func(opts CompletionOptions) []CompletionItem {
+		items, prefix, err := speculativeCompletion(p, "p.go", []byte(src), offset, cache, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefix != "unmar" {
+			t.Errorf("prefix = %q", prefix)
+		}
+		var unimported []CompletionItem
+		for _, item := range items {
+			if item.Qualifier != "" {
+				unimported = append(unimported, item)
+			}
+		}
+		return unimported
+	}
+
+	if items := complete(CompletionOptions{}); len(items) != 0 {
+		t.Errorf("the unimported packages are candidates by default: %+v", items)
+	}
+	items := complete(CompletionOptions{Unimported: true})
+	if len(items) != 1 {
+		t.Fatalf("got %+v, want json.Unmarshal only", items)
+	}
+	if item := items[0]; item.Label != "Unmarshal()" || item.Qualifier != "json" || item.Import != "example.com/json" || item.Score >= stdScore {
+		t.Errorf("got %+v", item)
+	}
+}
+
+func TestCanImport(t *testing.T) {
+	for _, test := range []struct {
+		from, path string
+		want       bool
+	}{
+		{"example.com/p", "fmt", true},
+		{"example.com/p", "internal/poll", false},
+		{"os", "internal/poll", true},
+		{"example.com/p", "example.com/p/internal/q", true},
+		{"example.com/p/r", "example.com/p/internal/q", true},
+		{"example.com/other", "example.com/p/internal/q", false},
+		{"example.com/p", "example.com/p/vendor/q", false},
+	} {
+		if got := canImport(test.from, test.path); got != test.want {
+			t.Errorf("canImport(%q, %q) = %v, want %v", test.from, test.path, got, test.want)
+		}
+	}
+}
@@ -11,6 +11,9 @@ import (
 	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
 	"strings"
 
 	"github.com/saibing/bingo/langserver/internal/diff"
@@ -64,6 +67,76 @@ func Imports(ctx context.Context, f File, rng span.Range) ([]TextEdit, error) {
 	return computeTextEdits(ctx, f, string(formatted)), nil
 }
 
+// AddImport returns the import path of the package name found by goimports,
+// and the edits which add its import to a file.
+func AddImport(ctx context.Context, f File, name string) (string, []TextEdit, error) {
+	path, formatted, err := addImport(f.GetToken(ctx).Name(), f.GetContent(ctx), name)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, computeTextEdits(ctx, f, formatted), nil
+}
+
+// addImport returns the import path of the package name found by goimports,
+// and the content of the file with only this import added.
+func addImport(filename string, content []byte, name string) (string, string, error) {
+	processed, err := imports.Process(filename, content, nil)
+	if err != nil {
+		return "", "", err
+	}
+	fixed, err := parser.ParseFile(token.NewFileSet(), filename, processed, parser.ImportsOnly)
+	if err != nil {
+		return "", "", err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+	if err != nil {
+		return "", "", err
+	}
+
+	imported := map[string]bool{}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		imported[path] = true
+	}
+	for _, spec := range fixed.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		if imported[path] {
+			continue
+		}
+		var specName string
+		if spec.Name != nil {
+			specName = spec.Name.Name
+		}
+		if specName != name && (specName != "" || assumedPackageName(path) != name) {
+			continue
+		}
+		astutil.AddNamedImport(fset, file, specName, path)
+		buf := &bytes.Buffer{}
+		if err := format.Node(buf, fset, file); err != nil {
+			return "", "", err
+		}
+		return path, buf.String(), nil
+	}
+	return "", "", fmt.Errorf("no package %s found", name)
+}
+
+// assumedPackageName returns the name which goimports assumes for the
+// package of path, without a name in the import declaration: the last
+// element of path, without a major version, a "go-" prefix or a dot suffix.
+func assumedPackageName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexAny(name, ".-"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
 func computeTextEdits(ctx context.Context, file File, formatted string) (edits []TextEdit) {
 	u := strings.SplitAfter(string(file.GetContent(ctx)), "\n")
 	f := strings.SplitAfter(formatted, "\n")
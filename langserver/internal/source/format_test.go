@@ -0,0 +1,47 @@
+package source
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddImport(t *testing.T) {
+	const src = `package p
+
+import "fmt"
+
+func f() {
+	fmt.Println(strings.ToUpper(""))
+	var _ os.File
+}
+`
+	path, formatted, err := addImport("p.go", []byte(src), "strings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "strings" {
+		t.Errorf("got path %q, want strings", path)
+	}
+	if !strings.Contains(formatted, "\"strings\"") || strings.Contains(formatted, "\"os\"") {
+		t.Errorf("only strings should be imported:\n%s", formatted)
+	}
+
+	if _, _, err := addImport("p.go", []byte(src), "nosuchpkg"); err == nil {
+		t.Error("an unknown package has been imported")
+	}
+}
+
+func TestAssumedPackageName(t *testing.T) {
+	tests := map[string]string{
+		"strings":                 "strings",
+		"gopkg.in/yaml.v2":        "yaml",
+		"github.com/a/go-sqlite3": "sqlite3",
+		"github.com/a/b/v2":       "b",
+		"github.com/a/kebab-case": "kebab",
+	}
+	for path, want := range tests {
+		if got := assumedPackageName(path); got != want {
+			t.Errorf("assumedPackageName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package source
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Matcher scores how well a candidate matches the pattern typed by the user,
+// from 0 if it does not match to 1 if it matches best.
+type Matcher func(pattern, candidate string) float64
+
+// PrefixMatch matches the candidates of which pattern is a prefix.
+func PrefixMatch(pattern, candidate string) float64 {
+	if strings.HasPrefix(candidate, pattern) {
+		return 1
+	}
+	return 0
+}
+
+// FuzzyMatch matches the candidates which contain the characters of pattern
+// in order, ignoring their case. The candidates of which pattern is a prefix
+// score 1, the others less than 1: the matched characters which follow the
+// previous one, start a word or have the same case score better.
+func FuzzyMatch(pattern, candidate string) float64 {
+	if strings.HasPrefix(candidate, pattern) {
+		return 1
+	}
+
+	p, c := []rune(pattern), []rune(candidate)
+	if len(p) > len(c) {
+		return 0
+	}
+	// scores[i] is the best score of the matches of the pattern so far of
+	// which the last one is the rune at i of the candidate, or -1.
+	scores := make([]float64, len(c))
+	for i := range scores {
+		scores[i] = -1
+	}
+	for j := range p {
+		next := make([]float64, len(c))
+		best := float64(-1) // the best score before i-1
+		for i := range c {
+			next[i] = -1
+			if i >= 2 && scores[i-2] > best {
+				best = scores[i-2]
+			}
+			if unicode.ToLower(c[i]) != unicode.ToLower(p[j]) {
+				continue
+			}
+			score := 1.0
+			if wordStart(c, i) {
+				score++
+			}
+			if c[i] == p[j] {
+				score += 0.5
+			}
+			switch {
+			case j == 0:
+				if i == 0 {
+					score += 1.5
+				}
+				next[i] = score
+			case i > 0 && scores[i-1] >= 0 && scores[i-1]+1.5 >= best:
+				next[i] = scores[i-1] + 1.5 + score
+			case best >= 0:
+				next[i] = best + score
+			}
+		}
+		scores = next
+	}
+
+	max := float64(-1)
+	for _, score := range scores {
+		if score > max {
+			max = score
+		}
+	}
+	if max < 0 {
+		return 0
+	}
+	const perfect = 4 // the score of a consecutive word start of the same case
+	return 0.99 * max / (perfect * float64(len(p)))
+}
+
+// wordStart reports whether the rune at i of s starts a word, in camel case
+// or snake case.
+func wordStart(s []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := s[i-1]
+	return prev == '_' || prev == '.' || unicode.IsUpper(s[i]) && !unicode.IsUpper(prev) || unicode.IsDigit(s[i]) && !unicode.IsDigit(prev)
+}
@@ -0,0 +1,50 @@
+package source
+
+import "testing"
+
+func TestFuzzyMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern, candidate string
+		matches            bool
+	}{
+		{"", "Println", true},
+		{"Print", "Println", true},
+		{"println", "Println", true},
+		{"pln", "Println", true},
+		{"rdir", "ReadDir", true},
+		{"nw", "NewWriter", true},
+		{"xyz", "Println", false},
+		{"Printlnf", "Println", false},
+	} {
+		if got := FuzzyMatch(test.pattern, test.candidate); (got > 0) != test.matches {
+			t.Errorf("FuzzyMatch(%q, %q) = %v, want a match: %v", test.pattern, test.candidate, got, test.matches)
+		}
+	}
+}
+
+func TestFuzzyMatchRanking(t *testing.T) {
+	// Each candidate matches the pattern better than the next one.
+	for _, test := range []struct {
+		pattern    string
+		candidates []string
+	}{
+		{"Print", []string{"Print", "print", "PRINT"}},
+		{"nw", []string{"NewWriter", "Nowhere"}},
+		{"buf", []string{"Buffer", "bytesUntilFlush"}},
+		{"rd", []string{"ReadDir", "Reader"}},
+	} {
+		for i := 1; i < len(test.candidates); i++ {
+			better := FuzzyMatch(test.pattern, test.candidates[i-1])
+			worse := FuzzyMatch(test.pattern, test.candidates[i])
+			if better <= worse {
+				t.Errorf("%q matches %q (%v) no better than %q (%v)", test.pattern, test.candidates[i-1], better, test.candidates[i], worse)
+			}
+		}
+	}
+}
+
+func TestPrefixMatch(t *testing.T) {
+	if PrefixMatch("Pr", "Println") != 1 || PrefixMatch("pr", "Println") != 0 {
+		t.Error("PrefixMatch does not match the prefixes only")
+	}
+}
@@ -0,0 +1,130 @@
+package source
+
+import (
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// maxRecoveries bounds the number of patches applied to the source of a file
+// being edited.
+const maxRecoveries = 8
+
+// closers maps the opening brackets to their closing brackets.
+var closers = map[token.Token]token.Token{
+	token.LPAREN: token.RPAREN,
+	token.LBRACK: token.RBRACK,
+	token.LBRACE: token.RBRACE,
+}
+
+// RecoverSource patches the source src of a file being edited, which fails
+// to parse with err, so that the statement being typed parses: the brackets
+// left open are closed, and the missing operands are replaced by the blank
+// identifier. The patches only insert text at the end of the lines, so the
+// positions of the file are unchanged but in the patched lines. It returns
+// nil if the patched source does not have fewer errors.
+func RecoverSource(filename string, src []byte, err error) []byte {
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) == 0 {
+		return nil
+	}
+	errs := len(list)
+
+	var fixed []byte
+	for i := 0; i < maxRecoveries && len(list) > 0; i++ {
+		patched := patchSource(src, list[0])
+		if patched == nil {
+			break
+		}
+		src = patched
+		_, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.AllErrors)
+		if err == nil {
+			return src
+		}
+		if list, ok = err.(scanner.ErrorList); !ok {
+			break
+		}
+		if len(list) < errs {
+			fixed, errs = src, len(list)
+		}
+	}
+	return fixed
+}
+
+// patchSource returns src patched for its parse error e, or nil if e is not
+// caused by an incomplete expression or statement.
+func patchSource(src []byte, e *scanner.Error) []byte {
+	offset := e.Pos.Offset
+	if offset < 0 || offset > len(src) {
+		return nil
+	}
+
+	var insert string
+	open, end := scanBrackets(src[:offset])
+	switch {
+	case strings.HasSuffix(e.Msg, "found 'EOF'"):
+		// Close every bracket, rather than only the innermost one.
+		open, end = scanBrackets(src)
+		for i := len(open) - 1; i >= 0; i-- {
+			insert += closers[open[i]].String()
+		}
+	case strings.HasPrefix(e.Msg, "expected operand"),
+		strings.HasPrefix(e.Msg, "expected selector or type assertion"),
+		strings.HasPrefix(e.Msg, "expected expression"):
+		insert = "_"
+		// Keep the placeholder from extending a keyword or an identifier.
+		if end > 0 && isIdentByte(src[end-1]) {
+			insert = " _"
+		}
+	case strings.HasPrefix(e.Msg, "missing ','"),
+		strings.HasPrefix(e.Msg, "expected ')'"),
+		strings.HasPrefix(e.Msg, "expected ']'"):
+		if len(open) > 0 {
+			insert = closers[open[len(open)-1]].String()
+		}
+	}
+	if insert == "" {
+		return nil
+	}
+
+	patched := make([]byte, 0, len(src)+len(insert))
+	patched = append(patched, src[:end]...)
+	patched = append(patched, insert...)
+	return append(patched, src[end:]...)
+}
+
+// scanBrackets returns the brackets which are still open at the end of src,
+// outermost first, and the offset of the end of its last token.
+func scanBrackets(src []byte) (open []token.Token, end int) {
+	file := token.NewFileSet().AddFile("", -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return open, end
+		}
+		// The automatic semicolons are not in the source.
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		if lit == "" {
+			lit = tok.String()
+		}
+		end = file.Offset(pos) + len(lit)
+
+		switch tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			open = append(open, tok)
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if n := len(open); n > 0 && closers[open[n-1]] == tok {
+				open = open[:n-1]
+			}
+		}
+	}
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || b >= 0x80
+}
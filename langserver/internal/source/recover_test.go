@@ -0,0 +1,62 @@
+package source
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestRecoverSource(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{
+			src:  "package p\n\nfunc f() {\n\ts.\n}\n",
+			want: "package p\n\nfunc f() {\n\ts._\n}\n",
+		},
+		{
+			src:  "package p\n\nfunc f() {\n\tfmt.Println(\n}\n",
+			want: "package p\n\nfunc f() {\n\tfmt.Println(_)\n}\n",
+		},
+		{
+			src:  "package p\n\nfunc f() {\n\tfmt.Println(a, s.\n}\n",
+			want: "package p\n\nfunc f() {\n\tfmt.Println(a, s._)\n}\n",
+		},
+		{
+			src:  "package p\n\nfunc f() {\n\tfmt.Println(a\n}\n\nfunc g() {}\n",
+			want: "package p\n\nfunc f() {\n\tfmt.Println(a)\n}\n\nfunc g() {}\n",
+		},
+		{
+			src:  "package p\n\nfunc f() {\n\tif x {\n\t\ty() // call\n}\n",
+			want: "package p\n\nfunc f() {\n\tif x {\n\t\ty() // call\n}}\n",
+		},
+		{
+			src:  "package p\n\nfunc f() {\n\tx := []int{1,\n",
+			want: "package p\n\nfunc f() {\n\tx := []int{1,}}\n",
+		},
+		{
+			src:  "package p\n\nfunc f() {\n\tx := a[\n}\n",
+			want: "package p\n\nfunc f() {\n\tx := a[_]\n}\n",
+		},
+		{
+			src:  "package p\n\nfunc f() {\n\tdefer\n}\n",
+			want: "package p\n\nfunc f() {\n\tdefer _\n}\n",
+		},
+		{
+			src:  "package p\n\nfunc f() {\n\treturn x +\n}\n",
+			want: "package p\n\nfunc f() {\n\treturn x +_\n}\n",
+		},
+		{
+			src: "package p\n\nfunc f() {\n\tx := 1 2\n}\n",
+		},
+	}
+	for _, test := range tests {
+		_, err := parser.ParseFile(token.NewFileSet(), "p.go", test.src, parser.AllErrors)
+		if err == nil {
+			t.Fatalf("%q parses", test.src)
+		}
+		if got := string(RecoverSource("p.go", []byte(test.src), err)); got != test.want {
+			t.Errorf("RecoverSource(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// CheckedFile is a File which keeps the package it was last type-checked in
+// while it is being edited.
+type CheckedFile interface {
+	File
+	GetCheckedPackage(ctx context.Context) Package
+}
+
+// SpeculativeCompletion returns the candidates for completion at the offset
+// of the current content of f, like Completion, but only type-checks the
+// function enclosing the offset, against the scope of the package f was last
+// type-checked in. A placeholder identifier is inserted at the cursor and
+// the brackets left open are closed, so that the expression being typed has
+// a type. It fails if the offset is not in the body of a function.
+func SpeculativeCompletion(ctx context.Context, f File, offset int, cache Cache, opts CompletionOptions) ([]CompletionItem, string, error) {
+	var pkg Package
+	if cf, ok := f.(CheckedFile); ok {
+		pkg = cf.GetCheckedPackage(ctx)
+	} else {
+		pkg = f.GetPackage(ctx)
+	}
+	if pkg == nil || pkg.IsIllTyped() || pkg.GetTypes() == nil {
+		return nil, "", fmt.Errorf("no type-checked package for %s", f.URI())
+	}
+	content := f.GetContent(ctx)
+	if offset < 0 || offset > len(content) {
+		return nil, "", fmt.Errorf("offset %d is outside of %s", offset, f.URI())
+	}
+	filename, err := f.URI().Filename()
+	if err != nil {
+		return nil, "", err
+	}
+	return speculativeCompletion(pkg.GetTypes(), filename, content, offset, cache, opts)
+}
+
+// speculativeCompletion returns the candidates for completion at the offset
+// of content, the content of the file filename of pkg.
+func speculativeCompletion(pkg *types.Package, filename string, content []byte, offset int, cache Cache, opts CompletionOptions) ([]CompletionItem, string, error) {
+	src := withPlaceholder(content, offset)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+	if err != nil {
+		if fixed := RecoverSource(filename, src, err); fixed != nil {
+			src = fixed
+			fset = token.NewFileSet()
+			file, _ = parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		}
+	}
+	if file == nil {
+		return nil, "", err
+	}
+	tok := fset.File(file.Pos())
+	if tok == nil {
+		// The file has no package clause.
+		return nil, "", fmt.Errorf("%s is not a Go file", filename)
+	}
+	pos := tok.Pos(offset)
+
+	var decl *ast.FuncDecl
+	for _, d := range file.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Body != nil && fn.Body.Lbrace < pos && pos <= fn.Body.Rbrace {
+			decl = fn
+			break
+		}
+	}
+	if decl == nil {
+		return nil, "", fmt.Errorf("%s is not in the body of a function", fset.Position(pos))
+	}
+
+	fake, scratch, info := checkFunction(pkg, fset, file, decl)
+	return completion(fake, tok, src, pos, scratch, info, cache, opts)
+}
+
+// withPlaceholder returns content with the blank identifier inserted at the
+// offset if an operand is expected there, e.g. after a period or an opening
+// parenthesis.
+func withPlaceholder(content []byte, offset int) []byte {
+	i := offset - 1
+	for i >= 0 && (content[i] == ' ' || content[i] == '\t') {
+		i--
+	}
+	if i < 0 || !strings.ContainsRune(".,([{=:+-*/%&|^<>!", rune(content[i])) {
+		return content
+	}
+
+	src := make([]byte, 0, len(content)+1)
+	src = append(src, content[:offset]...)
+	src = append(src, '_')
+	return append(src, content[offset:]...)
+}
+
+// checkFunction type-checks the function decl of file alone, against the
+// scope of pkg. The function is renamed to the blank identifier, so that it
+// does not conflict with its declaration in pkg. It returns the file of the
+// function, and the copy of pkg and the type information of the check.
+func checkFunction(pkg *types.Package, fset *token.FileSet, file *ast.File, decl *ast.FuncDecl) (*ast.File, *types.Package, *types.Info) {
+	// The copy has the path of pkg, so that its unexported names are
+	// accessible.
+	scratch := types.NewPackage(pkg.Path(), pkg.Name())
+	for _, name := range pkg.Scope().Names() {
+		scratch.Scope().Insert(pkg.Scope().Lookup(name))
+	}
+	scratch.SetImports(pkg.Imports())
+
+	fn := *decl
+	fn.Name = &ast.Ident{NamePos: decl.Name.NamePos, Name: "_"}
+	fake := &ast.File{
+		Package:  file.Package,
+		Name:     file.Name,
+		Imports:  file.Imports,
+		Comments: file.Comments,
+	}
+	for _, d := range file.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			fake.Decls = append(fake.Decls, gen)
+		}
+	}
+	fake.Decls = append(fake.Decls, &fn)
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	cfg := &types.Config{
+		Importer: importedPackages{pkg},
+		// The placeholder and the code being typed have errors.
+		Error: func(error) {},
+	}
+	_ = types.NewChecker(cfg, fset, scratch, info).Files([]*ast.File{fake})
+	return fake, scratch, info
+}
+
+// importedPackages is the importer of the packages imported by pkg.
+type importedPackages struct {
+	pkg *types.Package
+}
+
+func (imp importedPackages) Import(path string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	for _, p := range imp.pkg.Imports() {
+		if p.Path() == path || strings.HasSuffix(p.Path(), "/vendor/"+path) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not imported by %s", path, imp.pkg.Path())
+}
@@ -0,0 +1,99 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/testutil"
+)
+
+func TestSpeculativeCompletion(t *testing.T) {
+	const checked = `package p
+
+import "strings"
+
+type buffer struct {
+	data []byte
+	Size int
+}
+
+func (b *buffer) reset() {}
+
+func use(s string, n int) {}
+
+func run(b *buffer) {
+	_ = strings.ToUpper("")
+}
+`
+	pkg := testutil.CheckSource(t, checked).Types
+
+	tests := []struct {
+		edited, prefix string
+		want           []string
+	}{
+		{
+			// The unexported members of the package are accessible.
+			edited: "\tb.",
+			want:   []string{"Size", "data", "reset()"},
+		},
+		{
+			edited: "\tuse(\"\", b.Si",
+			prefix: "Si",
+			want:   []string{"Size", "data", "reset()"},
+		},
+		{
+			edited: "\tn := len(b.data)\n\tn.",
+		},
+		{
+			edited: "\tstrings.ToU",
+			prefix: "ToU",
+			want:   []string{"ToUpper(s string)"},
+		},
+	}
+	for _, test := range tests {
+		edited := strings.Replace(checked, "\t_ = strings.ToUpper(\"\")", test.edited, 1)
+		offset := strings.Index(edited, test.edited) + len(test.edited)
+		items, prefix, err := speculativeCompletion(pkg, "p.go", []byte(edited), offset, nil, CompletionOptions{})
+		if err != nil {
+			t.Fatalf("%q: %v", test.edited, err)
+		}
+		if prefix != test.prefix {
+			t.Errorf("%q: got prefix %q, want %q", test.edited, prefix, test.prefix)
+		}
+		labels := map[string]bool{}
+		for _, item := range items {
+			labels[item.Label] = true
+		}
+		if test.want == nil && len(labels) > 0 {
+			t.Errorf("%q: got %v, want none", test.edited, labels)
+		}
+		for _, label := range test.want {
+			if !labels[label] {
+				t.Errorf("%q: %s is not a candidate", test.edited, label)
+			}
+		}
+	}
+
+	if _, _, err := speculativeCompletion(pkg, "p.go", []byte(checked), strings.Index(checked, "type buffer"), nil, CompletionOptions{}); err == nil {
+		t.Error("completion outside of a function body succeeded")
+	}
+}
+
+func TestWithPlaceholder(t *testing.T) {
+	tests := []struct {
+		content string
+		offset  int
+		want    string
+	}{
+		{"x.", 2, "x._"},
+		{"f(a, ", 5, "f(a, _"},
+		{"f(a)", 4, "f(a)"},
+		{"foo", 3, "foo"},
+		{"", 0, ""},
+	}
+	for _, test := range tests {
+		if got := string(withPlaceholder([]byte(test.content), test.offset)); got != test.want {
+			t.Errorf("withPlaceholder(%q, %d) = %q, want %q", test.content, test.offset, got, test.want)
+		}
+	}
+}
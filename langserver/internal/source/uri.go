@@ -35,8 +35,16 @@ func toFilename(uri string) (string, error) {
 	}
 
 	uri = uri[len(fileSchemePrefix):]
-	if sys.IsWindows() && uri[0] == '/' {
-		uri = uri[1:]
+	if strings.HasPrefix(uri, "localhost/") {
+		uri = uri[len("localhost"):]
+	}
+	if sys.IsWindows() && uri != "" {
+		if uri[0] == '/' {
+			uri = uri[1:]
+		} else {
+			// file://server/share/path is the UNC path \\server\share\path
+			uri = "//" + uri
+		}
 	}
 
 	uri, err := url.PathUnescape(uri)
@@ -45,6 +53,9 @@ func toFilename(uri string) (string, error) {
 	}
 
 	uri = filepath.FromSlash(uri)
+	if sys.IsWindows() && util.IsReservedName(uri) {
+		return "", fmt.Errorf("%s is a reserved device name", uri)
+	}
 	return util.LowerDriver(uri), nil
 	//uri = util.UriToRealPath(lsp.DocumentURI(uri))
 	//return uri, nil
@@ -62,6 +73,11 @@ func ToURI(path string) URI {
 
 	uri := filepath.ToSlash(util.LowerDriver(path))
 
+	if sys.IsWindows() && strings.HasPrefix(uri, "//") {
+		// UNC path, the server is the host of the URI
+		return URI("file:" + uri)
+	}
+
 	if uri[0] != '/' {
 		uri = "/" + uri
 	}
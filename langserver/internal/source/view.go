@@ -35,6 +35,7 @@ type File interface {
 	GetPackage(ctx context.Context) Package
 	GetToken(ctx context.Context) *token.File
 	GetContent(ctx context.Context) []byte
+	GetLineIndex(ctx context.Context) *span.LineIndex
 }
 
 // Package represents a Go package that has been type-checked. It maintains
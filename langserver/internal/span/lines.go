@@ -0,0 +1,58 @@
+package span
+
+import "unicode/utf8"
+
+// LineIndex holds the byte offset of the start of every line of a content,
+// so that converting a line and UTF-16 column to an offset does not need to
+// rescan the content from its beginning.
+type LineIndex struct {
+	content []byte
+	lines   []int
+}
+
+// NewLineIndex indexes the lines of content.
+func NewLineIndex(content []byte) *LineIndex {
+	lines := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &LineIndex{content: content, lines: lines}
+}
+
+// LineCount returns the number of lines of the content.
+func (l *LineIndex) LineCount() int {
+	return len(l.lines)
+}
+
+// Offset returns the byte offset of the 0-based line and UTF-16 column chr,
+// or -1 if the position is outside of the content. A column in the middle of
+// a surrogate pair resolves to the start of the rune.
+func (l *LineIndex) Offset(line, chr int) int {
+	if line < 0 || line >= len(l.lines) || chr < 0 {
+		return -1
+	}
+
+	offset := l.lines[line]
+	end := len(l.content)
+	if line+1 < len(l.lines) {
+		end = l.lines[line+1] - 1 // the newline
+	}
+
+	for col := 0; col < chr; {
+		if offset >= end {
+			return -1
+		}
+		r, size := utf8.DecodeRune(l.content[offset:end])
+		if r >= 0x10000 {
+			if col+1 == chr {
+				return offset
+			}
+			col++
+		}
+		col++
+		offset += size
+	}
+	return offset
+}
@@ -0,0 +1,81 @@
+package span
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// scanOffset is the linear scan LineIndex replaces.
+func scanOffset(content []byte, line, chr int) int {
+	var l, c, offset int
+	for len(content) > 0 {
+		if l == line && c == chr {
+			return offset
+		}
+		r, size := utf8.DecodeRune(content)
+		c++
+		if r >= 0x10000 {
+			if l == line && c == chr {
+				return offset
+			}
+			c++
+		}
+		offset += size
+		content = content[size:]
+		if r == '\n' {
+			l++
+			c = 0
+		}
+	}
+	if l == line && c == chr {
+		return offset
+	}
+	return -1
+}
+
+func TestLineIndexOffset(t *testing.T) {
+	content := []byte("package a\n\nvar s = \"𐐀é\"\r\nfunc f() {}")
+	lines := NewLineIndex(content)
+	if got := lines.LineCount(); got != 4 {
+		t.Fatalf("LineCount() = %d, want 4", got)
+	}
+
+	for line := -1; line <= 5; line++ {
+		for chr := -1; chr <= 16; chr++ {
+			want := scanOffset(content, line, chr)
+			if line < 0 || chr < 0 {
+				want = -1
+			}
+			if got := lines.Offset(line, chr); got != want {
+				t.Errorf("Offset(%d, %d) = %d, want %d", line, chr, got, want)
+			}
+		}
+	}
+}
+
+func TestLineIndexEmpty(t *testing.T) {
+	lines := NewLineIndex(nil)
+	if got := lines.Offset(0, 0); got != 0 {
+		t.Errorf("Offset(0, 0) = %d, want 0", got)
+	}
+	if got := lines.Offset(0, 1); got != -1 {
+		t.Errorf("Offset(0, 1) = %d, want -1", got)
+	}
+}
+
+var benchContent = []byte(strings.Repeat("\tfmt.Println(\"hello, 世界\")\n", 5000))
+
+func BenchmarkLineIndexOffset(b *testing.B) {
+	lines := NewLineIndex(benchContent)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lines.Offset(i%5000, 10)
+	}
+}
+
+func BenchmarkScanOffset(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		scanOffset(benchContent, i%5000, 10)
+	}
+}
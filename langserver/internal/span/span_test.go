@@ -10,7 +10,7 @@ import (
 	"strings"
 	"testing"
 
-	"golang.org/x/tools/internal/span"
+	"github.com/saibing/bingo/langserver/internal/span"
 )
 
 var (
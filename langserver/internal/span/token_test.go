@@ -9,7 +9,7 @@ import (
 	"go/token"
 	"testing"
 
-	"golang.org/x/tools/internal/span"
+	"github.com/saibing/bingo/langserver/internal/span"
 )
 
 var testdata = []struct {
@@ -12,6 +12,8 @@ import (
 	"strings"
 	"unicode"
 
+	"github.com/saibing/bingo/langserver/internal/sys"
+	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 )
 
@@ -31,6 +33,10 @@ func (uri URI) Filename() (string, error) {
 }
 
 func filename(uri URI) (string, error) {
+	return uriFilename(uri, sys.IsWindows())
+}
+
+func uriFilename(uri URI, windows bool) (string, error) {
 	u, err := url.ParseRequestURI(string(uri))
 	if err != nil {
 		return "", err
@@ -38,6 +44,10 @@ func filename(uri URI) (string, error) {
 	if u.Scheme != fileScheme {
 		return "", fmt.Errorf("only file URIs are supported, got %v", u.Scheme)
 	}
+	if windows && u.Host != "" && u.Host != "localhost" {
+		// UNC path, e.g. file://server/share/file.go
+		return "//" + u.Host + u.Path, nil
+	}
 	if isWindowsDriveURI(u.Path) {
 		u.Path = u.Path[1:]
 	}
@@ -63,6 +73,12 @@ func FileURI(path string) URI {
 		suffix := path[len(prefix):]
 		path = runtime.GOROOT() + suffix
 	}
+	if sys.IsWindows() {
+		path = util.TrimLongPathPrefix(path)
+		if uri, ok := uncURI(path); ok {
+			return uri
+		}
+	}
 	if !isWindowsDrivePath(path) {
 		if abs, err := filepath.Abs(path); err == nil {
 			path = abs
@@ -80,6 +96,25 @@ func FileURI(path string) URI {
 	return URI(u.String())
 }
 
+// uncURI returns the URI of the Windows UNC path \\server\share\path, whose
+// host is the server, and false if path is not a UNC path.
+func uncURI(path string) (URI, bool) {
+	if !strings.HasPrefix(path, `\\`) {
+		return "", false
+	}
+	slashed := strings.Replace(path[2:], `\`, "/", -1)
+	i := strings.Index(slashed, "/")
+	if i < 0 {
+		i = len(slashed)
+	}
+	u := url.URL{
+		Scheme: fileScheme,
+		Host:   slashed[:i],
+		Path:   slashed[i:],
+	}
+	return URI(u.String()), true
+}
+
 // isWindowsDrivePath returns true if the file path is of the form used by
 // Windows. We check if the path begins with a drive letter, followed by a ":".
 func isWindowsDrivePath(path string) bool {
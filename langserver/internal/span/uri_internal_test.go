@@ -0,0 +1,47 @@
+package span
+
+import "testing"
+
+func TestUNCFilename(t *testing.T) {
+	tests := []struct {
+		uri     URI
+		windows bool
+		want    string
+	}{
+		{"file://server/share/dir/a.go", true, "//server/share/dir/a.go"},
+		{"file://server/share/my%20dir/a.go", true, "//server/share/my dir/a.go"},
+		{"file://server/share/dir/a.go", false, "/share/dir/a.go"},
+		{"file://localhost/C:/a.go", true, "C:/a.go"},
+		{"file:///C:/a.go", true, "C:/a.go"},
+		{"file:///home/a.go", false, "/home/a.go"},
+	}
+	for _, test := range tests {
+		got, err := uriFilename(test.uri, test.windows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("uriFilename(%q, %v) = %q, want %q", test.uri, test.windows, got, test.want)
+		}
+	}
+}
+
+func TestUNCURI(t *testing.T) {
+	tests := []struct {
+		path string
+		want URI
+		ok   bool
+	}{
+		{`\\server\share\dir\a.go`, "file://server/share/dir/a.go", true},
+		{`\\server\share\my dir\a.go`, "file://server/share/my%20dir/a.go", true},
+		{`\\server`, "file://server", true},
+		{`C:\a.go`, "", false},
+		{"/home/a.go", "", false},
+	}
+	for _, test := range tests {
+		got, ok := uncURI(test.path)
+		if got != test.want || ok != test.ok {
+			t.Errorf("uncURI(%q) = %q, %v, want %q, %v", test.path, got, ok, test.want, test.ok)
+		}
+	}
+}
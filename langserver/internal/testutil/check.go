@@ -0,0 +1,72 @@
+// Package testutil holds the fixtures shared by the tests of the packages of
+// the language server.
+package testutil
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+// Filename is the name of the file of the sources checked by CheckSource.
+const Filename = "/src/p/p.go"
+
+// Source is a source type-checked by CheckSource.
+type Source struct {
+	Fset  *token.FileSet
+	File  *ast.File
+	Types *types.Package
+	Info  *types.Info
+}
+
+// CheckSource parses src as the file Filename of the package example.com/p,
+// and type-checks it against the standard packages, with all the type
+// information recorded. The errors fail the test.
+func CheckSource(t testing.TB, src string) *Source {
+	t.Helper()
+	s, err := check(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// CheckBrokenSource is CheckSource for the sources with type errors, which
+// are ignored.
+func CheckBrokenSource(t testing.TB, src string) *Source {
+	t.Helper()
+	s, _ := check(t, src)
+	return s
+}
+
+// check is CheckSource, which returns the first type error instead.
+func check(t testing.TB, src string) (*Source, error) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, Filename, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	var firstErr error
+	cfg := &types.Config{
+		Importer: importer.Default(),
+		Error: func(err error) {
+			if firstErr == nil {
+				firstErr = err
+			}
+		},
+	}
+	pkg, _ := cfg.Check("example.com/p", fset, []*ast.File{file}, info)
+	return &Source{Fset: fset, File: file, Types: pkg, Info: info}, firstErr
+}
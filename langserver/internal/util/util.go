@@ -57,7 +57,19 @@ func isURI(s string) bool {
 
 // PathToURI converts given absolute path to file URI
 func PathToURI(path string) lsp.DocumentURI {
-	path = filepath.ToSlash(path)
+	return pathToURI(path, sys.IsWindows())
+}
+
+func pathToURI(path string, windows bool) lsp.DocumentURI {
+	path = toSlash(path, windows)
+	if windows {
+		path = TrimLongPathPrefix(path)
+		if isUNC(path) {
+			// file://server/share/path
+			return lsp.DocumentURI("file:" + path)
+		}
+	}
+
 	parts := strings.SplitN(path, "/", 2)
 
 	// If the first segment is a Windows drive letter, prefix with a slash and skip encoding
@@ -76,10 +88,18 @@ func PathToURI(path string) lsp.DocumentURI {
 
 // UriToPath converts given file URI to path
 func UriToPath(uri lsp.DocumentURI) string {
+	return uriToPath(uri, sys.IsWindows())
+}
+
+func uriToPath(uri lsp.DocumentURI, windows bool) string {
 	u, err := url.Parse(string(uri))
 	if err != nil {
 		return trimFilePrefix(string(uri))
 	}
+	if windows && u.Host != "" && u.Host != "localhost" {
+		// UNC path, keep the server as the first element
+		return "//" + u.Host + u.Path
+	}
 	return u.Path
 }
 
@@ -87,17 +107,81 @@ var regDriveLetter = regexp.MustCompile("^/[a-zA-Z]:")
 
 // UriToRealPath converts the given file URI to the platform specific path
 func UriToRealPath(uri lsp.DocumentURI) string {
-	path := UriToPath(uri)
+	return uriToRealPath(uri, sys.IsWindows())
+}
+
+func uriToRealPath(uri lsp.DocumentURI, windows bool) string {
+	path := uriToPath(uri, windows)
 
 	if regDriveLetter.MatchString(path) {
 		// remove the leading slash if it starts with a drive letter
 		// and convert to back slashes
-		path = filepath.FromSlash(path[1:])
+		path = fromSlash(path[1:], windows)
+	} else if windows && isUNC(path) {
+		path = fromSlash(path, windows)
 	}
 
 	return path
 }
 
+// toSlash is filepath.ToSlash for the given platform.
+func toSlash(path string, windows bool) string {
+	if !windows {
+		return filepath.ToSlash(path)
+	}
+	return strings.Replace(path, `\`, "/", -1)
+}
+
+// fromSlash is filepath.FromSlash for the given platform.
+func fromSlash(path string, windows bool) string {
+	if !windows {
+		return filepath.FromSlash(path)
+	}
+	return strings.Replace(path, "/", `\`, -1)
+}
+
+func isSlash(c byte) bool {
+	return c == '/' || c == '\\'
+}
+
+// isUNC tells if path is a Windows UNC path, e.g. \\server\share\file.go.
+func isUNC(path string) bool {
+	return len(path) > 2 && isSlash(path[0]) && isSlash(path[1]) && !isSlash(path[2])
+}
+
+// TrimLongPathPrefix removes the \\?\ and \\.\ prefixes of a Windows path.
+// \\?\UNC\server\share becomes \\server\share.
+func TrimLongPathPrefix(path string) string {
+	if len(path) < 4 || !isSlash(path[0]) || !isSlash(path[1]) || (path[2] != '?' && path[2] != '.') || !isSlash(path[3]) {
+		return path
+	}
+
+	rest := path[4:]
+	if len(rest) > 4 && strings.EqualFold(rest[:3], "UNC") && isSlash(rest[3]) {
+		return path[:2] + rest[4:]
+	}
+	return rest
+}
+
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// IsReservedName tells if the base name of path is a Windows reserved device
+// name, e.g. NUL or com1.go, which do not denote files.
+func IsReservedName(path string) bool {
+	base := path[strings.LastIndexAny(path, `/\`)+1:]
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	base = strings.TrimRight(base, " ")
+	return reservedNames[strings.ToUpper(base)]
+}
+
 // IsAbs returns true if the given path is absolute
 func IsAbs(path string) bool {
 	// Windows implementation accepts path-like and filepath-like arguments
@@ -126,6 +210,10 @@ func GetRealPath(filename string) string {
 		return filename
 	}
 
+	if isUNC(filename) {
+		return filepath.FromSlash(filename)
+	}
+
 	if filename[0] == '/' {
 		return filename[1:]
 	}
@@ -134,9 +222,11 @@ func GetRealPath(filename string) string {
 }
 
 func LowerDriver(path string) string {
-	if !sys.IsWindows() {
+	if !sys.IsWindows() || path == "" {
 		return path
 	}
 
+	path = TrimLongPathPrefix(path)
+
 	return strings.ToLower(path[0:1]) + path[1:]
 }
@@ -0,0 +1,103 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+func TestPathToURI(t *testing.T) {
+	tests := []struct {
+		path    string
+		windows bool
+		want    lsp.DocumentURI
+	}{
+		{"/home/user/a.go", false, "file:///home/user/a.go"},
+		{`C:\Users\a.go`, true, "file:///C:/Users/a.go"},
+		{"c:/Users/a.go", true, "file:///c:/Users/a.go"},
+		{`\\?\C:\very\long\a.go`, true, "file:///C:/very/long/a.go"},
+		{`\\.\C:\a.go`, true, "file:///C:/a.go"},
+		{`\\server\share\dir\a.go`, true, "file://server/share/dir/a.go"},
+		{"//server/share/a.go", true, "file://server/share/a.go"},
+		{`\\?\UNC\server\share\a.go`, true, "file://server/share/a.go"},
+	}
+	for _, test := range tests {
+		if got := pathToURI(test.path, test.windows); got != test.want {
+			t.Errorf("pathToURI(%q, %v) = %q, want %q", test.path, test.windows, got, test.want)
+		}
+	}
+}
+
+func TestUriToRealPath(t *testing.T) {
+	tests := []struct {
+		uri     lsp.DocumentURI
+		windows bool
+		want    string
+	}{
+		{"file:///home/user/a.go", false, "/home/user/a.go"},
+		{"file:///home/user/my%20dir/a.go", false, "/home/user/my dir/a.go"},
+		{"file:///c:/Users/a.go", true, `c:\Users\a.go`},
+		{"file:///C%3A/Users/a.go", true, `C:\Users\a.go`},
+		{"file://localhost/c:/Users/a.go", true, `c:\Users\a.go`},
+		{"file://server/share/dir/a.go", true, `\\server\share\dir\a.go`},
+		{"file://server/share/my%20dir/a.go", true, `\\server\share\my dir\a.go`},
+		{"file://server/share/dir/a.go", false, "/share/dir/a.go"},
+	}
+	for _, test := range tests {
+		if got := uriToRealPath(test.uri, test.windows); got != test.want {
+			t.Errorf("uriToRealPath(%q, %v) = %q, want %q", test.uri, test.windows, got, test.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	paths := []string{
+		`C:\Users\a.go`,
+		`\\server\share\dir\a.go`,
+		`\\server\share\a.go`,
+	}
+	for _, path := range paths {
+		if got := uriToRealPath(pathToURI(path, true), true); got != path {
+			t.Errorf("round trip of %q = %q", path, got)
+		}
+	}
+}
+
+func TestTrimLongPathPrefix(t *testing.T) {
+	tests := map[string]string{
+		`\\?\C:\a.go`:               `C:\a.go`,
+		`\\.\C:\a.go`:               `C:\a.go`,
+		`\\?\UNC\server\share\a.go`: `\\server\share\a.go`,
+		`\\?\unc\server\share\a.go`: `\\server\share\a.go`,
+		"//?/C:/a.go":               "C:/a.go",
+		`\\server\share\a.go`:       `\\server\share\a.go`,
+		`C:\a.go`:                   `C:\a.go`,
+		"/home/a.go":                "/home/a.go",
+		"":                          "",
+	}
+	for path, want := range tests {
+		if got := TrimLongPathPrefix(path); got != want {
+			t.Errorf("TrimLongPathPrefix(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestIsReservedName(t *testing.T) {
+	tests := map[string]bool{
+		"NUL":                 true,
+		`C:\proj\con`:         true,
+		`C:\proj\aux.go`:      true,
+		"c:/proj/com1.go":     true,
+		`\\server\share\LPT9`: true,
+		"nul .txt":            true,
+		`C:\proj\console.go`:  false,
+		`C:\proj\com10.go`:    false,
+		"c:/proj/nul/a.go":    false,
+		`\\server\share\a.go`: false,
+	}
+	for path, want := range tests {
+		if got := IsReservedName(path); got != want {
+			t.Errorf("IsReservedName(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// constGroup is a const declaration whose values are computed with iota.
+type constGroup struct {
+	decl  *ast.GenDecl
+	names []*ast.Ident
+}
+
+// findConstGroup returns the iota group declaring c in files, if any.
+func findConstGroup(files []*ast.File, c *types.Const) *constGroup {
+	for _, f := range files {
+		if c.Pos() < f.Pos() || c.Pos() > f.End() {
+			continue
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST || c.Pos() < gen.Pos() || c.Pos() > gen.End() {
+				continue
+			}
+			if !usesIota(gen) {
+				return nil
+			}
+			g := &constGroup{decl: gen}
+			for _, spec := range gen.Specs {
+				g.names = append(g.names, spec.(*ast.ValueSpec).Names...)
+			}
+			return g
+		}
+	}
+	return nil
+}
+
+// usesIota reports whether a const declaration uses iota.
+func usesIota(decl *ast.GenDecl) bool {
+	found := false
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// index returns the 1 based position of c in the group.
+func (g *constGroup) index(c *types.Const) int {
+	for i, name := range g.names {
+		if name.Pos() == c.Pos() {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// declaringPackage returns the package of pkg or of its imports which
+// declares obj.
+func declaringPackage(pkg source.Package, obj types.Object) source.Package {
+	if obj.Pkg() == nil {
+		return nil
+	}
+	if obj.Pkg() == pkg.GetTypes() {
+		return pkg
+	}
+	return pkg.GetImport(obj.Pkg().Path())
+}
+
+// iotaHover describes the value of c and its position in its iota group,
+// eg. "= 2, 3rd of the 5 constants of the Color group".
+func iotaHover(pkg source.Package, c *types.Const) string {
+	declPkg := declaringPackage(pkg, c)
+	if declPkg == nil {
+		return ""
+	}
+	g := findConstGroup(declPkg.GetSyntax(), c)
+	if g == nil {
+		return ""
+	}
+	i := g.index(c)
+	if i == 0 {
+		return ""
+	}
+
+	group := "iota"
+	if named, ok := c.Type().(*types.Named); ok {
+		group = named.Obj().Name()
+	}
+	return fmt.Sprintf("`= %s`, %d of the %d constants of the %s group", c.Val().ExactString(), i, len(g.names), group)
+}
+
+// stringerHeader matches the header of the files generated by stringer.
+var stringerHeader = regexp.MustCompile(`Code generated by "stringer (?:.* )?-type[= ]([\w,]+)`)
+
+// stringerFile returns the file of pkg where stringer generated the String
+// method of typeName, if any.
+func stringerFile(pkg source.Package, typeName string) string {
+	for _, f := range pkg.GetSyntax() {
+		for _, cg := range f.Comments {
+			if cg.Pos() > f.Package {
+				break
+			}
+			m := stringerHeader.FindStringSubmatch(cg.Text())
+			if m == nil {
+				continue
+			}
+			for _, name := range strings.Split(m[1], ",") {
+				if name == typeName {
+					return pkg.GetFileSet().Position(f.Pos()).Filename
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// stringerWarning returns a warning if renaming obj makes the String method
+// generated by stringer stale: obj is the enum type, or one of its
+// constants.
+func stringerWarning(pkg source.Package, obj types.Object) string {
+	var typeName *types.TypeName
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		typeName = obj
+	case *types.Const:
+		if named, ok := obj.Type().(*types.Named); ok {
+			typeName = named.Obj()
+		}
+	}
+	if typeName == nil {
+		return ""
+	}
+	declPkg := declaringPackage(pkg, typeName)
+	if declPkg == nil {
+		return ""
+	}
+	file := stringerFile(declPkg, typeName.Name())
+	if file == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s was generated by stringer for %s: run go generate to update it after the rename", filepath.Base(file), typeName.Name())
+}
+
+// warnStaleStringer warns the user on conn when obj, the object renamed in
+// pkg, has a String method generated by stringer, which a rename does not
+// update.
+func warnStaleStringer(ctx context.Context, conn jsonrpc2.JSONRPC2, pkg source.Package, obj types.Object) {
+	if warning := stringerWarning(pkg, obj); warning != "" {
+		_ = conn.Notify(ctx, "window/showMessage", &lsp.ShowMessageParams{Type: lsp.MTWarning, Message: warning})
+	}
+}
@@ -0,0 +1,60 @@
+package langserver
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindConstGroup(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `package p
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+const Answer = 42
+
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+)
+`
+	checked := checkSource(t, src)
+	pkg, files := checked.Types, checked.GetSyntax()
+
+	blue := pkg.Scope().Lookup("Blue").(*types.Const)
+	g := findConstGroup(files, blue)
+	require.NotNil(g)
+	require.Len(g.names, 3)
+	require.Equal(3, g.index(blue))
+	require.Equal("2", blue.Val().ExactString())
+
+	mb := pkg.Scope().Lookup("MB").(*types.Const)
+	g = findConstGroup(files, mb)
+	require.NotNil(g)
+	require.Equal(2, g.index(mb))
+
+	require.Nil(findConstGroup(files, pkg.Scope().Lookup("Answer").(*types.Const)))
+}
+
+func TestStringerHeader(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	m := stringerHeader.FindStringSubmatch(`Code generated by "stringer -type=Color,Shape"; DO NOT EDIT.`)
+	require.Equal([]string{`Code generated by "stringer -type=Color,Shape`, "Color,Shape"}, m)
+
+	m = stringerHeader.FindStringSubmatch(`Code generated by "stringer -linecomment -type Pill"; DO NOT EDIT.`)
+	require.Equal("Pill", m[1])
+
+	require.Nil(stringerHeader.FindStringSubmatch(`Code generated by mockgen. DO NOT EDIT.`))
+}
@@ -0,0 +1,550 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The workspace/executeCommand commands which convert between the Go structs
+// and the JSON samples.
+const (
+	jsonToStructCommand = "bingo.jsonToStruct"
+	structToJSONCommand = "bingo.structToJSON"
+)
+
+// defaultJSONStructName is the name of the generated struct if none is
+// given.
+const defaultJSONStructName = "AutoGenerated"
+
+// JSONToStructParams is the argument of the bingo.jsonToStruct command. The
+// struct is inserted at the position.
+type JSONToStructParams struct {
+	lsp.TextDocumentPositionParams
+
+	// JSON is the sample document.
+	JSON string `json:"json"`
+
+	// Name is the name of the struct type, AutoGenerated by default.
+	Name string `json:"name,omitempty"`
+}
+
+// StructToJSONParams is the argument of the bingo.structToJSON command. The
+// position selects the struct type.
+type StructToJSONParams struct {
+	lsp.TextDocumentPositionParams
+}
+
+func (h *LangHandler) handleJSONToStruct(ctx context.Context, conn jsonrpc2.JSONRPC2, params JSONToStructParams) (*protocol.WorkspaceEdit, error) {
+	name := params.Name
+	if name == "" {
+		name = defaultJSONStructName
+	}
+	if !token.IsIdentifier(name) {
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("invalid struct name: %q", name))
+	}
+
+	src, err := jsonToStruct(name, []byte(params.JSON))
+	if err != nil {
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, err.Error())
+	}
+
+	edit := &protocol.WorkspaceEdit{
+		Changes: map[string][]lsp.TextEdit{
+			string(params.TextDocument.URI): {{
+				Range:   lsp.Range{Start: params.Position, End: params.Position},
+				NewText: string(src),
+			}},
+		},
+	}
+	if err := applyEdit(ctx, conn, "generate struct "+name, edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+func (h *LangHandler) handleStructToJSON(ctx context.Context, params StructToJSONParams) (string, error) {
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		return "", err
+	}
+	obj := structAt(pkg, pos)
+	if obj == nil {
+		return "", requestErrorf(InvalidPositionError, "not a struct type")
+	}
+	return structToJSON(obj.Type())
+}
+
+// structAt returns the struct type of pkg named or declared at pos.
+func structAt(pkg source.Package, pos token.Pos) *types.TypeName {
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil
+	}
+
+	for _, n := range pathNodes {
+		var obj types.Object
+		switch n := n.(type) {
+		case *ast.Ident:
+			obj = pkg.GetTypesInfo().ObjectOf(n)
+		case *ast.TypeSpec:
+			obj = pkg.GetTypesInfo().Defs[n.Name]
+		}
+		if typeName, ok := obj.(*types.TypeName); ok {
+			if _, ok := typeName.Type().Underlying().(*types.Struct); ok {
+				return typeName
+			}
+		}
+	}
+	return nil
+}
+
+// The kinds of the values of a JSON sample, from the most specific.
+const (
+	jsonNull = iota
+	jsonBool
+	jsonInt
+	jsonFloat
+	jsonString
+	jsonObject
+	jsonArray
+	jsonMixed
+)
+
+// jsonShape is the merged shape of the values at the same place of a JSON
+// sample, like the elements of an array.
+type jsonShape struct {
+	kind int
+
+	// nullable is set if one of the values is null.
+	nullable bool
+
+	// fields are the fields of the objects, in the order of the sample.
+	fields []*jsonField
+
+	// elem is the shape of the elements of the arrays, nil if they are
+	// empty.
+	elem *jsonShape
+}
+
+// jsonField is a field of the objects of a shape.
+type jsonField struct {
+	key   string
+	shape *jsonShape
+
+	// optional is set if some objects do not have the field.
+	optional bool
+}
+
+func (s *jsonShape) field(key string) *jsonField {
+	for _, f := range s.fields {
+		if f.key == key {
+			return f
+		}
+	}
+	return nil
+}
+
+// mergeJSONShapes returns the shape of the values of both a and b.
+func mergeJSONShapes(a, b *jsonShape) *jsonShape {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.kind == jsonNull:
+		b.nullable = true
+		return b
+	case b.kind == jsonNull:
+		a.nullable = true
+		return a
+	}
+
+	merged := &jsonShape{kind: a.kind, nullable: a.nullable || b.nullable}
+	switch {
+	case a.kind == b.kind:
+	case a.kind == jsonInt && b.kind == jsonFloat, a.kind == jsonFloat && b.kind == jsonInt:
+		merged.kind = jsonFloat
+		return merged
+	default:
+		merged.kind = jsonMixed
+		return merged
+	}
+
+	switch a.kind {
+	case jsonObject:
+		for _, f := range a.fields {
+			other := b.field(f.key)
+			if other == nil {
+				merged.fields = append(merged.fields, &jsonField{key: f.key, shape: f.shape, optional: true})
+				continue
+			}
+			merged.fields = append(merged.fields, &jsonField{
+				key:      f.key,
+				shape:    mergeJSONShapes(f.shape, other.shape),
+				optional: f.optional || other.optional,
+			})
+		}
+		for _, f := range b.fields {
+			if a.field(f.key) == nil {
+				merged.fields = append(merged.fields, &jsonField{key: f.key, shape: f.shape, optional: true})
+			}
+		}
+	case jsonArray:
+		merged.elem = mergeJSONShapes(a.elem, b.elem)
+	}
+	return merged
+}
+
+// decodeJSONShape decodes the next value of dec. The keys of the objects are
+// kept in order, which a map would lose.
+func decodeJSONShape(dec *json.Decoder) (*jsonShape, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return &jsonShape{kind: jsonNull}, nil
+	case bool:
+		return &jsonShape{kind: jsonBool}, nil
+	case string:
+		return &jsonShape{kind: jsonString}, nil
+	case json.Number:
+		if _, err := tok.Int64(); err == nil {
+			return &jsonShape{kind: jsonInt}, nil
+		}
+		return &jsonShape{kind: jsonFloat}, nil
+	case json.Delim:
+		if tok == '[' {
+			shape := &jsonShape{kind: jsonArray}
+			for dec.More() {
+				elem, err := decodeJSONShape(dec)
+				if err != nil {
+					return nil, err
+				}
+				shape.elem = mergeJSONShapes(shape.elem, elem)
+			}
+			_, err := dec.Token()
+			return shape, err
+		}
+
+		shape := &jsonShape{kind: jsonObject}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONShape(dec)
+			if err != nil {
+				return nil, err
+			}
+			if f := shape.field(key.(string)); f != nil {
+				f.shape = value
+				continue
+			}
+			shape.fields = append(shape.fields, &jsonField{key: key.(string), shape: value})
+		}
+		_, err := dec.Token()
+		return shape, err
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+// jsonToStruct returns the declaration of the type name of the JSON sample
+// data. The nested objects are anonymous structs.
+func jsonToStruct(name string, data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	shape, err := decodeJSONShape(dec)
+	if err != nil {
+		return nil, requestErrorf(InvalidParamsError, "invalid JSON: %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, requestErrorf(InvalidParamsError, "invalid JSON: more than one value")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "type %s ", name)
+	writeJSONType(&buf, shape)
+	buf.WriteString("\n")
+	return format.Source(buf.Bytes())
+}
+
+// writeJSONType writes the Go type of the values of shape.
+func writeJSONType(buf *bytes.Buffer, shape *jsonShape) {
+	if shape == nil {
+		buf.WriteString("interface{}")
+		return
+	}
+
+	switch shape.kind {
+	case jsonBool:
+		buf.WriteString("bool")
+	case jsonInt:
+		buf.WriteString("int64")
+	case jsonFloat:
+		buf.WriteString("float64")
+	case jsonString:
+		buf.WriteString("string")
+	case jsonArray:
+		buf.WriteString("[]")
+		writeJSONType(buf, shape.elem)
+	case jsonObject:
+		if shape.nullable {
+			buf.WriteString("*")
+		}
+		buf.WriteString("struct {\n")
+		taken := map[string]bool{}
+		for _, f := range shape.fields {
+			name := jsonFieldName(f.key)
+			for i := 2; taken[name]; i++ {
+				name = jsonFieldName(f.key) + strconv.Itoa(i)
+			}
+			taken[name] = true
+
+			buf.WriteString(name + " ")
+			writeJSONType(buf, f.shape)
+			tag := f.key
+			if f.optional || f.shape.nullable {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(buf, " `json:%q`\n", tag)
+		}
+		buf.WriteString("}")
+	default:
+		buf.WriteString("interface{}")
+	}
+}
+
+// jsonInitialisms are the initialisms which golint wants in upper case.
+var jsonInitialisms = map[string]bool{
+	"API": true, "DNS": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true,
+	"JSON": true, "SQL": true, "TCP": true, "TLS": true, "UID": true, "URI": true, "URL": true,
+	"UUID": true, "XML": true,
+}
+
+// jsonFieldName returns the exported Go name of the JSON key, eg. "UserID"
+// for "user_id".
+func jsonFieldName(key string) string {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var name strings.Builder
+	for _, word := range words {
+		// Split the camel case words.
+		start := 0
+		runes := []rune(word)
+		for i := 1; i <= len(runes); i++ {
+			if i < len(runes) && !(unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1])) {
+				continue
+			}
+			part := string(runes[start:i])
+			if upper := strings.ToUpper(part); jsonInitialisms[upper] {
+				name.WriteString(upper)
+			} else {
+				r := []rune(part)
+				name.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+			}
+			start = i
+		}
+	}
+	s := name.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// structToJSON returns an indented sample JSON document of the type t. It
+// follows the rules of encoding/json for the tags and the embedded structs.
+// The slices and the maps have a single element.
+func structToJSON(t types.Type) (string, error) {
+	var buf bytes.Buffer
+	writeJSONSample(&buf, t, map[types.Type]bool{})
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// writeJSONSample writes the sample value of t. The types being written are
+// in parents, which stops the recursive types.
+func writeJSONSample(buf *bytes.Buffer, t types.Type, parents map[types.Type]bool) {
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Time" {
+			buf.WriteString(`"0001-01-01T00:00:00Z"`)
+			return
+		}
+		if hasMethod(t, "MarshalJSON") {
+			buf.WriteString("null")
+			return
+		}
+		if hasMethod(t, "MarshalText") {
+			buf.WriteString(`""`)
+			return
+		}
+		if parents[named] {
+			buf.WriteString("null")
+			return
+		}
+		parents[named] = true
+		defer delete(parents, named)
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			buf.WriteString("false")
+		case u.Info()&types.IsNumeric != 0:
+			buf.WriteString("0")
+		case u.Info()&types.IsString != 0:
+			buf.WriteString(`""`)
+		default:
+			buf.WriteString("null")
+		}
+
+	case *types.Pointer:
+		writeJSONSample(buf, u.Elem(), parents)
+
+	case *types.Slice:
+		if b, ok := u.Elem().Underlying().(*types.Basic); ok && b.Kind() == types.Byte {
+			// encoding/json encodes []byte in base64.
+			buf.WriteString(`""`)
+			return
+		}
+		buf.WriteString("[")
+		writeJSONSample(buf, u.Elem(), parents)
+		buf.WriteString("]")
+
+	case *types.Array:
+		buf.WriteString("[")
+		for i := int64(0); i < u.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			writeJSONSample(buf, u.Elem(), parents)
+		}
+		buf.WriteString("]")
+
+	case *types.Map:
+		key := `"key"`
+		if b, ok := u.Key().Underlying().(*types.Basic); ok && b.Info()&types.IsInteger != 0 {
+			key = `"0"`
+		}
+		buf.WriteString("{" + key + ":")
+		writeJSONSample(buf, u.Elem(), parents)
+		buf.WriteString("}")
+
+	case *types.Struct:
+		buf.WriteString("{")
+		for i, f := range jsonFields(u, map[string]bool{}) {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			fmt.Fprintf(buf, "%q:", f.name)
+			if f.quoted {
+				var value bytes.Buffer
+				writeJSONSample(&value, f.typ, parents)
+				fmt.Fprintf(buf, "%q", value.String())
+				continue
+			}
+			writeJSONSample(buf, f.typ, parents)
+		}
+		buf.WriteString("}")
+
+	default:
+		// The interfaces, and the channels and functions which encoding/json
+		// rejects.
+		buf.WriteString("null")
+	}
+}
+
+// jsonStructField is a field of the JSON encoding of a struct.
+type jsonStructField struct {
+	name string
+	typ  types.Type
+
+	// quoted is set by the ",string" option.
+	quoted bool
+}
+
+// jsonFields returns the JSON fields of st, including those of the embedded
+// structs which are not tagged with a name. The names of the outer fields,
+// which take precedence, are in names.
+func jsonFields(st *types.Struct, names map[string]bool) []jsonStructField {
+	var fields, embedded []jsonStructField
+	var inlined []*types.Struct
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i)).Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+
+		if f.Anonymous() && name == "" {
+			if inner, ok := source.Deref(f.Type()).Underlying().(*types.Struct); ok {
+				inlined = append(inlined, inner)
+				continue
+			}
+		}
+		if !f.Exported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name()
+		}
+		if names[name] {
+			continue
+		}
+		names[name] = true
+		fields = append(fields, jsonStructField{
+			name:   name,
+			typ:    f.Type(),
+			quoted: strings.Contains(opts, ",string") && isJSONQuotable(f.Type()),
+		})
+	}
+
+	// The fields of the embedded structs are shadowed by the direct fields.
+	for _, inner := range inlined {
+		embedded = append(embedded, jsonFields(inner, names)...)
+	}
+	return append(fields, embedded...)
+}
+
+// isJSONQuotable reports whether the ",string" option applies to t.
+func isJSONQuotable(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&(types.IsBoolean|types.IsNumeric|types.IsString) != 0
+}
+
+// hasMethod reports whether t or *t has the method name.
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), false, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
@@ -0,0 +1,102 @@
+package langserver
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONToStruct(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	src, err := jsonToStruct("User", []byte(`{
+	"id": 1,
+	"user_name": "gopher",
+	"score": 1.5,
+	"active": true,
+	"avatarUrl": null,
+	"tags": ["a", "b"],
+	"address": {"city": "Paris"},
+	"friends": [{"id": 2, "nick": "x"}, {"id": 3.5}],
+	"extra": [1, "one"]
+}`))
+	require.NoError(err)
+	require.Equal("type User struct {\n"+
+		"\tID        int64       `json:\"id\"`\n"+
+		"\tUserName  string      `json:\"user_name\"`\n"+
+		"\tScore     float64     `json:\"score\"`\n"+
+		"\tActive    bool        `json:\"active\"`\n"+
+		"\tAvatarURL interface{} `json:\"avatarUrl\"`\n"+
+		"\tTags      []string    `json:\"tags\"`\n"+
+		"\tAddress   struct {\n"+
+		"\t\tCity string `json:\"city\"`\n"+
+		"\t} `json:\"address\"`\n"+
+		"\tFriends []struct {\n"+
+		"\t\tID   float64 `json:\"id\"`\n"+
+		"\t\tNick string  `json:\"nick,omitempty\"`\n"+
+		"\t} `json:\"friends\"`\n"+
+		"\tExtra []interface{} `json:\"extra\"`\n"+
+		"}\n", string(src))
+
+	_, err = jsonToStruct("T", []byte(`{"a": 1} {}`))
+	require.EqualError(err, "invalid JSON: more than one value")
+}
+
+func TestJSONFieldName(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	for key, name := range map[string]string{
+		"id":         "ID",
+		"user_id":    "UserID",
+		"httpStatus": "HTTPStatus",
+		"firstName":  "FirstName",
+		"2fa":        "X2fa",
+		"":           "X",
+	} {
+		require.Equal(name, jsonFieldName(key), key)
+	}
+}
+
+func TestStructToJSON(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	_, _, pkg, _ := checkStubbedPackage(t, `package p
+
+import "time"
+
+type Base struct {
+	ID      int64     `+"`json:\"id,string\"`"+`
+	Created time.Time `+"`json:\"created\"`"+`
+}
+
+type Node struct {
+	*Base
+	Name     string            `+"`json:\"name,omitempty\"`"+`
+	Secret   string            `+"`json:\"-\"`"+`
+	Labels   map[string]string `+"`json:\"labels\"`"+`
+	Children []*Node
+	Data     []byte
+	hidden   int
+}
+`, nil)
+	obj := pkg.Scope().Lookup("Node").(*types.TypeName)
+
+	sample, err := structToJSON(obj.Type())
+	require.NoError(err)
+	require.Equal(`{
+  "name": "",
+  "labels": {
+    "key": ""
+  },
+  "Children": [
+    null
+  ],
+  "Data": "",
+  "id": "0",
+  "created": "0001-01-01T00:00:00Z"
+}`, sample)
+}
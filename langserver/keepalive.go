@@ -0,0 +1,42 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// pingMethod is the request a client sends to check that the server is
+// alive, whose result is its parameter, and the request the server sends
+// to check that the client is alive, see keepalive.
+const pingMethod = "bingo/ping"
+
+// keepalive pings the client of conn every interval until the connection is
+// closed, and closes it if the client does not answer a ping within the
+// interval. Any answer, including the error of a client which does not
+// implement bingo/ping, shows that the client is alive.
+func keepalive(conn *jsonrpc2.Conn, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.DisconnectNotify():
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := conn.Call(ctx, pingMethod, nil, nil)
+		cancel()
+		switch err {
+		case context.DeadlineExceeded:
+			log.Printf("the client did not answer %s within %s, closing the connection", pingMethod, interval)
+			conn.Close()
+			return
+		case jsonrpc2.ErrClosed:
+			return
+		}
+	}
+}
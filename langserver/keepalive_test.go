@@ -0,0 +1,71 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestKeepalive(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const interval = 50 * time.Millisecond
+	tests := []struct {
+		name   string
+		answer bool
+		closed bool
+	}{
+		// The error of a client which does not implement bingo/ping keeps the
+		// connection.
+		{name: "answering", answer: true, closed: false},
+		{name: "wedged", answer: false, closed: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			server := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(a, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (interface{}, error) {
+				return nil, nil
+			}))
+			defer server.Close()
+			client := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(b, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+				if !test.answer {
+					<-ctx.Done()
+				}
+				return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: "method not found"}
+			}))
+			defer client.Close()
+
+			go keepalive(server, interval)
+			select {
+			case <-server.DisconnectNotify():
+				if !test.closed {
+					t.Error("the connection was closed, want it kept")
+				}
+			case <-time.After(10 * interval):
+				if test.closed {
+					t.Error("the connection was kept, want it closed")
+				}
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	h := &LangHandler{HandlerShared: &HandlerShared{}}
+	params := json.RawMessage(`{"token":1}`)
+	result, err := h.handle(context.Background(), nil, &jsonrpc2.Request{Method: pingMethod, Params: &params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(result); string(got) != `{"token":1}` {
+		t.Errorf("got result %s, want the params", got)
+	}
+}
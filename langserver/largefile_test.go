@@ -0,0 +1,76 @@
+package langserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// largeFileFuncs is the number of functions of the file of the benchmarks,
+// about 25,000 lines.
+const largeFileFuncs = 5000
+
+// largeFile returns a file of n functions, each one calling the previous one
+// with a string of non-ASCII characters, so that the positions of the
+// requests at its end are converted to offsets through all its lines.
+func largeFile(n int) string {
+	var b strings.Builder
+	b.WriteString("package large\n\nfunc f0(s string) int { return len(s) }\n")
+	for i := 1; i < n; i++ {
+		fmt.Fprintf(&b, "\n// f%d calls f%d.\nfunc f%d(s string) int {\n\treturn f%d(\"héllo, 世界\" + s)\n}\n", i, i-1, i, i-1)
+	}
+	return b.String()
+}
+
+// benchmarkLargeFile calls method at the position of the last call of the
+// large file into result, once the file is open and its package is loaded,
+// and found reports whether the function is found.
+func benchmarkLargeFile(b *testing.B, method string, result interface{}, found func() bool) {
+	src := largeFile(largeFileFuncs)
+	root := writeWorkspace(b, map[string]string{
+		"go.mod":   "module example.com/large\n",
+		"large.go": src,
+	})
+	tx := newWorkspaceContext(b, testConfig(cache.Ondemand), root)
+	uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, "large.go")))
+	if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: src},
+	}); err != nil {
+		b.Fatal(err)
+	}
+
+	lines := strings.Split(src, "\n")
+	line := len(lines) - 3
+	params := lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: strings.Index(lines[line], "f")},
+	}
+	if err := tx.conn.Call(tx.ctx, method, params, result); err != nil {
+		b.Fatal(err)
+	}
+	if !found() {
+		b.Fatalf("%s found nothing at %d:%d", method, params.Position.Line, params.Position.Character)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tx.conn.Call(tx.ctx, method, params, result); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHoverLargeFile(b *testing.B) {
+	var hover lsp.Hover
+	benchmarkLargeFile(b, "textDocument/hover", &hover, func() bool { return len(hover.Contents) > 0 })
+}
+
+func BenchmarkDefinitionLargeFile(b *testing.B) {
+	var locations []lsp.Location
+	benchmarkLargeFile(b, "textDocument/definition", &locations, func() bool { return len(locations) > 0 })
+}
@@ -37,11 +37,11 @@ func (h *LangHandler) typeCheck(ctx context.Context, fileURI lsp.DocumentURI, po
 	}
 
 	if pkg == nil {
-		return nil, pos, fmt.Errorf("package for %s is null", fileURI)
+		return nil, pos, requestErrorf(PackageError, "package for %s is null", fileURI)
 	}
 
 	if pkg.IsIllTyped() {
-		return nil, pos, fmt.Errorf("package for %s is ill typed", fileURI)
+		return nil, pos, packageErrorf(PackageError, pkg.GetPkgPath(), "package for %s is ill typed", fileURI)
 	}
 
 	if f == nil {
@@ -67,7 +67,7 @@ func (h *LangHandler) getPosFromPkg(pkg source.Package, fileURI lsp.DocumentURI,
 
 	fToken := pkg.GetFileSet().File(fAST.Pos())
 	if fToken == nil {
-		return pos, fmt.Errorf("%s token file does not exist", fileURI)
+		return pos, packageErrorf(StaleError, pkg.GetPkgPath(), "%s token file does not exist", fileURI)
 	}
 
 	pos = fromProtocolPosition(fToken, position)
@@ -97,7 +97,7 @@ func (h *LangHandler) loadPackageAndAst(ctx context.Context, fileURI lsp.Documen
 func (h *LangHandler) getAstFromPkg(pkg source.Package, fileURI lsp.DocumentURI) (*ast.File, error) {
 	fAST := source.GetSyntaxFile(pkg, util.UriToRealPath(fileURI))
 	if fAST == nil {
-		return nil, fmt.Errorf("%s ast file does not exist", fileURI)
+		return nil, packageErrorf(StaleError, pkg.GetPkgPath(), "%s ast file does not exist", fileURI)
 	}
 
 	return fAST, nil
@@ -124,7 +124,7 @@ func doDefinitionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 	} else if strings.HasPrefix(want, gomodule) {
 		want = makePath(gomoduleDir, want[len(gomodule):])
 	} else if want != "" {
-		want = makePath(definitionContext.root(), want)
+		want = makePath(util.UriToRealPath(rootURI), want)
 	}
 
 	if definition != want {
@@ -142,7 +142,7 @@ func doTestDocumentSymbol(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, r
 	}
 
 	for i, s := range want {
-		want[i] = makePath(symbolContext.root(), s)
+		want[i] = makePath(util.UriToRealPath(rootURI), s)
 	}
 	if !reflect.DeepEqual(symbols, want) {
 		t.Errorf("got %q, want %q", symbols, want)
@@ -89,7 +89,7 @@ func doImplementationTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, r
 	sort.Strings(impls)
 
 	for i := range want {
-		want[i] = makePath(implementationContext.root(), want[i])
+		want[i] = makePath(util.UriToRealPath(rootURI), want[i])
 	}
 	sort.Strings(want)
 	if !reflect.DeepEqual(impls, want) {
@@ -110,7 +110,7 @@ func doReferencesTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 		if strings.HasPrefix(want[i], githubModule) {
 			want[i] = makePath(gopathDir, want[i])
 		} else {
-			want[i] = makePath(referencesContext.root(), want[i])
+			want[i] = makePath(util.UriToRealPath(rootURI), want[i])
 		}
 	}
 	sort.Strings(results)
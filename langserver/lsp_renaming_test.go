@@ -50,6 +50,29 @@ func TestRenaming(t *testing.T) {
 	})
 }
 
+// TestRenamingCacheStyles renames with every cache style, each in a server
+// of its own.
+func TestRenamingCacheStyles(t *testing.T) {
+	t.Parallel()
+
+	for _, style := range []cache.CacheStyle{cache.None, cache.Ondemand, cache.Always} {
+		style := style
+		t.Run(string(style), func(t *testing.T) {
+			t.Parallel()
+
+			tx := newIsolatedContext(t, testConfig(style))
+			dir, err := filepath.Abs(tx.root())
+			if err != nil {
+				t.Fatal(err)
+			}
+			doRenamingTest(t, tx.ctx, tx.conn, util.PathToURI(dir), "renaming/a.go:5:2", map[string]string{
+				"4:1-4:4":   "renaming/a.go",
+				"5:13-5:16": "renaming/a.go",
+			})
+		})
+	}
+}
+
 type renamingTestCase struct {
 	input  string
 	output map[string]string
@@ -84,7 +107,7 @@ func doRenamingTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI
 	}
 
 	for k := range want {
-		want[k] = makePath(renameContext.root(), want[k])
+		want[k] = makePath(util.UriToRealPath(rootURI), want[k])
 	}
 
 	if !reflect.DeepEqual(got, want) {
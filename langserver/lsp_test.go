@@ -6,17 +6,24 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"golang.org/x/tools/go/packages/packagestest"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/testutil"
 	"github.com/saibing/bingo/langserver/internal/util"
 
 	"github.com/sourcegraph/go-lsp"
@@ -54,41 +61,139 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// testContexts are the contexts shared by the tests of a feature, which are
+// torn down once all the tests have run.
+var testContexts struct {
+	mu       sync.Mutex
+	contexts []*TestContext
+}
+
 func tearDown() {
-	completionContext.tearDown()
-	definitionContext.tearDown()
-	symbolContext.tearDown()
-	formatContext.tearDown()
-	hoverContext.tearDown()
-	implementationContext.tearDown()
-	referencesContext.tearDown()
-	renameContext.tearDown()
-	signatureContext.tearDown()
-	typeDefinitionContext.tearDown()
-	workspaceReferencesContext.tearDown()
-	workspaceSymbolContext.tearDown()
-	xDefinitionContext.tearDown()
+	testContexts.mu.Lock()
+	defer testContexts.mu.Unlock()
+	for _, tx := range testContexts.contexts {
+		tx.tearDown()
+	}
 }
 
+// TestContext is a language server serving a copy of the test data of its
+// own, and the connection of its client.
 type TestContext struct {
 	h          jsonrpc2.Handler
 	conn       *jsonrpc2.Conn
 	connServer *jsonrpc2.Conn
+	client     *fakeClient
 	ctx        context.Context
 	exported   *packagestest.Exported
+
+	// dir is the workspace of the contexts which do not serve the test
+	// data, see newWorkspaceContext.
+	dir string
+
+	// capabilities are the capabilities of the client, if it does not
+	// have the default ones.
+	capabilities *ClientCapabilities
 }
 
-func newTestContext(style cache.CacheStyle) *TestContext {
+// testConfig returns the configuration of the tests with the cache style.
+func testConfig(style cache.CacheStyle) Config {
 	cfg := NewDefaultConfig()
 	cfg.DisableFuncSnippet = false
 	cfg.GlobalCacheStyle = string(style)
+	return cfg
+}
+
+// newTestContext returns the context shared by the tests of a feature, with
+// the configuration of the tests and the cache style. It is set up by the
+// tests, and torn down by TestMain.
+func newTestContext(style cache.CacheStyle) *TestContext {
+	tx := &TestContext{
+		h:   NewHandler(testConfig(style)),
+		ctx: context.Background(),
+	}
 
-	h := NewHandler(cfg)
-	ctx := context.Background()
-	return &TestContext{
-		h:   h,
-		ctx: ctx,
+	testContexts.mu.Lock()
+	testContexts.contexts = append(testContexts.contexts, tx)
+	testContexts.mu.Unlock()
+	return tx
+}
+
+// newIsolatedContext sets up a context of its own for the test t, served
+// with the configuration cfg, and tears it down at the end of t. It does not
+// share anything with the other contexts, so that the tests of a feature can
+// run in parallel with several configurations, e.g. every cache style.
+func newIsolatedContext(t *testing.T, cfg Config) *TestContext {
+	t.Helper()
+	tx := &TestContext{
+		h:   NewHandler(cfg),
+		ctx: context.Background(),
+	}
+	tx.setup(t)
+	t.Cleanup(tx.tearDown)
+	return tx
+}
+
+// newWorkspaceContext sets up a context of its own for the test t serving
+// the workspace dir with the configuration cfg, and tears it down at the end
+// of t.
+func newWorkspaceContext(t testing.TB, cfg Config, dir string) *TestContext {
+	t.Helper()
+	tx := &TestContext{
+		h:   NewHandler(cfg),
+		ctx: context.Background(),
+		dir: dir,
 	}
+	tx.initServer(t)
+	t.Cleanup(tx.tearDown)
+	return tx
+}
+
+// writeWorkspace writes files, relative paths to contents, into a temporary
+// workspace removed at the end of t, and returns its directory.
+func writeWorkspace(t testing.TB, files map[string]string) string {
+	t.Helper()
+	root, err := ioutil.TempDir("", "bingo-workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// checkedPackage is a source.Package of a single type-checked file.
+type checkedPackage struct {
+	source.Package
+	*testutil.Source
+}
+
+func (pkg *checkedPackage) GetFileSet() *token.FileSet { return pkg.Fset }
+func (pkg *checkedPackage) GetSyntax() []*ast.File     { return []*ast.File{pkg.File} }
+func (pkg *checkedPackage) GetTypes() *types.Package   { return pkg.Types }
+func (pkg *checkedPackage) GetTypesInfo() *types.Info  { return pkg.Info }
+
+// checkSource type-checks src as the package of a single file, see
+// testutil.CheckSource.
+func checkSource(t testing.TB, src string) *checkedPackage {
+	t.Helper()
+	return &checkedPackage{Source: testutil.CheckSource(t, src)}
+}
+
+// checkBrokenSource is checkSource for the sources with type errors.
+func checkBrokenSource(t testing.TB, src string) *checkedPackage {
+	t.Helper()
+	return &checkedPackage{Source: testutil.CheckBrokenSource(t, src)}
 }
 
 func (tx *TestContext) setup(t *testing.T) {
@@ -117,31 +222,39 @@ func (tx *TestContext) tearDown() {
 }
 
 func (tx *TestContext) root() string {
+	if tx.exported == nil {
+		return tx.dir
+	}
 	return tx.exported.Config.Dir
 }
 
-func (tx *TestContext) initServer(t *testing.T) {
+func (tx *TestContext) initServer(t testing.TB) {
 	t.Helper()
 	rootDir := tx.root()
-	os.Chdir(rootDir)
 	root := util.PathToURI(filepath.ToSlash(rootDir))
 	t.Log("rootUri:", root)
 
 	// Prepare the connection.
 	client, server := net.Pipe()
 	tx.connServer = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}), tx.h)
-	tx.conn = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), tx.h)
+	tx.client = newFakeClient()
+	tx.conn = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), tx.client)
 
 	tdCap := lsp.TextDocumentClientCapabilities{}
 	tdCap.Completion.CompletionItemKind.ValueSet = []lsp.CompletionItemKind{lsp.CIKConstant}
 	params := InitializeParams{
 		InitializeParams: lsp.InitializeParams{
-			RootURI:      root,
-			Capabilities: lsp.ClientCapabilities{TextDocument: tdCap},
+			RootURI: root,
+		},
+		Capabilities: ClientCapabilities{
+			TextDocument: TextDocumentClientCapabilities{TextDocumentClientCapabilities: tdCap},
 		},
 
 		RootImportPath: rootImportPath,
 	}
+	if tx.capabilities != nil {
+		params.Capabilities = *tx.capabilities
+	}
 	if err := tx.conn.Call(tx.ctx, "initialize", params, nil); err != nil {
 		t.Fatal("conn.Call initialize:", err)
 	}
@@ -69,7 +69,7 @@ func doTypeDefinitionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, r
 	}
 
 	if want != "" {
-		want = makePath(typeDefinitionContext.root(), want)
+		want = makePath(util.UriToRealPath(rootURI), want)
 	}
 	if definition != want {
 		t.Errorf("got %q, want %q", definition, want)
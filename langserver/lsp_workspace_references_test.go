@@ -175,7 +175,7 @@ func doWorkspaceReferencesTest(t testing.TB, ctx context.Context, c *jsonrpc2.Co
 			splits := strings.Split(want[i], "/")
 			pkgDir = splits[0]
 		}
-		want[i] = makePath(workspaceReferencesContext.root(), want[i])
+		want[i] = makePath(util.UriToRealPath(rootURI), want[i])
 	}
 
 	rootDir := util.UriToRealPath(rootURI)
@@ -98,7 +98,7 @@ func doXDefinitionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, root
 	} else if strings.HasPrefix(want, gomodule) {
 		want = makePath(gomoduleDir, want[len(gomodule):])
 	} else if want != "" {
-		want = makePath(xDefinitionContext.root(), want)
+		want = makePath(util.UriToRealPath(rootURI), want)
 	}
 
 	if xdefinition != want {
@@ -0,0 +1,117 @@
+package langserver
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// maxMemoEntries bounds the number of memoized results.
+const maxMemoEntries = 1024
+
+// memoizedMethods are the position based queries whose result only depends
+// on the content of the workspace.
+var memoizedMethods = map[string]bool{
+	"textDocument/hover":          true,
+	"textDocument/definition":     true,
+	"textDocument/typeDefinition": true,
+	"textDocument/xdefinition":    true,
+}
+
+type memoKey struct {
+	method   string
+	uri      lsp.DocumentURI
+	version  int
+	position lsp.Position
+}
+
+type memoEntry struct {
+	result     interface{}
+	cache      *cache.GlobalCache
+	generation uint64 // of the view of the project
+}
+
+// memo caches the results of position based queries, so that repeated hovers
+// over the same identifier return instantly. It is invalidated whenever a
+// document changes, including the documents the other clients sharing the
+// project edit, the packages of the project are invalidated, e.g. by a change
+// of the files on disk or of the build flags, or the global cache is rebuilt.
+type memo struct {
+	mu         sync.Mutex
+	generation int
+	entries    map[memoKey]memoEntry
+}
+
+func newMemo() *memo {
+	return &memo{entries: make(map[memoKey]memoEntry)}
+}
+
+// invalidate drops every memoized result.
+func (m *memo) invalidate() {
+	m.mu.Lock()
+	m.generation++
+	m.entries = make(map[memoKey]memoEntry)
+	m.mu.Unlock()
+}
+
+// memoize returns the memoized result of req, or calls fn and memoizes its
+// result.
+func (h *LangHandler) memoize(req *jsonrpc2.Request, fn func() (interface{}, error)) (interface{}, error) {
+	if isFileSystemRequest(req.Method) {
+		h.memo.invalidate()
+		return fn()
+	}
+
+	if !memoizedMethods[req.Method] || req.Params == nil {
+		return fn()
+	}
+
+	h.mu.Lock()
+	overlay, project := h.overlay, h.project
+	h.mu.Unlock()
+	if overlay == nil || project == nil {
+		return fn()
+	}
+
+	var params lsp.TextDocumentPositionParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return fn()
+	}
+
+	key := memoKey{
+		method:   req.Method,
+		uri:      params.TextDocument.URI,
+		version:  overlay.version(params.TextDocument.URI),
+		position: params.Position,
+	}
+	gcache := project.Cache()
+	viewGeneration := project.Generation()
+
+	m := h.memo
+	m.mu.Lock()
+	e, ok := m.entries[key]
+	generation := m.generation
+	m.mu.Unlock()
+	if ok && e.cache == gcache && e.generation == viewGeneration {
+		return e.result, nil
+	}
+
+	result, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	// Don't memoize a result computed while a document changed.
+	if m.generation == generation && project.Generation() == viewGeneration {
+		if len(m.entries) >= maxMemoEntries {
+			m.entries = make(map[memoKey]memoEntry)
+		}
+		m.entries[key] = memoEntry{result: result, cache: gcache, generation: viewGeneration}
+	}
+	m.mu.Unlock()
+	return result, nil
+}
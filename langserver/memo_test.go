@@ -0,0 +1,49 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoize(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	project := cache.NewProject(context.Background(), nil, "/work", nil, nil)
+	h := &LangHandler{HandlerShared: &HandlerShared{overlay: &overlay{project: project}}, memo: newMemo(), project: project}
+	params, err := json.Marshal(lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: "file:///work/a.go"}, Position: lsp.Position{Line: 2, Character: 4}})
+	require.NoError(err)
+	raw := json.RawMessage(params)
+	req := &jsonrpc2.Request{Method: "textDocument/hover", Params: &raw}
+
+	calls := 0
+	hover := func() interface{} {
+		result, err := h.memoize(req, func() (interface{}, error) {
+			calls++
+			return calls, nil
+		})
+		require.NoError(err)
+		return result
+	}
+	require.Equal(1, hover())
+	require.Equal(1, hover(), "the hover was not memoized")
+
+	// Another client sharing the project edits a document.
+	project.Overlay().Open(span.FileURI("/work/b.go"), 1, []byte("package p\n"))
+	require.Equal(2, hover(), "the hover was not invalidated by the edit of the other client")
+
+	// The files changed on disk.
+	project.View().(*cache.View).Invalidate()
+	require.Equal(3, hover(), "the hover was not invalidated with the view")
+
+	project.SetBuildFlags([]string{"-tags", "integration"})
+	require.Equal(4, hover(), "the hover was not invalidated by the build flags")
+	require.Equal(4, hover())
+}
@@ -0,0 +1,66 @@
+package langserver
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// MemoryUsage is the result of the bingo/memoryUsage request: the estimated
+// memory of the packages of the global cache, and the heap of the server.
+type MemoryUsage struct {
+	cache.MemoryUsage
+
+	// HeapAlloc and HeapSys are the bytes of the allocated heap objects and
+	// of the heap obtained from the system, see runtime.MemStats.
+	HeapAlloc uint64 `json:"heapAlloc"`
+	HeapSys   uint64 `json:"heapSys"`
+}
+
+func (h *LangHandler) handleMemoryUsage(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) (*MemoryUsage, error) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return &MemoryUsage{
+		MemoryUsage: h.project.MemoryUsage(),
+		HeapAlloc:   stats.HeapAlloc,
+		HeapSys:     stats.HeapSys,
+	}, nil
+}
+
+// memoryLimitOverhead is the memory, in megabytes, of the server besides the
+// packages of the global cache bounded by Config.GlobalCacheMemory: the
+// packages of the workspace, the documents and the requests in progress.
+const memoryLimitOverhead = 1024
+
+// memoryLimit returns the soft memory limit, in bytes, of c: MemoryLimit, or
+// else twice GlobalCacheMemory and memoryLimitOverhead, as the budget of the
+// global cache is an estimate. It returns 0 for no limit.
+func (c *Config) memoryLimit() int64 {
+	switch {
+	case c.MemoryLimit > 0:
+		return int64(c.MemoryLimit) << 20
+	case c.GlobalCacheMemory > 0:
+		return int64(2*c.GlobalCacheMemory+memoryLimitOverhead) << 20
+	}
+	return 0
+}
+
+// TuneGC sets the garbage collection target percentage and the soft memory
+// limit of the runtime from c, unless GOGC and GOMEMLIMIT set them. Once the
+// heap nears the limit, the garbage collector runs more often rather than
+// growing the heap, which the runtime is slow to return to the system.
+//
+// The settings are process-wide: TuneGC is called once, with the default
+// config of the server, rather than with the config of every client.
+func TuneGC(c Config) {
+	if c.GCPercent != 0 && os.Getenv("GOGC") == "" {
+		debug.SetGCPercent(c.GCPercent)
+	}
+	if limit := c.memoryLimit(); limit > 0 && os.Getenv("GOMEMLIMIT") == "" {
+		debug.SetMemoryLimit(limit)
+	}
+}
@@ -0,0 +1,20 @@
+package langserver
+
+import "testing"
+
+func TestMemoryLimit(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   int64
+	}{
+		{Config{}, 0},
+		{Config{MemoryLimit: 512}, 512 << 20},
+		{Config{MemoryLimit: 512, GlobalCacheMemory: 4096}, 512 << 20},
+		{Config{GlobalCacheMemory: 2048}, (2*2048 + memoryLimitOverhead) << 20},
+	}
+	for _, test := range tests {
+		if got := test.config.memoryLimit(); got != test.want {
+			t.Errorf("memoryLimit of memory limit %d and cache memory %d: got %d, want %d", test.config.MemoryLimit, test.config.GlobalCacheMemory, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// MetricsParams is the parameter of the bingo/metrics request.
+type MetricsParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+
+	// Package asks for the metrics of every file in the package of
+	// TextDocument, instead of TextDocument only.
+	Package bool `json:"package,omitempty"`
+}
+
+// FunctionMetrics holds the code metrics of a function or method.
+type FunctionMetrics struct {
+	Name       string       `json:"name"`
+	Location   lsp.Location `json:"location"`
+	Complexity int          `json:"complexity"` // cyclomatic complexity
+	Lines      int          `json:"lines"`
+	Parameters int          `json:"parameters"`
+}
+
+func (h *LangHandler) handleMetrics(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params MetricsParams) ([]FunctionMetrics, error) {
+	pkg, fAST, err := h.loadPackageAndAst(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*ast.File{fAST}
+	if params.Package {
+		files = pkg.GetSyntax()
+	}
+
+	metrics := []FunctionMetrics{}
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		metrics = append(metrics, fileMetrics(pkg, f)...)
+	}
+
+	sort.SliceStable(metrics, func(i, j int) bool {
+		if metrics[i].Location.URI != metrics[j].Location.URI {
+			return metrics[i].Location.URI < metrics[j].Location.URI
+		}
+		return metrics[i].Location.Range.Start.Line < metrics[j].Location.Range.Start.Line
+	})
+	return metrics, nil
+}
+
+func fileMetrics(pkg source.Package, f *ast.File) []FunctionMetrics {
+	fset := pkg.GetFileSet()
+
+	var metrics []FunctionMetrics
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		name := fn.Name.Name
+		if fn.Recv != nil && len(fn.Recv.List) > 0 {
+			name = "(" + recvString(fn.Recv.List[0].Type) + ")." + name
+		}
+
+		metrics = append(metrics, FunctionMetrics{
+			Name:       name,
+			Location:   createLocationFromRange(fset, fn.Pos(), fn.End()),
+			Complexity: cyclomaticComplexity(fn),
+			Lines:      fset.Position(fn.End()).Line - fset.Position(fn.Pos()).Line + 1,
+			Parameters: fn.Type.Params.NumFields(),
+		})
+	}
+	return metrics
+}
+
+// cyclomaticComplexity counts the number of independent paths through fn,
+// that is one plus the number of branch points. The branch points of the
+// function literals of fn are theirs, not the ones of fn.
+func cyclomaticComplexity(fn *ast.FuncDecl) int {
+	complexity := 1
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			complexity++
+		case *ast.CaseClause:
+			if n.List != nil {
+				complexity++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				complexity++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
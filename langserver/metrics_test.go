@@ -0,0 +1,61 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestCyclomaticComplexity(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func straight() {}
+
+func branches(a, b bool, xs []int) int {
+	if a && b {
+		return 1
+	}
+	for _, x := range xs {
+		switch x {
+		case 1, 2:
+			return x
+		default:
+		}
+	}
+	return 0
+}
+
+func literal(xs []int) func() bool {
+	if len(xs) == 0 {
+		return nil
+	}
+	return func() bool {
+		for range xs {
+			if xs[0] > 0 || xs[1] > 0 {
+				return true
+			}
+		}
+		return false
+	}
+}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{
+		"straight": 1,
+		"branches": 5,
+		"literal":  2,
+	}
+	for _, decl := range f.Decls {
+		fn := decl.(*ast.FuncDecl)
+		if got := cyclomaticComplexity(fn); got != want[fn.Name.Name] {
+			t.Errorf("cyclomaticComplexity(%s) = %d, want %d", fn.Name.Name, got, want[fn.Name.Name])
+		}
+	}
+}
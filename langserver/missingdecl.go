@@ -0,0 +1,504 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// The messages of the type errors of the undefined functions and methods, of
+// the older and the newer versions of go/types.
+var (
+	undefinedNameRegexp   = regexp.MustCompile(`^(?:undeclared name|undefined): (\w+)$`)
+	undefinedMethodRegexp = regexp.MustCompile(`undefined \(type .+ has no field or method (\w+)`)
+)
+
+// missingFunc is a function or a method to declare, inferred from a call.
+type missingFunc struct {
+	name string
+
+	// recv is the named type of the method, or nil for a function.
+	recv *types.TypeName
+
+	params  []*types.Var
+	results []types.Type
+}
+
+// missingDeclActions returns the actions which declare the functions and
+// the methods called but undefined, reported by diagnostics.
+func (h *LangHandler) missingDeclActions(ctx context.Context, uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) []protocol.CodeAction {
+	var actions []protocol.CodeAction
+	for _, d := range diagnostics {
+		if d.Code != typeErrorCode || !undefinedNameRegexp.MatchString(d.Message) && !undefinedMethodRegexp.MatchString(d.Message) {
+			continue
+		}
+		pkg, pos, err := h.typeCheck(ctx, uri, d.Range.Start)
+		if err != nil {
+			continue
+		}
+		pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+		if err != nil {
+			continue
+		}
+		fn, ok := newMissingFunc(pkg.GetTypesInfo(), pathNodes)
+		if !ok {
+			continue
+		}
+		edit, err := h.missingDeclEdit(pkg, pathNodes, fn)
+		if err != nil {
+			continue
+		}
+
+		title := "Create function " + fn.name
+		if fn.recv != nil {
+			title = "Create method " + fn.recv.Name() + "." + fn.name
+		}
+		actions = append(actions, protocol.CodeAction{
+			Title:       title,
+			Kind:        protocol.QuickFix,
+			Diagnostics: []lsp.Diagnostic{d},
+			Edit:        edit,
+		})
+	}
+	return actions
+}
+
+// newMissingFunc returns the function called by the identifier path[0],
+// whose declaration is missing.
+func newMissingFunc(info *types.Info, path []ast.Node) (*missingFunc, bool) {
+	ident, ok := path[0].(*ast.Ident)
+	if !ok || len(path) < 2 {
+		return nil, false
+	}
+	fn := &missingFunc{name: ident.Name}
+
+	// The callee is either the identifier or the selector of the method.
+	var callee ast.Expr = ident
+	i := 1
+	if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == ident {
+		t := info.TypeOf(sel.X)
+		if t == nil {
+			return nil, false
+		}
+		named, ok := source.Deref(t).(*types.Named)
+		if !ok || types.IsInterface(named) || named.Obj().Pkg() == nil {
+			return nil, false
+		}
+		fn.recv = named.Obj()
+		callee = sel
+		i = 2
+	}
+	if i >= len(path) {
+		return nil, false
+	}
+	call, ok := path[i].(*ast.CallExpr)
+	if !ok || call.Fun != callee {
+		return nil, false
+	}
+
+	names := map[string]int{}
+	for j, arg := range call.Args {
+		t := missingType(info.TypeOf(arg))
+		name := argName(arg, t, j)
+		if n := names[name]; n > 0 {
+			name += strconv.Itoa(n + 1)
+		}
+		names[name]++
+		fn.params = append(fn.params, types.NewVar(token.NoPos, nil, name, t))
+	}
+	fn.results = callResults(info, path[i+1:], call)
+	return fn, true
+}
+
+// missingType returns the type of a parameter or a result of type t: the
+// default type of the untyped constants, and interface{} if t is unknown.
+func missingType(t types.Type) types.Type {
+	if t == nil || t == types.Typ[types.Invalid] {
+		return types.NewInterfaceType(nil, nil)
+	}
+	if b, ok := t.(*types.Basic); ok && b.Kind() == types.UntypedNil {
+		return types.NewInterfaceType(nil, nil)
+	}
+	if tuple, ok := t.(*types.Tuple); ok {
+		if tuple.Len() != 1 {
+			return types.NewInterfaceType(nil, nil)
+		}
+		t = tuple.At(0).Type()
+	}
+	return types.Default(t)
+}
+
+// argName returns the name of the parameter of the argument arg of type t,
+// the i-th one.
+func argName(arg ast.Expr, t types.Type, i int) string {
+	var name string
+	switch arg := arg.(type) {
+	case *ast.Ident:
+		name = arg.Name
+	case *ast.SelectorExpr:
+		name = arg.Sel.Name
+	case *ast.UnaryExpr:
+		if id, ok := arg.X.(*ast.Ident); ok && arg.Op == token.AND {
+			name = id.Name
+		}
+	}
+	if name == "" || name == "_" || name == "nil" || name == "true" || name == "false" {
+		if named, ok := source.Deref(t).(*types.Named); ok {
+			name = named.Obj().Name()
+		} else {
+			name = "arg" + strconv.Itoa(i)
+		}
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	name = string(unicode.ToLower(r)) + name[size:]
+	if token.Lookup(name).IsKeyword() {
+		name += "_"
+	}
+	return name
+}
+
+// callResults returns the result types of call, inferred from its parents.
+func callResults(info *types.Info, parents []ast.Node, call *ast.CallExpr) []types.Type {
+	if len(parents) == 0 {
+		return nil
+	}
+	switch parent := parents[0].(type) {
+	case *ast.ExprStmt, *ast.GoStmt, *ast.DeferStmt:
+		return nil
+
+	case *ast.AssignStmt:
+		if len(parent.Rhs) != 1 {
+			return []types.Type{missingType(info.TypeOf(call))}
+		}
+		var results []types.Type
+		for _, lhs := range parent.Lhs {
+			t := missingType(info.TypeOf(lhs))
+			// The variables defined by the assignment have no type.
+			if id, ok := lhs.(*ast.Ident); ok && id.Name == "err" && types.IsInterface(t) {
+				t = types.Universe.Lookup("error").Type()
+			}
+			results = append(results, t)
+		}
+		return results
+
+	case *ast.ValueSpec:
+		t := missingType(nil)
+		if parent.Type != nil {
+			t = missingType(info.TypeOf(parent.Type))
+		}
+		if len(parent.Values) != 1 {
+			return []types.Type{t}
+		}
+		var results []types.Type
+		for range parent.Names {
+			results = append(results, t)
+		}
+		return results
+
+	case *ast.ReturnStmt:
+		sig := enclosingSignature(info, parents[1:])
+		if sig == nil {
+			return []types.Type{missingType(nil)}
+		}
+		if len(parent.Results) == 1 {
+			var results []types.Type
+			for i := 0; i < sig.Results().Len(); i++ {
+				results = append(results, sig.Results().At(i).Type())
+			}
+			return results
+		}
+		for i, r := range parent.Results {
+			if r == call && i < sig.Results().Len() {
+				return []types.Type{sig.Results().At(i).Type()}
+			}
+		}
+
+	case *ast.IfStmt, *ast.ForStmt:
+		return []types.Type{types.Typ[types.Bool]}
+
+	case *ast.UnaryExpr:
+		if parent.Op == token.NOT {
+			return []types.Type{types.Typ[types.Bool]}
+		}
+
+	case *ast.BinaryExpr:
+		switch parent.Op {
+		case token.LAND, token.LOR:
+			return []types.Type{types.Typ[types.Bool]}
+		}
+		other := parent.X
+		if other == call {
+			other = parent.Y
+		}
+		return []types.Type{missingType(info.TypeOf(other))}
+
+	case *ast.CallExpr:
+		sig, ok := info.TypeOf(parent.Fun).(*types.Signature)
+		if !ok {
+			break
+		}
+		for i, arg := range parent.Args {
+			if arg != call {
+				continue
+			}
+			if sig.Variadic() && i >= sig.Params().Len()-1 {
+				if slice, ok := sig.Params().At(sig.Params().Len() - 1).Type().(*types.Slice); ok && parent.Ellipsis == token.NoPos {
+					return []types.Type{slice.Elem()}
+				}
+				break
+			}
+			if i < sig.Params().Len() {
+				return []types.Type{sig.Params().At(i).Type()}
+			}
+		}
+	}
+	return []types.Type{missingType(info.TypeOf(call))}
+}
+
+// enclosingSignature returns the signature of the innermost function of
+// parents.
+func enclosingSignature(info *types.Info, parents []ast.Node) *types.Signature {
+	for _, n := range parents {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			sig, _ := info.TypeOf(n).(*types.Signature)
+			return sig
+		case *ast.FuncDecl:
+			if obj, ok := info.Defs[n.Name].(*types.Func); ok {
+				return obj.Type().(*types.Signature)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// source returns the declaration of fn, whose imported packages are named by
+// qualifier, and whose receiver is recvName, a pointer if pointer is set.
+func (fn *missingFunc) source(qualifier types.Qualifier, recvName string, pointer bool) string {
+	var buf bytes.Buffer
+	buf.WriteString("func ")
+	if fn.recv != nil {
+		star := ""
+		if pointer {
+			star = "*"
+		}
+		fmt.Fprintf(&buf, "(%s %s%s) ", recvName, star, fn.recv.Name())
+	}
+	buf.WriteString(fn.name + "(")
+	for i, p := range fn.params {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		name := p.Name()
+		if fn.recv != nil && name == recvName {
+			name += "2"
+		}
+		buf.WriteString(name + " " + types.TypeString(p.Type(), qualifier))
+	}
+	buf.WriteString(")")
+	switch len(fn.results) {
+	case 0:
+	case 1:
+		buf.WriteString(" " + types.TypeString(fn.results[0], qualifier))
+	default:
+		var results []string
+		for _, r := range fn.results {
+			results = append(results, types.TypeString(r, qualifier))
+		}
+		buf.WriteString(" (" + strings.Join(results, ", ") + ")")
+	}
+	buf.WriteString(" {\n\tpanic(\"not implemented\")\n}")
+	return buf.String()
+}
+
+// missingDeclEdit returns the edit which declares fn: a function after the
+// declaration of the call, or a method after the type declaration and the
+// methods of its receiver.
+func (h *LangHandler) missingDeclEdit(pkg source.Package, pathNodes []ast.Node, fn *missingFunc) (lsp.WorkspaceEdit, error) {
+	target := pkg
+	var file *ast.File
+	var after ast.Node
+	if fn.recv == nil {
+		file, _ = pathNodes[len(pathNodes)-1].(*ast.File)
+		if file == nil || len(pathNodes) < 2 {
+			return lsp.WorkspaceEdit{}, fmt.Errorf("no file declaring %s", fn.name)
+		}
+		after = pathNodes[len(pathNodes)-2]
+	} else {
+		if fn.recv.Pkg() != pkg.GetTypes() {
+			target = pkg.GetImport(fn.recv.Pkg().Path())
+			if target == nil || target.GetTypesInfo() == nil {
+				return lsp.WorkspaceEdit{}, packageErrorf(StaleError, fn.recv.Pkg().Path(), "package %s is not loaded", fn.recv.Pkg().Path())
+			}
+		}
+		file, after = methodPlace(target, fn.recv)
+		if file == nil {
+			return lsp.WorkspaceEdit{}, fmt.Errorf("no declaration of %s", fn.recv.Name())
+		}
+	}
+
+	fset := target.GetFileSet()
+	filename := fset.Position(file.Pos()).Filename
+	uri := lsp.DocumentURI(source.ToURI(filename))
+	if !h.project.Contain(uri) {
+		return lsp.WorkspaceEdit{}, requestErrorf(RefusedError, "%s is not in the workspace", filename)
+	}
+
+	imp := newFileImporter(target.GetPkgPath(), file)
+	recvName, pointer := receiverOf(target, fn.recv)
+	text := fn.source(imp.qualifier, recvName, pointer)
+
+	edits := fileEdits{}
+	imp.addImports(edits, fset)
+	edits.replace(fset, after.End(), after.End(), "\n\n"+text)
+	return lsp.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{string(uri): edits[filename]}}, nil
+}
+
+// fileImporter qualifies the types written in a file, importing the packages
+// the file does not import yet.
+type fileImporter struct {
+	file *ast.File
+	// path is the import path of the package of the file.
+	path    string
+	imports map[string]string
+	missing []string
+}
+
+func newFileImporter(path string, f *ast.File) *fileImporter {
+	return &fileImporter{file: f, path: path, imports: fileImports(f)}
+}
+
+func (imp *fileImporter) qualifier(p *types.Package) string {
+	if p.Path() == imp.path {
+		return ""
+	}
+	if name, ok := imp.imports[p.Path()]; ok {
+		return name
+	}
+	imp.imports[p.Path()] = p.Name()
+	imp.missing = append(imp.missing, p.Path())
+	return p.Name()
+}
+
+// addImports adds to edits the import declarations of the missing packages,
+// after the package clause.
+func (imp *fileImporter) addImports(edits fileEdits, fset *token.FileSet) {
+	if len(imp.missing) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	for _, path := range imp.missing {
+		fmt.Fprintf(&buf, "\n\nimport %q", path)
+	}
+	edits.replace(fset, imp.file.Name.End(), imp.file.Name.End(), buf.String())
+}
+
+// fileEdits are the text edits of a refactoring, by file name.
+type fileEdits map[string][]lsp.TextEdit
+
+// replace adds the edit replacing the text from pos to end.
+func (e fileEdits) replace(fset *token.FileSet, pos, end token.Pos, text string) {
+	filename := fset.Position(pos).Filename
+	e[filename] = append(e[filename], lsp.TextEdit{Range: rangeForNode(fset, fakeNode{pos, end}), NewText: text})
+}
+
+// fileImports returns the names of the packages imported by f, by path.
+func fileImports(f *ast.File) map[string]string {
+	imports := map[string]string{}
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[path] = name
+	}
+	return imports
+}
+
+// methodPlace returns the file of pkg declaring the type obj, and the last
+// declaration of the file among the type and its methods.
+func methodPlace(pkg source.Package, obj *types.TypeName) (*ast.File, ast.Node) {
+	info := pkg.GetTypesInfo()
+	for _, f := range pkg.GetSyntax() {
+		var after ast.Node
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.TypeSpec); ok && info.Defs[spec.Name] == obj {
+						after = decl
+					}
+				}
+			case *ast.FuncDecl:
+				if after != nil && decl.Recv != nil && len(decl.Recv.List) == 1 && receiverType(info, decl) == obj {
+					after = decl
+				}
+			}
+		}
+		if after != nil {
+			return f, after
+		}
+	}
+	return nil, nil
+}
+
+// receiverType returns the named type of the receiver of the method decl.
+func receiverType(info *types.Info, decl *ast.FuncDecl) *types.TypeName {
+	t := info.TypeOf(decl.Recv.List[0].Type)
+	if t == nil {
+		return nil
+	}
+	if named, ok := source.Deref(t).(*types.Named); ok {
+		return named.Obj()
+	}
+	return nil
+}
+
+// receiverOf returns the name of the receiver of the methods of obj, and
+// whether it is a pointer, following the existing methods.
+func receiverOf(pkg source.Package, obj *types.TypeName) (string, bool) {
+	if obj == nil {
+		return "", false
+	}
+	info := pkg.GetTypesInfo()
+	for _, f := range pkg.GetSyntax() {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Recv == nil || len(decl.Recv.List) != 1 || receiverType(info, decl) != obj {
+				continue
+			}
+			field := decl.Recv.List[0]
+			_, pointer := field.Type.(*ast.StarExpr)
+			if len(field.Names) == 1 && field.Names[0].Name != "_" {
+				return field.Names[0].Name, pointer
+			}
+			return receiverName(obj), pointer
+		}
+	}
+	_, isStruct := obj.Type().Underlying().(*types.Struct)
+	return receiverName(obj), isStruct
+}
+
+// receiverName returns the default name of the receiver of the methods of
+// obj: its lowercase initial.
+func receiverName(obj *types.TypeName) string {
+	r, _ := utf8.DecodeRuneInString(obj.Name())
+	return string(unicode.ToLower(r))
+}
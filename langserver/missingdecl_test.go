@@ -0,0 +1,55 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func TestMissingFunc(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	checked := checkBrokenSource(t, `package p
+
+import "strings"
+
+type store struct{}
+
+func (s *store) Get(k string) string { return k }
+
+func f(s *store, r *strings.Reader) (int, error) {
+	n := 3
+	total, err := compute(n, "x", r)
+	_, _ = total, err
+	s.Put(n, 1.5)
+	if valid(s) {
+	}
+	return parse(r.Len())
+}
+`)
+	f, info, pkg := checked.File, checked.Info, checked.Types
+
+	declare := func(name string) string {
+		var ident *ast.Ident
+		ast.Inspect(f, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name {
+				ident = id
+			}
+			return ident == nil
+		})
+		require.NotNil(ident, name)
+		path, _ := astutil.PathEnclosingInterval(f, ident.Pos(), ident.End())
+		fn, ok := newMissingFunc(info, path)
+		require.True(ok, name)
+		return fn.source(types.RelativeTo(pkg), "s", true)
+	}
+
+	require.Equal("func compute(n int, arg1 string, r *strings.Reader) (interface{}, error) {\n\tpanic(\"not implemented\")\n}", declare("compute"))
+	require.Equal("func (s *store) Put(n int, arg1 float64) {\n\tpanic(\"not implemented\")\n}", declare("Put"))
+	require.Equal("func valid(s *store) bool {\n\tpanic(\"not implemented\")\n}", declare("valid"))
+	require.Equal("func parse(arg0 int) (int, error) {\n\tpanic(\"not implemented\")\n}", declare("parse"))
+}
@@ -0,0 +1,282 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// mockCommand is the workspace/executeCommand command which generates a mock
+// implementation of an interface.
+const mockCommand = "bingo.mock"
+
+// The backends of the mock generation.
+const (
+	builtinMockBackend = "builtin"
+	mockgenMockBackend = "mockgen"
+	moqMockBackend     = "moq"
+)
+
+// MockParams is the argument of the bingo.mock command. The position selects
+// the interface.
+type MockParams struct {
+	lsp.TextDocumentPositionParams
+}
+
+func (h *LangHandler) handleMock(ctx context.Context, conn jsonrpc2.JSONRPC2, params MockParams) (*protocol.WorkspaceEdit, error) {
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		return nil, err
+	}
+	obj := interfaceAt(pkg, pos)
+	if obj == nil {
+		return nil, requestErrorf(InvalidPositionError, "not an interface")
+	}
+
+	declFile := pkg.GetFileSet().Position(obj.Pos()).Filename
+	dir := filepath.Dir(declFile)
+	filename := filepath.Join(dir, strings.ToLower(obj.Name())+"_mock.go")
+
+	var src []byte
+	switch h.config.MockBackend {
+	case "", builtinMockBackend:
+		src, err = generateMock(obj)
+	case mockgenMockBackend:
+		// The source mode of mockgen does not build the package, but it
+		// mocks every interface of the file.
+		filename = strings.TrimSuffix(declFile, ".go") + "_mock.go"
+		src, err = h.runMockGenerator(ctx, dir, "mockgen",
+			"-source="+filepath.Base(declFile), "-package="+pkg.GetName(), "-self_package="+pkg.GetPkgPath())
+	case moqMockBackend:
+		src, err = h.runMockGenerator(ctx, dir, "moq", "-pkg", pkg.GetName(), ".", obj.Name())
+	default:
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("unknown mock backend: %s", h.config.MockBackend))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	edit := createFileEdit(util.PathToURI(filename), string(src))
+	if err := applyEdit(ctx, conn, "generate mock of "+obj.Name(), edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// mockActions returns the action which generates a mock of the interface
+// at the start of rng.
+func (h *LangHandler) mockActions(ctx context.Context, uri lsp.DocumentURI, rng lsp.Range) []protocol.CodeAction {
+	pkg, pos, err := h.typeCheck(ctx, uri, rng.Start)
+	if err != nil {
+		return nil
+	}
+	obj := interfaceAt(pkg, pos)
+	if obj == nil {
+		return nil
+	}
+
+	title := "Generate mock of " + obj.Name()
+	return []protocol.CodeAction{{
+		Title: title,
+		Kind:  protocol.Refactor,
+		Command: protocol.Command{
+			Title:   title,
+			Command: mockCommand,
+			Arguments: []interface{}{MockParams{lsp.TextDocumentPositionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Position:     rng.Start,
+			}}},
+		},
+	}}
+}
+
+// interfaceAt returns the interface type of pkg named or declared at pos.
+func interfaceAt(pkg source.Package, pos token.Pos) *types.TypeName {
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil
+	}
+
+	for _, n := range pathNodes {
+		var obj types.Object
+		switch n := n.(type) {
+		case *ast.Ident:
+			obj = pkg.GetTypesInfo().ObjectOf(n)
+		case *ast.TypeSpec:
+			obj = pkg.GetTypesInfo().Defs[n.Name]
+		}
+		if typeName, ok := obj.(*types.TypeName); ok && typeName.Pkg() == pkg.GetTypes() && types.IsInterface(typeName.Type()) {
+			return typeName
+		}
+	}
+	return nil
+}
+
+// runMockGenerator runs the mock generator name in dir and returns its
+// output.
+func (h *LangHandler) runMockGenerator(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = h.commandEnv(nil)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	h.auditExec(cmd, err)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// mockImports names the packages imported by a mock, renaming those whose
+// name is already taken.
+type mockImports struct {
+	pkg   *types.Package
+	names map[string]string // path to name
+	taken map[string]bool
+}
+
+func (imports *mockImports) qualifier(p *types.Package) string {
+	if p == imports.pkg {
+		return ""
+	}
+	if name, ok := imports.names[p.Path()]; ok {
+		return name
+	}
+	name := p.Name()
+	for i := 2; imports.taken[name]; i++ {
+		name = p.Name() + strconv.Itoa(i)
+	}
+	imports.names[p.Path()] = name
+	imports.taken[name] = true
+	return name
+}
+
+func (imports *mockImports) write(buf *bytes.Buffer) {
+	if len(imports.names) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(imports.names))
+	for path := range imports.names {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	buf.WriteString("import (\n")
+	for _, path := range paths {
+		name := imports.names[path]
+		if name == filepath.Base(path) {
+			fmt.Fprintf(buf, "%q\n", path)
+		} else {
+			fmt.Fprintf(buf, "%s %q\n", name, path)
+		}
+	}
+	buf.WriteString(")\n\n")
+}
+
+// generateMock returns the source of the built-in mock of the interface obj:
+// a struct with a function field per method, in the package of obj.
+func generateMock(obj *types.TypeName) ([]byte, error) {
+	iface := obj.Type().Underlying().(*types.Interface)
+	if iface.NumMethods() == 0 {
+		return nil, requestErrorf(RefusedError, "%s has no method", obj.Name())
+	}
+	mock := mockName(obj)
+
+	// The receiver and the imported packages must not be shadowed by the
+	// parameters.
+	imports := &mockImports{pkg: obj.Pkg(), names: map[string]string{}, taken: map[string]bool{"m": true}}
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "// %s is a mock of %s.\ntype %s struct {\n", mock, obj.Name(), mock)
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		fmt.Fprintf(&body, "%sFunc %s\n", m.Name(), types.TypeString(m.Type(), imports.qualifier))
+	}
+	body.WriteString("}\n")
+
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		sig := m.Type().(*types.Signature)
+		params, args := mockParams(sig, imports)
+		fmt.Fprintf(&body, "\n// %s calls %sFunc.\nfunc (m *%s) %s(%s) %s {\n", m.Name(), m.Name(), mock, m.Name(), strings.Join(params, ", "), mockResults(sig, imports))
+		fmt.Fprintf(&body, "if m.%sFunc == nil {\npanic(%q)\n}\n", m.Name(), mock+"."+m.Name()+" is not set")
+		call := fmt.Sprintf("m.%sFunc(%s)", m.Name(), strings.Join(args, ", "))
+		if sig.Results().Len() > 0 {
+			fmt.Fprintf(&body, "return %s\n}\n", call)
+		} else {
+			fmt.Fprintf(&body, "%s\n}\n", call)
+		}
+	}
+	fmt.Fprintf(&body, "\nvar _ %s = (*%s)(nil)\n", obj.Name(), mock)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by \"bingo.mock -type=%s\"; DO NOT EDIT.\n\n", obj.Name())
+	fmt.Fprintf(&buf, "package %s\n\n", obj.Pkg().Name())
+	imports.write(&buf)
+	buf.Write(body.Bytes())
+	return format.Source(buf.Bytes())
+}
+
+// mockName returns the name of the mock of obj, which is exported if obj is.
+func mockName(obj *types.TypeName) string {
+	if obj.Exported() {
+		return "Mock" + obj.Name()
+	}
+	r, size := utf8.DecodeRuneInString(obj.Name())
+	return "mock" + string(unicode.ToUpper(r)) + obj.Name()[size:]
+}
+
+// mockParams returns the parameters of a mock method of sig, and the
+// arguments which forward them.
+func mockParams(sig *types.Signature, imports *mockImports) (params, args []string) {
+	for i := 0; i < sig.Params().Len(); i++ {
+		p := sig.Params().At(i)
+		name := p.Name()
+		if name == "" || name == "_" || imports.taken[name] {
+			name = "p" + strconv.Itoa(i)
+		}
+		typ := p.Type()
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			params = append(params, name+" ..."+types.TypeString(typ.(*types.Slice).Elem(), imports.qualifier))
+			args = append(args, name+"...")
+			continue
+		}
+		params = append(params, name+" "+types.TypeString(typ, imports.qualifier))
+		args = append(args, name)
+	}
+	return params, args
+}
+
+// mockResults returns the results of a mock method of sig.
+func mockResults(sig *types.Signature, imports *mockImports) string {
+	results := sig.Results()
+	if results.Len() == 0 {
+		return ""
+	}
+	var list []string
+	for i := 0; i < results.Len(); i++ {
+		list = append(list, types.TypeString(results.At(i).Type(), imports.qualifier))
+	}
+	if len(list) == 1 {
+		return list[0]
+	}
+	return "(" + strings.Join(list, ", ") + ")"
+}
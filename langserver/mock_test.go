@@ -0,0 +1,83 @@
+package langserver
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateMock(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `package p
+
+import (
+	"context"
+	"io"
+)
+
+type Store interface {
+	io.Closer
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(_ context.Context, m map[string]int, values ...string)
+}
+`
+	pkg := checkSource(t, src).Types
+
+	out, err := generateMock(pkg.Scope().Lookup("Store").(*types.TypeName))
+	require.NoError(err)
+	require.Equal(`// Code generated by "bingo.mock -type=Store"; DO NOT EDIT.
+
+package p
+
+import (
+	"context"
+)
+
+// MockStore is a mock of Store.
+type MockStore struct {
+	CloseFunc func() error
+	GetFunc   func(ctx context.Context, key string) ([]byte, error)
+	PutFunc   func(_ context.Context, m map[string]int, values ...string)
+}
+
+// Close calls CloseFunc.
+func (m *MockStore) Close() error {
+	if m.CloseFunc == nil {
+		panic("MockStore.Close is not set")
+	}
+	return m.CloseFunc()
+}
+
+// Get calls GetFunc.
+func (m *MockStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if m.GetFunc == nil {
+		panic("MockStore.Get is not set")
+	}
+	return m.GetFunc(ctx, key)
+}
+
+// Put calls PutFunc.
+func (m *MockStore) Put(p0 context.Context, p1 map[string]int, values ...string) {
+	if m.PutFunc == nil {
+		panic("MockStore.Put is not set")
+	}
+	m.PutFunc(p0, p1, values...)
+}
+
+var _ Store = (*MockStore)(nil)
+`, string(out))
+}
+
+func TestMockImports(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	imports := &mockImports{names: map[string]string{}, taken: map[string]bool{"m": true}}
+	require.Equal("rand", imports.qualifier(types.NewPackage("math/rand", "rand")))
+	require.Equal("rand2", imports.qualifier(types.NewPackage("crypto/rand", "rand")))
+	require.Equal("rand", imports.qualifier(types.NewPackage("math/rand", "rand")))
+	require.Equal("m2", imports.qualifier(types.NewPackage("example.com/m", "m")))
+}
@@ -0,0 +1,59 @@
+package langserver
+
+import (
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// clientProtocol is the version of the protocol the client speaks, negotiated
+// from its capabilities at initialize. The handlers compute the results of
+// the requests in the go-lsp structures, which clientProtocol serializes as
+// the client expects: as is for the clients which predate LSP 3.x, or as the
+// structures of LSP 3.17 for those which declare their support.
+type clientProtocol struct {
+	// hoverFormat is the kind of the protocol.MarkupContent of the hovers,
+	// or empty for the lsp.MarkedString contents.
+	hoverFormat protocol.MarkupKind
+
+	// hierarchicalSymbols is set if textDocument/documentSymbol answers
+	// protocol.DocumentSymbol trees rather than lsp.SymbolInformation lists.
+	hierarchicalSymbols bool
+}
+
+// negotiateProtocol returns the protocol of the client with the capabilities
+// caps.
+func negotiateProtocol(caps ClientCapabilities) clientProtocol {
+	var p clientProtocol
+	for _, format := range caps.TextDocument.Hover.ContentFormat {
+		if format == protocol.Markdown || format == protocol.PlainText {
+			p.hoverFormat = format
+			break
+		}
+	}
+	p.hierarchicalSymbols = caps.TextDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport
+	return p
+}
+
+// hover returns hover as the client expects it. The code of its contents is
+// fenced in markdown, and their text, which is markdown, is kept as is.
+func (p clientProtocol) hover(hover *lsp.Hover) interface{} {
+	if hover == nil || p.hoverFormat == "" {
+		return hover
+	}
+	var parts []string
+	for _, s := range hover.Contents {
+		switch {
+		case s.Value == "":
+		case s.Language == "" || p.hoverFormat == protocol.PlainText:
+			parts = append(parts, s.Value)
+		default:
+			parts = append(parts, "```"+s.Language+"\n"+s.Value+"\n```")
+		}
+	}
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{Kind: p.hoverFormat, Value: strings.Join(parts, "\n\n")},
+		Range:    hover.Range,
+	}
+}
@@ -0,0 +1,94 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	require := require.New(t)
+
+	negotiate := func(capabilities string) clientProtocol {
+		var caps ClientCapabilities
+		require.NoError(json.Unmarshal([]byte(capabilities), &caps))
+		return negotiateProtocol(caps)
+	}
+	require.Equal(clientProtocol{}, negotiate(`{}`))
+	require.Equal(clientProtocol{hoverFormat: protocol.Markdown}, negotiate(`{"textDocument":{"hover":{"contentFormat":["markdown","plaintext"]}}}`))
+	require.Equal(clientProtocol{hoverFormat: protocol.PlainText}, negotiate(`{"textDocument":{"hover":{"contentFormat":["html","plaintext"]}}}`))
+	require.Equal(clientProtocol{hierarchicalSymbols: true}, negotiate(`{"textDocument":{"documentSymbol":{"hierarchicalDocumentSymbolSupport":true}}}`))
+}
+
+func TestClientProtocolHover(t *testing.T) {
+	require := require.New(t)
+
+	rng := &lsp.Range{End: lsp.Position{Character: 1}}
+	hover := &lsp.Hover{
+		Contents: []lsp.MarkedString{{Language: "go", Value: "func A() int"}, lsp.RawMarkedString("A returns the *answer*.")},
+		Range:    rng,
+	}
+	require.Equal(hover, clientProtocol{}.hover(hover))
+	require.Equal(&protocol.Hover{
+		Contents: protocol.MarkupContent{Kind: protocol.Markdown, Value: "```go\nfunc A() int\n```\n\nA returns the *answer*."},
+		Range:    rng,
+	}, clientProtocol{hoverFormat: protocol.Markdown}.hover(hover))
+	require.Equal(&protocol.Hover{
+		Contents: protocol.MarkupContent{Kind: protocol.PlainText, Value: "func A() int\n\nA returns the *answer*."},
+		Range:    rng,
+	}, clientProtocol{hoverFormat: protocol.PlainText}.hover(hover))
+}
+
+func TestNegotiatedResults(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	src := "package p\n\n// A returns the answer.\nfunc A() int { return 42 }\n\ntype T struct{ F int }\n\nfunc (T) M() {}\n"
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/p\n",
+		"a.go":   src,
+	})
+	uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go")))
+
+	initialize := func(capabilities string) *TestContext {
+		var caps ClientCapabilities
+		require.NoError(json.Unmarshal([]byte(capabilities), &caps))
+		tx := &TestContext{h: NewHandler(testConfig(cache.Ondemand)), ctx: context.Background(), dir: root, capabilities: &caps}
+		tx.initServer(t)
+		t.Cleanup(tx.tearDown)
+		return tx
+	}
+	call := func(tx *TestContext, method string, params interface{}) string {
+		var result json.RawMessage
+		require.NoError(tx.conn.Call(tx.ctx, method, params, &result))
+		return string(result)
+	}
+	hoverParams := lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}, Position: lsp.Position{Line: 3, Character: 5}}
+	symbolParams := lsp.DocumentSymbolParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}
+
+	legacy := initialize(`{}`)
+	require.Contains(call(legacy, "textDocument/hover", hoverParams), `"contents":[{"language":"go","value":"func A() int"}`)
+	var information []lsp.SymbolInformation
+	require.NoError(json.Unmarshal([]byte(call(legacy, "textDocument/documentSymbol", symbolParams)), &information))
+	require.Len(information, 4)
+	require.Equal(uri, information[0].Location.URI)
+
+	current := initialize(`{"textDocument":{"hover":{"contentFormat":["markdown"]},"documentSymbol":{"hierarchicalDocumentSymbolSupport":true}}}`)
+	var hover protocol.Hover
+	require.NoError(json.Unmarshal([]byte(call(current, "textDocument/hover", hoverParams)), &hover))
+	require.Equal(protocol.Markdown, hover.Contents.Kind)
+	require.Contains(hover.Contents.Value, "```go\nfunc A() int\n```")
+	var symbols []protocol.DocumentSymbol
+	require.NoError(json.Unmarshal([]byte(call(current, "textDocument/documentSymbol", symbolParams)), &symbols))
+	require.Len(symbols, 3)
+	require.Equal("T", symbols[1].Name)
+	require.Len(symbols[1].Children, 1)
+	require.Equal("(T).M", symbols[2].Name)
+}
@@ -0,0 +1,166 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// boilerplate is the content inserted in the empty Go files: the license
+// header of Config.LicenseHeader and the package clause.
+type boilerplate struct {
+	// auto inserts it when the files are opened.
+	auto   bool
+	header *template.Template
+}
+
+// licenseHeaderData is the data of the template of Config.LicenseHeader.
+type licenseHeaderData struct {
+	Year    int
+	Package string
+}
+
+func newBoilerplate(c *Config) *boilerplate {
+	b := &boilerplate{auto: c.AutoPackageClause}
+	if c.LicenseHeader != "" {
+		header, err := template.New("license").Parse(c.LicenseHeader)
+		if err != nil {
+			log.Printf("invalid license header template: %s", err)
+		} else {
+			b.header = header
+		}
+	}
+	return b
+}
+
+// isEmptyGoFile reports whether text is the content of an empty Go file.
+func isEmptyGoFile(filename string, text []byte) bool {
+	return strings.HasSuffix(filename, ".go") && len(bytes.TrimSpace(text)) == 0
+}
+
+// text returns the boilerplate of the file filename.
+func (b *boilerplate) text(filename string) string {
+	pkg := packageClauseName(filename)
+
+	var buf bytes.Buffer
+	if b.header != nil {
+		var header bytes.Buffer
+		if err := b.header.Execute(&header, licenseHeaderData{Year: time.Now().Year(), Package: pkg}); err != nil {
+			log.Printf("failed to execute the license header template: %s", err)
+		} else {
+			writeComment(&buf, header.String())
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString("package " + pkg + "\n")
+	return buf.String()
+}
+
+// writeComment writes text as a comment, unless it already is one.
+func writeComment(buf *bytes.Buffer, text string) {
+	text = strings.TrimRight(text, "\n")
+	if strings.HasPrefix(text, "//") || strings.HasPrefix(text, "/*") {
+		buf.WriteString(text + "\n")
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			buf.WriteString("//\n")
+			continue
+		}
+		buf.WriteString("// " + line + "\n")
+	}
+}
+
+// edits returns the edits which insert the boilerplate in the empty file
+// filename.
+func (b *boilerplate) edits(filename string) []lsp.TextEdit {
+	return []lsp.TextEdit{{NewText: b.text(filename)}}
+}
+
+// packageClauseName returns the name of the package of the Go file
+// filename: the package of the other files of its directory, or else the
+// name of the directory.
+func packageClauseName(filename string) string {
+	dir := filepath.Dir(filename)
+	infos, err := ioutil.ReadDir(dir)
+	if err == nil {
+		fset := token.NewFileSet()
+		for _, info := range infos {
+			name := info.Name()
+			if info.IsDir() || !strings.HasSuffix(name, ".go") || name == filepath.Base(filename) {
+				continue
+			}
+			f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+			if err != nil || f.Name == nil || f.Name.Name == "" {
+				continue
+			}
+			// The external tests are in a package of their own.
+			if pkg := f.Name.Name; !strings.HasSuffix(pkg, "_test") {
+				return pkg
+			}
+		}
+	}
+
+	var name strings.Builder
+	for _, r := range strings.ToLower(filepath.Base(dir)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			name.WriteRune(r)
+		}
+	}
+	if name.Len() == 0 || !unicode.IsLetter([]rune(name.String())[0]) {
+		return "main"
+	}
+	return name.String()
+}
+
+// insert asks the client to insert the boilerplate in the empty file uri.
+func (b *boilerplate) insert(conn jsonrpc2.JSONRPC2, uri lsp.DocumentURI) {
+	filename, err := span.FromDocumentURI(uri).Filename()
+	if err != nil {
+		return
+	}
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{string(uri): b.edits(filename)}}
+
+	// The client answers after this notification has been handled.
+	go func() {
+		if err := applyEdit(context.Background(), conn, "insert package clause", edit); err != nil {
+			log.Printf("failed to insert the package clause of %s: %s", filename, err)
+		}
+	}()
+}
+
+// packageClauseActions returns the action which inserts the boilerplate in
+// the document uri, and whether the document is empty.
+func (h *LangHandler) packageClauseActions(ctx context.Context, uri lsp.DocumentURI) ([]protocol.CodeAction, bool) {
+	sourceURI := span.FromDocumentURI(uri)
+	filename, err := sourceURI.Filename()
+	if err != nil {
+		return nil, false
+	}
+	f, err := h.View().GetFile(ctx, sourceURI)
+	if err != nil || !isEmptyGoFile(filename, f.GetContent(ctx)) {
+		return nil, false
+	}
+
+	return []protocol.CodeAction{{
+		Title: "Add package clause",
+		Kind:  protocol.QuickFix,
+		Edit: lsp.WorkspaceEdit{
+			Changes: map[string][]lsp.TextEdit{string(uri): h.overlay.boilerplate.edits(filename)},
+		},
+	}}, true
+}
@@ -0,0 +1,40 @@
+package langserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoilerplate(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "bingo-newfile")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	pkgDir := filepath.Join(dir, "my-tool")
+	require.NoError(os.Mkdir(pkgDir, 0755))
+	filename := filepath.Join(pkgDir, "a.go")
+	require.Equal("mytool", packageClauseName(filename))
+
+	require.NoError(ioutil.WriteFile(filepath.Join(pkgDir, "x_test.go"), []byte("package mytool_test\n"), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(pkgDir, "x.go"), []byte("// Doc.\npackage tool\n\nfunc F() {}\n"), 0644))
+	require.Equal("tool", packageClauseName(filename))
+
+	b := newBoilerplate(&Config{LicenseHeader: "Copyright {{.Year}} Acme.\n\nPackage {{.Package}}."})
+	require.Equal(fmt.Sprintf("// Copyright %d Acme.\n//\n// Package tool.\n\npackage tool\n", time.Now().Year()), b.text(filename))
+
+	b = newBoilerplate(&Config{LicenseHeader: "/* MIT */"})
+	require.Equal("/* MIT */\n\npackage tool\n", b.text(filename))
+
+	require.True(isEmptyGoFile(filename, []byte(" \n")))
+	require.False(isEmptyGoFile(filename, []byte("package tool")))
+	require.False(isEmptyGoFile("go.mod", nil))
+}
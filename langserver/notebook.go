@@ -0,0 +1,661 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// isNotebookRequest reports whether method is a notification of the
+// synchronization of the notebook documents, which are handled in order,
+// like the file system requests.
+func isNotebookRequest(method string) bool {
+	return strings.HasPrefix(method, "notebookDocument/")
+}
+
+// notebookToleratedErrors are the type errors which are not reported in the
+// cells of a notebook, whose kernel evaluates the unused variables and
+// expressions to display them, and ignores the unused imports.
+var notebookToleratedErrors = []string{
+	"declared and not used",
+	"declared but not used",
+	"imported and not used",
+	"imported but not used",
+	"is not used",
+}
+
+// notebookCell is a cell of a notebook.
+type notebookCell struct {
+	uri        lsp.DocumentURI
+	kind       protocol.NotebookCellKind
+	languageID string
+	text       string
+}
+
+// code reports whether the cell is a code cell whose language is Go, the
+// only ones synthesized.
+func (c *notebookCell) code() bool {
+	return c.kind == protocol.Code && c.languageID == "go"
+}
+
+// notebookSegment is a range of lines of a cell copied in the synthesized
+// file of its notebook.
+type notebookSegment struct {
+	cell     lsp.DocumentURI
+	cellLine int // first line of the segment in the cell
+	fileLine int // first line of the segment in the synthesized file
+	lines    int
+}
+
+// notebook is a notebook document open in the client, whose Go cells are
+// type-checked as a synthesized file of a main package of its own.
+type notebook struct {
+	uri      lsp.DocumentURI
+	file     lsp.DocumentURI // of the synthesized file
+	version  int             // of the synthesized file
+	cells    []*notebookCell
+	segments []notebookSegment
+}
+
+// notebookDocuments maps the Go cells of the notebook documents of the
+// client to synthesized files, which are scratch files of the project, see
+// cache.Project.ScratchFilename. The notebook notifications are replaced
+// by the notifications of the changes of the synthesized files, and the
+// positions in the cells in the parameters of the requests and back in
+// their results and in the diagnostics.
+type notebookDocuments struct {
+	mu        sync.Mutex
+	project   *cache.Project
+	notebooks map[lsp.DocumentURI]*notebook // by notebook URI
+	cells     map[lsp.DocumentURI]*notebook // by cell URI
+	files     map[lsp.DocumentURI]*notebook // by synthesized file URI
+}
+
+func newNotebookDocuments() *notebookDocuments {
+	n := &notebookDocuments{}
+	n.reset(nil)
+	return n
+}
+
+// reset maps the notebooks to the scratch files of project.
+func (n *notebookDocuments) reset(project *cache.Project) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.project = project
+	n.notebooks = make(map[lsp.DocumentURI]*notebook)
+	n.cells = make(map[lsp.DocumentURI]*notebook)
+	n.files = make(map[lsp.DocumentURI]*notebook)
+}
+
+// move maps the notebooks to the scratch files of project, which has the same
+// root as the previous project, hence the same scratch files.
+func (n *notebookDocuments) move(project *cache.Project) {
+	n.mu.Lock()
+	n.project = project
+	n.mu.Unlock()
+}
+
+// notebookRequest is a request about a cell, whose result is translated back
+// to the cells with the segments of the notebook at the time of the request.
+type notebookRequest struct {
+	method   string
+	cell     lsp.DocumentURI
+	file     lsp.DocumentURI
+	cells    []lsp.DocumentURI // the code cells
+	segments []notebookSegment
+}
+
+// request returns req with the notebook notifications replaced by those of
+// the synthesized files, and the URIs and the positions of the cells in
+// the parameters of the other requests by those of the synthesized files.
+// The returned notebookRequest, nil if req is not about a cell, translates
+// the result.
+func (n *notebookDocuments) request(req *jsonrpc2.Request) (*jsonrpc2.Request, *notebookRequest) {
+	if req.Params == nil {
+		return req, nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.project == nil {
+		return req, nil
+	}
+	if isNotebookRequest(req.Method) {
+		method, params := n.sync(req.Method, *req.Params)
+		if params == nil {
+			return req, nil
+		}
+		translated := *req
+		translated.Method = method
+		data, _ := json.Marshal(params)
+		raw := json.RawMessage(data)
+		translated.Params = &raw
+		return &translated, nil
+	}
+	if len(n.cells) == 0 {
+		return req, nil
+	}
+
+	var document struct {
+		TextDocument struct {
+			URI lsp.DocumentURI `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if err := json.Unmarshal(*req.Params, &document); err != nil {
+		return req, nil
+	}
+	nb, ok := n.cells[document.TextDocument.URI]
+	if !ok {
+		return req, nil
+	}
+	params, err := decodeJSON(*req.Params)
+	if err != nil {
+		return req, nil
+	}
+	cell := document.TextDocument.URI
+	params.(map[string]interface{})["textDocument"].(map[string]interface{})["uri"] = string(nb.file)
+	walkPositions(params, func(line int) (int, bool) {
+		return toFileLine(nb.segments, cell, line)
+	})
+	data, _ := json.Marshal(params)
+	raw := json.RawMessage(data)
+	translated := *req
+	translated.Params = &raw
+	return &translated, nb.request(req.Method, cell)
+}
+
+// sync applies the notebook notification of method to the notebooks, and
+// returns the notification of the synthesized file replacing it, or a nil
+// params if the notebook is not known. It is assumed that the caller holds
+// the mutex.
+func (n *notebookDocuments) sync(method string, data json.RawMessage) (string, interface{}) {
+	switch method {
+	case "notebookDocument/didOpen":
+		var params protocol.DidOpenNotebookDocumentParams
+		if err := json.Unmarshal(data, &params); err != nil {
+			return "", nil
+		}
+		nb := &notebook{
+			uri:  params.NotebookDocument.URI,
+			file: lsp.DocumentURI(source.ToURI(n.project.ScratchFilename(notebookName(params.NotebookDocument.URI)))),
+		}
+		texts := make(map[lsp.DocumentURI]lsp.TextDocumentItem, len(params.CellTextDocuments))
+		for _, item := range params.CellTextDocuments {
+			texts[item.URI] = item
+		}
+		for _, cell := range params.NotebookDocument.Cells {
+			item := texts[cell.Document]
+			nb.cells = append(nb.cells, &notebookCell{uri: cell.Document, kind: cell.Kind, languageID: item.LanguageID, text: item.Text})
+			n.cells[cell.Document] = nb
+		}
+		n.notebooks[nb.uri] = nb
+		n.files[nb.file] = nb
+		text := nb.synthesize()
+		return "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: nb.file, LanguageID: "go", Version: nb.version, Text: text},
+		}
+
+	case "notebookDocument/didChange":
+		var params protocol.DidChangeNotebookDocumentParams
+		if err := json.Unmarshal(data, &params); err != nil {
+			return "", nil
+		}
+		nb, ok := n.notebooks[params.NotebookDocument.URI]
+		if !ok {
+			return "", nil
+		}
+		n.change(nb, params.Change)
+		text := nb.synthesize()
+		return "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+			TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: nb.file}, Version: nb.version},
+			ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: text}},
+		}
+
+	case "notebookDocument/didSave":
+		var params protocol.DidSaveNotebookDocumentParams
+		if err := json.Unmarshal(data, &params); err != nil {
+			return "", nil
+		}
+		nb, ok := n.notebooks[params.NotebookDocument.URI]
+		if !ok {
+			return "", nil
+		}
+		return "textDocument/didSave", lsp.DidSaveTextDocumentParams{TextDocument: lsp.TextDocumentIdentifier{URI: nb.file}}
+
+	case "notebookDocument/didClose":
+		var params protocol.DidCloseNotebookDocumentParams
+		if err := json.Unmarshal(data, &params); err != nil {
+			return "", nil
+		}
+		nb, ok := n.notebooks[params.NotebookDocument.URI]
+		if !ok {
+			return "", nil
+		}
+		for _, cell := range nb.cells {
+			delete(n.cells, cell.uri)
+		}
+		delete(n.notebooks, nb.uri)
+		delete(n.files, nb.file)
+		return "textDocument/didClose", lsp.DidCloseTextDocumentParams{TextDocument: lsp.TextDocumentIdentifier{URI: nb.file}}
+	}
+	return "", nil
+}
+
+// change applies the changes of the cells of the notebook nb. It is assumed
+// that the caller holds the mutex.
+func (n *notebookDocuments) change(nb *notebook, change protocol.NotebookDocumentChangeEvent) {
+	if change.Cells == nil {
+		return
+	}
+	byURI := make(map[lsp.DocumentURI]*notebookCell, len(nb.cells))
+	for _, cell := range nb.cells {
+		byURI[cell.uri] = cell
+	}
+
+	if structure := change.Cells.Structure; structure != nil {
+		texts := make(map[lsp.DocumentURI]lsp.TextDocumentItem, len(structure.DidOpen))
+		for _, item := range structure.DidOpen {
+			texts[item.URI] = item
+		}
+		start, end := structure.Array.Start, structure.Array.Start+structure.Array.DeleteCount
+		if start < 0 || start > len(nb.cells) {
+			start = len(nb.cells)
+		}
+		if end < start || end > len(nb.cells) {
+			end = len(nb.cells)
+		}
+		for _, cell := range nb.cells[start:end] {
+			delete(n.cells, cell.uri)
+		}
+		var inserted []*notebookCell
+		for _, c := range structure.Array.Cells {
+			cell, ok := byURI[c.Document]
+			if !ok {
+				item := texts[c.Document]
+				cell = &notebookCell{uri: c.Document, kind: c.Kind, languageID: item.LanguageID, text: item.Text}
+				byURI[cell.uri] = cell
+			}
+			inserted = append(inserted, cell)
+			n.cells[cell.uri] = nb
+		}
+		cells := append(append(append([]*notebookCell(nil), nb.cells[:start]...), inserted...), nb.cells[end:]...)
+		nb.cells = cells
+	}
+
+	for _, c := range change.Cells.Data {
+		if cell, ok := byURI[c.Document]; ok {
+			cell.kind = c.Kind
+		}
+	}
+
+	for _, content := range change.Cells.TextContent {
+		cell, ok := byURI[content.Document.URI]
+		if !ok {
+			continue
+		}
+		if len(content.Changes) == 1 && content.Changes[0].Range == nil {
+			cell.text = content.Changes[0].Text
+			continue
+		}
+		text, err := applyContentChanges([]byte(cell.text), span.NewLineIndex([]byte(cell.text)), content.Changes)
+		if err == nil {
+			cell.text = string(text)
+		}
+	}
+}
+
+// notebookName returns the name of the scratch file synthesized for the
+// notebook uri.
+func notebookName(uri lsp.DocumentURI) string {
+	sum := sha256.Sum256([]byte(uri))
+	base := strings.TrimSuffix(path.Base(string(uri)), path.Ext(string(uri)))
+	return "notebook-" + strings.TrimSuffix(scratchName(base), ".go") + "-" + hex.EncodeToString(sum[:4]) + ".go"
+}
+
+// synthesize synthesizes the file of the Go cells of the notebook, and
+// returns its content. The leading imports of the cells come first, then
+// the cells of declarations, then the cells of statements in the body of a
+// function, every cell in the order of the notebook. The lines of the
+// cells are copied as they are, so that a position in a cell is the
+// position of the same column of a line of the file, see notebookSegment.
+func (nb *notebook) synthesize() string {
+	type part struct {
+		cell  lsp.DocumentURI
+		first int
+		lines []string
+	}
+	var imports, decls, stmts []part
+	for _, cell := range nb.cells {
+		if !cell.code() {
+			continue
+		}
+		lines := strings.Split(cell.text, "\n")
+		n := importLines(cell.text)
+		if n > len(lines) {
+			n = len(lines)
+		}
+		if n > 0 {
+			imports = append(imports, part{cell.uri, 0, lines[:n]})
+		}
+		rest := lines[n:]
+		body := strings.Join(rest, "\n")
+		if strings.TrimSpace(body) == "" {
+			continue
+		}
+		if isDeclarations(body) {
+			decls = append(decls, part{cell.uri, n, rest})
+		} else {
+			stmts = append(stmts, part{cell.uri, n, rest})
+		}
+	}
+
+	lines := []string{"package main", ""}
+	var segments []notebookSegment
+	add := func(parts []part) {
+		for _, p := range parts {
+			segments = append(segments, notebookSegment{cell: p.cell, cellLine: p.first, fileLine: len(lines), lines: len(p.lines)})
+			lines = append(lines, p.lines...)
+		}
+	}
+	add(imports)
+	add(decls)
+	lines = append(lines, "func _() {")
+	add(stmts)
+	lines = append(lines, "}")
+
+	nb.version++
+	nb.segments = segments
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// importLines returns the number of the leading lines of src which are
+// import declarations.
+func importLines(src string) int {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, 0)
+	last := token.NoPos
+	for {
+		_, tok, _ := s.Scan()
+		if tok != token.IMPORT {
+			break
+		}
+		pos, tok, _ := s.Scan()
+		if tok == token.LPAREN {
+			for tok != token.RPAREN && tok != token.EOF {
+				pos, tok, _ = s.Scan()
+			}
+		} else if tok == token.IDENT || tok == token.PERIOD {
+			pos, tok, _ = s.Scan()
+		}
+		if tok == token.EOF {
+			break
+		}
+		last = pos
+		if _, tok, _ := s.Scan(); tok != token.SEMICOLON {
+			break
+		}
+	}
+	if !last.IsValid() {
+		return 0
+	}
+	return file.Line(last)
+}
+
+// isDeclarations reports whether the cell src is made of declarations rather
+// than of statements. A cell which is neither, eg. while it is edited, is
+// made of declarations if it starts with the keyword of one.
+func isDeclarations(src string) bool {
+	fset := token.NewFileSet()
+	if _, err := parser.ParseFile(fset, "", "package p\n"+src, 0); err == nil {
+		return true
+	}
+	if _, err := parser.ParseFile(fset, "", "package p\nfunc _() {\n"+src+"\n}", 0); err == nil {
+		return false
+	}
+	file := fset.AddFile("", -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, 0)
+	switch _, tok, _ := s.Scan(); tok {
+	case token.FUNC, token.TYPE, token.CONST, token.VAR:
+		return true
+	}
+	return false
+}
+
+// request returns the request of method about the cell of the notebook.
+func (nb *notebook) request(method string, cell lsp.DocumentURI) *notebookRequest {
+	r := &notebookRequest{method: method, cell: cell, file: nb.file, segments: nb.segments}
+	for _, c := range nb.cells {
+		if c.code() {
+			r.cells = append(r.cells, c.uri)
+		}
+	}
+	return r
+}
+
+// toFileLine returns the line of the synthesized file of the line of cell.
+func toFileLine(segments []notebookSegment, cell lsp.DocumentURI, line int) (int, bool) {
+	for _, s := range segments {
+		if s.cell == cell && s.cellLine <= line && line < s.cellLine+s.lines {
+			return s.fileLine + line - s.cellLine, true
+		}
+	}
+	return 0, false
+}
+
+// toCellLine returns the cell of a line of the synthesized file, and its
+// line in the cell. ok is false for the lines synthesized around the cells.
+func toCellLine(segments []notebookSegment, line int) (cell lsp.DocumentURI, cellLine int, ok bool) {
+	for _, s := range segments {
+		if s.fileLine <= line && line < s.fileLine+s.lines {
+			return s.cell, s.cellLine + line - s.fileLine, true
+		}
+	}
+	return "", 0, false
+}
+
+// result returns v with the positions of the synthesized file replaced by
+// those of the cells, and the URI of the synthesized file of the locations
+// by the URIs of their cells. The diagnostics pulled for a cell are only
+// those of the cell.
+func (n *notebookDocuments) result(r *notebookRequest, v interface{}) (interface{}, error) {
+	if r == nil || v == nil {
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.method == "textDocument/diagnostic" {
+		if report, ok := result.(map[string]interface{}); ok {
+			if items, ok := report["items"].([]interface{}); ok {
+				var diagnostics []lsp.Diagnostic
+				data, _ := json.Marshal(items)
+				if err := json.Unmarshal(data, &diagnostics); err == nil {
+					report["items"] = r.cellDiagnostics(diagnostics)[r.cell]
+				}
+			}
+		}
+		return result, nil
+	}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if uri, ok := v["uri"].(string); ok && lsp.DocumentURI(uri) == r.file {
+				// A location in the synthesized file is in the cell of its
+				// start.
+				if rng, ok := v["range"].(map[string]interface{}); ok {
+					if start, ok := rng["start"].(map[string]interface{}); ok {
+						if line, ok := jsonLine(start["line"]); ok {
+							if cell, _, ok := toCellLine(r.segments, line); ok {
+								v["uri"] = string(cell)
+							}
+						}
+					}
+				}
+			}
+			if line, ok := jsonLine(v["line"]); ok {
+				if _, isChar := v["character"]; isChar && len(v) == 2 {
+					if _, cellLine, ok := toCellLine(r.segments, line); ok {
+						v["line"] = cellLine
+					}
+					return
+				}
+			}
+			for _, value := range v {
+				walk(value)
+			}
+		case []interface{}:
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+	walk(result)
+	return result, nil
+}
+
+// cellDiagnostics returns the diagnostics of the synthesized file by cell,
+// every code cell having a possibly empty set. The diagnostics of the lines
+// synthesized around the cells and the tolerated errors are dropped.
+func (r *notebookRequest) cellDiagnostics(diagnostics []lsp.Diagnostic) map[lsp.DocumentURI][]lsp.Diagnostic {
+	result := make(map[lsp.DocumentURI][]lsp.Diagnostic, len(r.cells))
+	for _, cell := range r.cells {
+		result[cell] = []lsp.Diagnostic{}
+	}
+	for _, d := range diagnostics {
+		if tolerated(d.Message) {
+			continue
+		}
+		cell, start, ok := toCellLine(r.segments, d.Range.Start.Line)
+		if !ok {
+			continue
+		}
+		d.Range.End.Line += start - d.Range.Start.Line
+		if endCell, end, ok := toCellLine(r.segments, d.Range.End.Line); ok && endCell == cell {
+			d.Range.End.Line = end
+		}
+		d.Range.Start.Line = start
+		result[cell] = append(result[cell], d)
+	}
+	return result
+}
+
+// tolerated reports whether message is one of the notebookToleratedErrors.
+func tolerated(message string) bool {
+	for _, tolerated := range notebookToleratedErrors {
+		if strings.Contains(message, tolerated) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeJSON decodes data, keeping the numbers as json.Number.
+func decodeJSON(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// jsonLine returns the line of a position decoded by decodeJSON.
+func jsonLine(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		i, err := v.Int64()
+		return int(i), err == nil
+	case int:
+		return v, true
+	}
+	return 0, false
+}
+
+// walkPositions replaces the lines of the positions of v, the objects with
+// a line and a character, by their line mapped by f, if it is mapped.
+func walkPositions(v interface{}, f func(line int) (int, bool)) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if line, ok := jsonLine(v["line"]); ok {
+			if _, isChar := v["character"]; isChar && len(v) == 2 {
+				if mapped, ok := f(line); ok {
+					v["line"] = mapped
+				}
+				return
+			}
+		}
+		for _, value := range v {
+			walkPositions(value, f)
+		}
+	case []interface{}:
+		for _, value := range v {
+			walkPositions(value, f)
+		}
+	}
+}
+
+// conn returns conn, splitting the diagnostics of the synthesized files
+// published to the client into those of their cells.
+func (n *notebookDocuments) conn(conn jsonrpc2.JSONRPC2) jsonrpc2.JSONRPC2 {
+	return notebookConn{JSONRPC2: conn, notebooks: n}
+}
+
+type notebookConn struct {
+	jsonrpc2.JSONRPC2
+	notebooks *notebookDocuments
+}
+
+func (c notebookConn) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	if method != "textDocument/publishDiagnostics" {
+		return c.JSONRPC2.Notify(ctx, method, params, opt...)
+	}
+	var diagnostics lsp.PublishDiagnosticsParams
+	data, err := json.Marshal(params)
+	if err != nil || json.Unmarshal(data, &diagnostics) != nil {
+		return c.JSONRPC2.Notify(ctx, method, params, opt...)
+	}
+	c.notebooks.mu.Lock()
+	nb, ok := c.notebooks.files[diagnostics.URI]
+	var r *notebookRequest
+	if ok {
+		r = nb.request(method, "")
+	}
+	c.notebooks.mu.Unlock()
+	if !ok {
+		return c.JSONRPC2.Notify(ctx, method, params, opt...)
+	}
+
+	for cell, cellDiagnostics := range r.cellDiagnostics(diagnostics.Diagnostics) {
+		if err := c.JSONRPC2.Notify(ctx, method, &lsp.PublishDiagnosticsParams{URI: cell, Diagnostics: cellDiagnostics}, opt...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
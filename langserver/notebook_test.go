@@ -0,0 +1,168 @@
+package langserver
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportLines(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{src: `x := 1`, want: 0},
+		{src: "import \"fmt\"\n", want: 1},
+		{src: "import \"fmt\"\nimport str \"strings\"\nfmt.Println(str.ToUpper(\"a\"))", want: 2},
+		{src: "import (\n\t\"fmt\"\n\t\"os\"\n)\n\nfmt.Println(os.Args)", want: 4},
+		{src: "// A comment.\nimport \"fmt\"", want: 2},
+	}
+	for _, test := range tests {
+		if got := importLines(test.src); got != test.want {
+			t.Errorf("importLines(%q) = %d, want %d", test.src, got, test.want)
+		}
+	}
+}
+
+func TestIsDeclarations(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: "func f() int { return 1 }", want: true},
+		{src: "type T struct{}", want: true},
+		{src: "var x = 1", want: true},
+		{src: "x := 1", want: false},
+		{src: "fmt.Println(x)", want: false},
+		{src: "func() { println() }()", want: false},
+		// Being edited.
+		{src: "func f() int {", want: true},
+		{src: "x := ", want: false},
+	}
+	for _, test := range tests {
+		if got := isDeclarations(test.src); got != test.want {
+			t.Errorf("isDeclarations(%q) = %t, want %t", test.src, got, test.want)
+		}
+	}
+}
+
+func TestNotebookSynthesize(t *testing.T) {
+	nb := &notebook{cells: []*notebookCell{
+		{uri: "cell:1", kind: protocol.Code, languageID: "go", text: "import \"fmt\"\n\nx := f()"},
+		{uri: "cell:2", kind: protocol.Markup, languageID: "markdown", text: "# Title"},
+		{uri: "cell:3", kind: protocol.Code, languageID: "go", text: "func f() int {\n\treturn 1\n}"},
+		{uri: "cell:4", kind: protocol.Code, languageID: "go", text: "fmt.Println(x)"},
+	}}
+	want := strings.Join([]string{
+		"package main",
+		"",
+		`import "fmt"`,
+		"func f() int {",
+		"\treturn 1",
+		"}",
+		"func _() {",
+		"",
+		"x := f()",
+		"fmt.Println(x)",
+		"}",
+		"",
+	}, "\n")
+	if got := nb.synthesize(); got != want {
+		t.Errorf("got synthesized file\n%s\nwant\n%s", got, want)
+	}
+
+	for _, test := range []struct {
+		cell     lsp.DocumentURI
+		cellLine int
+		fileLine int
+	}{
+		{cell: "cell:1", cellLine: 0, fileLine: 2},
+		{cell: "cell:1", cellLine: 2, fileLine: 8},
+		{cell: "cell:3", cellLine: 1, fileLine: 4},
+		{cell: "cell:4", cellLine: 0, fileLine: 9},
+	} {
+		if line, ok := toFileLine(nb.segments, test.cell, test.cellLine); !ok || line != test.fileLine {
+			t.Errorf("toFileLine(%s, %d) = %d, %t, want %d", test.cell, test.cellLine, line, ok, test.fileLine)
+		}
+		if cell, line, ok := toCellLine(nb.segments, test.fileLine); !ok || cell != test.cell || line != test.cellLine {
+			t.Errorf("toCellLine(%d) = %s, %d, %t, want %s, %d", test.fileLine, cell, line, ok, test.cell, test.cellLine)
+		}
+	}
+	if _, _, ok := toCellLine(nb.segments, 6); ok {
+		t.Error("toCellLine mapped the synthesized func _() line to a cell")
+	}
+}
+
+func TestNotebook(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/notebook\n",
+	})
+	cfg := testConfig(cache.Ondemand)
+	cfg.DiagnosticsStyle = string(instantDiagnostics)
+	tx := newWorkspaceContext(t, cfg, root)
+
+	notebookURI := util.PathToURI(filepath.ToSlash(filepath.Join(root, "analysis.ipynb")))
+	cells := []lsp.TextDocumentItem{
+		{URI: "vscode-notebook-cell:analysis.ipynb#a", LanguageID: "go", Version: 1, Text: "func f() int {\n\treturn 1\n}"},
+		{URI: "vscode-notebook-cell:analysis.ipynb#b", LanguageID: "go", Version: 1, Text: "x := f()\nx\nvar s string = x"},
+	}
+	params := protocol.DidOpenNotebookDocumentParams{
+		NotebookDocument:  protocol.NotebookDocument{URI: notebookURI, NotebookType: "jupyter-notebook", Version: 1},
+		CellTextDocuments: cells,
+	}
+	for _, cell := range cells {
+		params.NotebookDocument.Cells = append(params.NotebookDocument.Cells, protocol.NotebookCell{Kind: protocol.Code, Document: cell.URI})
+	}
+	require.NoError(tx.conn.Notify(tx.ctx, "notebookDocument/didOpen", params))
+
+	// The type error is reported in its cell, the unused expression is not.
+	diagnostics := tx.client.awaitDiagnostics(t, cells[1].URI)
+	require.Len(diagnostics.Diagnostics, 1)
+	require.Equal(2, diagnostics.Diagnostics[0].Range.Start.Line)
+	require.Contains(diagnostics.Diagnostics[0].Message, "cannot use x")
+	require.Empty(tx.client.awaitDiagnostics(t, cells[0].URI).Diagnostics)
+
+	// The definition of f in the second cell is in the first one.
+	var locations []lsp.Location
+	require.NoError(tx.conn.Call(tx.ctx, "textDocument/definition", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: cells[1].URI},
+		Position:     lsp.Position{Line: 0, Character: 5},
+	}, &locations))
+	require.Len(locations, 1)
+	require.Equal(cells[0].URI, locations[0].URI)
+	require.Equal(0, locations[0].Range.Start.Line)
+	require.Equal(5, locations[0].Range.Start.Character)
+
+	// The fix of the cell clears its diagnostics.
+	change := protocol.DidChangeNotebookDocumentParams{
+		NotebookDocument: protocol.VersionedNotebookDocumentIdentifier{URI: notebookURI, Version: 2},
+	}
+	change.Change.Cells = &struct {
+		Structure *struct {
+			Array    protocol.NotebookCellArrayChange `json:"array"`
+			DidOpen  []lsp.TextDocumentItem           `json:"didOpen,omitempty"`
+			DidClose []lsp.TextDocumentIdentifier     `json:"didClose,omitempty"`
+		} `json:"structure,omitempty"`
+		Data        []protocol.NotebookCell                  `json:"data,omitempty"`
+		TextContent []protocol.NotebookCellTextContentChange `json:"textContent,omitempty"`
+	}{
+		TextContent: []protocol.NotebookCellTextContentChange{{
+			Document: lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: cells[1].URI}, Version: 2},
+			Changes: []lsp.TextDocumentContentChangeEvent{{
+				Range: &lsp.Range{Start: lsp.Position{Line: 2, Character: 6}, End: lsp.Position{Line: 2, Character: 12}},
+				Text:  "int",
+			}},
+		}},
+	}
+	require.NoError(tx.conn.Notify(tx.ctx, "notebookDocument/didChange", change))
+	require.Empty(tx.client.awaitDiagnostics(t, cells[1].URI).Diagnostics)
+}
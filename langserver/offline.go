@@ -0,0 +1,69 @@
+package langserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+// offlineEnv returns env, the environment overrides of the go command in the
+// workspace rootPath, with the ones of the offline mode: GOPROXY=off and
+// GOSUMDB=off, so that the go command fails at once instead of timing out,
+// and -mod=mod in GOFLAGS unless the workspace is vendored, so that it
+// resolves the imports with the modules of the module cache. The other
+// flags of GOFLAGS are kept.
+func offlineEnv(env []string, rootPath string) []string {
+	goflags, ok := os.LookupEnv("GOFLAGS")
+	var overrides []string
+	for _, kv := range env {
+		switch {
+		case strings.HasPrefix(kv, "GOFLAGS="):
+			goflags, ok = strings.TrimPrefix(kv, "GOFLAGS="), true
+		case strings.HasPrefix(kv, "GOPROXY="), strings.HasPrefix(kv, "GOSUMDB="):
+		default:
+			overrides = append(overrides, kv)
+		}
+	}
+
+	var flags []string
+	if ok {
+		for _, flag := range strings.Fields(goflags) {
+			if !strings.HasPrefix(flag, "-mod=") {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	if !fileExists(filepath.Join(rootPath, "vendor", "modules.txt")) {
+		flags = append(flags, "-mod=mod")
+	}
+	overrides = append(overrides, "GOPROXY=off", "GOSUMDB=off")
+	if len(flags) > 0 || ok {
+		overrides = append(overrides, "GOFLAGS="+strings.Join(flags, " "))
+	}
+	return overrides
+}
+
+// missingModulesDiagnostics returns a single diagnostic of the modules
+// required by the workspace which are missing from the module cache and
+// could not be downloaded, telling how to get them.
+func (h *LangHandler) missingModulesDiagnostics() []lsp.Diagnostic {
+	missing := h.project.MissingModules()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("%d modules are missing from the module cache: %s. ", len(missing), strings.Join(missing, ", "))
+	if h.config.Offline {
+		message += "They are not downloaded in offline mode: run go mod download online, or disable the offline mode."
+	} else {
+		message += "Check the network access to GOPROXY, or run go mod download."
+	}
+	return []lsp.Diagnostic{{
+		Severity: lsp.Error,
+		Source:   "modules",
+		Message:  message,
+	}}
+}
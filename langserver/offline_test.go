@@ -0,0 +1,29 @@
+package langserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOfflineEnv(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "bingo-offline")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	env := offlineEnv([]string{"GOFLAGS=-tags=integration -mod=vendor", "GOPROXY=https://proxy.example.com", "GOPRIVATE=example.com"}, dir)
+	require.Equal([]string{"GOPRIVATE=example.com", "GOPROXY=off", "GOSUMDB=off", "GOFLAGS=-tags=integration -mod=mod"}, env)
+
+	// The vendored workspaces resolve the imports with their vendor
+	// directory.
+	require.NoError(os.MkdirAll(filepath.Join(dir, "vendor"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "vendor", "modules.txt"), nil, 0644))
+	env = offlineEnv([]string{"GOFLAGS=-mod=mod"}, dir)
+	require.Equal([]string{"GOPROXY=off", "GOSUMDB=off", "GOFLAGS="}, env)
+}
@@ -0,0 +1,261 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	godoc "go/doc"
+	"go/format"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	doc "github.com/slimsag/godocmd"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// maxReadmeSize bounds the size of the README returned by bingo/packageDoc.
+const maxReadmeSize = 64 << 10
+
+// readmeNames are the names of the README files of a package directory.
+var readmeNames = []string{"README.md", "README.markdown", "README", "readme.md"}
+
+// PackageDocParams is the parameter of the bingo/packageDoc request.
+type PackageDocParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+
+	// Position selects an import or a package qualifier of TextDocument.
+	// Without it, the documentation of the package of TextDocument is
+	// returned.
+	Position *lsp.Position `json:"position,omitempty"`
+}
+
+// PackageDoc is the documentation of a package.
+type PackageDoc struct {
+	ImportPath string `json:"importPath"`
+	Name       string `json:"name"`
+	Synopsis   string `json:"synopsis"`
+
+	// Markdown is the package comment and the documentation of the exported
+	// declarations, rendered as markdown.
+	Markdown string `json:"markdown"`
+
+	// Readme is the README of the package directory, if any.
+	Readme string `json:"readme,omitempty"`
+}
+
+func (h *LangHandler) handlePackageDoc(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params PackageDocParams) (*PackageDoc, error) {
+	var target source.Package
+	if params.Position == nil {
+		pkg, _, err := h.loadPackageAndAst(ctx, params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		target = pkg
+	} else {
+		pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, *params.Position)
+		if err != nil {
+			return nil, err
+		}
+		pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+		if err != nil {
+			return nil, err
+		}
+		target = packageAt(pkg, pathNodes)
+	}
+
+	if target == nil {
+		return nil, nil
+	}
+	return newPackageDoc(target), nil
+}
+
+// packageAt returns the package of the import, the package qualifier or the
+// package clause of pathNodes.
+func packageAt(pkg source.Package, pathNodes []ast.Node) source.Package {
+	for _, n := range pathNodes {
+		switch n := n.(type) {
+		case *ast.ImportSpec:
+			path, err := strconv.Unquote(n.Path.Value)
+			if err != nil {
+				return nil
+			}
+			return pkg.GetImport(path)
+		case *ast.Ident:
+			if pkgName, ok := pkg.GetTypesInfo().ObjectOf(n).(*types.PkgName); ok {
+				return pkg.GetImport(pkgName.Imported().Path())
+			}
+			if packageStatementName(pkg.GetFileSet(), pkg.GetSyntax(), n) != "" {
+				return pkg
+			}
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok {
+				if pkgName, ok := pkg.GetTypesInfo().ObjectOf(x).(*types.PkgName); ok {
+					return pkg.GetImport(pkgName.Imported().Path())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func newPackageDoc(pkg source.Package) *PackageDoc {
+	d := &PackageDoc{
+		ImportPath: pkg.GetPkgPath(),
+		Name:       pkg.GetName(),
+		Synopsis:   godoc.Synopsis(source.PackageDoc(pkg.GetSyntax(), pkg.GetName())),
+		Markdown:   packageMarkdown(pkg.GetFileSet(), pkg.GetName(), pkg.GetPkgPath(), pkg.GetSyntax()),
+	}
+	if files := pkg.GetSyntax(); len(files) > 0 {
+		d.Readme = readme(filepath.Dir(pkg.GetFileSet().Position(files[0].Pos()).Filename))
+	}
+	return d
+}
+
+// packageSynopsis returns the first sentence of the package comment of pkg.
+func packageSynopsis(pkg source.Package) string {
+	if pkg == nil {
+		return ""
+	}
+	synopsis := godoc.Synopsis(source.PackageDoc(pkg.GetSyntax(), pkg.GetName()))
+	if synopsis == "" {
+		return ""
+	}
+	return synopsis + "\n"
+}
+
+// packageMarkdown renders the documentation of the exported declarations of
+// files as markdown.
+func packageMarkdown(fset *token.FileSet, name, importPath string, files []*ast.File) string {
+	astFiles := map[string]*ast.File{}
+	for _, f := range files {
+		astFiles[fset.Position(f.Pos()).Filename] = f
+	}
+	// The syntax is shared with the cache, so go/doc must neither filter nor
+	// trim it: unexported declarations are skipped below instead.
+	p := godoc.New(&ast.Package{Name: name, Files: astFiles}, importPath, godoc.AllDecls|godoc.PreserveAST)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# package %s\n\n`import %q`\n\n", name, importPath)
+	writeDoc(&b, p.Doc)
+
+	writeValues := func(title string, values []*godoc.Value) {
+		values = exportedValues(values)
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "## %s\n\n", title)
+		for _, v := range values {
+			writeDecl(&b, fset, v.Decl)
+			writeDoc(&b, v.Doc)
+		}
+	}
+	writeFuncs := func(level string, funcs []*godoc.Func) {
+		for _, fn := range funcs {
+			if !ast.IsExported(fn.Name) {
+				continue
+			}
+			title := fn.Name
+			if fn.Recv != "" {
+				title = "(" + fn.Recv + ") " + title
+			}
+			fmt.Fprintf(&b, "%s func %s\n\n", level, title)
+			writeDecl(&b, fset, &ast.FuncDecl{Recv: fn.Decl.Recv, Name: fn.Decl.Name, Type: fn.Decl.Type})
+			writeDoc(&b, fn.Doc)
+		}
+	}
+
+	writeValues("Constants", p.Consts)
+	writeValues("Variables", p.Vars)
+
+	if hasExportedFunc(p.Funcs) {
+		b.WriteString("## Functions\n\n")
+		writeFuncs("###", p.Funcs)
+	}
+
+	var exportedTypes []*godoc.Type
+	for _, t := range p.Types {
+		if ast.IsExported(t.Name) {
+			exportedTypes = append(exportedTypes, t)
+		}
+	}
+	if len(exportedTypes) > 0 {
+		b.WriteString("## Types\n\n")
+	}
+	for _, t := range exportedTypes {
+		fmt.Fprintf(&b, "### type %s\n\n", t.Name)
+		writeDecl(&b, fset, t.Decl)
+		writeDoc(&b, t.Doc)
+		for _, v := range exportedValues(append(t.Consts, t.Vars...)) {
+			writeDecl(&b, fset, v.Decl)
+			writeDoc(&b, v.Doc)
+		}
+		writeFuncs("####", t.Funcs)
+		writeFuncs("####", t.Methods)
+	}
+	return b.String()
+}
+
+// exportedValues returns the values which declare an exported name.
+func exportedValues(values []*godoc.Value) []*godoc.Value {
+	var exported []*godoc.Value
+	for _, v := range values {
+		for _, name := range v.Names {
+			if ast.IsExported(name) {
+				exported = append(exported, v)
+				break
+			}
+		}
+	}
+	return exported
+}
+
+func hasExportedFunc(funcs []*godoc.Func) bool {
+	for _, fn := range funcs {
+		if ast.IsExported(fn.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeDecl(b *bytes.Buffer, fset *token.FileSet, decl ast.Decl) {
+	if gen, ok := decl.(*ast.GenDecl); ok && gen.Doc != nil {
+		// The doc comment is written after the declaration.
+		withoutDoc := *gen
+		withoutDoc.Doc = nil
+		decl = &withoutDoc
+	}
+	b.WriteString("```go\n")
+	format.Node(b, fset, decl)
+	b.WriteString("\n```\n\n")
+}
+
+func writeDoc(b *bytes.Buffer, text string) {
+	if text == "" {
+		return
+	}
+	doc.ToMarkdown(b, text, nil)
+	b.WriteString("\n")
+}
+
+// readme returns the README of dir, if any.
+func readme(dir string) string {
+	for _, name := range readmeNames {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if len(content) > maxReadmeSize {
+			content = content[:maxReadmeSize]
+		}
+		return string(content)
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageMarkdown(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `// Package p does things.
+package p
+
+// Max is the maximum.
+const Max = 10
+
+const min = 0
+
+// T is a thing.
+type T struct{ F int }
+
+// NewT returns a T.
+func NewT() *T { return &T{} }
+
+// Do does it.
+func (t *T) Do(n int) error { return nil }
+
+func (t *T) hidden() {}
+
+// Run runs.
+func Run() {}
+
+func helper() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	require.NoError(err)
+
+	md := packageMarkdown(fset, "p", "example.com/p", []*ast.File{f})
+	require.Contains(md, "# package p\n\n`import \"example.com/p\"`")
+	require.Contains(md, "Package p does things.")
+	require.Contains(md, "## Constants\n\n```go\nconst Max = 10\n```")
+	require.Contains(md, "### func Run\n\n```go\nfunc Run()\n```")
+	require.Contains(md, "### type T\n\n```go\ntype T struct{ F int }\n```")
+	require.Contains(md, "#### func NewT")
+	require.Contains(md, "#### func (*T) Do\n\n```go\nfunc (t *T) Do(n int) error\n```")
+	require.NotContains(md, "min")
+	require.NotContains(md, "hidden")
+	require.NotContains(md, "helper")
+
+	// The syntax must not be modified.
+	require.Len(f.Decls, 8)
+	require.NotNil(f.Decls[7].(*ast.FuncDecl).Body)
+}
+
+func TestReadme(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "readme")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	require.Equal("", readme(dir))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("# p\n"), 0644))
+	require.Equal("# p\n", readme(dir))
+}
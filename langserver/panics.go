@@ -0,0 +1,208 @@
+package langserver
+
+import (
+	"context"
+	"go/constant"
+	"go/token"
+	"sort"
+
+	"github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// panicsCommand is the workspace/executeCommand command which reports the
+// panic sites reachable from a function.
+const panicsCommand = "bingo.panics"
+
+// PanicsParams is the argument of the bingo.panics command. The position
+// selects the root function.
+type PanicsParams struct {
+	lsp.TextDocumentPositionParams
+
+	// Algorithm is the call graph construction algorithm, "cha" or "rta".
+	// Defaults to "cha".
+	Algorithm string `json:"algorithm,omitempty"`
+}
+
+// PanicSite is a location which may panic.
+type PanicSite struct {
+	// Kind is "panic" for explicit panic calls, and "nilness" for the nil
+	// dereferences reported by the nilness analyzer. The index expressions
+	// which may be out of range are not reported, see panicSites.
+	Kind     string       `json:"kind"`
+	Message  string       `json:"message"`
+	Location lsp.Location `json:"location"`
+
+	// CallPath is the chain of functions from the root function to the
+	// function of the site.
+	CallPath []string `json:"callPath"`
+}
+
+func (h *LangHandler) handlePanics(ctx context.Context, params PanicsParams) ([]PanicSite, error) {
+	prog, root, err := h.ssaFunctionAt(ctx, params.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+
+	cg, err := prog.callGraph(params.Algorithm, root)
+	if err != nil {
+		return nil, err
+	}
+
+	sites := prog.panicSites(cg.Nodes[root])
+
+	// Only the sites of the workspace are actionable.
+	inside := sites[:0]
+	for _, site := range sites {
+		if h.project.Contain(site.Location.URI) {
+			inside = append(inside, site)
+		}
+	}
+	return inside, nil
+}
+
+// panicSites returns the panic sites of the functions reachable from root.
+// The index expressions are not candidates: any of them panics with an index
+// out of range unless its bounds are proven, which needs a range analysis
+// the SSA form does not provide, so that they would drown the other sites.
+func (prog *ssaProgram) panicSites(root *callgraph.Node) []PanicSite {
+	sites := []PanicSite{}
+	if root == nil {
+		return sites
+	}
+
+	// Walk the callees breadth first, so that the call paths are the
+	// shortest ones.
+	parents := map[*callgraph.Node]*callgraph.Node{root: nil}
+	queue := []*callgraph.Node{root}
+	var nodes []*callgraph.Node
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n.Func.Synthetic == "" && n.Func.Blocks != nil && n.Func.Pkg != nil {
+			nodes = append(nodes, n)
+		}
+		for _, edge := range n.Out {
+			if _, ok := parents[edge.Callee]; !ok {
+				parents[edge.Callee] = n
+				queue = append(queue, edge.Callee)
+			}
+		}
+	}
+
+	callPath := func(n *callgraph.Node) []string {
+		var path []string
+		for ; n != nil; n = parents[n] {
+			path = append([]string{n.Func.String()}, path...)
+		}
+		return path
+	}
+
+	nodesByPkg := map[*ssa.Package][]*callgraph.Node{}
+	for _, n := range nodes {
+		fn := n.Func
+		nodesByPkg[fn.Pkg] = append(nodesByPkg[fn.Pkg], n)
+
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				p, ok := instr.(*ssa.Panic)
+				if !ok {
+					continue
+				}
+				sites = append(sites, PanicSite{
+					Kind:     "panic",
+					Message:  panicMessage(p),
+					Location: prog.location(fn.Pkg.Pkg, p.Pos(), ""),
+					CallPath: callPath(n),
+				})
+			}
+		}
+	}
+
+	for pkg, pkgNodes := range nodesByPkg {
+		fset := prog.fsets[pkg.Pkg]
+		if fset == nil {
+			continue
+		}
+		funcs := make([]*ssa.Function, len(pkgNodes))
+		for i, n := range pkgNodes {
+			funcs[i] = n.Func
+		}
+		for _, diag := range runNilness(fset, pkg, funcs) {
+			n := enclosingNode(pkgNodes, diag.Pos)
+			if n == nil {
+				continue
+			}
+			sites = append(sites, PanicSite{
+				Kind:     "nilness",
+				Message:  diag.Message,
+				Location: prog.location(pkg.Pkg, diag.Pos, ""),
+				CallPath: callPath(n),
+			})
+		}
+	}
+
+	sort.Slice(sites, func(i, j int) bool {
+		si, sj := sites[i], sites[j]
+		if len(si.CallPath) != len(sj.CallPath) {
+			return len(si.CallPath) < len(sj.CallPath)
+		}
+		if si.Location.URI != sj.Location.URI {
+			return si.Location.URI < sj.Location.URI
+		}
+		return si.Location.Range.Start.Line < sj.Location.Range.Start.Line
+	})
+	return sites
+}
+
+// panicMessage describes the value of an explicit panic.
+func panicMessage(p *ssa.Panic) string {
+	x := p.X
+	if mi, ok := x.(*ssa.MakeInterface); ok {
+		x = mi.X
+	}
+	if c, ok := x.(*ssa.Const); ok && c.Value != nil && c.Value.Kind() == constant.String {
+		return "panic(" + c.Value.ExactString() + ")"
+	}
+	return "panic"
+}
+
+// runNilness runs the nilness analyzer on funcs of pkg.
+func runNilness(fset *token.FileSet, pkg *ssa.Package, funcs []*ssa.Function) []analysis.Diagnostic {
+	var diags []analysis.Diagnostic
+	pass := &analysis.Pass{
+		Analyzer: nilness.Analyzer,
+		Fset:     fset,
+		Pkg:      pkg.Pkg,
+		ResultOf: map[*analysis.Analyzer]interface{}{
+			buildssa.Analyzer: &buildssa.SSA{Pkg: pkg, SrcFuncs: funcs},
+		},
+		Report: func(diag analysis.Diagnostic) {
+			diags = append(diags, diag)
+		},
+	}
+	if _, err := nilness.Analyzer.Run(pass); err != nil {
+		return nil
+	}
+	return diags
+}
+
+// enclosingNode returns the innermost function of nodes whose syntax
+// contains pos.
+func enclosingNode(nodes []*callgraph.Node, pos token.Pos) *callgraph.Node {
+	var inner *callgraph.Node
+	for _, n := range nodes {
+		syntax := n.Func.Syntax()
+		if syntax == nil || pos < syntax.Pos() || pos >= syntax.End() {
+			continue
+		}
+		if inner == nil || syntax.Pos() > inner.Func.Syntax().Pos() {
+			inner = n
+		}
+	}
+	return inner
+}
@@ -0,0 +1,61 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPanicSites(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	prog, pkg := buildTestProgram(t, `package p
+
+type T struct{ f int }
+
+func handle(t *T) int {
+	check(t)
+	return deref(t) + at(nil, t.f)
+}
+
+func at(s []int, i int) int {
+	return s[i]
+}
+
+func check(t *T) {
+	if t == nil {
+		panic("nil T")
+	}
+}
+
+func deref(t *T) int {
+	if t == nil {
+		return t.f
+	}
+	return 0
+}
+
+func unreachable() {
+	panic("never")
+}
+`)
+
+	root := pkg.Func("handle")
+	cg, err := prog.callGraph("cha", root)
+	require.NoError(err)
+
+	// The index of at may be out of range, but it is not reported.
+	sites := prog.panicSites(cg.Nodes[root])
+	require.Len(sites, 2)
+
+	require.Equal("panic", sites[0].Kind)
+	require.Equal(`panic("nil T")`, sites[0].Message)
+	require.Equal(15, sites[0].Location.Range.Start.Line)
+	require.Equal([]string{"p.handle", "p.check"}, sites[0].CallPath)
+
+	require.Equal("nilness", sites[1].Kind)
+	require.Contains(sites[1].Message, "nil dereference")
+	require.Equal(21, sites[1].Location.Range.Start.Line)
+	require.Equal([]string{"p.handle", "p.deref"}, sites[1].CallPath)
+}
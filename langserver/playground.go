@@ -0,0 +1,261 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// playgroundShareCommand is the workspace/executeCommand command which
+// uploads a file to the Go Playground.
+const playgroundShareCommand = "bingo.playground.share"
+
+// defaultPlaygroundURL is the default of Config.PlaygroundURL.
+const defaultPlaygroundURL = "https://play.golang.org"
+
+// PlaygroundShareParams is the argument of the bingo.playground.share
+// command.
+type PlaygroundShareParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+
+	// Range selects the declarations to share. The whole file is shared
+	// if it is omitted.
+	Range *lsp.Range `json:"range,omitempty"`
+}
+
+func (h *LangHandler) handlePlaygroundShare(ctx context.Context, params PlaygroundShareParams) (string, error) {
+	var start lsp.Position
+	if params.Range != nil {
+		start = params.Range.Start
+	}
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, start)
+	if err != nil {
+		return "", err
+	}
+	filename, err := span.FromDocumentURI(params.TextDocument.URI).Filename()
+	if err != nil {
+		return "", err
+	}
+
+	local := newFrameworkPackage(pkg)
+	end := pos
+	if params.Range != nil {
+		end = fromProtocolPosition(local.fset.File(pos), params.Range.End)
+	}
+	var roots []ast.Decl
+	for _, f := range local.files {
+		if local.fset.Position(f.Pos()).Filename != filename {
+			continue
+		}
+		for _, decl := range f.Decls {
+			if params.Range == nil || decl.End() >= pos && decl.Pos() <= end {
+				roots = append(roots, decl)
+			}
+		}
+	}
+	if len(roots) == 0 {
+		return "", requestErrorf(InvalidPositionError, "no declaration to share")
+	}
+
+	src, err := flattenProgram(local, roots, func(filename string) ([]byte, error) {
+		f, err := h.View().GetFile(ctx, span.FileURI(filename))
+		if err != nil {
+			return nil, err
+		}
+		return f.GetContent(ctx), nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if h.config.Offline {
+		return "", requestErrorf(UnavailableError, "the Go Playground is not available in offline mode")
+	}
+	endpoint := h.config.PlaygroundURL
+	if endpoint == "" {
+		endpoint = defaultPlaygroundURL
+	}
+	return sharePlayground(ctx, endpoint, src)
+}
+
+// flattenProgram returns the single-file main program of the declarations
+// roots of pkg, and of the declarations of pkg they need. The program has a
+// main function, empty if none of the declarations is.
+func flattenProgram(pkg *frameworkPackage, roots []ast.Decl, content func(filename string) ([]byte, error)) ([]byte, error) {
+	// The package level declarations, and the methods, by object.
+	declOf := map[types.Object]ast.Decl{}
+	methods := map[types.Object][]ast.Decl{}
+	fileOf := map[ast.Decl]*ast.File{}
+	for _, f := range pkg.files {
+		for _, decl := range f.Decls {
+			fileOf[decl] = f
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				declOf[pkg.info.Defs[decl.Name]] = decl
+				if decl.Recv != nil && len(decl.Recv.List) == 1 {
+					if recv := receiverType(pkg.info, decl); recv != nil {
+						methods[recv] = append(methods[recv], decl)
+					}
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						declOf[pkg.info.Defs[spec.Name]] = decl
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							declOf[pkg.info.Defs[name]] = decl
+						}
+					}
+				}
+			}
+		}
+	}
+
+	included := map[ast.Decl]bool{}
+	imports := map[string]bool{}
+	queue := append([]ast.Decl(nil), roots...)
+	for len(queue) > 0 {
+		decl := queue[0]
+		queue = queue[1:]
+		// The import declarations are generated.
+		if gen, ok := decl.(*ast.GenDecl); included[decl] || fileOf[decl] == nil || ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		included[decl] = true
+		ast.Inspect(decl, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.TypeSpec:
+				queue = append(queue, methods[pkg.info.Defs[n.Name]]...)
+			case *ast.Ident:
+				obj := pkg.info.Uses[n]
+				if pkgName, ok := obj.(*types.PkgName); ok {
+					imports[importSpec(pkgName.Name(), pkgName.Imported())] = true
+				} else if d, ok := declOf[obj]; ok && obj != nil {
+					queue = append(queue, d)
+				}
+			}
+			return true
+		})
+	}
+
+	var decls []ast.Decl
+	for decl := range included {
+		decls = append(decls, decl)
+	}
+	sort.Slice(decls, func(i, j int) bool {
+		a, b := pkg.fset.Position(decls[i].Pos()), pkg.fset.Position(decls[j].Pos())
+		return a.Filename < b.Filename || a.Filename == b.Filename && a.Offset < b.Offset
+	})
+
+	var body bytes.Buffer
+	hasMain := false
+	files := map[*ast.File][]byte{}
+	for _, decl := range decls {
+		f := fileOf[decl]
+		if files[f] == nil {
+			filename := pkg.fset.Position(f.Pos()).Filename
+			text, err := content(filename)
+			if err != nil {
+				return nil, err
+			}
+			files[f] = text
+
+			// The blank and dot imports are not referenced by identifiers.
+			for _, spec := range f.Imports {
+				if spec.Name != nil && (spec.Name.Name == "_" || spec.Name.Name == ".") {
+					imports[spec.Name.Name+" "+spec.Path.Value] = true
+				}
+			}
+		}
+
+		start := decl.Pos()
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+			hasMain = hasMain || decl.Recv == nil && decl.Name.Name == "main"
+		case *ast.GenDecl:
+			if decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+		}
+		text := files[f]
+		from, to := pkg.fset.Position(start).Offset, pkg.fset.Position(decl.End()).Offset
+		if from < 0 || to > len(text) || from > to {
+			return nil, requestErrorf(StaleError, "%s has changed", pkg.fset.Position(start).Filename)
+		}
+		body.Write(text[from:to])
+		body.WriteString("\n\n")
+	}
+	if !hasMain {
+		body.WriteString("func main() {}\n")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("package main\n\n")
+	if len(imports) > 0 {
+		var specs []string
+		for spec := range imports {
+			specs = append(specs, spec)
+		}
+		sort.Strings(specs)
+		buf.WriteString("import (\n")
+		for _, spec := range specs {
+			buf.WriteString("\t" + spec + "\n")
+		}
+		buf.WriteString(")\n\n")
+	}
+	buf.Write(body.Bytes())
+	return format.Source(buf.Bytes())
+}
+
+// importSpec returns the import spec importing pkg as name.
+func importSpec(name string, pkg *types.Package) string {
+	if name == pkg.Name() {
+		return strconv.Quote(pkg.Path())
+	}
+	return name + " " + strconv.Quote(pkg.Path())
+}
+
+// sharePlayground uploads src to the Go Playground at endpoint, and returns
+// the URL of the shared program.
+func sharePlayground(ctx context.Context, endpoint string, src []byte) (string, error) {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	req, err := http.NewRequest(http.MethodPost, endpoint+"/share", bytes.NewReader(src))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("playground share failed: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	id := strings.TrimSpace(string(body))
+	if id == "" || strings.ContainsAny(id, "/ \n") {
+		return "", fmt.Errorf("invalid playground snippet id %q", id)
+	}
+	return endpoint + "/p/" + id, nil
+}
@@ -0,0 +1,124 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlattenProgram(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	sources := map[string]string{
+		"a.go": `package shapes
+
+import (
+	"fmt"
+	m "math"
+)
+
+// Area returns the area of s.
+func Area(s Shape) float64 { return s.Area() }
+
+func Describe(c Circle) string { return fmt.Sprintf("%.1f", Area(c)) }
+
+func unused() float64 { return m.Pi }
+`,
+		"b.go": `package shapes
+
+import (
+	_ "embed"
+	m "math"
+)
+
+type Shape interface{ Area() float64 }
+
+type Circle struct{ R float64 }
+
+func (c Circle) Area() float64 { return m.Pi * c.R * c.R }
+
+func (c Circle) String() string { return "circle" }
+
+type Square struct{ S float64 }
+`,
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range []string{"a.go", "b.go"} {
+		f, err := parser.ParseFile(fset, name, sources[name], parser.ParseComments)
+		require.NoError(err)
+		files = append(files, f)
+	}
+	info := &types.Info{
+		Defs: map[*ast.Ident]types.Object{},
+		Uses: map[*ast.Ident]types.Object{},
+	}
+	conf := types.Config{Importer: importer.Default()}
+	pkg, err := conf.Check("example.com/shapes", fset, files, info)
+	require.NoError(err)
+
+	local := &frameworkPackage{fset: fset, files: files, types: pkg, info: info}
+	src, err := flattenProgram(local, []ast.Decl{files[0].Decls[2]}, func(filename string) ([]byte, error) {
+		return []byte(sources[filename]), nil
+	})
+	require.NoError(err)
+	require.Equal(`package main
+
+import (
+	_ "embed"
+	"fmt"
+	m "math"
+)
+
+// Area returns the area of s.
+func Area(s Shape) float64 { return s.Area() }
+
+func Describe(c Circle) string { return fmt.Sprintf("%.1f", Area(c)) }
+
+type Shape interface{ Area() float64 }
+
+type Circle struct{ R float64 }
+
+func (c Circle) Area() float64 { return m.Pi * c.R * c.R }
+
+func (c Circle) String() string { return "circle" }
+
+func main() {}
+`, string(src))
+}
+
+func TestSharePlayground(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	var shared string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/share" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		shared = string(body)
+		w.Write([]byte("abc123\n"))
+	}))
+	defer server.Close()
+
+	url, err := sharePlayground(context.Background(), server.URL+"/", []byte("package main\n"))
+	require.NoError(err)
+	require.Equal(server.URL+"/p/abc123", url)
+	require.Equal("package main\n", shared)
+
+	_, err = sharePlayground(context.Background(), server.URL+"/missing", nil)
+	require.Error(err)
+}
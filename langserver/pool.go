@@ -0,0 +1,162 @@
+package langserver
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ProjectPool shares the projects of the handlers of a server which accepts
+// several connections, e.g. in tcp or websocket mode. The clients of the same
+// workspace, with the same build flags and environment, load and type-check
+// its packages once. They also share the contents of their open documents.
+type ProjectPool struct {
+	mu       sync.Mutex
+	projects map[string]*sharedProject
+}
+
+// sharedProject is a project of a pool and the connections of its clients.
+type sharedProject struct {
+	project *cache.Project
+	conns   *broadcast
+	refs    int
+
+	ready chan struct{} // closed once the project is initialized
+	err   error
+}
+
+// NewProjectPool returns an empty pool of projects.
+func NewProjectPool() *ProjectPool {
+	return &ProjectPool{projects: map[string]*sharedProject{}}
+}
+
+// acquire returns the project of the workspace rootPath, a function which
+// initializes it for the first client, or waits until it is initialized for
+// the others, and a function which detaches conn from the project. A new
+// project is set up by configure before it is initialized. The clients share
+// a project only if they have the same build flags, environment and settings,
+// which identify what configure applies to the whole project, e.g. the memory
+// limit or the load profile. The project notifies conn until conn is closed or
+// detached.
+func (p *ProjectPool) acquire(ctx context.Context, conn *jsonrpc2.Conn, rootPath string, buildFlags []string, env []string, settings string, style cache.CacheStyle, configure func(project *cache.Project)) (*cache.Project, func() error, func()) {
+	key := strings.Join([]string{rootPath, strings.Join(buildFlags, " "), strings.Join(env, "\n"), settings}, "\x00")
+
+	p.mu.Lock()
+	sp, ok := p.projects[key]
+	if !ok {
+		sp = &sharedProject{conns: &broadcast{}, ready: make(chan struct{})}
+		sp.project = cache.NewProject(ctx, sp.conns, rootPath, buildFlags, env)
+		configure(sp.project)
+		p.projects[key] = sp
+	}
+	sp.refs++
+	sp.conns.add(conn)
+	p.mu.Unlock()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() { p.release(key, sp, conn) })
+	}
+	go func() {
+		<-conn.DisconnectNotify()
+		release()
+	}()
+
+	return sp.project, func() error {
+		if !ok {
+			sp.err = sp.project.Init(ctx, style)
+			if sp.err != nil {
+				// The next client tries again.
+				p.mu.Lock()
+				if p.projects[key] == sp {
+					delete(p.projects, key)
+				}
+				p.mu.Unlock()
+			}
+			close(sp.ready)
+		}
+		<-sp.ready
+		return sp.err
+	}, release
+}
+
+// release detaches conn from the project, and closes the project and removes
+// it from the pool when it has no more clients.
+func (p *ProjectPool) release(key string, sp *sharedProject, conn *jsonrpc2.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sp.conns.remove(conn)
+	sp.refs--
+	if sp.refs > 0 {
+		return
+	}
+	if p.projects[key] == sp {
+		delete(p.projects, key)
+	}
+	sp.project.Close()
+}
+
+// broadcast is a jsonrpc2.JSONRPC2 which sends the notifications of a shared
+// project to all its clients.
+type broadcast struct {
+	mu    sync.Mutex
+	conns []*jsonrpc2.Conn
+}
+
+func (b *broadcast) add(conn *jsonrpc2.Conn) {
+	b.mu.Lock()
+	b.conns = append(b.conns, conn)
+	b.mu.Unlock()
+}
+
+func (b *broadcast) remove(conn *jsonrpc2.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, c := range b.conns {
+		if c == conn {
+			b.conns = append(b.conns[:i:i], b.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *broadcast) clients() []*jsonrpc2.Conn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.conns
+}
+
+// Call sends the request to the first client, because a project does not
+// expect several results.
+func (b *broadcast) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	conns := b.clients()
+	if len(conns) == 0 {
+		return jsonrpc2.ErrClosed
+	}
+	return conns[0].Call(ctx, method, params, result, opt...)
+}
+
+// Notify sends the notification to all the clients, except the progress,
+// which is sent to the first client, whose token it reports on.
+func (b *broadcast) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	conns := b.clients()
+	if method == "$/progress" && len(conns) > 1 {
+		conns = conns[:1]
+	}
+	var err error
+	for _, conn := range conns {
+		if e := conn.Notify(ctx, method, params, opt...); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Close does nothing: the connections are closed by their clients.
+func (b *broadcast) Close() error {
+	return nil
+}
@@ -0,0 +1,140 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+type notifications chan string
+
+func (n notifications) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var message string
+	_ = json.Unmarshal(*req.Params, &message)
+	n <- message
+}
+
+func TestProjectPool(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	ctx := context.Background()
+	connect := func() (*jsonrpc2.Conn, notifications) {
+		client, server := net.Pipe()
+		received := make(notifications, 1)
+		jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), received)
+		return jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}), nil), received
+	}
+
+	pool := NewProjectPool()
+	conn1, received1 := connect()
+	conn2, received2 := connect()
+	p1, _, _ := pool.acquire(ctx, conn1, "/work", nil, nil, "", "none", func(*cache.Project) {})
+	p2, _, _ := pool.acquire(ctx, conn2, "/work", nil, nil, "", "none", func(*cache.Project) {})
+	require.True(p1 == p2, "the clients of a workspace share its project")
+
+	conn3, _ := connect()
+	p3, _, _ := pool.acquire(ctx, conn3, "/work", []string{"-tags", "integration"}, nil, "", "none", func(*cache.Project) {})
+	require.False(p1 == p3, "the clients with other build flags share the project")
+
+	conn4, _ := connect()
+	p4, _, release4 := pool.acquire(ctx, conn4, "/work", nil, nil, "512 full", "none", func(*cache.Project) {})
+	require.False(p1 == p4, "the clients with other settings share the project")
+	release4()
+	release4()
+
+	sp := pool.projects["/work\x00\x00\x00"]
+	require.NoError(sp.conns.Notify(ctx, "window/logMessage", "loaded"))
+	require.Equal("loaded", <-received1)
+	require.Equal("loaded", <-received2)
+
+	projects := func() int {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.projects)
+	}
+	require.True(waitFor(func() bool { return projects() == 2 }), "the project of the released client was not removed")
+	conn1.Close()
+	require.True(waitFor(func() bool { return len(sp.conns.clients()) == 1 }), "the first client was not released")
+	conn3.Close()
+	require.True(waitFor(func() bool { return projects() == 1 }), "the project of the other build flags was not released")
+	conn2.Close()
+	require.True(waitFor(func() bool { return projects() == 0 }), "the project of the workspace was not released")
+}
+
+func TestPooledBuildFlags(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/flags\n",
+		"a.go":   "package flags\n",
+	})
+	uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go")))
+	pool := NewProjectPool()
+	connect := func() *TestContext {
+		tx := &TestContext{h: NewSharedHandler(testConfig(cache.None), pool), ctx: context.Background(), dir: root}
+		tx.initServer(t)
+		return tx
+	}
+	notify := func(tx *TestContext, method string, params interface{}) {
+		require.NoError(tx.conn.Notify(tx.ctx, method, params))
+		require.NoError(tx.conn.Call(tx.ctx, "bingo/health", nil, nil))
+	}
+	project := func(buildFlags string) *cache.Project {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		for key, sp := range pool.projects {
+			if strings.Split(key, "\x00")[1] == buildFlags {
+				return sp.project
+			}
+		}
+		return nil
+	}
+
+	tx1, tx2 := connect(), connect()
+	t.Cleanup(tx1.tearDown)
+	open := lsp.DidOpenTextDocumentParams{TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: "package flags\n\nvar A int\n"}}
+	notify(tx1, "textDocument/didOpen", open)
+	notify(tx2, "textDocument/didOpen", open)
+	shared := project("")
+	require.NotNil(shared)
+
+	// The build flags of a client do not change those of the project it
+	// shares: the client moves to a project with its build flags.
+	notify(tx1, "workspace/didChangeConfiguration", lsp.DidChangeConfigurationParams{Settings: map[string]interface{}{"bingo": map[string]interface{}{"buildTags": []string{"integration"}}}})
+	tagged := project("-tags integration")
+	require.NotNil(tagged, "the client did not move to a project with its build flags")
+	require.True(project("") == shared, "the project of the other client changed")
+	require.NotNil(tagged.Overlay().Get(span.FromDocumentURI(uri)), "the document of the client did not move with it")
+	require.NotNil(shared.Overlay().Get(span.FromDocumentURI(uri)), "the document of the other client was closed")
+
+	// The project of the previous build flags is closed with its last
+	// client.
+	notify(tx2, "textDocument/didClose", lsp.DidCloseTextDocumentParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}})
+	require.Nil(shared.Overlay().Get(span.FromDocumentURI(uri)))
+	tx2.tearDown()
+	require.True(waitFor(func() bool { return project("") == nil }), "the project of the previous build flags was not released")
+}
+
+// waitFor waits until cond is true, since the projects are released in the
+// background.
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,8 @@ package langserver
 import (
 	"go/token"
 	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/sourcegraph/go-lsp"
@@ -89,3 +91,35 @@ func lineStart(f *token.File, line int) token.Pos {
 		}
 	}
 }
+
+// applyTextEdits returns src edited by edits.
+func applyTextEdits(src string, edits []lsp.TextEdit) string {
+	lines := strings.SplitAfter(src, "\n")
+	size := len(src)
+	offset := func(pos lsp.Position) int {
+		if pos.Line >= len(lines) {
+			return size
+		}
+		n := 0
+		for _, line := range lines[:pos.Line] {
+			n += len(line)
+		}
+		if n += pos.Character; n > size {
+			return size
+		}
+		return n
+	}
+	indexes := make([]int, len(edits))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	// The edits inserting text at the same position apply in order.
+	sort.Slice(indexes, func(i, j int) bool {
+		a, b := offset(edits[indexes[i]].Range.Start), offset(edits[indexes[j]].Range.Start)
+		return a > b || a == b && indexes[i] > indexes[j]
+	})
+	for _, i := range indexes {
+		src = src[:offset(edits[i].Range.Start)] + edits[i].NewText + src[offset(edits[i].Range.End):]
+	}
+	return src
+}
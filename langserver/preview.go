@@ -0,0 +1,191 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/diff"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The workspace/executeCommand commands which preview a large edit of the
+// workspace, and apply the previewed edit.
+const (
+	previewEditCommand = "bingo.previewEdit"
+	applyEditCommand   = "bingo.applyEdit"
+)
+
+// maxRetainedEdits is the number of previewed edits which can be applied.
+const maxRetainedEdits = 8
+
+// previewContextLines is the number of lines of context of the hunks of a
+// preview.
+const previewContextLines = 3
+
+// PreviewEditParams is the argument of the bingo.previewEdit command, which
+// holds one of the edits.
+type PreviewEditParams struct {
+	// Rename renames an identifier, as textDocument/rename.
+	Rename *lsp.RenameParams `json:"rename,omitempty"`
+
+	// Rewrite rewrites the expressions of the workspace, as bingo.rewrite.
+	Rewrite *RewriteParams `json:"rewrite,omitempty"`
+}
+
+// EditPreview is the result of the bingo.previewEdit command.
+type EditPreview struct {
+	// ID identifies the edit for the bingo.applyEdit command.
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Files int    `json:"files"`
+	Edits int    `json:"edits"`
+
+	// Changes are the changes of the files, sorted by URI.
+	Changes []FileEditPreview `json:"changes"`
+}
+
+// FileEditPreview is the change of a file of an EditPreview.
+type FileEditPreview struct {
+	URI   lsp.DocumentURI `json:"uri"`
+	Edits int             `json:"edits"`
+
+	// Hunks are the hunks of the unified diff of the file.
+	Hunks []string `json:"hunks"`
+}
+
+// ApplyEditParams is the argument of the bingo.applyEdit command.
+type ApplyEditParams struct {
+	ID string `json:"id"`
+}
+
+// retainedEdit is a previewed edit, along with the content of the files it
+// was computed for.
+type retainedEdit struct {
+	id       string
+	label    string
+	edit     *protocol.WorkspaceEdit
+	contents map[string]string
+}
+
+// retainedEdits are the last previewed edits.
+type retainedEdits struct {
+	mu    sync.Mutex
+	seq   int
+	edits []*retainedEdit
+}
+
+// add retains e, dropping the oldest edit beyond maxRetainedEdits, and sets
+// its ID.
+func (r *retainedEdits) add(e *retainedEdit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	e.id = fmt.Sprint(r.seq)
+	r.edits = append(r.edits, e)
+	if len(r.edits) > maxRetainedEdits {
+		r.edits = append([]*retainedEdit(nil), r.edits[len(r.edits)-maxRetainedEdits:]...)
+	}
+}
+
+// take removes the edit id and returns it, or nil if it is not retained.
+func (r *retainedEdits) take(id string) *retainedEdit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.edits {
+		if e.id == id {
+			r.edits = append(r.edits[:i:i], r.edits[i+1:]...)
+			return e
+		}
+	}
+	return nil
+}
+
+func (h *LangHandler) handlePreviewEdit(ctx context.Context, conn jsonrpc2.JSONRPC2, params PreviewEditParams) (*EditPreview, error) {
+	var label string
+	var edit *protocol.WorkspaceEdit
+	switch {
+	case params.Rename != nil && params.Rewrite == nil:
+		renamed, err := h.handleRename(ctx, conn, nil, *params.Rename)
+		if err != nil {
+			return nil, err
+		}
+		label = "rename to " + params.Rename.NewName
+		edit = &protocol.WorkspaceEdit{Changes: renamed.Changes}
+
+	case params.Rewrite != nil && params.Rename == nil:
+		rewrite := *params.Rewrite
+		rewrite.Apply = false
+		rewritten, err := h.handleRewrite(ctx, conn, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		label = "rewrite " + rewrite.Rule
+		edit = rewritten
+
+	default:
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, "bingo.previewEdit expects either a rename or a rewrite")
+	}
+
+	retained := &retainedEdit{label: label, edit: edit, contents: map[string]string{}}
+	preview := &EditPreview{Label: label, Changes: []FileEditPreview{}}
+	for uri, edits := range edit.Changes {
+		content, err := h.documentContent(ctx, lsp.DocumentURI(uri))
+		if err != nil {
+			return nil, err
+		}
+		retained.contents[uri] = content
+		preview.Files++
+		preview.Edits += len(edits)
+		preview.Changes = append(preview.Changes, FileEditPreview{
+			URI:   lsp.DocumentURI(uri),
+			Edits: len(edits),
+			Hunks: diff.Hunks(strings.SplitAfter(content, "\n"), strings.SplitAfter(applyTextEdits(content, edits), "\n"), previewContextLines),
+		})
+	}
+	sort.Slice(preview.Changes, func(i, j int) bool {
+		return preview.Changes[i].URI < preview.Changes[j].URI
+	})
+
+	h.retainedEdits.add(retained)
+	preview.ID = retained.id
+	return preview, nil
+}
+
+func (h *LangHandler) handleApplyEdit(ctx context.Context, conn jsonrpc2.JSONRPC2, params ApplyEditParams) error {
+	retained := h.retainedEdits.take(params.ID)
+	if retained == nil {
+		return newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("no previewed edit %s", params.ID))
+	}
+
+	// The edit is applied as a whole, or not at all if a file has changed
+	// since the preview.
+	for uri, previewed := range retained.contents {
+		content, err := h.documentContent(ctx, lsp.DocumentURI(uri))
+		if err != nil {
+			return err
+		}
+		if content != previewed {
+			return requestErrorf(StaleError, "%s has changed since the preview of %s", uri, retained.label)
+		}
+	}
+	return applyEdit(ctx, conn, retained.label, retained.edit)
+}
+
+// documentContent returns the content of the document uri, which is edited
+// or on disk.
+func (h *LangHandler) documentContent(ctx context.Context, uri lsp.DocumentURI) (string, error) {
+	sourceURI, err := fromProtocolURI(uri)
+	if err != nil {
+		return "", err
+	}
+	f, err := h.View().GetFile(ctx, sourceURI)
+	if err != nil {
+		return "", err
+	}
+	return string(f.GetContent(ctx)), nil
+}
@@ -0,0 +1,43 @@
+package langserver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetainedEdits(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	var r retainedEdits
+	for i := 0; i < maxRetainedEdits+2; i++ {
+		r.add(&retainedEdit{label: fmt.Sprintf("edit %d", i)})
+	}
+	require.Len(r.edits, maxRetainedEdits)
+
+	// The oldest edits are dropped.
+	require.Nil(r.take("1"))
+	require.Nil(r.take("2"))
+	e := r.take("3")
+	require.NotNil(e)
+	require.Equal("edit 2", e.label)
+
+	// An edit is applied once.
+	require.Nil(r.take("3"))
+	require.Len(r.edits, maxRetainedEdits-1)
+}
+
+func TestApplyTextEdits(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	src := "package p\n\nvar x = 1\n"
+	edits := []lsp.TextEdit{
+		{Range: lsp.Range{Start: lsp.Position{Line: 2, Character: 4}, End: lsp.Position{Line: 2, Character: 5}}, NewText: "count"},
+		{Range: lsp.Range{Start: lsp.Position{Line: 3}, End: lsp.Position{Line: 3}}, NewText: "var y = x\n"},
+	}
+	require.Equal("package p\n\nvar count = 1\nvar y = x\n", applyTextEdits(src, edits))
+}
@@ -0,0 +1,43 @@
+package langserver
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// indexingProject is the project whose packages are loaded by the initialize
+// request, which holds the handler until they are.
+type indexingProject struct {
+	mu      sync.Mutex
+	project *cache.Project
+}
+
+func (p *indexingProject) set(project *cache.Project) {
+	p.mu.Lock()
+	p.project = project
+	p.mu.Unlock()
+}
+
+// cancel handles window/workDoneProgress/cancel notifications, which cancel
+// the loading of the packages of the project when they are for its progress.
+func (p *indexingProject) cancel(req *jsonrpc2.Request) error {
+	if req.Params == nil {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+	var params protocol.WorkDoneProgressCancelParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	project := p.project
+	p.mu.Unlock()
+	if project != nil {
+		project.CancelIndexing(params.Token)
+	}
+	return nil
+}
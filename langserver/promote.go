@@ -0,0 +1,597 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// promoteVariableCommand is the workspace/executeCommand command which
+// promotes a local variable to a field of the receiver of its method, or to a
+// parameter of its function.
+const promoteVariableCommand = "bingo.promoteVariable"
+
+// The targets of the bingo.promoteVariable command.
+const (
+	promoteToFieldTarget     = "field"
+	promoteToParameterTarget = "parameter"
+)
+
+// PromoteVariableParams is the argument of the bingo.promoteVariable command.
+// The position selects the local variable.
+type PromoteVariableParams struct {
+	lsp.TextDocumentPositionParams
+
+	// To is "field" to promote the variable to a field of the receiver of
+	// the method declaring it, or "parameter" to promote it to a parameter
+	// of the function declaring it.
+	To string `json:"to"`
+
+	// Apply applies the edit with workspace/applyEdit. Otherwise the edit is
+	// only returned, to be previewed.
+	Apply bool `json:"apply,omitempty"`
+}
+
+func (h *LangHandler) handlePromoteVariable(ctx context.Context, conn jsonrpc2.JSONRPC2, params PromoteVariableParams) (*protocol.WorkspaceEdit, error) {
+	if params.To != promoteToFieldTarget && params.To != promoteToParameterTarget {
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, fmt.Sprintf("cannot promote a variable to %q", params.To))
+	}
+
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		return nil, err
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, err
+	}
+	ident, ok := pathNodes[0].(*ast.Ident)
+	if !ok {
+		return nil, requestErrorf(InvalidPositionError, "not a variable")
+	}
+	v, ok := pkg.GetTypesInfo().ObjectOf(ident).(*types.Var)
+	if !ok || v.IsField() || v.Parent() == nil || v.Pkg() == nil || v.Parent() == v.Pkg().Scope() {
+		return nil, requestErrorf(InvalidPositionError, "%s is not a local variable", ident.Name)
+	}
+
+	local := newFrameworkPackage(pkg)
+	pv, err := findPromotedVariable(local, v)
+	if err != nil {
+		return nil, withCategory(RefusedError, err)
+	}
+
+	var edits fileEdits
+	if params.To == promoteToFieldTarget {
+		edits, err = promoteToField(local, pv)
+	} else {
+		var callers []*frameworkPackage
+		err = h.project.Search(func(p source.Package) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if p.GetTypesInfo() != nil {
+				callers = append(callers, newFrameworkPackage(p))
+			}
+			return nil
+		})
+		if err == nil {
+			edits, err = promoteToParameter(local, pv, callers)
+		}
+	}
+	if err != nil {
+		return nil, withCategory(RefusedError, err)
+	}
+
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{}}
+	for filename, textEdits := range edits {
+		uri := source.ToURI(filename)
+		if !h.project.Contain(lsp.DocumentURI(uri)) {
+			return nil, requestErrorf(RefusedError, "%s is not in the workspace", filename)
+		}
+		edit.Changes[string(uri)] = textEdits
+	}
+
+	if params.Apply {
+		if err := applyEdit(ctx, conn, "promote "+v.Name()+" to a "+params.To, edit); err != nil {
+			return nil, err
+		}
+	}
+	return edit, nil
+}
+
+// promotedVariable is a local variable to promote, with its declaration.
+type promotedVariable struct {
+	v    *types.Var
+	file *ast.File
+	decl *ast.FuncDecl
+
+	// stmt is the short variable declaration or the var declaration of v,
+	// one of the statements of block.
+	stmt  ast.Stmt
+	block ast.Node
+}
+
+// findPromotedVariable returns the declaration of the local variable v of
+// pkg.
+func findPromotedVariable(pkg *frameworkPackage, v *types.Var) (*promotedVariable, error) {
+	for _, f := range pkg.files {
+		if v.Pos() < f.Pos() || v.Pos() >= f.End() {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(f, v.Pos(), v.Pos()+token.Pos(len(v.Name())))
+		pv := &promotedVariable{v: v, file: f}
+		for _, n := range path {
+			if decl, ok := n.(*ast.FuncDecl); ok {
+				pv.decl = decl
+			}
+		}
+		if pv.decl == nil || len(path) < 5 {
+			return nil, fmt.Errorf("%s is not declared in a function", v.Name())
+		}
+
+		switch n := path[1].(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				return nil, fmt.Errorf("%s is not declared by a variable declaration", v.Name())
+			}
+			pv.stmt, pv.block = n, path[2]
+		case *ast.ValueSpec:
+			if len(n.Names) != 1 || len(path[2].(*ast.GenDecl).Specs) != 1 {
+				return nil, fmt.Errorf("%s is declared with other variables", v.Name())
+			}
+			pv.stmt, pv.block = path[3].(*ast.DeclStmt), path[4]
+		default:
+			return nil, fmt.Errorf("%s is not declared by a variable declaration", v.Name())
+		}
+
+		switch pv.block.(type) {
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			return pv, nil
+		}
+		return nil, fmt.Errorf("%s is declared in the header of a statement", v.Name())
+	}
+	return nil, fmt.Errorf("no declaration of %s", v.Name())
+}
+
+// value returns the initial value of the variable, or nil.
+func (pv *promotedVariable) value() ast.Expr {
+	switch stmt := pv.stmt.(type) {
+	case *ast.AssignStmt:
+		if len(stmt.Lhs) == len(stmt.Rhs) {
+			for i, lhs := range stmt.Lhs {
+				if lhs.Pos() == pv.v.Pos() {
+					return stmt.Rhs[i]
+				}
+			}
+		}
+	case *ast.DeclStmt:
+		spec := stmt.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+		if len(spec.Values) == 1 {
+			return spec.Values[0]
+		}
+	}
+	return nil
+}
+
+// deleteStmt adds to edits the edit which deletes the declaration of the
+// variable, with the line break preceding it.
+func (pv *promotedVariable) deleteStmt(edits fileEdits, fset *token.FileSet) {
+	var list []ast.Stmt
+	var from token.Pos
+	switch block := pv.block.(type) {
+	case *ast.BlockStmt:
+		list, from = block.List, block.Lbrace+1
+	case *ast.CaseClause:
+		list, from = block.Body, block.Colon+1
+	case *ast.CommClause:
+		list, from = block.Body, block.Colon+1
+	}
+	for _, stmt := range list {
+		if stmt == pv.stmt {
+			break
+		}
+		from = stmt.End()
+	}
+	edits.replace(fset, from, pv.stmt.End(), "")
+}
+
+// promoteToField returns the edits which promote the variable of pv to a
+// field of the receiver of its method. The receiver becomes a pointer, like
+// the receivers of the methods calling the method on their receiver. A
+// variable declared without a value is no longer reset by the method.
+func promoteToField(pkg *frameworkPackage, pv *promotedVariable) (fileEdits, error) {
+	name := pv.v.Name()
+	if pv.decl.Recv == nil || len(pv.decl.Recv.List) != 1 {
+		return nil, fmt.Errorf("%s is not declared in a method", name)
+	}
+	recvType := receiverType(pkg.info, pv.decl)
+	if recvType == nil || recvType.Pkg() != pkg.types {
+		return nil, fmt.Errorf("invalid receiver of %s", pv.decl.Name.Name)
+	}
+	structFile, st := structDecl(pkg, recvType)
+	if st == nil {
+		return nil, fmt.Errorf("%s is not declared as a struct type", recvType.Name())
+	}
+	if obj, _, _ := types.LookupFieldOrMethod(recvType.Type(), true, pkg.types, name); obj != nil {
+		return nil, fmt.Errorf("%s already has a field or method %s", recvType.Name(), name)
+	}
+	if named, ok := source.Deref(pv.v.Type()).(*types.Named); ok && named.Obj().Pkg() != nil && named.Obj().Parent() != named.Obj().Pkg().Scope() {
+		return nil, fmt.Errorf("the type of %s is declared in %s", name, pv.decl.Name.Name)
+	}
+
+	fset := pkg.fset
+	edits := fileEdits{}
+	importers := map[*ast.File]*fileImporter{}
+	importer := func(f *ast.File) *fileImporter {
+		if importers[f] == nil {
+			importers[f] = newFileImporter(pkg.types.Path(), f)
+		}
+		return importers[f]
+	}
+
+	// The receiver must be named to access the field.
+	field := pv.decl.Recv.List[0]
+	recv := receiverName(recvType)
+	if len(field.Names) == 1 && field.Names[0].Name != "_" {
+		recv = field.Names[0].Name
+	} else {
+		if declaresName(pv.decl, recv) {
+			return nil, fmt.Errorf("cannot name the receiver of %s %s", pv.decl.Name.Name, recv)
+		}
+		if len(field.Names) == 1 {
+			edits.replace(fset, field.Names[0].Pos(), field.Names[0].End(), recv)
+		} else {
+			edits.replace(fset, field.Type.Pos(), field.Type.Pos(), recv+" ")
+		}
+	}
+	for _, method := range mutatingMethods(pkg, recvType, pv.decl) {
+		if typ := method.Recv.List[0].Type; !isStarExpr(typ) {
+			edits.replace(fset, typ.Pos(), typ.Pos(), "*")
+		}
+	}
+
+	typ := types.TypeString(pv.v.Type(), importer(structFile).qualifier)
+	if len(st.Fields.List) == 0 {
+		edits.replace(fset, st.Fields.Opening+1, st.Fields.Closing, "\n\t"+name+" "+typ+"\n")
+	} else {
+		last := st.Fields.List[len(st.Fields.List)-1]
+		end := last.End()
+		if last.Comment != nil {
+			end = last.Comment.End()
+		}
+		edits.replace(fset, end, end, "\n\t"+name+" "+typ)
+	}
+
+	selector := recv + "." + name
+	replaced := map[*ast.Ident]bool{}
+	ast.Inspect(pv.decl.Body, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.AssignStmt)
+		if !ok || stmt.Tok != token.DEFINE {
+			return true
+		}
+		declares := false
+		for _, lhs := range stmt.Lhs {
+			if id, ok := lhs.(*ast.Ident); ok && pkg.info.ObjectOf(id) == pv.v {
+				declares = true
+			}
+		}
+		if !declares {
+			return true
+		}
+
+		// The short variable declaration becomes an assignment, after the
+		// declarations of its other new variables.
+		var lhs []string
+		var decls bytes.Buffer
+		indent := strings.Repeat("\t", fset.Position(stmt.Pos()).Column-1)
+		for _, expr := range stmt.Lhs {
+			id := expr.(*ast.Ident)
+			switch obj := pkg.info.Defs[id]; {
+			case pkg.info.ObjectOf(id) == pv.v:
+				lhs = append(lhs, selector)
+				replaced[id] = true
+			case obj != nil && id.Name != "_":
+				fmt.Fprintf(&decls, "var %s %s\n%s", id.Name, types.TypeString(obj.Type(), importer(pv.file).qualifier), indent)
+				lhs = append(lhs, id.Name)
+			default:
+				lhs = append(lhs, id.Name)
+			}
+		}
+		edits.replace(fset, stmt.Pos(), stmt.TokPos+token.Pos(len(token.DEFINE.String())), decls.String()+strings.Join(lhs, ", ")+" =")
+		return true
+	})
+
+	if stmt, ok := pv.stmt.(*ast.DeclStmt); ok {
+		if value := pv.value(); value != nil {
+			edits.replace(fset, stmt.Pos(), value.Pos(), selector+" = ")
+		} else {
+			pv.deleteStmt(edits, fset)
+		}
+	}
+	ast.Inspect(pv.decl.Body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && !replaced[id] && pkg.info.Uses[id] == pv.v {
+			edits.replace(fset, id.Pos(), id.End(), selector)
+		}
+		return true
+	})
+
+	for _, imp := range importers {
+		imp.addImports(edits, fset)
+	}
+	return edits, nil
+}
+
+// structDecl returns the file declaring the struct type obj of pkg, and its
+// struct type expression.
+func structDecl(pkg *frameworkPackage, obj *types.TypeName) (*ast.File, *ast.StructType) {
+	for _, f := range pkg.files {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				if pkg.info.Defs[spec.Name] != obj {
+					continue
+				}
+				st, _ := spec.Type.(*ast.StructType)
+				return f, st
+			}
+		}
+	}
+	return nil, nil
+}
+
+// mutatingMethods returns the method decl of the type obj and the methods
+// calling it, directly or not, on their receiver: their receivers must be
+// pointers for the changes of the fields to be visible to the callers.
+func mutatingMethods(pkg *frameworkPackage, obj *types.TypeName, decl *ast.FuncDecl) []*ast.FuncDecl {
+	var methods []*ast.FuncDecl
+	for _, f := range pkg.files {
+		for _, d := range f.Decls {
+			if d, ok := d.(*ast.FuncDecl); ok && d.Recv != nil && len(d.Recv.List) == 1 && d.Body != nil && receiverType(pkg.info, d) == obj {
+				methods = append(methods, d)
+			}
+		}
+	}
+
+	mutating := map[types.Object]bool{pkg.info.Defs[decl.Name]: true}
+	result := []*ast.FuncDecl{decl}
+	for changed := true; changed; {
+		changed = false
+		for _, m := range methods {
+			if mutating[pkg.info.Defs[m.Name]] || len(m.Recv.List[0].Names) != 1 {
+				continue
+			}
+			recv := pkg.info.Defs[m.Recv.List[0].Names[0]]
+			calls := false
+			ast.Inspect(m.Body, func(n ast.Node) bool {
+				if sel, ok := n.(*ast.SelectorExpr); ok {
+					if x, ok := sel.X.(*ast.Ident); ok && recv != nil && pkg.info.Uses[x] == recv && mutating[pkg.info.Uses[sel.Sel]] {
+						calls = true
+					}
+				}
+				return !calls
+			})
+			if calls {
+				mutating[pkg.info.Defs[m.Name]] = true
+				result = append(result, m)
+				changed = true
+			}
+		}
+	}
+	return result
+}
+
+// isStarExpr reports whether expr is a pointer type.
+func isStarExpr(expr ast.Expr) bool {
+	_, ok := expr.(*ast.StarExpr)
+	return ok
+}
+
+// declaresName reports whether an identifier of decl is name.
+func declaresName(decl *ast.FuncDecl, name string) bool {
+	found := false
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// promoteToParameter returns the edits which promote the variable of pv to
+// the last parameter of its function, before the variadic one, and pass its
+// initial value at the calls of the function by callers. The initial value
+// must be constant.
+func promoteToParameter(pkg *frameworkPackage, pv *promotedVariable, callers []*frameworkPackage) (fileEdits, error) {
+	name := pv.v.Name()
+	fnName := pv.decl.Name.Name
+	if pv.block != pv.decl.Body {
+		return nil, fmt.Errorf("%s is not declared in the top-level block of %s", name, fnName)
+	}
+	if stmt, ok := pv.stmt.(*ast.AssignStmt); ok && len(stmt.Lhs) != 1 {
+		return nil, fmt.Errorf("%s is declared with other variables", name)
+	}
+	fn, ok := pkg.info.Defs[pv.decl.Name].(*types.Func)
+	if !ok {
+		return nil, fmt.Errorf("invalid function %s", fnName)
+	}
+	var conflict bool
+	ast.Inspect(pv.decl, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name && pkg.info.ObjectOf(id) != pv.v {
+			conflict = true
+		}
+		return !conflict
+	})
+	if conflict {
+		return nil, fmt.Errorf("another %s is declared or used in %s", name, fnName)
+	}
+	params := pv.decl.Type.Params
+	if len(params.List) > 0 && len(params.List[0].Names) == 0 {
+		return nil, fmt.Errorf("the parameters of %s are not named", fnName)
+	}
+	sig := fn.Type().(*types.Signature)
+
+	// The argument is the initial value in the file declaring the variable,
+	// and its constant value in the other files.
+	filename := pkg.fset.Position(pv.decl.Pos()).Filename
+	value := pv.value()
+	var constValue constant.Value
+	if value != nil {
+		if constValue = pkg.info.Types[value].Value; constValue == nil {
+			return nil, fmt.Errorf("the initial value of %s is not constant", name)
+		}
+	}
+
+	fset := pkg.fset
+	edits := fileEdits{}
+	imp := newFileImporter(pkg.types.Path(), pv.file)
+	param := name + " " + types.TypeString(pv.v.Type(), imp.qualifier)
+	switch {
+	case sig.Variadic():
+		last := params.List[len(params.List)-1]
+		edits.replace(fset, last.Pos(), last.Pos(), param+", ")
+	case len(params.List) == 0:
+		edits.replace(fset, params.Closing, params.Closing, param)
+	default:
+		last := params.List[len(params.List)-1]
+		edits.replace(fset, last.End(), last.End(), ", "+param)
+	}
+	pv.deleteStmt(edits, fset)
+	imp.addImports(edits, fset)
+
+	index := sig.Params().Len()
+	if sig.Variadic() {
+		index--
+	}
+	// The package may be loaded with its tests, declaring another fn.
+	seen := map[string]bool{}
+	for _, caller := range append([]*frameworkPackage{pkg}, callers...) {
+		for _, f := range caller.files {
+			callerFile := caller.fset.Position(f.Pos()).Filename
+			if seen[callerFile] {
+				continue
+			}
+			seen[callerFile] = true
+
+			callerImp := imp
+			if callerFile != filename {
+				callerImp = newFileImporter(caller.types.Path(), f)
+			}
+			var arg string
+			switch {
+			case value == nil:
+				arg = zeroValue(pv.v.Type(), callerImp.qualifier)
+			case callerFile == filename:
+				var buf bytes.Buffer
+				printer.Fprint(&buf, fset, value)
+				arg = buf.String()
+			default:
+				arg, _ = constantText(constValue)
+			}
+
+			var err error
+			callees := map[*ast.Ident]bool{}
+			ast.Inspect(f, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || err != nil {
+					return err == nil
+				}
+				if callee := referencedObject(caller.info, call.Fun); callee == nil || !sameFunc(callee, fn) {
+					return true
+				}
+				switch fun := astutil.Unparen(call.Fun).(type) {
+				case *ast.Ident:
+					callees[fun] = true
+				case *ast.SelectorExpr:
+					callees[fun.Sel] = true
+				}
+				switch {
+				case arg == "":
+					err = fmt.Errorf("cannot write the initial value of %s in %s", name, callerFile)
+				case len(call.Args) > index:
+					edits.replace(caller.fset, call.Args[index].Pos(), call.Args[index].Pos(), arg+", ")
+				case len(call.Args) < index:
+					err = fmt.Errorf("cannot add an argument to the call of %s at %s", fnName, caller.fset.Position(call.Pos()))
+				case len(call.Args) == 0:
+					edits.replace(caller.fset, call.Rparen, call.Rparen, arg)
+				default:
+					last := call.Args[len(call.Args)-1]
+					edits.replace(caller.fset, last.End(), last.End(), ", "+arg)
+				}
+				return true
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			// The function must not be used as a value.
+			for id, obj := range caller.info.Uses {
+				if id.Pos() >= f.Pos() && id.Pos() < f.End() && !callees[id] && sameFunc(obj, fn) {
+					return nil, fmt.Errorf("%s is used as a value at %s", fnName, caller.fset.Position(id.Pos()))
+				}
+			}
+			if callerImp != imp {
+				callerImp.addImports(edits, caller.fset)
+			}
+		}
+	}
+	return edits, nil
+}
+
+// sameFunc reports whether x and y are the same function, possibly type
+// checked twice with the tests of its package.
+func sameFunc(x, y types.Object) bool {
+	if sameObj(x, y) {
+		return true
+	}
+	return x.Pkg() != nil && y.Pkg() != nil && x.Pkg().Path() == y.Pkg().Path() && x.Name() == y.Name() && x.Pos() == y.Pos()
+}
+
+// zeroValue returns the expression of the zero value of the type t.
+func zeroValue(t types.Type, qualifier types.Qualifier) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return types.TypeString(t, qualifier) + "{}"
+	}
+	return "nil"
+}
+
+// constantText returns the literal of the constant value v.
+func constantText(v constant.Value) (string, bool) {
+	switch v.Kind() {
+	case constant.Bool, constant.Int:
+		return v.ExactString(), true
+	case constant.String:
+		return strconv.Quote(constant.StringVal(v)), true
+	case constant.Float:
+		f, _ := constant.Float64Val(v)
+		return strconv.FormatFloat(f, 'g', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,112 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const promoteSrc = `package p
+
+import "strconv"
+
+type counter struct {
+	name string
+}
+
+func (c counter) Add(n int) int {
+	total := 0
+	total += n
+	return total
+}
+
+func (c counter) Twice(n int) int {
+	c.Add(n)
+	return c.Add(n)
+}
+
+func (counter) Reset() {
+	var last int
+	last = 1
+	_ = last
+}
+
+func (c *counter) Parse(s string) error {
+	v, err := strconv.Atoi(s)
+	_ = v
+	return err
+}
+
+func scale(x int) int {
+	factor := 3
+	return x * factor
+}
+
+func use() int {
+	return scale(2) + scale(scale(1))
+}
+`
+
+func TestPromoteVariable(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fset, f, pkg, info := checkStubbedPackage(t, promoteSrc, nil)
+	local := &frameworkPackage{fset: fset, files: []*ast.File{f}, types: pkg, info: info}
+
+	variable := func(fn, name string) *promotedVariable {
+		for id, obj := range info.Defs {
+			v, ok := obj.(*types.Var)
+			if !ok || id.Name != name {
+				continue
+			}
+			for _, decl := range f.Decls {
+				if decl, ok := decl.(*ast.FuncDecl); ok && decl.Name.Name == fn && decl.Pos() <= id.Pos() && id.Pos() < decl.End() {
+					pv, err := findPromotedVariable(local, v)
+					require.NoError(err)
+					return pv
+				}
+			}
+		}
+		require.FailNow("no variable " + name)
+		return nil
+	}
+	promote := func(fn, name, to string) string {
+		pv := variable(fn, name)
+		var edits fileEdits
+		var err error
+		if to == promoteToFieldTarget {
+			edits, err = promoteToField(local, pv)
+		} else {
+			edits, err = promoteToParameter(local, pv, nil)
+		}
+		require.NoError(err)
+		require.Len(edits, 1)
+		return applyTextEdits(promoteSrc, edits["p.go"])
+	}
+
+	src := promote("Add", "total", promoteToFieldTarget)
+	require.Contains(src, "type counter struct {\n\tname string\n\ttotal int\n}")
+	require.Contains(src, "func (c *counter) Add(n int) int {\n\tc.total = 0\n\tc.total += n\n\treturn c.total\n}")
+	require.Contains(src, "func (c *counter) Twice(n int) int {")
+	require.Contains(src, "func (counter) Reset() {")
+
+	src = promote("Reset", "last", promoteToFieldTarget)
+	require.Contains(src, "func (c *counter) Reset() {\n\tc.last = 1\n\t_ = c.last\n}")
+	require.Contains(src, "func (c counter) Add(n int) int {")
+
+	src = promote("Parse", "v", promoteToFieldTarget)
+	require.Contains(src, "\tname string\n\tv int\n}")
+	require.Contains(src, "\tvar err error\n\tc.v, err = strconv.Atoi(s)\n\t_ = c.v\n")
+
+	src = promote("scale", "factor", promoteToParameterTarget)
+	require.Contains(src, "func scale(x int, factor int) int {\n\treturn x * factor\n}")
+	require.Contains(src, "return scale(2, 3) + scale(scale(1, 3), 3)")
+
+	_, err := promoteToField(local, variable("scale", "factor"))
+	require.EqualError(err, "factor is not declared in a method")
+	_, err = promoteToParameter(local, variable("Parse", "v"), nil)
+	require.EqualError(err, "v is declared with other variables")
+}
@@ -0,0 +1,214 @@
+package langserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// refreshDelay is how long a workspace/diagnostic/refresh request waits for
+// the other diagnostics computed along with it, eg. those of the dependents
+// of a saved document.
+const refreshDelay = 100 * time.Millisecond
+
+// workspaceDiagnosticWait bounds how long a workspace/diagnostic request
+// whose reports are all unchanged waits for a document to change before it
+// is answered, so that the clients which pull the workspace diagnostics
+// again once answered do not pull them in a loop.
+const workspaceDiagnosticWait = 30 * time.Second
+
+// diagnosticsPull is the pull model of the diagnostics of LSP 3.17, for the
+// clients which request the diagnostics of the documents with
+// textDocument/diagnostic and workspace/diagnostic on their own schedule,
+// instead of receiving every set of diagnostics computed during a bulk edit
+// with textDocument/publishDiagnostics.
+type diagnosticsPull struct {
+	// refreshSupport is set if the client supports the
+	// workspace/diagnostic/refresh request, which asks it to pull the
+	// diagnostics again, eg. once the dependents of a saved document are
+	// diagnosed.
+	refreshSupport bool
+
+	mu           sync.Mutex
+	change       chan struct{} // closed and replaced when a document changes
+	refreshTimer *time.Timer
+}
+
+// newDiagnosticsPull returns the pull model of the diagnostics, or nil if
+// the client does not pull them.
+func newDiagnosticsPull(caps ClientCapabilities) *diagnosticsPull {
+	if caps.TextDocument.Diagnostic == nil {
+		return nil
+	}
+	return &diagnosticsPull{
+		refreshSupport: caps.Workspace.Diagnostics.RefreshSupport,
+		change:         make(chan struct{}),
+	}
+}
+
+// changed wakes up the workspace/diagnostic requests waiting for a change.
+func (p *diagnosticsPull) changed() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	close(p.change)
+	p.change = make(chan struct{})
+}
+
+// changes returns the channel closed once a document changes.
+func (p *diagnosticsPull) changes() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.change
+}
+
+// refresh asks the client to pull the diagnostics again, once those computed
+// along with the current ones are computed too.
+func (p *diagnosticsPull) refresh(conn jsonrpc2.JSONRPC2) {
+	p.changed()
+	if !p.refreshSupport {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.refreshTimer != nil {
+		p.refreshTimer.Reset(refreshDelay)
+		return
+	}
+	p.refreshTimer = time.AfterFunc(refreshDelay, func() {
+		p.mu.Lock()
+		p.refreshTimer = nil
+		p.mu.Unlock()
+		_ = conn.Call(context.Background(), "workspace/diagnostic/refresh", nil, nil)
+	})
+}
+
+// resultID returns the result ID of a set of diagnostics, which only depends
+// on the diagnostics, so that the unchanged ones are recognized whatever
+// the edits which led to them.
+func resultID(diagnostics []lsp.Diagnostic) string {
+	data, _ := json.Marshal(diagnostics)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// documentReport returns the unchanged report of the diagnostics of the
+// document uri if their result ID is previousResultID, or their full
+// report, which is added to the history of the document.
+func (h *overlay) documentReport(uri lsp.DocumentURI, diagnostics []lsp.Diagnostic, previousResultID string) interface{} {
+	id := resultID(diagnostics)
+	if id == previousResultID {
+		return protocol.UnchangedDocumentDiagnosticReport{Kind: protocol.UnchangedReport, ResultID: id}
+	}
+	if diagnostics == nil {
+		diagnostics = []lsp.Diagnostic{}
+	}
+	h.history.record(uri, h.version(uri), diagnostics)
+	return protocol.FullDocumentDiagnosticReport{Kind: protocol.FullReport, ResultID: id, Items: diagnostics}
+}
+
+// documentReports returns the diagnostics of the files of the packages of
+// the documents uris, by URI. The documents of a package are diagnosed
+// once.
+func (h *overlay) documentReports(ctx context.Context, uris []span.URI) (map[lsp.DocumentURI][]lsp.Diagnostic, error) {
+	result := map[lsp.DocumentURI][]lsp.Diagnostic{}
+	for _, uri := range uris {
+		if _, ok := result[lsp.DocumentURI(uri)]; ok {
+			continue
+		}
+		f, err := h.view().GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		reports, err := h.reports(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		for filename, diagnostics := range reports {
+			result[lsp.DocumentURI(source.ToURI(filename))] = diagnostics
+		}
+		if _, ok := result[lsp.DocumentURI(uri)]; !ok {
+			result[lsp.DocumentURI(uri)] = nil
+		}
+	}
+	return result, nil
+}
+
+// openDocuments returns the documents open in the client.
+func (h *overlay) openDocuments() []span.URI {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	uris := make([]span.URI, 0, len(h.open))
+	for uri := range h.open {
+		uris = append(uris, uri)
+	}
+	return uris
+}
+
+func (h *LangHandler) handleDocumentDiagnostic(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.DocumentDiagnosticParams) (interface{}, error) {
+	uri := span.FromDocumentURI(params.TextDocument.URI)
+	reports, err := h.overlay.documentReports(ctx, []span.URI{uri})
+	if err != nil {
+		return nil, err
+	}
+	return h.overlay.documentReport(params.TextDocument.URI, reports[lsp.DocumentURI(uri)], params.PreviousResultID), nil
+}
+
+// handleWorkspaceDiagnostic reports the diagnostics of the files of the
+// packages of the documents open in the client. If they are all unchanged,
+// it waits for a document to change first, for at most
+// workspaceDiagnosticWait.
+func (h *LangHandler) handleWorkspaceDiagnostic(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.WorkspaceDiagnosticParams) (*protocol.WorkspaceDiagnosticReport, error) {
+	previous := map[lsp.DocumentURI]string{}
+	for _, id := range params.PreviousResultIds {
+		previous[id.URI] = id.Value
+	}
+
+	timeout := time.NewTimer(workspaceDiagnosticWait)
+	defer timeout.Stop()
+	for {
+		var changes <-chan struct{}
+		if h.overlay.pull != nil {
+			changes = h.overlay.pull.changes()
+		}
+		reports, err := h.overlay.documentReports(ctx, h.overlay.openDocuments())
+		if err != nil {
+			return nil, err
+		}
+
+		result := &protocol.WorkspaceDiagnosticReport{Items: []interface{}{}}
+		changed := false
+		for uri, diagnostics := range reports {
+			var version *int
+			if v := h.overlay.version(uri); v != 0 {
+				version = &v
+			}
+			switch report := h.overlay.documentReport(uri, diagnostics, previous[uri]).(type) {
+			case protocol.FullDocumentDiagnosticReport:
+				changed = true
+				result.Items = append(result.Items, protocol.WorkspaceFullDocumentDiagnosticReport{FullDocumentDiagnosticReport: report, URI: uri, Version: version})
+			case protocol.UnchangedDocumentDiagnosticReport:
+				result.Items = append(result.Items, protocol.WorkspaceUnchangedDocumentDiagnosticReport{UnchangedDocumentDiagnosticReport: report, URI: uri, Version: version})
+			}
+		}
+		if changed || changes == nil {
+			return result, nil
+		}
+
+		select {
+		case <-changes:
+		case <-timeout.C:
+			return result, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
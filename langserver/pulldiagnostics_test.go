@@ -0,0 +1,136 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+// documentReport is a full or an unchanged diagnostic report, as decoded by
+// a client.
+type documentReport struct {
+	Kind     protocol.DocumentDiagnosticReportKind `json:"kind"`
+	ResultID string                                `json:"resultId"`
+	Items    []lsp.Diagnostic                      `json:"items"`
+	URI      lsp.DocumentURI                       `json:"uri"`
+}
+
+func TestResultID(t *testing.T) {
+	a := []lsp.Diagnostic{{Message: "a"}}
+	if resultID(a) != resultID([]lsp.Diagnostic{{Message: "a"}}) {
+		t.Error("got different result IDs for the same diagnostics")
+	}
+	if resultID(a) == resultID([]lsp.Diagnostic{{Message: "b"}}) {
+		t.Error("got the same result ID for different diagnostics")
+	}
+}
+
+func TestPullDiagnostics(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	broken := "package client\n\nfunc F() int {\n\treturn \"\"\n}\n"
+	fixed := "package client\n\nfunc F() int {\n\treturn 0\n}\n"
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/client\n",
+		"a.go":   broken,
+	})
+
+	cfg := testConfig(cache.Ondemand)
+	cfg.DiagnosticsStyle = string(instantDiagnostics)
+	caps := ClientCapabilities{}
+	caps.TextDocument.Diagnostic = &struct {
+		DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+	}{}
+	tx := &TestContext{h: NewHandler(cfg), ctx: context.Background(), dir: root, capabilities: &caps}
+	tx.initServer(t)
+	t.Cleanup(tx.tearDown)
+	uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go")))
+
+	change := func(version int, text string) {
+		require.NoError(tx.conn.Notify(tx.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+			TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: version},
+			ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: text}},
+		}))
+	}
+	pull := func(previousResultID string) documentReport {
+		var report documentReport
+		require.NoError(tx.conn.Call(tx.ctx, "textDocument/diagnostic", protocol.DocumentDiagnosticParams{
+			TextDocument:     lsp.TextDocumentIdentifier{URI: uri},
+			PreviousResultID: previousResultID,
+		}, &report))
+		return report
+	}
+
+	require.NoError(tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: broken},
+	}))
+	report := pull("")
+	require.Equal(protocol.FullReport, report.Kind)
+	require.Len(report.Items, 1)
+	require.Equal(3, report.Items[0].Range.Start.Line)
+
+	// The unchanged diagnostics are not sent again.
+	unchanged := pull(report.ResultID)
+	require.Equal(protocol.UnchangedReport, unchanged.Kind)
+	require.Equal(report.ResultID, unchanged.ResultID)
+
+	change(2, fixed)
+	report = pull(report.ResultID)
+	require.Equal(protocol.FullReport, report.Kind)
+	require.Empty(report.Items)
+	require.NotNil(report.Items)
+
+	// A workspace pull whose reports are unchanged waits for a change.
+	type workspaceResult struct {
+		report struct {
+			Items []documentReport `json:"items"`
+		}
+		err error
+	}
+	done := make(chan workspaceResult, 1)
+	go func() {
+		var result workspaceResult
+		result.err = tx.conn.Call(tx.ctx, "workspace/diagnostic", protocol.WorkspaceDiagnosticParams{
+			PreviousResultIds: []protocol.PreviousResultID{{URI: uri, Value: report.ResultID}},
+		}, &result.report)
+		done <- result
+	}()
+	select {
+	case <-done:
+		t.Fatal("the workspace diagnostics were answered although they did not change")
+	case <-time.After(200 * time.Millisecond):
+	}
+	change(3, broken)
+	select {
+	case result := <-done:
+		require.NoError(result.err)
+		require.Len(result.report.Items, 1)
+		require.Equal(uri, result.report.Items[0].URI)
+		require.Equal(protocol.FullReport, result.report.Items[0].Kind)
+		require.Len(result.report.Items[0].Items, 1)
+	case <-time.After(awaitTimeout):
+		t.Fatal("the workspace diagnostics were not answered after a change")
+	}
+
+	// The diagnostics are pulled, not pushed.
+	tx.client.mu.Lock()
+	defer tx.client.mu.Unlock()
+	for _, req := range tx.client.notifications {
+		if req.Method == "textDocument/publishDiagnostics" {
+			var params json.RawMessage
+			if req.Params != nil {
+				params = *req.Params
+			}
+			t.Errorf("got textDocument/publishDiagnostics %s, want none", params)
+		}
+	}
+}
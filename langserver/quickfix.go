@@ -0,0 +1,184 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// unusedVariableRegexp matches the messages of the unused variables of the
+// older and the newer versions of go/types.
+var unusedVariableRegexp = regexp.MustCompile(`^(?:(\w+) declared (?:but|and) not used|declared and not used: (\w+))$`)
+
+// importActions returns the quick fixes which import the packages of the
+// undeclared names reported by diagnostics, found by goimports.
+func (h *LangHandler) importActions(ctx context.Context, uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) []protocol.CodeAction {
+	var f source.File
+	var actions []protocol.CodeAction
+	for _, d := range diagnostics {
+		m := undefinedNameRegexp.FindStringSubmatch(d.Message)
+		if d.Code != typeErrorCode || m == nil {
+			continue
+		}
+		if f == nil {
+			var err error
+			if f, err = h.View().GetFile(ctx, span.FromDocumentURI(uri)); err != nil {
+				return nil
+			}
+		}
+		path, edits, err := source.AddImport(ctx, f, m[1])
+		if err != nil {
+			continue
+		}
+		actions = append(actions, protocol.CodeAction{
+			Title:       fmt.Sprintf("Add import %q", path),
+			Kind:        protocol.QuickFix,
+			Diagnostics: []lsp.Diagnostic{d},
+			Edit: lsp.WorkspaceEdit{
+				Changes: map[string][]lsp.TextEdit{
+					string(uri): toProtocolEdits(ctx, f, edits),
+				},
+			},
+		})
+	}
+	return actions
+}
+
+// unusedVariableActions returns the quick fixes which remove the unused
+// variables reported by diagnostics.
+func (h *LangHandler) unusedVariableActions(ctx context.Context, uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) []protocol.CodeAction {
+	var actions []protocol.CodeAction
+	for _, d := range diagnostics {
+		if d.Code != unusedVariableCode || !unusedVariableRegexp.MatchString(d.Message) {
+			continue
+		}
+		pkg, pos, err := h.typeCheck(ctx, uri, d.Range.Start)
+		if err != nil {
+			continue
+		}
+		pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+		if err != nil {
+			continue
+		}
+		edits := unusedVariableEdits(pkg.GetFileSet(), pkg.GetTypesInfo(), pathNodes)
+		if len(edits) == 0 {
+			continue
+		}
+		actions = append(actions, protocol.CodeAction{
+			Title:       "Remove unused variable " + pathNodes[0].(*ast.Ident).Name,
+			Kind:        protocol.QuickFix,
+			Diagnostics: []lsp.Diagnostic{d},
+			Edit: lsp.WorkspaceEdit{
+				Changes: map[string][]lsp.TextEdit{
+					string(uri): edits,
+				},
+			},
+		})
+	}
+	return actions
+}
+
+// unusedVariableEdits returns the edits which remove the unused variable
+// path[0]. Its declaration is removed unless it has side effects, or declares
+// other variables, in which case the variable is replaced with a blank
+// identifier.
+func unusedVariableEdits(fset *token.FileSet, info *types.Info, path []ast.Node) []lsp.TextEdit {
+	if len(path) < 2 {
+		return nil
+	}
+	ident, ok := path[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	blank := lsp.TextEdit{Range: rangeForNode(fset, ident), NewText: "_"}
+
+	switch parent := path[1].(type) {
+	case *ast.AssignStmt:
+		if parent.Tok != token.DEFINE {
+			return nil
+		}
+		if len(parent.Lhs) == 1 {
+			if !hasSideEffects(parent.Rhs) {
+				return []lsp.TextEdit{deleteLinesEdit(fset, parent)}
+			}
+			// x := f() becomes _ = f().
+			return []lsp.TextEdit{{
+				Range:   rangeForNode(fset, fakeNode{p: ident.Pos(), e: parent.TokPos + token.Pos(len(token.DEFINE.String()))}),
+				NewText: "_ =",
+			}}
+		}
+		edits := []lsp.TextEdit{blank}
+		for _, lhs := range parent.Lhs {
+			if id, ok := lhs.(*ast.Ident); ok && id != ident && id.Name != "_" && info.Defs[id] != nil {
+				return edits
+			}
+		}
+		// No new variable is left on the left side of :=.
+		return append(edits, lsp.TextEdit{
+			Range:   rangeForNode(fset, fakeNode{p: parent.TokPos, e: parent.TokPos + token.Pos(len(token.DEFINE.String()))}),
+			NewText: "=",
+		})
+
+	case *ast.ValueSpec:
+		if len(path) < 4 {
+			return nil
+		}
+		gen, ok := path[2].(*ast.GenDecl)
+		if !ok || len(parent.Names) > 1 || len(gen.Specs) > 1 || hasSideEffects(parent.Values) {
+			return []lsp.TextEdit{blank}
+		}
+		if stmt, ok := path[3].(*ast.DeclStmt); ok {
+			return []lsp.TextEdit{deleteLinesEdit(fset, stmt)}
+		}
+		return []lsp.TextEdit{blank}
+
+	case *ast.RangeStmt:
+		switch {
+		case ident == parent.Value:
+			// for k, v := range x becomes for k := range x.
+			return []lsp.TextEdit{{Range: rangeForNode(fset, fakeNode{p: parent.Key.End(), e: ident.End()})}}
+		case ident == parent.Key && parent.Value == nil && parent.Tok == token.DEFINE:
+			// for k := range x becomes for range x.
+			return []lsp.TextEdit{{Range: rangeForNode(fset, fakeNode{p: ident.Pos(), e: parent.TokPos + token.Pos(len(":= "))})}}
+		case ident == parent.Key:
+			return []lsp.TextEdit{blank}
+		}
+	}
+	return nil
+}
+
+// deleteLinesEdit returns the edit which deletes the lines of stmt.
+func deleteLinesEdit(fset *token.FileSet, stmt ast.Stmt) lsp.TextEdit {
+	start, end := fset.Position(stmt.Pos()), fset.Position(stmt.End())
+	return lsp.TextEdit{Range: lsp.Range{
+		Start: lsp.Position{Line: start.Line - 1},
+		End:   lsp.Position{Line: end.Line},
+	}}
+}
+
+// hasSideEffects reports whether the evaluation of exprs may have side
+// effects: they call a function or receive from a channel. Conversions are
+// conservatively considered calls.
+func hasSideEffects(exprs []ast.Expr) bool {
+	effects := false
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				effects = true
+			case *ast.UnaryExpr:
+				effects = effects || n.Op == token.ARROW
+			}
+			return !effects
+		})
+	}
+	return effects
+}
@@ -0,0 +1,77 @@
+package langserver
+
+import (
+	"go/ast"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func TestUnusedVariableEdits(t *testing.T) {
+	t.Parallel()
+
+	const src = `package p
+
+func f(xs []int, c chan int) {
+	a := 1
+	b := g()
+	d, e := 1, 2
+	_, h := 1, 2
+	var i int
+	var j, k = 1, 2
+	for l, m := range xs {
+		_ = l
+	}
+	for n := range xs {
+	}
+	o := <-c
+	_, _ = e, k
+}
+
+func g() int { return 0 }
+`
+	pkg := checkBrokenSource(t, src)
+	fset, f, info := pkg.Fset, pkg.File, pkg.Info
+
+	lines := strings.Split(src, "\n")
+	apply := func(edits []lsp.TextEdit) string {
+		// The edits of a variable are on a single line, or delete lines.
+		if len(edits) == 1 && edits[0].Range.Start.Character == 0 && edits[0].Range.End.Character == 0 && edits[0].Range.End.Line > edits[0].Range.Start.Line {
+			return "<deleted>"
+		}
+		line := lines[edits[0].Range.Start.Line]
+		for i := len(edits) - 1; i >= 0; i-- {
+			e := edits[i]
+			line = line[:e.Range.Start.Character] + e.NewText + line[e.Range.End.Character:]
+		}
+		return strings.TrimSpace(line)
+	}
+
+	tests := map[string]string{
+		"a": "<deleted>",
+		"b": "_ = g()",
+		"d": "_, e := 1, 2",
+		"h": "_, _ = 1, 2",
+		"i": "<deleted>",
+		"j": "var _, k = 1, 2",
+		"m": "for l := range xs {",
+		"n": "for range xs {",
+		"o": "_ = <-c",
+	}
+	for name, want := range tests {
+		var ident *ast.Ident
+		for id := range info.Defs {
+			if id.Name == name {
+				ident = id
+			}
+		}
+		require.NotNil(t, ident, name)
+		path, _ := astutil.PathEnclosingInterval(f, ident.Pos(), ident.Pos())
+		edits := unusedVariableEdits(fset, info, path)
+		require.NotEmpty(t, edits, name)
+		require.Equal(t, want, apply(edits), name)
+	}
+}
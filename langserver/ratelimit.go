@@ -0,0 +1,174 @@
+package langserver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// coalesceWindow is how long the result of a request is shared with
+// identical requests after it completed.
+const coalesceWindow = 100 * time.Millisecond
+
+// codeRequestCancelled is the LSP error code for a cancelled request.
+const codeRequestCancelled = -32800
+
+// rateLimitedMethods are the methods which clients tend to spam, typically
+// several times per keystroke or mouse move.
+var rateLimitedMethods = map[string]bool{
+	"textDocument/hover":          true,
+	"textDocument/completion":     true,
+	"textDocument/signatureHelp":  true,
+	"textDocument/definition":     true,
+	"textDocument/typeDefinition": true,
+}
+
+// limiter protects the typechecker from misbehaving clients. Identical
+// requests of a rate limited method share a single computation, and requests
+// which exceed the per method rate are rejected.
+type limiter struct {
+	mu      sync.Mutex
+	rate    int // requests per second and method, 0 means unlimited
+	buckets map[string]*bucket
+	calls   map[string]*call
+}
+
+// bucket is a token bucket refilled at the limiter rate.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// call is an in-flight or recently completed request.
+type call struct {
+	done   chan struct{}
+	result interface{}
+	err    error
+	expiry time.Time // zero until done is closed
+
+	// canceled reports whether the request was canceled, in which case its
+	// result is not shared.
+	canceled bool
+}
+
+func newLimiter(rate int) *limiter {
+	return &limiter{
+		rate:    rate,
+		buckets: make(map[string]*bucket),
+		calls:   make(map[string]*call),
+	}
+}
+
+// do calls fn for req, unless an identical request is in-flight or completed
+// within the coalesce window, in which case its result is returned instead.
+func (l *limiter) do(ctx context.Context, req *jsonrpc2.Request, fn func() (interface{}, error)) (interface{}, error) {
+	if isFileSystemRequest(req.Method) {
+		// The document changed, so none of the results can be shared anymore.
+		l.mu.Lock()
+		l.calls = make(map[string]*call)
+		l.mu.Unlock()
+		return fn()
+	}
+
+	if !rateLimitedMethods[req.Method] || req.Params == nil {
+		return fn()
+	}
+
+	key := req.Method + string(*req.Params)
+	now := time.Now()
+
+	l.mu.Lock()
+	if c, ok := l.calls[key]; ok && (c.expiry.IsZero() || now.Before(c.expiry)) {
+		l.mu.Unlock()
+		select {
+		case <-c.done:
+			if !c.canceled {
+				return c.result, c.err
+			}
+			// The cancellation of the request computing the result is
+			// not the one of req.
+			return fn()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if !l.allow(req.Method, now) {
+		l.mu.Unlock()
+		return nil, requestErrorf(RateLimitedError, "rate limit exceeded for %s", req.Method)
+	}
+
+	c := &call{done: make(chan struct{})}
+	l.calls[key] = c
+	l.mu.Unlock()
+
+	c.result, c.err = fn()
+
+	l.mu.Lock()
+	if isCanceled(ctx, c.err) {
+		c.canceled = true
+		if l.calls[key] == c {
+			delete(l.calls, key)
+		}
+	} else {
+		c.expiry = time.Now().Add(coalesceWindow)
+	}
+	l.expire(now)
+	l.mu.Unlock()
+	close(c.done)
+
+	return c.result, c.err
+}
+
+// isCanceled reports whether err, the error of a request whose context is
+// ctx, is a cancellation of the request.
+func isCanceled(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return true
+	}
+	switch err := err.(type) {
+	case *jsonrpc2.Error:
+		return err.Code == codeRequestCancelled
+	case *requestError:
+		return err.category == CanceledError
+	}
+	return false
+}
+
+// allow takes a token from the bucket of method. It assumes that the caller
+// holds l.mu.
+func (l *limiter) allow(method string, now time.Time) bool {
+	if l.rate <= 0 {
+		return true
+	}
+
+	b, ok := l.buckets[method]
+	if !ok {
+		b = &bucket{tokens: float64(l.rate), last: now}
+		l.buckets[method] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * float64(l.rate)
+	if b.tokens > float64(l.rate) {
+		b.tokens = float64(l.rate)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// expire drops the completed calls whose coalesce window is over. It assumes
+// that the caller holds l.mu.
+func (l *limiter) expire(now time.Time) {
+	for key, c := range l.calls {
+		if !c.expiry.IsZero() && now.After(c.expiry) {
+			delete(l.calls, key)
+		}
+	}
+}
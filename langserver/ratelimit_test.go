@@ -0,0 +1,102 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	newRequest := func(method string, line int) *jsonrpc2.Request {
+		params := json.RawMessage(fmt.Sprintf(`{"position":{"line":%d,"character":0}}`, line))
+		return &jsonrpc2.Request{Method: method, Params: &params}
+	}
+
+	var calls int
+	fn := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	ctx := context.Background()
+	l := newLimiter(2)
+
+	// Identical requests share a single computation.
+	result, err := l.do(ctx, newRequest("textDocument/hover", 1), fn)
+	require.NoError(err)
+	require.Equal(1, result)
+	result, err = l.do(ctx, newRequest("textDocument/hover", 1), fn)
+	require.NoError(err)
+	require.Equal(1, result)
+
+	// A different position is computed, but exceeds the rate afterwards.
+	result, err = l.do(ctx, newRequest("textDocument/hover", 2), fn)
+	require.NoError(err)
+	require.Equal(2, result)
+	_, err = l.do(ctx, newRequest("textDocument/hover", 3), fn)
+	require.Error(err)
+	require.Equal(codeServerCancelled, int(responseError(err).Code), "the rate limited requests are not canceled by the client")
+
+	// Other methods have their own budget, and are not rate limited at all
+	// unless listed in rateLimitedMethods.
+	_, err = l.do(ctx, newRequest("textDocument/completion", 3), fn)
+	require.NoError(err)
+	_, err = l.do(ctx, newRequest("textDocument/references", 3), fn)
+	require.NoError(err)
+
+	// A document change drops the shared results.
+	_, err = l.do(ctx, &jsonrpc2.Request{Method: "textDocument/didChange"}, fn)
+	require.NoError(err)
+	require.Empty(l.calls)
+}
+
+func TestLimiterCancellation(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	params := json.RawMessage(`{"position":{"line":1,"character":0}}`)
+	req := &jsonrpc2.Request{Method: "textDocument/hover", Params: &params}
+	l := newLimiter(0)
+
+	// The first request is canceled while an identical one waits for it.
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := l.do(ctx, req, func() (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		done <- err
+	}()
+	<-started
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	waiter := make(chan outcome)
+	go func() {
+		result, err := l.do(context.Background(), req, func() (interface{}, error) {
+			return "computed", nil
+		})
+		waiter <- outcome{result, err}
+	}()
+	// The identical request waits for the first one.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	require.Equal(context.Canceled, <-done)
+	require.Equal(outcome{"computed", nil}, <-waiter, "the cancellation was shared")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	require.Empty(l.calls, "the canceled result is kept")
+}
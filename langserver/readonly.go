@@ -0,0 +1,57 @@
+package langserver
+
+// readOnlyFeatures are the features which edit the documents, disabled in
+// read-only mode.
+var readOnlyFeatures = []string{
+	documentFormattingFeature,
+	documentRangeFormattingFeature,
+	renameFeature,
+	codeActionFeature,
+	coverageFeature,
+}
+
+// readOnlyCommands are the commands which neither edit the documents nor run
+// tools or code of the workspace, the only ones available in read-only mode.
+var readOnlyCommands = map[string]bool{
+	statusCommand:       true,
+	callGraphCommand:    true,
+	panicsCommand:       true,
+	taintCommand:        true,
+	structToJSONCommand: true,
+	clonesCommand:       true,
+	importersCommand:    true,
+}
+
+// readOnly returns c without the features which edit the documents or run
+// tools, if Config.ReadOnly is set.
+func (c Config) readOnly() Config {
+	if !c.ReadOnly {
+		return c
+	}
+	c.DisabledFeatures = append(append([]string(nil), c.DisabledFeatures...), readOnlyFeatures...)
+	c.FixOnSave = noFixOnSave
+	c.CoverageOnSave = false
+	c.AutoPackageClause = false
+	c.RunCodeLens = false
+	c.TestCodeLens = false
+	c.EnumCodeLens = false
+	c.CommandAllowlist = nil
+	return c
+}
+
+// commandAvailable reports whether command may be executed, which in
+// read-only mode is restricted to the readOnlyCommands.
+func (c *Config) commandAvailable(command string) bool {
+	return !c.ReadOnly || readOnlyCommands[command]
+}
+
+// availableCommands returns the commands which may be executed.
+func (c *Config) availableCommands(commands []string) []string {
+	var available []string
+	for _, command := range commands {
+		if c.commandAvailable(command) {
+			available = append(available, command)
+		}
+	}
+	return available
+}
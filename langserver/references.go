@@ -2,11 +2,11 @@ package langserver
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
+	"strings"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
@@ -55,12 +55,12 @@ func (h *LangHandler) doHandleTextDocumentReferences(ctx context.Context, conn j
 	// referrers.go.
 	obj := source.FindIdentObject(pkg, ident)
 	if obj == nil {
-		return nil, errors.New("references object not found")
+		return nil, requestErrorf(NotFoundError, "references object not found")
 	}
 
 	if obj.Pkg() == nil {
 		if _, builtin := obj.(*types.Builtin); !builtin {
-			return nil, fmt.Errorf("no package found for object %s", obj)
+			return nil, requestErrorf(NotFoundError, "no package found for object %s", obj)
 		}
 	}
 
@@ -129,11 +129,20 @@ func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object)
 		defPkgPath = cache.BuiltinPkg
 	}
 
+	fset := h.project.View().FileSet()
+	gcache := h.project.Cache()
+
+	// A symbol declared in a test file is only used by the test variants.
+	testOnly := queryObj.Pos().IsValid() && strings.HasSuffix(fset.Position(queryObj.Pos()).Filename, "_test.go")
 	f := func(pkg source.Package) error {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 
+		if testOnly && !cache.IsTestVariant(pkg) {
+			return nil
+		}
+
 		if defPkgPath != cache.BuiltinPkg {
 			if p := pkg.GetImport(defPkgPath); p == nil && pkg.GetPkgPath() != defPkgPath {
 				return nil
@@ -145,9 +154,16 @@ func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object)
 		}
 
 		for id, obj := range pkg.GetTypesInfo().Uses {
-			if sameObj(queryObj, obj) {
-				refs = append(refs, id)
+			if !sameObj(queryObj, obj) && !sameDecl(fset, queryObj, obj) {
+				continue
+			}
+			// The files of a package are also type checked in its test
+			// variants: their uses are reported by the owner of the file.
+			filename := pkg.GetFileSet().Position(id.Pos()).Filename
+			if owner := gcache.GetByURI(filename); owner != nil && source.Package(owner) != pkg {
+				continue
 			}
+			refs = append(refs, id)
 		}
 
 		return nil
@@ -192,3 +208,16 @@ func sameObj(x, y types.Object) bool {
 	}
 	return false
 }
+
+// sameDecl reports whether x and y are declared at the same position, which
+// is the case of the objects of a package type checked again with its tests,
+// even if they are not exported.
+func sameDecl(fset *token.FileSet, x, y types.Object) bool {
+	if x.Pkg() == nil || y.Pkg() == nil || x.Pkg().Path() != y.Pkg().Path() || x.Name() != y.Name() {
+		return false
+	}
+	if !x.Pos().IsValid() || !y.Pos().IsValid() {
+		return false
+	}
+	return fset.Position(x.Pos()) == fset.Position(y.Pos())
+}
@@ -0,0 +1,34 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSameDecl(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// The package is type checked twice, like with its tests, but its file
+	// is parsed again.
+	fset := token.NewFileSet()
+	check := func() *types.Package {
+		file, err := parser.ParseFile(fset, "p.go", "package p\n\nfunc helper() {}\n\nfunc other() {}\n", 0)
+		require.NoError(err)
+		pkg, err := new(types.Config).Check("p", fset, []*ast.File{file}, nil)
+		require.NoError(err)
+		return pkg
+	}
+	p, variant := check(), check()
+
+	helper := p.Scope().Lookup("helper")
+	require.False(sameObj(helper, variant.Scope().Lookup("helper")))
+	require.True(sameDecl(fset, helper, variant.Scope().Lookup("helper")))
+	require.False(sameDecl(fset, helper, variant.Scope().Lookup("other")))
+	require.False(sameDecl(fset, helper, types.Universe.Lookup("len")))
+}
@@ -2,7 +2,17 @@ package langserver
 
 import (
 	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
 
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -20,16 +30,30 @@ func (h *LangHandler) handleRename(ctx context.Context, conn jsonrpc2.JSONRPC2,
 		},
 	}
 
+	pkg, obj, err := h.checkRename(ctx, params)
+	if err != nil {
+		return lsp.WorkspaceEdit{}, err
+	}
+
 	references, err := h.handleTextDocumentReferences(ctx, conn, req, rp)
 	if err != nil {
 		return lsp.WorkspaceEdit{}, err
 	}
 
+	warnStaleStringer(ctx, conn, pkg, obj)
+
 	result := lsp.WorkspaceEdit{}
 	if result.Changes == nil {
 		result.Changes = make(map[string][]lsp.TextEdit)
 	}
+	// The uses of the exported symbols may be reported by the packages and
+	// their tests.
+	seen := map[lsp.Location]bool{}
 	for _, ref := range references {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
 		edit := lsp.TextEdit{
 			Range:   ref.Range,
 			NewText: params.NewName,
@@ -43,3 +67,143 @@ func (h *LangHandler) handleRename(ctx context.Context, conn jsonrpc2.JSONRPC2,
 	}
 	return result, nil
 }
+
+// renameFileOperation returns the rename of the file of the type renamed by
+// params, if the file is named after the type and declares no other type.
+func (h *LangHandler) renameFileOperation(ctx context.Context, params lsp.RenameParams) *protocol.RenameFile {
+	if !h.config.RenameFiles || !h.clientSupportsResourceOperation("rename") {
+		return nil
+	}
+
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		return nil
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil
+	}
+	ident, ok := pathNodes[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	obj, ok := source.FindIdentObject(pkg, ident).(*types.TypeName)
+	if !ok || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+		return nil
+	}
+
+	filename := h.project.View().FileSet().Position(obj.Pos()).Filename
+	declPkg := h.project.GetFromURI(lsp.DocumentURI(source.ToURI(filename)))
+	if declPkg == nil || typeDeclCount(declPkg, filename) != 1 {
+		return nil
+	}
+	newFilename, ok := renamedFilename(filename, obj.Name(), params.NewName)
+	if !ok {
+		return nil
+	}
+	if _, err := os.Stat(newFilename); err == nil {
+		return nil
+	}
+	return &protocol.RenameFile{
+		Kind:   "rename",
+		OldURI: lsp.DocumentURI(source.ToURI(filename)),
+		NewURI: lsp.DocumentURI(source.ToURI(newFilename)),
+	}
+}
+
+// clientSupportsResourceOperation reports whether the client applies the
+// resource operation kind of workspace edits.
+func (h *LangHandler) clientSupportsResourceOperation(kind string) bool {
+	if h.init == nil {
+		return false
+	}
+	edit := h.init.Capabilities.Workspace.WorkspaceEdit
+	if !edit.DocumentChanges {
+		return false
+	}
+	for _, k := range edit.ResourceOperations {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// typeDeclCount returns the number of the types declared at the top level of
+// the file filename of pkg.
+func typeDeclCount(pkg source.Package, filename string) int {
+	fset := pkg.GetFileSet()
+	for _, f := range pkg.GetSyntax() {
+		if fset.Position(f.Pos()).Filename != filename {
+			continue
+		}
+		n := 0
+		for _, decl := range f.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				n += len(gen.Specs)
+			}
+		}
+		return n
+	}
+	return 0
+}
+
+// renamedFilename returns the name of filename after the rename of the type
+// oldName to newName, if filename is named after oldName in lower case or in
+// snake case. The suffix _test of a test file is kept.
+func renamedFilename(filename, oldName, newName string) (string, bool) {
+	dir, base := filepath.Split(filename)
+	if !strings.HasSuffix(base, ".go") || oldName == newName {
+		return "", false
+	}
+	base = strings.TrimSuffix(base, ".go")
+	suffix := ".go"
+	if strings.HasSuffix(base, "_test") {
+		base = strings.TrimSuffix(base, "_test")
+		suffix = "_test.go"
+	}
+
+	switch base {
+	case strings.ToLower(oldName):
+		return dir + strings.ToLower(newName) + suffix, true
+	case snakeCase(oldName):
+		return dir + snakeCase(newName) + suffix, true
+	}
+	return "", false
+}
+
+// snakeCase returns name in snake case, e.g. http_server for HTTPServer.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && next {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// withResourceOperation returns edit as document changes followed by op.
+func withResourceOperation(edit lsp.WorkspaceEdit, op interface{}) *protocol.WorkspaceEdit {
+	uris := make([]string, 0, len(edit.Changes))
+	for uri := range edit.Changes {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	result := &protocol.WorkspaceEdit{}
+	for _, uri := range uris {
+		result.DocumentChanges = append(result.DocumentChanges, protocol.TextDocumentEdit{
+			TextDocument: lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: lsp.DocumentURI(uri)}},
+			Edits:        edit.Changes[uri],
+		})
+	}
+	result.DocumentChanges = append(result.DocumentChanges, op)
+	return result
+}
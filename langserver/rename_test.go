@@ -0,0 +1,62 @@
+package langserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenamedFilename(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		filename, oldName, newName string
+		want                       string
+	}{
+		{"/p/server.go", "Server", "Listener", "/p/listener.go"},
+		{"/p/httpserver.go", "HTTPServer", "GRPCServer", "/p/grpcserver.go"},
+		{"/p/http_server.go", "HTTPServer", "GRPCServer", "/p/grpc_server.go"},
+		{"/p/http_server_test.go", "HTTPServer", "Server2", "/p/server2_test.go"},
+		{"/p/handler.go", "Server", "Listener", ""},
+		{"/p/server.go", "Server", "Server", ""},
+	}
+	for _, test := range tests {
+		got, ok := renamedFilename(test.filename, test.oldName, test.newName)
+		require.Equal(t, test.want != "", ok, test.filename)
+		require.Equal(t, test.want, got, test.filename)
+	}
+
+	require.Equal(t, "json_to_struct2", snakeCase("JSONToStruct2"))
+}
+
+func TestWithResourceOperation(t *testing.T) {
+	t.Parallel()
+
+	rename := &protocol.RenameFile{Kind: "rename", OldURI: "file:///p/a.go", NewURI: "file:///p/b.go"}
+	edit := withResourceOperation(lsp.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{
+		"file:///p/c.go": {{NewText: "B"}},
+		"file:///p/a.go": {{NewText: "B"}},
+	}}, rename)
+	require.Len(t, edit.DocumentChanges, 3)
+	require.Equal(t, lsp.DocumentURI("file:///p/a.go"), edit.DocumentChanges[0].(protocol.TextDocumentEdit).TextDocument.URI)
+	require.Equal(t, rename, edit.DocumentChanges[2])
+}
+
+func TestClientSupportsResourceOperation(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	var params InitializeParams
+	require.NoError(json.Unmarshal([]byte(`{"capabilities": {
+		"workspace": {"workspaceEdit": {"documentChanges": true, "resourceOperations": ["create", "rename"]}},
+		"textDocument": {"completion": {"completionItem": {"snippetSupport": true}}}
+	}}`), &params))
+
+	h := &LangHandler{init: &params}
+	require.True(h.clientSupportsResourceOperation("rename"))
+	require.False(h.clientSupportsResourceOperation("delete"))
+	require.True(h.clientSupportsSnippets())
+}
@@ -0,0 +1,355 @@
+package langserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"unicode"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// renameRef is a reference of a renamed object, and the package of its file.
+type renameRef struct {
+	id  *ast.Ident
+	pkg *types.Package
+}
+
+func (h *LangHandler) handlePrepareRename(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (*protocol.PrepareRenameResult, error) {
+	pkg, ident, obj, err := h.renameTarget(ctx, params)
+	if err != nil {
+		return nil, renameError(err)
+	}
+	if err := renamable(obj, h.isWorkspacePackage); err != nil {
+		return nil, renameError(err)
+	}
+	return &protocol.PrepareRenameResult{
+		Range:       rangeForNode(pkg.GetFileSet(), ident),
+		Placeholder: ident.Name,
+	}, nil
+}
+
+// checkRename returns the renamed object and its package, or the error
+// explaining why the rename of params would break the code, if it would. The
+// references of the renamed object are searched in all the packages of the
+// workspace.
+func (h *LangHandler) checkRename(ctx context.Context, params lsp.RenameParams) (source.Package, types.Object, error) {
+	pkg, _, obj, err := h.renameTarget(ctx, lsp.TextDocumentPositionParams{TextDocument: params.TextDocument, Position: params.Position})
+	if err != nil {
+		return nil, nil, renameError(err)
+	}
+	if err := renamable(obj, h.isWorkspacePackage); err != nil {
+		return nil, nil, renameError(err)
+	}
+	if params.NewName == obj.Name() {
+		return pkg, obj, nil
+	}
+	if err := checkIdentifier(params.NewName); err != nil {
+		return nil, nil, renameError(err)
+	}
+
+	ids, err := h.findReferences(ctx, obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := h.project.View().FileSet()
+	gcache := h.project.Cache()
+	var declPkg *types.Package
+	var info *types.Info
+	if p := gcache.GetByURI(fset.Position(obj.Pos()).Filename); p != nil {
+		declPkg, info = p.GetTypes(), p.GetTypesInfo()
+	}
+	var refs []renameRef
+	for _, id := range ids {
+		if p := gcache.GetByURI(fset.Position(id.Pos()).Filename); p != nil && p.GetTypes() != nil {
+			refs = append(refs, renameRef{id: id, pkg: p.GetTypes()})
+		}
+	}
+	if err := renameConflict(fset, obj, params.NewName, declPkg, info, refs); err != nil {
+		return nil, nil, renameError(err)
+	}
+	return pkg, obj, nil
+}
+
+// renameTarget returns the identifier at the position of params, which may
+// also follow it, and the object it declares or refers to.
+func (h *LangHandler) renameTarget(ctx context.Context, params lsp.TextDocumentPositionParams) (source.Package, *ast.Ident, types.Object, error) {
+	pkg, ident, obj, err := h.identAt(ctx, params.TextDocument.URI, params.Position)
+	if err != nil && params.Position.Character > 0 {
+		params.Position.Character--
+		if pkg, ident, obj, e := h.identAt(ctx, params.TextDocument.URI, params.Position); e == nil {
+			return pkg, ident, obj, nil
+		}
+	}
+	return pkg, ident, obj, err
+}
+
+// identAt returns the identifier at position of the document uri and the
+// object it declares or refers to.
+func (h *LangHandler) identAt(ctx context.Context, uri lsp.DocumentURI, position lsp.Position) (source.Package, *ast.Ident, types.Object, error) {
+	pkg, pos, err := h.typeCheck(ctx, uri, position)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ident, ok := pathNodes[0].(*ast.Ident)
+	if !ok {
+		return nil, nil, nil, requestErrorf(InvalidPositionError, "there is no identifier to rename at the position")
+	}
+	obj := source.FindIdentObject(pkg, ident)
+	if obj == nil {
+		return nil, nil, nil, requestErrorf(InvalidPositionError, "%s does not refer to a declaration", ident.Name)
+	}
+	return pkg, ident, obj, nil
+}
+
+// renameError returns err as the error of an invalid rename, whose message is
+// shown to the user. The rename conflicts are refused.
+func renameError(err error) error {
+	rpcErr := responseError(withCategory(RefusedError, err))
+	rpcErr.Code = jsonrpc2.CodeInvalidParams
+	return rpcErr
+}
+
+// renamable returns the error explaining why obj cannot be renamed, if it
+// cannot. inWorkspace reports whether a package is one of the workspace.
+func renamable(obj types.Object, inWorkspace func(pkg *types.Package) bool) error {
+	if obj.Pkg() == nil {
+		return fmt.Errorf("%s is predeclared", obj.Name())
+	}
+	if !inWorkspace(obj.Pkg()) {
+		return fmt.Errorf("%s is declared in %s, outside the workspace", obj.Name(), obj.Pkg().Path())
+	}
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return fmt.Errorf("renaming the import of %s is not supported", obj.Imported().Path())
+	case *types.Var:
+		if obj.Anonymous() {
+			return fmt.Errorf("the embedded field %s is named after its type, which must be renamed instead", obj.Name())
+		}
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		if sig.Recv() == nil && (obj.Name() == "init" || obj.Name() == "main" && obj.Pkg().Name() == "main") {
+			return fmt.Errorf("func %s is called by the runtime and cannot be renamed", obj.Name())
+		}
+	}
+	return nil
+}
+
+// checkIdentifier returns the error explaining why name is not a valid new
+// name, if it is not.
+func checkIdentifier(name string) error {
+	if name == "_" {
+		return errors.New("the blank identifier cannot be referenced")
+	}
+	valid := name != "" && !token.Lookup(name).IsKeyword()
+	for i, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			valid = false
+		}
+	}
+	if !valid {
+		return fmt.Errorf("%q is not a valid identifier", name)
+	}
+	return nil
+}
+
+// renameConflict returns the error explaining why renaming obj to newName
+// would break the code, if it would: the new name is not accessible from a
+// reference in another package, or it collides with another declaration of
+// the scope of obj or of the scope of a reference, or obj would shadow a
+// declaration used in its scope. declPkg and info are the package declaring
+// obj and its type information, and refs are the references of obj.
+func renameConflict(fset *token.FileSet, obj types.Object, newName string, declPkg *types.Package, info *types.Info, refs []renameRef) error {
+	conflict := func(other types.Object) error {
+		if other.Parent() == types.Universe {
+			return fmt.Errorf("renaming %s to %s conflicts with the predeclared %s", obj.Name(), newName, newName)
+		}
+		return fmt.Errorf("renaming %s to %s conflicts with the %s declared at %s", obj.Name(), newName, objectKind(other), fset.Position(other.Pos()))
+	}
+
+	if obj.Exported() && !ast.IsExported(newName) {
+		for _, ref := range refs {
+			if ref.pkg.Path() != obj.Pkg().Path() {
+				return fmt.Errorf("renaming %s to %s unexports it, but it is used by package %s at %s", obj.Name(), newName, ref.pkg.Path(), fset.Position(ref.id.Pos()))
+			}
+		}
+	}
+
+	// The fields and the methods are selected from their type.
+	if owner := memberOwner(obj, info); owner != nil {
+		if other, _, _ := types.LookupFieldOrMethod(owner, true, obj.Pkg(), newName); other != nil {
+			return conflict(other)
+		}
+		return nil
+	}
+
+	scope := obj.Parent()
+	if scope == nil {
+		return nil
+	}
+	if other := scope.Lookup(newName); other != nil {
+		return conflict(other)
+	}
+	pkgLevel := scope == obj.Pkg().Scope()
+	if pkgLevel {
+		if newName == "init" {
+			return errors.New("init is reserved for the initialization functions, which cannot be referenced")
+		}
+		// A package member cannot have the name of an import.
+		if info != nil {
+			for node, s := range info.Scopes {
+				if _, ok := node.(*ast.File); !ok {
+					continue
+				}
+				if other := s.Lookup(newName); other != nil {
+					return conflict(other)
+				}
+			}
+		}
+	}
+
+	// A reference must not refer to another declaration, of a scope nested in
+	// the scope of obj, once renamed.
+	for _, ref := range refs {
+		if ref.pkg.Path() != obj.Pkg().Path() {
+			continue // a qualified reference
+		}
+		inner := ref.pkg.Scope().Innermost(ref.id.Pos())
+		if inner == nil {
+			continue
+		}
+		s, other := inner.LookupParent(newName, ref.id.Pos())
+		if other == nil || other == obj {
+			continue
+		}
+		if pkgLevel && isLocalScope(s, ref.pkg) || !pkgLevel && nestedScope(s, scope) {
+			return fmt.Errorf("renaming %s to %s makes its reference at %s refer to the %s declared at %s", obj.Name(), newName, fset.Position(ref.id.Pos()), objectKind(other), fset.Position(other.Pos()))
+		}
+	}
+
+	// Once renamed, obj must not shadow the declarations of an enclosing
+	// scope used in its scope.
+	if declPkg == nil || info == nil {
+		return nil
+	}
+	for id, other := range info.Uses {
+		if id.Name != newName || other == obj {
+			continue
+		}
+		pos := id.Pos()
+		inner := declPkg.Scope().Innermost(pos)
+		if inner == nil {
+			continue
+		}
+		s, found := inner.LookupParent(newName, pos)
+		if found != other {
+			continue // a selected field or method
+		}
+		if pkgLevel {
+			if s != types.Universe {
+				continue
+			}
+			return fmt.Errorf("renaming %s to %s shadows the predeclared %s used at %s", obj.Name(), newName, newName, fset.Position(pos))
+		}
+		if inner != scope && !nestedScope(inner, scope) || pos < obj.Pos() || s == scope || nestedScope(s, scope) {
+			continue
+		}
+		return fmt.Errorf("renaming %s to %s shadows the %s declared at %s used at %s", obj.Name(), newName, objectKind(other), fset.Position(other.Pos()), fset.Position(pos))
+	}
+	return nil
+}
+
+// memberOwner returns the type declaring obj if it is a field or a method,
+// or nil. The structs declaring fields are searched in info.
+func memberOwner(obj types.Object, info *types.Info) types.Type {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			return recv.Type()
+		}
+	case *types.Var:
+		if !obj.IsField() {
+			return nil
+		}
+		declares := func(T types.Type) bool {
+			s, ok := T.Underlying().(*types.Struct)
+			if !ok {
+				return false
+			}
+			for i := 0; i < s.NumFields(); i++ {
+				if s.Field(i) == obj {
+					return true
+				}
+			}
+			return false
+		}
+		scope := obj.Pkg().Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok && declares(tn.Type()) {
+				return tn.Type()
+			}
+		}
+		if info != nil {
+			for _, tv := range info.Types {
+				if tv.Type != nil && declares(tv.Type) {
+					return tv.Type
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// nestedScope reports whether s is nested in parent, but is not parent.
+func nestedScope(s, parent *types.Scope) bool {
+	if s == parent {
+		return false
+	}
+	for ; s != nil; s = s.Parent() {
+		if s == parent {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalScope reports whether s is the scope of a function or a block of
+// pkg, rather than the universe, the package or a file scope.
+func isLocalScope(s *types.Scope, pkg *types.Package) bool {
+	return s != types.Universe && s != pkg.Scope() && s.Parent() != pkg.Scope()
+}
+
+// objectKind describes the kind of obj, e.g. "func" or "field".
+func objectKind(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return "import"
+	case *types.Const:
+		return "const"
+	case *types.TypeName:
+		return "type"
+	case *types.Var:
+		if obj.IsField() {
+			return "field"
+		}
+		return "var"
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.Label:
+		return "label"
+	}
+	return "object"
+}
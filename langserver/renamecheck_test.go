@@ -0,0 +1,174 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameConflict(t *testing.T) {
+	t.Parallel()
+
+	const src = `package p
+
+import "strings"
+
+var total int
+
+type T struct{ Name string }
+
+func (T) Describe() string { return strings.ToUpper("t") }
+
+func helper(s []int) int { return len(s) }
+
+func run(count int) int {
+	limit := 10
+	for i := 0; i < count; i++ {
+		n := i
+		limit += n + count
+	}
+	total += limit
+	return count
+}
+`
+	const other = `package q
+
+import "example.com/p"
+
+var _ = p.Exported
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src+"\nvar Exported = 1\n", 0)
+	require.NoError(t, err)
+	info := &types.Info{
+		Defs:   map[*ast.Ident]types.Object{},
+		Uses:   map[*ast.Ident]types.Object{},
+		Scopes: map[ast.Node]*types.Scope{},
+		Types:  map[ast.Expr]types.TypeAndValue{},
+	}
+	pkg, err := (&types.Config{Importer: fakeImporter{}}).Check("example.com/p", fset, []*ast.File{f}, info)
+	require.NoError(t, err)
+
+	qf, err := parser.ParseFile(fset, "q.go", other, 0)
+	require.NoError(t, err)
+	qinfo := &types.Info{Uses: map[*ast.Ident]types.Object{}}
+	qpkg, err := (&types.Config{Importer: fakeImporter{pkg}}).Check("example.com/q", fset, []*ast.File{qf}, qinfo)
+	require.NoError(t, err)
+
+	// object returns the object declared by the nth identifier name.
+	object := func(name string, nth int) types.Object {
+		var ids []*ast.Ident
+		for id := range info.Defs {
+			if id.Name == name {
+				ids = append(ids, id)
+			}
+		}
+		require.True(t, nth < len(ids), "%s is not declared", name)
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				if ids[j].Pos() < ids[i].Pos() {
+					ids[i], ids[j] = ids[j], ids[i]
+				}
+			}
+		}
+		return info.Defs[ids[nth]]
+	}
+	refs := func(obj types.Object) []renameRef {
+		var refs []renameRef
+		for id, o := range info.Uses {
+			if o == obj {
+				refs = append(refs, renameRef{id: id, pkg: pkg})
+			}
+		}
+		for id, o := range qinfo.Uses {
+			if o == obj {
+				refs = append(refs, renameRef{id: id, pkg: qpkg})
+			}
+		}
+		return refs
+	}
+
+	for _, test := range []struct {
+		name, newName string
+		conflict      string
+	}{
+		{"limit", "max", ""},
+		{"limit", "count", "conflicts with the var declared at p.go:13:10"},
+		{"count", "n", "makes its reference at"},
+		{"limit", "total", "shadows the var declared at p.go:5:5"},
+		{"helper", "len", "shadows the predeclared len"},
+		{"helper", "strings", "conflicts with the import declared at p.go:3:8"},
+		{"helper", "run", "conflicts with the func declared at"},
+		{"helper", "init", "init is reserved"},
+		{"Describe", "Name", "conflicts with the field declared at p.go:7:16"},
+		{"Name", "Describe", "conflicts with the method"},
+		{"Name", "Title", ""},
+		{"Exported", "exported", "it is used by package example.com/q"},
+		{"Exported", "Renamed", ""},
+	} {
+		obj := object(test.name, 0)
+		err := renameConflict(fset, obj, test.newName, pkg, info, refs(obj))
+		if test.conflict == "" {
+			require.NoError(t, err, "%s to %s", test.name, test.newName)
+			continue
+		}
+		require.Error(t, err, "%s to %s", test.name, test.newName)
+		require.True(t, strings.Contains(err.Error(), test.conflict), "%s to %s: %v", test.name, test.newName, err)
+	}
+}
+
+func TestCheckIdentifier(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.NoError(checkIdentifier("newName"))
+	require.NoError(checkIdentifier("_x1"))
+	require.NoError(checkIdentifier("ünïcode"))
+	require.Error(checkIdentifier("_"))
+	require.Error(checkIdentifier(""))
+	require.Error(checkIdentifier("1x"))
+	require.Error(checkIdentifier("a-b"))
+	require.Error(checkIdentifier("func"))
+}
+
+func TestRenamable(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	checked := checkSource(t, "package main\n\ntype Inner struct{}\n\ntype Outer struct{ Inner }\n\nfunc init() {}\n\nfunc main() {}\n\nfunc run() {}\n")
+	pkg, info := checked.Types, checked.Info
+	inWorkspace := func(p *types.Package) bool { return p == pkg }
+
+	require.NoError(renamable(pkg.Scope().Lookup("run"), inWorkspace))
+	require.Error(renamable(pkg.Scope().Lookup("main"), inWorkspace))
+	require.Error(renamable(types.Universe.Lookup("len"), inWorkspace))
+	require.Error(renamable(pkg.Scope().Lookup("Outer").Type().Underlying().(*types.Struct).Field(0), inWorkspace))
+	for id, obj := range info.Defs {
+		if id.Name == "init" {
+			require.Error(renamable(obj, inWorkspace))
+		}
+	}
+	require.Error(renamable(pkg.Scope().Lookup("run"), func(*types.Package) bool { return false }))
+}
+
+// fakeImporter imports its packages and the standard library packages as
+// empty packages, but for strings.ToUpper.
+type fakeImporter []*types.Package
+
+func (imp fakeImporter) Import(path string) (*types.Package, error) {
+	for _, p := range imp {
+		if p.Path() == path {
+			return p, nil
+		}
+	}
+	p := types.NewPackage(path, path[strings.LastIndex(path, "/")+1:])
+	sig := types.NewSignature(nil, types.NewTuple(types.NewVar(token.NoPos, p, "s", types.Typ[types.String])), types.NewTuple(types.NewVar(token.NoPos, p, "", types.Typ[types.String])), false)
+	p.Scope().Insert(types.NewFunc(token.NoPos, p, "ToUpper", sig))
+	p.MarkComplete()
+	return p, nil
+}
@@ -0,0 +1,311 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// rewriteCommand is the workspace/executeCommand command which rewrites the
+// expressions of the workspace matching a pattern, like gofmt -r.
+const rewriteCommand = "bingo.rewrite"
+
+// RewriteParams is the argument of the bingo.rewrite command.
+type RewriteParams struct {
+	// Rule is the rewrite rule "pattern -> replacement", whose single
+	// lowercase letter identifiers are wildcards, eg. "a.Old(x) -> b.New(x)".
+	Rule string `json:"rule"`
+
+	// Types restricts the expressions matched by wildcards to a type, eg.
+	// {"a": "*net/http.Client"}. The types are written with the full import
+	// paths of their packages.
+	Types map[string]string `json:"types,omitempty"`
+
+	// Apply applies the edit with workspace/applyEdit. Otherwise the edit is
+	// only returned, to be previewed.
+	Apply bool `json:"apply,omitempty"`
+}
+
+func (h *LangHandler) handleRewrite(ctx context.Context, conn jsonrpc2.JSONRPC2, params RewriteParams) (*protocol.WorkspaceEdit, error) {
+	pattern, replacement, err := parseRewriteRule(params.Rule)
+	if err != nil {
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, err.Error())
+	}
+
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{}}
+	// The test variants of the packages share their files.
+	seen := map[string]bool{}
+	err = h.project.Search(func(pkg source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if pkg.IsIllTyped() || pkg.GetTypesInfo() == nil {
+			return nil
+		}
+		fset := pkg.GetFileSet()
+		for _, f := range pkg.GetSyntax() {
+			filename := fset.Position(f.Pos()).Filename
+			uri := source.ToURI(filename)
+			if seen[filename] || !h.project.Contain(lsp.DocumentURI(uri)) {
+				continue
+			}
+			seen[filename] = true
+			if edits := rewriteFile(fset, pkg.GetTypesInfo(), f, pattern, replacement, params.Types); len(edits) > 0 {
+				edit.Changes[string(uri)] = edits
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Apply && len(edit.Changes) > 0 {
+		if err := applyEdit(ctx, conn, "rewrite "+params.Rule, edit); err != nil {
+			return nil, err
+		}
+	}
+	return edit, nil
+}
+
+// parseRewriteRule parses the rule "pattern -> replacement".
+func parseRewriteRule(rule string) (ast.Expr, string, error) {
+	parts := strings.Split(rule, "->")
+	if len(parts) != 2 {
+		return nil, "", requestErrorf(InvalidParamsError, "rewrite rule must be of the form 'pattern -> replacement': %q", rule)
+	}
+	pattern, err := parser.ParseExpr(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, "", requestErrorf(InvalidParamsError, "invalid pattern %q: %s", parts[0], err)
+	}
+	replacement := strings.TrimSpace(parts[1])
+	repl, err := parser.ParseExpr(replacement)
+	if err != nil {
+		return nil, "", requestErrorf(InvalidParamsError, "invalid replacement %q: %s", parts[1], err)
+	}
+
+	wildcards := map[string]bool{}
+	ast.Inspect(pattern, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && isWildcard(id.Name) {
+			wildcards[id.Name] = true
+		}
+		return true
+	})
+	var unbound error
+	ast.Inspect(repl, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && isWildcard(id.Name) && !wildcards[id.Name] && unbound == nil {
+			unbound = fmt.Errorf("wildcard %s of the replacement is not in the pattern", id.Name)
+		}
+		return true
+	})
+	return pattern, replacement, unbound
+}
+
+// isWildcard reports whether name is a wildcard of a rewrite rule: a single
+// lowercase letter.
+func isWildcard(name string) bool {
+	r, size := utf8.DecodeRuneInString(name)
+	return size == len(name) && unicode.IsLower(r)
+}
+
+// rewriteWildcard returns the name of the wildcard of a rewrite rule id.
+func rewriteWildcard(id string) (string, bool) {
+	return id, isWildcard(id)
+}
+
+// rewriteFile returns the edits which replace the outermost expressions of f
+// matching pattern.
+func rewriteFile(fset *token.FileSet, info *types.Info, f *ast.File, pattern ast.Expr, replacement string, filters map[string]string) []lsp.TextEdit {
+	var edits []lsp.TextEdit
+	ast.Inspect(f, func(n ast.Node) bool {
+		expr, ok := n.(ast.Expr)
+		if !ok {
+			return true
+		}
+		m := newRewriteMatcher(info, filters, rewriteWildcard)
+		if !m.match(reflect.ValueOf(pattern), reflect.ValueOf(expr), true) {
+			return true
+		}
+		edits = append(edits, lsp.TextEdit{
+			Range:   rangeForNode(fset, expr),
+			NewText: m.substitute(fset, replacement),
+		})
+		return false
+	})
+	sort.Slice(edits, func(i, j int) bool {
+		a, b := edits[i].Range.Start, edits[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
+	return edits
+}
+
+var (
+	identType    = reflect.TypeOf((*ast.Ident)(nil))
+	objectType   = reflect.TypeOf((*ast.Object)(nil))
+	positionType = reflect.TypeOf(token.NoPos)
+	exprType     = reflect.TypeOf((*ast.Expr)(nil)).Elem()
+)
+
+// rewriteMatcher matches a pattern against the expressions of a type checked
+// file.
+type rewriteMatcher struct {
+	info    *types.Info
+	filters map[string]string
+
+	// wildcard returns the name of the wildcard of the pattern identifier
+	// id. The wildcards named "" match any expression without binding it.
+	wildcard func(id string) (string, bool)
+
+	// env are the expressions bound to the wildcards.
+	env map[string]ast.Expr
+}
+
+func newRewriteMatcher(info *types.Info, filters map[string]string, wildcard func(string) (string, bool)) *rewriteMatcher {
+	return &rewriteMatcher{info: info, filters: filters, wildcard: wildcard, env: map[string]ast.Expr{}}
+}
+
+// match reports whether the pattern matches val. The wildcards are only
+// interpreted if wildcards is set, when pattern is a part of the pattern.
+func (m *rewriteMatcher) match(pattern, val reflect.Value, wildcards bool) bool {
+	if wildcards && pattern.IsValid() && pattern.Type() == identType {
+		name, ok := m.wildcard(pattern.Interface().(*ast.Ident).Name)
+		if ok && val.IsValid() && val.Type().Implements(exprType) && !val.IsNil() {
+			expr := val.Interface().(ast.Expr)
+			if bound, ok := m.env[name]; ok {
+				return m.match(reflect.ValueOf(bound), val, false)
+			}
+			if !m.typeMatches(name, expr) {
+				return false
+			}
+			if name != "" {
+				m.env[name] = expr
+			}
+			return true
+		}
+	}
+
+	if !pattern.IsValid() || !val.IsValid() {
+		return !pattern.IsValid() && !val.IsValid()
+	}
+	if pattern.Type() != val.Type() {
+		return false
+	}
+
+	switch pattern.Type() {
+	case identType:
+		p, v := pattern.Interface().(*ast.Ident), val.Interface().(*ast.Ident)
+		if p == nil || v == nil {
+			return p == v
+		}
+		if p.Name == v.Name {
+			return true
+		}
+		// The imports may be renamed.
+		pkgName, ok := m.info.Uses[v].(*types.PkgName)
+		return wildcards && ok && pkgName.Imported().Name() == p.Name
+	case objectType, positionType:
+		return true
+	}
+
+	switch pattern.Kind() {
+	case reflect.Slice:
+		if pattern.Len() != val.Len() {
+			return false
+		}
+		for i := 0; i < pattern.Len(); i++ {
+			if !m.match(pattern.Index(i), val.Index(i), wildcards) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Struct:
+		for i := 0; i < pattern.NumField(); i++ {
+			if !m.match(pattern.Field(i), val.Field(i), wildcards) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Ptr, reflect.Interface:
+		if pattern.IsNil() || val.IsNil() {
+			return pattern.IsNil() && val.IsNil()
+		}
+		return m.match(pattern.Elem(), val.Elem(), wildcards)
+	}
+	return pattern.Interface() == val.Interface()
+}
+
+// typeMatches reports whether the type of expr is the one the wildcard name
+// is restricted to, if any.
+func (m *rewriteMatcher) typeMatches(name string, expr ast.Expr) bool {
+	filter, ok := m.filters[name]
+	if !ok {
+		return true
+	}
+	t := m.info.TypeOf(expr)
+	return t != nil && types.TypeString(t, nil) == filter
+}
+
+// substitute returns the replacement whose wildcards are replaced by the
+// source of the bound expressions.
+func (m *rewriteMatcher) substitute(fset *token.FileSet, replacement string) string {
+	repl, _ := parser.ParseExpr(replacement)
+	result := astutil.Apply(repl, nil, func(c *astutil.Cursor) bool {
+		id, ok := c.Node().(*ast.Ident)
+		if !ok || !isWildcard(id.Name) {
+			return true
+		}
+		bound, ok := m.env[id.Name]
+		if !ok {
+			return true
+		}
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, bound)
+		text := buf.String()
+		if needsParens(bound, c) {
+			text = "(" + text + ")"
+		}
+		// The printer writes the names verbatim.
+		c.Replace(&ast.Ident{Name: text})
+		return true
+	})
+
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), result)
+	return buf.String()
+}
+
+// needsParens reports whether expr binds less tightly than the operator of
+// the parent of the cursor c, where it is substituted.
+func needsParens(expr ast.Expr, c *astutil.Cursor) bool {
+	switch expr.(type) {
+	case *ast.Ident, *ast.BasicLit, *ast.SelectorExpr, *ast.CallExpr, *ast.IndexExpr, *ast.SliceExpr, *ast.ParenExpr, *ast.CompositeLit, *ast.TypeAssertExpr:
+		return false
+	}
+	switch c.Parent().(type) {
+	case *ast.SelectorExpr, *ast.StarExpr, *ast.UnaryExpr, *ast.BinaryExpr, *ast.TypeAssertExpr, *ast.SliceExpr:
+		return true
+	case *ast.IndexExpr:
+		return c.Name() == "X"
+	case *ast.CallExpr:
+		return c.Name() == "Fun"
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteFile(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fset, f, _, info := checkStubbedPackage(t, `package p
+
+import str "strings"
+
+type T struct{}
+
+func (T) Old(x int) int { return x }
+
+type U struct{}
+
+func (U) Old(x int) int { return x }
+
+func f(t T, u U) bool {
+	_ = t.Old(1 + 2)
+	_ = u.Old(t.Old(3))
+	return str.Contains("a", "b") || str.HasPrefix("a", "b")
+}
+`, nil)
+
+	rewrite := func(rule string, filters map[string]string) []string {
+		pattern, replacement, err := parseRewriteRule(rule)
+		require.NoError(err)
+		var edits []string
+		for _, edit := range rewriteFile(fset, info, f, pattern, replacement, filters) {
+			edits = append(edits, edit.Range.String()+" "+edit.NewText)
+		}
+		return edits
+	}
+
+	require.Equal([]string{
+		"13:5-13:17 t.New(1 + 2)",
+		"14:5-14:20 u.New(t.Old(3))",
+	}, rewrite("a.Old(x) -> a.New(x)", nil))
+	require.Equal([]string{
+		"13:5-13:17 New(t, 1 + 2)",
+		"14:11-14:19 New(t, 3)",
+	}, rewrite("a.Old(x) -> New(a, x)", map[string]string{"a": "example.com/p.T"}))
+	require.Equal([]string{
+		"15:8-15:30 (\"a\" + \"b\").Len()",
+	}, rewrite("strings.Contains(a, b) -> (a + b).Len()", nil))
+	require.Equal([]string{
+		"15:8-15:30 str.Index(\"a\", \"b\") >= 0",
+	}, rewrite("strings.Contains(a, b) -> str.Index(a, b) >= 0", nil))
+
+	_, _, err := parseRewriteRule("a.Old(x) -> a.New(y)")
+	require.EqualError(err, "wildcard y of the replacement is not in the pattern")
+}
@@ -0,0 +1,305 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// routeKind is the symbol kind of the HTTP routes.
+const routeKind = lsp.SKString
+
+// The import paths of the supported routers.
+const (
+	netHTTPPath    = "net/http"
+	gorillaMuxPath = "github.com/gorilla/mux"
+	chiPath        = "github.com/go-chi/chi"
+	ginPath        = "github.com/gin-gonic/gin"
+)
+
+// chiMethods are the methods of chi.Router which register a route for an
+// HTTP method.
+var chiMethods = map[string]string{
+	"Connect": "CONNECT",
+	"Delete":  "DELETE",
+	"Get":     "GET",
+	"Head":    "HEAD",
+	"Options": "OPTIONS",
+	"Patch":   "PATCH",
+	"Post":    "POST",
+	"Put":     "PUT",
+	"Trace":   "TRACE",
+}
+
+// ginMethods are the methods of gin.IRoutes which register a route for an
+// HTTP method.
+var ginMethods = map[string]string{
+	"DELETE":  "DELETE",
+	"GET":     "GET",
+	"HEAD":    "HEAD",
+	"OPTIONS": "OPTIONS",
+	"PATCH":   "PATCH",
+	"POST":    "POST",
+	"PUT":     "PUT",
+}
+
+// route is the registration of an HTTP handler.
+type route struct {
+	// method is the HTTP method of the route, or empty for any method.
+	method  string
+	pattern string
+
+	// lit is the literal of the pattern, and handler the expression of the
+	// handler.
+	lit     *ast.BasicLit
+	handler ast.Expr
+}
+
+// name returns the name of the route symbol, eg. "GET /users".
+func (r route) name() string {
+	if r.method == "" {
+		return r.pattern
+	}
+	return r.method + " " + r.pattern
+}
+
+// findRoutes returns the routes registered with net/http, gorilla/mux, chi
+// or gin by the calls of files. The prefixes of the route groups are not
+// tracked.
+func findRoutes(info *types.Info, files []*ast.File) []route {
+	var routes []route
+	for _, f := range files {
+		// The parents of the calls, for the Methods calls chained to
+		// gorilla/mux routes.
+		var stack []ast.Node
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return false
+			}
+			stack = append(stack, n)
+
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if r, ok := callRoute(info, call, stack); ok {
+				routes = append(routes, r)
+			}
+			return true
+		})
+	}
+	return routes
+}
+
+// callRoute returns the route registered by call, whose parents are stack.
+func callRoute(info *types.Info, call *ast.CallExpr, stack []ast.Node) (route, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return route{}, false
+	}
+	fn, ok := info.Uses[sel.Sel].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return route{}, false
+	}
+	pkgPath := fn.Pkg().Path()
+	name := fn.Name()
+
+	// arg returns the argument i as a route, with the handler last.
+	arg := func(method string, i int) (route, bool) {
+		if i >= len(call.Args) || len(call.Args) < 2 {
+			return route{}, false
+		}
+		lit, pattern, ok := stringLiteral(info, call.Args[i])
+		if !ok {
+			return route{}, false
+		}
+		return route{method: method, pattern: pattern, lit: lit, handler: call.Args[len(call.Args)-1]}, true
+	}
+
+	switch {
+	case pkgPath == netHTTPPath && (name == "Handle" || name == "HandleFunc"):
+		// Since Go 1.22, the patterns may start with the method.
+		r, ok := arg("", 0)
+		if i := strings.IndexByte(r.pattern, ' '); ok && i > 0 {
+			r.method, r.pattern = r.pattern[:i], strings.TrimSpace(r.pattern[i:])
+		}
+		return r, ok
+
+	case pkgPath == gorillaMuxPath && (name == "Handle" || name == "HandleFunc"):
+		r, ok := arg(gorillaMethods(info, call, stack), 0)
+		return r, ok
+
+	case isChiPath(pkgPath):
+		if method, ok := chiMethods[name]; ok {
+			return arg(method, 0)
+		}
+		switch name {
+		case "Handle", "HandleFunc":
+			return arg("", 0)
+		case "Method", "MethodFunc":
+			if len(call.Args) == 0 {
+				break
+			}
+			if _, method, ok := stringLiteral(info, call.Args[0]); ok {
+				return arg(strings.ToUpper(method), 1)
+			}
+		}
+
+	case pkgPath == ginPath:
+		if method, ok := ginMethods[name]; ok {
+			return arg(method, 0)
+		}
+		switch name {
+		case "Any":
+			return arg("", 0)
+		case "Handle":
+			if len(call.Args) == 0 {
+				break
+			}
+			if _, method, ok := stringLiteral(info, call.Args[0]); ok {
+				return arg(strings.ToUpper(method), 1)
+			}
+		}
+	}
+	return route{}, false
+}
+
+// gorillaMethods returns the methods of the gorilla/mux route registered by
+// call, set by a chained Methods call, eg. r.HandleFunc("/", h).Methods("GET").
+func gorillaMethods(info *types.Info, call *ast.CallExpr, stack []ast.Node) string {
+	// The chained call is the grandparent of call: call.Methods(...).
+	if len(stack) < 3 {
+		return ""
+	}
+	sel, ok := stack[len(stack)-2].(*ast.SelectorExpr)
+	if !ok || sel.X != call || sel.Sel.Name != "Methods" {
+		return ""
+	}
+	chained, ok := stack[len(stack)-3].(*ast.CallExpr)
+	if !ok || chained.Fun != sel {
+		return ""
+	}
+	var methods []string
+	for _, arg := range chained.Args {
+		if _, method, ok := stringLiteral(info, arg); ok {
+			methods = append(methods, strings.ToUpper(method))
+		}
+	}
+	return strings.Join(methods, ",")
+}
+
+// stringLiteral returns the string literal expr and its value.
+func stringLiteral(info *types.Info, expr ast.Expr) (*ast.BasicLit, string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return nil, "", false
+	}
+	tv, ok := info.Types[lit]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return nil, "", false
+	}
+	return lit, constant.StringVal(tv.Value), true
+}
+
+// isChiPath reports whether path is an import path of chi, eg.
+// "github.com/go-chi/chi/v5".
+func isChiPath(p string) bool {
+	return p == chiPath || strings.HasPrefix(p, chiPath+"/v") && !strings.Contains(strings.TrimPrefix(p, chiPath+"/"), "/")
+}
+
+// handlerPos returns the position of the declaration of the handler expr: a
+// function, a method value, a variable, or a function literal. Conversions
+// like http.HandlerFunc(f) are looked through.
+func handlerPos(info *types.Info, expr ast.Expr) token.Pos {
+loop:
+	for {
+		switch e := expr.(type) {
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.CallExpr:
+			tv, ok := info.Types[e.Fun]
+			if !ok || !tv.IsType() || len(e.Args) != 1 {
+				break loop
+			}
+			expr = e.Args[0]
+		case *ast.FuncLit:
+			return e.Pos()
+		default:
+			break loop
+		}
+	}
+	if obj := referencedObject(info, expr); obj != nil && obj.Pos().IsValid() {
+		return obj.Pos()
+	}
+	return expr.Pos()
+}
+
+// routeSymbols returns the symbols of the routes of pkg.
+func routeSymbols(pkg source.Package) []symbolPair {
+	info := pkg.GetTypesInfo()
+	if info == nil {
+		return nil
+	}
+	var symbols []symbolPair
+	for _, r := range findRoutes(info, pkg.GetSyntax()) {
+		sym := toSym(r.name(), pkg, "", "", routeKind, pkg.GetFileSet(), r.lit.Pos())
+		sym.Location.Range = rangeForNode(pkg.GetFileSet(), r.lit)
+		symbols = append(symbols, sym)
+	}
+	return symbols
+}
+
+// routeScore scores the route name for the query q, which matches it if
+// the route contains all its tokens, eg. "get /users" or "users".
+func routeScore(q Query, name string) int {
+	if q.Symbol != nil || q.Kind != 0 && q.Kind != routeKind {
+		return 0
+	}
+	if len(q.Tokens) == 0 {
+		return 2
+	}
+	name = strings.ToLower(name)
+	scor := 0
+	for _, tok := range q.Tokens {
+		if !strings.Contains(name, tok) {
+			return 0
+		}
+		scor += 3
+	}
+	// The exact routes first.
+	if path.Base(name) == q.Tokens[len(q.Tokens)-1] {
+		scor += 50
+	}
+	return scor
+}
+
+// collectRoute records the route symbol si if it matches the query.
+func (s *resultSorter) collectRoute(si symbolPair) {
+	s.resultsMu.Lock()
+	if score := routeScore(s.Query, si.Name); score > 0 {
+		s.results = append(s.results, scoredSymbol{score, si})
+	}
+	s.resultsMu.Unlock()
+}
+
+// routeDefinition returns the location of the handler of the route whose
+// pattern is lit.
+func routeDefinition(pkg source.Package, lit *ast.BasicLit) (lsp.Location, bool) {
+	info := pkg.GetTypesInfo()
+	if info == nil {
+		return lsp.Location{}, false
+	}
+	for _, r := range findRoutes(info, pkg.GetSyntax()) {
+		if r.lit == lit {
+			return goRangeToLSPLocation(pkg.GetFileSet(), handlerPos(info, r.handler), ""), true
+		}
+	}
+	return lsp.Location{}, false
+}
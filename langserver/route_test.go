@@ -0,0 +1,102 @@
+package langserver
+
+import (
+	"fmt"
+	"go/ast"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// routerStubs are the declarations of the routers used by the tests.
+var routerStubs = map[string]string{
+	gorillaMuxPath: `package mux
+
+import "net/http"
+
+type Router struct{}
+type Route struct{}
+
+func (r *Router) HandleFunc(path string, f func(http.ResponseWriter, *http.Request)) *Route { return nil }
+func (r *Route) Methods(methods ...string) *Route { return r }
+`,
+	chiPath + "/v5": `package chi
+
+import "net/http"
+
+type Router interface {
+	Get(pattern string, h http.HandlerFunc)
+	Method(method, pattern string, h http.Handler)
+}
+`,
+	ginPath: `package gin
+
+type Context struct{}
+type HandlerFunc func(*Context)
+type Engine struct{}
+
+func (e *Engine) GET(path string, handlers ...HandlerFunc) {}
+func (e *Engine) Handle(method, path string, handlers ...HandlerFunc) {}
+`,
+}
+
+func TestFindRoutes(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fset, f, _, info := checkStubbedPackage(t, `package p
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+)
+
+type server struct{}
+
+func (s *server) users(w http.ResponseWriter, r *http.Request) {}
+
+func health(w http.ResponseWriter, r *http.Request) {}
+
+func list(c *gin.Context) {}
+
+func routes(s *server, m *mux.Router, c chi.Router, g *gin.Engine) {
+	http.HandleFunc("/health", health)
+	http.Handle("POST /users", http.HandlerFunc(s.users))
+	m.HandleFunc("/users", s.users).Methods("GET", "put")
+	c.Get("/items", health)
+	c.Method("delete", "/items", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	g.GET("/list", func(c *gin.Context) {}, list)
+	g.Handle("PATCH", "/list", list)
+}
+`, routerStubs)
+
+	var names, handlers []string
+	for _, r := range findRoutes(info, []*ast.File{f}) {
+		names = append(names, r.name())
+		pos := fset.Position(handlerPos(info, r.handler))
+		handlers = append(handlers, fmt.Sprintf("%d:%d", pos.Line, pos.Column))
+	}
+	require.Equal([]string{
+		"/health",
+		"POST /users",
+		"GET,PUT /users",
+		"GET /items",
+		"DELETE /items",
+		"GET /list",
+		"PATCH /list",
+	}, names)
+	require.Equal([]string{"15:6", "13:18", "13:18", "15:6", "24:48", "17:6", "17:6"}, handlers)
+}
+
+func TestRouteScore(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.True(routeScore(ParseQuery("GET /users"), "GET /users") > routeScore(ParseQuery("GET /users"), "GET /users/{id}"))
+	require.True(routeScore(ParseQuery("users"), "POST /users") > 0)
+	require.Equal(0, routeScore(ParseQuery("GET /items"), "GET /users"))
+	require.Equal(0, routeScore(ParseQuery("func users"), "GET /users"))
+}
@@ -0,0 +1,132 @@
+package langserver
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// runCommand is the workspace/executeCommand command which runs a main
+// package or a single file with go run. Its arguments are the directory to
+// run in and the go run target.
+const runCommand = "bingo.run"
+
+// runCodeLenses returns a code lens on func main and on the package clause
+// of f if it belongs to package main.
+func runCodeLenses(fset *token.FileSet, f *ast.File, uri lsp.DocumentURI) []protocol.CodeLens {
+	lenses := []protocol.CodeLens{}
+	if f.Name.Name != "main" {
+		return lenses
+	}
+
+	add := func(kind string, name *ast.Ident) {
+		rng := rangeForNode(fset, name)
+		lenses = append(lenses, protocol.CodeLens{
+			Range: rng,
+			Data:  codeLensData{Kind: kind, URI: uri, Position: rng.Start},
+		})
+	}
+
+	add(runFileLens, f.Name)
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+			add(runLens, fn.Name)
+		}
+	}
+	return lenses
+}
+
+// runLensCommand returns the command of a run code lens of the file uri.
+func runLensCommand(kind string, uri lsp.DocumentURI) *lsp.Command {
+	filename := util.UriToRealPath(uri)
+	if kind == runFileLens {
+		return &lsp.Command{
+			Title:     "run file",
+			Command:   runCommand,
+			Arguments: []interface{}{filepath.Dir(filename), filepath.Base(filename)},
+		}
+	}
+	return &lsp.Command{
+		Title:     "run",
+		Command:   runCommand,
+		Arguments: []interface{}{filepath.Dir(filename), "."},
+	}
+}
+
+// run starts go run target in dir with the configured flags and environment,
+// and streams its output to the client log. It does not wait for the
+// program to exit.
+func (h *LangHandler) run(dir, target string) error {
+	args := append([]string{"run"}, h.config.RunFlags...)
+	args = append(args, target)
+
+	// The program outlives the request, so it is not bound to its context.
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = h.commandEnv(h.config.RunEnv)
+	out := &lineWriter{emit: h.notifyLog}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	name := "go " + strings.Join(args, " ")
+	if err := cmd.Start(); err != nil {
+		h.auditExec(cmd, err)
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	h.notifyLog(fmt.Sprintf("%s (in %s)", name, dir))
+
+	go func() {
+		err := cmd.Wait()
+		out.flush()
+		h.auditExec(cmd, err)
+		if err != nil {
+			h.notifyLog(fmt.Sprintf("%s: %s", name, err))
+			return
+		}
+		h.notifyLog(fmt.Sprintf("%s: done", name))
+	}()
+	return nil
+}
+
+// lineWriter calls emit with every complete line written to it.
+type lineWriter struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	emit func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf.Next(i + 1))
+		w.emit(strings.TrimRight(line, "\r\n"))
+	}
+	return len(p), nil
+}
+
+// flush emits the last line if it does not end with a newline.
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len() > 0 {
+		w.emit(w.buf.String())
+		w.buf.Reset()
+	}
+}
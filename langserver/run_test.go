@@ -0,0 +1,60 @@
+package langserver
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCodeLenses(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `package main
+
+func (t) main() {}
+
+func main() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, 0)
+	require.NoError(err)
+
+	lenses := runCodeLenses(fset, f, "file:///a/main.go")
+	require.Len(lenses, 2)
+	require.Equal(runFileLens, lenses[0].Data.(codeLensData).Kind)
+	require.Equal(0, lenses[0].Range.Start.Line)
+	require.Equal(runLens, lenses[1].Data.(codeLensData).Kind)
+	require.Equal(4, lenses[1].Range.Start.Line)
+
+	f, err = parser.ParseFile(fset, "p.go", "package p\n\nfunc main() {}\n", 0)
+	require.NoError(err)
+	require.Empty(runCodeLenses(fset, f, "file:///a/p.go"))
+}
+
+func TestRunLensCommand(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cmd := runLensCommand(runLens, "file:///a/main.go")
+	require.Equal(runCommand, cmd.Command)
+	require.Equal([]interface{}{"/a", "."}, cmd.Arguments)
+
+	cmd = runLensCommand(runFileLens, "file:///a/main.go")
+	require.Equal([]interface{}{"/a", "main.go"}, cmd.Arguments)
+}
+
+func TestLineWriter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	var lines []string
+	w := &lineWriter{emit: func(line string) { lines = append(lines, line) }}
+	w.Write([]byte("hello\r\nwor"))
+	w.Write([]byte("ld\nlast"))
+	require.Equal([]string{"hello", "world"}, lines)
+	w.flush()
+	require.Equal([]string{"hello", "world", "last"}, lines)
+}
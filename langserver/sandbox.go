@@ -0,0 +1,117 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// codeRunningCommands are the workspace/executeCommand commands which run
+// code of the workspace, and so have to be allowed by the user.
+var codeRunningCommands = map[string]bool{
+	runCommand:      true,
+	testCommand:     true,
+	coverageCommand: true,
+}
+
+// confirmAction is the action of the confirmation of a command.
+const confirmAction = "Run"
+
+// scrubbedEnvKeep are the environment variables which are kept when the
+// environment of the commands is scrubbed. The go command needs them to
+// find its toolchain and its caches.
+var scrubbedEnvKeep = []string{
+	"PATH",
+	"HOME",
+	"TMPDIR",
+	"GOROOT",
+	"GOPATH",
+	"GOCACHE",
+	"GOFLAGS",
+	"GOPROXY",
+	"GOPRIVATE",
+	"GONOPROXY",
+	"GONOSUMDB",
+	"GO111MODULE",
+	"CGO_ENABLED",
+	// Windows
+	"SYSTEMROOT",
+	"USERPROFILE",
+	"LOCALAPPDATA",
+	"APPDATA",
+	"TEMP",
+	"TMP",
+}
+
+// commandAllowed reports whether command may run without confirmation.
+func (c *Config) commandAllowed(command string) bool {
+	if !codeRunningCommands[command] {
+		return true
+	}
+	for _, allowed := range c.CommandAllowlist {
+		if allowed == command {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmCommand asks the user to confirm a command which is not in
+// Config.CommandAllowlist.
+func (h *LangHandler) confirmCommand(ctx context.Context, conn jsonrpc2.JSONRPC2, params lsp.ExecuteCommandParams) error {
+	if h.config.commandAllowed(params.Command) {
+		return nil
+	}
+
+	var action *protocol.MessageActionItem
+	err := conn.Call(ctx, "window/showMessageRequest", &protocol.ShowMessageRequestParams{
+		Type:    lsp.MTWarning,
+		Message: fmt.Sprintf("%s %v runs code of the workspace. Add it to the command allowlist to skip this confirmation.", params.Command, params.Arguments),
+		Actions: []protocol.MessageActionItem{{Title: confirmAction}, {Title: "Cancel"}},
+	}, &action)
+	if err != nil {
+		return err
+	}
+	if action == nil || action.Title != confirmAction {
+		return &jsonrpc2.Error{Code: codeRequestCancelled, Message: fmt.Sprintf("%s has not been confirmed", params.Command)}
+	}
+	return nil
+}
+
+// commandEnv returns the environment of the commands, with the environment
+// overrides of the workspace and extra. If Config.ScrubCommandEnv is set,
+// only the variables the go command needs are kept from the environment of
+// bingo.
+func (h *LangHandler) commandEnv(extra []string) []string {
+	env := os.Environ()
+	if h.config.ScrubCommandEnv {
+		env = scrubEnv(env)
+	}
+	env = append(env, h.project.Env()...)
+	return append(env, extra...)
+}
+
+// scrubEnv returns the variables of env listed in scrubbedEnvKeep.
+func scrubEnv(env []string) []string {
+	var scrubbed []string
+	for _, kv := range env {
+		i := strings.Index(kv, "=")
+		if i <= 0 {
+			continue
+		}
+		key := kv[:i]
+		for _, keep := range scrubbedEnvKeep {
+			if key == keep || runtime.GOOS == "windows" && strings.EqualFold(key, keep) {
+				scrubbed = append(scrubbed, kv)
+				break
+			}
+		}
+	}
+	return scrubbed
+}
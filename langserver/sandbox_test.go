@@ -0,0 +1,42 @@
+package langserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandAllowed(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	c := NewDefaultConfig()
+	require.True(c.commandAllowed(statusCommand))
+	require.False(c.commandAllowed(runCommand))
+
+	c.CommandAllowlist = []string{runCommand}
+	require.True(c.commandAllowed(runCommand))
+	require.False(c.commandAllowed(coverageCommand), "the tests run for the coverage are not confirmed")
+}
+
+func TestSandboxOptions(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// A client cannot allow itself to run code, nor see the environment.
+	var opts InitializationOptions
+	require.NoError(json.Unmarshal([]byte(`{"commandAllowlist": ["bingo.run"], "scrubCommandEnv": false}`), &opts))
+	c := NewDefaultConfig()
+	c.ScrubCommandEnv = true
+	c = c.Apply(&opts)
+	require.False(c.commandAllowed(runCommand))
+	require.True(c.ScrubCommandEnv)
+}
+
+func TestScrubEnv(t *testing.T) {
+	t.Parallel()
+
+	env := []string{"PATH=/bin", "AWS_SECRET_ACCESS_KEY=x", "GOPATH=/go", "GITHUB_TOKEN=y", "=C:=C:\\"}
+	require.Equal(t, []string{"PATH=/bin", "GOPATH=/go"}, scrubEnv(env))
+}
@@ -0,0 +1,150 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// untitledURIRegexp matches the quoted URIs of the untitled documents in
+// JSON.
+var untitledURIRegexp = regexp.MustCompile(`"untitled:[^"\\]*"`)
+
+// scratchDocuments maps the untitled documents of the client, which have no
+// file, to the scratch files of the project, see cache.Project.ScratchFilename.
+// The URIs of the untitled documents are replaced in the parameters of the
+// requests and back in their results and in the notifications to the client.
+type scratchDocuments struct {
+	mu       sync.Mutex
+	project  *cache.Project
+	files    map[string]string // file URI of each untitled URI
+	untitled map[string]string // untitled URI of each file URI
+}
+
+func newScratchDocuments() *scratchDocuments {
+	return &scratchDocuments{
+		files:    make(map[string]string),
+		untitled: make(map[string]string),
+	}
+}
+
+// reset maps the untitled documents to the scratch files of project.
+func (s *scratchDocuments) reset(project *cache.Project) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.project = project
+	s.files = make(map[string]string)
+	s.untitled = make(map[string]string)
+}
+
+// move maps the untitled documents to the scratch files of project, which has
+// the same root as the previous project, hence the same scratch files.
+func (s *scratchDocuments) move(project *cache.Project) {
+	s.mu.Lock()
+	s.project = project
+	s.mu.Unlock()
+}
+
+// request returns req with the URIs of the untitled documents in its
+// parameters replaced by the URIs of their scratch files.
+func (s *scratchDocuments) request(req *jsonrpc2.Request) *jsonrpc2.Request {
+	if req.Params == nil || !untitledURIRegexp.Match(*req.Params) {
+		return req
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.project == nil {
+		return req
+	}
+	params := json.RawMessage(untitledURIRegexp.ReplaceAllFunc(*req.Params, func(quoted []byte) []byte {
+		var uri string
+		if err := json.Unmarshal(quoted, &uri); err != nil {
+			return quoted
+		}
+		file, ok := s.files[uri]
+		if !ok {
+			file = string(source.ToURI(s.project.ScratchFilename(scratchName(uri))))
+			s.files[uri] = file
+			s.untitled[file] = uri
+		}
+		b, _ := json.Marshal(file)
+		return b
+	}))
+	translated := *req
+	translated.Params = &params
+	return &translated
+}
+
+// result returns v with the URIs of the scratch files replaced by the URIs
+// of their untitled documents.
+func (s *scratchDocuments) result(v interface{}) (interface{}, error) {
+	s.mu.Lock()
+	empty := len(s.untitled) == 0
+	s.mu.Unlock()
+	if empty || v == nil {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for file, uri := range s.untitled {
+		quotedFile, _ := json.Marshal(file)
+		quotedURI, _ := json.Marshal(uri)
+		b = bytes.Replace(b, quotedFile, quotedURI, -1)
+	}
+	return json.RawMessage(b), nil
+}
+
+// conn returns conn, translating the URIs of the scratch files of the
+// notifications and the requests to the client.
+func (s *scratchDocuments) conn(conn jsonrpc2.JSONRPC2) jsonrpc2.JSONRPC2 {
+	return scratchConn{JSONRPC2: conn, scratch: s}
+}
+
+// scratchName returns the name of the scratch file of the untitled document
+// uri.
+func scratchName(uri string) string {
+	name := strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(uri, "untitled:"))
+	if !strings.HasSuffix(name, ".go") {
+		name += ".go"
+	}
+	return name
+}
+
+type scratchConn struct {
+	jsonrpc2.JSONRPC2
+	scratch *scratchDocuments
+}
+
+func (c scratchConn) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	translated, err := c.scratch.result(params)
+	if err != nil {
+		return err
+	}
+	return c.JSONRPC2.Call(ctx, method, translated, result, opt...)
+}
+
+func (c scratchConn) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	translated, err := c.scratch.result(params)
+	if err != nil {
+		return err
+	}
+	return c.JSONRPC2.Notify(ctx, method, translated, opt...)
+}
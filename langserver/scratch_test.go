@@ -0,0 +1,52 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScratchName(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.Equal("Untitled-1.go", scratchName("untitled:Untitled-1"))
+	require.Equal("main.go", scratchName("untitled:main.go"))
+	require.Equal("a_b_c.go", scratchName("untitled:a/b c"))
+}
+
+func TestScratchDocuments(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	root, err := filepath.Abs("testdata")
+	require.NoError(err)
+	project := cache.NewProject(context.Background(), nil, root, nil, nil)
+	s := newScratchDocuments()
+	s.reset(project)
+
+	params := json.RawMessage(`{"textDocument":{"uri":"untitled:Untitled-1"},"position":{"line":1,"character":2}}`)
+	req := s.request(&jsonrpc2.Request{Method: "textDocument/hover", Params: &params})
+	var translated lsp.TextDocumentPositionParams
+	require.NoError(json.Unmarshal(*req.Params, &translated))
+	file := source.ToURI(project.ScratchFilename("Untitled-1.go"))
+	require.Equal(lsp.DocumentURI(file), translated.TextDocument.URI)
+	require.Equal(lsp.Position{Line: 1, Character: 2}, translated.Position)
+
+	result, err := s.result(lsp.PublishDiagnosticsParams{URI: lsp.DocumentURI(file), Diagnostics: []lsp.Diagnostic{}})
+	require.NoError(err)
+	var diagnostics lsp.PublishDiagnosticsParams
+	require.NoError(json.Unmarshal(result.(json.RawMessage), &diagnostics))
+	require.Equal(lsp.DocumentURI("untitled:Untitled-1"), diagnostics.URI)
+
+	// The documents which are not untitled are left alone.
+	other := json.RawMessage(`{"textDocument":{"uri":"file:///a.go"}}`)
+	require.Equal(&other, s.request(&jsonrpc2.Request{Params: &other}).Params)
+}
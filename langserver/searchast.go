@@ -0,0 +1,136 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// SearchASTParams is the parameter of the bingo/searchAST request.
+type SearchASTParams struct {
+	// Pattern is a Go expression or statement whose $name identifiers are
+	// wildcards matching any expression, eg. "time.Now().Sub($x)". The
+	// wildcards of the same name match the same expression, except $_.
+	Pattern string `json:"pattern"`
+
+	// Types restricts the expressions matched by wildcards to a type, eg.
+	// {"x": "time.Time"}.
+	Types map[string]string `json:"types,omitempty"`
+}
+
+// searchWildcardPrefix replaces the $ of the wildcards, which is not valid
+// Go.
+const searchWildcardPrefix = "bingoWildcard_"
+
+var searchWildcardRegexp = regexp.MustCompile(`\$(\w+)`)
+
+func (h *LangHandler) handleSearchAST(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params SearchASTParams) ([]lsp.Location, error) {
+	pattern, err := parseSearchPattern(params.Pattern)
+	if err != nil {
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInvalidParams, err.Error())
+	}
+
+	locs := []lsp.Location{}
+	// The test variants of the packages share their files.
+	seen := map[string]bool{}
+	err = h.project.Search(func(pkg source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if pkg.GetTypesInfo() == nil {
+			return nil
+		}
+		fset := pkg.GetFileSet()
+		for _, f := range pkg.GetSyntax() {
+			filename := fset.Position(f.Pos()).Filename
+			uri := lsp.DocumentURI(source.ToURI(filename))
+			if seen[filename] || !h.project.Contain(uri) {
+				continue
+			}
+			seen[filename] = true
+			for _, n := range searchFile(pkg.GetTypesInfo(), f, pattern, params.Types) {
+				locs = append(locs, lsp.Location{URI: uri, Range: rangeForNode(fset, n)})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].URI != locs[j].URI {
+			return locs[i].URI < locs[j].URI
+		}
+		a, b := locs[i].Range.Start, locs[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
+	return locs, nil
+}
+
+// parseSearchPattern parses the expression or the statement pattern.
+func parseSearchPattern(pattern string) (ast.Node, error) {
+	src := searchWildcardRegexp.ReplaceAllString(pattern, searchWildcardPrefix+"$1")
+	if expr, err := parser.ParseExpr(src); err == nil {
+		return expr, nil
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p\nfunc _() {\n"+src+"\n}", 0)
+	if err != nil {
+		return nil, requestErrorf(InvalidParamsError, "pattern is neither an expression nor a statement")
+	}
+	body := f.Decls[0].(*ast.FuncDecl).Body.List
+	if len(body) != 1 {
+		return nil, requestErrorf(InvalidParamsError, "pattern must be a single expression or statement")
+	}
+	return body[0], nil
+}
+
+// searchWildcard returns the name of the search wildcard id.
+func searchWildcard(id string) (string, bool) {
+	if !strings.HasPrefix(id, searchWildcardPrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(id, searchWildcardPrefix)
+	if name == "_" {
+		return "", true
+	}
+	return name, true
+}
+
+// searchFile returns the expressions or the statements of f matching
+// pattern, including the nested ones.
+func searchFile(info *types.Info, f *ast.File, pattern ast.Node, filters map[string]string) []ast.Node {
+	_, isStmt := pattern.(ast.Stmt)
+	var nodes []ast.Node
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case ast.Expr:
+			if isStmt {
+				return true
+			}
+		case ast.Stmt:
+			if !isStmt {
+				return true
+			}
+		default:
+			return true
+		}
+		m := newRewriteMatcher(info, filters, searchWildcard)
+		if m.match(reflect.ValueOf(pattern), reflect.ValueOf(n), true) {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+	return nodes
+}
@@ -0,0 +1,51 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchFile(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fset, f, _, info := checkStubbedPackage(t, `package p
+
+import (
+	"errors"
+	"time"
+)
+
+func f(start time.Time, d time.Duration) error {
+	_ = time.Now().Sub(start)
+	_ = time.Now().Sub(time.Now().Add(d))
+	_ = start.Sub(start)
+	err := errors.New("x")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+`, nil)
+
+	search := func(pattern string, filters map[string]string) []string {
+		n, err := parseSearchPattern(pattern)
+		require.NoError(err)
+		var ranges []string
+		for _, n := range searchFile(info, f, n, filters) {
+			ranges = append(ranges, rangeForNode(fset, n).String())
+		}
+		return ranges
+	}
+
+	require.Equal([]string{"8:5-8:26", "9:5-9:38"}, search("time.Now().Sub($x)", nil))
+	require.Equal([]string{"10:5-10:21"}, search("$x.Sub($x)", nil))
+	require.Equal([]string{"8:5-8:26", "9:5-9:38", "10:5-10:21"}, search("$_.Sub($_)", nil))
+	require.Equal([]string{"9:20-9:37"}, search("$_.Add($x)", map[string]string{"x": "time.Duration"}))
+	require.Empty(search("$_.Sub($x)", map[string]string{"x": "time.Duration"}))
+	require.Equal([]string{"12:1-14:2"}, search("if $e != nil { return $e }", nil))
+
+	_, err := parseSearchPattern("a := 1; b := 2")
+	require.EqualError(err, "pattern must be a single expression or statement")
+}
@@ -0,0 +1,246 @@
+package langserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/span"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// sessionSaveDelay is how long we wait after the last change before writing
+// the session state to disk.
+const sessionSaveDelay = time.Second
+
+// sessionState is the part of the server state which survives a restart.
+type sessionState struct {
+	// Documents holds the digest of the content of every open document.
+	Documents map[lsp.DocumentURI]string `json:"documents"`
+
+	// Diagnostics holds the last published diagnostics of every file.
+	Diagnostics map[lsp.DocumentURI]publishedDiagnostics `json:"diagnostics"`
+}
+
+type publishedDiagnostics struct {
+	// Digest is the digest of the document content the diagnostics were
+	// computed for. It is empty if the document was not open.
+	Digest      string           `json:"digest,omitempty"`
+	Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+}
+
+// session keeps track of the open documents and of the published diagnostics
+// and persists them to a file, so that a restarted server does not republish
+// stale diagnostics and can warm the packages which were in use.
+type session struct {
+	mu       sync.Mutex
+	filename string
+	state    sessionState
+
+	// restored holds the state loaded at startup. It is consumed as the
+	// client reopens its documents.
+	restored sessionState
+
+	timer *time.Timer
+}
+
+// newSession loads the session state from filename. An empty filename
+// disables persistence, in which case newSession returns nil.
+func newSession(filename string) *session {
+	if filename == "" {
+		return nil
+	}
+
+	s := &session{
+		filename: filename,
+		state:    newSessionState(),
+		restored: newSessionState(),
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("read session state %s: %s", filename, err)
+		}
+		return s
+	}
+
+	if err := json.Unmarshal(data, &s.restored); err != nil {
+		log.Printf("parse session state %s: %s", filename, err)
+		s.restored = newSessionState()
+	}
+	return s
+}
+
+func newSessionState() sessionState {
+	return sessionState{
+		Documents:   make(map[lsp.DocumentURI]string),
+		Diagnostics: make(map[lsp.DocumentURI]publishedDiagnostics),
+	}
+}
+
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// openDocuments returns the documents which were open before the restart.
+func (s *session) openDocuments() []lsp.DocumentURI {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var uris []lsp.DocumentURI
+	for uri := range s.restored.Documents {
+		uris = append(uris, uri)
+	}
+	return uris
+}
+
+// open records the content of an opened document. If the document was open
+// before the restart with the same content, the diagnostics we published for
+// it back then are still valid and are returned.
+func (s *session) open(uri lsp.DocumentURI, content []byte) ([]lsp.Diagnostic, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := digest(content)
+	s.state.Documents[uri] = d
+	s.scheduleSave()
+
+	restored, ok := s.restored.Diagnostics[uri]
+	delete(s.restored.Documents, uri)
+	delete(s.restored.Diagnostics, uri)
+	if !ok || restored.Digest != d {
+		return nil, false
+	}
+
+	s.state.Diagnostics[uri] = restored
+	return restored.Diagnostics, true
+}
+
+// change records the new content of an open document.
+func (s *session) change(uri lsp.DocumentURI, content []byte) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.state.Documents[uri] = digest(content)
+	s.scheduleSave()
+	s.mu.Unlock()
+}
+
+// close forgets a closed document.
+func (s *session) close(uri lsp.DocumentURI) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.state.Documents, uri)
+	s.scheduleSave()
+	s.mu.Unlock()
+}
+
+// publish records the diagnostics about to be published for uri. It returns
+// false if they are identical to the ones last published, in which case they
+// do not need to be sent again.
+func (s *session) publish(uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.state.Documents[uri]
+	if last, ok := s.state.Diagnostics[uri]; ok && last.Digest == d && reflect.DeepEqual(last.Diagnostics, diagnostics) {
+		return false
+	}
+
+	s.state.Diagnostics[uri] = publishedDiagnostics{Digest: d, Diagnostics: diagnostics}
+	s.scheduleSave()
+	return true
+}
+
+// scheduleSave writes the session state to disk once it stops changing. It
+// assumes that the caller holds s.mu.
+func (s *session) scheduleSave() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(sessionSaveDelay, s.save)
+}
+
+func (s *session) save() {
+	s.mu.Lock()
+	data, err := json.Marshal(s.state)
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("marshal session state: %s", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.filename), 0755); err != nil {
+		log.Printf("write session state %s: %s", s.filename, err)
+		return
+	}
+
+	// Write to a temporary file first, so that a crash while saving does not
+	// leave a truncated state behind.
+	tmp := s.filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("write session state %s: %s", s.filename, err)
+		return
+	}
+	if err := os.Rename(tmp, s.filename); err != nil {
+		log.Printf("write session state %s: %s", s.filename, err)
+	}
+}
+
+// warmSession type checks the packages of the documents which were open
+// before the restart in the background.
+func warmSession(ctx context.Context, project *cache.Project, s *session) {
+	uris := s.openDocuments()
+	if len(uris) == 0 {
+		return
+	}
+
+	go func() {
+		for _, uri := range uris {
+			sourceURI := span.FromDocumentURI(uri)
+			filename, err := sourceURI.Filename()
+			if err != nil {
+				continue
+			}
+			if _, err := os.Stat(filename); err != nil {
+				continue
+			}
+
+			project.WarmUp(filename)
+			project.LoadTests(filename)
+			f, err := project.View().GetFile(ctx, sourceURI)
+			if err != nil {
+				continue
+			}
+			f.GetPackage(ctx)
+		}
+	}()
+}
@@ -0,0 +1,43 @@
+package langserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "bingo-session")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "session.json")
+	uri := lsp.DocumentURI("file:///src/a.go")
+	content := []byte("package a")
+	diagnostics := []lsp.Diagnostic{{Message: "undeclared name: x", Severity: lsp.Error}}
+
+	s := newSession(filename)
+	_, restored := s.open(uri, content)
+	require.False(restored)
+	require.True(s.publish(uri, diagnostics))
+	require.False(s.publish(uri, diagnostics), "identical diagnostics should not be republished")
+	s.save()
+
+	s = newSession(filename)
+	require.Equal([]lsp.DocumentURI{uri}, s.openDocuments())
+	got, restored := s.open(uri, content)
+	require.True(restored)
+	require.Equal(diagnostics, got)
+	require.False(s.publish(uri, diagnostics))
+
+	s = newSession(filename)
+	_, restored = s.open(uri, []byte("package b"))
+	require.False(restored, "diagnostics of a modified document are stale")
+}
@@ -0,0 +1,75 @@
+package langserver
+
+import (
+	"log"
+	"strings"
+
+	"github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/packages"
+)
+
+// The codes of the compiler diagnostics.
+const (
+	parseErrorCode     = "parseError"
+	typeErrorCode      = "typeError"
+	unusedVariableCode = "unusedVariable"
+	unusedImportCode   = "unusedImport"
+)
+
+// compilerErrorCode classifies a compiler error, so that its severity can be
+// remapped and it can be suppressed by its code.
+func compilerErrorCode(err packages.Error) string {
+	if err.Kind == packages.ParseError {
+		return parseErrorCode
+	}
+	switch {
+	case strings.Contains(err.Msg, "declared but not used"), strings.Contains(err.Msg, "declared and not used"):
+		return unusedVariableCode
+	case strings.Contains(err.Msg, "imported but not used"), strings.Contains(err.Msg, "imported and not used"):
+		return unusedImportCode
+	}
+	return typeErrorCode
+}
+
+var severityNames = map[string]lsp.DiagnosticSeverity{
+	"error":       lsp.Error,
+	"warning":     lsp.Warning,
+	"information": lsp.Information,
+	"info":        lsp.Information,
+	"hint":        lsp.Hint,
+}
+
+// severityMap maps diagnostic codes and sources to the severity of their
+// diagnostics.
+type severityMap map[string]lsp.DiagnosticSeverity
+
+// newSeverityMap parses Config.DiagnosticsSeverity. Unknown severities are
+// logged and ignored.
+func newSeverityMap(severities map[string]string) severityMap {
+	m := severityMap{}
+	for key, name := range severities {
+		severity, ok := severityNames[strings.ToLower(name)]
+		if !ok {
+			log.Printf("unknown severity %q of %q diagnostics", name, key)
+			continue
+		}
+		m[key] = severity
+	}
+	return m
+}
+
+// remap sets the severity of the diagnostics whose code or, failing that,
+// source is in m.
+func (m severityMap) remap(diagnostics []lsp.Diagnostic) {
+	if len(m) == 0 {
+		return
+	}
+	for i := range diagnostics {
+		d := &diagnostics[i]
+		if severity, ok := m[d.Code]; ok && d.Code != "" {
+			d.Severity = severity
+		} else if severity, ok := m[d.Source]; ok {
+			d.Severity = severity
+		}
+	}
+}
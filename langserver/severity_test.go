@@ -0,0 +1,41 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestCompilerErrorCode(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.Equal(parseErrorCode, compilerErrorCode(packages.Error{Kind: packages.ParseError, Msg: "expected ';'"}))
+	require.Equal(unusedVariableCode, compilerErrorCode(packages.Error{Kind: packages.TypeError, Msg: "x declared but not used"}))
+	require.Equal(unusedImportCode, compilerErrorCode(packages.Error{Kind: packages.TypeError, Msg: `"fmt" imported but not used`}))
+	require.Equal(typeErrorCode, compilerErrorCode(packages.Error{Kind: packages.TypeError, Msg: "undeclared name: x"}))
+}
+
+func TestSeverityMapRemap(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	m := newSeverityMap(map[string]string{
+		"unusedVariable":   "hint",
+		"LSP: Go compiler": "Warning",
+		"typeError":        "fatal",
+	})
+	require.Len(m, 2)
+
+	diagnostics := []lsp.Diagnostic{
+		{Severity: lsp.Error, Code: unusedVariableCode, Source: "LSP: Go compiler"},
+		{Severity: lsp.Error, Code: typeErrorCode, Source: "LSP: Go compiler"},
+		{Severity: lsp.Error, Source: "vet"},
+	}
+	m.remap(diagnostics)
+	require.Equal(lsp.DiagnosticSeverity(lsp.Hint), diagnostics[0].Severity)
+	require.Equal(lsp.DiagnosticSeverity(lsp.Warning), diagnostics[1].Severity)
+	require.Equal(lsp.DiagnosticSeverity(lsp.Error), diagnostics[2].Severity)
+}
@@ -0,0 +1,192 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The phases of a slow request, besides cache.LoadPhase and
+// cache.TypeCheckPhase.
+const (
+	// walkPhase is the rest of the handling of the request, eg. the walk of
+	// the syntax trees and of the type information.
+	walkPhase cache.Phase = "walk"
+
+	// encodePhase is the encoding of the result in JSON.
+	encodePhase cache.Phase = "encode"
+)
+
+// maxSlowRequestProfile bounds the CPU profile of a slow request.
+const maxSlowRequestProfile = 30 * time.Second
+
+// SlowRequest is the structured record logged for the requests which take
+// longer than Config.SlowRequestThreshold.
+type SlowRequest struct {
+	Method string    `json:"method"`
+	ID     string    `json:"id,omitempty"`
+	Start  time.Time `json:"start"`
+	Millis float64   `json:"millis"`
+
+	// Phases are the milliseconds spent in the load, the typecheck, the walk
+	// and the encode phases.
+	Phases map[cache.Phase]float64 `json:"phases"`
+	Error  string                  `json:"error,omitempty"`
+
+	// Profile is the CPU profile of the request from the moment it exceeded
+	// the threshold, if Config.SlowRequestProfileDir is set.
+	Profile string `json:"profile,omitempty"`
+}
+
+// slowRequests logs the requests which take longer than threshold.
+type slowRequests struct {
+	threshold  time.Duration
+	profileDir string
+}
+
+// newSlowRequests returns the log of the requests which take longer than
+// thresholdMillis, or nil if it is not positive.
+func newSlowRequests(thresholdMillis int, profileDir string) *slowRequests {
+	if thresholdMillis <= 0 {
+		return nil
+	}
+	return &slowRequests{threshold: time.Duration(thresholdMillis) * time.Millisecond, profileDir: profileDir}
+}
+
+// slowRequest is a request being timed.
+type slowRequest struct {
+	log    *slowRequests
+	req    *jsonrpc2.Request
+	start  time.Time
+	phases *cache.Phases
+	timer  *time.Timer
+
+	mu      sync.Mutex
+	done    bool
+	profile *os.File
+	name    string // of the profile
+}
+
+// start starts timing req, and returns the context recording the time spent
+// in its phases.
+func (s *slowRequests) start(ctx context.Context, req *jsonrpc2.Request) (context.Context, *slowRequest) {
+	if s == nil || req.Notif {
+		return ctx, nil
+	}
+	r := &slowRequest{log: s, req: req, start: time.Now()}
+	ctx, r.phases = cache.WithPhases(ctx)
+	if s.profileDir != "" {
+		r.timer = time.AfterFunc(s.threshold, r.startProfile)
+	}
+	return ctx, r
+}
+
+// end logs the request if it was slow. The result is encoded, so that the
+// time of the encoding is known, and returned encoded.
+func (r *slowRequest) end(result interface{}, err error) (interface{}, error) {
+	if r == nil {
+		return result, err
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+
+	encodeStart := time.Now()
+	if err == nil && result != nil {
+		if data, merr := json.Marshal(result); merr == nil {
+			raw := json.RawMessage(data)
+			result = &raw
+		}
+	}
+	encode := time.Since(encodeStart)
+	profile := r.stopProfile()
+
+	total := time.Since(r.start)
+	if total < r.log.threshold {
+		if profile != "" {
+			os.Remove(profile)
+		}
+		return result, err
+	}
+
+	durations := r.phases.Durations()
+	durations[encodePhase] = encode
+	walk := total
+	for _, d := range durations {
+		walk -= d
+	}
+	if walk < 0 {
+		walk = 0
+	}
+	durations[walkPhase] = walk
+
+	record := SlowRequest{
+		Method:  r.req.Method,
+		ID:      r.req.ID.String(),
+		Start:   r.start,
+		Millis:  durationMillis(total),
+		Phases:  map[cache.Phase]float64{},
+		Profile: profile,
+	}
+	for phase, d := range durations {
+		record.Phases[phase] = durationMillis(d)
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if data, merr := json.Marshal(record); merr == nil {
+		log.Printf("slow request: %s", data)
+	}
+	return result, err
+}
+
+// startProfile starts the CPU profile of the request, unless a profile of
+// the process is already running, eg. the one of another slow request.
+func (r *slowRequest) startProfile() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return
+	}
+
+	f, err := ioutil.TempFile(r.log.profileDir, "bingo-"+strings.Replace(r.req.Method, "/", "-", -1)+"-*.pprof")
+	if err != nil {
+		log.Printf("slow request profile: %s", err)
+		return
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return
+	}
+	r.profile, r.name = f, f.Name()
+	time.AfterFunc(maxSlowRequestProfile, func() { r.stopProfile() })
+}
+
+// stopProfile stops the CPU profile of the request, and returns its file, if
+// any.
+func (r *slowRequest) stopProfile() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done = true
+	if r.profile != nil {
+		pprof.StopCPUProfile()
+		r.profile.Close()
+		r.profile = nil
+	}
+	return r.name
+}
+
+// durationMillis returns d in milliseconds.
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
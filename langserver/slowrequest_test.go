@@ -0,0 +1,66 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestSlowRequests(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	slow := newSlowRequests(10, "")
+	req := &jsonrpc2.Request{Method: "textDocument/hover", ID: jsonrpc2.ID{Num: 7}}
+
+	// A fast request is not logged, but its result is encoded.
+	_, r := slow.start(context.Background(), req)
+	result, err := r.end([]string{"fast"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, ok := result.(*json.RawMessage); !ok || string(*raw) != `["fast"]` {
+		t.Errorf("result of the fast request: %v", result)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("fast request logged: %s", buf.String())
+	}
+
+	_, r = slow.start(context.Background(), req)
+	r.phases.Add(cache.LoadPhase, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	r.end("slow", nil)
+
+	line := buf.String()
+	i := strings.Index(line, "slow request: ")
+	if i < 0 {
+		t.Fatalf("slow request not logged: %q", line)
+	}
+	var record SlowRequest
+	if err := json.Unmarshal([]byte(line[i+len("slow request: "):]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Method != "textDocument/hover" || record.ID != "7" || record.Millis < 20 {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if record.Phases[cache.LoadPhase] != 5 || record.Phases[walkPhase] < 10 {
+		t.Errorf("unexpected phases %v", record.Phases)
+	}
+	if _, ok := record.Phases[encodePhase]; !ok {
+		t.Errorf("no encode phase in %v", record.Phases)
+	}
+
+	// Nothing is timed without a threshold.
+	if _, r := newSlowRequests(0, "").start(context.Background(), req); r != nil {
+		t.Error("request timed without a threshold")
+	}
+}
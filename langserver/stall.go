@@ -0,0 +1,184 @@
+package langserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// maxStalledBytes bounds the messages buffered for a client which does not
+// read them, beyond which the client is disconnected.
+const maxStalledBytes = 64 << 20
+
+// stallStream is a jsonrpc2.ObjectStream whose messages are buffered and
+// written by a goroutine of their own, so that a client which stops reading
+// its input does not block the handlers sending it notifications. The
+// connection is closed once a message has not been read for the timeout, or
+// once more than maxStalledBytes are buffered.
+type stallStream struct {
+	jsonrpc2.ObjectStream
+	timeout time.Duration
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    [][]byte
+	buffered int
+	closing  bool
+	err      error // set once the stream has failed or is closed
+
+	// failed is closed once err is set, written is closed once the writer
+	// goroutine returns.
+	failed  chan struct{}
+	written chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// WithStallTimeout returns the stream s whose client is disconnected once it
+// has not read a message for the timeout, or s itself if the timeout is not
+// positive.
+func WithStallTimeout(s jsonrpc2.ObjectStream, timeout time.Duration) jsonrpc2.ObjectStream {
+	if timeout <= 0 {
+		return s
+	}
+	st := &stallStream{
+		ObjectStream: s,
+		timeout:      timeout,
+		failed:       make(chan struct{}),
+		written:      make(chan struct{}),
+	}
+	st.cond = sync.NewCond(&st.mu)
+	go st.write()
+	return st
+}
+
+// WriteObject implements jsonrpc2.ObjectStream. It only buffers the message.
+func (s *stallStream) WriteObject(obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.err != nil {
+		err := s.err
+		s.mu.Unlock()
+		return err
+	}
+	if s.buffered+len(data) > maxStalledBytes {
+		s.mu.Unlock()
+		return s.fail(fmt.Errorf("client stalled: more than %d MB of messages are not read", maxStalledBytes>>20))
+	}
+	s.queue = append(s.queue, data)
+	s.buffered += len(data)
+	s.cond.Signal()
+	s.mu.Unlock()
+	return nil
+}
+
+// write writes the buffered messages to the stream, until it fails or is
+// closed and its messages are written.
+func (s *stallStream) write() {
+	defer close(s.written)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && s.err == nil && !s.closing {
+			s.cond.Wait()
+		}
+		if s.err != nil || len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		data := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		timer := time.AfterFunc(s.timeout, func() {
+			s.fail(fmt.Errorf("client stalled: a message was not read for %s", s.timeout))
+		})
+		raw := json.RawMessage(data)
+		err := s.ObjectStream.WriteObject(&raw)
+		timer.Stop()
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		s.mu.Lock()
+		s.buffered -= len(data)
+		s.mu.Unlock()
+	}
+}
+
+// ReadObject implements jsonrpc2.ObjectStream. It returns the error of the
+// stream once it has failed, even if the read of the underlying stream does
+// not return, so that the connection is closed.
+func (s *stallStream) ReadObject(v interface{}) error {
+	read := make(chan error, 1)
+	go func() {
+		read <- s.ObjectStream.ReadObject(v)
+	}()
+	select {
+	case err := <-read:
+		return err
+	case <-s.failed:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.err
+	}
+}
+
+// fail closes the stream with err, unless it has already failed, and
+// returns the error it failed with.
+func (s *stallStream) fail(err error) error {
+	s.mu.Lock()
+	if s.err != nil {
+		err := s.err
+		s.mu.Unlock()
+		return err
+	}
+	s.err = err
+	s.queue = nil
+	s.buffered = 0
+	close(s.failed)
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	if err != jsonrpc2.ErrClosed {
+		log.Printf("jsonrpc2: %s, closing the connection", err)
+	}
+	s.closeStream()
+	return err
+}
+
+// closeStream closes the underlying stream, which unblocks its writes.
+func (s *stallStream) closeStream() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.ObjectStream.Close()
+	})
+	return s.closeErr
+}
+
+// Close implements jsonrpc2.ObjectStream. The buffered messages are written
+// first, eg. the response to the shutdown request, for at most the timeout.
+func (s *stallStream) Close() error {
+	s.mu.Lock()
+	s.closing = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case <-s.written:
+	case <-timer.C:
+	}
+	s.fail(jsonrpc2.ErrClosed)
+	return s.closeStream()
+}
@@ -0,0 +1,74 @@
+package langserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStallStream(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	server, client := net.Pipe()
+	defer client.Close()
+	stream, err := NewStream(server, LineFraming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := WithStallTimeout(stream, 100*time.Millisecond)
+
+	// The messages are written in order while the client reads them.
+	r := bufio.NewReader(client)
+	for _, method := range []string{"a", "b"} {
+		if err := s.WriteObject(map[string]string{"method": method}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"a", "b"} {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]string
+		if err := json.Unmarshal(line, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got["method"] != want {
+			t.Errorf("got method %q, want %q", got["method"], want)
+		}
+	}
+
+	// The writes do not block once the client stops reading, until it is
+	// disconnected.
+	read := make(chan error, 1)
+	go func() {
+		var v interface{}
+		read <- s.ReadObject(&v)
+	}()
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := s.WriteObject(map[string]int{"i": i}); err != nil {
+			t.Fatalf("write %d: %s", i, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("the writes took %s, want them buffered", elapsed)
+	}
+	select {
+	case err := <-read:
+		if err == nil {
+			t.Error("got no read error, want the stall error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream was not closed after the stall timeout")
+	}
+	if err := s.WriteObject(map[string]int{"i": 10}); err == nil {
+		t.Error("got no write error after the stall, want one")
+	}
+}
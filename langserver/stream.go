@@ -0,0 +1,220 @@
+package langserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The framings of the messages of a stream, see NewStream.
+const (
+	// HeaderFraming precedes every message with a Content-Length header, as
+	// the base protocol of LSP does.
+	HeaderFraming = "header"
+
+	// LineFraming writes every message on a line of its own, for the
+	// clients which do not implement the base protocol.
+	LineFraming = "line"
+)
+
+// maxContentLength bounds the length of a message, larger ones being
+// considered as garbage.
+const maxContentLength = 256 << 20
+
+// stream is a jsonrpc2.ObjectStream which survives the garbage written by a
+// client: the bytes which are not a message are skipped up to the next
+// header, and the messages which are not JSON are answered with a parse
+// error, so that the connection is only closed at the end of the input.
+type stream struct {
+	r       *bufio.Reader
+	line    bool
+	closer  io.Closer
+	writeMu sync.Mutex
+	w       io.Writer
+}
+
+// NewStream returns the stream of the JSON-RPC messages of rwc with the
+// framing, HeaderFraming or LineFraming.
+func NewStream(rwc io.ReadWriteCloser, framing string) (jsonrpc2.ObjectStream, error) {
+	s := &stream{r: bufio.NewReader(rwc), closer: rwc, w: rwc}
+	switch framing {
+	case HeaderFraming, "":
+	case LineFraming:
+		s.line = true
+	default:
+		return nil, fmt.Errorf("invalid framing %q", framing)
+	}
+	return s, nil
+}
+
+// WriteObject implements jsonrpc2.ObjectStream.
+func (s *stream) WriteObject(obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return s.write(data)
+}
+
+// write writes the message data, which is written at once so that the
+// messages written concurrently do not interleave.
+func (s *stream) write(data []byte) error {
+	var buf bytes.Buffer
+	if s.line {
+		buf.Grow(len(data) + 1)
+		buf.Write(data)
+		buf.WriteByte('\n')
+	} else {
+		fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(data))
+		buf.Write(data)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// ReadObject implements jsonrpc2.ObjectStream. It only returns an error once
+// the input ends or fails, io.EOF if it ends between two messages.
+func (s *stream) ReadObject(v interface{}) error {
+	for {
+		var data []byte
+		var err error
+		if s.line {
+			data, err = s.readLine()
+		} else {
+			data, err = s.readContent()
+		}
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			log.Printf("jsonrpc2: skipped a message which is not JSON-RPC: %s", err)
+			s.parseError(err)
+			continue
+		}
+		return nil
+	}
+}
+
+// parseError answers a message which could not be parsed, whose ID is not
+// known, as JSON-RPC requires.
+func (s *stream) parseError(err error) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error":   &jsonrpc2.Error{Code: jsonrpc2.CodeParseError, Message: err.Error()},
+	})
+	if err := s.write(data); err != nil {
+		log.Printf("jsonrpc2: %s", err)
+	}
+}
+
+// readLine returns the next non-empty line of the input.
+func (s *stream) readLine() ([]byte, error) {
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			// The last line may lack its newline.
+			return trimmed, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readContent returns the content of the next message framed by a
+// Content-Length header. The lines which are not headers are skipped, up to
+// the next Content-Length header.
+func (s *stream) readContent() ([]byte, error) {
+	length := -1
+	skipped := 0
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && (length >= 0 || strings.TrimSpace(line) != "") {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if i := strings.Index(strings.ToLower(line), "content-length:"); i > 0 {
+			// Garbage before a header, on its line.
+			skipped += i
+			line = line[i:]
+		}
+
+		if line == "" {
+			if length >= 0 {
+				break
+			}
+			// An empty line before any header.
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			// Garbage, or a header split by a lone carriage return.
+			skipped += len(line)
+			length = -1
+			continue
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if !strings.EqualFold(name, "Content-Length") {
+			if length < 0 {
+				// Garbage before the Content-Length header, unless it is
+				// another header, eg. Content-Type, which may come first.
+				if !isHeaderName(name) {
+					skipped += len(line)
+				}
+			}
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxContentLength {
+			log.Printf("jsonrpc2: skipped an invalid Content-Length header %q", value)
+			length = -1
+			continue
+		}
+		length = n
+	}
+	if skipped > 0 {
+		log.Printf("jsonrpc2: skipped %d bytes which are not a message", skipped)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// isHeaderName reports whether name is a valid name of a header.
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Close implements jsonrpc2.ObjectStream.
+func (s *stream) Close() error {
+	return s.closer.Close()
+}
@@ -0,0 +1,113 @@
+package langserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pipe is an io.ReadWriteCloser reading the input and writing to output.
+type pipe struct {
+	io.Reader
+	output bytes.Buffer
+}
+
+func (p *pipe) Write(data []byte) (int, error) { return p.output.Write(data) }
+func (p *pipe) Close() error                   { return nil }
+
+// readObjects reads the objects of the input until an error, and returns
+// them with the output of the stream.
+func readObjects(t *testing.T, framing, input string) ([]map[string]interface{}, string, error) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	p := &pipe{Reader: strings.NewReader(input)}
+	s, err := NewStream(p, framing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objects []map[string]interface{}
+	for {
+		var v map[string]interface{}
+		if err := s.ReadObject(&v); err != nil {
+			return objects, p.output.String(), err
+		}
+		objects = append(objects, v)
+	}
+}
+
+func methods(objects []map[string]interface{}) []string {
+	var methods []string
+	for _, object := range objects {
+		methods = append(methods, object["method"].(string))
+	}
+	return methods
+}
+
+func TestStreamHeaderFraming(t *testing.T) {
+	message := func(method string) string {
+		body := `{"jsonrpc":"2.0","method":"` + method + `"}`
+		return "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	}
+	input := message("a") +
+		// Another header, and a header split across lines without \r.
+		"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\n" + strings.Replace(message("b"), "\r\n", "\n", -1) +
+		// Garbage, on its own lines and before a header.
+		"npm WARN something\nmore garbage" + message("c") +
+		// An invalid length.
+		"Content-Length: x\r\n\r\n" + message("d") +
+		// A message which is not JSON.
+		"Content-Length: 5\r\n\r\nnot j" + message("e")
+
+	objects, output, err := readObjects(t, HeaderFraming, input)
+	if err != io.EOF {
+		t.Errorf("got the error %v, want EOF", err)
+	}
+	if got, want := methods(objects), []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the methods %v, want %v", got, want)
+	}
+	if !strings.Contains(output, `"code":-32700`) || !strings.HasPrefix(output, "Content-Length: ") {
+		t.Errorf("no parse error written: %q", output)
+	}
+
+	// The input ends in the middle of a message.
+	_, _, err = readObjects(t, HeaderFraming, message("a")+"Content-Length: 10\r\n\r\n{")
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got the error %v, want unexpected EOF", err)
+	}
+}
+
+func TestStreamLineFraming(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"a"}` + "\n\r\n" + "garbage\n" + `{"jsonrpc":"2.0","method":"b"}`
+	objects, output, err := readObjects(t, LineFraming, input)
+	if err != io.EOF {
+		t.Errorf("got the error %v, want EOF", err)
+	}
+	if got, want := methods(objects), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the methods %v, want %v", got, want)
+	}
+	var parseError map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &parseError); err != nil || parseError["error"] == nil {
+		t.Errorf("no parse error written: %q", output)
+	}
+
+	p := &pipe{Reader: strings.NewReader("")}
+	s, _ := NewStream(p, LineFraming)
+	if err := s.WriteObject(map[string]string{"method": "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.output.String(); got != `{"method":"c"}`+"\n" {
+		t.Errorf("got the output %q", got)
+	}
+
+	if _, err := NewStream(p, "xml"); err == nil {
+		t.Error("no error for an invalid framing")
+	}
+}
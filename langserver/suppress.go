@@ -0,0 +1,251 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// defaultNolintMarker is the marker of the //nolint:<code> directives when
+// Config.NolintMarker is empty.
+const defaultNolintMarker = "nolint"
+
+// suppression is a directive which suppresses the diagnostics of its codes,
+// or all of them if it has none.
+type suppression struct {
+	codes []string
+}
+
+func (s suppression) matches(d lsp.Diagnostic) bool {
+	if len(s.codes) == 0 {
+		return true
+	}
+	for _, code := range s.codes {
+		if code == "all" || (d.Code != "" && strings.EqualFold(code, d.Code)) || strings.EqualFold(code, d.Source) {
+			return true
+		}
+	}
+	return false
+}
+
+// suppressions are the suppression directives of a file.
+type suppressions struct {
+	// file are the //lint:file-ignore directives.
+	file []suppression
+
+	// lines are the directives applying to a line, by 0 based line.
+	lines map[int][]suppression
+}
+
+// parseSuppressions scans the comments of content for suppression
+// directives:
+//
+//	x := f() //nolint:code1,code2  suppresses the diagnostics of the line
+//	//nolint:code                  on its own line, of the next line
+//	//lint:ignore code reason      of the next line
+//	//lint:file-ignore code reason of the file
+//
+// A //nolint directive without codes suppresses all diagnostics. The content
+// does not have to parse.
+func parseSuppressions(content []byte, marker string) *suppressions {
+	s := &suppressions{lines: map[int][]suppression{}}
+	if !bytes.Contains(content, []byte("//")) {
+		return s
+	}
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(content))
+	var sc scanner.Scanner
+	sc.Init(file, content, nil, scanner.ScanComments)
+
+	codeLine := -1 // the last line with a token
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		line := file.Line(pos) - 1
+		if tok != token.COMMENT {
+			if tok != token.SEMICOLON || lit != "\n" {
+				codeLine = line
+			}
+			continue
+		}
+		if !strings.HasPrefix(lit, "//") {
+			continue
+		}
+
+		text := strings.TrimSpace(lit[2:])
+		switch {
+		case strings.HasPrefix(text, "lint:ignore "):
+			s.lines[line+1] = append(s.lines[line+1], suppression{codes: directiveCodes(text[len("lint:ignore "):])})
+		case strings.HasPrefix(text, "lint:file-ignore "):
+			s.file = append(s.file, suppression{codes: directiveCodes(text[len("lint:file-ignore "):])})
+		case strings.HasPrefix(text, marker):
+			rest := text[len(marker):]
+			var codes []string
+			if strings.HasPrefix(rest, ":") {
+				codes = directiveCodes(rest[1:])
+			} else if rest != "" && rest[0] != ' ' {
+				// eg. //nolintfoo
+				continue
+			}
+			target := line
+			if codeLine != line {
+				target = line + 1
+			}
+			s.lines[target] = append(s.lines[target], suppression{codes: codes})
+		}
+	}
+	return s
+}
+
+// directiveCodes returns the comma separated codes at the start of text.
+func directiveCodes(text string) []string {
+	if i := strings.IndexAny(text, " \t"); i >= 0 {
+		text = text[:i]
+	}
+	var codes []string
+	for _, code := range strings.Split(text, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// filter returns the diagnostics which are not suppressed.
+func (s *suppressions) filter(diagnostics []lsp.Diagnostic) []lsp.Diagnostic {
+	if len(s.file) == 0 && len(s.lines) == 0 {
+		return diagnostics
+	}
+	kept := []lsp.Diagnostic{}
+	for _, d := range diagnostics {
+		if !s.suppressed(d) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+func (s *suppressions) suppressed(d lsp.Diagnostic) bool {
+	for _, sup := range s.file {
+		if sup.matches(d) {
+			return true
+		}
+	}
+	for _, sup := range s.lines[d.Range.Start.Line] {
+		if sup.matches(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// suppressDiagnostics removes the diagnostics of filename suppressed by its
+// directives.
+func (h *overlay) suppressDiagnostics(ctx context.Context, filename string, diagnostics []lsp.Diagnostic) []lsp.Diagnostic {
+	if len(diagnostics) == 0 {
+		return diagnostics
+	}
+	f, err := h.view().GetFile(ctx, span.FileURI(filename))
+	if err != nil {
+		return diagnostics
+	}
+	return parseSuppressions(f.GetContent(ctx), h.nolintMarker).filter(diagnostics)
+}
+
+// suppressActions returns the quick fixes which append a //nolint:<code>
+// directive to the lines of the diagnostics.
+func (h *LangHandler) suppressActions(ctx context.Context, uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) []protocol.CodeAction {
+	if len(diagnostics) == 0 {
+		return nil
+	}
+	f, err := h.View().GetFile(ctx, span.FromDocumentURI(uri))
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(string(f.GetContent(ctx)), "\n")
+
+	var actions []protocol.CodeAction
+	for _, d := range diagnostics {
+		if d.Code == "" || d.Code == parseErrorCode || d.Range.Start.Line >= len(lines) {
+			continue
+		}
+		edit := suppressEdit(lines[d.Range.Start.Line], d.Range.Start.Line, h.nolintMarker(), d.Code)
+		actions = append(actions, protocol.CodeAction{
+			Title:       fmt.Sprintf("Suppress %s with //%s:%s", d.Code, h.nolintMarker(), d.Code),
+			Kind:        protocol.QuickFix,
+			Diagnostics: []lsp.Diagnostic{d},
+			Edit: lsp.WorkspaceEdit{
+				Changes: map[string][]lsp.TextEdit{
+					string(uri): {edit},
+				},
+			},
+		})
+	}
+	return actions
+}
+
+// suppressEdit returns the edit which suppresses code on line: the code is
+// added to the //marker: directive of the line, or a new directive is
+// inserted before the trailing comment of the line, which would otherwise
+// contain it, or appended to the line.
+func suppressEdit(text string, line int, marker, code string) lsp.TextEdit {
+	text = strings.TrimSuffix(text, "\r")
+	directive := "//" + marker + ":"
+	if i := strings.LastIndex(text, directive); i >= 0 {
+		end := i + len(directive)
+		if j := strings.IndexAny(text[end:], " \t"); j >= 0 {
+			end += j
+		} else {
+			end = len(text)
+		}
+		pos := lsp.Position{Line: line, Character: utf16Len(text[:end])}
+		return lsp.TextEdit{Range: lsp.Range{Start: pos, End: pos}, NewText: "," + code}
+	}
+	if i := trailingComment(text); i >= 0 {
+		pos := lsp.Position{Line: line, Character: utf16Len(text[:i])}
+		return lsp.TextEdit{Range: lsp.Range{Start: pos, End: pos}, NewText: directive + code + " "}
+	}
+	pos := lsp.Position{Line: line, Character: utf16Len(text)}
+	return lsp.TextEdit{Range: lsp.Range{Start: pos, End: pos}, NewText: " " + directive + code}
+}
+
+// trailingComment returns the offset of the // comment which ends the line
+// text, or -1 if there is none.
+func trailingComment(text string) int {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(text))
+	var sc scanner.Scanner
+	sc.Init(file, []byte(text), nil, scanner.ScanComments)
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			return -1
+		}
+		if tok == token.COMMENT && strings.HasPrefix(lit, "//") {
+			return file.Offset(pos)
+		}
+	}
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// nolintMarker returns the marker of the //nolint directives.
+func (h *LangHandler) nolintMarker() string {
+	if h.config.NolintMarker != "" {
+		return h.config.NolintMarker
+	}
+	return defaultNolintMarker
+}
@@ -0,0 +1,77 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSuppressions(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	const src = `package p
+
+import "fmt" //nolint:unusedImport
+
+func f() {
+	//nolint
+	x := 1
+	y := "//nolint" // not a directive
+	//lint:ignore unusedVariable,SA1000 legacy code
+	z := 2
+	w := 3 //nolintfoo
+	//nolint:vet parse errors do not matter (
+`
+	s := parseSuppressions([]byte(src), defaultNolintMarker)
+
+	diagnostic := func(line int, code string) lsp.Diagnostic {
+		return lsp.Diagnostic{
+			Range:  lsp.Range{Start: lsp.Position{Line: line}},
+			Code:   code,
+			Source: "LSP: Go compiler",
+		}
+	}
+	require.True(s.suppressed(diagnostic(2, unusedImportCode)))
+	require.False(s.suppressed(diagnostic(2, typeErrorCode)))
+	require.True(s.suppressed(diagnostic(6, typeErrorCode)))
+	require.False(s.suppressed(diagnostic(7, unusedVariableCode)))
+	require.True(s.suppressed(diagnostic(9, "unusedvariable")))
+	require.False(s.suppressed(diagnostic(10, unusedVariableCode)))
+	require.True(s.suppressed(lsp.Diagnostic{Range: lsp.Range{Start: lsp.Position{Line: 12}}, Source: "vet"}))
+
+	kept := s.filter([]lsp.Diagnostic{diagnostic(2, unusedImportCode), diagnostic(7, unusedVariableCode)})
+	require.Equal([]lsp.Diagnostic{diagnostic(7, unusedVariableCode)}, kept)
+}
+
+func TestFileSuppressions(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	s := parseSuppressions([]byte("//lint:file-ignore typeError generated\npackage p\n"), "lint:ignore-line")
+	require.True(s.suppressed(lsp.Diagnostic{Range: lsp.Range{Start: lsp.Position{Line: 7}}, Code: typeErrorCode}))
+	require.False(s.suppressed(lsp.Diagnostic{Code: unusedVariableCode}))
+}
+
+func TestSuppressEdit(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	edit := suppressEdit("\tx := \"é\"", 3, "nolint", unusedVariableCode)
+	require.Equal(lsp.Position{Line: 3, Character: 9}, edit.Range.Start)
+	require.Equal(" //nolint:unusedVariable", edit.NewText)
+
+	edit = suppressEdit("import \"fmt\" //nolint:vet because\r", 1, "nolint", unusedImportCode)
+	require.Equal(lsp.Position{Line: 1, Character: 25}, edit.Range.Start)
+	require.Equal(",unusedImport", edit.NewText)
+
+	// The directive is not appended to the trailing comment of the line.
+	line := "\tx := \"//\" // the answer"
+	edit = suppressEdit(line, 2, "nolint", unusedVariableCode)
+	require.Equal(lsp.Position{Line: 2, Character: 11}, edit.Range.Start)
+	require.Equal("//nolint:unusedVariable ", edit.NewText)
+	fixed := line[:11] + edit.NewText + line[11:]
+	s := parseSuppressions([]byte("package p\n\n"+fixed+"\n"), defaultNolintMarker)
+	require.True(s.suppressed(lsp.Diagnostic{Range: lsp.Range{Start: lsp.Position{Line: 2}}, Code: unusedVariableCode}), fixed)
+}
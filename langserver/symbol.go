@@ -356,7 +356,11 @@ func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2,
 		results.results = results.results[:limit]
 	}
 
-	return results.Results(), nil
+	symbols := results.Results()
+	if h.config.CompleteUnexported {
+		markUnexported(symbols)
+	}
+	return symbols, nil
 }
 
 // collectFromPkg collects all the symbols from the specified package
@@ -374,6 +378,12 @@ func (h *LangHandler) collectFromPkg(pkg source.Package, results *resultSorter)
 		}
 		results.Collect(sym)
 	}
+
+	if h.config.RouteIndex {
+		for _, sym := range routeSymbols(pkg) {
+			results.collectRoute(sym)
+		}
+	}
 }
 
 // SymbolCollector stores symbol information for an AST
@@ -0,0 +1,221 @@
+package langserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// The codes of the diagnostics of the struct tag schema.
+const (
+	missingTagCode   = "missingTag"
+	duplicateTagCode = "duplicateTag"
+)
+
+// tagSchemaSource is the source of the diagnostics of the struct tag schema.
+const tagSchemaSource = "tagschema"
+
+// tagSchema is the content of Config.TagSchemaFile: the rules the struct tags
+// of the workspace are checked against.
+type tagSchema struct {
+	Rules []tagRule `json:"rules"`
+}
+
+// tagRule is a rule of the tags of the fields of some structs, eg.
+//
+//	{"types": ["*Config"], "required": ["yaml", "default"], "unique": ["yaml", "env"]}
+type tagRule struct {
+	// Types are the path.Match patterns of the names of the struct types the
+	// rule applies to.
+	Types []string `json:"types"`
+
+	// Packages are the path.Match patterns of the import paths of the
+	// packages the rule applies to, all of them if empty.
+	Packages []string `json:"packages,omitempty"`
+
+	// Required are the keys of the tags every exported field must have. A
+	// field ignored by one of them, eg. `yaml:"-"`, is not checked.
+	Required []string `json:"required,omitempty"`
+
+	// Unique are the keys of the tags whose names must not be repeated in a
+	// struct.
+	Unique []string `json:"unique,omitempty"`
+}
+
+// tagSchemaFile returns the path of Config.TagSchemaFile, which is relative
+// to the root of the workspace.
+func (c *Config) tagSchemaFile(rootPath string) string {
+	if c.TagSchemaFile == "" || filepath.IsAbs(c.TagSchemaFile) {
+		return c.TagSchemaFile
+	}
+	return filepath.Join(rootPath, c.TagSchemaFile)
+}
+
+// loadTagSchema reads the schema filename. Errors are logged, and disable the
+// check.
+func loadTagSchema(filename string) *tagSchema {
+	if filename == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Printf("failed to read the tag schema: %s", err)
+		return nil
+	}
+	var schema tagSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		log.Printf("invalid tag schema %s: %s", filename, err)
+		return nil
+	}
+	return &schema
+}
+
+// tagError is a violation of a rule of the tag schema.
+type tagError struct {
+	node    ast.Node
+	code    string
+	message string
+}
+
+// matches reports whether the rule applies to the struct name of the package
+// pkgPath.
+func (r *tagRule) matches(pkgPath, name string) bool {
+	return matchAny(r.Packages, pkgPath, true) && matchAny(r.Types, name, false)
+}
+
+// matchAny reports whether s matches one of patterns, or whether patterns is
+// empty and empty is set.
+func matchAny(patterns []string, s string, empty bool) bool {
+	if len(patterns) == 0 {
+		return empty
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns the violations of the schema by the structs declared in files
+// of the package pkgPath.
+func (s *tagSchema) check(pkgPath string, files []*ast.File) []tagError {
+	var errs []tagError
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				st, ok := spec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				for i := range s.Rules {
+					if s.Rules[i].matches(pkgPath, spec.Name.Name) {
+						errs = append(errs, s.Rules[i].check(st)...)
+					}
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// check returns the violations of the rule by the fields of st.
+func (r *tagRule) check(st *ast.StructType) []tagError {
+	var errs []tagError
+	// The fields which have a name, by key and name.
+	seen := map[string]map[string]string{}
+	for _, key := range r.Unique {
+		seen[key] = map[string]string{}
+	}
+
+	for _, field := range st.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			if s, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = reflect.StructTag(s)
+			}
+		}
+
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+
+			ignored := false
+			for _, key := range r.Required {
+				if tag.Get(key) == "-" {
+					ignored = true
+				}
+			}
+			if !ignored {
+				for _, key := range r.Required {
+					if _, ok := tag.Lookup(key); !ok {
+						errs = append(errs, tagError{
+							node:    name,
+							code:    missingTagCode,
+							message: fmt.Sprintf("field %s has no %s tag", name.Name, key),
+						})
+					}
+				}
+			}
+
+			for _, key := range r.Unique {
+				value := tag.Get(key)
+				if i := strings.IndexByte(value, ','); i >= 0 {
+					value = value[:i]
+				}
+				if value == "" || value == "-" {
+					continue
+				}
+				if other, ok := seen[key][value]; ok {
+					errs = append(errs, tagError{
+						node:    name,
+						code:    duplicateTagCode,
+						message: fmt.Sprintf("%s tag %q of field %s is already used by field %s", key, value, name.Name, other),
+					})
+					continue
+				}
+				seen[key][value] = name.Name
+			}
+		}
+	}
+	return errs
+}
+
+// diagnostics returns the diagnostics of the violations of the schema by the
+// structs of pkg, by filename.
+func (s *tagSchema) diagnostics(pkg source.Package) map[string][]lsp.Diagnostic {
+	if s == nil || len(s.Rules) == 0 {
+		return nil
+	}
+
+	fset := pkg.GetFileSet()
+	reports := map[string][]lsp.Diagnostic{}
+	for _, err := range s.check(pkg.GetPkgPath(), pkg.GetSyntax()) {
+		filename := fset.Position(err.node.Pos()).Filename
+		reports[filename] = append(reports[filename], lsp.Diagnostic{
+			Range:    rangeForNode(fset, err.node),
+			Severity: lsp.Warning,
+			Code:     err.code,
+			Source:   tagSchemaSource,
+			Message:  err.message,
+		})
+	}
+	return reports
+}
@@ -0,0 +1,50 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagSchemaCheck(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", `package p
+
+type ServerConfig struct {
+	Addr    string `+"`yaml:\"addr\" env:\"ADDR\" default:\":8080\"`"+`
+	Port    int    `+"`yaml:\"port\" env:\"ADDR\"`"+`
+	Timeout int    `+"`yaml:\"addr,omitempty\" default:\"5\"`"+`
+	Secret  string `+"`yaml:\"-\"`"+`
+	cache   int
+}
+
+type Other struct {
+	Name string
+}
+`, 0)
+	require.NoError(err)
+
+	schema := &tagSchema{Rules: []tagRule{{
+		Types:    []string{"*Config"},
+		Required: []string{"yaml", "default"},
+		Unique:   []string{"yaml", "env"},
+	}}}
+	var messages []string
+	for _, err := range schema.check("example.com/p", []*ast.File{f}) {
+		messages = append(messages, err.code+": "+err.message)
+	}
+	require.Equal([]string{
+		"missingTag: field Port has no default tag",
+		"duplicateTag: env tag \"ADDR\" of field Port is already used by field Addr",
+		"duplicateTag: yaml tag \"addr\" of field Timeout is already used by field Addr",
+	}, messages)
+
+	schema.Rules[0].Packages = []string{"example.com/other/*"}
+	require.Empty(schema.check("example.com/p", []*ast.File{f}))
+}
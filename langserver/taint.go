@@ -0,0 +1,336 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/ssa"
+)
+
+// taintCommand is the experimental workspace/executeCommand command which
+// traces the data flow from a variable to sink functions.
+const taintCommand = "bingo.taint"
+
+// maxTaintValues bounds the number of values a taint query follows.
+const maxTaintValues = 10000
+
+// defaultTaintSinks are the sinks used when Config.TaintSinks is empty.
+var defaultTaintSinks = []string{
+	"os/exec.Command",
+	"os/exec.CommandContext",
+	"(*database/sql.DB).Query*",
+	"(*database/sql.DB).Exec*",
+	"(*database/sql.Tx).Query*",
+	"(*database/sql.Tx).Exec*",
+	"(*database/sql.Conn).Query*",
+	"(*database/sql.Conn).Exec*",
+}
+
+// TaintParams is the argument of the bingo.taint command. The position
+// selects the parameter or the variable the data flows from.
+type TaintParams struct {
+	lsp.TextDocumentPositionParams
+
+	// Sinks overrides Config.TaintSinks.
+	Sinks []string `json:"sinks,omitempty"`
+}
+
+// TaintPath is a chain of locations through which the data flows from the
+// source variable to a sink.
+type TaintPath struct {
+	Sink  string      `json:"sink"`
+	Steps []TaintStep `json:"steps"`
+}
+
+// TaintStep is a location of a TaintPath.
+type TaintStep struct {
+	Description string       `json:"description"`
+	Location    lsp.Location `json:"location"`
+}
+
+func (h *LangHandler) handleTaint(ctx context.Context, params TaintParams) ([]TaintPath, error) {
+	sinks := params.Sinks
+	if len(sinks) == 0 {
+		sinks = h.config.TaintSinks
+	}
+	if len(sinks) == 0 {
+		sinks = defaultTaintSinks
+	}
+	for _, sink := range sinks {
+		if _, err := path.Match(sink, ""); err != nil {
+			return nil, requestErrorf(InvalidParamsError, "invalid sink pattern %q: %s", sink, err)
+		}
+	}
+
+	prog, sources, err := h.taintSources(ctx, params.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return prog.taintPaths(cha.CallGraph(prog.Program), sources, sinks), nil
+}
+
+// taintSources returns the SSA values of the parameter or variable at
+// position.
+func (h *LangHandler) taintSources(ctx context.Context, position lsp.TextDocumentPositionParams) (*ssaProgram, []ssa.Value, error) {
+	pkg, pos, err := h.typeCheck(ctx, position.TextDocument.URI, position.Position)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	ident, ok := pathNodes[0].(*ast.Ident)
+	if !ok {
+		return nil, nil, source.NewInvalidNodeError(pkg.GetFileSet(), pathNodes[0])
+	}
+	v, ok := pkg.GetTypesInfo().ObjectOf(ident).(*types.Var)
+	if !ok || v.IsField() {
+		return nil, nil, requestErrorf(InvalidPositionError, "not a parameter or a variable")
+	}
+
+	var fnObj *types.Func
+	for _, n := range pathNodes {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			fnObj, _ = pkg.GetTypesInfo().Defs[decl.Name].(*types.Func)
+			break
+		}
+	}
+	if fnObj == nil {
+		return nil, nil, requestErrorf(InvalidPositionError, "the variable is not declared in a function")
+	}
+
+	// The debug mode keeps track of the values of the variables.
+	prog, err := h.ssaProgram(ctx, pkg, ssa.GlobalDebug)
+	if err != nil {
+		return nil, nil, err
+	}
+	fn := prog.function(fnObj)
+	if fn == nil {
+		return nil, nil, fmt.Errorf("no function body found for %s", fnObj.FullName())
+	}
+
+	decl := pkg.GetFileSet().Position(v.Pos())
+	sources := prog.varValues(fn, v.Name(), decl)
+	if len(sources) == 0 {
+		return nil, nil, fmt.Errorf("no value found for %s", v.Name())
+	}
+	return prog, sources, nil
+}
+
+// varValues returns the values of the variable name declared at decl in fn
+// and its closures. The variable is matched by its position, because the
+// objects of the program are not the ones of the type checked package.
+func (prog *ssaProgram) varValues(fn *ssa.Function, name string, decl token.Position) []ssa.Value {
+	fset, info := prog.fsets[fn.Pkg.Pkg], prog.infos[fn.Pkg.Pkg]
+	if fset == nil || info == nil {
+		return nil
+	}
+	declared := func(obj types.Object) bool {
+		if obj == nil || obj.Name() != name {
+			return false
+		}
+		p := fset.Position(obj.Pos())
+		return p.Line == decl.Line && p.Column == decl.Column
+	}
+
+	var values []ssa.Value
+	var visit func(fn *ssa.Function)
+	visit = func(fn *ssa.Function) {
+		for _, p := range fn.Params {
+			if declared(p.Object()) {
+				values = append(values, p)
+			}
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				ref, ok := instr.(*ssa.DebugRef)
+				if !ok {
+					continue
+				}
+				if id, ok := ref.Expr.(*ast.Ident); ok && declared(info.ObjectOf(id)) {
+					values = append(values, ref.X)
+				}
+			}
+		}
+		for _, anon := range fn.AnonFuncs {
+			visit(anon)
+		}
+	}
+	visit(fn)
+	return values
+}
+
+// taintStep records how a value got tainted.
+type taintStep struct {
+	from        ssa.Value
+	instr       ssa.Instruction
+	description string
+}
+
+// taintPaths follows the data flow of sources through the instructions which
+// use them, the arguments of the calls and the returned values, and returns
+// the paths which reach a call to a function matching one of the sinks.
+func (prog *ssaProgram) taintPaths(cg *callgraph.Graph, sources []ssa.Value, sinks []string) []TaintPath {
+	paths := []TaintPath{}
+	steps := map[ssa.Value]*taintStep{}
+	var queue []ssa.Value
+	taint := func(v ssa.Value, step *taintStep) {
+		if _, ok := steps[v]; ok || len(steps) >= maxTaintValues {
+			return
+		}
+		steps[v] = step
+		queue = append(queue, v)
+	}
+	for _, v := range sources {
+		taint(v, &taintStep{description: "source " + v.Name()})
+	}
+
+	// callees returns the functions called by call.
+	callees := func(call ssa.CallInstruction) []*ssa.Function {
+		if fn := call.Common().StaticCallee(); fn != nil {
+			return []*ssa.Function{fn}
+		}
+		var fns []*ssa.Function
+		if node := cg.Nodes[call.Parent()]; node != nil {
+			for _, edge := range node.Out {
+				if edge.Site == call {
+					fns = append(fns, edge.Callee.Func)
+				}
+			}
+		}
+		return fns
+	}
+
+	seenSinks := map[ssa.Instruction]bool{}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+
+		refs := v.Referrers()
+		if refs == nil {
+			continue
+		}
+		for _, instr := range *refs {
+			switch instr := instr.(type) {
+			case *ssa.DebugRef:
+				// Not a use.
+
+			case ssa.CallInstruction:
+				common := instr.Common()
+				for _, fn := range callees(instr) {
+					if matchSink(fn, sinks) {
+						if !seenSinks[instr] {
+							seenSinks[instr] = true
+							paths = append(paths, prog.taintPath(steps, v, instr, fn))
+						}
+						continue
+					}
+					if fn.Blocks == nil {
+						continue
+					}
+					// The receiver of an invoke is not part of Args.
+					offset := 0
+					if common.IsInvoke() {
+						offset = 1
+					}
+					for i, arg := range common.Args {
+						if arg == v && i+offset < len(fn.Params) {
+							taint(fn.Params[i+offset], &taintStep{from: v, instr: instr, description: "argument of " + fn.String()})
+						}
+					}
+				}
+				// The results of the functions we cannot look into are
+				// assumed to derive from their arguments.
+				if call, ok := instr.(*ssa.Call); ok {
+					if fn := common.StaticCallee(); fn == nil || fn.Blocks == nil {
+						taint(call, &taintStep{from: v, instr: instr, description: "result of " + common.Description()})
+					}
+				}
+
+			case *ssa.Return:
+				node := cg.Nodes[instr.Parent()]
+				if node == nil {
+					continue
+				}
+				for _, edge := range node.In {
+					if call, ok := edge.Site.(*ssa.Call); ok {
+						taint(call, &taintStep{from: v, instr: instr, description: "returned by " + instr.Parent().String()})
+					}
+				}
+
+			case *ssa.Store:
+				if instr.Val == v {
+					taint(instr.Addr, &taintStep{from: v, instr: instr, description: "stored"})
+				}
+
+			case *ssa.MapUpdate:
+				taint(instr.Map, &taintStep{from: v, instr: instr, description: "stored in map"})
+
+			case *ssa.Send:
+				taint(instr.Chan, &taintStep{from: v, instr: instr, description: "sent"})
+
+			case ssa.Value:
+				taint(instr, &taintStep{from: v, instr: instr.(ssa.Instruction), description: instr.Name() + " = " + instr.String()})
+			}
+		}
+	}
+	return paths
+}
+
+// taintPath returns the path from a source to the call to sink, skipping
+// the steps which have no position.
+func (prog *ssaProgram) taintPath(steps map[ssa.Value]*taintStep, v ssa.Value, call ssa.CallInstruction, sink *ssa.Function) TaintPath {
+	p := TaintPath{Sink: sink.String()}
+	p.Steps = append(p.Steps, TaintStep{
+		Description: "call " + sink.String(),
+		Location:    prog.instrLocation(call, call.Pos()),
+	})
+
+	for ; v != nil; v = steps[v].from {
+		step := steps[v]
+		var loc lsp.Location
+		if step.instr != nil {
+			loc = prog.instrLocation(step.instr, step.instr.Pos())
+		} else if fn := v.Parent(); fn != nil && fn.Pkg != nil {
+			loc = prog.location(fn.Pkg.Pkg, v.Pos(), "")
+		}
+		if loc.URI == "" {
+			continue
+		}
+		p.Steps = append([]TaintStep{{Description: step.description, Location: loc}}, p.Steps...)
+	}
+	return p
+}
+
+// instrLocation returns the location of pos in the function of instr.
+func (prog *ssaProgram) instrLocation(instr ssa.Instruction, pos token.Pos) lsp.Location {
+	if instr == nil || instr.Parent() == nil || instr.Parent().Pkg == nil {
+		return lsp.Location{}
+	}
+	return prog.location(instr.Parent().Pkg.Pkg, pos, "")
+}
+
+// matchSink reports whether fn matches one of the sink patterns.
+func matchSink(fn *ssa.Function, sinks []string) bool {
+	name := fn.String()
+	for _, sink := range sinks {
+		if ok, _ := path.Match(sink, name); ok {
+			return true
+		}
+	}
+	return false
+}
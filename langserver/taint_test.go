@@ -0,0 +1,69 @@
+package langserver
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/ssa"
+)
+
+func TestTaintPaths(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	prog, pkg := buildTestProgram(t, `package p
+
+func handler(input string) {
+	q := "select " + input
+	run(q)
+	safe(input)
+}
+
+func run(q string) {
+	sink(q)
+}
+
+func safe(s string) {
+	sink("constant")
+}
+
+func sink(s string) {}
+`)
+
+	handler := pkg.Func("handler")
+	sources := []ssa.Value{handler.Params[0]}
+	paths := prog.taintPaths(cha.CallGraph(prog.Program), sources, []string{"p.sink"})
+	require.Len(paths, 1)
+	require.Equal("p.sink", paths[0].Sink)
+
+	var lines []int
+	for _, step := range paths[0].Steps {
+		lines = append(lines, step.Location.Range.Start.Line)
+	}
+	// source, concatenation, call to run, call to sink.
+	require.Equal([]int{2, 3, 4, 9}, lines)
+	require.Equal("call p.sink", paths[0].Steps[len(paths[0].Steps)-1].Description)
+}
+
+func TestMatchSink(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	prog, pkg := buildTestProgram(t, `package p
+
+type DB struct{}
+
+func (*DB) QueryContext() {}
+
+func Command() {}
+`)
+
+	command := pkg.Func("Command")
+	require.True(matchSink(command, []string{"p.Command"}))
+	require.False(matchSink(command, []string{"p.Comm"}))
+
+	sel := prog.MethodSets.MethodSet(types.NewPointer(pkg.Type("DB").Type())).Lookup(pkg.Pkg, "QueryContext")
+	require.True(matchSink(prog.MethodValue(sel), []string{"(*p.DB).Query*"}))
+}
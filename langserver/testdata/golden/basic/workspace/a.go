@@ -0,0 +1,6 @@
+package basic
+
+// A returns the answer.
+func A() int {
+	return 42
+}
@@ -0,0 +1,5 @@
+package basic
+
+func B() int {
+	return A() + 1
+}
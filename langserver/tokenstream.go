@@ -0,0 +1,360 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/span"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// documentTokensMethod is the notification of the changes of the semantic
+// tokens of the documents, see tokenStream.
+const documentTokensMethod = "bingo/documentTokens"
+
+// tokenStreamDelay is how long the tokens of a changed document wait for the
+// next keystroke before they are computed.
+const tokenStreamDelay = 50 * time.Millisecond
+
+// tokenLegend is the legend of the tokens of bingo/documentTokens.
+var tokenLegend = protocol.SemanticTokensLegend{
+	TokenTypes:     []string{"namespace", "type", "function", "method", "variable", "parameter", "field", "constant", "label", "keyword", "string", "number", "comment", "operator"},
+	TokenModifiers: []string{"declaration", "defaultLibrary"},
+}
+
+// The indexes of the types and the bits of the modifiers of tokenLegend.
+const (
+	namespaceToken = iota
+	typeToken
+	functionToken
+	methodToken
+	variableToken
+	parameterToken
+	fieldToken
+	constantToken
+	labelToken
+	keywordToken
+	stringToken
+	numberToken
+	commentToken
+	operatorToken
+)
+
+const (
+	declarationModifier = 1 << iota
+	defaultLibraryModifier
+)
+
+// DocumentTokensParams is the parameter of the bingo/documentTokens
+// notification.
+type DocumentTokensParams struct {
+	URI     lsp.DocumentURI `json:"uri"`
+	Version int             `json:"version"`
+
+	// Legend is set in the first notification of a document, whose edit
+	// inserts all its tokens.
+	Legend *protocol.SemanticTokensLegend `json:"legend,omitempty"`
+
+	// Edits are the edits of the tokens of the previous notification.
+	Edits []protocol.SemanticTokensEdit `json:"edits,omitempty"`
+
+	// Closed is set once the document is closed.
+	Closed bool `json:"closed,omitempty"`
+}
+
+// tokenStream notifies the client of the changes of the semantic tokens of
+// the documents of the project, including those changed by the other
+// clients sharing it, so that companion tools such as code review overlays
+// mirror the tokens of bingo rather than parse the documents themselves.
+type tokenStream struct {
+	mu     sync.Mutex
+	timers map[span.URI]*time.Timer
+	sendMu sync.Mutex            // serializes the computations and the notifications
+	tokens map[span.URI][]uint32 // as last notified
+}
+
+// newTokenStream returns the stream of the tokens if enabled, or nil.
+func newTokenStream(enabled bool) *tokenStream {
+	if !enabled {
+		return nil
+	}
+	return &tokenStream{
+		timers: map[span.URI]*time.Timer{},
+		tokens: map[span.URI][]uint32{},
+	}
+}
+
+// start streams the tokens of the documents already open when the client
+// connects.
+func (s *tokenStream) start(h *overlay) {
+	if s == nil {
+		return
+	}
+	for _, doc := range h.documents().Documents() {
+		s.changed(h, cache.DocumentChange{URI: doc.URI, New: doc})
+	}
+}
+
+// stop stops the notifications scheduled.
+func (s *tokenStream) stop() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uri, timer := range s.timers {
+		timer.Stop()
+		delete(s.timers, uri)
+	}
+}
+
+// changed schedules the notification of the tokens of a changed document,
+// or notifies that it is closed.
+func (s *tokenStream) changed(h *overlay, change cache.DocumentChange) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if timer, ok := s.timers[change.URI]; ok {
+		timer.Stop()
+		delete(s.timers, change.URI)
+	}
+	if change.New == nil {
+		go s.close(h, change.URI)
+		return
+	}
+	s.timers[change.URI] = time.AfterFunc(tokenStreamDelay, func() {
+		s.mu.Lock()
+		delete(s.timers, change.URI)
+		s.mu.Unlock()
+		s.send(h, change.URI)
+	})
+}
+
+// send notifies the client of the changes of the tokens of the document uri
+// since the previous notification.
+func (s *tokenStream) send(h *overlay, uri span.URI) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	ctx := context.Background()
+	f, err := h.view().GetFile(ctx, uri)
+	if err != nil {
+		return
+	}
+	// A later change of the document is notified later.
+	doc := h.documents().Get(uri)
+	content := f.GetContent(ctx)
+	if doc == nil || !bytes.Equal(doc.Content, content) {
+		return
+	}
+	var info *types.Info
+	if pkg := f.GetPackage(ctx); pkg != nil && !pkg.IsIllTyped() {
+		info = pkg.GetTypesInfo()
+	}
+	tokens := documentTokens(content, f.GetAST(ctx), f.GetToken(ctx), info)
+
+	params := &DocumentTokensParams{URI: lsp.DocumentURI(uri), Version: doc.Version}
+	old, ok := s.tokens[uri]
+	if !ok {
+		params.Legend = &tokenLegend
+	}
+	edit, changed := diffTokens(old, tokens)
+	if !changed && ok {
+		return
+	}
+	params.Edits = []protocol.SemanticTokensEdit{edit}
+	s.tokens[uri] = tokens
+	h.conn.Notify(ctx, documentTokensMethod, params)
+}
+
+// close notifies the client that the document uri is closed.
+func (s *tokenStream) close(h *overlay, uri span.URI) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if _, ok := s.tokens[uri]; !ok {
+		return
+	}
+	delete(s.tokens, uri)
+	h.conn.Notify(context.Background(), documentTokensMethod, &DocumentTokensParams{URI: lsp.DocumentURI(uri), Closed: true})
+}
+
+// diffTokens returns the edit of the tokens old into new, which replaces
+// the tokens between their common prefix and suffix, and whether they
+// differ.
+func diffTokens(old, new []uint32) (protocol.SemanticTokensEdit, bool) {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	prefix -= prefix % 5
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	suffix -= suffix % 5
+	edit := protocol.SemanticTokensEdit{
+		Start:       prefix,
+		DeleteCount: len(old) - prefix - suffix,
+		Data:        new[prefix : len(new)-suffix],
+	}
+	return edit, edit.DeleteCount > 0 || len(edit.Data) > 0
+}
+
+// documentTokens returns the semantic tokens of content, encoded as in
+// protocol.SemanticTokensEdit. The identifiers are classified by the objects
+// they denote if the syntax file of content is type checked with info, and
+// as variables otherwise. The tokens span one line, and their positions and
+// lengths are in UTF-16 code units.
+func documentTokens(content []byte, file *ast.File, tok *token.File, info *types.Info) []uint32 {
+	var idents map[int]*ast.Ident
+	if file != nil && tok != nil && info != nil && tok.Size() == len(content) {
+		idents = map[int]*ast.Ident{}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				idents[tok.Offset(id.Pos())] = id
+			}
+			return true
+		})
+	}
+	params := map[types.Object]bool{}
+	if idents != nil {
+		ast.Inspect(file, func(n ast.Node) bool {
+			var lists []*ast.FieldList
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				lists = append(lists, n.Recv)
+			case *ast.FuncType:
+				lists = append(lists, n.Params, n.Results)
+			}
+			for _, list := range lists {
+				if list == nil {
+					continue
+				}
+				for _, field := range list.List {
+					for _, name := range field.Names {
+						params[info.Defs[name]] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	e := &tokenEncoder{}
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(content)), content, nil, scanner.ScanComments)
+	for {
+		pos, t, lit := s.Scan()
+		if t == token.EOF {
+			break
+		}
+		position := fset.Position(pos)
+		line, character := position.Line-1, utf16Len(string(content[position.Offset-position.Column+1:position.Offset]))
+		switch {
+		case t == token.COMMENT:
+			e.add(line, character, lit, commentToken, 0)
+		case t == token.STRING || t == token.CHAR:
+			e.add(line, character, lit, stringToken, 0)
+		case t == token.INT || t == token.FLOAT || t == token.IMAG:
+			e.add(line, character, lit, numberToken, 0)
+		case t.IsKeyword():
+			e.add(line, character, lit, keywordToken, 0)
+		case t == token.IDENT:
+			id := idents[position.Offset]
+			if id != nil && id == file.Name {
+				e.add(line, character, lit, namespaceToken, declarationModifier)
+				break
+			}
+			typ, modifiers := classifyIdent(id, info, params)
+			e.add(line, character, lit, typ, modifiers)
+		case t.IsOperator() && !strings.Contains("()[]{},;.:", t.String()):
+			e.add(line, character, t.String(), operatorToken, 0)
+		}
+	}
+	return e.data
+}
+
+// classifyIdent returns the type and the modifiers of the token of id, which
+// is nil if the file is not type checked.
+func classifyIdent(id *ast.Ident, info *types.Info, params map[types.Object]bool) (uint32, uint32) {
+	if id == nil {
+		return variableToken, 0
+	}
+	var modifiers uint32
+	obj := info.Defs[id]
+	if obj != nil {
+		modifiers |= declarationModifier
+	} else {
+		obj = info.Uses[id]
+	}
+	if obj == nil {
+		return variableToken, 0
+	}
+	if obj.Parent() == types.Universe {
+		modifiers |= defaultLibraryModifier
+	}
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return namespaceToken, modifiers
+	case *types.TypeName:
+		return typeToken, modifiers
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			return methodToken, modifiers
+		}
+		return functionToken, modifiers
+	case *types.Builtin:
+		return functionToken, modifiers
+	case *types.Const, *types.Nil:
+		return constantToken, modifiers
+	case *types.Label:
+		return labelToken, modifiers
+	case *types.Var:
+		if obj.IsField() {
+			return fieldToken, modifiers
+		}
+		if params[obj] {
+			return parameterToken, modifiers
+		}
+	}
+	return variableToken, modifiers
+}
+
+// tokenEncoder encodes the tokens relatively to the previous one.
+type tokenEncoder struct {
+	data []uint32
+
+	line, character int // of the previous token
+}
+
+// add encodes the token text at the line and the character, splitting it
+// into one token per line.
+func (e *tokenEncoder) add(line, character int, text string, typ, modifiers uint32) {
+	for i, part := range strings.Split(text, "\n") {
+		if i > 0 {
+			line, character = line+1, 0
+		}
+		part = strings.TrimSuffix(part, "\r")
+		if part == "" {
+			continue
+		}
+		deltaStart := character
+		if line == e.line {
+			deltaStart -= e.character
+		}
+		e.data = append(e.data, uint32(line-e.line), uint32(deltaStart), uint32(utf16Len(part)), typ, modifiers)
+		e.line, e.character = line, character
+	}
+}
@@ -0,0 +1,150 @@
+package langserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeTokens returns the text, the type and the modifiers of the tokens
+// of content encoded in data.
+func decodeTokens(content string, data []uint32) []string {
+	lines := strings.Split(content, "\n")
+	var tokens []string
+	line, character := 0, 0
+	for i := 0; i+4 < len(data); i += 5 {
+		if data[i] > 0 {
+			character = 0
+		}
+		line += int(data[i])
+		character += int(data[i+1])
+		units := utf16.Encode([]rune(lines[line]))
+		text := string(utf16.Decode(units[character : character+int(data[i+2])]))
+		token := text + ":" + tokenLegend.TokenTypes[data[i+3]]
+		for bit, modifier := range tokenLegend.TokenModifiers {
+			if data[i+4]&(1<<uint(bit)) != 0 {
+				token += "+" + modifier
+			}
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func TestDocumentTokens(t *testing.T) {
+	src := `package p
+
+import "fmt"
+
+// T is a ≠ type.
+type T struct{ f int }
+
+func (t *T) Print(s string) error {
+	_, err := fmt.Println(t.f, s, "é", 1, nil)
+	return err
+}
+`
+	fset, f, _, info := checkStubbedPackage(t, src, nil)
+	tokens := decodeTokens(src, documentTokens([]byte(src), f, fset.File(f.Pos()), info))
+	require.Equal(t, []string{
+		"package:keyword", "p:namespace+declaration",
+		"import:keyword", `"fmt":string`,
+		"// T is a ≠ type.:comment",
+		"type:keyword", "T:type+declaration", "struct:keyword", "f:field+declaration", "int:type+defaultLibrary",
+		"func:keyword", "t:parameter+declaration", "*:operator", "T:type", "Print:method+declaration", "s:parameter+declaration", "string:type+defaultLibrary", "error:type+defaultLibrary",
+		"_:variable+declaration", "err:variable+declaration", ":=:operator", "fmt:namespace", "Println:function", "t:parameter", "f:field", "s:parameter", `"é":string`, "1:number", "nil:constant+defaultLibrary",
+		"return:keyword", "err:variable",
+	}, tokens)
+
+	// Without type information.
+	tokens = decodeTokens(src, documentTokens([]byte(src), nil, nil, nil))
+	require.Contains(t, tokens, "Print:variable")
+}
+
+func TestDiffTokens(t *testing.T) {
+	a := []uint32{0, 0, 7, 9, 0, 0, 8, 1, 0, 1}
+	b := []uint32{0, 0, 7, 9, 0, 0, 8, 2, 0, 1}
+
+	_, changed := diffTokens(a, a)
+	require.False(t, changed)
+
+	edit, changed := diffTokens(a, b)
+	require.True(t, changed)
+	require.Equal(t, protocol.SemanticTokensEdit{Start: 5, DeleteCount: 5, Data: []uint32{0, 8, 2, 0, 1}}, edit)
+
+	edit, _ = diffTokens(nil, a)
+	require.Equal(t, protocol.SemanticTokensEdit{Start: 0, DeleteCount: 0, Data: a}, edit)
+
+	// The common prefix and suffix are whole tokens.
+	c := []uint32{0, 0, 7, 9, 0, 0, 8, 1, 0, 1, 0, 2, 1, 13, 0}
+	edit, _ = diffTokens(a, c)
+	require.Equal(t, protocol.SemanticTokensEdit{Start: 10, DeleteCount: 0, Data: []uint32{0, 2, 1, 13, 0}}, edit)
+}
+
+func TestTokenStream(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	text := "package p\n\nfunc F(x int) int {\n\treturn x\n}\n"
+	root := writeWorkspace(t, map[string]string{
+		"go.mod": "module example.com/p\n",
+		"a.go":   text,
+	})
+	cfg := testConfig(cache.Ondemand)
+	cfg.TokenStream = true
+	tx := newWorkspaceContext(t, cfg, root)
+	uri := util.PathToURI(filepath.ToSlash(filepath.Join(root, "a.go")))
+
+	var mirror []uint32
+	await := func() DocumentTokensParams {
+		var params DocumentTokensParams
+		require.NoError(json.Unmarshal(tx.client.await(t, documentTokensMethod, nil), &params))
+		require.Equal(uri, params.URI)
+		for _, edit := range params.Edits {
+			mirror = append(mirror[:edit.Start:edit.Start], append(edit.Data, mirror[edit.Start+edit.DeleteCount:]...)...)
+		}
+		return params
+	}
+
+	require.NoError(tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: text},
+	}))
+	params := await()
+	require.NotNil(params.Legend)
+	require.Equal(1, params.Version)
+	require.Equal([]string{
+		"package:keyword", "p:namespace+declaration",
+		"func:keyword", "F:function+declaration", "x:parameter+declaration", "int:type+defaultLibrary", "int:type+defaultLibrary",
+		"return:keyword", "x:parameter",
+	}, decodeTokens(text, mirror))
+
+	text = "package p\n\nfunc F(x int) int {\n\treturn x + 1\n}\n"
+	require.NoError(tx.conn.Notify(tx.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+		TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: 2},
+		ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: text}},
+	}))
+	params = await()
+	require.Nil(params.Legend)
+	require.Equal(2, params.Version)
+	require.Len(params.Edits, 1)
+	require.Equal(45, params.Edits[0].Start, fmt.Sprint(params.Edits))
+	require.Equal([]string{
+		"package:keyword", "p:namespace+declaration",
+		"func:keyword", "F:function+declaration", "x:parameter+declaration", "int:type+defaultLibrary", "int:type+defaultLibrary",
+		"return:keyword", "x:parameter", "+:operator", "1:number",
+	}, decodeTokens(text, mirror))
+
+	require.NoError(tx.conn.Notify(tx.ctx, "textDocument/didClose", lsp.DidCloseTextDocumentParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+	}))
+	require.True(await().Closed)
+}
@@ -0,0 +1,154 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// The messages of the type errors of the references to unexported members
+// of other packages, of the older and the newer versions of go/types.
+var (
+	unexportedNameRegexp   = regexp.MustCompile(`^(?:name )?(\w+) not exported by package \w+$`)
+	unexportedMemberRegexp = regexp.MustCompile(`\(cannot refer to unexported (?:field or method |field |method )?(\w+)\)$`)
+)
+
+// completionOptions returns the options of the completion of the documents.
+func (h *LangHandler) completionOptions() source.CompletionOptions {
+	var opts source.CompletionOptions
+	if h.config.CompleteUnexported {
+		opts.Unexported = h.isWorkspacePackage
+	}
+	opts.Unimported = h.config.DeepCompletion
+	return opts
+}
+
+// isWorkspacePackage reports whether pkg is a cached package of the
+// workspace, whose unexported members may be exported.
+func (h *LangHandler) isWorkspacePackage(pkg *types.Package) bool {
+	p := h.project.GetFromPkgPath(pkg.Path())
+	if p == nil || len(p.GetFilenames()) == 0 {
+		return false
+	}
+	return h.project.Contain(lsp.DocumentURI(source.ToURI(p.GetFilenames()[0])))
+}
+
+// markUnexported labels the unexported symbols of workspace/symbol.
+func markUnexported(symbols []lsp.SymbolInformation) {
+	for i, sym := range symbols {
+		if !ast.IsExported(sym.Name) || sym.ContainerName != "" && !ast.IsExported(sym.ContainerName) {
+			symbols[i].ContainerName = strings.TrimSpace(sym.ContainerName + " (unexported)")
+		}
+	}
+}
+
+// exportActions returns the quick fixes which export the unexported members
+// of the packages of the workspace referenced from other packages, reported
+// by diagnostics.
+func (h *LangHandler) exportActions(ctx context.Context, uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) []protocol.CodeAction {
+	if !h.config.CompleteUnexported {
+		return nil
+	}
+
+	var actions []protocol.CodeAction
+	for _, d := range diagnostics {
+		if d.Code != typeErrorCode || !unexportedNameRegexp.MatchString(d.Message) && !unexportedMemberRegexp.MatchString(d.Message) {
+			continue
+		}
+		pkg, pos, err := h.typeCheck(ctx, uri, d.Range.Start)
+		if err != nil {
+			continue
+		}
+		pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+		if err != nil || len(pathNodes) < 2 {
+			continue
+		}
+		sel, ok := pathNodes[1].(*ast.SelectorExpr)
+		if !ok || sel.Sel != pathNodes[0] {
+			continue
+		}
+		obj, name := unexportedObject(pkg.GetTypesInfo(), sel)
+		if obj == nil || !h.isWorkspacePackage(obj.Pkg()) {
+			continue
+		}
+
+		decl := h.project.View().FileSet().Position(obj.Pos())
+		edit, err := h.handleRename(ctx, nil, nil, lsp.RenameParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: lsp.DocumentURI(source.ToURI(decl.Filename))},
+			Position:     lsp.Position{Line: decl.Line - 1, Character: decl.Column - 1},
+			NewName:      name,
+		})
+		if err != nil {
+			continue
+		}
+		// The reference is not known to the type checker.
+		rng := rangeForNode(pkg.GetFileSet(), sel.Sel)
+		edits := edit.Changes[string(uri)]
+		renamed := false
+		for _, e := range edits {
+			renamed = renamed || e.Range == rng
+		}
+		if !renamed {
+			edit.Changes[string(uri)] = append(edits, lsp.TextEdit{Range: rng, NewText: name})
+		}
+
+		actions = append(actions, protocol.CodeAction{
+			Title:       "Export " + obj.Name() + " as " + name,
+			Kind:        protocol.QuickFix,
+			Diagnostics: []lsp.Diagnostic{d},
+			Edit:        edit,
+		})
+	}
+	return actions
+}
+
+// unexportedObject returns the unexported member of another package selected
+// by sel, and its exported name, unless the name is taken.
+func unexportedObject(info *types.Info, sel *ast.SelectorExpr) (types.Object, string) {
+	name := exportedName(sel.Sel.Name)
+	if name == sel.Sel.Name {
+		return nil, ""
+	}
+
+	if id, ok := sel.X.(*ast.Ident); ok {
+		if pkgName, ok := info.Uses[id].(*types.PkgName); ok {
+			scope := pkgName.Imported().Scope()
+			if scope.Lookup(name) != nil {
+				return nil, ""
+			}
+			return scope.Lookup(sel.Sel.Name), name
+		}
+	}
+
+	T := info.TypeOf(sel.X)
+	if T == nil {
+		return nil, ""
+	}
+	if ptr, ok := T.(*types.Pointer); ok {
+		T = ptr.Elem()
+	}
+	named, ok := T.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil, ""
+	}
+	pkg := named.Obj().Pkg()
+	if obj, _, _ := types.LookupFieldOrMethod(named, true, pkg, name); obj != nil {
+		return nil, ""
+	}
+	obj, _, _ := types.LookupFieldOrMethod(named, true, pkg, sel.Sel.Name)
+	return obj, name
+}
+
+// exportedName returns name with its first letter in upper case.
+func exportedName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
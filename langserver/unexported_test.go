@@ -0,0 +1,36 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportMessages(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.True(unexportedNameRegexp.MatchString("name helper not exported by package p"))
+	require.True(unexportedNameRegexp.MatchString("helper not exported by package p"))
+	require.True(unexportedMemberRegexp.MatchString("b.data undefined (cannot refer to unexported field or method data)"))
+	require.True(unexportedMemberRegexp.MatchString("b.reset undefined (cannot refer to unexported method reset)"))
+	require.False(unexportedNameRegexp.MatchString("undeclared name: helper"))
+
+	require.Equal("Helper", exportedName("helper"))
+	require.Equal("Ärger", exportedName("ärger"))
+}
+
+func TestMarkUnexported(t *testing.T) {
+	t.Parallel()
+
+	symbols := []lsp.SymbolInformation{
+		{Name: "Exported"},
+		{Name: "helper"},
+		{Name: "Size", ContainerName: "buffer"},
+	}
+	markUnexported(symbols)
+	require.Equal(t, []string{"", "(unexported)", "buffer (unexported)"}, []string{
+		symbols[0].ContainerName, symbols[1].ContainerName, symbols[2].ContainerName,
+	})
+}
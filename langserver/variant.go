@@ -0,0 +1,90 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	lsp "github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/packages"
+)
+
+// buildVariants parses the BuildVariants. The invalid ones are logged and
+// ignored.
+func (c *Config) buildVariants() []cache.BuildVariant {
+	var variants []cache.BuildVariant
+	for _, s := range c.BuildVariants {
+		variant, err := cache.ParseBuildVariant(s)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+// variantChecker type-checks the package of a file in a build variant, see
+// cache.Project.CheckVariant.
+type variantChecker interface {
+	CheckVariant(ctx context.Context, filename string, variant cache.BuildVariant) ([]packages.Error, bool, error)
+}
+
+// variantDiagnostics type-checks the package of the file filename in each of
+// the variants the file is built in, and returns the compiler diagnostics of
+// the files of reports which are not reported in the configuration of the
+// view already. The variants of a diagnostic are noted in its message, eg.
+// "undefined: syscall.Fchflags [GOOS=linux, GOOS=windows]".
+func variantDiagnostics(ctx context.Context, checker variantChecker, filename string, variants []cache.BuildVariant, reports map[string][]lsp.Diagnostic) map[string][]lsp.Diagnostic {
+	type key struct {
+		filename string
+		line     int
+		column   int
+		message  string
+	}
+	found := map[key][]string{}
+	var keys []key
+	var diagnostics []lsp.Diagnostic
+	for _, variant := range variants {
+		errs, ok, err := checker.CheckVariant(ctx, filename, variant)
+		if err != nil {
+			log.Printf("type-check %s in %s: %s", filename, variant, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for _, err := range compilerErrors(errs) {
+			name, diagnostic := compilerDiagnostic(err)
+			if _, ok := reports[name]; !ok || hasDiagnostic(reports[name], diagnostic) {
+				continue
+			}
+			k := key{name, diagnostic.Range.Start.Line, diagnostic.Range.Start.Character, diagnostic.Message}
+			if _, ok := found[k]; !ok {
+				keys = append(keys, k)
+				diagnostics = append(diagnostics, diagnostic)
+			}
+			found[k] = append(found[k], variant.String())
+		}
+	}
+
+	result := map[string][]lsp.Diagnostic{}
+	for i, k := range keys {
+		diagnostic := diagnostics[i]
+		diagnostic.Message += " [" + strings.Join(found[k], ", ") + "]"
+		result[k.filename] = append(result[k.filename], diagnostic)
+	}
+	return result
+}
+
+// hasDiagnostic reports whether diagnostics has a diagnostic of the same
+// range and message as diagnostic.
+func hasDiagnostic(diagnostics []lsp.Diagnostic, diagnostic lsp.Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Range == diagnostic.Range && d.Message == diagnostic.Message {
+			return true
+		}
+	}
+	return false
+}
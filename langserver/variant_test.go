@@ -0,0 +1,47 @@
+package langserver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	lsp "github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/packages"
+)
+
+// fakeVariantChecker returns the errors of the packages by the GOOS of the
+// variants, a GOOS without errors not being built.
+type fakeVariantChecker map[string][]packages.Error
+
+func (c fakeVariantChecker) CheckVariant(ctx context.Context, filename string, variant cache.BuildVariant) ([]packages.Error, bool, error) {
+	errs, ok := c[variant.GOOS]
+	return errs, ok, nil
+}
+
+func TestVariantDiagnostics(t *testing.T) {
+	undefined := packages.Error{Pos: "/src/p/p.go:3:2", Msg: "undefined: unix.Fchflags", Kind: packages.TypeError}
+	checker := fakeVariantChecker{
+		"linux":   {undefined},
+		"windows": {undefined, {Pos: "/src/p/p.go:5:1", Msg: "missing return", Kind: packages.TypeError}},
+		"darwin":  {},
+		"plan9":   {{Pos: "/src/p/other.go:1:1", Msg: "undefined: x", Kind: packages.TypeError}},
+	}
+	variants := []cache.BuildVariant{{GOOS: "linux"}, {GOOS: "windows"}, {GOOS: "darwin"}, {GOOS: "js"}, {GOOS: "plan9"}}
+	reports := map[string][]lsp.Diagnostic{
+		"/src/p/p.go": {{Range: lsp.Range{Start: lsp.Position{Line: 4}, End: lsp.Position{Line: 4}}, Message: "missing return"}},
+	}
+
+	got := variantDiagnostics(context.Background(), checker, "/src/p/p.go", variants, reports)
+	var messages []string
+	for _, diagnostic := range got["/src/p/p.go"] {
+		messages = append(messages, diagnostic.Message)
+	}
+	want := []string{"undefined: unix.Fchflags [GOOS=linux, GOOS=windows]"}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("got %q, want %q", messages, want)
+	}
+	if len(got) != 1 {
+		t.Errorf("got diagnostics of %d files, want 1: %v", len(got), got)
+	}
+}
@@ -0,0 +1,42 @@
+package langserver
+
+import (
+	"context"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// watchedFilesRegistration is the id of the registration of the watchers of
+// workspace/didChangeWatchedFiles.
+const watchedFilesRegistration = "bingo.watchedFiles"
+
+// watchedFiles are the glob patterns of the files whose changes outside of
+// the editor invalidate the packages of the project: the go.mod and go.sum
+// files, whose changes re-resolve the dependencies, and the Go files, whose
+// creation or deletion changes the files of their package.
+var watchedFiles = []string{"**/go.mod", "**/go.sum", "**/*.go"}
+
+// registerWatchers asks the client to send workspace/didChangeWatchedFiles
+// for the watchedFiles, if it supports the dynamic registration of the
+// watchers.
+func (h *LangHandler) registerWatchers(ctx context.Context, conn jsonrpc2.JSONRPC2) {
+	if h.init == nil || !h.init.Capabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration {
+		return
+	}
+
+	var options protocol.DidChangeWatchedFilesRegistrationOptions
+	for _, pattern := range watchedFiles {
+		options.Watchers = append(options.Watchers, protocol.FileSystemWatcher{GlobPattern: pattern})
+	}
+	err := conn.Call(ctx, "client/registerCapability", &protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:              watchedFilesRegistration,
+			Method:          "workspace/didChangeWatchedFiles",
+			RegisterOptions: options,
+		}},
+	}, nil)
+	if err != nil {
+		h.notifyLog("register the watchers of the go.mod and Go files: " + err.Error())
+	}
+}
@@ -0,0 +1,221 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"reflect"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+)
+
+// wirePath is the import path of google/wire.
+const wirePath = "github.com/google/wire"
+
+// wireFramework supports google/wire. Its injectors are only checked if the
+// files of the package with the wireinject build tag are loaded.
+type wireFramework struct{}
+
+func init() {
+	registerFramework(wireFramework{})
+}
+
+func (wireFramework) name() string {
+	return "wire"
+}
+
+func (wireFramework) registrations(pkg *frameworkPackage) []registration {
+	var regs []registration
+	for _, call := range callsOf(pkg, wirePath, "NewSet", "Build") {
+		for _, arg := range call.Args {
+			switch obj := referencedObject(pkg.info, arg).(type) {
+			case *types.Func, *types.Var:
+				regs = append(regs, registration{call: call, arg: arg, obj: obj})
+			}
+		}
+	}
+	return regs
+}
+
+// check reports the providers of the wire.Build calls of the injectors
+// whose parameters are neither provided nor parameters of the injector, and
+// the injectors whose result is not provided.
+func (wireFramework) check(pkg *frameworkPackage) []frameworkError {
+	var errs []frameworkError
+	for _, call := range callsOf(pkg, wirePath, "Build") {
+		decl := enclosingFunc(pkg, call)
+		if decl == nil {
+			continue
+		}
+		fn, ok := pkg.info.Defs[decl.Name].(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+
+		set := newProviderSet(pkg)
+		for _, arg := range call.Args {
+			addWireProvider(set, pkg, arg, nil)
+		}
+		if set.unsupported {
+			continue
+		}
+
+		var given []types.Type
+		for i := 0; i < sig.Params().Len(); i++ {
+			given = append(given, sig.Params().At(i).Type())
+		}
+		if sig.Results().Len() > 0 {
+			// The injector consumes its result.
+			set.providers = append(set.providers, provider{
+				name:  decl.Name.Name,
+				node:  call,
+				needs: []types.Type{sig.Results().At(0).Type()},
+			})
+		}
+		errs = append(errs, set.missing(given, nil)...)
+	}
+	return errs
+}
+
+// addWireProvider adds the providers of the wire.Build or wire.NewSet
+// argument expr of pkg to set.
+func addWireProvider(set *providerSet, pkg *frameworkPackage, expr ast.Expr, report ast.Node) {
+	if call, ok := expr.(*ast.CallExpr); ok {
+		if path, name := calledFunc(pkg.info, call); path == wirePath {
+			addWireCall(set, pkg, name, call, report)
+			return
+		}
+	}
+
+	if v, ok := referencedObject(pkg.info, expr).(*types.Var); ok && isWireProviderSet(v.Type()) {
+		set.expandVar(pkg, v, expr, report, func(pkg *frameworkPackage, init ast.Expr, report ast.Node) {
+			addWireProvider(set, pkg, init, report)
+		})
+		return
+	}
+
+	sig, ok := pkg.info.TypeOf(expr).(*types.Signature)
+	if !ok || sig.Results().Len() == 0 {
+		set.unsupported = true
+		return
+	}
+	var needs []types.Type
+	for i := 0; i < sig.Params().Len(); i++ {
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			break
+		}
+		needs = append(needs, sig.Params().At(i).Type())
+	}
+	set.add(expr, report, needs, []types.Type{sig.Results().At(0).Type()})
+}
+
+// addWireCall adds the providers of the call to the wire function name.
+func addWireCall(set *providerSet, pkg *frameworkPackage, name string, call *ast.CallExpr, report ast.Node) {
+	// elem returns the type pointed to by the argument i, eg. new(T).
+	elem := func(i int) types.Type {
+		if i >= len(call.Args) {
+			return nil
+		}
+		if ptr, ok := pkg.info.TypeOf(call.Args[i]).(*types.Pointer); ok {
+			return ptr.Elem()
+		}
+		return nil
+	}
+
+	if len(call.Args) == 0 {
+		set.unsupported = true
+		return
+	}
+
+	switch name {
+	case "NewSet":
+		for _, arg := range call.Args {
+			addWireProvider(set, pkg, arg, report)
+		}
+
+	case "Bind":
+		iface, impl := elem(0), elem(1)
+		if iface == nil || impl == nil {
+			set.unsupported = true
+			return
+		}
+		set.add(call, report, []types.Type{impl}, []types.Type{iface})
+
+	case "Value":
+		if len(call.Args) != 1 {
+			set.unsupported = true
+			return
+		}
+		set.add(call, report, nil, []types.Type{pkg.info.TypeOf(call.Args[0])})
+
+	case "InterfaceValue":
+		iface := elem(0)
+		if iface == nil {
+			set.unsupported = true
+			return
+		}
+		set.add(call, report, nil, []types.Type{iface})
+
+	case "Struct":
+		t := elem(0)
+		fields, ok := wireFields(pkg, t, call.Args[1:])
+		if !ok {
+			set.unsupported = true
+			return
+		}
+		set.add(call, report, fields, []types.Type{t, types.NewPointer(t)})
+
+	case "FieldsOf":
+		t := elem(0)
+		fields, ok := wireFields(pkg, source.Deref(t), call.Args[1:])
+		if !ok {
+			set.unsupported = true
+			return
+		}
+		set.add(call, report, []types.Type{t}, fields)
+
+	default:
+		set.unsupported = true
+	}
+}
+
+// wireFields returns the types of the fields of the struct t named by the
+// string constants args, "*" standing for all the fields which are not
+// tagged `wire:"-"`.
+func wireFields(pkg *frameworkPackage, t types.Type, args []ast.Expr) ([]types.Type, bool) {
+	if t == nil {
+		return nil, false
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return nil, false
+	}
+
+	var fields []types.Type
+	for _, arg := range args {
+		tv, ok := pkg.info.Types[arg]
+		if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+			return nil, false
+		}
+		name := constant.StringVal(tv.Value)
+		found := false
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			if name == "*" && reflect.StructTag(st.Tag(i)).Get("wire") != "-" || f.Name() == name {
+				fields = append(fields, f.Type())
+				found = true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+// isWireProviderSet reports whether t is wire.ProviderSet.
+func isWireProviderSet(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == wirePath && named.Obj().Name() == "ProviderSet"
+}
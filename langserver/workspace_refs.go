@@ -141,7 +141,7 @@ func defSymbolDescriptor(pkg source.Package, project *cache.Project, def refs.De
 			return nil, err
 		}
 		if defPkg == nil {
-			return nil, fmt.Errorf("package %s does not exist", def.ImportPath)
+			return nil, packageErrorf(NotFoundError, def.ImportPath, "package %s does not exist", def.ImportPath)
 		}
 	}
 
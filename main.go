@@ -9,34 +9,90 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/saibing/bingo/langserver"
 	"github.com/sourcegraph/jsonrpc2"
+	wsjsonrpc2 "github.com/sourcegraph/jsonrpc2/websocket"
 
 	_ "net/http/pprof"
 )
 
 var (
-	mode         = flag.String("mode", "stdio", "communication mode (stdio|tcp)")
-	addr         = flag.String("addr", ":4389", "server listen address (tcp)")
+	mode         = flag.String("mode", "stdio", "communication mode (stdio|tcp|websocket)")
+	addr         = flag.String("addr", ":4389", "server listen address (tcp|websocket)")
+	framing      = flag.String("framing", "header", "framing of the messages (stdio|tcp): header for the Content-Length headers of LSP, line for newline-delimited JSON")
+	stallTimeout = flag.Int("stall-timeout", 0, "disconnect a client which has not read a message for N seconds, buffering the messages meanwhile, 0 never does.")
 	trace        = flag.Bool("trace", false, "print all requests and responses")
 	logfile      = flag.String("logfile", "", "also log to this file (in addition to stderr)")
 	printVersion = flag.Bool("version", false, "print version and exit")
 	freeosmemory = flag.Int("freeosmemory", 0, "the interval time that aggressively free memory back to the OS, unit is second, default value is 0, means no free memroy back to the OS")
-	pprof        = flag.String("pprof", "", "start a pprof http server (https://golang.org/pkg/net/http/pprof/)")
+	pprof        = flag.String("pprof", "", "start a pprof http server (https://golang.org/pkg/net/http/pprof/), which also serves the status of bingo at /debug/bingo")
 
 	// Default Config, can be overridden by InitializationOptions
-	maxparallelism       = flag.Int("maxparallelism", 0, "use at max N parallel goroutines to fulfill requests. Can be overridden by InitializationOptions.")
-	diagnosticsStyle     = flag.String("diagnostics-style", "instant", "diagnostics style: none, instant, onsave. Can be overridden by InitializationOptions.")
-	disableFuncSnippet   = flag.Bool("disable-func-snippet", false, "disable argument snippets on func completion. Can be overridden by InitializationOptions.")
-	globalCacheStyle     = flag.String("cache-style", "always", "set global cache style: none, on-demand, always. Can be overridden by InitializationOptions.")
-	formatStyle          = flag.String("format-style", "goimports", "which format style is used to format documents. Supported: gofmt and goimports. Can be overridden by InitializationOptions.")
-	goimportsPrefix      = flag.String("goimports-prefix", "", "set '--local' flag for the goimports invocation. Can be overridden by InitializationOptions.")
-	enhanceSignatureHelp = flag.Bool("enhance-signature-help", false, "enhance signature help with return result. Can be overridden by InitializationOptions.")
-	buildTags            = flag.String("build-tags", "", "build tags, separated by spaces.")
+	maxparallelism         = flag.Int("maxparallelism", 0, "use at max N parallel goroutines to fulfill requests. Can be overridden by InitializationOptions.")
+	slowRequestThreshold   = flag.Int("slow-request-threshold", 0, "log the requests which take longer than N milliseconds, with the time spent in each phase, 0 logs none.")
+	slowRequestProfileDir  = flag.String("slow-request-profile-dir", "", "write the CPU profiles of the slow requests to this directory, from the moment they exceed -slow-request-threshold.")
+	keepalive              = flag.Int("keepalive", 0, "send a bingo/ping request to the client every N seconds, and disconnect it if it does not answer within N seconds, 0 sends none.")
+	maxRequestsPerSecond   = flag.Int("max-requests-per-second", 0, "reject hover, completion, signature help and definition requests above N per second and method, 0 means unlimited.")
+	diagnosticsStyle       = flag.String("diagnostics-style", "instant", "diagnostics style: none, instant, onsave. Can be overridden by InitializationOptions.")
+	diagnosticsHistory     = flag.Int("diagnostics-history", 10, "the number of the last published sets of diagnostics kept per document for the bingo/diagnosticsHistory request, 0 disables the history. Can be overridden by InitializationOptions.")
+	disableFuncSnippet     = flag.Bool("disable-func-snippet", false, "disable argument snippets on func completion. Can be overridden by InitializationOptions.")
+	globalCacheStyle       = flag.String("cache-style", "always", "set global cache style: none, on-demand, always. Can be overridden by InitializationOptions.")
+	indexingProgress       = flag.String("indexing-progress", "progress", "how the progress of the loading of the packages in the always cache style is reported: progress, message, none. Can be overridden by InitializationOptions.")
+	globalCacheProfile     = flag.String("cache-profile", "full", "what is loaded into the global cache: full, or navigation for the types only of the dependencies. Can be overridden by InitializationOptions.")
+	globalCacheMemory      = flag.Int("cache-memory", 0, "the budget, in megabytes, of the syntax and type information of the cached packages outside of the workspace. 0 means unbounded. Can be overridden by InitializationOptions.")
+	gcPercent              = flag.Int("gc-percent", 0, "the garbage collection target percentage, 0 keeps the default of the runtime. GOGC takes precedence.")
+	memoryLimit            = flag.Int("memory-limit", 0, "the soft memory limit, in megabytes, 0 derives it from -cache-memory. GOMEMLIMIT takes precedence.")
+	formatStyle            = flag.String("format-style", "goimports", "which format style is used to format documents. Supported: gofmt and goimports. Can be overridden by InitializationOptions.")
+	goimportsPrefix        = flag.String("goimports-prefix", "", "set '--local' flag for the goimports invocation. Can be overridden by InitializationOptions.")
+	enhanceSignatureHelp   = flag.Bool("enhance-signature-help", false, "enhance signature help with return result. Can be overridden by InitializationOptions.")
+	buildTags              = flag.String("build-tags", "", "build tags, separated by spaces.")
+	buildVariants          = flag.String("build-variants", "", "build configurations the open files are also type-checked in, separated by semicolons, e.g. GOOS=darwin;GOOS=windows tags=integration. Can be overridden by InitializationOptions.")
+	coverageOnSave         = flag.Bool("coverage-on-save", false, "run the tests of the package of a test file with coverage when it is saved. Can be overridden by InitializationOptions.")
+	coverageDiagnostics    = flag.Bool("coverage-diagnostics", false, "report the blocks which are not covered by the tests as hint diagnostics. Can be overridden by InitializationOptions.")
+	documentColor          = flag.Bool("document-color", false, "enable document colors for color.RGBA literals and \"#RRGGBB\" strings. Can be overridden by InitializationOptions.")
+	renameFiles            = flag.Bool("rename-files", false, "rename the file of a type along with the type when the file is named after it. Can be overridden by InitializationOptions.")
+	referencesCodeLens     = flag.Bool("references-code-lens", false, "show the number of references above exported functions and types. Can be overridden by InitializationOptions.")
+	implementationCodeLens = flag.Bool("implementation-code-lens", false, "show the implementations of interfaces and the interface methods implemented by methods. Can be overridden by InitializationOptions.")
+	runCodeLens            = flag.Bool("run-code-lens", false, "show a code lens which runs func main with go run. Can be overridden by InitializationOptions.")
+	runFlags               = flag.String("run-flags", "", "flags passed to go run by the run code lens, separated by spaces.")
+	runEnv                 = flag.String("run-env", "", "KEY=VALUE environment variables of go run by the run code lens, separated by commas.")
+	taintSinks             = flag.String("taint-sinks", "", "function patterns the experimental bingo.taint command traces data flows to, separated by commas. Can be overridden by InitializationOptions.")
+	diagnosticsSeverity    = flag.String("diagnostics-severity", "", "CODE_OR_SOURCE=SEVERITY remappings of the severity of diagnostics, separated by commas, e.g. unusedVariable=hint. Can be overridden by InitializationOptions.")
+	completionMatcher      = flag.String("completion-matcher", "fuzzy", "how the completion candidates match the identifier being typed: prefix, fuzzy. Can be overridden by InitializationOptions.")
+	deepCompletion         = flag.Bool("deep-completion", false, "complete the exported members of the cached packages which are not imported yet, inserting their import. Can be overridden by InitializationOptions.")
+	fixOnSave              = flag.String("fix-on-save", "none", "fixes pushed with workspace/applyEdit, once confirmed, when a document is saved: imports, all, none. Can be overridden by InitializationOptions.")
+	completeUnexported     = flag.Bool("complete-unexported", false, "complete the unexported members of the other packages of the workspace, marked as not accessible, with a quick fix which exports them. Can be overridden by InitializationOptions.")
+	analyses               = flag.String("analyses", "", "NAME=true|false enablements of the analyzers of the diagnostics, separated by commas, e.g. shadow=true,printf=false. Can be overridden by InitializationOptions.")
+	nolintMarker           = flag.String("nolint-marker", "nolint", "marker of the //nolint:<code> comments which suppress the diagnostics of their line. Can be overridden by InitializationOptions.")
+	testCodeLens           = flag.Bool("test-code-lens", false, "show a code lens which runs test and benchmark functions with go test. Can be overridden by InitializationOptions.")
+	goroot                 = flag.String("goroot", "", "root of the Go toolchain of the workspace, relative to the workspace root, e.g. a toolchain checked into the repository. Can be overridden by InitializationOptions.")
+	offline                = flag.Bool("offline", false, "avoid any network access: run the go command with GOPROXY=off and report the missing modules. Can be overridden by InitializationOptions.")
+	playgroundURL          = flag.String("playground-url", "https://play.golang.org", "URL of the Go Playground, or of a proxy of it, the bingo.playground.share command uploads the programs to. Can be overridden by InitializationOptions.")
+	wrapErrors             = flag.Bool("wrap-errors", false, "wrap the errors returned by the inserted error checks with fmt.Errorf and the name of the called function. Can be overridden by InitializationOptions.")
+	autoPackageClause      = flag.Bool("auto-package-clause", false, "insert the package clause and the license header in the empty Go files when they are opened. Can be overridden by InitializationOptions.")
+	licenseHeader          = flag.String("license-header", "", "text/template of the license header inserted above the package clause of the empty Go files, e.g. \"Copyright {{.Year}} Acme\". Can be overridden by InitializationOptions.")
+	tagSchemaFile          = flag.String("tag-schema-file", "", "JSON file of rules the struct tags of the workspace are checked against, relative to the workspace root. Can be overridden by InitializationOptions.")
+	routeIndex             = flag.Bool("route-index", false, "index the HTTP routes of net/http, gorilla/mux, chi and gin for workspace/symbol and definition. Can be overridden by InitializationOptions.")
+	frameworks             = flag.String("frameworks", "", "dependency injection frameworks whose provider sets are checked, separated by commas: wire, fx. Can be overridden by InitializationOptions.")
+	inlineCompletion       = flag.String("inline-completion", "", "providers of the inline completions, in order, separated by commas: iferr, structfill, template. Empty uses them all. Can be overridden by InitializationOptions.")
+	mockBackend            = flag.String("mock-backend", "builtin", "generator of the mocks of the bingo.mock command: builtin, mockgen or moq. Can be overridden by InitializationOptions.")
+	enumCodeLens           = flag.Bool("enum-code-lens", false, "show a code lens which generates the String method of const enum types. Can be overridden by InitializationOptions.")
+	commandAllowlist       = flag.String("command-allowlist", "", "commands which run code of the workspace without confirmation, separated by commas, e.g. bingo.run. Cannot be overridden by InitializationOptions.")
+	scrubCommandEnv        = flag.Bool("scrub-command-env", false, "only pass the variables the go command needs to the commands which run code of the workspace. Cannot be overridden by InitializationOptions.")
+	readOnly               = flag.Bool("read-only", false, "disable the formatting, the rename, the code actions and the commands which edit the documents or run tools, e.g. for a code browsing web UI.")
+	tokenStream            = flag.Bool("token-stream", false, "stream the changes of the semantic tokens of the documents with the bingo/documentTokens notification, for companion tools. Can be overridden by InitializationOptions.")
+	allowedRoots           = flag.String("allowed-roots", "", "directories besides the workspace, GOROOT and the module cache whose files the requests may refer to, separated by commas.")
+	auditLog               = flag.String("audit-log", "", "append the edits of the formatting, the rename and the commands, and the commands run with their exit codes, to this file.")
+	sessionFile            = flag.String("session-file", "", "persist open documents and published diagnostics to this file, so that they survive a restart. Can be overridden by InitializationOptions.")
+	disabledFeatures       = flag.String("disabled-features", "", "disabled features, separated by commas, e.g. documentFormatting,workspaceSymbol,diagnostics. Can be overridden by InitializationOptions.")
 
 	// Compatible with sourcegraph/go-langserver, ensuring that ide-go can run, but no actual effect
 	// https://github.com/saibing/bingo/issues/163
@@ -57,8 +113,16 @@ func main() {
 	flag.Parse()
 	log.SetFlags(0)
 
+	if flag.Arg(0) == "cache" {
+		if err := cacheCommand(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Start pprof server, if desired.
 	if *pprof != "" {
+		http.Handle("/debug/bingo", langserver.DebugHandler())
 		go func() {
 			log.Println(http.ListenAndServe(*pprof, nil))
 		}()
@@ -71,15 +135,112 @@ func main() {
 	cfg := langserver.NewDefaultConfig()
 	cfg.DisableFuncSnippet = *disableFuncSnippet
 	cfg.DiagnosticsStyle = *diagnosticsStyle
+	cfg.DiagnosticsHistory = *diagnosticsHistory
 	cfg.GlobalCacheStyle = *globalCacheStyle
+	cfg.GlobalCacheMemory = *globalCacheMemory
+	cfg.GCPercent = *gcPercent
+	cfg.MemoryLimit = *memoryLimit
+	cfg.GlobalCacheProfile = *globalCacheProfile
+	cfg.IndexingProgress = *indexingProgress
 	cfg.FormatStyle = *formatStyle
 	cfg.GoimportsLocalPrefix = *goimportsPrefix
 	cfg.EnhanceSignatureHelp = *enhanceSignatureHelp
+	cfg.SessionFile = *sessionFile
+	cfg.AuditLog = *auditLog
+	cfg.TokenStream = *tokenStream
+	cfg.ReadOnly = *readOnly
+	cfg.DocumentColor = *documentColor
+	cfg.CoverageOnSave = *coverageOnSave
+	cfg.CoverageDiagnostics = *coverageDiagnostics
+	cfg.RenameFiles = *renameFiles
+	cfg.ReferencesCodeLens = *referencesCodeLens
+	cfg.ImplementationCodeLens = *implementationCodeLens
+	cfg.RunCodeLens = *runCodeLens
+	cfg.ScrubCommandEnv = *scrubCommandEnv
+	cfg.EnumCodeLens = *enumCodeLens
+	cfg.MockBackend = *mockBackend
+	cfg.RouteIndex = *routeIndex
+	cfg.TagSchemaFile = *tagSchemaFile
+	cfg.AutoPackageClause = *autoPackageClause
+	cfg.LicenseHeader = *licenseHeader
+	cfg.WrapErrors = *wrapErrors
+	cfg.PlaygroundURL = *playgroundURL
+	cfg.Offline = *offline
+	cfg.GOROOT = *goroot
+	cfg.TestCodeLens = *testCodeLens
+	cfg.NolintMarker = *nolintMarker
+	cfg.CompleteUnexported = *completeUnexported
+	cfg.CompletionMatcher = *completionMatcher
+	cfg.DeepCompletion = *deepCompletion
+	cfg.FixOnSave = *fixOnSave
+	cfg.MaxRequestsPerSecond = *maxRequestsPerSecond
+	cfg.SlowRequestThreshold = *slowRequestThreshold
+	cfg.SlowRequestProfileDir = *slowRequestProfileDir
+	cfg.KeepaliveInterval = *keepalive
 
 	if *buildTags != "" {
 		cfg.BuildTags = strings.Split(*buildTags, " ")
 	}
 
+	if *buildVariants != "" {
+		cfg.BuildVariants = strings.Split(*buildVariants, ";")
+	}
+
+	if *runFlags != "" {
+		cfg.RunFlags = strings.Split(*runFlags, " ")
+	}
+
+	if *runEnv != "" {
+		cfg.RunEnv = strings.Split(*runEnv, ",")
+	}
+
+	if *taintSinks != "" {
+		cfg.TaintSinks = strings.Split(*taintSinks, ",")
+	}
+
+	if *diagnosticsSeverity != "" {
+		cfg.DiagnosticsSeverity = map[string]string{}
+		for _, kv := range strings.Split(*diagnosticsSeverity, ",") {
+			if i := strings.LastIndex(kv, "="); i > 0 {
+				cfg.DiagnosticsSeverity[kv[:i]] = kv[i+1:]
+			}
+		}
+	}
+
+	if *analyses != "" {
+		cfg.Analyses = map[string]bool{}
+		for _, kv := range strings.Split(*analyses, ",") {
+			if i := strings.LastIndex(kv, "="); i > 0 {
+				enable, err := strconv.ParseBool(kv[i+1:])
+				if err != nil {
+					log.Printf("invalid enablement %q of analyzer %q", kv[i+1:], kv[:i])
+					continue
+				}
+				cfg.Analyses[kv[:i]] = enable
+			}
+		}
+	}
+
+	if *frameworks != "" {
+		cfg.Frameworks = strings.Split(*frameworks, ",")
+	}
+
+	if *inlineCompletion != "" {
+		cfg.InlineCompletion = strings.Split(*inlineCompletion, ",")
+	}
+
+	if *commandAllowlist != "" {
+		cfg.CommandAllowlist = strings.Split(*commandAllowlist, ",")
+	}
+
+	if *allowedRoots != "" {
+		cfg.AllowedRoots = strings.Split(*allowedRoots, ",")
+	}
+
+	if *disabledFeatures != "" {
+		cfg.DisabledFeatures = strings.Split(*disabledFeatures, ",")
+	}
+
 	if *maxparallelism > 0 {
 		cfg.MaxParallelism = *maxparallelism
 	}
@@ -109,13 +270,20 @@ func run(cfg langserver.Config) error {
 	}
 	log.SetOutput(logW)
 
+	langserver.TuneGC(cfg)
+
 	var connOpt []jsonrpc2.ConnOpt
 	if *trace {
 		connOpt = append(connOpt, jsonrpc2.LogMessages(log.New(logW, "", 0)))
 	}
 
+	stall := time.Duration(*stallTimeout) * time.Second
+
+	// The clients of a tcp or websocket server share the projects of their
+	// workspaces.
+	pool := langserver.NewProjectPool()
 	newHandler := func() jsonrpc2.Handler {
-		return langserver.NewHandler(cfg)
+		return langserver.NewSharedHandler(cfg, pool)
 	}
 
 	switch *mode {
@@ -132,12 +300,41 @@ func run(cfg langserver.Config) error {
 			if err != nil {
 				return err
 			}
-			jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(conn, jsonrpc2.VSCodeObjectCodec{}), newHandler(), connOpt...)
+			stream, err := langserver.NewStream(conn, *framing)
+			if err != nil {
+				return err
+			}
+			jsonrpc2.NewConn(context.Background(), langserver.WithStallTimeout(stream, stall), newHandler(), connOpt...)
 		}
 
+	case "websocket":
+		lis, err := net.Listen("tcp", *addr)
+		if err != nil {
+			return err
+		}
+		defer lis.Close()
+
+		log.Println("langserver-go: listening for websocket connections on", *addr)
+		upgrader := websocket.Upgrader{}
+		return http.Serve(lis, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				log.Println("websocket upgrade:", err)
+				return
+			}
+			jsonrpc2.NewConn(context.Background(), langserver.WithStallTimeout(wsjsonrpc2.NewObjectStream(conn), stall), newHandler(), connOpt...)
+		}))
+
 	case "stdio":
+		stream, err := langserver.NewStream(stdrwc{}, *framing)
+		if err != nil {
+			return err
+		}
+		// A client which exits closes stdout, whose writes must fail rather
+		// than kill the server with SIGPIPE, so that it shuts down.
+		signal.Ignore(syscall.SIGPIPE)
 		log.Println("langserver-go: reading on stdin, writing on stdout")
-		<-jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(stdrwc{}, jsonrpc2.VSCodeObjectCodec{}), newHandler(), connOpt...).DisconnectNotify()
+		<-jsonrpc2.NewConn(context.Background(), langserver.WithStallTimeout(stream, stall), newHandler(), connOpt...).DisconnectNotify()
 		log.Println("connection closed")
 		return nil
 
@@ -163,6 +360,58 @@ func (stdrwc) Close() error {
 	return os.Stdout.Close()
 }
 
+// cacheCommand runs "bingo cache export|import [-root dir] archive", which
+// exports the disk cache of a workspace to an archive, or imports it.
+func cacheCommand(args []string) error {
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	root := fs.String("root", ".", "the root directory of the workspace")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: bingo cache export|import [-root dir] archive")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	command := args[0]
+	_ = fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	switch command {
+	case "export":
+		f, err := os.Create(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		n, err := langserver.ExportCache(*root, f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(fs.Arg(0))
+			return err
+		}
+		log.Printf("exported %d packages to %s", n, fs.Arg(0))
+	case "import":
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n, err := langserver.ImportCache(*root, f)
+		if err != nil {
+			return err
+		}
+		log.Printf("imported %d packages from %s", n, fs.Arg(0))
+	default:
+		return fmt.Errorf("unknown cache command %q", command)
+	}
+	return nil
+}
+
 // freeOSMemory should be called in a goroutine, it invokes
 // runtime/debug.FreeOSMemory() more aggressively than the runtime default of
 // 5 minutes after GC.